@@ -0,0 +1,105 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command conformance runs the conformance suite against a running validator and prints the result of
+// each case and capability.  The validator must be started with the suite's policies, which
+// -writePolicies writes out:
+//
+//	conformance -writePolicies /tmp/suite
+//	server -policyPath /tmp/suite/policies -policyLibraryPath /tmp/suite/library -port 10000 &
+//	conformance -endpoint localhost:10000
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/conformance"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var (
+	endpoint      = flag.String("endpoint", "localhost:10000", "Address of the validator under test, as host:port.")
+	useTLS        = flag.Bool("tls", false, "Connect to the endpoint with TLS.")
+	suiteDir      = flag.String("suite", "", "Directory of a suite with the layout of pkg/conformance/suite, the published suite is used when unset.")
+	capabilities  = flag.String("capabilities", "", "Capabilities to check, separated by comma, all of the suite's capabilities when unset.")
+	timeout       = flag.Duration("timeout", 5*time.Minute, "Time allowed for the whole suite.")
+	writePolicies = flag.String("writePolicies", "", "Write the suite's policies to policies/ and library/ in this directory, to start the validator under test with, then exit.")
+)
+
+func main() {
+	flag.Parse()
+	os.Exit(run())
+}
+
+// run runs the suite and returns the exit status.
+func run() int {
+	suite, err := loadSuite()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load suite: %v\n", err)
+		return 2
+	}
+	if *writePolicies != "" {
+		if err := suite.WritePolicies(*writePolicies); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write policies: %v\n", err)
+			return 2
+		}
+		return 0
+	}
+
+	creds := insecure.NewCredentials()
+	if *useTLS {
+		creds = credentials.NewTLS(&tls.Config{})
+	}
+	conn, err := grpc.Dial(*endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to %s: %v\n", *endpoint, err)
+		return 2
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	var selected []string
+	if *capabilities != "" {
+		selected = strings.Split(*capabilities, ",")
+	}
+	report := conformance.Run(ctx, validator.NewValidatorClient(conn), suite, selected...)
+	for _, result := range report.Results {
+		fmt.Println(result)
+	}
+	fmt.Println()
+	for _, summary := range report.Summary() {
+		fmt.Println(summary)
+	}
+	if report.Failed() {
+		return 1
+	}
+	return 0
+}
+
+func loadSuite() (*conformance.Suite, error) {
+	if *suiteDir == "" {
+		return conformance.DefaultSuite()
+	}
+	return conformance.LoadSuite(os.DirFS(*suiteDir))
+}
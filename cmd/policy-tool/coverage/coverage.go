@@ -0,0 +1,90 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coverage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:     "coverage",
+	Short:   "Report how many sample assets each constraint matches, flagging constraints that match none.",
+	Example: `policy-tool coverage --policies ./GoogleCloudPlatform/policy-library/policies --libs ./GoogleCloudPlatform/policy-library/libs --assets ./samples`,
+	RunE:    coverageCmd,
+}
+
+var (
+	flags struct {
+		policies         []string
+		libs             string
+		assets           []string
+		disabledBuiltins []string
+		failUncovered    bool
+	}
+)
+
+func init() {
+	Cmd.Flags().StringSliceVar(&flags.policies, "policies", nil, "Path to one or more policy directories or files.")
+	Cmd.Flags().StringVar(&flags.libs, "libs", "", "Path to the Rego libs directory.")
+	Cmd.Flags().StringSliceVar(&flags.assets, "assets", nil, "Files or directories of sample assets, as JSON or newline delimited JSON.")
+	Cmd.Flags().StringSliceVar(&flags.disabledBuiltins, "disabledBuiltins", nil, "Built in functions that should be disabled.")
+	Cmd.Flags().BoolVar(&flags.failUncovered, "failUncovered", false, "Exit with an error if a constraint matches no sample asset.")
+	for _, name := range []string{"policies", "assets"} {
+		if err := Cmd.MarkFlagRequired(name); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func coverageCmd(cmd *cobra.Command, args []string) error {
+	validator, err := gcv.NewValidator(flags.policies, flags.libs, gcv.DisableBuiltins(flags.disabledBuiltins...))
+	if err != nil {
+		fmt.Printf("Errors Loading Policies:\n%s\n", err)
+		os.Exit(1)
+	}
+	report, err := validator.Coverage(context.Background(), flags.assets)
+	if err != nil {
+		return err
+	}
+	for _, err := range report.Errors {
+		fmt.Fprintf(os.Stderr, "Error reviewing asset: %v\n", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CONSTRAINT\tMATCHED\tEVALUATED\tVIOLATIONS\t")
+	for _, c := range report.Constraints {
+		flag := ""
+		if c.Matched == 0 {
+			flag = "NO COVERAGE"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", c.Constraint, c.Matched, c.Evaluated, c.Violations, flag)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	uncovered := report.Uncovered()
+	fmt.Printf("\n%d assets reviewed, %d of %d constraints matched no asset.\n", report.Assets, len(uncovered), len(report.Constraints))
+	if flags.failUncovered && len(uncovered) != 0 {
+		os.Exit(1)
+	}
+	return nil
+}
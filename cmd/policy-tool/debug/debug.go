@@ -4,8 +4,12 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strings"
 
+	"github.com/GoogleCloudPlatform/config-validator/pkg/asset"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export/csv"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export/jsonl"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export/junit"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
 	"github.com/spf13/cobra"
 )
@@ -23,6 +27,9 @@ var (
 		libs             string
 		files            []string
 		disabledBuiltins []string
+		maxErrorRatio    float64
+		junit            string
+		output           string
 	}
 )
 
@@ -31,12 +38,33 @@ func init() {
 	Cmd.Flags().StringVar(&flags.libs, "libs", "", "Path to the Rego libs directory.")
 	Cmd.Flags().StringSliceVar(&flags.files, "file", nil, "Files to process.")
 	Cmd.Flags().StringSliceVar(&flags.disabledBuiltins, "disabledBuiltins", nil, "Built in functions that should be disabled.")
+	Cmd.Flags().Float64Var(&flags.maxErrorRatio, "maxErrorRatio", 1, "Fraction of malformed input lines to tolerate before aborting, malformed lines are reported and skipped.  1 tolerates any number, 0 aborts on the first.")
+	Cmd.Flags().StringVar(&flags.junit, "junit", "", "Write the results as a JUnit XML report to this file, with a test case for each constraint evaluated against each resource.")
+	Cmd.Flags().StringVar(&flags.output, "output", "text", "Format of the violations written to stdout, one of text, csv or jsonl.")
 	if err := Cmd.MarkFlagRequired("policies"); err != nil {
 		panic(err)
 	}
 }
 
+// newOutput returns the sink for the violations written to stdout in the given format, nil for text.
+func newOutput(format string) (export.ResultSink, error) {
+	switch format {
+	case "text":
+		return nil, nil
+	case "csv":
+		return csv.NewWriter(os.Stdout), nil
+	case "jsonl":
+		return jsonl.NewWriter(os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, must be one of text, csv or jsonl", format)
+	}
+}
+
 func debugCmd(cmd *cobra.Command, args []string) error {
+	output, err := newOutput(flags.output)
+	if err != nil {
+		return err
+	}
 	validator, err := gcv.NewValidator(flags.policies, flags.libs, gcv.DisableBuiltins(flags.disabledBuiltins...))
 	if err != nil {
 		fmt.Printf("Errors Loading Policies:\n%s\n", err)
@@ -44,33 +72,66 @@ func debugCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	ctx := context.Background()
+	var junitFile *os.File
+	var junitReport *junit.Writer
+	if flags.junit != "" {
+		if junitFile, err = os.Create(flags.junit); err != nil {
+			return err
+		}
+		defer junitFile.Close()
+		junitReport = junit.NewWriter(junitFile)
+		ctx = gcv.RecordEvaluated(ctx)
+	}
 
-	// TODO: streaming read
+	reader := &asset.JSONLReader{MaxErrorRatio: flags.maxErrorRatio}
 	for _, fileName := range flags.files {
-		fileBytes, err := os.ReadFile(fileName)
+		file, err := os.Open(fileName)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", fileName, err)
 			continue
 		}
-		lines := strings.Split(string(fileBytes), "\n")
-		for idx, line := range lines {
-			if len(line) == 0 {
-				continue
-			}
-			result, err := validator.ReviewJSON(ctx, line)
+		report, err := reader.ReadLines(fileName, file, func(line int, input map[string]interface{}) error {
+			result, err := validator.ReviewUnmarshalledJSON(ctx, input)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error processing input at %s[%d]: %v\n", fileName, idx, err)
-				continue
+				fmt.Fprintf(os.Stderr, "Error processing input at %s:%d: %v\n", fileName, line, err)
+				return nil
+			}
+			if junitReport != nil {
+				if err := junitReport.WriteResults(ctx, []*gcv.Result{result}); err != nil {
+					return err
+				}
 			}
 			vs, err := result.ToViolations()
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error processing violations for input at %s[%d]: %v\n", fileName, idx, err)
-				continue
+				fmt.Fprintf(os.Stderr, "Error processing violations for input at %s:%d: %v\n", fileName, line, err)
+				return nil
+			}
+			if output != nil {
+				return output.WriteViolations(ctx, vs)
 			}
 			for _, v := range vs {
 				fmt.Printf("%s: %s [%s]\n", v.Resource, v.Message, v.Constraint)
 			}
+			return nil
+		})
+		file.Close()
+		for _, lineErr := range report.Errors {
+			fmt.Fprintf(os.Stderr, "Malformed input at %s\n", lineErr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if output != nil {
+		if err := output.Close(); err != nil {
+			return err
+		}
+	}
+	if junitReport != nil {
+		if err := junitReport.Close(); err != nil {
+			return err
 		}
+		return junitFile.Close()
 	}
 	return nil
 }
@@ -0,0 +1,75 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forseti
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/forseti"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:     "convert-forseti",
+	Short:   "Convert Forseti scanner rules to ConstraintTemplates and Constraints.",
+	Example: `policy-tool convert-forseti --rules ./rules/iam_rules.yaml,./rules/firewall_rules.yaml --out ./policies`,
+	RunE:    convertCmd,
+}
+
+var (
+	flags struct {
+		rules          []string
+		out            string
+		projectNumbers map[string]string
+	}
+)
+
+func init() {
+	Cmd.Flags().StringSliceVar(&flags.rules, "rules", nil, "Forseti rule files, named bucket_rules.yaml, firewall_rules.yaml or iam_rules.yaml.")
+	Cmd.Flags().StringVar(&flags.out, "out", "", "Directory to write the templates and constraints to.")
+	Cmd.Flags().StringToStringVar(&flags.projectNumbers, "projectNumbers", nil, "Project numbers of the project IDs in the rules, as id=number pairs.")
+	for _, flag := range []string{"rules", "out"} {
+		if err := Cmd.MarkFlagRequired(flag); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func convertCmd(cmd *cobra.Command, args []string) error {
+	conversion, err := forseti.ConvertFiles(flags.rules, forseti.ProjectNumbers(flags.projectNumbers))
+	if err != nil {
+		return err
+	}
+	for _, warning := range conversion.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	files, err := conversion.PolicyFiles()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		path := filepath.Join(flags.out, file.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, file.Content, 0644); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Converted %d constraints to %s\n", len(conversion.Constraints), flags.out)
+	return nil
+}
@@ -47,11 +47,17 @@ func init() {
 }
 
 func lintCmd(cmd *cobra.Command, args []string) error {
-	_, err := gcv.NewValidator(flags.policies, flags.libs, gcv.DisableBuiltins(flags.disabledBuiltins...))
+	report, err := gcv.ValidatePolicies(flags.policies, flags.libs, gcv.DisableBuiltins(flags.disabledBuiltins...))
 	if err != nil {
 		fmt.Printf("linter errors:\n%v\n", err)
 		os.Exit(1)
 	}
+	for _, issue := range report.Issues {
+		fmt.Println(issue)
+	}
+	if report.HasErrors() {
+		os.Exit(1)
+	}
 	fmt.Printf("No lint errors found.\n")
 	return nil
 }
@@ -0,0 +1,81 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:     "migrate",
+	Short:   "Rewrite v1alpha1 ConstraintTemplates and Constraints as v1beta1 in place.",
+	Example: `policy-tool migrate --policies ./GoogleCloudPlatform/policy-library/policies --libs ./GoogleCloudPlatform/policy-library/libs`,
+	RunE:    migrateCmd,
+}
+
+var (
+	flags struct {
+		policies []string
+		libs     string
+		dryRun   bool
+	}
+)
+
+func init() {
+	Cmd.Flags().StringSliceVar(&flags.policies, "policies", nil, "Path to one or more local policy directories or files.")
+	Cmd.Flags().StringVar(&flags.libs, "libs", "", "Path to the Rego libs directory, the parts that the templates use are copied into them.")
+	Cmd.Flags().BoolVar(&flags.dryRun, "dryRun", false, "Only list the files that would be rewritten.")
+	for _, flag := range []string{"policies", "libs"} {
+		if err := Cmd.MarkFlagRequired(flag); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func migrateCmd(cmd *cobra.Command, args []string) error {
+	regoLib, err := configs.LoadRegoFiles(flags.libs)
+	if err != nil {
+		return err
+	}
+	files, err := configs.ReadPolicyFiles(flags.policies)
+	if err != nil {
+		return err
+	}
+	migrated := 0
+	for _, file := range files {
+		content, changed, err := configs.MigrateFile(file.Content, regoLib)
+		if err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", file.Path, err)
+		}
+		if !changed {
+			continue
+		}
+		migrated++
+		if flags.dryRun {
+			fmt.Printf("Would migrate %s\n", file.Path)
+			continue
+		}
+		if err := os.WriteFile(file.Path, content, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Migrated %s\n", file.Path)
+	}
+	fmt.Printf("Migrated %d of %d files\n", migrated, len(files))
+	return nil
+}
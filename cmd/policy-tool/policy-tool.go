@@ -19,8 +19,12 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/GoogleCloudPlatform/config-validator/cmd/policy-tool/coverage"
 	"github.com/GoogleCloudPlatform/config-validator/cmd/policy-tool/debug"
+	"github.com/GoogleCloudPlatform/config-validator/cmd/policy-tool/forseti"
 	"github.com/GoogleCloudPlatform/config-validator/cmd/policy-tool/lint"
+	"github.com/GoogleCloudPlatform/config-validator/cmd/policy-tool/migrate"
+	"github.com/GoogleCloudPlatform/config-validator/cmd/policy-tool/scaffold"
 	_ "github.com/golang/glog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -44,8 +48,12 @@ var glogFlags = map[string]struct{}{
 }
 
 func init() {
+	rootCmd.AddCommand(coverage.Cmd)
 	rootCmd.AddCommand(debug.Cmd)
+	rootCmd.AddCommand(forseti.Cmd)
 	rootCmd.AddCommand(lint.Cmd)
+	rootCmd.AddCommand(migrate.Cmd)
+	rootCmd.AddCommand(scaffold.Cmd)
 	flag.CommandLine.VisitAll(func(f *flag.Flag) {
 		if _, ok := glogFlags[f.Name]; ok {
 			pflag.CommandLine.AddGoFlag(f)
@@ -0,0 +1,82 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaffold
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/scaffold"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:     "ct-scaffold",
+	Aliases: []string{"scaffold"},
+	Short:   "Generate a skeleton ConstraintTemplate and a sample Constraint of it.",
+	Example: `policy-tool ct-scaffold --kind GCPStorageLocationConstraintV1 --target gcp --resourceType storage.googleapis.com/Bucket --parameter locations:array --out ./policies`,
+	RunE:    scaffoldCmd,
+}
+
+var (
+	flags struct {
+		kind         string
+		target       string
+		resourceType string
+		parameters   []string
+		out          string
+	}
+)
+
+func init() {
+	Cmd.Flags().StringVar(&flags.kind, "kind", "", "Kind of the constraints of the template, eg GCPStorageLocationConstraintV1.")
+	Cmd.Flags().StringVar(&flags.target, "target", "gcp", "Target of the template, one of gcp, tf or k8s.")
+	Cmd.Flags().StringVar(&flags.resourceType, "resourceType", "", "CAI asset type, Terraform resource type or Kubernetes kind that the template checks.")
+	Cmd.Flags().StringSliceVar(&flags.parameters, "parameter", nil, "Parameters of the template, as name or name:type with type one of string, integer, number, boolean, array or object.")
+	Cmd.Flags().StringVar(&flags.out, "out", "", "Directory to write the template and constraint to, under templates/ and constraints/.  They are written to stdout when unset.")
+	if err := Cmd.MarkFlagRequired("kind"); err != nil {
+		panic(err)
+	}
+}
+
+func scaffoldCmd(cmd *cobra.Command, args []string) error {
+	opts := scaffold.Options{Kind: flags.kind, Target: flags.target, ResourceType: flags.resourceType}
+	for _, param := range flags.parameters {
+		opts.Parameters = append(opts.Parameters, scaffold.ParseParameter(param))
+	}
+	s, err := scaffold.Generate(opts)
+	if err != nil {
+		return err
+	}
+	if flags.out == "" {
+		fmt.Printf("%s---\n%s", s.Template, s.Constraint)
+		return nil
+	}
+	for _, file := range s.PolicyFiles() {
+		path := filepath.Join(flags.out, file.Path)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists", path)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, file.Content, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+)
+
+// runBundle implements the bundle subcommand, which packages policies into a bundle archive that can
+// be passed to -policyPath.
+func runBundle(args []string) int {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	policyPath := fs.String("policy-path", "", "directories, separated by comma, containing policy templates and configs, or policy bundles with policies/ and lib/ directories")
+	libPath := fs.String("lib-path", "", "directory containing the rego policy library, optional when policy-path is a policy bundle")
+	out := fs.String("out", "bundle"+configs.BundleArchiveSuffix, "file to write the bundle archive to")
+	signKey := fs.String("sign-key", "", "unencrypted PEM encoded private key to sign the bundle archive with, the detached signature is written to the archive's path with "+configs.BundleSignatureSuffix+" appended")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s bundle --policy-path <dirs> [--lib-path <dir>] [--out <file>] [--sign-key <file>]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *policyPath == "" {
+		fs.Usage()
+		return 2
+	}
+
+	if err := writeBundle(strings.Split(*policyPath, ","), *libPath, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to bundle policies: %v\n", err)
+		return 1
+	}
+	if *signKey != "" {
+		if err := signBundle(*out, *signKey); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to sign bundle: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// signBundle writes the detached signature of the bundle archive at path next to it.
+func signBundle(path, keyPath string) error {
+	key, err := configs.LoadBundleSigningKey(keyPath)
+	if err != nil {
+		return err
+	}
+	archive, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	signature, err := configs.SignBundleArchive(key, archive)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+configs.BundleSignatureSuffix, signature, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote signature %s\n", path+configs.BundleSignatureSuffix)
+	return nil
+}
+
+// writeBundle writes the archive to a temporary file next to out and renames it so that out is never
+// left half written.
+func writeBundle(policyPaths []string, libPath, out string) error {
+	f, err := os.CreateTemp(filepath.Dir(out), filepath.Base(out)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	manifest, err := configs.WriteBundleArchive(f, policyPaths, libPath)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), out); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s with %d templates, %d constraints and %d library files\n",
+		out, manifest.Templates, manifest.Constraints, manifest.Libraries)
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// configFlag is the name of the flag that sets the config file, which can't be set in the file itself.
+const configFlag = "config"
+
+// loadConfigFile sets the flags of fs from the config file at path, a YAML or JSON object keyed by
+// flag name, eg
+//
+//	policyPath: [gs://my-bucket/policies, /etc/policies]
+//	workerCount: 8
+//	disabledBuiltins: [http.send]
+//	methodMaxRecvSize: {AddData: 268435456}
+//	feedBigQuery: ${PROJECT}.audit.violations
+//
+// Lists are joined with commas and objects become comma separated key=value pairs, as the flags that
+// take several values expect them.  References to environment variables, as $VAR or ${VAR}, are
+// expanded before the file is parsed.  Flags given on the command line take precedence over the file,
+// and unknown settings are rejected so that typos don't go unnoticed.
+func loadConfigFile(fs *flag.FlagSet, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	content, err = yaml.YAMLToJSON([]byte(os.ExpandEnv(string(content))))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	var settings map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&settings); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	onCommandLine := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		onCommandLine[f.Name] = true
+	})
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == configFlag || fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q in %s", name, path)
+		}
+		if onCommandLine[name] {
+			continue
+		}
+		value, err := configValue(settings[name])
+		if err != nil {
+			return fmt.Errorf("invalid %s in %s: %w", name, path, err)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s in %s: %w", name, path, err)
+		}
+	}
+	return nil
+}
+
+// configValue returns the flag value of a setting of the config file.
+func configValue(setting interface{}) (string, error) {
+	switch setting := setting.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return setting, nil
+	case bool:
+		return strconv.FormatBool(setting), nil
+	case json.Number:
+		return setting.String(), nil
+	case []interface{}:
+		values := make([]string, 0, len(setting))
+		for _, item := range setting {
+			value, err := scalarConfigValue(item)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, value)
+		}
+		return strings.Join(values, ","), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(setting))
+		for key := range setting {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		values := make([]string, 0, len(setting))
+		for _, key := range keys {
+			value, err := scalarConfigValue(setting[key])
+			if err != nil {
+				return "", err
+			}
+			values = append(values, key+"="+value)
+		}
+		return strings.Join(values, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", setting)
+	}
+}
+
+// scalarConfigValue returns the value of an item of a list or object setting, which must not be a
+// list or object itself.
+func scalarConfigValue(item interface{}) (string, error) {
+	switch item.(type) {
+	case []interface{}, map[string]interface{}:
+		return "", fmt.Errorf("nested value %v", item)
+	}
+	return configValue(item)
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "server.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Setenv("POLICY_BUCKET", "my-bucket")
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	policyPath := fs.String("policyPath", "", "")
+	workerCount := fs.Int("workerCount", 1, "")
+	skip := fs.Bool("skipDisabledBuiltins", false, "")
+	methodSizes := fs.String("methodMaxRecvSize", "", "")
+	port := fs.Int("port", 10000, "")
+	fs.String(configFlag, "", "")
+	if err := fs.Parse([]string{"-port=8080"}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := writeConfig(t, `
+policyPath: [gs://${POLICY_BUCKET}/policies, /etc/policies]
+workerCount: 8
+skipDisabledBuiltins: true
+methodMaxRecvSize: {Review: 1024, AddData: 2048}
+port: 9090
+`)
+	if err := loadConfigFile(fs, path); err != nil {
+		t.Fatal(err)
+	}
+	if want := "gs://my-bucket/policies,/etc/policies"; *policyPath != want {
+		t.Errorf("got policyPath %q, want %q", *policyPath, want)
+	}
+	if *workerCount != 8 {
+		t.Errorf("got workerCount %d, want 8", *workerCount)
+	}
+	if !*skip {
+		t.Error("skipDisabledBuiltins was not set")
+	}
+	if want := "AddData=2048,Review=1024"; *methodSizes != want {
+		t.Errorf("got methodMaxRecvSize %q, want %q", *methodSizes, want)
+	}
+	if *port != 8080 {
+		t.Errorf("got port %d, want the command line's 8080", *port)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "unknown setting", content: "workerCont: 8", wantErr: `unknown setting "workerCont"`},
+		{name: "config", content: "config: other.yaml", wantErr: `unknown setting "config"`},
+		{name: "invalid value", content: "workerCount: many", wantErr: "invalid workerCount"},
+		{name: "nested list", content: "policyPath: [[a]]", wantErr: "nested value"},
+		{name: "not an object", content: "- a", wantErr: "failed to parse"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("server", flag.ContinueOnError)
+			fs.String("policyPath", "", "")
+			fs.Int("workerCount", 1, "")
+			fs.String(configFlag, "", "")
+			err := loadConfigFile(fs, writeConfig(t, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v, want %s", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -16,38 +16,125 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
 	"strings"
+	"syscall"
+	"time"
 
+	"cloud.google.com/go/pubsub"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/auth"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export/archive"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export/bigquery"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/feed"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/identity"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/inframanager"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/msgsize"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/peerlimit"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/shadow"
 	"github.com/golang/glog"
+	"github.com/open-policy-agent/opa/ast"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	bq "google.golang.org/api/bigquery/v2"
+	crm "google.golang.org/api/cloudresourcemanager/v3"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 var (
-	policyPath = flag.String("policyPath", os.Getenv("POLICY_PATH"), "directories, separated by comma, containing policy templates and configs")
+	policyPath = flag.String("policyPath", os.Getenv("POLICY_PATH"), "local directories or files, or gs:// prefixes, separated by comma, containing policy templates and configs, or policy bundles with policies/ and lib/ directories, or a single policy bundle archive written by the bundle subcommand")
 	// TODO(corb): Template development will eventually inline library code, but the currently template examples have dependency rego code.
 	//  This flag will be deprecated when the template tooling is complete.
-	policyLibraryPath  = flag.String("policyLibraryPath", os.Getenv("POLICY_LIBRARY_PATH"), "directory containing policy templates and configs")
+	policyLibraryPath  = flag.String("policyLibraryPath", os.Getenv("POLICY_LIBRARY_PATH"), "directory containing the rego policy library, optional when policyPath is a policy bundle")
 	port               = flag.Int("port", 10000, "The server port")
 	maxMessageRecvSize = flag.Int(
 		"maxMessageRecvSize", 128*1024*1024, "The max message receive size for the RPC service")
-	disabledBuiltins = flag.String("disabledBuiltins", "", "Built-in functions, separated by comma, that should be disabled.")
+	methodMaxRecvSize   = flag.String("methodMaxRecvSize", "", "Max message receive sizes of individual methods, as a comma separated list of method=bytes, eg AddData=268435456, overriding maxMessageRecvSize.  The limits of AddDataStream and ReviewStream apply to each request on the stream.")
+	disabledBuiltins    = flag.String("disabledBuiltins", "", "Built-in functions, separated by comma, that should be disabled.  The server refuses to start if any template calls them, unless -skipDisabledBuiltins is set.")
+	skipBuiltins        = flag.Bool("skipDisabledBuiltins", false, "Skip the templates calling a builtin disabled with -disabledBuiltins, along with their constraints, with a warning, instead of refusing to start.")
+	regoCapabilities    = flag.String("regoCapabilities", "", "OPA version, eg v0.54.0, or path of an OPA capabilities JSON file, to pin the rego capabilities templates are compiled with.  Templates relying on builtins or future keywords outside the capabilities are rejected.")
+	callerIdentity      = flag.Bool("callerIdentity", false, "Extract the caller identity from the ID token in the request and record it in violation metadata and logs.  The token is not verified, so only use it behind a proxy that authenticates callers.  Ignored with -authConfig, which records the authenticated caller instead.")
+	requireOwner        = flag.Bool("requireOwner", false, "Reject constraints without the "+configs.OwnerAnnotation+" annotation.")
+	strictParameters    = flag.Bool("strictParameters", false, "Reject constraints with parameters that are not declared in, or don't have the type declared by, their template's schema, instead of logging a warning.")
+	lenientLoad         = flag.Bool("lenientLoad", false, "Skip the templates and constraints that fail to load, and the constraints of skipped templates, with a warning instead of refusing to start, so that a bad policy degrades enforcement rather than stopping it.")
+	conflictPolicy      = flag.String("conflictPolicy", string(configs.ConflictError), "How templates with the same name or kind, and constraints with the same kind and name, declared more than once across the policy paths are resolved: error refuses to start, first-wins keeps the first one loaded and last-wins the last one loaded, with a warning for each one skipped.")
+	validateOnly        = flag.Bool("validateOnly", false, "Load and compile the policies, print any errors and warnings, then exit without starting the server.")
+	testPolicies        = flag.Bool("testPolicies", false, "Run the rego unit tests, in files ending with _test.rego, in the policy library and policy paths, print the result of each test, then exit without starting the server.  Disabled builtins are also disabled in the tests.")
+	feedSubscription    = flag.String("feedSubscription", "", "Pub/Sub subscription of a Cloud Asset Inventory feed, as projects/<project>/subscriptions/<subscription>.  When set, the assets published to the feed are reviewed continuously instead of starting the server.")
+	feedArchive         = flag.String("feedArchive", "", "File to write the violations found on the feed to as a violation archive.")
+	feedBigQuery        = flag.String("feedBigQuery", "", "BigQuery table to stream the violations found on the feed to, as <project>.<dataset>.<table>.")
+	infraManagerPreview = flag.String("infraManagerPreview", "", "Infrastructure Manager preview, as projects/<project>/locations/<location>/previews/<preview>.  When set, the terraform plan of the preview is reviewed with the TF constraints and the violations are printed as JSON, one per line, instead of starting the server.  The exit status is 1 if there are violations.")
+	tfSource            = flag.String("tfSource", "", "Directory of the terraform root module of the -infraManagerPreview.  Violations of resources with a gcv:ignore=<ConstraintKind> reason=<reason> comment in its .tf files, or those of the local modules it calls, are printed with the suppression and don't fail the review.")
+	tfWorkspace         = flag.String("tfWorkspace", "", "Terraform workspace of the -infraManagerPreview, exposed to TF constraints as plan_metadata.workspace.")
+	drainTimeout        = flag.Duration("drainTimeout", 30*time.Second, "How long to wait for in-flight requests to finish after SIGTERM or SIGINT before cancelling them.  Keep it below the pod's termination grace period.")
+	workerCount         = flag.Int("workerCount", runtime.NumCPU(), "Number of workers that Validator will spawn to handle validate calls, this defaults to core count on the host")
+	maxAssetsPerRequest = flag.Int("maxAssetsPerRequest", 0, "Maximum number of assets in a Review request, larger requests fail with RESOURCE_EXHAUSTED.  Zero means no limit.")
+	maxAssetBytes       = flag.Int("maxAssetBytes", 0, "Maximum size in bytes of each asset in a Review request, requests with a larger asset fail with RESOURCE_EXHAUSTED.  Zero means no limit.")
+	enforceOnly         = flag.String("enforceOnly", "", "Comma separated enforcement actions, eg deny,warn, of the constraints whose violations Review returns.  Constraints without spec.enforcementAction are deny.  All actions are returned when unset.")
+	otlpEndpoint        = flag.String("otlpEndpoint", "", "OTLP gRPC endpoint, as host:port, to export review traces to.  Tracing is disabled when unset.")
+	otlpInsecure        = flag.Bool("otlpInsecure", false, "Connect to the OTLP endpoint without TLS.")
+	ancestorIAM         = flag.Bool("ancestorIAM", false, "Accept organization, folder and project IAM policies with AddData and make them available to GCP constraints as data.inventory.ancestors_iam.")
+	k8sExpansion        = flag.Bool("k8sExpansion", false, "Expand K8S resources with the Gatekeeper ExpansionTemplates in the policy paths before review, eg Deployments into Pods, so that workload policies apply to the resources that generate them as they do in the cluster.")
+	constraintShards    = flag.Int("constraintShards", 1, "Number of Constraint Framework clients the GCP constraints are split across by kind, so that the constraints for a single asset are evaluated concurrently.  Each client holds its own copy of the policy library.")
+	deterministic       = flag.Bool("deterministic", false, "Evaluate every asset of a review request at the same time, exposed to templates as evaluation_time, so that results can be reproduced.")
+	resolveTags         = flag.Bool("resolveTags", false, "Look up the effective Resource Manager tags of GCP assets that don't provide them, for constraints that match on spec.match.requiredTags or spec.match.excludedTags.  Each such asset costs a Resource Manager request.")
+	bundlePublicKey     = flag.String("bundleVerificationKey", "", "PEM encoded public key file, eg from cosign generate-key-pair.  When set, -policyPath must be a single policy bundle archive with a detached signature in <archive>.sig, as written by the bundle subcommand with --sign-key or by cosign sign-blob, that verifies with the key, and the server refuses to start otherwise.")
+	statusPort          = flag.Int("statusPort", 0, "Port to serve the policy status, as returned by GetPolicyStatus, on over HTTP at /policyStatus as JSON, the requests of each client limited by -peerMaxConcurrent or -peerRate at /peerLimits, and the results of -shadowPolicyPath at /shadow.  Disabled when zero.")
+	authConfig          = flag.String("authConfig", "", "YAML or JSON file of API keys, accepted Google ID token audiences and the callers allowed to call each method, see auth.Config.  When set, calls without valid credentials are rejected and the authenticated caller is used as caller identity.")
+	peerMaxConcurrent   = flag.Int("peerMaxConcurrent", 0, "Maximum number of requests in flight of each client, identified by its authenticated caller identity with -authConfig or else its address.  Further requests fail with RESOURCE_EXHAUSTED.  Zero means no limit.")
+	peerRate            = flag.Float64("peerRate", 0, "Requests per second each client may make on average, requests over the rate fail with RESOURCE_EXHAUSTED and the delay to retry after.  Zero means no limit.")
+	peerBurst           = flag.Int("peerBurst", 1, "Number of requests each client may make at once above -peerRate.")
+	shadowPolicyPath    = flag.String("shadowPolicyPath", "", "Policy paths, as -policyPath, of a new version of the policies to canary.  A sample of the reviewed assets, set by -shadowSamplePercent, is also reviewed with them in the background, and the violations that differ are logged, but only those of -policyPath are returned.")
+	shadowLibraryPath   = flag.String("shadowPolicyLibraryPath", "", "Policy library of -shadowPolicyPath, defaults to -policyLibraryPath.")
+	shadowSamplePercent = flag.Float64("shadowSamplePercent", 1, "Percentage of the reviewed assets, selected by name, that are reviewed with -shadowPolicyPath.")
+	tlsCert             = flag.String("tlsCert", "", "PEM encoded certificate file to serve TLS with, together with -tlsKey.  The server is plain text when unset.")
+	tlsKey              = flag.String("tlsKey", "", "PEM encoded private key file of -tlsCert.")
+	configFile          = flag.String(configFlag, "", "YAML or JSON file setting any of the other flags, keyed by flag name, with lists for comma separated values and $VAR references to environment variables.  Flags given on the command line take precedence.")
 )
 
 type gcvServer struct {
+	cv        *gcv.Validator
 	validator *gcv.ParallelValidator
+	// shadow, if set, reviews a sample of the reviewed assets with the shadow policies.
+	shadow *shadow.Evaluator
 }
 
 func (s *gcvServer) AddData(ctx context.Context, request *validator.AddDataRequest) (*validator.AddDataResponse, error) {
-	return &validator.AddDataResponse{}, status.Error(codes.Internal, "Not supported")
+	if !*ancestorIAM {
+		return &validator.AddDataResponse{}, status.Error(codes.Internal, "Not supported")
+	}
+	// Only ancestor IAM policies are stored, other assets are ignored.
+	added := 0
+	for _, asset := range request.Assets {
+		ok, err := s.cv.AddAncestorIAM(ctx, asset)
+		if err != nil {
+			return &validator.AddDataResponse{}, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if ok {
+			added++
+		}
+	}
+	glog.V(1).Infof("added %d of %d assets as ancestor IAM policies", added, len(request.Assets))
+	return &validator.AddDataResponse{}, nil
 }
 
 func (s *gcvServer) Audit(ctx context.Context, request *validator.AuditRequest) (*validator.AuditResponse, error) {
@@ -55,44 +142,552 @@ func (s *gcvServer) Audit(ctx context.Context, request *validator.AuditRequest)
 }
 
 func (s *gcvServer) Reset(ctx context.Context, request *validator.ResetRequest) (*validator.ResetResponse, error) {
-	return &validator.ResetResponse{}, status.Error(codes.Internal, "Not supported")
+	if !*ancestorIAM {
+		return &validator.ResetResponse{}, status.Error(codes.Internal, "Not supported")
+	}
+	if err := s.cv.ResetAncestorIAM(ctx); err != nil {
+		return &validator.ResetResponse{}, status.Error(codes.Internal, err.Error())
+	}
+	return &validator.ResetResponse{}, nil
 }
 
 func (s *gcvServer) Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
-	return s.validator.Review(ctx, request)
+	response, err := s.validator.Review(ctx, request)
+	if caller, ok := identity.FromContext(ctx); ok && response != nil {
+		identity.StampViolations(caller, response.Violations)
+		glog.Infof("review by %s: %d assets, %d violations", caller, len(request.Assets), len(response.Violations))
+	}
+	if err != nil {
+		st := gcv.ReviewStatus(err)
+		if response != nil && (st.Code() == codes.DeadlineExceeded || st.Code() == codes.Canceled) {
+			// Return the violations found before the deadline as a detail of the status.
+			if partial, detailErr := st.WithDetails(response); detailErr == nil {
+				st = partial
+			} else {
+				glog.Errorf("failed to add partial review response: %s", detailErr)
+			}
+		}
+		return nil, st.Err()
+	}
+	if s.shadow != nil {
+		s.shadow.Observe(request, response)
+	}
+	return response, nil
+}
+
+// AddDataStream adds the assets of each request on the stream, as AddData.
+func (s *gcvServer) AddDataStream(stream validator.Validator_AddDataStreamServer) error {
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&validator.AddDataResponse{})
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.AddData(stream.Context(), request); err != nil {
+			return err
+		}
+	}
+}
+
+// ReviewStream reviews the assets of each request on the stream, as Review, and returns the violations
+// of all of them.  Once a review stops at ReviewOptions.stop_at_severity the remaining requests are not
+// reviewed.
+func (s *gcvServer) ReviewStream(stream validator.Validator_ReviewStreamServer) error {
+	response := &validator.ReviewResponse{}
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(response)
+		}
+		if err != nil {
+			return err
+		}
+		if response.Stopped {
+			continue
+		}
+		reviewed, err := s.Review(stream.Context(), request)
+		if err != nil {
+			return err
+		}
+		response.Violations = append(response.Violations, reviewed.Violations...)
+		response.Stopped = reviewed.Stopped
+	}
+}
+
+func (s *gcvServer) ListConstraints(ctx context.Context, request *validator.ListConstraintsRequest) (*validator.ListConstraintsResponse, error) {
+	constraints, err := s.cv.ListConstraints()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	templates, err := s.cv.ListTemplates()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &validator.ListConstraintsResponse{}
+	for _, c := range constraints {
+		pb, err := c.ToProto()
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		response.Constraints = append(response.Constraints, pb)
+	}
+	for _, t := range templates {
+		pb, err := t.ToProto()
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		response.Templates = append(response.Templates, pb)
+	}
+	return response, nil
+}
+
+// GetLastLoadReport returns the report of loading the policies the server was started with.
+func (s *gcvServer) GetLastLoadReport(ctx context.Context, request *validator.GetLastLoadReportRequest) (*validator.LoadReport, error) {
+	return s.cv.LoadReport().ToProto(), nil
+}
+
+// GetPolicyStatus returns the fingerprint and counts of the policies the server is serving.
+func (s *gcvServer) GetPolicyStatus(ctx context.Context, request *validator.GetPolicyStatusRequest) (*validator.PolicyStatus, error) {
+	policyStatus, err := s.cv.PolicyStatus()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return policyStatus.ToProto(), nil
+}
+
+// servePolicyStatus serves the policy status as JSON at /policyStatus on the port, for load balancer
+// and fleet checks that don't speak gRPC, the statistics of the peer limiter, if any, at /peerLimits,
+// and those of the shadow reviews, if any, at /shadow.
+func servePolicyStatus(port int, s *gcvServer, peerLimiter *peerlimit.Limiter) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/policyStatus", func(w http.ResponseWriter, r *http.Request) {
+		policyStatus, err := s.GetPolicyStatus(r.Context(), &validator.GetPolicyStatusRequest{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		content, err := protojson.Marshal(policyStatus)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(content)
+	})
+	if peerLimiter != nil {
+		mux.HandleFunc("/peerLimits", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(peerLimiter.Stats())
+		})
+	}
+	if s.shadow != nil {
+		mux.HandleFunc("/shadow", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(s.shadow.Stats())
+		})
+	}
+	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
 }
 
-func newServer(stopChannel chan struct{}, policyPaths []string, policyLibraryPath string, opts ...gcv.Option) (*gcvServer, error) {
+func newServer(policyPaths []string, policyLibraryPath string, opts ...gcv.Option) (*gcvServer, error) {
 	cv, err := gcv.NewValidator(policyPaths, policyLibraryPath, opts...)
 	if err != nil {
 		return nil, err
 	}
-	v := gcv.NewParallelValidator(stopChannel, cv)
+	v := gcv.NewParallelValidator(nil, cv)
 	return &gcvServer{
+		cv:        cv,
 		validator: v,
 	}, nil
 }
 
+// validatePolicies prints the problems found in the policies and returns the exit code.
+func validatePolicies(policyPaths []string, policyLibraryPath string, opts ...gcv.Option) int {
+	report, err := gcv.ValidatePolicies(policyPaths, policyLibraryPath, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read policies: %v\n", err)
+		return 1
+	}
+	for _, issue := range report.Issues {
+		fmt.Println(issue)
+	}
+	if report.HasErrors() {
+		return 1
+	}
+	return 0
+}
+
+// runPolicyTests runs the rego unit tests of the policies and prints the results, returning the exit
+// status.
+func runPolicyTests(policyPaths []string, policyLibraryPath string, opts ...gcv.Option) int {
+	report, err := gcv.RunPolicyTests(policyPaths, policyLibraryPath, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run policy tests: %v\n", err)
+		return 1
+	}
+	for _, result := range report.Results {
+		fmt.Println(result)
+	}
+	if report.Failed() {
+		return 1
+	}
+	return 0
+}
+
+// reviewInfraManagerPreview reviews the plan of the Infrastructure Manager preview and prints the
+// violations, returning the exit status.
+func reviewInfraManagerPreview(policyPaths []string, policyLibraryPath string, opts ...gcv.Option) int {
+	ctx := context.Background()
+	cv, err := gcv.NewValidator(policyPaths, policyLibraryPath, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load policies: %v\n", err)
+		return 1
+	}
+	client, err := inframanager.NewClient(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	plan, err := client.Plan(ctx, *infraManagerPreview)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to review preview: %v\n", err)
+		return 1
+	}
+	if *tfSource != "" {
+		suppressions, err := gcv.ReadTFSuppressions(*tfSource, plan)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read suppressions: %v\n", err)
+			return 1
+		}
+		ctx = gcv.WithTFSuppressions(ctx, suppressions)
+	}
+	if *tfWorkspace != "" {
+		ctx = gcv.WithTFWorkspace(ctx, *tfWorkspace)
+	}
+	violations, err := cv.ReviewTFPlan(ctx, plan)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to review preview: %v\n", err)
+		return 1
+	}
+	unsuppressed := 0
+	for _, violation := range violations {
+		if violation.Suppression == nil {
+			unsuppressed++
+		}
+		line, err := protojson.Marshal(violation)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to marshal violation: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(line))
+	}
+	if unsuppressed != 0 {
+		return 1
+	}
+	return 0
+}
+
+// runFeed reviews the assets published to the feed subscription until the process is signalled to
+// stop, then flushes the sinks.
+func runFeed(policyPaths []string, policyLibraryPath string, opts ...gcv.Option) error {
+	parts := strings.Split(*feedSubscription, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "subscriptions" {
+		return fmt.Errorf("invalid feed subscription %q, expected projects/<project>/subscriptions/<subscription>", *feedSubscription)
+	}
+	cv, err := gcv.NewValidator(policyPaths, policyLibraryPath, opts...)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var listenerOpts []feed.Option
+	if *feedArchive != "" {
+		f, err := os.Create(*feedArchive)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w, err := archive.NewWriter(f)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := w.Close(); err != nil {
+				glog.Errorf("failed to close archive %s: %v", *feedArchive, err)
+			}
+		}()
+		listenerOpts = append(listenerOpts, feed.Sink(w))
+	}
+	if *feedBigQuery != "" {
+		table := strings.Split(*feedBigQuery, ".")
+		if len(table) != 3 {
+			return fmt.Errorf("invalid BigQuery table %q, expected <project>.<dataset>.<table>", *feedBigQuery)
+		}
+		service, err := bq.NewService(ctx)
+		if err != nil {
+			return err
+		}
+		w := bigquery.NewWriter(service, table[0], table[1], table[2])
+		if err := w.EnsureTable(ctx); err != nil {
+			return err
+		}
+		listenerOpts = append(listenerOpts, feed.Sink(w))
+	}
+
+	client, err := pubsub.NewClient(ctx, parts[1])
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	glog.Infof("reviewing assets from feed subscription %s", *feedSubscription)
+	return feed.NewListener(client.Subscription(parts[3]), cv, listenerOpts...).Run(ctx)
+}
+
+// setupTracing installs a tracer provider that exports spans to the OTLP endpoint, and returns a
+// function that flushes and stops it.
+func setupTracing(ctx context.Context) (func(), error) {
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(*otlpEndpoint)}
+	if *otlpInsecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName("config-validator"))),
+	)
+	otel.SetTracerProvider(provider)
+	return func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			glog.Errorf("failed to shut down tracer provider: %v", err)
+		}
+	}, nil
+}
+
+// loadRegoCapabilities loads the capabilities of an OPA version, or from a capabilities file if
+// versionOrPath names one.
+func loadRegoCapabilities(versionOrPath string) (*ast.Capabilities, error) {
+	if _, err := os.Stat(versionOrPath); err == nil {
+		return ast.LoadCapabilitiesFile(versionOrPath)
+	}
+	return ast.LoadCapabilitiesVersion(versionOrPath)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bundle" {
+		os.Exit(runBundle(os.Args[2:]))
+	}
 	flag.Parse()
+	if *configFile != "" {
+		if err := loadConfigFile(flag.CommandLine, *configFile); err != nil {
+			log.Fatalf("Failed to load config file: %v", err)
+		}
+	}
+	if *otlpEndpoint != "" {
+		shutdown, err := setupTracing(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to set up tracing: %v", err)
+		}
+		defer shutdown()
+	}
+	policyPaths := strings.Split(*policyPath, ",")
+	disabledBuiltins := strings.Split(*disabledBuiltins, ",")
+	opts := []gcv.Option{gcv.DisableBuiltins(disabledBuiltins...), gcv.WithWorkerCount(*workerCount)}
+	if *requireOwner {
+		opts = append(opts, gcv.RequireOwner())
+	}
+	if *strictParameters {
+		opts = append(opts, gcv.StrictParameters())
+	}
+	if *skipBuiltins {
+		opts = append(opts, gcv.SkipDisabledBuiltins())
+	}
+	if *lenientLoad {
+		opts = append(opts, gcv.WithLenientLoad())
+	}
+	policy, err := configs.ParseConflictPolicy(*conflictPolicy)
+	if err != nil {
+		log.Fatalf("Invalid -conflictPolicy: %v", err)
+	}
+	opts = append(opts, gcv.WithConflictPolicy(policy))
+	if *regoCapabilities != "" {
+		capabilities, err := loadRegoCapabilities(*regoCapabilities)
+		if err != nil {
+			log.Fatalf("Failed to load rego capabilities: %v", err)
+		}
+		opts = append(opts, gcv.WithRegoCapabilities(capabilities))
+	}
+	if *ancestorIAM {
+		opts = append(opts, gcv.AncestorIAM())
+	}
+	if *k8sExpansion {
+		opts = append(opts, gcv.K8SExpansion())
+	}
+	if *deterministic {
+		opts = append(opts, gcv.Deterministic())
+	}
+	if *resolveTags {
+		service, err := crm.NewService(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to create resource manager client: %v", err)
+		}
+		opts = append(opts, gcv.WithTagResolver(gcv.ResourceManagerTagResolver(service)))
+	}
+	if *maxAssetsPerRequest > 0 {
+		opts = append(opts, gcv.MaxAssetsPerRequest(*maxAssetsPerRequest))
+	}
+	if *maxAssetBytes > 0 {
+		opts = append(opts, gcv.MaxAssetBytes(*maxAssetBytes))
+	}
+	if *enforceOnly != "" {
+		opts = append(opts, gcv.EnforceOnly(strings.Split(*enforceOnly, ",")...))
+	}
+	if *constraintShards > 1 {
+		opts = append(opts, gcv.ConstraintShards(*constraintShards))
+	}
+	if *bundlePublicKey != "" {
+		opts = append(opts, gcv.WithBundleVerification(*bundlePublicKey))
+	}
+	if *validateOnly {
+		os.Exit(validatePolicies(policyPaths, *policyLibraryPath, opts...))
+	}
+	if *testPolicies {
+		os.Exit(runPolicyTests(policyPaths, *policyLibraryPath, opts...))
+	}
+	if *infraManagerPreview != "" {
+		os.Exit(reviewInfraManagerPreview(policyPaths, *policyLibraryPath, opts...))
+	}
+	if *feedSubscription != "" {
+		if err := runFeed(policyPaths, *policyLibraryPath, opts...); err != nil {
+			log.Fatalf("Failed to review feed: %v", err)
+		}
+		return
+	}
+
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
 	if err != nil {
 		log.Fatalf("failed to listen on port %d: %v", *port, err)
 	}
 
-	stopChannel := make(chan struct{})
-	defer close(stopChannel)
-	grpcServer := grpc.NewServer(
-		grpc.MaxRecvMsgSize(*maxMessageRecvSize),
-	)
-	policyPaths := strings.Split(*policyPath, ",")
-	disabledBuiltins := strings.Split(*disabledBuiltins, ",")
-	serverImpl, err := newServer(stopChannel, policyPaths, *policyLibraryPath, gcv.DisableBuiltins(disabledBuiltins...))
+	methodLimits, err := msgsize.ParseMethodLimits(*methodMaxRecvSize)
+	if err != nil {
+		log.Fatalf("invalid -methodMaxRecvSize: %v", err)
+	}
+	limits := msgsize.Limits{Default: *maxMessageRecvSize, Methods: methodLimits}
+	interceptors := []grpc.UnaryServerInterceptor{limits.UnaryServerInterceptor()}
+	streamInterceptors := []grpc.StreamServerInterceptor{limits.StreamServerInterceptor()}
+	if *callerIdentity && *authConfig == "" {
+		interceptors = append(interceptors, identity.UnaryServerInterceptor(identity.NewIDTokenExtractor()))
+		streamInterceptors = append(streamInterceptors, identity.StreamServerInterceptor(identity.NewIDTokenExtractor()))
+	}
+	if *authConfig != "" {
+		config, err := auth.LoadConfig(*authConfig)
+		if err != nil {
+			log.Fatalf("Failed to load -authConfig: %v", err)
+		}
+		authenticator, err := auth.New(config)
+		if err != nil {
+			log.Fatalf("Invalid -authConfig: %v", err)
+		}
+		interceptors = append(interceptors, authenticator.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, authenticator.StreamServerInterceptor())
+	}
+	// The peer limits run after authentication, so that clients are told apart by their authenticated
+	// caller identity.  The unverified identity of -callerIdentity is not used.
+	var peerLimiter *peerlimit.Limiter
+	if *peerMaxConcurrent > 0 || *peerRate > 0 {
+		peerLimiter = peerlimit.New(peerlimit.Limits{MaxConcurrent: *peerMaxConcurrent, Rate: *peerRate, Burst: *peerBurst})
+		interceptors = append(interceptors, peerLimiter.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, peerLimiter.StreamServerInterceptor())
+	}
+	serverOpts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(limits.ServerMax()),
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+	if *tlsCert != "" || *tlsKey != "" {
+		creds, err := credentials.NewServerTLSFromFile(*tlsCert, *tlsKey)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificate: %v", err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+	grpcServer := grpc.NewServer(serverOpts...)
+	serverImpl, err := newServer(policyPaths, *policyLibraryPath, opts...)
 	if err != nil {
 		log.Fatalf("Failed to load server %v", err)
 	}
+	var shadowServer *gcvServer
+	if *shadowPolicyPath != "" {
+		libraryPath := *shadowLibraryPath
+		if libraryPath == "" {
+			libraryPath = *policyLibraryPath
+		}
+		shadowServer, err = newServer(strings.Split(*shadowPolicyPath, ","), libraryPath, opts...)
+		if err != nil {
+			log.Fatalf("Failed to load shadow policies: %v", err)
+		}
+		serverImpl.shadow = shadow.New(shadowServer.validator, *shadowSamplePercent/100, shadow.WithNameNormalizer(serverImpl.cv.NormalizeName))
+		glog.Infof("reviewing %v%% of assets with the shadow policies in %s", *shadowSamplePercent, *shadowPolicyPath)
+	}
 	validator.RegisterValidatorServer(grpcServer, serverImpl)
+	if *statusPort != 0 {
+		go func() {
+			glog.Fatalf("policy status server stopped: %v", servePolicyStatus(*statusPort, serverImpl, peerLimiter))
+		}()
+	}
+	// Reflection lets tools such as grpcurl discover the service and its request options.
+	reflection.Register(grpcServer)
+
+	// On SIGTERM, eg from a rolling update, new RPCs are refused while the in-flight ones finish.  The
+	// worker pool is only stopped once they have.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		glog.Infof("Shutting down, draining in-flight requests for up to %v", *drainTimeout)
+		gracefulStop(grpcServer, *drainTimeout)
+	}()
 	if err := grpcServer.Serve(lis); err != nil {
 		glog.Fatalf("RPC server ungracefully stopped: %v", err)
 	}
+	<-drained
+	if err := serverImpl.validator.Stop(context.Background()); err != nil {
+		glog.Warningf("Failed to stop validator: %v", err)
+	}
+	if shadowServer != nil {
+		serverImpl.shadow.Wait()
+		if err := shadowServer.validator.Stop(context.Background()); err != nil {
+			glog.Warningf("Failed to stop shadow validator: %v", err)
+		}
+	}
+	glog.Infof("RPC server stopped")
+}
+
+// gracefulStop stops the server from accepting RPCs and waits for the in-flight RPCs to finish for at
+// most timeout, after which they are cancelled.
+func gracefulStop(server *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		glog.Warningf("In-flight requests did not finish within %v, cancelling them", timeout)
+		server.Stop()
+		<-done
+	}
 }
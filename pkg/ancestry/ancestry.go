@@ -0,0 +1,87 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ancestry handles the ancestry paths of GCP resources, such as
+// organizations/123/folders/456/projects/789, and the globs that constraints match them with.
+package ancestry
+
+import (
+	"strings"
+)
+
+// The collections of an ancestry path, from the top of the resource hierarchy.
+const (
+	Organizations = "organizations"
+	Folders       = "folders"
+	Projects      = "projects"
+)
+
+// Unknown is the ID CAI uses for a container it can't resolve, typically the organization of a
+// project the caller has no access to, as in organizations/unknown.  It is accepted wherever an ID
+// is, in ancestry paths and in globs.
+const Unknown = "unknown"
+
+// levels are the depths of the collections in the resource hierarchy.
+var levels = map[string]int{
+	Organizations: 0,
+	Folders:       1,
+	Projects:      2,
+}
+
+// IsCollection returns true if segment is one of the collections of an ancestry path.
+func IsCollection(segment string) bool {
+	_, ok := levels[segment]
+	return ok
+}
+
+// Path returns the ancestry path of a resource from its CAI ancestors, which are listed from the
+// resource up, eg [projects/789 folders/456 organizations/123].
+func Path(ancestors []string) string {
+	cnt := len(ancestors)
+	revAncestors := make([]string, len(ancestors))
+	for idx := 0; idx < cnt; idx++ {
+		revAncestors[cnt-idx-1] = ancestors[idx]
+	}
+	return strings.Join(revAncestors, "/")
+}
+
+// Normalize converts the singular collections of legacy ancestry paths and globs, eg
+// organization/123/project/789, to their plural form.
+func Normalize(path string) string {
+	for _, r := range []struct {
+		old string
+		new string
+	}{
+		{"organization/", "organizations/"},
+		{"folder/", "folders/"},
+		{"project/", "projects/"},
+	} {
+		path = strings.ReplaceAll(path, r.old, r.new)
+	}
+	return path
+}
+
+// Containers returns the organizations, folders and projects of the ancestry path, from the top, eg
+// [organizations/123 folders/456], stopping at the first segment that isn't one of them.
+func Containers(path string) []string {
+	var ret []string
+	segments := strings.Split(path, "/")
+	for idx := 0; idx+1 < len(segments); idx += 2 {
+		if !IsCollection(segments[idx]) || segments[idx+1] == "" {
+			break
+		}
+		ret = append(ret, segments[idx]+"/"+segments[idx+1])
+	}
+	return ret
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ancestry
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPath(t *testing.T) {
+	got := Path([]string{"projects/3", "folders/2", "organizations/unknown"})
+	if want := "organizations/unknown/folders/2/projects/3"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got := Path(nil); got != "" {
+		t.Errorf("got %q for no ancestors, want empty", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for path, want := range map[string]string{
+		"organization/1/folder/2/project/3":    "organizations/1/folders/2/projects/3",
+		"organizations/1/folders/2/projects/3": "organizations/1/folders/2/projects/3",
+		"organization/*":                       "organizations/*",
+	} {
+		if got := Normalize(path); got != want {
+			t.Errorf("Normalize(%q) got %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestContainers(t *testing.T) {
+	for path, want := range map[string][]string{
+		"":                                     nil,
+		"organizations/1":                      {"organizations/1"},
+		"organizations/1/folders/2/projects/3": {"organizations/1", "folders/2", "projects/3"},
+		"organizations/unknown/projects/3":     {"organizations/unknown", "projects/3"},
+		"organizations/1/unknown/2/projects/3": {"organizations/1"},
+		"projects/3/":                          {"projects/3"},
+		"organizations/":                       nil,
+	} {
+		if diff := cmp.Diff(want, Containers(path)); diff != "" {
+			t.Errorf("Containers(%q) diff (-want +got):\n%s", path, diff)
+		}
+	}
+}
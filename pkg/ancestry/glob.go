@@ -0,0 +1,156 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ancestry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// Separator separates the segments of ancestry paths, * in a glob matches within a segment and **
+// across segments.
+const Separator = '/'
+
+var numberRegex = regexp.MustCompile(`^[0-9]+\*{0,2}$`)
+
+// From https://cloud.google.com/resource-manager/docs/creating-managing-projects:
+// The project ID must be a unique string of 6 to 30 lowercase letters, digits, or hyphens. It must start with a letter, and cannot have a trailing hyphen.
+var projectIDRegex = regexp.MustCompile(`^[a-z][a-z0-9-]{5,27}[a-z0-9]$`)
+
+// position is what a glob segment stands for in the ancestry paths it matches.
+type position int
+
+const (
+	// collectionPosition segments match a collection, eg folders.
+	collectionPosition position = iota
+	// idPosition segments match the ID of a container, eg 456.
+	idPosition
+	// anyPosition segments follow a ** so they may match either.
+	anyPosition
+)
+
+// globState is the state of ValidateGlob after a segment.
+type globState struct {
+	position position
+	// collection is the collection of the ID in idPosition, empty if it was matched by a wildcard.
+	collection string
+	// level is the level of the deepest collection so far, -1 before the first.
+	level int
+}
+
+// ValidateGlob returns an error if expression is not a glob of ancestry paths, that is a sequence of
+// collections, each followed by an ID, in the order of the resource hierarchy.  IDs are numbers,
+// optionally followed by * or **, project IDs after projects, or Unknown.  A * stands for a single
+// collection or ID and a ** for any number of segments, eg organizations/123/**/projects/*.
+func ValidateGlob(expression string) error {
+	if _, err := glob.Compile(expression, Separator); err != nil {
+		return err
+	}
+	state := globState{position: collectionPosition, level: -1}
+	parts := strings.Split(expression, "/")
+	for i, item := range parts {
+		var err error
+		if state, err = state.next(item); err != nil {
+			return fmt.Errorf("unexpected %s element %d in %s: %w", item, i, expression, err)
+		}
+	}
+	if state.position == idPosition && state.collection != "" {
+		return fmt.Errorf("%s ends with %s without an ID", expression, state.collection)
+	}
+	return nil
+}
+
+// next returns the state after item.
+func (s globState) next(item string) (globState, error) {
+	switch {
+	case item == "**":
+		s.position, s.collection = anyPosition, ""
+		return s, nil
+	case item == "*":
+		switch s.position {
+		case collectionPosition:
+			s.position, s.collection = idPosition, ""
+		case idPosition:
+			s.position = collectionPosition
+		}
+		return s, nil
+	case IsCollection(item):
+		if s.position == idPosition {
+			return s, fmt.Errorf("want an ID")
+		}
+		level := levels[item]
+		if level < s.level || (level == s.level && item != Folders) {
+			return s, fmt.Errorf("%s can't be under %s", item, collectionAt(s.level))
+		}
+		s.position, s.collection, s.level = idPosition, item, level
+		return s, nil
+	}
+
+	if s.position == collectionPosition {
+		return s, fmt.Errorf("want one of %s, %s or %s", Organizations, Folders, Projects)
+	}
+	switch {
+	case item == Unknown:
+	case numberRegex.MatchString(item):
+	case s.collection == Projects && projectIDRegex.MatchString(item):
+	default:
+		return s, fmt.Errorf("want a number or %s", Unknown)
+	}
+	if s.position == idPosition {
+		s.position = collectionPosition
+	}
+	return s, nil
+}
+
+// collectionAt returns the collection at the level.
+func collectionAt(level int) string {
+	for collection, l := range levels {
+		if l == level {
+			return collection
+		}
+	}
+	return ""
+}
+
+// ValidateGlobs validates each of the expressions with ValidateGlob.
+func ValidateGlobs(expressions []string) error {
+	for idx, expression := range expressions {
+		if err := ValidateGlob(expression); err != nil {
+			return fmt.Errorf("idx [%d]: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// Match returns true if the ancestry path matches the glob pattern.  Patterns that don't compile
+// match nothing, they are rejected by ValidateGlob.
+func Match(pattern, path string) bool {
+	g, err := glob.Compile(pattern, Separator)
+	return err == nil && g.Match(path)
+}
+
+// FirstMatch returns the first of the glob patterns that matches the ancestry path, false if none
+// does.
+func FirstMatch(patterns []string, path string) (string, bool) {
+	for _, pattern := range patterns {
+		if Match(pattern, path) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ancestry
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestValidateGlob(t *testing.T) {
+	for _, expression := range []string{
+		"**",
+		"*",
+		"organizations/*",
+		"organizations/**",
+		"organizations/123/*",
+		"organizations/123/folders/*",
+		"organizations/123/folders/456/*",
+		"organizations/123/folders/456/projects/*",
+		"organizations/123/folders/456/folders/789/projects/1",
+		"organizations/unknown",
+		"organizations/unknown/**",
+		"folders/unknown/projects/*",
+		"organizations/*/projects/557385378",
+		"organizations/123**",
+		"**/folders/1221214/**",
+		"**/projects/tfv-test-project",
+		"**/projects/**",
+		"projects/my-project-id",
+		"projects/*",
+	} {
+		if err := ValidateGlob(expression); err != nil {
+			t.Errorf("ValidateGlob(%q) got error %v", expression, err)
+		}
+	}
+}
+
+func TestValidateGlobErrors(t *testing.T) {
+	for _, expression := range []string{
+		"flubber/*",
+		"organizations/random",
+		"folders/123/organizations/*",
+		"projects/123/organizations/*",
+		"projects/123/folders/123",
+		"projects/1/**/folders/2",
+		"projects/1/projects/2",
+		"organizations/1/organizations/2",
+		"organizations/folders/1",
+		"organizations/123/folders",
+		"123/**",
+		"unknown/**",
+		"**/my-project-id",
+		"organizations/[",
+	} {
+		if err := ValidateGlob(expression); err == nil {
+			t.Errorf("ValidateGlob(%q) got no error", expression)
+		}
+	}
+}
+
+func TestFirstMatch(t *testing.T) {
+	patterns := []string{"organizations/1/folders/*/**", "organizations/*/**"}
+	for path, want := range map[string]string{
+		"organizations/1/folders/2/projects/3": "organizations/1/folders/*/**",
+		"organizations/unknown/projects/3":     "organizations/*/**",
+		"folders/2/projects/3":                 "",
+	} {
+		got, ok := FirstMatch(patterns, path)
+		if got != want || ok != (want != "") {
+			t.Errorf("FirstMatch(%q) got %q, %v, want %q", path, got, ok, want)
+		}
+	}
+}
+
+// ancestryPath is a random ancestry path for property tests.
+type ancestryPath []string
+
+// Generate implements quick.Generator.
+func (ancestryPath) Generate(r *rand.Rand, size int) reflect.Value {
+	var ancestors []string
+	if r.Intn(4) != 0 {
+		id := fmt.Sprint(r.Int63())
+		if r.Intn(4) == 0 {
+			id = Unknown
+		}
+		ancestors = append(ancestors, Organizations+"/"+id)
+	}
+	for i := r.Intn(4); i > 0; i-- {
+		ancestors = append(ancestors, fmt.Sprintf("%s/%d", Folders, r.Int63()))
+	}
+	if len(ancestors) == 0 || r.Intn(2) == 0 {
+		ancestors = append(ancestors, fmt.Sprintf("%s/%d", Projects, r.Int63()))
+	}
+	return reflect.ValueOf(ancestryPath(ancestors))
+}
+
+func (p ancestryPath) String() string {
+	return strings.Join(p, "/")
+}
+
+func TestGlobProperties(t *testing.T) {
+	properties := map[string]interface{}{
+		// Every ancestry path is a valid glob that matches itself.
+		"path is a glob of itself": func(p ancestryPath) bool {
+			return ValidateGlob(p.String()) == nil && Match(p.String(), p.String())
+		},
+		// ** matches every path, and every ancestor followed by ** matches its descendants.
+		"** matches descendants": func(p ancestryPath) bool {
+			if !Match("**", p.String()) {
+				return false
+			}
+			for idx := 0; idx+1 < len(p); idx++ {
+				pattern := ancestryPath(p[:idx+1]).String() + "/**"
+				if ValidateGlob(pattern) != nil || !Match(pattern, p.String()) {
+					return false
+				}
+			}
+			return true
+		},
+		// Replacing any ID with * gives a valid glob that still matches the path.
+		"* matches any ID": func(p ancestryPath, idx uint8) bool {
+			segments := strings.Split(p.String(), "/")
+			segments[2*(int(idx)%len(p))+1] = "*"
+			pattern := strings.Join(segments, "/")
+			return ValidateGlob(pattern) == nil && Match(pattern, p.String())
+		},
+		// Paths are the reverse of their CAI ancestors, and their containers are the path.
+		"path and containers round trip": func(p ancestryPath) bool {
+			ancestors := make([]string, len(p))
+			for idx, container := range p {
+				ancestors[len(p)-idx-1] = container
+			}
+			return Path(ancestors) == p.String() && reflect.DeepEqual(Containers(p.String()), []string(p))
+		},
+	}
+	for name, property := range properties {
+		t.Run(name, func(t *testing.T) {
+			if err := quick.Check(property, &quick.Config{Rand: rand.New(rand.NewSource(1))}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
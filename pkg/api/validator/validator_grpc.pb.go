@@ -0,0 +1,514 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The definition of validator RPC service.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.24.0
+// source: validator.proto
+
+package validator
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Validator_AddData_FullMethodName           = "/validator.Validator/AddData"
+	Validator_Audit_FullMethodName             = "/validator.Validator/Audit"
+	Validator_Reset_FullMethodName             = "/validator.Validator/Reset"
+	Validator_Review_FullMethodName            = "/validator.Validator/Review"
+	Validator_ListConstraints_FullMethodName   = "/validator.Validator/ListConstraints"
+	Validator_GetLastLoadReport_FullMethodName = "/validator.Validator/GetLastLoadReport"
+	Validator_GetPolicyStatus_FullMethodName   = "/validator.Validator/GetPolicyStatus"
+	Validator_AddDataStream_FullMethodName     = "/validator.Validator/AddDataStream"
+	Validator_ReviewStream_FullMethodName      = "/validator.Validator/ReviewStream"
+)
+
+// ValidatorClient is the client API for Validator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ValidatorClient interface {
+	// AddData adds GCP resource metadata to be audited later.
+	AddData(ctx context.Context, in *AddDataRequest, opts ...grpc.CallOption) (*AddDataResponse, error)
+	// Audit checks the GCP resource metadata that has been added via AddData to determine if any of the constraint is violated.
+	Audit(ctx context.Context, in *AuditRequest, opts ...grpc.CallOption) (*AuditResponse, error)
+	// Reset clears previously added data from the underlying query evaluation engine.
+	Reset(ctx context.Context, in *ResetRequest, opts ...grpc.CallOption) (*ResetResponse, error)
+	// Review checks the GCP resources and returns any constraint violations.  Note that referential checks are not supported
+	// with this mode.
+	Review(ctx context.Context, in *ReviewRequest, opts ...grpc.CallOption) (*ReviewResponse, error)
+	// ListConstraints returns the constraints and constraint templates that are loaded in the validator.
+	ListConstraints(ctx context.Context, in *ListConstraintsRequest, opts ...grpc.CallOption) (*ListConstraintsResponse, error)
+	// GetLastLoadReport returns the report of the last time the policies were loaded, so that deployments
+	// can verify that every template and constraint was applied.
+	GetLastLoadReport(ctx context.Context, in *GetLastLoadReportRequest, opts ...grpc.CallOption) (*LoadReport, error)
+	// GetPolicyStatus returns the fingerprint and counts of the policies being served.
+	GetPolicyStatus(ctx context.Context, in *GetPolicyStatusRequest, opts ...grpc.CallOption) (*PolicyStatus, error)
+	// AddDataStream adds the assets of each request on the stream, as AddData, for clients with more
+	// assets than fit in a single request.
+	AddDataStream(ctx context.Context, opts ...grpc.CallOption) (Validator_AddDataStreamClient, error)
+	// ReviewStream reviews the assets of each request on the stream, as Review, and returns the
+	// violations of all of them, for clients with more assets than fit in a single request.
+	ReviewStream(ctx context.Context, opts ...grpc.CallOption) (Validator_ReviewStreamClient, error)
+}
+
+type validatorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewValidatorClient(cc grpc.ClientConnInterface) ValidatorClient {
+	return &validatorClient{cc}
+}
+
+func (c *validatorClient) AddData(ctx context.Context, in *AddDataRequest, opts ...grpc.CallOption) (*AddDataResponse, error) {
+	out := new(AddDataResponse)
+	err := c.cc.Invoke(ctx, Validator_AddData_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *validatorClient) Audit(ctx context.Context, in *AuditRequest, opts ...grpc.CallOption) (*AuditResponse, error) {
+	out := new(AuditResponse)
+	err := c.cc.Invoke(ctx, Validator_Audit_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *validatorClient) Reset(ctx context.Context, in *ResetRequest, opts ...grpc.CallOption) (*ResetResponse, error) {
+	out := new(ResetResponse)
+	err := c.cc.Invoke(ctx, Validator_Reset_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *validatorClient) Review(ctx context.Context, in *ReviewRequest, opts ...grpc.CallOption) (*ReviewResponse, error) {
+	out := new(ReviewResponse)
+	err := c.cc.Invoke(ctx, Validator_Review_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *validatorClient) ListConstraints(ctx context.Context, in *ListConstraintsRequest, opts ...grpc.CallOption) (*ListConstraintsResponse, error) {
+	out := new(ListConstraintsResponse)
+	err := c.cc.Invoke(ctx, Validator_ListConstraints_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *validatorClient) GetLastLoadReport(ctx context.Context, in *GetLastLoadReportRequest, opts ...grpc.CallOption) (*LoadReport, error) {
+	out := new(LoadReport)
+	err := c.cc.Invoke(ctx, Validator_GetLastLoadReport_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *validatorClient) GetPolicyStatus(ctx context.Context, in *GetPolicyStatusRequest, opts ...grpc.CallOption) (*PolicyStatus, error) {
+	out := new(PolicyStatus)
+	err := c.cc.Invoke(ctx, Validator_GetPolicyStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *validatorClient) AddDataStream(ctx context.Context, opts ...grpc.CallOption) (Validator_AddDataStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Validator_ServiceDesc.Streams[0], Validator_AddDataStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &validatorAddDataStreamClient{stream}
+	return x, nil
+}
+
+type Validator_AddDataStreamClient interface {
+	Send(*AddDataRequest) error
+	CloseAndRecv() (*AddDataResponse, error)
+	grpc.ClientStream
+}
+
+type validatorAddDataStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *validatorAddDataStreamClient) Send(m *AddDataRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *validatorAddDataStreamClient) CloseAndRecv() (*AddDataResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(AddDataResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *validatorClient) ReviewStream(ctx context.Context, opts ...grpc.CallOption) (Validator_ReviewStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Validator_ServiceDesc.Streams[1], Validator_ReviewStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &validatorReviewStreamClient{stream}
+	return x, nil
+}
+
+type Validator_ReviewStreamClient interface {
+	Send(*ReviewRequest) error
+	CloseAndRecv() (*ReviewResponse, error)
+	grpc.ClientStream
+}
+
+type validatorReviewStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *validatorReviewStreamClient) Send(m *ReviewRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *validatorReviewStreamClient) CloseAndRecv() (*ReviewResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ReviewResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ValidatorServer is the server API for Validator service.
+// All implementations should embed UnimplementedValidatorServer
+// for forward compatibility
+type ValidatorServer interface {
+	// AddData adds GCP resource metadata to be audited later.
+	AddData(context.Context, *AddDataRequest) (*AddDataResponse, error)
+	// Audit checks the GCP resource metadata that has been added via AddData to determine if any of the constraint is violated.
+	Audit(context.Context, *AuditRequest) (*AuditResponse, error)
+	// Reset clears previously added data from the underlying query evaluation engine.
+	Reset(context.Context, *ResetRequest) (*ResetResponse, error)
+	// Review checks the GCP resources and returns any constraint violations.  Note that referential checks are not supported
+	// with this mode.
+	Review(context.Context, *ReviewRequest) (*ReviewResponse, error)
+	// ListConstraints returns the constraints and constraint templates that are loaded in the validator.
+	ListConstraints(context.Context, *ListConstraintsRequest) (*ListConstraintsResponse, error)
+	// GetLastLoadReport returns the report of the last time the policies were loaded, so that deployments
+	// can verify that every template and constraint was applied.
+	GetLastLoadReport(context.Context, *GetLastLoadReportRequest) (*LoadReport, error)
+	// GetPolicyStatus returns the fingerprint and counts of the policies being served.
+	GetPolicyStatus(context.Context, *GetPolicyStatusRequest) (*PolicyStatus, error)
+	// AddDataStream adds the assets of each request on the stream, as AddData, for clients with more
+	// assets than fit in a single request.
+	AddDataStream(Validator_AddDataStreamServer) error
+	// ReviewStream reviews the assets of each request on the stream, as Review, and returns the
+	// violations of all of them, for clients with more assets than fit in a single request.
+	ReviewStream(Validator_ReviewStreamServer) error
+}
+
+// UnimplementedValidatorServer should be embedded to have forward compatible implementations.
+type UnimplementedValidatorServer struct {
+}
+
+func (UnimplementedValidatorServer) AddData(context.Context, *AddDataRequest) (*AddDataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddData not implemented")
+}
+func (UnimplementedValidatorServer) Audit(context.Context, *AuditRequest) (*AuditResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Audit not implemented")
+}
+func (UnimplementedValidatorServer) Reset(context.Context, *ResetRequest) (*ResetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reset not implemented")
+}
+func (UnimplementedValidatorServer) Review(context.Context, *ReviewRequest) (*ReviewResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Review not implemented")
+}
+func (UnimplementedValidatorServer) ListConstraints(context.Context, *ListConstraintsRequest) (*ListConstraintsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListConstraints not implemented")
+}
+func (UnimplementedValidatorServer) GetLastLoadReport(context.Context, *GetLastLoadReportRequest) (*LoadReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLastLoadReport not implemented")
+}
+func (UnimplementedValidatorServer) GetPolicyStatus(context.Context, *GetPolicyStatusRequest) (*PolicyStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPolicyStatus not implemented")
+}
+func (UnimplementedValidatorServer) AddDataStream(Validator_AddDataStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method AddDataStream not implemented")
+}
+func (UnimplementedValidatorServer) ReviewStream(Validator_ReviewStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ReviewStream not implemented")
+}
+
+// UnsafeValidatorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ValidatorServer will
+// result in compilation errors.
+type UnsafeValidatorServer interface {
+	mustEmbedUnimplementedValidatorServer()
+}
+
+func RegisterValidatorServer(s grpc.ServiceRegistrar, srv ValidatorServer) {
+	s.RegisterService(&Validator_ServiceDesc, srv)
+}
+
+func _Validator_AddData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServer).AddData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Validator_AddData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServer).AddData(ctx, req.(*AddDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Validator_Audit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServer).Audit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Validator_Audit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServer).Audit(ctx, req.(*AuditRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Validator_Reset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServer).Reset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Validator_Reset_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServer).Reset(ctx, req.(*ResetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Validator_Review_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReviewRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServer).Review(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Validator_Review_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServer).Review(ctx, req.(*ReviewRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Validator_ListConstraints_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListConstraintsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServer).ListConstraints(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Validator_ListConstraints_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServer).ListConstraints(ctx, req.(*ListConstraintsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Validator_GetLastLoadReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLastLoadReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServer).GetLastLoadReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Validator_GetLastLoadReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServer).GetLastLoadReport(ctx, req.(*GetLastLoadReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Validator_GetPolicyStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPolicyStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServer).GetPolicyStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Validator_GetPolicyStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServer).GetPolicyStatus(ctx, req.(*GetPolicyStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Validator_AddDataStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ValidatorServer).AddDataStream(&validatorAddDataStreamServer{stream})
+}
+
+type Validator_AddDataStreamServer interface {
+	SendAndClose(*AddDataResponse) error
+	Recv() (*AddDataRequest, error)
+	grpc.ServerStream
+}
+
+type validatorAddDataStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *validatorAddDataStreamServer) SendAndClose(m *AddDataResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *validatorAddDataStreamServer) Recv() (*AddDataRequest, error) {
+	m := new(AddDataRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Validator_ReviewStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ValidatorServer).ReviewStream(&validatorReviewStreamServer{stream})
+}
+
+type Validator_ReviewStreamServer interface {
+	SendAndClose(*ReviewResponse) error
+	Recv() (*ReviewRequest, error)
+	grpc.ServerStream
+}
+
+type validatorReviewStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *validatorReviewStreamServer) SendAndClose(m *ReviewResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *validatorReviewStreamServer) Recv() (*ReviewRequest, error) {
+	m := new(ReviewRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Validator_ServiceDesc is the grpc.ServiceDesc for Validator service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Validator_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "validator.Validator",
+	HandlerType: (*ValidatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddData",
+			Handler:    _Validator_AddData_Handler,
+		},
+		{
+			MethodName: "Audit",
+			Handler:    _Validator_Audit_Handler,
+		},
+		{
+			MethodName: "Reset",
+			Handler:    _Validator_Reset_Handler,
+		},
+		{
+			MethodName: "Review",
+			Handler:    _Validator_Review_Handler,
+		},
+		{
+			MethodName: "ListConstraints",
+			Handler:    _Validator_ListConstraints_Handler,
+		},
+		{
+			MethodName: "GetLastLoadReport",
+			Handler:    _Validator_GetLastLoadReport_Handler,
+		},
+		{
+			MethodName: "GetPolicyStatus",
+			Handler:    _Validator_GetPolicyStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AddDataStream",
+			Handler:       _Validator_AddDataStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ReviewStream",
+			Handler:       _Validator_ReviewStream_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "validator.proto",
+}
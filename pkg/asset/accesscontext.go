@@ -0,0 +1,148 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import (
+	"encoding/json"
+
+	"cloud.google.com/go/accesscontextmanager/apiv1/accesscontextmanagerpb"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Asset types of Access Context Manager assets.
+const (
+	AccessPolicyAssetType     = "accesscontextmanager.googleapis.com/AccessPolicy"
+	AccessLevelAssetType      = "accesscontextmanager.googleapis.com/AccessLevel"
+	ServicePerimeterAssetType = "accesscontextmanager.googleapis.com/ServicePerimeter"
+)
+
+// accessContextField describes a field of the access_context_policy oneof.
+type accessContextField struct {
+	// key is the field's key in the JSON form of the asset.
+	key       string
+	assetType string
+	message   func() proto.Message
+}
+
+var accessContextFields = []accessContextField{
+	{
+		key:       "access_policy",
+		assetType: AccessPolicyAssetType,
+		message:   func() proto.Message { return &accesscontextmanagerpb.AccessPolicy{} },
+	},
+	{
+		key:       "access_level",
+		assetType: AccessLevelAssetType,
+		message:   func() proto.Message { return &accesscontextmanagerpb.AccessLevel{} },
+	},
+	{
+		key:       "service_perimeter",
+		assetType: ServicePerimeterAssetType,
+		message:   func() proto.Message { return &accesscontextmanagerpb.ServicePerimeter{} },
+	},
+}
+
+// accessContextPolicy returns the key and asset type of the access context policy set on the asset,
+// or false if none is set.  A oneof wrapper without a policy is not set.
+func accessContextPolicy(asset *validator.Asset) (accessContextField, bool) {
+	switch {
+	case asset.GetAccessPolicy() != nil:
+		return accessContextFields[0], true
+	case asset.GetAccessLevel() != nil:
+		return accessContextFields[1], true
+	case asset.GetServicePerimeter() != nil:
+		return accessContextFields[2], true
+	}
+	return accessContextField{}, false
+}
+
+// validateAccessContextPolicy returns an error if the access context policy of the asset does not
+// match its asset type.
+func validateAccessContextPolicy(asset *validator.Asset) error {
+	field, ok := accessContextPolicy(asset)
+	if !ok {
+		return nil
+	}
+	if asset.GetAssetType() != "" && asset.GetAssetType() != field.assetType {
+		return errors.Errorf("asset %q has %s but type %q, want %q", asset.GetName(), field.key, asset.GetAssetType(), field.assetType)
+	}
+	return nil
+}
+
+// NormalizeAccessContextPolicy adds the fields that are unset in the access policy, access level or
+// service perimeter of the asset with their default values, eg a service perimeter's perimeter_type of
+// PERIMETER_TYPE_REGULAR, which the JSON form of the proto omits.  Templates then see the same fields
+// whether the asset was reviewed as a proto or as JSON.  Fields already present, including fields
+// unknown to the proto, are left unchanged.
+func NormalizeAccessContextPolicy(asset map[string]interface{}) error {
+	for _, field := range accessContextFields {
+		policy, ok := asset[field.key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		defaults, err := accessContextDefaults(policy, field.message())
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s in asset %v", field.key, asset["name"])
+		}
+		mergeDefaults(policy, defaults)
+	}
+	return nil
+}
+
+// accessContextDefaults returns the policy, as its proto message, with every unset field set to its
+// default value.
+func accessContextDefaults(policy map[string]interface{}, msg proto.Message) (map[string]interface{}, error) {
+	buf, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(buf, msg); err != nil {
+		return nil, err
+	}
+	buf, err = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	var defaults map[string]interface{}
+	if err := json.Unmarshal(buf, &defaults); err != nil {
+		return nil, err
+	}
+	return defaults, nil
+}
+
+// mergeDefaults adds the keys of defaults that are missing from m, recursing into objects present in
+// both.  Null defaults, the value of unset messages, are not added.
+func mergeDefaults(m, defaults map[string]interface{}) {
+	for key, value := range defaults {
+		if value == nil {
+			continue
+		}
+		existing, found := m[key]
+		if !found {
+			m[key] = value
+			continue
+		}
+		existingMap, ok := existing.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if valueMap, ok := value.(map[string]interface{}); ok {
+			mergeDefaults(existingMap, valueMap)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import (
+	"testing"
+
+	"cloud.google.com/go/accesscontextmanager/apiv1/accesscontextmanagerpb"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidateAssetAccessContextPolicy(t *testing.T) {
+	testCases := []struct {
+		description string
+		input       *validator.Asset
+		wantErr     bool
+	}{
+		{
+			description: "access policy",
+			input: &validator.Asset{
+				Name:         "//accesscontextmanager.googleapis.com/accessPolicies/1",
+				AssetType:    AccessPolicyAssetType,
+				AncestryPath: "organizations/1",
+				AccessContextPolicy: &validator.Asset_AccessPolicy{
+					AccessPolicy: &accesscontextmanagerpb.AccessPolicy{Name: "accessPolicies/1"},
+				},
+			},
+		},
+		{
+			description: "service perimeter",
+			input: &validator.Asset{
+				Name:         "//accesscontextmanager.googleapis.com/accessPolicies/1/servicePerimeters/p",
+				AssetType:    ServicePerimeterAssetType,
+				AncestryPath: "organizations/1",
+				AccessContextPolicy: &validator.Asset_ServicePerimeter{
+					ServicePerimeter: &accesscontextmanagerpb.ServicePerimeter{Name: "accessPolicies/1/servicePerimeters/p"},
+				},
+			},
+		},
+		{
+			description: "empty oneof wrapper",
+			input: &validator.Asset{
+				Name:                "//accesscontextmanager.googleapis.com/accessPolicies/1/accessLevels/l",
+				AssetType:           AccessLevelAssetType,
+				AncestryPath:        "organizations/1",
+				AccessContextPolicy: &validator.Asset_AccessLevel{},
+			},
+			wantErr: true,
+		},
+		{
+			description: "mismatched asset type",
+			input: &validator.Asset{
+				Name:         "//accesscontextmanager.googleapis.com/accessPolicies/1/accessLevels/l",
+				AssetType:    ServicePerimeterAssetType,
+				AncestryPath: "organizations/1",
+				AccessContextPolicy: &validator.Asset_AccessLevel{
+					AccessLevel: &accesscontextmanagerpb.AccessLevel{Name: "accessPolicies/1/accessLevels/l"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := ValidateAsset(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ValidateAsset() = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeAccessContextPolicy(t *testing.T) {
+	asset := map[string]interface{}{
+		"name": "//accesscontextmanager.googleapis.com/accessPolicies/1/servicePerimeters/p",
+		"service_perimeter": map[string]interface{}{
+			"name": "accessPolicies/1/servicePerimeters/p",
+			"status": map[string]interface{}{
+				"restricted_services": []interface{}{"storage.googleapis.com"},
+			},
+			"unknown_field": "kept",
+		},
+	}
+	if err := NormalizeAccessContextPolicy(asset); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"name":                      "accessPolicies/1/servicePerimeters/p",
+		"title":                     "",
+		"description":               "",
+		"perimeter_type":            "PERIMETER_TYPE_REGULAR",
+		"use_explicit_dry_run_spec": false,
+		"status": map[string]interface{}{
+			"resources":           []interface{}{},
+			"access_levels":       []interface{}{},
+			"restricted_services": []interface{}{"storage.googleapis.com"},
+			"ingress_policies":    []interface{}{},
+			"egress_policies":     []interface{}{},
+		},
+		"unknown_field": "kept",
+	}
+	if diff := cmp.Diff(want, asset["service_perimeter"]); diff != "" {
+		t.Errorf("service_perimeter (-want, +got) %v", diff)
+	}
+
+	invalid := map[string]interface{}{
+		"access_level": map[string]interface{}{"title": 123},
+	}
+	if err := NormalizeAccessContextPolicy(invalid); err == nil {
+		t.Errorf("expected error for invalid access level")
+	}
+}
+
+func TestConvertResourceToInterfaceAccessContextPolicy(t *testing.T) {
+	input := &validator.Asset{
+		Name:      "//accesscontextmanager.googleapis.com/accessPolicies/1/servicePerimeters/p",
+		AssetType: ServicePerimeterAssetType,
+		AccessContextPolicy: &validator.Asset_ServicePerimeter{
+			ServicePerimeter: &accesscontextmanagerpb.ServicePerimeter{Name: "accessPolicies/1/servicePerimeters/p"},
+		},
+	}
+	got, err := ConvertResourceViaJSONToInterface(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	perimeter := got.(map[string]interface{})["service_perimeter"].(map[string]interface{})
+	if perimeter["perimeter_type"] != "PERIMETER_TYPE_REGULAR" {
+		t.Errorf("got perimeter_type %v, want PERIMETER_TYPE_REGULAR", perimeter["perimeter_type"])
+	}
+}
@@ -18,14 +18,17 @@ import (
 	"encoding/json"
 	"regexp"
 	"strings"
+	"sync"
 
+	"github.com/GoogleCloudPlatform/config-validator/pkg/ancestry"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
 	"github.com/golang/glog"
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	"google.golang.org/protobuf/encoding/protojson"
-	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -34,6 +37,21 @@ import (
 
 const logRequestsVerboseLevel = 2
 
+// assetMarshalOptions are the options assets are converted to JSON with.
+var assetMarshalOptions = protojson.MarshalOptions{UseProtoNames: true}
+
+// maxPooledJSONBuffer is the capacity above which JSON buffers are dropped rather than pooled, so that
+// a single large asset doesn't pin its buffer for the life of the process.
+const maxPooledJSONBuffer = 1 << 20
+
+// jsonBuffers pools the buffers assets are marshalled to JSON into.
+var jsonBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 4096)
+		return &buf
+	},
+}
+
 func ValidateAsset(asset *validator.Asset) error {
 	var result *multierror.Error
 	if asset.GetName() == "" {
@@ -45,34 +63,131 @@ func ValidateAsset(asset *validator.Asset) error {
 	if asset.GetAssetType() == "" {
 		result = multierror.Append(result, errors.Errorf("asset %q missing type", asset.GetName()))
 	}
-	if asset.GetResource() == nil && asset.GetIamPolicy() == nil && asset.GetOrgPolicy() == nil && asset.GetAccessContextPolicy() == nil && asset.GetV2OrgPolicies() == nil {
+	if !HasData(asset) {
 		result = multierror.Append(result, errors.Errorf("asset %q missing all of these: resource, IAM policy, Org Policy, Access Context Policy, v2 Org Policy", asset.GetName()))
 	}
+	if err := validateAccessContextPolicy(asset); err != nil {
+		result = multierror.Append(result, err)
+	}
 	return result.ErrorOrNil()
 }
 
+// HasData returns true if the asset has any of the data that is reviewed: a resource, IAM policy, org
+// policy, access context policy or v2 org policies.
+func HasData(asset *validator.Asset) bool {
+	_, hasAccessContextPolicy := accessContextPolicy(asset)
+	return asset.GetResource() != nil || asset.GetIamPolicy() != nil || asset.GetOrgPolicy() != nil || hasAccessContextPolicy || asset.GetV2OrgPolicies() != nil
+}
+
 func ConvertResourceViaJSONToInterface(asset *validator.Asset) (interface{}, error) {
 	if asset == nil {
 		return nil, nil
 	}
-	m := &protojson.MarshalOptions{
-		UseProtoNames: true,
-	}
 	if asset.Resource != nil {
 		CleanStructValue(asset.Resource.Data)
 	}
 	glog.V(logRequestsVerboseLevel).Infof("converting asset to golang interface: %v", asset)
-	buf, err := m.Marshal(asset)
+	// The resource data holds most of a large asset, eg the schema of a BigQuery table, so it is
+	// converted directly rather than through JSON text with the rest of the asset.
+	pooled := jsonBuffers.Get().(*[]byte)
+	buf, err := assetMarshalOptions.MarshalAppend((*pooled)[:0], withoutResourceData(asset))
 	if err != nil {
 		return nil, errors.Wrapf(err, "marshalling to json with asset %s: %v", asset.Name, asset)
 	}
-	var f interface{}
-	if err := json.Unmarshal(buf, &f); err != nil {
+	var f map[string]interface{}
+	err = json.Unmarshal(buf, &f)
+	// json.Unmarshal copies what it keeps, so the buffer can be reused once it returns.
+	if cap(buf) <= maxPooledJSONBuffer {
+		*pooled = buf[:0]
+		jsonBuffers.Put(pooled)
+	}
+	if err != nil {
 		return nil, errors.Wrapf(err, "marshalling from json with asset %s: %v", asset.Name, asset)
 	}
+	if data := asset.GetResource().GetData(); data != nil {
+		converted, err := structToMap(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "converting resource data with asset %s", asset.Name)
+		}
+		f["resource"].(map[string]interface{})["data"] = converted
+	}
+	if err := NormalizeAccessContextPolicy(f); err != nil {
+		return nil, err
+	}
 	return f, nil
 }
 
+// withoutResourceData returns a shallow copy of the asset with no resource data, the asset itself
+// is left unchanged as it may be reviewed concurrently.
+func withoutResourceData(asset *validator.Asset) *validator.Asset {
+	if asset.GetResource().GetData() == nil {
+		return asset
+	}
+	copied := shallowCopyMessage(asset.ProtoReflect())
+	resource := shallowCopyMessage(asset.Resource.ProtoReflect())
+	resource.Clear(resource.Descriptor().Fields().ByName("data"))
+	copied.Set(copied.Descriptor().Fields().ByName("resource"), protoreflect.ValueOfMessage(resource))
+	return copied.Interface().(*validator.Asset)
+}
+
+const (
+	// DefaultMaxAncestryDepth is the default maximum number of components in an ancestry path.  The
+	// resource hierarchy allows an organization, 10 levels of folders and a project, so this leaves
+	// headroom for new resource types while rejecting runaway input.
+	DefaultMaxAncestryDepth = 32
+	// DefaultMaxAncestryLength is the default maximum length of an ancestry path in bytes.
+	DefaultMaxAncestryLength = 2048
+)
+
+var (
+	// ErrAncestryTooDeep is returned for ancestry paths with more components than allowed.
+	ErrAncestryTooDeep = errors.New("ancestry path too deep")
+	// ErrAncestryTooLong is returned for ancestry paths longer than allowed.
+	ErrAncestryTooLong = errors.New("ancestry path too long")
+	// ErrMalformedAncestry is returned for ancestry paths that are not a sequence of type/id pairs.
+	ErrMalformedAncestry = errors.New("malformed ancestry path")
+)
+
+// AncestryLimits bounds the ancestry paths that are accepted for review.  Ancestry paths come from
+// the caller and are matched against constraint globs, so they are bounded before matching.
+type AncestryLimits struct {
+	// MaxDepth is the maximum number of type/id components, zero disables the check.
+	MaxDepth int
+	// MaxLength is the maximum length of the path in bytes, zero disables the check.
+	MaxLength int
+}
+
+// DefaultAncestryLimits are the limits applied when none are configured.
+var DefaultAncestryLimits = AncestryLimits{
+	MaxDepth:  DefaultMaxAncestryDepth,
+	MaxLength: DefaultMaxAncestryLength,
+}
+
+// Validate returns an error if the ancestry path exceeds the limits or is not a sequence of type/id
+// pairs such as organizations/123/folders/456/projects/789.  An empty ancestry path is valid, it is
+// matched by the ** ancestry glob.
+func (l AncestryLimits) Validate(ancestryPath string) error {
+	if ancestryPath == "" {
+		return nil
+	}
+	if l.MaxLength > 0 && len(ancestryPath) > l.MaxLength {
+		return errors.Wrapf(ErrAncestryTooLong, "%d bytes, max %d", len(ancestryPath), l.MaxLength)
+	}
+	segments := strings.Split(ancestryPath, "/")
+	if len(segments)%2 != 0 {
+		return errors.Wrapf(ErrMalformedAncestry, "%q has an odd number of segments", ancestryPath)
+	}
+	for _, segment := range segments {
+		if segment == "" {
+			return errors.Wrapf(ErrMalformedAncestry, "%q has an empty segment", ancestryPath)
+		}
+	}
+	if depth := len(segments) / 2; l.MaxDepth > 0 && depth > l.MaxDepth {
+		return errors.Wrapf(ErrAncestryTooDeep, "%d components, max %d", depth, l.MaxDepth)
+	}
+	return nil
+}
+
 // SanitizeAncestryPath will populate the AncestryPath field from the ancestors list, or fix the pre-populated one
 // if no ancestry list is provided.
 func SanitizeAncestryPath(asset *validator.Asset) error {
@@ -89,14 +204,9 @@ func SanitizeAncestryPath(asset *validator.Asset) error {
 	return errors.Errorf("no ancestry information for asset %s", asset.String())
 }
 
-// AncestryPath returns the ancestry path from a given ancestors list
+// AncestryPath returns the ancestry path from a given ancestors list, see ancestry.Path.
 func AncestryPath(ancestors []string) string {
-	cnt := len(ancestors)
-	revAncestors := make([]string, len(ancestors))
-	for idx := 0; idx < cnt; idx++ {
-		revAncestors[cnt-idx-1] = ancestors[idx]
-	}
-	return strings.Join(revAncestors, "/")
+	return ancestry.Path(ancestors)
 }
 
 // ConvertCAIToK8s will convert a supported CAI Asset to a K8S resource and populate any omitted fields.
@@ -165,7 +275,11 @@ func ConvertCAIToK8s(asset map[string]interface{}) (*unstructured.Unstructured,
 
 // ConvertToAdmissionRequest converts a CAI asset containing a K8S type to an AdmissionRequest which is the format that
 // the Gatekeeper Constraint Framework target expects.
-func ConvertToAdmissionRequest(asset map[string]interface{}) (*admissionv1beta1.AdmissionRequest, error) {
+//
+// Assets from CAI feeds may carry the prior state of the resource in prior_asset and a deleted flag.  The prior asset
+// becomes the old object of an UPDATE, a deleted asset becomes the old object of a DELETE, and any other asset is
+// treated as a CREATE.  CAI does not record who made the change, so the user info is left empty.
+func ConvertToAdmissionRequest(asset map[string]interface{}) (*admissionv1.AdmissionRequest, error) {
 	resource, err := ConvertCAIToK8s(asset)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to convert CAI asset to k8s resource")
@@ -177,7 +291,7 @@ func ConvertToAdmissionRequest(asset map[string]interface{}) (*admissionv1beta1.
 	}
 
 	gvk := resource.GroupVersionKind()
-	req := &admissionv1beta1.AdmissionRequest{
+	req := &admissionv1.AdmissionRequest{
 		Kind: metav1.GroupVersionKind{
 			Group:   gvk.Group,
 			Version: gvk.Version,
@@ -186,11 +300,54 @@ func ConvertToAdmissionRequest(asset map[string]interface{}) (*admissionv1beta1.
 		Object: runtime.RawExtension{
 			Raw: resourceJSON,
 		},
-		Name: resource.GetName(),
+		Name:      resource.GetName(),
+		Namespace: resource.GetNamespace(),
+		Operation: admissionv1.Create,
+	}
+
+	if deleted, _, _ := unstructured.NestedBool(asset, "deleted"); deleted {
+		req.Operation = admissionv1.Delete
+		req.OldObject = req.Object
+		req.Object = runtime.RawExtension{}
+		return req, nil
+	}
+
+	prior, found, err := unstructured.NestedFieldNoCopy(asset, "prior_asset")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to access prior_asset field")
+	}
+	priorAsset, ok := prior.(map[string]interface{})
+	if !found || !ok || len(priorAsset) == 0 {
+		return req, nil
+	}
+	if _, found := priorAsset["ancestors"]; !found {
+		// A K8S resource can't move between clusters, so the prior asset shares the current ancestors.
+		priorAsset = shallowCopy(priorAsset)
+		priorAsset["ancestors"] = asset["ancestors"]
+	}
+	oldResource, err := ConvertCAIToK8s(priorAsset)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to convert prior CAI asset to k8s resource")
+	}
+	oldResourceJSON, err := json.Marshal(oldResource.Object)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal prior k8s resource (converted from CAI asset) to JSON")
+	}
+	req.Operation = admissionv1.Update
+	req.OldObject = runtime.RawExtension{
+		Raw: oldResourceJSON,
 	}
 	return req, nil
 }
 
+func shallowCopy(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
 // k8s assset names will follow pattern:
 // //container.googleapis.com/projects/*/(locations|zones)/*/clusters/*/k8s
 var assetPath = regexp.MustCompile(`^//container\.googleapis\.com/projects/[^/]*/(locations|zones)/[^/]*/clusters/[^/]*/k8s`)
@@ -203,3 +360,31 @@ func IsK8S(asset map[string]interface{}) bool {
 	}
 	return assetPath.MatchString(assetName)
 }
+
+// ancestorTypes maps the asset types of resources that can be ancestors of other resources to the
+// collection name they use in ancestry paths.
+var ancestorTypes = map[string]string{
+	"cloudresourcemanager.googleapis.com/Organization": "organizations",
+	"cloudresourcemanager.googleapis.com/Folder":       "folders",
+	"cloudresourcemanager.googleapis.com/Project":      "projects",
+}
+
+// AncestorName returns the name of the CAI asset as it appears in the ancestry paths of its
+// descendants, eg "folders/123", if the asset is an organization, folder or project.
+func AncestorName(asset map[string]interface{}) (string, bool) {
+	assetType, _, _ := unstructured.NestedString(asset, "asset_type")
+	collection, ok := ancestorTypes[assetType]
+	if !ok {
+		return "", false
+	}
+	name, _, _ := unstructured.NestedString(asset, "name")
+	idx := strings.LastIndex(name, "/"+collection+"/")
+	if idx < 0 {
+		return "", false
+	}
+	ancestor := name[idx+1:]
+	if strings.Count(ancestor, "/") != 1 || strings.HasSuffix(ancestor, "/") {
+		return "", false
+	}
+	return ancestor, true
+}
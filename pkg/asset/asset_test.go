@@ -15,13 +15,23 @@
 package asset
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/asset/apiv1/assetpb"
+	"cloud.google.com/go/iam/apiv1/iampb"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
 	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	admissionv1 "k8s.io/api/admission/v1"
 )
 
 func TestConvertResourceToInterface(t *testing.T) {
@@ -96,3 +106,323 @@ func TestConvertResourceToInterface(t *testing.T) {
 		})
 	}
 }
+
+// convertViaJSON converts the whole asset through JSON text, as ConvertResourceViaJSONToInterface
+// did before converting resource data directly.
+func convertViaJSON(asset *validator.Asset) (interface{}, error) {
+	buf, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(asset)
+	if err != nil {
+		return nil, err
+	}
+	var f map[string]interface{}
+	if err := json.Unmarshal(buf, &f); err != nil {
+		return nil, err
+	}
+	return f, NormalizeAccessContextPolicy(f)
+}
+
+// bigQueryTableAsset returns a BigQuery table asset with a schema of n columns.
+func bigQueryTableAsset(t testing.TB, n int) *validator.Asset {
+	t.Helper()
+	fields := make([]interface{}, n)
+	for i := range fields {
+		fields[i] = map[string]interface{}{
+			"name":        fmt.Sprintf("column_%d", i),
+			"type":        "RECORD",
+			"mode":        "NULLABLE",
+			"description": strings.Repeat("d", 64),
+			"fields": []interface{}{
+				map[string]interface{}{"name": "value", "type": "FLOAT", "maxLength": float64(i) + 0.5},
+				map[string]interface{}{"name": "flag", "type": "BOOLEAN", "policyTags": nil},
+			},
+		}
+	}
+	data, err := structpb.NewStruct(map[string]interface{}{
+		"id":                     "project:dataset.table",
+		"numBytes":               "123456789",
+		"requirePartitionFilter": true,
+		"schema":                 map[string]interface{}{"fields": fields},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &validator.Asset{
+		Name:         "//bigquery.googleapis.com/projects/p/datasets/dataset/tables/table",
+		AssetType:    "bigquery.googleapis.com/Table",
+		AncestryPath: "organizations/1/projects/2",
+		Resource: &assetpb.Resource{
+			Version:       "v2",
+			DiscoveryName: "Table",
+			Parent:        "//bigquery.googleapis.com/projects/p/datasets/dataset",
+			Data:          data,
+		},
+		IamPolicy:  &iampb.Policy{Bindings: []*iampb.Binding{{Role: "roles/bigquery.dataViewer", Members: []string{"user:a@example.com"}}}},
+		UpdateTime: timestamppb.New(time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC)),
+	}
+}
+
+func TestConvertResourceDataDirectly(t *testing.T) {
+	asset := bigQueryTableAsset(t, 50)
+	asset.Resource.Data.Fields["unset"] = &structpb.Value{}
+	got, err := ConvertResourceViaJSONToInterface(asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := convertViaJSON(asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("conversion differs from JSON (-want, +got) %v", diff)
+	}
+	if asset.Resource.Data == nil {
+		t.Errorf("conversion removed the resource data of the asset")
+	}
+
+	for name, value := range map[string]*structpb.Value{
+		"NaN":          structpb.NewNumberValue(math.NaN()),
+		"infinity":     structpb.NewNumberValue(math.Inf(1)),
+		"invalid UTF8": structpb.NewStringValue("\xff"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			asset := bigQueryTableAsset(t, 1)
+			asset.Resource.Data.Fields["bad"] = value
+			if _, err := ConvertResourceViaJSONToInterface(asset); err == nil {
+				t.Errorf("conversion succeeded, want error")
+			}
+		})
+	}
+}
+
+func BenchmarkConvertResourceViaJSONToInterface(b *testing.B) {
+	for _, columns := range []int{1, 5000} {
+		asset := bigQueryTableAsset(b, columns)
+		for name, convert := range map[string]func(*validator.Asset) (interface{}, error){
+			"direct": ConvertResourceViaJSONToInterface,
+			"json":   convertViaJSON,
+		} {
+			b.Run(fmt.Sprintf("%s/columns=%d", name, columns), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := convert(asset); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func k8sTestAsset(labels map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       "//container.googleapis.com/projects/p/zones/us-central1-a/clusters/c/k8s/namespaces/ns/pods/my-pod",
+		"asset_type": "k8s.io/Pod",
+		"ancestors":  []interface{}{"projects/123", "organizations/456"},
+		"resource": map[string]interface{}{
+			"version": "v1",
+			"data": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":      "my-pod",
+					"namespace": "ns",
+					"labels":    labels,
+				},
+			},
+		},
+	}
+}
+
+func TestConvertToAdmissionRequest(t *testing.T) {
+	testCases := []struct {
+		description   string
+		asset         map[string]interface{}
+		wantOperation admissionv1.Operation
+		wantObject    map[string]interface{}
+		wantOldObject map[string]interface{}
+	}{
+		{
+			description:   "create",
+			asset:         k8sTestAsset(map[string]interface{}{"app": "new"}),
+			wantOperation: admissionv1.Create,
+			wantObject:    map[string]interface{}{"app": "new"},
+		},
+		{
+			description: "update",
+			asset: func() map[string]interface{} {
+				a := k8sTestAsset(map[string]interface{}{"app": "new"})
+				prior := k8sTestAsset(map[string]interface{}{"app": "old"})
+				delete(prior, "ancestors")
+				a["prior_asset"] = prior
+				return a
+			}(),
+			wantOperation: admissionv1.Update,
+			wantObject:    map[string]interface{}{"app": "new"},
+			wantOldObject: map[string]interface{}{"app": "old"},
+		},
+		{
+			description: "delete",
+			asset: func() map[string]interface{} {
+				a := k8sTestAsset(map[string]interface{}{"app": "old"})
+				a["deleted"] = true
+				return a
+			}(),
+			wantOperation: admissionv1.Delete,
+			wantOldObject: map[string]interface{}{"app": "old"},
+		},
+	}
+
+	labels := func(t *testing.T, raw []byte) map[string]interface{} {
+		if raw == nil {
+			return nil
+		}
+		var obj struct {
+			Metadata struct {
+				Labels map[string]interface{} `json:"labels"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			t.Fatal(err)
+		}
+		return obj.Metadata.Labels
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			req, err := ConvertToAdmissionRequest(tc.asset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.Operation != tc.wantOperation {
+				t.Errorf("got operation %s, want %s", req.Operation, tc.wantOperation)
+			}
+			if req.Name != "my-pod" || req.Namespace != "ns" {
+				t.Errorf("got name %s/%s, want ns/my-pod", req.Namespace, req.Name)
+			}
+			if req.Kind.Group != "" || req.Kind.Version != "v1" || req.Kind.Kind != "Pod" {
+				t.Errorf("got kind %v, want v1 Pod", req.Kind)
+			}
+			if diff := cmp.Diff(tc.wantObject, labels(t, req.Object.Raw)); diff != "" {
+				t.Errorf("object labels diff (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantOldObject, labels(t, req.OldObject.Raw)); diff != "" {
+				t.Errorf("old object labels diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAncestryLimitsValidate(t *testing.T) {
+	testCases := []struct {
+		description  string
+		limits       AncestryLimits
+		ancestryPath string
+		wantErr      error
+	}{
+		{
+			description:  "valid",
+			limits:       DefaultAncestryLimits,
+			ancestryPath: "organizations/1/folders/2/projects/3",
+		},
+		{
+			description:  "too deep",
+			limits:       AncestryLimits{MaxDepth: 2},
+			ancestryPath: "organizations/1/folders/2/projects/3",
+			wantErr:      ErrAncestryTooDeep,
+		},
+		{
+			description:  "too long",
+			limits:       AncestryLimits{MaxLength: 16},
+			ancestryPath: "organizations/1/folders/2/projects/3",
+			wantErr:      ErrAncestryTooLong,
+		},
+		{
+			description:  "default depth",
+			limits:       DefaultAncestryLimits,
+			ancestryPath: strings.Repeat("folders/1/", DefaultMaxAncestryDepth) + "projects/2",
+			wantErr:      ErrAncestryTooDeep,
+		},
+		{
+			description:  "limits disabled",
+			limits:       AncestryLimits{},
+			ancestryPath: strings.Repeat("folders/1/", 1000) + "projects/2",
+		},
+		{
+			description:  "empty",
+			limits:       DefaultAncestryLimits,
+			ancestryPath: "",
+		},
+		{
+			description:  "odd segments",
+			limits:       DefaultAncestryLimits,
+			ancestryPath: "organizations/1/folders",
+			wantErr:      ErrMalformedAncestry,
+		},
+		{
+			description:  "empty segment",
+			limits:       DefaultAncestryLimits,
+			ancestryPath: "organizations//folders/2",
+			wantErr:      ErrMalformedAncestry,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := tc.limits.Validate(tc.ancestryPath)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("got error %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAncestorName(t *testing.T) {
+	testCases := []struct {
+		description string
+		assetType   string
+		name        string
+		want        string
+		wantOK      bool
+	}{
+		{
+			description: "organization",
+			assetType:   "cloudresourcemanager.googleapis.com/Organization",
+			name:        "//cloudresourcemanager.googleapis.com/organizations/1",
+			want:        "organizations/1",
+			wantOK:      true,
+		},
+		{
+			description: "folder",
+			assetType:   "cloudresourcemanager.googleapis.com/Folder",
+			name:        "//cloudresourcemanager.googleapis.com/folders/2",
+			want:        "folders/2",
+			wantOK:      true,
+		},
+		{
+			description: "project",
+			assetType:   "cloudresourcemanager.googleapis.com/Project",
+			name:        "//cloudresourcemanager.googleapis.com/projects/3",
+			want:        "projects/3",
+			wantOK:      true,
+		},
+		{
+			description: "not an ancestor",
+			assetType:   "storage.googleapis.com/Bucket",
+			name:        "//storage.googleapis.com/my-bucket",
+		},
+		{
+			description: "name does not match type",
+			assetType:   "cloudresourcemanager.googleapis.com/Folder",
+			name:        "//cloudresourcemanager.googleapis.com/projects/3",
+		},
+		{
+			description: "trailing segments",
+			assetType:   "cloudresourcemanager.googleapis.com/Project",
+			name:        "//cloudresourcemanager.googleapis.com/projects/3/extra",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got, ok := AncestorName(map[string]interface{}{"asset_type": tc.assetType, "name": tc.name})
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("got (%q, %v), want (%q, %v)", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import (
+	"strings"
+	"unicode"
+)
+
+// searchResultDataFields are the fields of a gcloud asset search-all-resources result that describe
+// the resource itself, they become resource.data when the result has no versioned resources.
+var searchResultDataFields = []string{
+	"displayName", "description", "location", "labels", "networkTags", "kmsKey", "kmsKeys", "state",
+	"createTime", "updateTime",
+}
+
+// NormalizeGcloudAsset converts an asset in the output format of gcloud, which uses the JSON names of
+// the CAI proto fields, eg assetType and iamPolicy, to the proto names the validator expects, eg
+// asset_type and iam_policy.  Assets from `gcloud asset export` or `gcloud asset list` keep their
+// structure, with the fields outside resource.data renamed.  Results of `gcloud asset
+// search-all-resources` are converted to an asset with the ancestors from their project, folders and
+// organization, and with the versioned resource, or else the searchable attributes, as resource.data.
+// It returns the asset unchanged and false if it already uses proto names or isn't from gcloud.
+func NormalizeGcloudAsset(asset map[string]interface{}) (map[string]interface{}, bool) {
+	if _, found := asset["asset_type"]; found {
+		return asset, false
+	}
+	if _, found := asset["assetType"]; !found {
+		return asset, false
+	}
+	if isSearchResult(asset) {
+		return convertSearchResult(asset), true
+	}
+	return protoNames(asset).(map[string]interface{}), true
+}
+
+// isSearchResult returns true if the asset is a search-all-resources result rather than an asset.
+func isSearchResult(asset map[string]interface{}) bool {
+	if _, found := asset["resource"]; found {
+		return false
+	}
+	for _, field := range []string{"project", "folders", "organization", "parentFullResourceName", "additionalAttributes", "versionedResources"} {
+		if _, found := asset[field]; found {
+			return true
+		}
+	}
+	return false
+}
+
+// protoNames returns a copy of value with the keys of its objects converted to proto names, except
+// under resource.data which holds the resource's own JSON representation.
+func protoNames(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			key := snakeCase(k)
+			if key == "resource" {
+				ret[key] = resourceProtoNames(v)
+				continue
+			}
+			ret[key] = protoNames(v)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(value))
+		for idx, v := range value {
+			ret[idx] = protoNames(v)
+		}
+		return ret
+	default:
+		return value
+	}
+}
+
+// resourceProtoNames is protoNames for the resource of an asset, whose data is left as it is.
+func resourceProtoNames(value interface{}) interface{} {
+	resource, ok := value.(map[string]interface{})
+	if !ok {
+		return protoNames(value)
+	}
+	ret := make(map[string]interface{}, len(resource))
+	for k, v := range resource {
+		if k == "data" {
+			ret[k] = v
+			continue
+		}
+		ret[snakeCase(k)] = protoNames(v)
+	}
+	return ret
+}
+
+// snakeCase converts a lowerCamelCase JSON name to the snake_case proto name, eg discoveryDocumentUri
+// to discovery_document_uri.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for idx, r := range name {
+		if unicode.IsUpper(r) {
+			if idx > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// convertSearchResult converts a search-all-resources result to an asset.
+func convertSearchResult(result map[string]interface{}) map[string]interface{} {
+	asset := map[string]interface{}{
+		"name":       result["name"],
+		"asset_type": result["assetType"],
+	}
+	var ancestors []interface{}
+	if project, ok := result["project"].(string); ok && project != "" {
+		ancestors = append(ancestors, project)
+	}
+	if folders, ok := result["folders"].([]interface{}); ok {
+		ancestors = append(ancestors, folders...)
+	}
+	if org, ok := result["organization"].(string); ok && org != "" {
+		ancestors = append(ancestors, org)
+	}
+	if len(ancestors) != 0 {
+		asset["ancestors"] = ancestors
+	}
+	if updateTime, found := result["updateTime"]; found {
+		asset["update_time"] = updateTime
+	}
+
+	resource := map[string]interface{}{}
+	if parent, ok := result["parentFullResourceName"].(string); ok && parent != "" {
+		resource["parent"] = parent
+	}
+	if location, ok := result["location"].(string); ok && location != "" {
+		resource["location"] = location
+	}
+	if versioned, ok := result["versionedResources"].([]interface{}); ok && len(versioned) != 0 {
+		if latest, ok := versioned[0].(map[string]interface{}); ok {
+			resource["version"] = latest["version"]
+			resource["data"] = latest["resource"]
+		}
+	}
+	if _, found := resource["data"]; !found {
+		data := map[string]interface{}{}
+		if attributes, ok := result["additionalAttributes"].(map[string]interface{}); ok {
+			for k, v := range attributes {
+				data[k] = v
+			}
+		}
+		for _, field := range searchResultDataFields {
+			if v, found := result[field]; found {
+				data[field] = v
+			}
+		}
+		resource["data"] = data
+	}
+	asset["resource"] = resource
+	return asset
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalizeGcloudAsset(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		want    string
+		changed bool
+	}{
+		{
+			name: "proto names",
+			input: `{"name": "//storage.googleapis.com/b", "asset_type": "storage.googleapis.com/Bucket",
+				"resource": {"discovery_name": "Bucket", "data": {"storageClass": "STANDARD"}}}`,
+			want: `{"name": "//storage.googleapis.com/b", "asset_type": "storage.googleapis.com/Bucket",
+				"resource": {"discovery_name": "Bucket", "data": {"storageClass": "STANDARD"}}}`,
+		},
+		{
+			name: "export",
+			input: `{
+				"name": "//storage.googleapis.com/b",
+				"assetType": "storage.googleapis.com/Bucket",
+				"ancestors": ["projects/3", "organizations/1"],
+				"updateTime": "2023-01-01T00:00:00Z",
+				"resource": {"discoveryName": "Bucket", "discoveryDocumentUri": "uri", "data": {"storageClass": "STANDARD"}},
+				"iamPolicy": {"auditConfigs": [{"auditLogConfigs": [{"logType": "DATA_READ"}]}]},
+				"orgPolicy": [{"booleanPolicy": {"enforced": true}}],
+				"priorAsset": {"assetType": "storage.googleapis.com/Bucket", "resource": {"data": {"storageClass": "NEARLINE"}}}
+			}`,
+			want: `{
+				"name": "//storage.googleapis.com/b",
+				"asset_type": "storage.googleapis.com/Bucket",
+				"ancestors": ["projects/3", "organizations/1"],
+				"update_time": "2023-01-01T00:00:00Z",
+				"resource": {"discovery_name": "Bucket", "discovery_document_uri": "uri", "data": {"storageClass": "STANDARD"}},
+				"iam_policy": {"audit_configs": [{"audit_log_configs": [{"log_type": "DATA_READ"}]}]},
+				"org_policy": [{"boolean_policy": {"enforced": true}}],
+				"prior_asset": {"asset_type": "storage.googleapis.com/Bucket", "resource": {"data": {"storageClass": "NEARLINE"}}}
+			}`,
+			changed: true,
+		},
+		{
+			name: "search result",
+			input: `{
+				"name": "//storage.googleapis.com/b",
+				"assetType": "storage.googleapis.com/Bucket",
+				"project": "projects/3",
+				"folders": ["folders/2"],
+				"organization": "organizations/1",
+				"location": "us-central1",
+				"labels": {"env": "prod"},
+				"parentFullResourceName": "//cloudresourcemanager.googleapis.com/projects/my-project",
+				"additionalAttributes": {"storageClass": "STANDARD"}
+			}`,
+			want: `{
+				"name": "//storage.googleapis.com/b",
+				"asset_type": "storage.googleapis.com/Bucket",
+				"ancestors": ["projects/3", "folders/2", "organizations/1"],
+				"resource": {
+					"parent": "//cloudresourcemanager.googleapis.com/projects/my-project",
+					"location": "us-central1",
+					"data": {"storageClass": "STANDARD", "location": "us-central1", "labels": {"env": "prod"}}
+				}
+			}`,
+			changed: true,
+		},
+		{
+			name: "search result with versioned resource",
+			input: `{
+				"name": "//storage.googleapis.com/b",
+				"assetType": "storage.googleapis.com/Bucket",
+				"project": "projects/3",
+				"versionedResources": [{"version": "v1", "resource": {"storageClass": "STANDARD"}}]
+			}`,
+			want: `{
+				"name": "//storage.googleapis.com/b",
+				"asset_type": "storage.googleapis.com/Bucket",
+				"ancestors": ["projects/3"],
+				"resource": {"version": "v1", "data": {"storageClass": "STANDARD"}}
+			}`,
+			changed: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var input, want map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.input), &input); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			got, changed := NormalizeGcloudAsset(input)
+			if changed != tc.changed {
+				t.Errorf("got changed %v, want %v", changed, tc.changed)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("NormalizeGcloudAsset (-want, +got) %v", diff)
+			}
+		})
+	}
+}
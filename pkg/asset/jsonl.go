@@ -0,0 +1,173 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/golang/glog"
+)
+
+const (
+	// maxLineSize is the largest JSONL line that will be read, CAI exports of large assets
+	// (eg BigQuery tables with large schemas) can exceed several megabytes.
+	maxLineSize = 64 * 1024 * 1024
+	// minLinesForErrorRatio is the number of lines that must be read before the error ratio is
+	// checked, this prevents aborting on a single bad line at the start of an input.
+	minLinesForErrorRatio = 100
+)
+
+// ErrTooManyErrors is returned when the ratio of malformed lines exceeds the configured maximum.
+var ErrTooManyErrors = errors.New("too many malformed lines")
+
+// LineError records a line of JSONL input that could not be parsed.
+type LineError struct {
+	// Path is the file the line was read from.
+	Path string
+	// Line is the 1-based line number.
+	Line int
+	// Err is the parse error.
+	Err error
+}
+
+// Error implements error.
+func (e *LineError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Err)
+}
+
+// Unwrap returns the parse error.
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// ReadReport summarizes a read of JSONL input.
+type ReadReport struct {
+	// Lines is the number of non-empty lines read.
+	Lines int
+	// Errors are the lines that could not be parsed.
+	Errors []*LineError
+}
+
+// ErrorRatio returns the fraction of lines that could not be parsed.
+func (r *ReadReport) ErrorRatio() float64 {
+	if r.Lines == 0 {
+		return 0
+	}
+	return float64(len(r.Errors)) / float64(r.Lines)
+}
+
+// JSONLReader reads assets from newline delimited JSON files such as CAI exports.  Malformed lines
+// are recorded in the ReadReport and skipped rather than failing the whole read.
+type JSONLReader struct {
+	// MaxErrorRatio is the largest fraction of malformed lines that is tolerated before the read
+	// is aborted with ErrTooManyErrors.  Zero aborts on the first malformed line.
+	MaxErrorRatio float64
+}
+
+// ReadPaths reads each local or GCS path, calling fn for each asset that was successfully parsed.
+// Errors returned by fn abort the read.
+func (r *JSONLReader) ReadPaths(ctx context.Context, paths []string, fn func(asset map[string]interface{}) error) (*ReadReport, error) {
+	report := &ReadReport{}
+	for _, path := range paths {
+		p, err := configs.NewPath(path)
+		if err != nil {
+			return report, fmt.Errorf("invalid path %s: %w", path, err)
+		}
+		files, err := p.ReadAll(ctx)
+		if err != nil {
+			return report, err
+		}
+		for _, file := range files {
+			if err := r.read(file.Path, bytes.NewReader(file.Content), report, ignoreLine(fn)); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// Read reads JSONL from reader, calling fn for each asset that was successfully parsed.  Path is
+// only used for error reporting.
+func (r *JSONLReader) Read(path string, reader io.Reader, fn func(asset map[string]interface{}) error) (*ReadReport, error) {
+	return r.ReadLines(path, reader, ignoreLine(fn))
+}
+
+// ReadLines is like Read, but also passes fn the 1-based line number of each asset, so that callers
+// can report where an asset came from when malformed lines were skipped before it.
+func (r *JSONLReader) ReadLines(path string, reader io.Reader, fn func(line int, asset map[string]interface{}) error) (*ReadReport, error) {
+	report := &ReadReport{}
+	err := r.read(path, reader, report, fn)
+	return report, err
+}
+
+// ignoreLine adapts an asset callback to the callback of ReadLines.
+func ignoreLine(fn func(asset map[string]interface{}) error) func(int, map[string]interface{}) error {
+	return func(_ int, asset map[string]interface{}) error {
+		return fn(asset)
+	}
+}
+
+func (r *JSONLReader) read(path string, reader io.Reader, report *ReadReport, fn func(line int, asset map[string]interface{}) error) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, maxLineSize)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		report.Lines++
+
+		asset := map[string]interface{}{}
+		if err := json.Unmarshal(line, &asset); err != nil {
+			lineErr := &LineError{Path: path, Line: lineNumber, Err: err}
+			glog.Warningf("skipping malformed line: %s", lineErr)
+			report.Errors = append(report.Errors, lineErr)
+			if err := r.checkErrorRatio(report, false); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(lineNumber, asset); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		// A line that does not fit in the buffer can't be skipped reliably, so it ends the read.
+		return fmt.Errorf("%s:%d: %w", path, lineNumber+1, err)
+	}
+	return r.checkErrorRatio(report, true)
+}
+
+// checkErrorRatio returns ErrTooManyErrors if the error ratio exceeds the maximum.  Before the end
+// of input the ratio is only checked once enough lines have been read for it to be meaningful.
+func (r *JSONLReader) checkErrorRatio(report *ReadReport, final bool) error {
+	if !final && report.Lines < minLinesForErrorRatio && r.MaxErrorRatio > 0 {
+		return nil
+	}
+	if report.ErrorRatio() > r.MaxErrorRatio {
+		return fmt.Errorf("%w: %d of %d lines malformed, max ratio %v",
+			ErrTooManyErrors, len(report.Errors), report.Lines, r.MaxErrorRatio)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONLReader(t *testing.T) {
+	testCases := []struct {
+		name          string
+		input         string
+		maxErrorRatio float64
+		wantNames     []string
+		wantErrLines  []int
+		wantErr       error
+	}{
+		{
+			name:      "valid input",
+			input:     "{\"name\": \"a\"}\n\n{\"name\": \"b\"}\n",
+			wantNames: []string{"a", "b"},
+		},
+		{
+			name:          "truncated line tolerated",
+			input:         "{\"name\": \"a\"}\n{\"name\": \n{\"name\": \"b\"}\n{\"name\": \"c\"}\n",
+			maxErrorRatio: 0.5,
+			wantNames:     []string{"a", "b", "c"},
+			wantErrLines:  []int{2},
+		},
+		{
+			name:          "error ratio exceeded",
+			input:         "{\"name\": \"a\"}\ngarbage\ngarbage\n",
+			maxErrorRatio: 0.5,
+			wantNames:     []string{"a"},
+			wantErrLines:  []int{2, 3},
+			wantErr:       ErrTooManyErrors,
+		},
+		{
+			name:         "strict mode aborts on first error",
+			input:        "garbage\n{\"name\": \"a\"}\n",
+			wantErrLines: []int{1},
+			wantErr:      ErrTooManyErrors,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var names []string
+			r := &JSONLReader{MaxErrorRatio: tc.maxErrorRatio}
+			report, err := r.Read("test.jsonl", strings.NewReader(tc.input), func(asset map[string]interface{}) error {
+				names = append(names, asset["name"].(string))
+				return nil
+			})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tc.wantErr)
+			}
+			if strings.Join(names, ",") != strings.Join(tc.wantNames, ",") {
+				t.Errorf("got assets %v, want %v", names, tc.wantNames)
+			}
+			var errLines []int
+			for _, lineErr := range report.Errors {
+				if lineErr.Path != "test.jsonl" {
+					t.Errorf("got path %s, want test.jsonl", lineErr.Path)
+				}
+				errLines = append(errLines, lineErr.Line)
+			}
+			if len(errLines) != len(tc.wantErrLines) {
+				t.Fatalf("got error lines %v, want %v", errLines, tc.wantErrLines)
+			}
+			for idx := range errLines {
+				if errLines[idx] != tc.wantErrLines[idx] {
+					t.Errorf("got error lines %v, want %v", errLines, tc.wantErrLines)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONLReaderReadLines(t *testing.T) {
+	input := "{\"name\": \"a\"}\n{\"name\": \n\n{\"name\": \"b\"}\n"
+	lines := map[string]int{}
+	r := &JSONLReader{MaxErrorRatio: 1}
+	if _, err := r.ReadLines("test.jsonl", strings.NewReader(input), func(line int, asset map[string]interface{}) error {
+		lines[asset["name"].(string)] = line
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// The malformed and empty lines still count towards the line numbers.
+	if lines["a"] != 1 || lines["b"] != 4 {
+		t.Errorf("got lines %v, want a at 1 and b at 4", lines)
+	}
+}
+
+func TestJSONLReaderReadPaths(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "export.json"), []byte("{\"name\": \"a\"}\n{\"na\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	r := &JSONLReader{MaxErrorRatio: 0.5}
+	report, err := r.ReadPaths(context.Background(), []string{dir}, func(asset map[string]interface{}) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || report.Lines != 2 || len(report.Errors) != 1 {
+		t.Errorf("got %d assets, report %+v", count, report)
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// krmGroupSuffix is the suffix of the API groups of Config Connector resources, eg
+// storage.cnrm.cloud.google.com.
+const krmGroupSuffix = ".cnrm.cloud.google.com"
+
+// Config Connector annotations that set the container of a resource.
+const (
+	krmProjectAnnotation      = "cnrm.cloud.google.com/project-id"
+	krmFolderAnnotation       = "cnrm.cloud.google.com/folder-id"
+	krmOrganizationAnnotation = "cnrm.cloud.google.com/organization-id"
+)
+
+// ErrUnsupportedKRMKind is returned by ConvertKRMToCAI for Config Connector kinds without a CAI
+// asset type mapping.
+var ErrUnsupportedKRMKind = errors.New("unsupported Config Connector kind")
+
+// krmKind describes how a Config Connector kind is converted to a CAI asset.
+type krmKind struct {
+	// assetType is the CAI asset type of the kind.
+	assetType string
+	// name returns the CAI asset name of the resource from its project, location and resource name.
+	name func(project, location, resourceName string) string
+	// fixData rewrites the fields of resource.data whose Config Connector spec differs from the GCP
+	// API representation CAI uses, nil if they are the same.
+	fixData func(data map[string]interface{}, resourceName string)
+}
+
+// krmKinds are the Config Connector kinds that can be converted to CAI assets, by kind.
+var krmKinds = map[string]krmKind{
+	"StorageBucket": {
+		assetType: "storage.googleapis.com/Bucket",
+		name: func(_, _, resourceName string) string {
+			return "//storage.googleapis.com/" + resourceName
+		},
+		fixData: fixStorageBucketData,
+	},
+	"ComputeInstance": {
+		assetType: "compute.googleapis.com/Instance",
+		name: func(project, zone, resourceName string) string {
+			return fmt.Sprintf("//compute.googleapis.com/projects/%s/zones/%s/instances/%s", project, zone, resourceName)
+		},
+	},
+	"ComputeFirewall": {
+		assetType: "compute.googleapis.com/Firewall",
+		name: func(project, _, resourceName string) string {
+			return fmt.Sprintf("//compute.googleapis.com/projects/%s/global/firewalls/%s", project, resourceName)
+		},
+	},
+	"ComputeNetwork": {
+		assetType: "compute.googleapis.com/Network",
+		name: func(project, _, resourceName string) string {
+			return fmt.Sprintf("//compute.googleapis.com/projects/%s/global/networks/%s", project, resourceName)
+		},
+	},
+	"SQLInstance": {
+		assetType: "sqladmin.googleapis.com/Instance",
+		name: func(project, _, resourceName string) string {
+			return fmt.Sprintf("//cloudsql.googleapis.com/projects/%s/instances/%s", project, resourceName)
+		},
+	},
+	"BigQueryDataset": {
+		assetType: "bigquery.googleapis.com/Dataset",
+		name: func(project, _, resourceName string) string {
+			return fmt.Sprintf("//bigquery.googleapis.com/projects/%s/datasets/%s", project, resourceName)
+		},
+	},
+	"PubSubTopic": {
+		assetType: "pubsub.googleapis.com/Topic",
+		name: func(project, _, resourceName string) string {
+			return fmt.Sprintf("//pubsub.googleapis.com/projects/%s/topics/%s", project, resourceName)
+		},
+	},
+	"ContainerCluster": {
+		assetType: "container.googleapis.com/Cluster",
+		name: func(project, location, resourceName string) string {
+			return fmt.Sprintf("//container.googleapis.com/projects/%s/locations/%s/clusters/%s", project, location, resourceName)
+		},
+	},
+	"IAMServiceAccount": {
+		assetType: "iam.googleapis.com/ServiceAccount",
+		name: func(project, _, resourceName string) string {
+			return fmt.Sprintf("//iam.googleapis.com/projects/%s/serviceAccounts/%s@%s.iam.gserviceaccount.com", project, resourceName, project)
+		},
+	},
+	"Project": {
+		assetType: "cloudresourcemanager.googleapis.com/Project",
+		name: func(_, _, resourceName string) string {
+			return "//cloudresourcemanager.googleapis.com/projects/" + resourceName
+		},
+		fixData: func(data map[string]interface{}, resourceName string) {
+			// The spec name of a project is its display name, as it is in the API.
+			data["projectId"] = resourceName
+		},
+	},
+}
+
+// krmReferenceFields are the spec fields of Config Connector resources that reference their container
+// rather than configure the resource, they are left out of resource.data.
+var krmReferenceFields = []string{"resourceID", "projectRef", "folderRef", "organizationRef"}
+
+// IsKRM returns true if obj is a Config Connector resource, ie its API group is a
+// cnrm.cloud.google.com group.
+func IsKRM(obj map[string]interface{}) bool {
+	apiVersion, _ := obj["apiVersion"].(string)
+	group := strings.SplitN(apiVersion, "/", 2)[0]
+	return strings.HasSuffix(group, krmGroupSuffix)
+}
+
+// ConvertKRMToCAI converts a Config Connector resource, as it appears in a GitOps repo, to the CAI
+// asset of the resource it manages, so that it can be reviewed with the GCP constraints.  The spec
+// becomes resource.data, named after the resource unless the spec has a name and labelled with the
+// metadata labels.  The ancestry path is the resource's project, folder or organization, resolved from
+// its references, annotations or namespace as Config Connector does, so the asset has no ancestors
+// that aren't declared on the resource.  Kinds without an asset type mapping return
+// ErrUnsupportedKRMKind.  obj is not modified.
+func ConvertKRMToCAI(obj map[string]interface{}) (map[string]interface{}, error) {
+	u := &unstructured.Unstructured{Object: runtime.DeepCopyJSON(obj)}
+	kind, found := krmKinds[u.GetKind()]
+	if !found {
+		return nil, errors.Wrapf(ErrUnsupportedKRMKind, "%s", u.GetKind())
+	}
+
+	spec, _, err := unstructured.NestedMap(u.Object, "spec")
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid spec")
+	}
+	if spec == nil {
+		spec = map[string]interface{}{}
+	}
+	resourceName, _, _ := unstructured.NestedString(spec, "resourceID")
+	if resourceName == "" {
+		resourceName = u.GetName()
+	}
+	if resourceName == "" {
+		return nil, errors.Errorf("%s has no metadata.name", u.GetKind())
+	}
+	ancestryPath, project := krmAncestry(u, spec)
+	if ancestryPath == "" {
+		return nil, errors.Errorf("%s %s has no project, folder or organization", u.GetKind(), resourceName)
+	}
+	if u.GetKind() == "Project" {
+		ancestryPath += "/projects/" + resourceName
+	}
+	location, _, _ := unstructured.NestedString(spec, "location")
+	if location == "" {
+		location, _, _ = unstructured.NestedString(spec, "zone")
+	}
+
+	data := spec
+	for _, field := range krmReferenceFields {
+		delete(data, field)
+	}
+	if _, found := data["name"]; !found {
+		data["name"] = resourceName
+	}
+	if labels := u.GetLabels(); len(labels) != 0 {
+		dataLabels := map[string]interface{}{}
+		for k, v := range labels {
+			dataLabels[k] = v
+		}
+		data["labels"] = dataLabels
+	}
+	if kind.fixData != nil {
+		kind.fixData(data, resourceName)
+	}
+
+	return map[string]interface{}{
+		"name":          kind.name(project, location, resourceName),
+		"asset_type":    kind.assetType,
+		"ancestry_path": ancestryPath,
+		"resource": map[string]interface{}{
+			"version":        u.GroupVersionKind().Version,
+			"discovery_name": kind.assetType[strings.LastIndex(kind.assetType, "/")+1:],
+			"data":           data,
+		},
+	}, nil
+}
+
+// krmAncestry returns the ancestry path of a Config Connector resource and its project, empty for
+// resources in a folder or organization.  The container is resolved in Config Connector's order: the
+// spec references, the annotations and then the namespace, which names the project by default.
+func krmAncestry(u *unstructured.Unstructured, spec map[string]interface{}) (string, string) {
+	if u.GetKind() != "Project" {
+		if project := krmExternalRef(spec, "projectRef", "projects/"); project != "" {
+			return "projects/" + project, project
+		}
+	}
+	if folder := krmExternalRef(spec, "folderRef", "folders/"); folder != "" {
+		return "folders/" + folder, ""
+	}
+	if org := krmExternalRef(spec, "organizationRef", "organizations/"); org != "" {
+		return "organizations/" + org, ""
+	}
+	annotations := u.GetAnnotations()
+	if u.GetKind() != "Project" {
+		if project := annotations[krmProjectAnnotation]; project != "" {
+			return "projects/" + project, project
+		}
+	}
+	if folder := annotations[krmFolderAnnotation]; folder != "" {
+		return "folders/" + folder, ""
+	}
+	if org := annotations[krmOrganizationAnnotation]; org != "" {
+		return "organizations/" + org, ""
+	}
+	if u.GetKind() != "Project" && u.GetNamespace() != "" {
+		return "projects/" + u.GetNamespace(), u.GetNamespace()
+	}
+	return "", ""
+}
+
+// krmExternalRef returns the external ID of a Config Connector resource reference, eg
+// spec.projectRef.external, without the collection prefix.  References to other Config Connector
+// resources by name are resolved by the name, as is Config Connector's default.
+func krmExternalRef(spec map[string]interface{}, field, prefix string) string {
+	ref, ok := spec[field].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if external, ok := ref["external"].(string); ok && external != "" {
+		return strings.TrimPrefix(external, prefix)
+	}
+	name, _ := ref["name"].(string)
+	return name
+}
+
+// fixStorageBucketData moves the bucket fields that Config Connector flattens to where the storage
+// API, and so CAI, has them.
+func fixStorageBucketData(data map[string]interface{}, _ string) {
+	if enabled, found := data["uniformBucketLevelAccess"]; found {
+		delete(data, "uniformBucketLevelAccess")
+		_ = unstructured.SetNestedField(data, enabled, "iamConfiguration", "uniformBucketLevelAccess", "enabled")
+	}
+	if location, ok := data["location"].(string); ok {
+		data["location"] = strings.ToUpper(location)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConvertKRMToCAI(t *testing.T) {
+	testCases := []struct {
+		name string
+		obj  map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "bucket in namespace project",
+			obj: map[string]interface{}{
+				"apiVersion": "storage.cnrm.cloud.google.com/v1beta1",
+				"kind":       "StorageBucket",
+				"metadata": map[string]interface{}{
+					"name":      "my-bucket",
+					"namespace": "my-project",
+					"labels":    map[string]interface{}{"env": "prod"},
+				},
+				"spec": map[string]interface{}{
+					"location":                 "us-east1",
+					"uniformBucketLevelAccess": true,
+				},
+			},
+			want: map[string]interface{}{
+				"name":          "//storage.googleapis.com/my-bucket",
+				"asset_type":    "storage.googleapis.com/Bucket",
+				"ancestry_path": "projects/my-project",
+				"resource": map[string]interface{}{
+					"version":        "v1beta1",
+					"discovery_name": "Bucket",
+					"data": map[string]interface{}{
+						"name":     "my-bucket",
+						"location": "US-EAST1",
+						"labels":   map[string]interface{}{"env": "prod"},
+						"iamConfiguration": map[string]interface{}{
+							"uniformBucketLevelAccess": map[string]interface{}{"enabled": true},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "instance with resource ID and project ref",
+			obj: map[string]interface{}{
+				"apiVersion": "compute.cnrm.cloud.google.com/v1beta1",
+				"kind":       "ComputeInstance",
+				"metadata": map[string]interface{}{
+					"name":        "vm",
+					"namespace":   "config-control",
+					"annotations": map[string]interface{}{"cnrm.cloud.google.com/project-id": "ignored"},
+				},
+				"spec": map[string]interface{}{
+					"resourceID":  "my-vm",
+					"zone":        "us-east1-b",
+					"machineType": "n1-standard-1",
+					"projectRef":  map[string]interface{}{"external": "projects/my-project"},
+				},
+			},
+			want: map[string]interface{}{
+				"name":          "//compute.googleapis.com/projects/my-project/zones/us-east1-b/instances/my-vm",
+				"asset_type":    "compute.googleapis.com/Instance",
+				"ancestry_path": "projects/my-project",
+				"resource": map[string]interface{}{
+					"version":        "v1beta1",
+					"discovery_name": "Instance",
+					"data": map[string]interface{}{
+						"name":        "my-vm",
+						"zone":        "us-east1-b",
+						"machineType": "n1-standard-1",
+					},
+				},
+			},
+		},
+		{
+			name: "project in folder",
+			obj: map[string]interface{}{
+				"apiVersion": "resourcemanager.cnrm.cloud.google.com/v1beta1",
+				"kind":       "Project",
+				"metadata": map[string]interface{}{
+					"name":        "my-project",
+					"namespace":   "projects",
+					"annotations": map[string]interface{}{"cnrm.cloud.google.com/folder-id": "123"},
+				},
+				"spec": map[string]interface{}{
+					"name": "My Project",
+				},
+			},
+			want: map[string]interface{}{
+				"name":          "//cloudresourcemanager.googleapis.com/projects/my-project",
+				"asset_type":    "cloudresourcemanager.googleapis.com/Project",
+				"ancestry_path": "folders/123/projects/my-project",
+				"resource": map[string]interface{}{
+					"version":        "v1beta1",
+					"discovery_name": "Project",
+					"data": map[string]interface{}{
+						"name":      "My Project",
+						"projectId": "my-project",
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !IsKRM(tc.obj) {
+				t.Fatalf("IsKRM got false, want true")
+			}
+			got, err := ConvertKRMToCAI(tc.obj)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ConvertKRMToCAI (-want, +got) %v", diff)
+			}
+		})
+	}
+}
+
+func TestConvertKRMToCAIErrors(t *testing.T) {
+	unsupported := map[string]interface{}{
+		"apiVersion": "dns.cnrm.cloud.google.com/v1beta1",
+		"kind":       "DNSManagedZone",
+		"metadata":   map[string]interface{}{"name": "zone", "namespace": "my-project"},
+	}
+	if _, err := ConvertKRMToCAI(unsupported); !errors.Is(err, ErrUnsupportedKRMKind) {
+		t.Errorf("got error %v, want ErrUnsupportedKRMKind", err)
+	}
+	noProject := map[string]interface{}{
+		"apiVersion": "storage.cnrm.cloud.google.com/v1beta1",
+		"kind":       "StorageBucket",
+		"metadata":   map[string]interface{}{"name": "my-bucket"},
+	}
+	if _, err := ConvertKRMToCAI(noProject); err == nil {
+		t.Error("expected error for resource without a project")
+	}
+	if IsKRM(map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"}) {
+		t.Error("IsKRM got true for a ConfigMap")
+	}
+}
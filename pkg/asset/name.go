@@ -0,0 +1,160 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import (
+	"regexp"
+	"strings"
+)
+
+// NameOption configures NormalizeName.
+type NameOption func(*nameOptions)
+
+type nameOptions struct {
+	// service is the service of relative names, eg "compute.googleapis.com".
+	service string
+	// projectNumbers maps project IDs to project numbers.
+	projectNumbers map[string]string
+}
+
+// NameAssetType sets the asset type of the named asset, eg "compute.googleapis.com/Instance".  Relative
+// names of Google API assets are made full names with the service of the asset type.
+func NameAssetType(assetType string) NameOption {
+	return func(o *nameOptions) {
+		service := strings.SplitN(assetType, "/", 2)[0]
+		if strings.HasSuffix(service, ".googleapis.com") {
+			o.service = strings.ToLower(service)
+		}
+	}
+}
+
+// NameProjectNumbers sets the project numbers of project IDs, so that names with a project ID are
+// normalized to the project number form used in ancestry paths.
+func NameProjectNumbers(projectNumbers map[string]string) NameOption {
+	return func(o *nameOptions) {
+		o.projectNumbers = projectNumbers
+	}
+}
+
+// hierarchyCollections maps the collections of the resource hierarchy, including the singular forms
+// of legacy ancestry paths, to their canonical form.
+var hierarchyCollections = map[string]string{
+	"organizations": "organizations",
+	"organization":  "organizations",
+	"folders":       "folders",
+	"folder":        "folders",
+	"projects":      "projects",
+	"project":       "projects",
+}
+
+// apiVersion matches the version segment of a REST URL, eg "v1" or "v1beta2".
+var apiVersion = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]*)?$`)
+
+// NormalizeName returns the canonical form of a CAI resource name, so that names given in different
+// styles by asset producers compare equal:
+//
+//   - Surrounding whitespace, empty path segments and trailing slashes are removed.
+//   - REST URLs of Google APIs, eg compute self links such as
+//     https://www.googleapis.com/compute/v1/projects/p/zones/z/instances/i, become full resource names
+//     such as //compute.googleapis.com/projects/p/zones/z/instances/i.
+//   - Relative names become full resource names if the service is known from NameAssetType.
+//   - The service and the organization, folder and project segments leading the path are lower
+//     cased, and project IDs are replaced with project numbers given by NameProjectNumbers.
+//
+// Other segments are left as is since resource IDs may be case sensitive.  Names that are not Google
+// API names, such as terraform addresses, are only trimmed.
+func NormalizeName(name string, opts ...NameOption) string {
+	o := &nameOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	name = strings.TrimSpace(name)
+	var service string
+	var segments []string
+	switch {
+	case strings.HasPrefix(name, "//"):
+		service, segments = splitService(name[2:])
+	case strings.HasPrefix(name, "https://") || strings.HasPrefix(name, "http://"):
+		var ok bool
+		service, segments, ok = splitURL(name[strings.Index(name, "://")+3:])
+		if !ok {
+			return strings.TrimRight(name, "/")
+		}
+	default:
+		service, segments = o.service, splitPath(name)
+	}
+
+	for idx := 0; idx+1 < len(segments); idx += 2 {
+		collection, ok := hierarchyCollections[strings.ToLower(segments[idx])]
+		if !ok {
+			break
+		}
+		segments[idx] = collection
+		if collection != "projects" {
+			continue
+		}
+		project := strings.ToLower(segments[idx+1])
+		if number, ok := o.projectNumbers[project]; ok {
+			project = number
+		}
+		segments[idx+1] = project
+	}
+
+	path := strings.Join(segments, "/")
+	if service == "" {
+		return path
+	}
+	return "//" + service + "/" + path
+}
+
+// splitPath splits a path into its non-empty segments.
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+}
+
+// splitService splits a full resource name, without the leading slashes, into its lower cased service
+// and the segments of its path.
+func splitService(name string) (string, []string) {
+	segments := splitPath(name)
+	if len(segments) == 0 {
+		return "", nil
+	}
+	return strings.ToLower(segments[0]), segments[1:]
+}
+
+// splitURL splits a Google API REST URL, without the scheme, into the service and the segments of the
+// resource path.  The API name and version that prefix the path are dropped, eg for
+// www.googleapis.com/compute/v1/projects/p the service is compute.googleapis.com and the path is
+// projects/p.  It returns false if the URL is not a Google API URL.
+func splitURL(url string) (string, []string, bool) {
+	host, segments := splitService(url)
+	if !strings.HasSuffix(host, ".googleapis.com") {
+		return "", nil, false
+	}
+	if host == "www.googleapis.com" {
+		if len(segments) == 0 {
+			return "", nil, false
+		}
+		host = strings.ToLower(segments[0]) + ".googleapis.com"
+		segments = segments[1:]
+	} else if len(segments) > 0 && strings.ToLower(segments[0]) == strings.TrimSuffix(host, ".googleapis.com") {
+		segments = segments[1:]
+	}
+	if len(segments) > 0 && apiVersion.MatchString(segments[0]) {
+		segments = segments[1:]
+	}
+	return host, segments, true
+}
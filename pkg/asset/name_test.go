@@ -0,0 +1,116 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	projectNumbers := NameProjectNumbers(map[string]string{"my-project": "123"})
+	testCases := []struct {
+		description string
+		name        string
+		opts        []NameOption
+		want        string
+	}{
+		{
+			description: "canonical name unchanged",
+			name:        "//storage.googleapis.com/my-bucket",
+			want:        "//storage.googleapis.com/my-bucket",
+		},
+		{
+			description: "whitespace and slashes",
+			name:        " //storage.googleapis.com//my-bucket/ ",
+			want:        "//storage.googleapis.com/my-bucket",
+		},
+		{
+			description: "service and hierarchy case",
+			name:        "//Compute.GoogleAPIs.com/Projects/My-Project/zones/us-central1-a/instances/Instance-1",
+			want:        "//compute.googleapis.com/projects/my-project/zones/us-central1-a/instances/Instance-1",
+		},
+		{
+			description: "project ID to number",
+			name:        "//compute.googleapis.com/projects/my-project/global/networks/default",
+			opts:        []NameOption{projectNumbers},
+			want:        "//compute.googleapis.com/projects/123/global/networks/default",
+		},
+		{
+			description: "unknown project ID unchanged",
+			name:        "//compute.googleapis.com/projects/other-project/global/networks/default",
+			opts:        []NameOption{projectNumbers},
+			want:        "//compute.googleapis.com/projects/other-project/global/networks/default",
+		},
+		{
+			description: "only leading hierarchy segments",
+			name:        "//iam.googleapis.com/projects/my-project/serviceAccounts/sa/keys/projects",
+			opts:        []NameOption{projectNumbers},
+			want:        "//iam.googleapis.com/projects/123/serviceAccounts/sa/keys/projects",
+		},
+		{
+			description: "self link",
+			name:        "https://www.googleapis.com/compute/v1/projects/my-project/zones/us-central1-a/instances/i",
+			want:        "//compute.googleapis.com/projects/my-project/zones/us-central1-a/instances/i",
+		},
+		{
+			description: "service URL",
+			name:        "https://container.googleapis.com/v1beta1/projects/my-project/locations/us-central1/clusters/c",
+			want:        "//container.googleapis.com/projects/my-project/locations/us-central1/clusters/c",
+		},
+		{
+			description: "service URL with API name",
+			name:        "https://compute.googleapis.com/compute/v1/projects/my-project/regions/us-central1",
+			want:        "//compute.googleapis.com/projects/my-project/regions/us-central1",
+		},
+		{
+			description: "other URL",
+			name:        "https://example.com/projects/my-project/",
+			want:        "https://example.com/projects/my-project",
+		},
+		{
+			description: "relative name with asset type",
+			name:        "projects/my-project/datasets/d",
+			opts:        []NameOption{NameAssetType("bigquery.googleapis.com/Dataset"), projectNumbers},
+			want:        "//bigquery.googleapis.com/projects/123/datasets/d",
+		},
+		{
+			description: "relative name without asset type",
+			name:        "project/my-project",
+			want:        "projects/my-project",
+		},
+		{
+			description: "relative name with non-API asset type",
+			name:        "ns/pod",
+			opts:        []NameOption{NameAssetType("k8s.io/Pod")},
+			want:        "ns/pod",
+		},
+		{
+			description: "ancestry path",
+			name:        "organizations/1/folders/2/projects/my-project",
+			opts:        []NameOption{projectNumbers},
+			want:        "organizations/1/folders/2/projects/123",
+		},
+		{
+			description: "terraform address",
+			name:        "module.a.google_storage_bucket.b",
+			want:        "module.a.google_storage_bucket.b",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if got := NormalizeName(tc.name, tc.opts...); got != tc.want {
+				t.Errorf("NormalizeName(%q) = %q, want %q", tc.name, got, tc.want)
+			}
+		})
+	}
+}
@@ -15,11 +15,16 @@
 package asset
 
 import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
 // CleanProtoValue recursively updates proto Values that have a nil .Kind field
-// to be a NullValue to avoid issues with the jsonpb.Marshaler.
+// to be a NullValue to avoid issues with protojson.Marshal.
 // This issue arose when calling GCV from python.
 func CleanProtoValue(v *structpb.Value) {
 	if v == nil {
@@ -48,3 +53,67 @@ func CleanStructValue(s *structpb.Struct) {
 		CleanProtoValue(s.Fields[k])
 	}
 }
+
+// structToMap converts a proto Struct to the map encoding/json would decode from its protojson
+// encoding, without the JSON text in between.  Like protojson it fails on values that JSON can't
+// represent: NaN, infinities and invalid UTF-8.  Values without a kind must be cleaned with
+// CleanStructValue first.
+func structToMap(s *structpb.Struct) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, len(s.GetFields()))
+	for k, v := range s.GetFields() {
+		if !utf8.ValidString(k) {
+			return nil, fmt.Errorf("invalid UTF-8 in field name %q", k)
+		}
+		converted, err := valueToInterface(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		m[k] = converted
+	}
+	return m, nil
+}
+
+// valueToInterface converts a proto Value like structToMap.
+func valueToInterface(v *structpb.Value) (interface{}, error) {
+	switch t := v.GetKind().(type) {
+	case *structpb.Value_NullValue:
+		return nil, nil
+	case *structpb.Value_NumberValue:
+		if math.IsNaN(t.NumberValue) || math.IsInf(t.NumberValue, 0) {
+			return nil, fmt.Errorf("invalid number %v", t.NumberValue)
+		}
+		return t.NumberValue, nil
+	case *structpb.Value_StringValue:
+		if !utf8.ValidString(t.StringValue) {
+			return nil, fmt.Errorf("invalid UTF-8 in string %q", t.StringValue)
+		}
+		return t.StringValue, nil
+	case *structpb.Value_BoolValue:
+		return t.BoolValue, nil
+	case *structpb.Value_StructValue:
+		return structToMap(t.StructValue)
+	case *structpb.Value_ListValue:
+		values := t.ListValue.GetValues()
+		list := make([]interface{}, len(values))
+		for i, value := range values {
+			converted, err := valueToInterface(value)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			list[i] = converted
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("value has no kind")
+	}
+}
+
+// shallowCopyMessage returns a copy of m that shares its field values.
+func shallowCopyMessage(m protoreflect.Message) protoreflect.Message {
+	copied := m.New()
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		copied.Set(fd, v)
+		return true
+	})
+	return copied
+}
@@ -0,0 +1,276 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth authenticates and authorizes the callers of the validator RPC service, so that it can
+// be exposed beyond a trusted network without a proxy in front of it.
+//
+// Callers authenticate with a static API key in the x-api-key metadata, or with a Google ID token as
+// a bearer token in the authorization metadata, whose signature and audience are verified.  The
+// authenticated caller, its principal, is then authorized per method, and stored as the caller
+// identity, see the identity package, so that violations are attributed to it.
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/identity"
+	"github.com/ghodss/yaml"
+	"github.com/golang/glog"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// APIKeyHeader is the metadata key of the API key of a caller.
+	APIKeyHeader = "x-api-key"
+	// APIKeyPrefix prefixes the name of an API key in the principal of the callers that use it.
+	APIKeyPrefix = "apikey:"
+	// AnyMethod is the key of Config.Methods whose principals may call the methods that have no entry.
+	AnyMethod = "*"
+
+	authorizationHeader = "authorization"
+	bearerPrefix        = "bearer "
+)
+
+// Config is the authentication and authorization config of the server, eg
+//
+//	apiKeys:
+//	  ci: ${CI_API_KEY}
+//	audiences: [https://validator.example.com]
+//	methods:
+//	  /validator.Validator/Review: [apikey:ci, "*@my-project.iam.gserviceaccount.com"]
+//	  "*": [admin@example.com]
+//	unauthenticated: [/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo]
+type Config struct {
+	// APIKeys maps the name of each API key to the key.  Callers that present a key have the principal
+	// "apikey:<name>".
+	APIKeys map[string]string `json:"apiKeys,omitempty"`
+	// Audiences are the accepted audiences of Google ID tokens.  Callers that present a valid token
+	// have its email claim, or else its subject, as principal.  ID tokens are rejected when empty.
+	Audiences []string `json:"audiences,omitempty"`
+	// Methods maps full method names, eg /validator.Validator/Review, to the principals allowed to
+	// call them, as path.Match patterns such as "*@example.com" or "*" for every authenticated caller.
+	// The principals of AnyMethod may call the methods without an entry.  Every authenticated caller
+	// may call every method when Methods is empty.
+	Methods map[string][]string `json:"methods,omitempty"`
+	// Unauthenticated are the full method names that may be called without credentials.
+	Unauthenticated []string `json:"unauthenticated,omitempty"`
+}
+
+// LoadConfig reads the Config from the YAML or JSON file at path.  References to environment
+// variables, as $VAR or ${VAR}, are expanded before the file is parsed, so that the API keys need not
+// be stored in it.
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err = yaml.YAMLToJSON([]byte(os.ExpandEnv(string(content))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	config := &Config{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// TokenValidator verifies the signature, expiry and audience of a Google ID token, as idtoken.Validate
+// does.
+type TokenValidator func(ctx context.Context, token string, audience string) (*idtoken.Payload, error)
+
+// Option configures an Authenticator.
+type Option func(*Authenticator)
+
+// WithTokenValidator sets the validator of ID tokens, idtoken.Validate by default.
+func WithTokenValidator(validator TokenValidator) Option {
+	return func(a *Authenticator) {
+		a.validateToken = validator
+	}
+}
+
+// Authenticator authenticates and authorizes callers according to a Config.
+type Authenticator struct {
+	config          *Config
+	validateToken   TokenValidator
+	unauthenticated map[string]bool
+}
+
+// New returns an Authenticator for the config, or an error if the config is invalid.
+func New(config *Config, opts ...Option) (*Authenticator, error) {
+	if len(config.APIKeys) == 0 && len(config.Audiences) == 0 {
+		return nil, fmt.Errorf("no API keys or ID token audiences configured, no caller could authenticate")
+	}
+	for name, key := range config.APIKeys {
+		if key == "" {
+			return nil, fmt.Errorf("API key %q is empty", name)
+		}
+	}
+	for method, principals := range config.Methods {
+		for _, principal := range principals {
+			if _, err := path.Match(principal, ""); err != nil {
+				return nil, fmt.Errorf("invalid principal %q of method %s: %w", principal, method, err)
+			}
+		}
+	}
+	a := &Authenticator{
+		config:          config,
+		validateToken:   idtoken.Validate,
+		unauthenticated: map[string]bool{},
+	}
+	for _, method := range config.Unauthenticated {
+		a.unauthenticated[method] = true
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a, nil
+}
+
+// Authenticate returns the principal of the caller of the incoming request context.  The error is an
+// Unauthenticated status if the caller presents no credentials or invalid ones.
+func (a *Authenticator) Authenticate(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range md.Get(APIKeyHeader) {
+		if name, ok := a.apiKeyName(key); ok {
+			return APIKeyPrefix + name, nil
+		}
+		return "", status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	for _, value := range md.Get(authorizationHeader) {
+		if len(value) < len(bearerPrefix) || !strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+			continue
+		}
+		return a.tokenPrincipal(ctx, value[len(bearerPrefix):])
+	}
+	return "", status.Error(codes.Unauthenticated, "no API key or bearer token")
+}
+
+// apiKeyName returns the name of the API key, comparing it with every configured key in constant time.
+func (a *Authenticator) apiKeyName(key string) (string, bool) {
+	var found string
+	for name, configured := range a.config.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(configured)) == 1 {
+			found = name
+		}
+	}
+	return found, found != ""
+}
+
+// tokenPrincipal validates the ID token against each audience and returns its principal.
+func (a *Authenticator) tokenPrincipal(ctx context.Context, token string) (string, error) {
+	if len(a.config.Audiences) == 0 {
+		return "", status.Error(codes.Unauthenticated, "ID tokens are not accepted")
+	}
+	var lastErr error
+	for _, audience := range a.config.Audiences {
+		payload, err := a.validateToken(ctx, token, audience)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if email, ok := payload.Claims["email"].(string); ok && email != "" {
+			return email, nil
+		}
+		if payload.Subject != "" {
+			return payload.Subject, nil
+		}
+		return "", status.Error(codes.Unauthenticated, "ID token has no email or subject")
+	}
+	return "", status.Errorf(codes.Unauthenticated, "invalid ID token: %v", lastErr)
+}
+
+// Authorize returns a PermissionDenied status if the principal may not call the full method.
+func (a *Authenticator) Authorize(principal, method string) error {
+	if len(a.config.Methods) == 0 {
+		return nil
+	}
+	patterns, found := a.config.Methods[method]
+	if !found {
+		patterns = a.config.Methods[AnyMethod]
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, principal); matched {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "%s may not call %s", principal, method)
+}
+
+// check authenticates and authorizes the caller of the method and returns the context with its
+// principal as caller identity.
+func (a *Authenticator) check(ctx context.Context, method string) (context.Context, error) {
+	if a.unauthenticated[method] {
+		return ctx, nil
+	}
+	principal, err := a.Authenticate(ctx)
+	if err != nil {
+		glog.V(1).Infof("rejected unauthenticated call to %s: %v", method, err)
+		return nil, err
+	}
+	if err := a.Authorize(principal, method); err != nil {
+		glog.Warningf("rejected unauthorized call to %s: %v", method, err)
+		return nil, err
+	}
+	return identity.NewVerifiedContext(ctx, principal), nil
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that rejects the unary calls of callers that fail
+// to authenticate or are not allowed to call the method.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.check(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that rejects the streaming calls, such as
+// ReviewStream or those of server reflection, of callers that fail to authenticate or are not allowed
+// to call the method.
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.check(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream is a stream with the context of the authenticated caller.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context implements grpc.ServerStream.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,327 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client is a Go client for the validator RPC service.  It retries requests that fail with
+// transient errors, and splits the assets of Review and AddData requests into requests that stay under
+// the server's message size limit, see the msgsize package.
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/msgsize"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultMaxRequestBytes is the size that requests are split to stay under unless set with
+	// MaxRequestBytes, the default receive limit of gRPC servers.
+	DefaultMaxRequestBytes = 4 * 1024 * 1024
+	// DefaultMaxResponseBytes is the largest response that is accepted unless set with
+	// MaxResponseBytes.  Reviews of large requests can have many violations.
+	DefaultMaxResponseBytes = 128 * 1024 * 1024
+	// DefaultRetries is the number of times a request is retried unless set with Retries.
+	DefaultRetries = 3
+
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+)
+
+// Client calls the validator RPC service.  It is safe for concurrent use.
+type Client struct {
+	conn             *grpc.ClientConn
+	stub             validator.ValidatorClient
+	dialOpts         []grpc.DialOption
+	maxRequestBytes  int
+	maxResponseBytes int
+	retries          int
+	initialBackoff   time.Duration
+	maxBackoff       time.Duration
+	sleep            func(ctx context.Context, d time.Duration) error
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// DialOptions adds options for the connection made by Dial, eg
+// grpc.WithTransportCredentials.  Connections are made without TLS unless transport credentials are
+// given, as the server does not serve TLS itself.
+func DialOptions(opts ...grpc.DialOption) Option {
+	return func(c *Client) {
+		c.dialOpts = append(c.dialOpts, opts...)
+	}
+}
+
+// MaxRequestBytes sets the size that Review and AddData requests are split to stay under.  It should
+// be at most the -maxMessageRecvSize, or -methodMaxRecvSize, of the server.  Requests that the server
+// still rejects as too large are split further.
+func MaxRequestBytes(n int) Option {
+	return func(c *Client) {
+		c.maxRequestBytes = n
+	}
+}
+
+// MaxResponseBytes sets the largest response that is accepted from the server.
+func MaxResponseBytes(n int) Option {
+	return func(c *Client) {
+		c.maxResponseBytes = n
+	}
+}
+
+// Retries sets the number of times a request that failed with a transient error is retried, zero
+// disables retries.
+func Retries(n int) Option {
+	return func(c *Client) {
+		c.retries = n
+	}
+}
+
+// Backoff sets the delay before the first retry, which doubles on each retry up to max.  Each delay
+// is jittered by up to half, so that clients retrying together spread out.
+func Backoff(initial, max time.Duration) Option {
+	return func(c *Client) {
+		c.initialBackoff = initial
+		c.maxBackoff = max
+	}
+}
+
+func newClient(opts ...Option) *Client {
+	c := &Client{
+		maxRequestBytes:  DefaultMaxRequestBytes,
+		maxResponseBytes: DefaultMaxResponseBytes,
+		retries:          DefaultRetries,
+		initialBackoff:   defaultInitialBackoff,
+		maxBackoff:       defaultMaxBackoff,
+		sleep:            sleep,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Dial returns a Client connected to the server at target, eg "localhost:10000".  Close must be called
+// to release the connection.
+func Dial(ctx context.Context, target string, opts ...Option) (*Client, error) {
+	c := newClient(opts...)
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(c.maxResponseBytes)),
+	}
+	conn, err := grpc.DialContext(ctx, target, append(dialOpts, c.dialOpts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to validator at %s: %w", target, err)
+	}
+	c.conn = conn
+	c.stub = validator.NewValidatorClient(conn)
+	return c, nil
+}
+
+// New returns a Client that calls the server over an existing connection, which Close does not close.
+// MaxResponseBytes has no effect, set grpc.MaxCallRecvMsgSize on the connection instead.
+func New(conn grpc.ClientConnInterface, opts ...Option) *Client {
+	c := newClient(opts...)
+	c.stub = validator.NewValidatorClient(conn)
+	return c
+}
+
+// Close closes the connection made by Dial.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Review reviews the assets of the request, in as many requests as needed to stay under the request
+// size limit, and returns all of their violations.  Every request has the other fields of request, eg
+// its options.  The requests are made one at a time, if one fails the violations found so far are
+// returned with the error.
+func (c *Client) Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
+	response := &validator.ReviewResponse{}
+	template := proto.Clone(request).(*validator.ReviewRequest)
+	template.Assets = nil
+	for _, chunk := range c.chunks(template, request.GetAssets()) {
+		violations, err := c.review(ctx, template, chunk)
+		response.Violations = append(response.Violations, violations...)
+		if err != nil {
+			return response, err
+		}
+	}
+	return response, nil
+}
+
+// review reviews a chunk of assets, splitting it in two if the server rejects it as too large.
+func (c *Client) review(ctx context.Context, template *validator.ReviewRequest, assets []*validator.Asset) ([]*validator.Violation, error) {
+	request := proto.Clone(template).(*validator.ReviewRequest)
+	request.Assets = assets
+	var response *validator.ReviewResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		response, err = c.stub.Review(ctx, request)
+		return err
+	})
+	if _, tooLarge := msgsize.FromError(err); tooLarge && len(assets) > 1 {
+		half := len(assets) / 2
+		violations, err := c.review(ctx, template, assets[:half])
+		if err != nil {
+			return violations, err
+		}
+		rest, err := c.review(ctx, template, assets[half:])
+		return append(violations, rest...), err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response.GetViolations(), nil
+}
+
+// AddData adds the assets, in as many requests as needed to stay under the request size limit.
+func (c *Client) AddData(ctx context.Context, assets []*validator.Asset) error {
+	for _, chunk := range c.chunks(&validator.AddDataRequest{}, assets) {
+		if err := c.addData(ctx, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addData adds a chunk of assets, splitting it in two if the server rejects it as too large.
+func (c *Client) addData(ctx context.Context, assets []*validator.Asset) error {
+	err := c.call(ctx, func(ctx context.Context) error {
+		_, err := c.stub.AddData(ctx, &validator.AddDataRequest{Assets: assets})
+		return err
+	})
+	if _, tooLarge := msgsize.FromError(err); tooLarge && len(assets) > 1 {
+		half := len(assets) / 2
+		if err := c.addData(ctx, assets[:half]); err != nil {
+			return err
+		}
+		return c.addData(ctx, assets[half:])
+	}
+	return err
+}
+
+// Reset removes the data added with AddData.
+func (c *Client) Reset(ctx context.Context) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.stub.Reset(ctx, &validator.ResetRequest{})
+		return err
+	})
+}
+
+// ListConstraints returns the constraints and templates loaded by the server.
+func (c *Client) ListConstraints(ctx context.Context) (*validator.ListConstraintsResponse, error) {
+	var response *validator.ListConstraintsResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		response, err = c.stub.ListConstraints(ctx, &validator.ListConstraintsRequest{})
+		return err
+	})
+	return response, err
+}
+
+// GetLastLoadReport returns the report of loading the policies of the server.
+func (c *Client) GetLastLoadReport(ctx context.Context) (*validator.LoadReport, error) {
+	var response *validator.LoadReport
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		response, err = c.stub.GetLastLoadReport(ctx, &validator.GetLastLoadReportRequest{})
+		return err
+	})
+	return response, err
+}
+
+// GetPolicyStatus returns the fingerprint and counts of the policies the server is serving.
+func (c *Client) GetPolicyStatus(ctx context.Context) (*validator.PolicyStatus, error) {
+	var response *validator.PolicyStatus
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		response, err = c.stub.GetPolicyStatus(ctx, &validator.GetPolicyStatusRequest{})
+		return err
+	})
+	return response, err
+}
+
+// chunks splits the assets so that each chunk, added to the request template, stays under the request
+// size limit.  Assets that are over the limit on their own are sent alone.
+func (c *Client) chunks(template proto.Message, assets []*validator.Asset) [][]*validator.Asset {
+	base := proto.Size(template)
+	var chunks [][]*validator.Asset
+	var chunk []*validator.Asset
+	size := base
+	for _, asset := range assets {
+		// Assets are field 1 of both ReviewRequest and AddDataRequest.
+		assetSize := protowire.SizeTag(1) + protowire.SizeBytes(proto.Size(asset))
+		if len(chunk) != 0 && c.maxRequestBytes > 0 && size+assetSize > c.maxRequestBytes {
+			chunks = append(chunks, chunk)
+			chunk, size = nil, base
+		}
+		chunk = append(chunk, asset)
+		size += assetSize
+	}
+	if len(chunk) != 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// retryable returns true for errors that may succeed if the request is retried.
+func retryable(err error) bool {
+	if _, tooLarge := msgsize.FromError(err); tooLarge {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+// call calls fn, retrying it with backoff while it fails with a retryable error.  The error of the last
+// attempt is returned, also if ctx is done while waiting to retry.
+func (c *Client) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	backoff := c.initialBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= c.retries || !retryable(err) {
+			return err
+		}
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		if c.sleep(ctx, delay) != nil {
+			return err
+		}
+		if backoff *= 2; backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/msgsize"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeServer returns a violation for each reviewed asset, and fails the first failures requests with
+// Unavailable.
+type fakeServer struct {
+	validator.UnimplementedValidatorServer
+
+	mtx      sync.Mutex
+	failures int
+	requests []*validator.ReviewRequest
+	added    []string
+}
+
+func (s *fakeServer) fail() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return status.Error(codes.Unavailable, "try again")
+	}
+	return nil
+}
+
+func (s *fakeServer) Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	s.mtx.Lock()
+	s.requests = append(s.requests, request)
+	s.mtx.Unlock()
+	response := &validator.ReviewResponse{}
+	for _, asset := range request.Assets {
+		response.Violations = append(response.Violations, &validator.Violation{Resource: asset.Name})
+	}
+	return response, nil
+}
+
+func (s *fakeServer) AddData(ctx context.Context, request *validator.AddDataRequest) (*validator.AddDataResponse, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, asset := range request.Assets {
+		s.added = append(s.added, asset.Name)
+	}
+	return &validator.AddDataResponse{}, nil
+}
+
+// newTestClient returns a client of a fake server behind an interceptor that limits requests to limit
+// bytes.
+func newTestClient(t *testing.T, server *fakeServer, limit int, opts ...Option) *Client {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	limits := msgsize.Limits{Default: limit}
+	s := grpc.NewServer(grpc.UnaryInterceptor(limits.UnaryServerInterceptor()))
+	validator.RegisterValidatorServer(s, server)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	opts = append([]Option{
+		Backoff(0, 0),
+		DialOptions(grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		})),
+	}, opts...)
+	c, err := Dial(context.Background(), "bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func testAssets(n int) []*validator.Asset {
+	var assets []*validator.Asset
+	for i := 0; i < n; i++ {
+		assets = append(assets, &validator.Asset{
+			Name:         fmt.Sprintf("//storage.googleapis.com/bucket-%d", i),
+			AssetType:    "storage.googleapis.com/Bucket",
+			AncestryPath: "organizations/1/projects/" + strings.Repeat("x", 100),
+		})
+	}
+	return assets
+}
+
+func resources(violations []*validator.Violation) []string {
+	var ret []string
+	for _, v := range violations {
+		ret = append(ret, v.Resource)
+	}
+	return ret
+}
+
+func TestReviewChunks(t *testing.T) {
+	testCases := []struct {
+		name         string
+		limit        int
+		opts         []Option
+		wantRequests int
+	}{
+		{
+			name:         "under limit",
+			limit:        1024 * 1024,
+			wantRequests: 1,
+		},
+		{
+			name:         "split by client",
+			limit:        1024 * 1024,
+			opts:         []Option{MaxRequestBytes(1000)},
+			wantRequests: 4,
+		},
+		{
+			name:         "split when server rejects",
+			limit:        1000,
+			opts:         []Option{MaxRequestBytes(1024 * 1024)},
+			wantRequests: 4,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &fakeServer{}
+			c := newTestClient(t, server, tc.limit, tc.opts...)
+			assets := testAssets(20)
+			response, err := c.Review(context.Background(), &validator.ReviewRequest{
+				Assets:  assets,
+				Options: &validator.ReviewOptions{MinSeverity: "high"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(resources(testViolations(assets)), resources(response.Violations)); diff != "" {
+				t.Errorf("violations diff (-want +got):\n%s", diff)
+			}
+			if len(server.requests) != tc.wantRequests {
+				t.Errorf("got %d requests, want %d", len(server.requests), tc.wantRequests)
+			}
+			for _, request := range server.requests {
+				if request.GetOptions().GetMinSeverity() != "high" {
+					t.Errorf("request options %v were not kept", request.GetOptions())
+				}
+			}
+		})
+	}
+}
+
+func testViolations(assets []*validator.Asset) []*validator.Violation {
+	var ret []*validator.Violation
+	for _, asset := range assets {
+		ret = append(ret, &validator.Violation{Resource: asset.Name})
+	}
+	return ret
+}
+
+func TestRetries(t *testing.T) {
+	testCases := []struct {
+		name     string
+		failures int
+		retries  int
+		wantCode codes.Code
+	}{
+		{name: "no failures", retries: 2, wantCode: codes.OK},
+		{name: "recovers", failures: 2, retries: 2, wantCode: codes.OK},
+		{name: "gives up", failures: 3, retries: 2, wantCode: codes.Unavailable},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &fakeServer{failures: tc.failures}
+			c := newTestClient(t, server, 1024*1024, Retries(tc.retries))
+			err := c.AddData(context.Background(), testAssets(2))
+			if code := status.Code(err); code != tc.wantCode {
+				t.Fatalf("got code %v, want %v: %v", code, tc.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestRetryBackoffStopsWithContext(t *testing.T) {
+	server := &fakeServer{failures: 10}
+	c := newTestClient(t, server, 1024*1024, Backoff(time.Hour, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := c.Reset(ctx); err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
+
+func TestReviewExport(t *testing.T) {
+	server := &fakeServer{}
+	c := newTestClient(t, server, 1024*1024)
+	export := `{"name":"//storage.googleapis.com/a","asset_type":"storage.googleapis.com/Bucket","ancestors":["projects/1"],"osInventory":{}}
+not json
+{"name":"//storage.googleapis.com/b","asset_type":"storage.googleapis.com/Bucket","ancestors":["projects/1"]}
+{"name":"//storage.googleapis.com/c","asset_type":"storage.googleapis.com/Bucket","ancestors":["projects/1"]}
+`
+	var got []string
+	report, err := c.ReviewExport(context.Background(), "export.json", strings.NewReader(export), ExportOptions{
+		MaxErrorRatio: 0.5,
+		BatchSize:     2,
+		Request:       &validator.ReviewRequest{ApplySampling: true},
+	}, func(violations []*validator.Violation) error {
+		got = append(got, resources(violations)...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"//storage.googleapis.com/a", "//storage.googleapis.com/b", "//storage.googleapis.com/c"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("violations diff (-want +got):\n%s", diff)
+	}
+	if len(report.Errors) != 1 {
+		t.Errorf("got %d malformed lines, want 1", len(report.Errors))
+	}
+	if len(server.requests) != 2 {
+		t.Errorf("got %d requests, want 2", len(server.requests))
+	}
+	for _, request := range server.requests {
+		if !request.ApplySampling {
+			t.Errorf("request template was not applied to %v", request)
+		}
+	}
+}
+
+func TestAddExport(t *testing.T) {
+	server := &fakeServer{}
+	c := newTestClient(t, server, 1024*1024)
+	export := `{"name":"//cloudresourcemanager.googleapis.com/projects/1","asset_type":"cloudresourcemanager.googleapis.com/Project","iam_policy":{"bindings":[{"role":"roles/owner","members":["user:a@example.com"]}]}}
+`
+	if _, err := c.AddExport(context.Background(), "export.json", strings.NewReader(export), ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"//cloudresourcemanager.googleapis.com/projects/1"}, server.added); diff != "" {
+		t.Errorf("added diff (-want +got):\n%s", diff)
+	}
+}
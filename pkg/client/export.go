@@ -0,0 +1,117 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/asset"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// exportBatchSize is the number of assets of a CAI export that are read before they are sent.  The
+// batch is split further to stay under the request size limit.
+const exportBatchSize = 1000
+
+// ExportOptions configure the reading of a CAI export, newline delimited JSON as written by
+// gcloud asset export, by ReviewExport and AddExport.
+type ExportOptions struct {
+	// MaxErrorRatio is the largest fraction of malformed lines that are skipped before the read is
+	// aborted, see asset.JSONLReader.  Zero aborts on the first malformed line.
+	MaxErrorRatio float64
+	// BatchSize is the number of assets that are read before they are sent, or exportBatchSize if
+	// zero.  The batch is split further to stay under the request size limit.
+	BatchSize int
+	// Request has the fields, other than its assets, of each review request made by ReviewExport, eg
+	// its options.  It may be nil.
+	Request *validator.ReviewRequest
+}
+
+// readExport calls fn with the assets of the export in batches.  Path is only used for error reporting.
+func readExport(path string, reader io.Reader, opts ExportOptions, fn func(assets []*validator.Asset) error) (*asset.ReadReport, error) {
+	size := opts.BatchSize
+	if size <= 0 {
+		size = exportBatchSize
+	}
+	var batch []*validator.Asset
+	jsonl := &asset.JSONLReader{MaxErrorRatio: opts.MaxErrorRatio}
+	report, err := jsonl.Read(path, reader, func(input map[string]interface{}) error {
+		a, err := toAsset(input)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, a)
+		if len(batch) < size {
+			return nil
+		}
+		full := batch
+		batch = nil
+		return fn(full)
+	})
+	if err != nil {
+		return report, err
+	}
+	if len(batch) != 0 {
+		return report, fn(batch)
+	}
+	return report, nil
+}
+
+// toAsset converts an asset of a CAI export to its proto.  Exports include asset fields that the
+// validator does not review, such as osInventory, which are dropped.
+func toAsset(input map[string]interface{}) (*validator.Asset, error) {
+	content, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	a := &validator.Asset{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(content, a); err != nil {
+		return nil, fmt.Errorf("failed to convert asset %v: %w", input["name"], err)
+	}
+	return a, nil
+}
+
+// ReviewExport reviews the assets of the CAI export read from reader in batches, calling fn with the
+// violations of each batch.  Path is only used for error reporting.  Errors returned by fn abort the
+// review.
+func (c *Client) ReviewExport(ctx context.Context, path string, reader io.Reader, opts ExportOptions, fn func(violations []*validator.Violation) error) (*asset.ReadReport, error) {
+	template := opts.Request
+	if template == nil {
+		template = &validator.ReviewRequest{}
+	}
+	return readExport(path, reader, opts, func(assets []*validator.Asset) error {
+		request := proto.Clone(template).(*validator.ReviewRequest)
+		request.Assets = assets
+		response, err := c.Review(ctx, request)
+		if err != nil {
+			return err
+		}
+		return fn(response.GetViolations())
+	})
+}
+
+// AddExport adds the assets of the CAI export read from reader in batches, eg the organization, folder
+// and project IAM policies for a server running with -ancestorIAM.  Path is only used for error
+// reporting.
+func (c *Client) AddExport(ctx context.Context, path string, reader io.Reader, opts ExportOptions) (*asset.ReadReport, error) {
+	return readExport(path, reader, opts, func(assets []*validator.Asset) error {
+		return c.AddData(ctx, assets)
+	})
+}
@@ -0,0 +1,416 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance checks that a running validator behaves as specified.
+//
+// A Suite holds the policies the validator under test must be started with, and cases grouped by
+// capability: requests to send to the validator and the responses expected.  Run sends each request
+// and reports which cases passed, so that upgrades and custom builds can be checked to behave like
+// the release they replace.  The published suite is returned by DefaultSuite.
+package conformance
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+//go:embed suite
+var suiteFS embed.FS
+
+const (
+	// policiesDir holds the templates and constraints of a suite.
+	policiesDir = "policies"
+	// libraryDir holds the rego library of a suite.
+	libraryDir = "library"
+	// casesDir holds a JSON file of cases per capability.
+	casesDir = "cases"
+)
+
+// Suite is a set of conformance cases and the policies the validator must be loaded with.
+type Suite struct {
+	// Policies holds the templates and constraints to start the validator with.
+	Policies fs.FS
+	// Library holds the rego library to start the validator with.
+	Library fs.FS
+	// Capabilities are the cases grouped by the capability they check, sorted by name.
+	Capabilities []*Capability
+}
+
+// Capability is a group of cases that check one capability of the validator.
+type Capability struct {
+	Name        string  `json:"capability"`
+	Description string  `json:"description"`
+	Cases       []*Case `json:"cases"`
+}
+
+// Case is a request to the validator and the response expected.
+type Case struct {
+	Name string `json:"name"`
+	// Review is a ReviewRequest in protojson form.  The request is sent with Review.
+	Review json.RawMessage `json:"review,omitempty"`
+	// ListConstraints sends a ListConstraintsRequest.
+	ListConstraints bool `json:"listConstraints,omitempty"`
+
+	// WantCode is the gRPC status code, eg InvalidArgument, the request must fail with.  The request
+	// must succeed if it is empty.
+	WantCode string `json:"wantCode,omitempty"`
+	// WantViolations are the violations of a successful review, in any order.
+	WantViolations []*ExpectedViolation `json:"wantViolations,omitempty"`
+	// WantConstraints are the constraints listed, in any order.
+	WantConstraints []*ExpectedConstraint `json:"wantConstraints,omitempty"`
+	// WantTemplates are the kinds of the templates listed, in any order.
+	WantTemplates []string `json:"wantTemplates,omitempty"`
+
+	review   *validator.ReviewRequest
+	wantCode codes.Code
+}
+
+// ExpectedViolation describes a violation, fields that are empty are not checked.
+type ExpectedViolation struct {
+	Constraint string `json:"constraint"`
+	Resource   string `json:"resource"`
+	Message    string `json:"message,omitempty"`
+	Severity   string `json:"severity,omitempty"`
+}
+
+func (e *ExpectedViolation) key() string {
+	return e.Constraint + " " + e.Resource
+}
+
+// matches returns an error if the violation is not as expected.
+func (e *ExpectedViolation) matches(v *validator.Violation) error {
+	if e.Message != "" && v.GetMessage() != e.Message {
+		return fmt.Errorf("violation of %s by %s has message %q, want %q", e.Constraint, e.Resource, v.GetMessage(), e.Message)
+	}
+	if e.Severity != "" && v.GetSeverity() != e.Severity {
+		return fmt.Errorf("violation of %s by %s has severity %q, want %q", e.Constraint, e.Resource, v.GetSeverity(), e.Severity)
+	}
+	return nil
+}
+
+// ExpectedConstraint describes a listed constraint, an empty severity is not checked.
+type ExpectedConstraint struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Severity string `json:"severity,omitempty"`
+}
+
+// DefaultSuite returns the published conformance suite.  Its policies are in the suite/policies and
+// suite/library directories of this package.
+func DefaultSuite() (*Suite, error) {
+	fsys, err := fs.Sub(suiteFS, "suite")
+	if err != nil {
+		return nil, err
+	}
+	return LoadSuite(fsys)
+}
+
+// LoadSuite loads a suite from a directory with the layout of this package's suite directory: the
+// policies in policies/, the rego library in library/ and a JSON file of cases per capability in
+// cases/.
+func LoadSuite(fsys fs.FS) (*Suite, error) {
+	policies, err := fs.Sub(fsys, policiesDir)
+	if err != nil {
+		return nil, err
+	}
+	library, err := fs.Sub(fsys, libraryDir)
+	if err != nil {
+		return nil, err
+	}
+	suite := &Suite{Policies: policies, Library: library}
+
+	paths, err := fs.Glob(fsys, path.Join(casesDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no cases in %s", casesDir)
+	}
+	for _, p := range paths {
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return nil, err
+		}
+		capability := &Capability{}
+		if err := json.Unmarshal(data, capability); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		if capability.Name == "" {
+			return nil, fmt.Errorf("%s: capability is not set", p)
+		}
+		for _, c := range capability.Cases {
+			if err := c.parse(); err != nil {
+				return nil, fmt.Errorf("%s: case %q: %w", p, c.Name, err)
+			}
+		}
+		suite.Capabilities = append(suite.Capabilities, capability)
+	}
+	sort.Slice(suite.Capabilities, func(i, j int) bool {
+		return suite.Capabilities[i].Name < suite.Capabilities[j].Name
+	})
+	return suite, nil
+}
+
+// parse checks the case and parses its request.
+func (c *Case) parse() error {
+	if (c.Review != nil) == c.ListConstraints {
+		return fmt.Errorf("exactly one of review and listConstraints must be set")
+	}
+	if c.WantCode != "" {
+		if err := c.wantCode.UnmarshalJSON([]byte(`"` + strings.ToUpper(toSnake(c.WantCode)) + `"`)); err != nil {
+			return fmt.Errorf("invalid wantCode %q", c.WantCode)
+		}
+	}
+	if c.Review != nil {
+		c.review = &validator.ReviewRequest{}
+		if err := protojson.Unmarshal(c.Review, c.review); err != nil {
+			return fmt.Errorf("invalid review request: %w", err)
+		}
+	}
+	return nil
+}
+
+// toSnake converts a status code name, eg InvalidArgument, to the form parsed by codes.Code.
+func toSnake(name string) string {
+	var b strings.Builder
+	for idx, r := range name {
+		if idx > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// WritePolicies copies the suite's policies to dir/policies and its library to dir/library, to start
+// the validator under test with.
+func (s *Suite) WritePolicies(dir string) error {
+	for name, fsys := range map[string]fs.FS{policiesDir: s.Policies, libraryDir: s.Library} {
+		err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(dir, name, filepath.FromSlash(p))
+			if d.IsDir() {
+				return os.MkdirAll(target, 0o755)
+			}
+			data, err := fs.ReadFile(fsys, p)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, data, 0o644)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Result is the outcome of a case.
+type Result struct {
+	Capability string
+	Case       string
+	// Err is why the case failed, nil if it passed.
+	Err error
+}
+
+// String implements fmt.Stringer.
+func (r *Result) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("FAIL %s: %s: %v", r.Capability, r.Case, r.Err)
+	}
+	return fmt.Sprintf("PASS %s: %s", r.Capability, r.Case)
+}
+
+// Report holds the results of running a suite.
+type Report struct {
+	Results []*Result
+}
+
+// Failed returns true if any case failed.
+func (r *Report) Failed() bool {
+	for _, result := range r.Results {
+		if result.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// CapabilitySummary counts the cases of a capability that passed and failed.
+type CapabilitySummary struct {
+	Capability string
+	Passed     int
+	Failed     int
+}
+
+// String implements fmt.Stringer.
+func (s *CapabilitySummary) String() string {
+	outcome := "PASS"
+	if s.Failed != 0 {
+		outcome = "FAIL"
+	}
+	return fmt.Sprintf("%s %s: %d passed, %d failed", outcome, s.Capability, s.Passed, s.Failed)
+}
+
+// Summary returns the pass and fail counts of each capability, in the order they were run.
+func (r *Report) Summary() []*CapabilitySummary {
+	var summaries []*CapabilitySummary
+	byCapability := map[string]*CapabilitySummary{}
+	for _, result := range r.Results {
+		summary, found := byCapability[result.Capability]
+		if !found {
+			summary = &CapabilitySummary{Capability: result.Capability}
+			byCapability[result.Capability] = summary
+			summaries = append(summaries, summary)
+		}
+		if result.Err != nil {
+			summary.Failed++
+		} else {
+			summary.Passed++
+		}
+	}
+	return summaries
+}
+
+// Run runs the cases of the named capabilities, or of every capability if none are named, against the
+// validator.  Cases are run one at a time in order.
+func Run(ctx context.Context, client validator.ValidatorClient, suite *Suite, capabilities ...string) *Report {
+	selected := map[string]bool{}
+	for _, name := range capabilities {
+		selected[name] = true
+	}
+	report := &Report{}
+	for _, capability := range suite.Capabilities {
+		if len(selected) != 0 && !selected[capability.Name] {
+			continue
+		}
+		for _, c := range capability.Cases {
+			report.Results = append(report.Results, &Result{
+				Capability: capability.Name,
+				Case:       c.Name,
+				Err:        c.run(ctx, client),
+			})
+		}
+	}
+	return report
+}
+
+// run sends the case's request and returns an error if the response is not as expected.
+func (c *Case) run(ctx context.Context, client validator.ValidatorClient) error {
+	var err error
+	var check func() error
+	if c.review != nil {
+		var response *validator.ReviewResponse
+		response, err = client.Review(ctx, c.review)
+		check = func() error { return c.checkViolations(response.GetViolations()) }
+	} else {
+		var response *validator.ListConstraintsResponse
+		response, err = client.ListConstraints(ctx, &validator.ListConstraintsRequest{})
+		check = func() error { return c.checkInventory(response) }
+	}
+
+	if c.WantCode != "" {
+		if err == nil {
+			return fmt.Errorf("request succeeded, want %s error", c.WantCode)
+		}
+		if got := status.Code(err); got != c.wantCode {
+			return fmt.Errorf("got %s error %v, want %s error", got, err, c.wantCode)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	return check()
+}
+
+func (c *Case) checkViolations(violations []*validator.Violation) error {
+	var got []string
+	byKey := map[string][]*validator.Violation{}
+	for _, v := range violations {
+		key := v.GetConstraint() + " " + v.GetResource()
+		got = append(got, key)
+		byKey[key] = append(byKey[key], v)
+	}
+	var want []string
+	for _, e := range c.WantViolations {
+		want = append(want, e.key())
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		return fmt.Errorf("got violations %q, want %q", got, want)
+	}
+	for _, e := range c.WantViolations {
+		matched := byKey[e.key()]
+		if err := e.matches(matched[0]); err != nil {
+			return err
+		}
+		byKey[e.key()] = matched[1:]
+	}
+	return nil
+}
+
+func (c *Case) checkInventory(response *validator.ListConstraintsResponse) error {
+	var got, want []string
+	for _, constraint := range response.GetConstraints() {
+		got = append(got, constraint.GetKind()+"."+constraint.GetName())
+	}
+	bySeverity := map[string]string{}
+	for _, constraint := range response.GetConstraints() {
+		bySeverity[constraint.GetKind()+"."+constraint.GetName()] = constraint.GetSeverity()
+	}
+	for _, e := range c.WantConstraints {
+		want = append(want, e.Kind+"."+e.Name)
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		return fmt.Errorf("got constraints %q, want %q", got, want)
+	}
+	for _, e := range c.WantConstraints {
+		if severity := bySeverity[e.Kind+"."+e.Name]; e.Severity != "" && severity != e.Severity {
+			return fmt.Errorf("constraint %s.%s has severity %q, want %q", e.Kind, e.Name, severity, e.Severity)
+		}
+	}
+
+	if c.WantTemplates != nil {
+		var kinds []string
+		for _, templ := range response.GetTemplates() {
+			kinds = append(kinds, templ.GetKind())
+		}
+		wantKinds := append([]string(nil), c.WantTemplates...)
+		sort.Strings(kinds)
+		sort.Strings(wantKinds)
+		if strings.Join(kinds, "\n") != strings.Join(wantKinds, "\n") {
+			return fmt.Errorf("got templates %q, want %q", kinds, wantKinds)
+		}
+	}
+	return nil
+}
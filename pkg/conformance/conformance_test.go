@@ -0,0 +1,209 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// server serves the RPCs used by the suite like cmd/server.
+type server struct {
+	validator.UnimplementedValidatorServer
+	cv        *gcv.Validator
+	validator *gcv.ParallelValidator
+}
+
+func (s *server) Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
+	response, err := s.validator.Review(ctx, request)
+	if err != nil {
+		return nil, gcv.ReviewStatus(err).Err()
+	}
+	return response, nil
+}
+
+func (s *server) ListConstraints(ctx context.Context, request *validator.ListConstraintsRequest) (*validator.ListConstraintsResponse, error) {
+	constraints, err := s.cv.ListConstraints()
+	if err != nil {
+		return nil, err
+	}
+	templates, err := s.cv.ListTemplates()
+	if err != nil {
+		return nil, err
+	}
+	response := &validator.ListConstraintsResponse{}
+	for _, c := range constraints {
+		pb, err := c.ToProto()
+		if err != nil {
+			return nil, err
+		}
+		response.Constraints = append(response.Constraints, pb)
+	}
+	for _, t := range templates {
+		pb, err := t.ToProto()
+		if err != nil {
+			return nil, err
+		}
+		response.Templates = append(response.Templates, pb)
+	}
+	return response, nil
+}
+
+// newClient serves a validator loaded with the suite's policies.
+func newClient(t *testing.T, suite *Suite) validator.ValidatorClient {
+	t.Helper()
+	cv, err := gcv.NewValidatorFromFS(suite.Policies, suite.Library)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	stopChannel := make(chan struct{})
+	t.Cleanup(func() { close(stopChannel) })
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	validator.RegisterValidatorServer(srv, &server{cv: cv, validator: gcv.NewParallelValidator(stopChannel, cv)})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return validator.NewValidatorClient(conn)
+}
+
+func TestDefaultSuite(t *testing.T) {
+	suite, err := DefaultSuite()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	report := Run(context.Background(), newClient(t, suite), suite)
+	for _, result := range report.Results {
+		if result.Err != nil {
+			t.Error(result)
+		}
+	}
+	if len(report.Summary()) != len(suite.Capabilities) {
+		t.Errorf("got summary %v, want one entry per capability", report.Summary())
+	}
+
+	// Selecting a capability only runs its cases.
+	report = Run(context.Background(), newClient(t, suite), suite, "inventory")
+	if len(report.Results) != 1 || report.Results[0].Capability != "inventory" {
+		t.Errorf("got results %v, want the inventory case", report.Results)
+	}
+}
+
+func TestRunReportsFailures(t *testing.T) {
+	suite, err := DefaultSuite()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	fsys := fstest.MapFS{
+		"cases/review.json": {Data: []byte(`{
+  "capability": "review",
+  "cases": [
+    {
+      "name": "missing violation",
+      "review": {"assets": [{
+        "name": "//storage.googleapis.com/conformance-logging",
+        "assetType": "storage.googleapis.com/Bucket",
+        "ancestryPath": "organizations/1/projects/3",
+        "resource": {"version": "v1", "data": {"logging": {"logBucket": "logs"}}}
+      }]},
+      "wantViolations": [{"constraint": "ConformanceStorageLoggingV1.require-storage-logging", "resource": "//storage.googleapis.com/conformance-logging"}]
+    },
+    {
+      "name": "unexpected success",
+      "listConstraints": true,
+      "wantCode": "NotFound"
+    }
+  ]
+}`)},
+		"policies/.keep": {},
+		"library/.keep":  {},
+	}
+	failing, err := LoadSuite(fsys)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	report := Run(context.Background(), newClient(t, suite), failing)
+	if !report.Failed() {
+		t.Fatal("report did not fail")
+	}
+	for _, result := range report.Results {
+		if result.Err == nil {
+			t.Errorf("case %s passed, want failure", result.Case)
+		}
+	}
+	if got := report.Summary()[0].String(); got != "FAIL review: 0 passed, 2 failed" {
+		t.Errorf("got summary %q", got)
+	}
+}
+
+func TestLoadSuiteErrors(t *testing.T) {
+	testCases := map[string]string{
+		"no cases":       "",
+		"both requests":  `{"capability": "c", "cases": [{"name": "n", "review": {}, "listConstraints": true}]}`,
+		"bad code":       `{"capability": "c", "cases": [{"name": "n", "listConstraints": true, "wantCode": "Nope"}]}`,
+		"bad request":    `{"capability": "c", "cases": [{"name": "n", "review": {"assets": 1}}]}`,
+		"no capability":  `{"cases": []}`,
+		"malformed json": `{`,
+	}
+	for name, content := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fsys := fstest.MapFS{"policies/.keep": {}, "library/.keep": {}}
+			if content != "" {
+				fsys["cases/c.json"] = &fstest.MapFile{Data: []byte(content)}
+			}
+			if _, err := LoadSuite(fsys); err == nil {
+				t.Errorf("LoadSuite() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestWritePolicies(t *testing.T) {
+	suite, err := DefaultSuite()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	dir := t.TempDir()
+	if err := suite.WritePolicies(dir); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if _, err := gcv.NewValidator([]string{filepath.Join(dir, "policies")}, filepath.Join(dir, "library")); err != nil {
+		t.Errorf("validator from written policies: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "library", "lib.rego"))
+	if err != nil || !strings.Contains(string(data), "package validator.gcp.lib") {
+		t.Errorf("library not written: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package archive stores audit results in a compact, versioned archive format.
+//
+// An archive is a gzip stream containing a magic string followed by a size delimited ArchiveHeader
+// proto and then a size delimited Violation proto for each violation.  Violations are streamed in
+// both directions so that audits with millions of violations do not need to be held in memory.
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// SchemaVersion is the archive format version written by Writer.  It is incremented for changes
+	// that older readers can't handle, additive proto changes do not require a new version.
+	SchemaVersion = 1
+	// magic identifies archive files.
+	magic = "GCVARCHIVE"
+	// maxRecordSize is the largest record a Reader accepts.
+	maxRecordSize = 64 * 1024 * 1024
+)
+
+// ErrUnsupportedVersion is returned when reading an archive written with a newer schema version.
+var ErrUnsupportedVersion = errors.New("unsupported archive schema version")
+
+// Option configures a Writer.
+type Option func(*Writer)
+
+// PolicyFingerprint sets the policy fingerprint recorded in the archive header.
+func PolicyFingerprint(fingerprint string) Option {
+	return func(w *Writer) {
+		w.header.PolicyFingerprint = fingerprint
+	}
+}
+
+var _ export.ResultSink = &Writer{}
+
+// Writer writes violations to an archive.
+type Writer struct {
+	header *validator.ArchiveHeader
+	gz     *gzip.Writer
+	buf    *bufio.Writer
+	count  int
+}
+
+// NewWriter writes the archive header to w and returns a Writer for the violations.  Close must be
+// called to flush the archive.
+func NewWriter(w io.Writer, opts ...Option) (*Writer, error) {
+	aw := &Writer{
+		header: &validator.ArchiveHeader{
+			SchemaVersion: SchemaVersion,
+			CreateTime:    timestamppb.New(time.Now()),
+		},
+		gz: gzip.NewWriter(w),
+	}
+	for _, opt := range opts {
+		opt(aw)
+	}
+	aw.buf = bufio.NewWriter(aw.gz)
+	if _, err := aw.buf.WriteString(magic); err != nil {
+		return nil, fmt.Errorf("failed to write archive magic: %w", err)
+	}
+	if _, err := protodelim.MarshalTo(aw.buf, aw.header); err != nil {
+		return nil, fmt.Errorf("failed to write archive header: %w", err)
+	}
+	return aw, nil
+}
+
+// WriteResults writes the violations of each result to the archive.
+func (w *Writer) WriteResults(ctx context.Context, results []*gcv.Result) error {
+	for _, result := range results {
+		violations, err := result.ToViolations()
+		if err != nil {
+			return fmt.Errorf("failed to convert result for %s: %w", result.Name, err)
+		}
+		if err := w.WriteViolations(ctx, violations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteViolations writes the violations to the archive.
+func (w *Writer) WriteViolations(ctx context.Context, violations []*validator.Violation) error {
+	for _, v := range violations {
+		if _, err := protodelim.MarshalTo(w.buf, v); err != nil {
+			return fmt.Errorf("failed to write violation %d: %w", w.count, err)
+		}
+		w.count++
+	}
+	return nil
+}
+
+// Count returns the number of violations written.
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Close flushes the archive, it does not close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+// Reader reads violations from an archive.
+type Reader struct {
+	// Header is the archive header.
+	Header *validator.ArchiveHeader
+	gz     *gzip.Reader
+	buf    *bufio.Reader
+}
+
+// NewReader reads the archive header from r and returns a Reader for the violations.
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	ar := &Reader{
+		Header: &validator.ArchiveHeader{},
+		gz:     gz,
+		buf:    bufio.NewReader(gz),
+	}
+	prefix := make([]byte, len(magic))
+	if _, err := io.ReadFull(ar.buf, prefix); err != nil || string(prefix) != magic {
+		return nil, fmt.Errorf("not a config validator archive")
+	}
+	if err := ar.unmarshal(ar.Header); err != nil {
+		return nil, fmt.Errorf("failed to read archive header: %w", err)
+	}
+	if ar.Header.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("%w %d, newest supported version is %d", ErrUnsupportedVersion, ar.Header.SchemaVersion, SchemaVersion)
+	}
+	return ar, nil
+}
+
+// Next returns the next violation in the archive, or io.EOF once all violations have been read.
+func (r *Reader) Next() (*validator.Violation, error) {
+	v := &validator.Violation{}
+	if err := r.unmarshal(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// ForEach calls fn for each remaining violation in the archive, stopping at the first error.
+func (r *Reader) ForEach(fn func(v *validator.Violation) error) error {
+	for {
+		v, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+}
+
+// Close releases the resources held by the Reader, it does not close the underlying reader.
+func (r *Reader) Close() error {
+	return r.gz.Close()
+}
+
+func (r *Reader) unmarshal(m proto.Message) error {
+	return protodelim.UnmarshalOptions{MaxSize: maxRecordSize}.UnmarshalFrom(r.buf, m)
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var violations []*validator.Violation
+	for i := 0; i < 1000; i++ {
+		violations = append(violations, &validator.Violation{
+			Constraint: "GCPStorageLoggingConstraint.require-storage-logging",
+			Resource:   fmt.Sprintf("//storage.googleapis.com/bucket-%d", i),
+			Message:    "bucket does not have logging enabled",
+			Severity:   "high",
+		})
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, PolicyFingerprint("abc123"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteViolations(context.Background(), violations); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Count() != len(violations) {
+		t.Errorf("got count %d, want %d", w.Count(), len(violations))
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.Header.SchemaVersion != SchemaVersion || r.Header.PolicyFingerprint != "abc123" {
+		t.Errorf("unexpected header %v", r.Header)
+	}
+	var got []*validator.Violation
+	if err := r.ForEach(func(v *validator.Violation) error {
+		got = append(got, v)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(violations, got, protocmp.Transform()); diff != "" {
+		t.Errorf("violations diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewReaderErrors(t *testing.T) {
+	var newer bytes.Buffer
+	gz := gzip.NewWriter(&newer)
+	if _, err := gz.Write([]byte(magic)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := protodelim.MarshalTo(gz, &validator.ArchiveHeader{SchemaVersion: SchemaVersion + 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReader(&newer); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("got error %v, want %v", err, ErrUnsupportedVersion)
+	}
+
+	var other bytes.Buffer
+	gz = gzip.NewWriter(&other)
+	if _, err := gz.Write([]byte("{\"violations\": []}")); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReader(&other); err == nil {
+		t.Errorf("expected error reading non-archive input")
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bigquery streams config validator review results into a BigQuery table.
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
+	"github.com/golang/glog"
+	bq "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// defaultBatchSize is the number of rows sent per insertAll call.  BigQuery recommends a maximum
+	// of 500 rows per request for streaming inserts.
+	defaultBatchSize = 500
+	// timestampFormat is the canonical BigQuery format for TIMESTAMP values in streaming inserts.
+	timestampFormat = "2006-01-02 15:04:05.000000 UTC"
+	// ancestryPathKey is the violation metadata key holding the ancestry path of the resource.
+	ancestryPathKey = "ancestry_path"
+)
+
+// Schema is the BigQuery table schema used for violation rows.  Columns are only ever appended to this
+// schema so that existing tables can be updated in place by EnsureTable.
+var Schema = &bq.TableSchema{
+	Fields: []*bq.TableFieldSchema{
+		{Name: "timestamp", Type: "TIMESTAMP", Mode: "REQUIRED", Description: "Time the review result was written."},
+		{Name: "constraint", Type: "STRING", Mode: "REQUIRED", Description: "Constraint that was violated, as Kind.name."},
+		{Name: "constraint_kind", Type: "STRING", Mode: "NULLABLE", Description: "Kind of the constraint that was violated."},
+		{Name: "resource", Type: "STRING", Mode: "REQUIRED", Description: "Name of the resource that violated the constraint."},
+		{Name: "ancestry_path", Type: "STRING", Mode: "NULLABLE", Description: "Ancestry path of the resource."},
+		{Name: "severity", Type: "STRING", Mode: "NULLABLE", Description: "Severity of the constraint."},
+		{Name: "message", Type: "STRING", Mode: "NULLABLE", Description: "Human readable violation message."},
+		{Name: "metadata", Type: "STRING", Mode: "NULLABLE", Description: "Violation metadata encoded as JSON."},
+		{Name: "policy_fingerprint", Type: "STRING", Mode: "NULLABLE", Description: "Fingerprint of the policy set used for the review."},
+		{Name: "fingerprint", Type: "STRING", Mode: "NULLABLE", Description: "Stable fingerprint of the violation, for deduplication."},
+	},
+}
+
+// Row is a single violation row as stored in BigQuery.
+type Row struct {
+	Timestamp         time.Time
+	Constraint        string
+	ConstraintKind    string
+	Resource          string
+	AncestryPath      string
+	Severity          string
+	Message           string
+	Metadata          string
+	PolicyFingerprint string
+	Fingerprint       string
+}
+
+// toJSON returns the row in the form expected by the insertAll API.
+func (r *Row) toJSON() map[string]bq.JsonValue {
+	return map[string]bq.JsonValue{
+		"timestamp":          r.Timestamp.UTC().Format(timestampFormat),
+		"constraint":         r.Constraint,
+		"constraint_kind":    r.ConstraintKind,
+		"resource":           r.Resource,
+		"ancestry_path":      r.AncestryPath,
+		"severity":           r.Severity,
+		"message":            r.Message,
+		"metadata":           r.Metadata,
+		"policy_fingerprint": r.PolicyFingerprint,
+		"fingerprint":        r.Fingerprint,
+	}
+}
+
+// NewRow converts a violation to a Row.
+func NewRow(v *validator.Violation, timestamp time.Time, policyFingerprint string) (*Row, error) {
+	row := &Row{
+		Timestamp:         timestamp,
+		Constraint:        v.GetConstraint(),
+		ConstraintKind:    v.GetConstraintConfig().GetKind(),
+		Resource:          v.GetResource(),
+		Severity:          v.GetSeverity(),
+		Message:           v.GetMessage(),
+		PolicyFingerprint: policyFingerprint,
+		Fingerprint:       v.GetFingerprint(),
+	}
+	if v.GetMetadata() != nil {
+		metadata, err := protojson.Marshal(v.GetMetadata())
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata for %s on %s: %w", v.GetConstraint(), v.GetResource(), err)
+		}
+		row.Metadata = string(metadata)
+		if ancestryPath, ok := v.GetMetadata().GetStructValue().GetFields()[ancestryPathKey]; ok {
+			row.AncestryPath = ancestryPath.GetStringValue()
+		}
+	}
+	return row, nil
+}
+
+var _ export.ResultSink = &Writer{}
+
+// Writer streams violations into a BigQuery table.
+type Writer struct {
+	service           *bq.Service
+	projectID         string
+	datasetID         string
+	tableID           string
+	policyFingerprint string
+	batchSize         int
+	now               func() time.Time
+}
+
+// Option configures a Writer.
+type Option func(*Writer)
+
+// PolicyFingerprint sets the policy fingerprint that is recorded on every row.
+func PolicyFingerprint(fingerprint string) Option {
+	return func(w *Writer) {
+		w.policyFingerprint = fingerprint
+	}
+}
+
+// BatchSize sets the maximum number of rows sent in a single streaming insert.
+func BatchSize(size int) Option {
+	return func(w *Writer) {
+		if size > 0 {
+			w.batchSize = size
+		}
+	}
+}
+
+// NewWriter returns a Writer for the table projectID.datasetID.tableID.
+func NewWriter(service *bq.Service, projectID, datasetID, tableID string, opts ...Option) *Writer {
+	w := &Writer{
+		service:   service,
+		projectID: projectID,
+		datasetID: datasetID,
+		tableID:   tableID,
+		batchSize: defaultBatchSize,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// EnsureTable creates the destination table if it does not exist, or appends any columns from Schema
+// that are missing on an existing table.
+func (w *Writer) EnsureTable(ctx context.Context) error {
+	table, err := w.service.Tables.Get(w.projectID, w.datasetID, w.tableID).Context(ctx).Do()
+	if err != nil {
+		if apiErr, ok := err.(*googleapi.Error); !ok || apiErr.Code != http.StatusNotFound {
+			return fmt.Errorf("failed to get table %s: %w", w.tableName(), err)
+		}
+		glog.Infof("creating BigQuery table %s", w.tableName())
+		_, err = w.service.Tables.Insert(w.projectID, w.datasetID, &bq.Table{
+			TableReference: &bq.TableReference{
+				ProjectId: w.projectID,
+				DatasetId: w.datasetID,
+				TableId:   w.tableID,
+			},
+			Schema: Schema,
+		}).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("failed to create table %s: %w", w.tableName(), err)
+		}
+		return nil
+	}
+
+	schema, changed := mergeSchema(table.Schema)
+	if !changed {
+		return nil
+	}
+	glog.Infof("updating schema for BigQuery table %s", w.tableName())
+	if _, err := w.service.Tables.Patch(w.projectID, w.datasetID, w.tableID, &bq.Table{Schema: schema}).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to update schema for table %s: %w", w.tableName(), err)
+	}
+	return nil
+}
+
+// mergeSchema appends the columns of Schema that are missing from existing.  New columns are always
+// added as NULLABLE since BigQuery does not allow adding REQUIRED columns to an existing table.
+func mergeSchema(existing *bq.TableSchema) (*bq.TableSchema, bool) {
+	merged := &bq.TableSchema{}
+	found := map[string]bool{}
+	if existing != nil {
+		merged.Fields = append(merged.Fields, existing.Fields...)
+		for _, f := range existing.Fields {
+			found[f.Name] = true
+		}
+	}
+	changed := false
+	for _, f := range Schema.Fields {
+		if found[f.Name] {
+			continue
+		}
+		field := *f
+		field.Mode = "NULLABLE"
+		merged.Fields = append(merged.Fields, &field)
+		changed = true
+	}
+	return merged, changed
+}
+
+// WriteResults writes the violations of each result to the table.
+func (w *Writer) WriteResults(ctx context.Context, results []*gcv.Result) error {
+	var violations []*validator.Violation
+	for _, result := range results {
+		vs, err := result.ToViolations()
+		if err != nil {
+			return fmt.Errorf("failed to convert result for %s: %w", result.Name, err)
+		}
+		violations = append(violations, vs...)
+	}
+	return w.WriteViolations(ctx, violations)
+}
+
+// WriteViolations streams the violations to the table in batches.  All rows written by a single call
+// share the same timestamp.
+func (w *Writer) WriteViolations(ctx context.Context, violations []*validator.Violation) error {
+	timestamp := w.now()
+	rows := make([]*bq.TableDataInsertAllRequestRows, 0, len(violations))
+	for _, v := range violations {
+		row, err := NewRow(v, timestamp, w.policyFingerprint)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, &bq.TableDataInsertAllRequestRows{Json: row.toJSON()})
+	}
+
+	var errs multierror.Errors
+	for start := 0; start < len(rows); start += w.batchSize {
+		end := start + w.batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		errs.Add(w.insert(ctx, start, rows[start:end]))
+	}
+	return errs.ToError()
+}
+
+// Close is a no-op, rows are sent to BigQuery as they are written.
+func (w *Writer) Close() error {
+	return nil
+}
+
+// insert sends a single batch of rows, offset is the index of the first row in the batch and is used
+// for error reporting.
+func (w *Writer) insert(ctx context.Context, offset int, rows []*bq.TableDataInsertAllRequestRows) error {
+	resp, err := w.service.Tabledata.InsertAll(w.projectID, w.datasetID, w.tableID, &bq.TableDataInsertAllRequest{
+		Rows: rows,
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to insert rows %d-%d into %s: %w", offset, offset+len(rows)-1, w.tableName(), err)
+	}
+
+	var errs multierror.Errors
+	for _, insertErr := range resp.InsertErrors {
+		for _, e := range insertErr.Errors {
+			errs.Add(fmt.Errorf("row %d: %s: %s", offset+int(insertErr.Index), e.Reason, e.Message))
+		}
+	}
+	return errs.ToError()
+}
+
+func (w *Writer) tableName() string {
+	return fmt.Sprintf("%s.%s.%s", w.projectID, w.datasetID, w.tableID)
+}
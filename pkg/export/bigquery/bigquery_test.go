@@ -0,0 +1,181 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	bq "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var testTime = time.Date(2023, 8, 1, 12, 30, 0, 0, time.UTC)
+
+func testViolation(t *testing.T) *validator.Violation {
+	metadata, err := structpb.NewValue(map[string]interface{}{
+		"ancestry_path": "organizations/1/projects/2",
+		"details":       map[string]interface{}{"location": "us-west1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &validator.Violation{
+		Constraint:       "GCPStorageLocationConstraintV1.allow-some-storage-location",
+		ConstraintConfig: &validator.Constraint{Kind: "GCPStorageLocationConstraintV1"},
+		Resource:         "//storage.googleapis.com/my-bucket",
+		Message:          "bucket in disallowed location",
+		Metadata:         metadata,
+		Severity:         "high",
+		Fingerprint:      "3ef8779b",
+	}
+}
+
+func TestNewRow(t *testing.T) {
+	row, err := NewRow(testViolation(t), testTime, "abc123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(row.Metadata), &metadata); err != nil {
+		t.Fatalf("metadata is not valid json: %v", err)
+	}
+	row.Metadata = ""
+	want := &Row{
+		Timestamp:         testTime,
+		Constraint:        "GCPStorageLocationConstraintV1.allow-some-storage-location",
+		ConstraintKind:    "GCPStorageLocationConstraintV1",
+		Resource:          "//storage.googleapis.com/my-bucket",
+		AncestryPath:      "organizations/1/projects/2",
+		Severity:          "high",
+		Message:           "bucket in disallowed location",
+		PolicyFingerprint: "abc123",
+		Fingerprint:       "3ef8779b",
+	}
+	if diff := cmp.Diff(want, row); diff != "" {
+		t.Errorf("NewRow() diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestMergeSchema(t *testing.T) {
+	testCases := []struct {
+		name        string
+		existing    *bq.TableSchema
+		wantChanged bool
+		wantFields  []string
+	}{
+		{
+			name:        "up to date",
+			existing:    Schema,
+			wantChanged: false,
+		},
+		{
+			name: "missing columns",
+			existing: &bq.TableSchema{Fields: []*bq.TableFieldSchema{
+				{Name: "timestamp", Type: "TIMESTAMP", Mode: "REQUIRED"},
+				{Name: "custom", Type: "STRING"},
+			}},
+			wantChanged: true,
+			wantFields: []string{
+				"timestamp", "custom", "constraint", "constraint_kind", "resource", "ancestry_path",
+				"severity", "message", "metadata", "policy_fingerprint", "fingerprint",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, changed := mergeSchema(tc.existing)
+			if changed != tc.wantChanged {
+				t.Fatalf("got changed %v, want %v", changed, tc.wantChanged)
+			}
+			if !changed {
+				return
+			}
+			var got []string
+			for _, f := range merged.Fields {
+				got = append(got, f.Name)
+				if f.Name != "timestamp" && f.Mode == "REQUIRED" {
+					t.Errorf("appended field %s must not be REQUIRED", f.Name)
+				}
+			}
+			if diff := cmp.Diff(tc.wantFields, got); diff != "" {
+				t.Errorf("merged fields diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var created bool
+	var inserted []*bq.TableDataInsertAllRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/tables/violations"):
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"code": 404, "message": "not found"}}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/tables"):
+			created = true
+			_, _ = w.Write([]byte(`{}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/insertAll"):
+			req := &bq.TableDataInsertAllRequest{}
+			if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+				t.Errorf("failed to decode insertAll request: %v", err)
+			}
+			inserted = append(inserted, req)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	service, err := bq.NewService(ctx, option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := NewWriter(service, "my-project", "audit", "violations", PolicyFingerprint("abc123"), BatchSize(2))
+	writer.now = func() time.Time { return testTime }
+
+	if err := writer.EnsureTable(ctx); err != nil {
+		t.Fatalf("EnsureTable: %v", err)
+	}
+	if !created {
+		t.Errorf("expected table to be created")
+	}
+
+	violations := []*validator.Violation{testViolation(t), testViolation(t), testViolation(t)}
+	if err := writer.WriteViolations(ctx, violations); err != nil {
+		t.Fatalf("WriteViolations: %v", err)
+	}
+	if len(inserted) != 2 {
+		t.Fatalf("got %d insertAll calls, want 2", len(inserted))
+	}
+	if got := len(inserted[0].Rows) + len(inserted[1].Rows); got != len(violations) {
+		t.Errorf("got %d rows inserted, want %d", got, len(violations))
+	}
+	if got := inserted[0].Rows[0].Json["policy_fingerprint"]; got != "abc123" {
+		t.Errorf("got policy_fingerprint %v, want abc123", got)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package csv writes config validator violations as CSV with a header row of export.Columns, for
+// spreadsheets and ad hoc analysis.
+package csv
+
+import (
+	"context"
+	gocsv "encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+)
+
+var _ export.ResultSink = &Writer{}
+
+// Writer writes violations as CSV records, one row per violation.
+type Writer struct {
+	cw         *gocsv.Writer
+	header     bool
+	headerDone bool
+}
+
+// Option configures a Writer.
+type Option func(*Writer)
+
+// NoHeader omits the header row, eg when appending to an existing file.
+func NoHeader() Option {
+	return func(w *Writer) {
+		w.header = false
+	}
+}
+
+// NewWriter returns a Writer that writes CSV to w.  The header row is written before the first
+// violation, or on Close if there are none.
+func NewWriter(w io.Writer, opts ...Option) *Writer {
+	cw := &Writer{cw: gocsv.NewWriter(w), header: true}
+	for _, opt := range opts {
+		opt(cw)
+	}
+	return cw
+}
+
+// writeHeader writes the header row if it is due.
+func (w *Writer) writeHeader() error {
+	if !w.header || w.headerDone {
+		return nil
+	}
+	w.headerDone = true
+	if err := w.cw.Write(export.Columns); err != nil {
+		return fmt.Errorf("failed to write csv header: %w", err)
+	}
+	return nil
+}
+
+// WriteResults writes the violations of each result.
+func (w *Writer) WriteResults(ctx context.Context, results []*gcv.Result) error {
+	for _, result := range results {
+		violations, err := result.ToViolations()
+		if err != nil {
+			return fmt.Errorf("failed to convert result for %s: %w", result.Name, err)
+		}
+		if err := w.WriteViolations(ctx, violations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteViolations writes a row for each violation and flushes them to the underlying writer.
+func (w *Writer) WriteViolations(ctx context.Context, violations []*validator.Violation) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	for _, v := range violations {
+		record, err := export.NewRecord(v)
+		if err != nil {
+			return err
+		}
+		if err := w.cw.Write(record.Values()); err != nil {
+			return fmt.Errorf("failed to write csv row for %s on %s: %w", v.GetConstraint(), v.GetResource(), err)
+		}
+	}
+	w.cw.Flush()
+	return w.cw.Error()
+}
+
+// Close writes the header row if nothing was written and flushes the output.  It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.cw.Flush()
+	return w.cw.Error()
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csv
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestWriter(t *testing.T) {
+	metadata, err := structpb.NewValue(map[string]interface{}{
+		"ancestry_path": "organizations/123/projects/my-project",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations := []*validator.Violation{
+		{
+			Constraint:       "GCPStorageLoggingConstraint.require-storage-logging",
+			ConstraintConfig: &validator.Constraint{Kind: "GCPStorageLoggingConstraint"},
+			Resource:         "//storage.googleapis.com/my-bucket",
+			Message:          "bucket does not have logging, enabled",
+			Severity:         "high",
+			Metadata:         metadata,
+		},
+		{
+			Constraint: "GCPSQLBackupConstraint.require-backups",
+			Resource:   "//cloudsql.googleapis.com/my-instance",
+			Message:    "backups are disabled",
+		},
+	}
+	testCases := []struct {
+		name       string
+		opts       []Option
+		violations []*validator.Violation
+		want       string
+	}{
+		{
+			name:       "violations",
+			violations: violations,
+			want: "constraint,kind,resource,severity,message,ancestry,metadata\n" +
+				`GCPStorageLoggingConstraint.require-storage-logging,GCPStorageLoggingConstraint,//storage.googleapis.com/my-bucket,high,"bucket does not have logging, enabled",organizations/123/projects/my-project,"{""ancestry_path"":""organizations/123/projects/my-project""}"` + "\n" +
+				"GCPSQLBackupConstraint.require-backups,,//cloudsql.googleapis.com/my-instance,,backups are disabled,,null\n",
+		},
+		{
+			name: "no violations",
+			want: "constraint,kind,resource,severity,message,ancestry,metadata\n",
+		},
+		{
+			name:       "no header",
+			opts:       []Option{NoHeader()},
+			violations: violations[1:],
+			want:       "GCPSQLBackupConstraint.require-backups,,//cloudsql.googleapis.com/my-instance,,backups are disabled,,null\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, tc.opts...)
+			if err := w.WriteViolations(context.Background(), tc.violations); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("csv diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package export contains the destinations that review results can be written to, each in its own
+// sub package.
+package export
+
+import (
+	"context"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+)
+
+// ResultSink is a destination for review results.
+type ResultSink interface {
+	// WriteResults writes the violations of each result to the sink.
+	WriteResults(ctx context.Context, results []*gcv.Result) error
+	// WriteViolations writes violations that have already been converted from results to the sink.
+	WriteViolations(ctx context.Context, violations []*validator.Violation) error
+	// Close flushes any buffered output, no results may be written after Close.
+	Close() error
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsonl writes config validator violations as newline delimited JSON, one export.Record per
+// line, for log pipelines and tools such as jq.
+package jsonl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+)
+
+var _ export.ResultSink = &Writer{}
+
+// Writer writes violations as JSON objects, one per line.  The metadata is embedded as a JSON object
+// rather than a string.
+type Writer struct {
+	enc *json.Encoder
+}
+
+// NewWriter returns a Writer that writes newline delimited JSON to w.
+func NewWriter(w io.Writer) *Writer {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &Writer{enc: enc}
+}
+
+// WriteResults writes the violations of each result.
+func (w *Writer) WriteResults(ctx context.Context, results []*gcv.Result) error {
+	for _, result := range results {
+		violations, err := result.ToViolations()
+		if err != nil {
+			return fmt.Errorf("failed to convert result for %s: %w", result.Name, err)
+		}
+		if err := w.WriteViolations(ctx, violations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteViolations writes a line for each violation.
+func (w *Writer) WriteViolations(ctx context.Context, violations []*validator.Violation) error {
+	for _, v := range violations {
+		record, err := export.NewRecord(v)
+		if err != nil {
+			return err
+		}
+		if err := w.enc.Encode(record); err != nil {
+			return fmt.Errorf("failed to write json line for %s on %s: %w", v.GetConstraint(), v.GetResource(), err)
+		}
+	}
+	return nil
+}
+
+// Close is a no-op, lines are written as they come.  It does not close the underlying writer.
+func (w *Writer) Close() error {
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonl
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestWriter(t *testing.T) {
+	metadata, err := structpb.NewValue(map[string]interface{}{
+		"ancestry_path": "organizations/123/projects/my-project",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteViolations(context.Background(), []*validator.Violation{
+		{
+			Constraint:       "GCPStorageLoggingConstraint.require-storage-logging",
+			ConstraintConfig: &validator.Constraint{Kind: "GCPStorageLoggingConstraint"},
+			Resource:         "//storage.googleapis.com/my-bucket",
+			Message:          "bucket does not have logging enabled",
+			Severity:         "high",
+			Metadata:         metadata,
+		},
+		{
+			Constraint: "GCPSQLBackupConstraint.require-backups",
+			Resource:   "//cloudsql.googleapis.com/my-instance",
+			Message:    "backups are <disabled>",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"constraint":"GCPStorageLoggingConstraint.require-storage-logging","kind":"GCPStorageLoggingConstraint","resource":"//storage.googleapis.com/my-bucket","severity":"high","message":"bucket does not have logging enabled","ancestry":"organizations/123/projects/my-project","metadata":{"ancestry_path":"organizations/123/projects/my-project"}}` + "\n" +
+		`{"constraint":"GCPSQLBackupConstraint.require-backups","kind":"","resource":"//cloudsql.googleapis.com/my-instance","severity":"","message":"backups are <disabled>","ancestry":"","metadata":null}` + "\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("jsonl diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package junit writes config validator review results as JUnit XML so that CI systems such as Jenkins
+// and GitLab show them in their test reports.  Each resource is a test suite with a test case for each
+// constraint evaluated against it, which fails if the resource violates the constraint.
+package junit
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+)
+
+// DefaultName is the name of the test suites unless set with Name.
+const DefaultName = "config-validator"
+
+// TestSuites is the root element of a JUnit XML report.
+type TestSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Suites   []TestSuite `xml:"testsuite"`
+}
+
+// TestSuite holds the test cases of a resource.
+type TestSuite struct {
+	Name     string     `xml:"name,attr"`
+	Tests    int        `xml:"tests,attr"`
+	Failures int        `xml:"failures,attr"`
+	Cases    []TestCase `xml:"testcase"`
+}
+
+// TestCase is the evaluation of a constraint against a resource, the class name is the resource and
+// the name the constraint as "[Kind].[Name]".
+type TestCase struct {
+	ClassName string   `xml:"classname,attr"`
+	Name      string   `xml:"name,attr"`
+	Failure   *Failure `xml:"failure,omitempty"`
+}
+
+// Failure holds the violations of the constraint by the resource.  The message is that of the first
+// violation, the type its severity, and the text has every message and remediation.
+type Failure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// violationType is the failure type of violations of constraints without a severity.
+const violationType = "violation"
+
+// report collects the test cases by resource and constraint.
+type report struct {
+	cases map[string]map[string]*TestCase
+}
+
+func newReport() *report {
+	return &report{cases: map[string]map[string]*TestCase{}}
+}
+
+// testCase returns the test case of the constraint for the resource, adding a passing one if needed.
+func (r *report) testCase(resource, constraint string) *TestCase {
+	if r.cases[resource] == nil {
+		r.cases[resource] = map[string]*TestCase{}
+	}
+	tc, ok := r.cases[resource][constraint]
+	if !ok {
+		tc = &TestCase{ClassName: resource, Name: constraint}
+		r.cases[resource][constraint] = tc
+	}
+	return tc
+}
+
+// fail records a violation of the constraint by the resource.
+func (r *report) fail(resource, constraint, message, severity, remediation string) {
+	tc := r.testCase(resource, constraint)
+	if tc.Failure == nil {
+		tc.Failure = &Failure{Message: message, Type: severity}
+		if tc.Failure.Type == "" {
+			tc.Failure.Type = violationType
+		}
+	} else {
+		tc.Failure.Text += "\n"
+	}
+	tc.Failure.Text += message
+	if remediation != "" {
+		tc.Failure.Text += "\nRemediation: " + remediation
+	}
+}
+
+// addResults adds a test case for each constraint evaluated against, or violated by, each resource.
+func (r *report) addResults(results []*gcv.Result) error {
+	for _, result := range results {
+		for _, constraint := range result.Evaluated {
+			r.testCase(result.Name, constraint)
+		}
+		violations, err := result.ToViolations()
+		if err != nil {
+			return fmt.Errorf("failed to convert result for %s: %w", result.Name, err)
+		}
+		for _, v := range violations {
+			r.fail(v.GetResource(), v.GetConstraint(), v.GetMessage(), v.GetSeverity(), v.GetRemediation())
+		}
+	}
+	return nil
+}
+
+// testSuites returns the report sorted by resource and constraint.
+func (r *report) testSuites(name string) *TestSuites {
+	suites := &TestSuites{Name: name}
+	resources := make([]string, 0, len(r.cases))
+	for resource := range r.cases {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		suite := TestSuite{Name: resource}
+		for _, tc := range r.cases[resource] {
+			suite.Cases = append(suite.Cases, *tc)
+			suite.Tests++
+			if tc.Failure != nil {
+				suite.Failures++
+			}
+		}
+		sort.Slice(suite.Cases, func(i, j int) bool {
+			return suite.Cases[i].Name < suite.Cases[j].Name
+		})
+		suites.Suites = append(suites.Suites, suite)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+	}
+	return suites
+}
+
+// FromResults converts the results to a JUnit report.  Test cases only pass for the constraints listed
+// in Result.Evaluated, see gcv.RecordEvaluated, otherwise the report only has the failing test cases.
+func FromResults(name string, results []*gcv.Result) (*TestSuites, error) {
+	r := newReport()
+	if err := r.addResults(results); err != nil {
+		return nil, err
+	}
+	return r.testSuites(name), nil
+}
+
+// Encode writes the report as an indented XML document to w.
+func Encode(w io.Writer, suites *TestSuites) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return fmt.Errorf("failed to write junit report: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+var _ export.ResultSink = &Writer{}
+
+// Writer collects results and writes them as a JUnit report when it is closed, since the report
+// counts the test cases of each suite before listing them.
+type Writer struct {
+	w      io.Writer
+	name   string
+	report *report
+}
+
+// Option configures a Writer.
+type Option func(*Writer)
+
+// Name sets the name of the test suites, DefaultName if not set.
+func Name(name string) Option {
+	return func(w *Writer) {
+		w.name = name
+	}
+}
+
+// NewWriter returns a Writer that writes a JUnit report to w on Close.
+func NewWriter(w io.Writer, opts ...Option) *Writer {
+	jw := &Writer{w: w, name: DefaultName, report: newReport()}
+	for _, opt := range opts {
+		opt(jw)
+	}
+	return jw
+}
+
+// WriteResults adds the results to the report.
+func (w *Writer) WriteResults(ctx context.Context, results []*gcv.Result) error {
+	return w.report.addResults(results)
+}
+
+// WriteViolations adds a failing test case for each violation to the report.
+func (w *Writer) WriteViolations(ctx context.Context, violations []*validator.Violation) error {
+	for _, v := range violations {
+		w.report.fail(v.GetResource(), v.GetConstraint(), v.GetMessage(), v.GetSeverity(), v.GetRemediation())
+	}
+	return nil
+}
+
+// Close writes the report.
+func (w *Writer) Close() error {
+	return Encode(w.w, w.report.testSuites(w.name))
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package junit
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testConstraint(kind, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"spec":       map[string]interface{}{},
+	}}
+	u.SetKind(kind)
+	u.SetName(name)
+	return u
+}
+
+const wantReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="ci" tests="4" failures="3">
+  <testsuite name="//compute.googleapis.com/projects/p/global/firewalls/f" tests="1" failures="1">
+    <testcase classname="//compute.googleapis.com/projects/p/global/firewalls/f" name="GCPFirewallConstraint.no-open-ports">
+      <failure message="port 22 is open to &lt;0.0.0.0/0&gt;" type="critical">port 22 is open to &lt;0.0.0.0/0&gt;</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="//storage.googleapis.com/b" tests="3" failures="2">
+    <testcase classname="//storage.googleapis.com/b" name="GCPStorageLocationConstraint.allowed-locations"></testcase>
+    <testcase classname="//storage.googleapis.com/b" name="GCPStorageLoggingConstraint.require-logging">
+      <failure message="no logging" type="high">no logging&#xA;Remediation: Enable access logging.&#xA;no logging destination</failure>
+    </testcase>
+    <testcase classname="//storage.googleapis.com/b" name="GCPStorageVersioningConstraint.versioning">
+      <failure message="versioning disabled" type="violation">versioning disabled</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+
+func TestWriter(t *testing.T) {
+	logging := testConstraint("GCPStorageLoggingConstraint", "require-logging")
+	results := []*gcv.Result{{
+		Name:          "//storage.googleapis.com/b",
+		InputResource: map[string]interface{}{},
+		ConstraintViolations: []gcv.ConstraintViolation{
+			{Message: "no logging", Constraint: logging, Severity: "high", Remediation: "Enable access logging."},
+			{Message: "no logging destination", Constraint: logging, Severity: "high"},
+			{Message: "versioning disabled", Constraint: testConstraint("GCPStorageVersioningConstraint", "versioning")},
+		},
+		Evaluated: []string{
+			"GCPStorageLocationConstraint.allowed-locations",
+			"GCPStorageLoggingConstraint.require-logging",
+			"GCPStorageVersioningConstraint.versioning",
+		},
+	}}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, Name("ci"))
+	ctx := context.Background()
+	if err := w.WriteResults(ctx, results); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteViolations(ctx, []*validator.Violation{{
+		Constraint: "GCPFirewallConstraint.no-open-ports",
+		Resource:   "//compute.googleapis.com/projects/p/global/firewalls/f",
+		Message:    "port 22 is open to <0.0.0.0/0>",
+		Severity:   "critical",
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(wantReport, buf.String()); diff != "" {
+		t.Errorf("report diff (-want +got):\n%s", diff)
+	}
+
+	var parsed TestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Tests != 4 || parsed.Failures != 3 || len(parsed.Suites) != 2 {
+		t.Errorf("parsed report has %d tests, %d failures and %d suites, want 4, 3 and 2", parsed.Tests, parsed.Failures, len(parsed.Suites))
+	}
+}
+
+func TestFromResultsWithoutEvaluated(t *testing.T) {
+	suites, err := FromResults(DefaultName, []*gcv.Result{
+		{Name: "//storage.googleapis.com/compliant"},
+		{Name: "//storage.googleapis.com/b", ConstraintViolations: []gcv.ConstraintViolation{
+			{Message: "no logging", Constraint: testConstraint("GCPStorageLoggingConstraint", "require-logging")},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only failing test cases are known without the evaluated constraints.
+	if suites.Tests != 1 || suites.Failures != 1 || len(suites.Suites) != 1 {
+		t.Errorf("got %d tests, %d failures and %d suites, want 1, 1 and 1", suites.Tests, suites.Failures, len(suites.Suites))
+	}
+}
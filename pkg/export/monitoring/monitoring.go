@@ -0,0 +1,265 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package monitoring exports violation counts to Cloud Monitoring as a custom metric, so that alerting
+// policies can be built on audit results.  The results of an audit, eg from gcv.OnResult in
+// Validator.ReviewCAIExport, are written to a Writer and Writer.Flush is called once the audit ends.
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
+	"github.com/golang/glog"
+	"google.golang.org/api/googleapi"
+	cm "google.golang.org/api/monitoring/v3"
+)
+
+const (
+	// MetricType is the custom metric the violation counts are written to.
+	MetricType = "custom.googleapis.com/config_validator/violations"
+	// maxTimeSeriesPerRequest is the limit on time series in a single CreateTimeSeries call.
+	maxTimeSeriesPerRequest = 200
+	// ancestryPathKey is the violation metadata key holding the ancestry path of the resource.
+	ancestryPathKey = "ancestry_path"
+)
+
+// Labels of the violation count metric.
+const (
+	LabelConstraint = "constraint"
+	LabelSeverity   = "severity"
+	LabelProject    = "project"
+)
+
+// MetricDescriptor is the descriptor of MetricType, created by EnsureMetricDescriptor.
+var MetricDescriptor = &cm.MetricDescriptor{
+	Type:        MetricType,
+	MetricKind:  "GAUGE",
+	ValueType:   "INT64",
+	Unit:        "1",
+	DisplayName: "Config Validator violations",
+	Description: "Number of violations found by the last audit, by constraint, severity and project.",
+	Labels: []*cm.LabelDescriptor{
+		{Key: LabelConstraint, ValueType: "STRING", Description: "Constraint that was violated, as Kind.name."},
+		{Key: LabelSeverity, ValueType: "STRING", Description: "Severity of the constraint."},
+		{Key: LabelProject, ValueType: "STRING", Description: "Project of the resource, empty for resources outside a project."},
+	},
+}
+
+// seriesKey identifies a time series of the metric.
+type seriesKey struct {
+	constraint string
+	severity   string
+	project    string
+}
+
+var _ export.ResultSink = &Writer{}
+
+// Writer counts the violations written to it and exports the counts to Cloud Monitoring on Flush.
+// Violations are written to the Writer during an audit, then Flush is called once the audit is
+// complete.
+type Writer struct {
+	service   *cm.Service
+	projectID string
+	now       func() time.Time
+
+	mtx    sync.Mutex
+	counts map[seriesKey]int64
+	// reported are the series written by the previous Flush, series that no longer have violations
+	// are reported as zero so that alerts on them resolve.
+	reported map[seriesKey]bool
+}
+
+// NewWriter returns a Writer that writes the metric to the Cloud Monitoring workspace of projectID.
+func NewWriter(service *cm.Service, projectID string) *Writer {
+	return &Writer{
+		service:   service,
+		projectID: projectID,
+		now:       time.Now,
+		counts:    map[seriesKey]int64{},
+		reported:  map[seriesKey]bool{},
+	}
+}
+
+// EnsureMetricDescriptor creates the metric descriptor if it does not exist.  Cloud Monitoring also
+// creates it automatically when the first point is written, but without descriptions.
+func (w *Writer) EnsureMetricDescriptor(ctx context.Context) error {
+	name := fmt.Sprintf("%s/metricDescriptors/%s", w.projectName(), MetricType)
+	_, err := w.service.Projects.MetricDescriptors.Get(name).Context(ctx).Do()
+	if err == nil {
+		return nil
+	}
+	if apiErr, ok := err.(*googleapi.Error); !ok || apiErr.Code != http.StatusNotFound {
+		return fmt.Errorf("failed to get metric descriptor %s: %w", MetricType, err)
+	}
+	glog.Infof("creating metric descriptor %s in %s", MetricType, w.projectID)
+	if _, err := w.service.Projects.MetricDescriptors.Create(w.projectName(), MetricDescriptor).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to create metric descriptor %s: %w", MetricType, err)
+	}
+	return nil
+}
+
+// WriteResults counts the violations of each result.
+func (w *Writer) WriteResults(ctx context.Context, results []*gcv.Result) error {
+	var violations []*validator.Violation
+	for _, result := range results {
+		vs, err := result.ToViolations()
+		if err != nil {
+			return fmt.Errorf("failed to convert result for %s: %w", result.Name, err)
+		}
+		violations = append(violations, vs...)
+	}
+	return w.WriteViolations(ctx, violations)
+}
+
+// WriteViolations counts the violations, nothing is sent until Flush.
+func (w *Writer) WriteViolations(ctx context.Context, violations []*validator.Violation) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	for _, v := range violations {
+		w.counts[seriesKey{
+			constraint: v.GetConstraint(),
+			severity:   v.GetSeverity(),
+			project:    project(v),
+		}]++
+	}
+	return nil
+}
+
+// Flush writes the counts since the last Flush to Cloud Monitoring and resets them.  Series written
+// by the previous Flush without violations since are written as zero.
+func (w *Writer) Flush(ctx context.Context) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	counts := w.counts
+	for key := range w.reported {
+		if _, ok := counts[key]; !ok {
+			counts[key] = 0
+		}
+	}
+	series := w.timeSeries(counts)
+
+	var errs multierror.Errors
+	for start := 0; start < len(series); start += maxTimeSeriesPerRequest {
+		end := start + maxTimeSeriesPerRequest
+		if end > len(series) {
+			end = len(series)
+		}
+		_, err := w.service.Projects.TimeSeries.Create(w.projectName(), &cm.CreateTimeSeriesRequest{
+			TimeSeries: series[start:end],
+		}).Context(ctx).Do()
+		if err != nil {
+			errs.Add(fmt.Errorf("failed to write time series %d-%d: %w", start, end-1, err))
+		}
+	}
+	w.counts = map[seriesKey]int64{}
+	if err := errs.ToError(); err != nil {
+		// The counts belong to this audit and are dropped, but every series is written again by the
+		// next Flush so that none is left at a stale value.
+		for key := range counts {
+			w.reported[key] = true
+		}
+		return err
+	}
+	w.reported = map[seriesKey]bool{}
+	for key, count := range counts {
+		if count != 0 {
+			w.reported[key] = true
+		}
+	}
+	return nil
+}
+
+// Close flushes any counts that have not been written.
+func (w *Writer) Close() error {
+	w.mtx.Lock()
+	pending := len(w.counts) != 0
+	w.mtx.Unlock()
+	if !pending {
+		return nil
+	}
+	return w.Flush(context.Background())
+}
+
+// timeSeries returns a point for each count, sorted for deterministic requests.
+func (w *Writer) timeSeries(counts map[seriesKey]int64) []*cm.TimeSeries {
+	keys := make([]seriesKey, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.constraint != b.constraint {
+			return a.constraint < b.constraint
+		}
+		if a.severity != b.severity {
+			return a.severity < b.severity
+		}
+		return a.project < b.project
+	})
+
+	endTime := w.now().UTC().Format(time.RFC3339Nano)
+	series := make([]*cm.TimeSeries, 0, len(keys))
+	for _, key := range keys {
+		count := counts[key]
+		series = append(series, &cm.TimeSeries{
+			Metric: &cm.Metric{
+				Type: MetricType,
+				Labels: map[string]string{
+					LabelConstraint: key.constraint,
+					LabelSeverity:   key.severity,
+					LabelProject:    key.project,
+				},
+			},
+			Resource: &cm.MonitoredResource{
+				Type:   "global",
+				Labels: map[string]string{"project_id": w.projectID},
+			},
+			MetricKind: "GAUGE",
+			ValueType:  "INT64",
+			Points: []*cm.Point{{
+				Interval: &cm.TimeInterval{EndTime: endTime},
+				Value:    &cm.TypedValue{Int64Value: &count},
+			}},
+		})
+	}
+	return series
+}
+
+func (w *Writer) projectName() string {
+	return "projects/" + w.projectID
+}
+
+// project returns the project in the ancestry path of the violation's resource, eg "projects/123", or
+// an empty string if the resource is not in a project.
+func project(v *validator.Violation) string {
+	ancestryPath := v.GetMetadata().GetStructValue().GetFields()[ancestryPathKey].GetStringValue()
+	segments := strings.Split(ancestryPath, "/")
+	for i := len(segments) - 2; i >= 0; i -= 2 {
+		if segments[i] == "projects" {
+			return segments[i] + "/" + segments[i+1]
+		}
+	}
+	return ""
+}
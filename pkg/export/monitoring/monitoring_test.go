@@ -0,0 +1,186 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	cm "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var testTime = time.Date(2023, 8, 1, 12, 30, 0, 0, time.UTC)
+
+func testViolation(t *testing.T, constraint, ancestryPath string) *validator.Violation {
+	metadata, err := structpb.NewValue(map[string]interface{}{"ancestry_path": ancestryPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &validator.Violation{
+		Constraint: constraint,
+		Resource:   "//storage.googleapis.com/my-bucket",
+		Metadata:   metadata,
+		Severity:   "high",
+	}
+}
+
+func TestProject(t *testing.T) {
+	testCases := []struct {
+		ancestryPath string
+		want         string
+	}{
+		{ancestryPath: "organizations/1/folders/2/projects/3", want: "projects/3"},
+		{ancestryPath: "organizations/1/folders/2", want: ""},
+		{ancestryPath: "", want: ""},
+	}
+	for _, tc := range testCases {
+		if got := project(testViolation(t, "c", tc.ancestryPath)); got != tc.want {
+			t.Errorf("project(%q) = %q, want %q", tc.ancestryPath, got, tc.want)
+		}
+	}
+}
+
+// point is a written time series reduced to its labels and value.
+type point struct {
+	Constraint string
+	Project    string
+	Value      int64
+}
+
+func TestWriter(t *testing.T) {
+	var created bool
+	var flushes [][]point
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/metricDescriptors/"):
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"code": 404, "message": "not found"}}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/metricDescriptors"):
+			created = true
+			_, _ = w.Write([]byte(`{}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/timeSeries"):
+			req := &cm.CreateTimeSeriesRequest{}
+			if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+				t.Errorf("failed to decode timeSeries request: %v", err)
+			}
+			var points []point
+			for _, ts := range req.TimeSeries {
+				if ts.Metric.Type != MetricType || ts.Resource.Labels["project_id"] != "my-project" {
+					t.Errorf("unexpected time series %+v", ts)
+				}
+				if got := ts.Points[0].Interval.EndTime; got != testTime.Format(time.RFC3339Nano) {
+					t.Errorf("got end time %s", got)
+				}
+				points = append(points, point{
+					Constraint: ts.Metric.Labels[LabelConstraint],
+					Project:    ts.Metric.Labels[LabelProject],
+					Value:      *ts.Points[0].Value.Int64Value,
+				})
+			}
+			flushes = append(flushes, points)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	service, err := cm.NewService(ctx, option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := NewWriter(service, "my-project")
+	writer.now = func() time.Time { return testTime }
+
+	if err := writer.EnsureMetricDescriptor(ctx); err != nil {
+		t.Fatalf("EnsureMetricDescriptor: %v", err)
+	}
+	if !created {
+		t.Errorf("expected metric descriptor to be created")
+	}
+
+	if err := writer.WriteViolations(ctx, []*validator.Violation{
+		testViolation(t, "A.a", "organizations/1/projects/2"),
+		testViolation(t, "A.a", "organizations/1/projects/2"),
+		testViolation(t, "B.b", "organizations/1/projects/3"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	// The second audit no longer finds B.b, it is reported as zero once.
+	if err := writer.WriteViolations(ctx, []*validator.Violation{
+		testViolation(t, "A.a", "organizations/1/projects/2"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if err := writer.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	want := [][]point{
+		{{"A.a", "projects/2", 2}, {"B.b", "projects/3", 1}},
+		{{"A.a", "projects/2", 1}, {"B.b", "projects/3", 0}},
+		{{"A.a", "projects/2", 0}},
+	}
+	if diff := cmp.Diff(want, flushes); diff != "" {
+		t.Errorf("flushed points diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriterBatches(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	service, err := cm.NewService(ctx, option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := NewWriter(service, "my-project")
+	var violations []*validator.Violation
+	for i := 0; i < maxTimeSeriesPerRequest+1; i++ {
+		violations = append(violations, testViolation(t, fmt.Sprintf("A.a%d", i), ""))
+	}
+	if err := writer.WriteViolations(ctx, violations); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package parquet writes config validator review results as Parquet files so they can be loaded into
+// data lakes, BigQuery external tables or Spark jobs without conversion.
+package parquet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+	pq "github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// ancestryPathKey is the violation metadata key holding the ancestry path of the resource.
+	ancestryPathKey = "ancestry_path"
+	// parallelism is the number of goroutines used by the parquet writer to encode columns.
+	parallelism = 4
+)
+
+// Row is a single violation row as stored in the Parquet file.  The column names and types are stable,
+// columns are only ever appended so that existing external tables keep working.
+type Row struct {
+	Timestamp         int64    `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Constraint        string   `parquet:"name=constraint, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ConstraintKind    string   `parquet:"name=constraint_kind, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Resource          string   `parquet:"name=resource, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Severity          string   `parquet:"name=severity, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Message           string   `parquet:"name=message, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AncestryPath      string   `parquet:"name=ancestry_path, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Organization      string   `parquet:"name=organization, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Folders           []string `parquet:"name=folders, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Project           string   `parquet:"name=project, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Metadata          string   `parquet:"name=metadata, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PolicyFingerprint string   `parquet:"name=policy_fingerprint, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Fingerprint       string   `parquet:"name=fingerprint, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// NewRow converts a violation to a Row.
+func NewRow(v *validator.Violation, timestamp time.Time, policyFingerprint string) (*Row, error) {
+	row := &Row{
+		Timestamp:         timestamp.UnixMilli(),
+		Constraint:        v.GetConstraint(),
+		ConstraintKind:    v.GetConstraintConfig().GetKind(),
+		Resource:          v.GetResource(),
+		Severity:          v.GetSeverity(),
+		Message:           v.GetMessage(),
+		Folders:           []string{},
+		PolicyFingerprint: policyFingerprint,
+		Fingerprint:       v.GetFingerprint(),
+	}
+	if v.GetMetadata() != nil {
+		metadata, err := protojson.Marshal(v.GetMetadata())
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata for %s on %s: %w", v.GetConstraint(), v.GetResource(), err)
+		}
+		row.Metadata = string(metadata)
+		if ancestryPath, ok := v.GetMetadata().GetStructValue().GetFields()[ancestryPathKey]; ok {
+			row.setAncestry(ancestryPath.GetStringValue())
+		}
+	}
+	return row, nil
+}
+
+// setAncestry sets the ancestry path and splits it into its organization, folder and project segments.
+// The path has the form organizations/123/folders/456/projects/789, any segment may be missing.
+func (r *Row) setAncestry(ancestryPath string) {
+	r.AncestryPath = ancestryPath
+	parts := strings.Split(ancestryPath, "/")
+	for i := 0; i+1 < len(parts); i += 2 {
+		switch parts[i] {
+		case "organizations", "organization":
+			r.Organization = parts[i+1]
+		case "folders", "folder":
+			r.Folders = append(r.Folders, parts[i+1])
+		case "projects", "project":
+			r.Project = parts[i+1]
+		}
+	}
+}
+
+var _ export.ResultSink = &Writer{}
+
+// Writer writes violations to a Parquet file.
+type Writer struct {
+	pw                *writer.ParquetWriter
+	policyFingerprint string
+	now               func() time.Time
+	count             int
+}
+
+// Option configures a Writer.
+type Option func(*Writer)
+
+// PolicyFingerprint sets the policy fingerprint that is recorded on every row.
+func PolicyFingerprint(fingerprint string) Option {
+	return func(w *Writer) {
+		w.policyFingerprint = fingerprint
+	}
+}
+
+// NewWriter returns a Writer that writes a Snappy compressed Parquet file to w.  Close must be called
+// to write the file footer.
+func NewWriter(w io.Writer, opts ...Option) (*Writer, error) {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(Row), parallelism)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parquet writer: %w", err)
+	}
+	pw.CompressionType = pq.CompressionCodec_SNAPPY
+	fw := &Writer{
+		pw:  pw,
+		now: time.Now,
+	}
+	for _, opt := range opts {
+		opt(fw)
+	}
+	return fw, nil
+}
+
+// WriteResults writes the violations of each result to the file.
+func (w *Writer) WriteResults(ctx context.Context, results []*gcv.Result) error {
+	for _, result := range results {
+		violations, err := result.ToViolations()
+		if err != nil {
+			return fmt.Errorf("failed to convert result for %s: %w", result.Name, err)
+		}
+		if err := w.WriteViolations(ctx, violations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteViolations writes the violations to the file.  All rows written by a single call share the
+// same timestamp.
+func (w *Writer) WriteViolations(ctx context.Context, violations []*validator.Violation) error {
+	timestamp := w.now()
+	for _, v := range violations {
+		row, err := NewRow(v, timestamp, w.policyFingerprint)
+		if err != nil {
+			return err
+		}
+		if err := w.pw.Write(row); err != nil {
+			return fmt.Errorf("failed to write violation %d: %w", w.count, err)
+		}
+		w.count++
+	}
+	return nil
+}
+
+// Count returns the number of violations written.
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Close writes the buffered rows and the file footer, it does not close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.pw.WriteStop(); err != nil {
+		return fmt.Errorf("failed to finish parquet file: %w", err)
+	}
+	return nil
+}
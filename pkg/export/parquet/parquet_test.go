@@ -0,0 +1,127 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestNewRow(t *testing.T) {
+	metadata, err := structpb.NewValue(map[string]interface{}{
+		"ancestry_path": "organizations/123/folders/456/folders/789/projects/my-project",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	got, err := NewRow(&validator.Violation{
+		Constraint:  "GCPStorageLoggingConstraint.require-storage-logging",
+		Resource:    "//storage.googleapis.com/my-bucket",
+		Message:     "bucket does not have logging enabled",
+		Severity:    "high",
+		Metadata:    metadata,
+		Fingerprint: "3ef8779b",
+	}, ts, "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Row{
+		Timestamp:         ts.UnixMilli(),
+		Constraint:        "GCPStorageLoggingConstraint.require-storage-logging",
+		Resource:          "//storage.googleapis.com/my-bucket",
+		Severity:          "high",
+		Message:           "bucket does not have logging enabled",
+		AncestryPath:      "organizations/123/folders/456/folders/789/projects/my-project",
+		Organization:      "123",
+		Folders:           []string{"456", "789"},
+		Project:           "my-project",
+		Metadata:          `{"ancestry_path":"organizations/123/folders/456/folders/789/projects/my-project"}`,
+		PolicyFingerprint: "abc123",
+		Fingerprint:       "3ef8779b",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("row diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	metadata, err := structpb.NewValue(map[string]interface{}{
+		"ancestry_path": "organizations/123/projects/my-project",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations := []*validator.Violation{
+		{
+			Constraint: "GCPStorageLoggingConstraint.require-storage-logging",
+			Resource:   "//storage.googleapis.com/my-bucket",
+			Severity:   "high",
+			Metadata:   metadata,
+		},
+		{
+			Constraint: "GCPStorageLoggingConstraint.require-storage-logging",
+			Resource:   "//storage.googleapis.com/other-bucket",
+		},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, PolicyFingerprint("abc123"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteViolations(context.Background(), violations); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Count() != len(violations) {
+		t.Errorf("got count %d, want %d", w.Count(), len(violations))
+	}
+
+	file, err := buffer.NewBufferFile(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetReader(file, new(Row), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+	if int(pr.GetNumRows()) != len(violations) {
+		t.Fatalf("got %d rows, want %d", pr.GetNumRows(), len(violations))
+	}
+	rows := make([]Row, len(violations))
+	if err := pr.Read(&rows); err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range rows {
+		if row.Resource != violations[i].Resource || row.PolicyFingerprint != "abc123" {
+			t.Errorf("row %d: unexpected row %+v", i, row)
+		}
+	}
+	if rows[0].Organization != "123" || rows[0].Project != "my-project" {
+		t.Errorf("unexpected ancestry in row %+v", rows[0])
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Projection selects the parts of a violation that a sink needs.
+type Projection struct {
+	// Fields are the Violation fields to keep by proto name, eg "resource" or "message".  All fields
+	// are kept if empty.
+	Fields []string
+	// MetadataKeys are the top level metadata keys to keep.  All keys are kept if empty.
+	MetadataKeys []string
+}
+
+// Validate returns an error if the projection names a field that Violation does not have.
+func (p Projection) Validate() error {
+	fields := (&validator.Violation{}).ProtoReflect().Descriptor().Fields()
+	var errs multierror.Errors
+	for _, name := range p.Fields {
+		if fields.ByName(protoreflect.Name(name)) == nil {
+			errs.Add(fmt.Errorf("unknown violation field %q", name))
+		}
+	}
+	return errs.ToError()
+}
+
+// empty returns true if the projection keeps the whole violation.
+func (p Projection) empty() bool {
+	return len(p.Fields) == 0 && len(p.MetadataKeys) == 0
+}
+
+// Apply returns copies of the violations with only the projected fields and metadata keys set.  The
+// violations are returned as is if the projection is empty.
+func (p Projection) Apply(violations []*validator.Violation) []*validator.Violation {
+	if p.empty() {
+		return violations
+	}
+	keep := map[protoreflect.Name]bool{}
+	for _, name := range p.Fields {
+		keep[protoreflect.Name(name)] = true
+	}
+	keys := map[string]bool{}
+	for _, key := range p.MetadataKeys {
+		keys[key] = true
+	}
+
+	projected := make([]*validator.Violation, len(violations))
+	for i, v := range violations {
+		c := proto.Clone(v).(*validator.Violation)
+		if len(keep) != 0 {
+			m := c.ProtoReflect()
+			m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+				if !keep[fd.Name()] {
+					m.Clear(fd)
+				}
+				return true
+			})
+		}
+		if len(keys) != 0 {
+			if fields := c.GetMetadata().GetStructValue().GetFields(); fields != nil {
+				filtered := map[string]*structpb.Value{}
+				for k, value := range fields {
+					if keys[k] {
+						filtered[k] = value
+					}
+				}
+				c.Metadata = structpb.NewStructValue(&structpb.Struct{Fields: filtered})
+			}
+		}
+		projected[i] = c
+	}
+	return projected
+}
+
+// Projector is implemented by sinks that only need part of each violation.
+type Projector interface {
+	Projection() Projection
+}
+
+// projectedSink adds a projection to a sink.
+type projectedSink struct {
+	ResultSink
+	projection Projection
+}
+
+// Projection implements Projector.
+func (s *projectedSink) Projection() Projection {
+	return s.projection
+}
+
+// WithProjection returns sink with the projection declared, so that MultiSink only writes the projected
+// fields to it.
+func WithProjection(sink ResultSink, projection Projection) (ResultSink, error) {
+	if err := projection.Validate(); err != nil {
+		return nil, err
+	}
+	return &projectedSink{ResultSink: sink, projection: projection}, nil
+}
+
+var _ ResultSink = &MultiSink{}
+
+// MultiSink writes results to several sinks, applying the Projection of each sink that implements
+// Projector.
+type MultiSink struct {
+	sinks []ResultSink
+}
+
+// NewMultiSink returns a MultiSink that writes to sinks.
+func NewMultiSink(sinks ...ResultSink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+// WriteResults converts the results to violations once and writes them to each sink.
+func (m *MultiSink) WriteResults(ctx context.Context, results []*gcv.Result) error {
+	var violations []*validator.Violation
+	for _, result := range results {
+		vs, err := result.ToViolations()
+		if err != nil {
+			return fmt.Errorf("failed to convert result for %s: %w", result.Name, err)
+		}
+		violations = append(violations, vs...)
+	}
+	return m.WriteViolations(ctx, violations)
+}
+
+// WriteViolations writes the violations to each sink, all sinks are written to even if some fail.
+func (m *MultiSink) WriteViolations(ctx context.Context, violations []*validator.Violation) error {
+	var errs multierror.Errors
+	for _, sink := range m.sinks {
+		vs := violations
+		if p, ok := sink.(Projector); ok {
+			vs = p.Projection().Apply(violations)
+		}
+		errs.Add(sink.WriteViolations(ctx, vs))
+	}
+	return errs.ToError()
+}
+
+// Close closes each sink.
+func (m *MultiSink) Close() error {
+	var errs multierror.Errors
+	for _, sink := range m.sinks {
+		errs.Add(sink.Close())
+	}
+	return errs.ToError()
+}
@@ -0,0 +1,149 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func testViolation(t *testing.T) *validator.Violation {
+	metadata, err := structpb.NewValue(map[string]interface{}{
+		"ancestry_path": "organizations/1/projects/2",
+		"details":       map[string]interface{}{"location": "us-west1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &validator.Violation{
+		Constraint:       "GCPStorageLocationConstraintV1.allow-some-storage-location",
+		ConstraintConfig: &validator.Constraint{Kind: "GCPStorageLocationConstraintV1"},
+		Resource:         "//storage.googleapis.com/my-bucket",
+		Message:          "bucket in disallowed location",
+		Metadata:         metadata,
+		Severity:         "high",
+	}
+}
+
+func TestProjectionApply(t *testing.T) {
+	full := testViolation(t)
+	testCases := []struct {
+		name       string
+		projection Projection
+		want       *validator.Violation
+	}{
+		{
+			name:       "empty keeps everything",
+			projection: Projection{},
+			want:       testViolation(t),
+		},
+		{
+			name:       "fields",
+			projection: Projection{Fields: []string{"message", "resource"}},
+			want: &validator.Violation{
+				Resource: "//storage.googleapis.com/my-bucket",
+				Message:  "bucket in disallowed location",
+			},
+		},
+		{
+			name:       "metadata keys",
+			projection: Projection{Fields: []string{"resource", "metadata"}, MetadataKeys: []string{"ancestry_path"}},
+			want: &validator.Violation{
+				Resource: "//storage.googleapis.com/my-bucket",
+				Metadata: structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+					"ancestry_path": structpb.NewStringValue("organizations/1/projects/2"),
+				}}),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.projection.Apply([]*validator.Violation{full})
+			if diff := cmp.Diff([]*validator.Violation{tc.want}, got, protocmp.Transform()); diff != "" {
+				t.Errorf("Apply() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+	if diff := cmp.Diff(testViolation(t), full, protocmp.Transform()); diff != "" {
+		t.Errorf("Apply() modified its input (-want +got):\n%s", diff)
+	}
+}
+
+func TestProjectionValidate(t *testing.T) {
+	if err := (Projection{Fields: []string{"resource", "fingerprint"}}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (Projection{Fields: []string{"resource", "labels"}}).Validate(); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+	if _, err := WithProjection(&recordingSink{}, Projection{Fields: []string{"labels"}}); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+}
+
+type recordingSink struct {
+	violations []*validator.Violation
+	closed     bool
+}
+
+func (s *recordingSink) WriteResults(ctx context.Context, results []*gcv.Result) error {
+	return nil
+}
+
+func (s *recordingSink) WriteViolations(ctx context.Context, violations []*validator.Violation) error {
+	s.violations = append(s.violations, violations...)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestMultiSink(t *testing.T) {
+	full := &recordingSink{}
+	notifier := &recordingSink{}
+	projected, err := WithProjection(notifier, Projection{Fields: []string{"message", "resource"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMultiSink(full, projected)
+	if err := m.WriteViolations(context.Background(), []*validator.Violation{testViolation(t)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]*validator.Violation{testViolation(t)}, full.violations, protocmp.Transform()); diff != "" {
+		t.Errorf("full sink diff (-want +got):\n%s", diff)
+	}
+	want := []*validator.Violation{{
+		Resource: "//storage.googleapis.com/my-bucket",
+		Message:  "bucket in disallowed location",
+	}}
+	if diff := cmp.Diff(want, notifier.violations, protocmp.Transform()); diff != "" {
+		t.Errorf("projected sink diff (-want +got):\n%s", diff)
+	}
+	if !full.closed || !notifier.closed {
+		t.Errorf("sinks not closed")
+	}
+}
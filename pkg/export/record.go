@@ -0,0 +1,78 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ancestryPathKey is the violation metadata key holding the ancestry path of the resource.
+const ancestryPathKey = "ancestry_path"
+
+// Columns are the names of the fields of a Record in order, as written by the csv and jsonl sinks.
+// Columns are only ever appended so that existing consumers keep working.
+var Columns = []string{"constraint", "kind", "resource", "severity", "message", "ancestry", "metadata"}
+
+// Record is the flat form of a violation written by the csv and jsonl sinks.
+type Record struct {
+	Constraint string `json:"constraint"`
+	Kind       string `json:"kind"`
+	Resource   string `json:"resource"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Ancestry   string `json:"ancestry"`
+	// Metadata is the compact JSON encoding of the violation metadata, null if there is none.
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// NewRecord converts a violation to a Record.
+func NewRecord(v *validator.Violation) (*Record, error) {
+	r := &Record{
+		Constraint: v.GetConstraint(),
+		Kind:       v.GetConstraintConfig().GetKind(),
+		Resource:   v.GetResource(),
+		Severity:   v.GetSeverity(),
+		Message:    v.GetMessage(),
+		Metadata:   json.RawMessage("null"),
+	}
+	if v.GetMetadata() == nil {
+		return r, nil
+	}
+	metadata, err := protojson.Marshal(v.GetMetadata())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata for %s on %s: %w", v.GetConstraint(), v.GetResource(), err)
+	}
+	// protojson output is deliberately unstable, compact it so the same violation is always written the
+	// same way.
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, metadata); err != nil {
+		return nil, fmt.Errorf("failed to compact metadata for %s on %s: %w", v.GetConstraint(), v.GetResource(), err)
+	}
+	r.Metadata = buf.Bytes()
+	if ancestry, ok := v.GetMetadata().GetStructValue().GetFields()[ancestryPathKey]; ok {
+		r.Ancestry = ancestry.GetStringValue()
+	}
+	return r, nil
+}
+
+// Values returns the fields of the record in the order of Columns, with the metadata as a JSON string.
+func (r *Record) Values() []string {
+	return []string{r.Constraint, r.Kind, r.Resource, r.Severity, r.Message, r.Ancestry, string(r.Metadata)}
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestNewRecord(t *testing.T) {
+	metadata, err := structpb.NewValue(map[string]interface{}{
+		"ancestry_path": "organizations/123/projects/my-project",
+		"details":       map[string]interface{}{"bucket": "my-bucket"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name      string
+		violation *validator.Violation
+		want      *Record
+	}{
+		{
+			name: "full",
+			violation: &validator.Violation{
+				Constraint:       "GCPStorageLoggingConstraint.require-storage-logging",
+				ConstraintConfig: &validator.Constraint{Kind: "GCPStorageLoggingConstraint"},
+				Resource:         "//storage.googleapis.com/my-bucket",
+				Message:          "bucket does not have logging enabled",
+				Severity:         "high",
+				Metadata:         metadata,
+			},
+			want: &Record{
+				Constraint: "GCPStorageLoggingConstraint.require-storage-logging",
+				Kind:       "GCPStorageLoggingConstraint",
+				Resource:   "//storage.googleapis.com/my-bucket",
+				Severity:   "high",
+				Message:    "bucket does not have logging enabled",
+				Ancestry:   "organizations/123/projects/my-project",
+				Metadata:   json.RawMessage(`{"ancestry_path":"organizations/123/projects/my-project","details":{"bucket":"my-bucket"}}`),
+			},
+		},
+		{
+			name: "no metadata",
+			violation: &validator.Violation{
+				Constraint: "GCPStorageLoggingConstraint.require-storage-logging",
+				Resource:   "//storage.googleapis.com/my-bucket",
+				Message:    "bucket does not have logging enabled",
+			},
+			want: &Record{
+				Constraint: "GCPStorageLoggingConstraint.require-storage-logging",
+				Resource:   "//storage.googleapis.com/my-bucket",
+				Message:    "bucket does not have logging enabled",
+				Metadata:   json.RawMessage("null"),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewRecord(tc.violation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("record diff (-want +got):\n%s", diff)
+			}
+			if len(got.Values()) != len(Columns) {
+				t.Errorf("got %d values, want one for each of %v", len(got.Values()), Columns)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package feed reviews assets from a Cloud Asset Inventory real-time feed.
+//
+// A CAI feed publishes a TemporalAsset JSON message to Pub/Sub each time an asset changes.  The
+// Listener receives those messages from a subscription, reviews the changed asset and writes the
+// violations to the configured sinks.
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/export"
+	"github.com/golang/glog"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ErrDeleted is returned by ParseMessage for messages about deleted assets.
+var ErrDeleted = errors.New("asset was deleted")
+
+// Reviewer reviews a single asset, it is implemented by gcv.Validator.
+type Reviewer interface {
+	ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error)
+}
+
+// temporalAsset is the JSON message published by a CAI feed.
+type temporalAsset struct {
+	Asset   json.RawMessage `json:"asset"`
+	Deleted bool            `json:"deleted"`
+}
+
+// ParseMessage converts the data of a CAI feed message to an asset.  ErrDeleted is returned if the
+// message is for a deleted asset, which has nothing left to review.
+func ParseMessage(data []byte) (*validator.Asset, error) {
+	var msg temporalAsset
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal feed message: %w", err)
+	}
+	if msg.Deleted {
+		return nil, ErrDeleted
+	}
+	if len(msg.Asset) == 0 {
+		return nil, fmt.Errorf("feed message has no asset")
+	}
+	asset := &validator.Asset{}
+	// Feeds include asset fields that the validator does not review, such as osInventory.
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(msg.Asset, asset); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal feed asset: %w", err)
+	}
+	return asset, nil
+}
+
+// Option configures a Listener.
+type Option func(*Listener)
+
+// Sink adds a sink that the violations found by the Listener are written to.  Sinks that implement
+// export.Projector only receive their projection of each violation.
+func Sink(sink export.ResultSink) Option {
+	return func(l *Listener) {
+		l.sinks = append(l.sinks, sink)
+	}
+}
+
+// Listener reviews the assets published to a CAI feed subscription.
+type Listener struct {
+	sub      *pubsub.Subscription
+	reviewer Reviewer
+	// mtx serializes sink writes, Receive handles messages concurrently and sinks are not safe for
+	// concurrent use.
+	mtx   sync.Mutex
+	sinks []export.ResultSink
+}
+
+// NewListener returns a Listener that reviews the assets received on sub with reviewer.
+func NewListener(sub *pubsub.Subscription, reviewer Reviewer, opts ...Option) *Listener {
+	l := &Listener{
+		sub:      sub,
+		reviewer: reviewer,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Run receives and reviews feed messages until ctx is done or receiving fails.  Messages that can't
+// be parsed or reviewed are logged and acknowledged since redelivery would fail the same way,
+// messages whose violations could not be written to a sink are redelivered.
+func (l *Listener) Run(ctx context.Context) error {
+	return l.sub.Receive(ctx, func(ctx context.Context, m *pubsub.Message) {
+		if err := l.handle(ctx, m.Data); err != nil {
+			glog.Errorf("failed to handle feed message %s: %v", m.ID, err)
+			m.Nack()
+			return
+		}
+		m.Ack()
+	})
+}
+
+// handle reviews the asset in a feed message and writes its violations to the sinks.  Only sink
+// errors are returned.
+func (l *Listener) handle(ctx context.Context, data []byte) error {
+	asset, err := ParseMessage(data)
+	if errors.Is(err, ErrDeleted) {
+		glog.V(2).Infof("skipping deleted asset")
+		return nil
+	}
+	if err != nil {
+		glog.Errorf("dropping feed message: %v", err)
+		return nil
+	}
+	violations, err := l.reviewer.ReviewAsset(ctx, asset)
+	if err != nil {
+		glog.Errorf("failed to review %s: %v", asset.GetName(), err)
+		return nil
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	constraints := make([]string, 0, len(violations))
+	for _, v := range violations {
+		constraints = append(constraints, v.GetConstraint())
+	}
+	glog.Infof("%s violates %s", asset.GetName(), strings.Join(constraints, ", "))
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return export.NewMultiSink(l.sinks...).WriteViolations(ctx, violations)
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package feed
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+)
+
+const bucketMessage = `{
+  "asset": {
+    "name": "//storage.googleapis.com/my-bucket",
+    "assetType": "storage.googleapis.com/Bucket",
+    "ancestors": ["projects/123", "organizations/456"],
+    "resource": {
+      "version": "v1",
+      "discoveryName": "Bucket",
+      "parent": "//cloudresourcemanager.googleapis.com/projects/123",
+      "data": {"name": "my-bucket"}
+    },
+    "osInventory": {}
+  },
+  "priorAssetState": "PRESENT",
+  "window": {"startTime": "2023-06-01T00:00:00Z"}
+}`
+
+func TestParseMessage(t *testing.T) {
+	asset, err := ParseMessage([]byte(bucketMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.GetName() != "//storage.googleapis.com/my-bucket" || asset.GetAssetType() != "storage.googleapis.com/Bucket" {
+		t.Errorf("unexpected asset %v", asset)
+	}
+	if got := asset.GetResource().GetData().GetFields()["name"].GetStringValue(); got != "my-bucket" {
+		t.Errorf("got resource name %q, want my-bucket", got)
+	}
+
+	if _, err := ParseMessage([]byte(`{"asset": {"name": "x"}, "deleted": true}`)); !errors.Is(err, ErrDeleted) {
+		t.Errorf("got error %v, want %v", err, ErrDeleted)
+	}
+	if _, err := ParseMessage([]byte(`{"window": {}}`)); err == nil {
+		t.Errorf("expected error for message without asset")
+	}
+}
+
+type fakeReviewer struct {
+	violations []*validator.Violation
+	err        error
+}
+
+func (r *fakeReviewer) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error) {
+	return r.violations, r.err
+}
+
+type fakeSink struct {
+	violations []*validator.Violation
+	err        error
+}
+
+func (s *fakeSink) WriteResults(ctx context.Context, results []*gcv.Result) error {
+	return errors.New("unexpected call to WriteResults")
+}
+
+func (s *fakeSink) WriteViolations(ctx context.Context, violations []*validator.Violation) error {
+	s.violations = append(s.violations, violations...)
+	return s.err
+}
+
+func (s *fakeSink) Close() error {
+	return nil
+}
+
+func TestHandle(t *testing.T) {
+	violation := &validator.Violation{
+		Constraint: "GCPStorageLoggingConstraint.require-storage-logging",
+		Resource:   "//storage.googleapis.com/my-bucket",
+	}
+	testCases := []struct {
+		name           string
+		message        string
+		reviewer       *fakeReviewer
+		sinkErr        error
+		wantErr        bool
+		wantViolations int
+	}{
+		{
+			name:           "violations written",
+			message:        bucketMessage,
+			reviewer:       &fakeReviewer{violations: []*validator.Violation{violation}},
+			wantViolations: 1,
+		},
+		{
+			name:     "deleted asset skipped",
+			message:  `{"asset": {"name": "x"}, "deleted": true}`,
+			reviewer: &fakeReviewer{violations: []*validator.Violation{violation}},
+		},
+		{
+			name:     "invalid message dropped",
+			message:  `not json`,
+			reviewer: &fakeReviewer{violations: []*validator.Violation{violation}},
+		},
+		{
+			name:     "review error dropped",
+			message:  bucketMessage,
+			reviewer: &fakeReviewer{err: errors.New("invalid asset")},
+		},
+		{
+			name:           "sink error returned",
+			message:        bucketMessage,
+			reviewer:       &fakeReviewer{violations: []*validator.Violation{violation}},
+			sinkErr:        errors.New("sink unavailable"),
+			wantErr:        true,
+			wantViolations: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := &fakeSink{err: tc.sinkErr}
+			l := NewListener(nil, tc.reviewer, Sink(sink))
+			err := l.handle(context.Background(), []byte(tc.message))
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if len(sink.violations) != tc.wantViolations {
+				t.Errorf("got %d violations written, want %d", len(sink.violations), tc.wantViolations)
+			}
+		})
+	}
+}
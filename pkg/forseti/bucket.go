@@ -0,0 +1,78 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forseti
+
+import (
+	"fmt"
+
+	"github.com/ghodss/yaml"
+)
+
+// BucketACLKind is the kind of the constraints converted from bucket rules.
+const BucketACLKind = "GCPForsetiBucketACLConstraintV1"
+
+const bucketAssetType = "storage.googleapis.com/Bucket"
+
+type bucketRules struct {
+	Rules []bucketRule `json:"rules"`
+}
+
+// bucketRule flags the ACL entries of buckets that match all of its patterns.
+type bucketRule struct {
+	Name   string `json:"name"`
+	Bucket string `json:"bucket"`
+	Entity string `json:"entity"`
+	Email  string `json:"email"`
+	Domain string `json:"domain"`
+	Role   string `json:"role"`
+	// Resource are the projects, by ID, the rule applies to.
+	Resource []resource `json:"resource"`
+}
+
+// convertBucketRules converts each resource of a bucket rule to a constraint.
+func (c *converter) convertBucketRules(data []byte) error {
+	var rules bucketRules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("failed to parse bucket rules: %w", err)
+	}
+	for _, rule := range rules.Rules {
+		parameters := map[string]interface{}{
+			"bucket": glob(rule.Bucket),
+			"entity": glob(rule.Entity),
+			"email":  glob(rule.Email),
+			"domain": glob(rule.Domain),
+			"role":   glob(rule.Role),
+		}
+		resources := rule.Resource
+		if len(resources) == 0 {
+			resources = []resource{{ResourceIDs: []string{"*"}}}
+		}
+		for _, r := range resources {
+			if r.Type == "" {
+				r.Type = "project"
+			}
+			r.AppliesTo = "children"
+			match, err := c.match(rule.Name, r)
+			if err != nil {
+				c.warnf("bucket rule %q: skipped resource, %v", rule.Name, err)
+				continue
+			}
+			delete(match, "excludedAssetTypes")
+			match["assetTypes"] = []interface{}{bucketAssetType}
+			c.addConstraint(BucketRules, rule.Name, BucketACLKind, match, parameters)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forseti
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ghodss/yaml"
+)
+
+// FirewallKind is the kind of the constraints converted from firewall rules.
+const FirewallKind = "GCPForsetiFirewallConstraintV1"
+
+const firewallAssetType = "compute.googleapis.com/Firewall"
+
+type firewallRules struct {
+	Rules      []firewallRule      `json:"rules"`
+	RuleGroups []firewallRuleGroup `json:"rule_groups"`
+	OrgPolicy  struct {
+		Resources []firewallResource `json:"resources"`
+	} `json:"org_policy"`
+}
+
+// firewallRule checks the firewalls that match any of MatchPolicies against VerifyPolicies.  In
+// blacklist mode firewalls must not cover any verify policy, in whitelist mode they must be contained
+// in one.
+type firewallRule struct {
+	RuleID         string                   `json:"rule_id"`
+	Mode           string                   `json:"mode"`
+	MatchPolicies  []map[string]interface{} `json:"match_policies"`
+	VerifyPolicies []map[string]interface{} `json:"verify_policies"`
+}
+
+type firewallRuleGroup struct {
+	GroupID string   `json:"group_id"`
+	RuleIDs []string `json:"rule_ids"`
+}
+
+// firewallResource applies rules, directly or by group, to resources and their children.
+type firewallResource struct {
+	Type        string   `json:"type"`
+	ResourceIDs []string `json:"resource_ids"`
+	Rules       struct {
+		RuleIDs  []string `json:"rule_ids"`
+		GroupIDs []string `json:"group_ids"`
+	} `json:"rules"`
+}
+
+// firewallModes maps the Forseti firewall rule modes to the modes of the firewall template.
+var firewallModes = map[string]string{
+	"blacklist": "denylist",
+	"whitelist": "allowlist",
+}
+
+// firewallListFields are the firewall fields holding lists of globs or CIDR ranges.
+var firewallListFields = map[string]bool{
+	"sourceRanges":          true,
+	"destinationRanges":     true,
+	"sourceTags":            true,
+	"targetTags":            true,
+	"sourceServiceAccounts": true,
+	"targetServiceAccounts": true,
+}
+
+// convertFirewallRules converts each resource a firewall rule applies to, according to the
+// org_policy section, to a constraint.  Without an org_policy section rules apply to every resource.
+func (c *converter) convertFirewallRules(data []byte) error {
+	var rules firewallRules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("failed to parse firewall rules: %w", err)
+	}
+
+	groups := map[string][]string{}
+	for _, group := range rules.RuleGroups {
+		groups[group.GroupID] = append(groups[group.GroupID], group.RuleIDs...)
+	}
+	resources := map[string][]resource{}
+	for _, r := range rules.OrgPolicy.Resources {
+		ruleIDs := append([]string(nil), r.Rules.RuleIDs...)
+		for _, groupID := range r.Rules.GroupIDs {
+			ruleIDs = append(ruleIDs, groups[groupID]...)
+		}
+		for _, ruleID := range ruleIDs {
+			resources[ruleID] = append(resources[ruleID], resource{Type: r.Type, ResourceIDs: r.ResourceIDs})
+		}
+	}
+
+	for _, rule := range rules.Rules {
+		mode, ok := firewallModes[rule.Mode]
+		if !ok {
+			c.warnf("firewall rule %q: skipped, unsupported mode %q", rule.RuleID, rule.Mode)
+			continue
+		}
+		matchPolicies, err := c.firewallPolicies(rule.RuleID, rule.MatchPolicies)
+		if err != nil {
+			c.warnf("firewall rule %q: skipped, %v", rule.RuleID, err)
+			continue
+		}
+		verifyPolicies, err := c.firewallPolicies(rule.RuleID, rule.VerifyPolicies)
+		if err != nil {
+			c.warnf("firewall rule %q: skipped, %v", rule.RuleID, err)
+			continue
+		}
+		parameters := map[string]interface{}{
+			"mode":   mode,
+			"match":  matchPolicies,
+			"verify": verifyPolicies,
+		}
+
+		ruleResources, ok := resources[rule.RuleID]
+		if !ok {
+			if len(rules.OrgPolicy.Resources) != 0 {
+				c.warnf("firewall rule %q: skipped, not applied to any resource in org_policy", rule.RuleID)
+				continue
+			}
+			ruleResources = []resource{{Type: "organization", ResourceIDs: []string{"*"}}}
+		}
+		for _, r := range ruleResources {
+			match, err := c.match(rule.RuleID, r)
+			if err != nil {
+				c.warnf("firewall rule %q: skipped resource, %v", rule.RuleID, err)
+				continue
+			}
+			match["assetTypes"] = []interface{}{firewallAssetType}
+			c.addConstraint(FirewallRules, rule.RuleID, FirewallKind, match, parameters)
+		}
+	}
+	return nil
+}
+
+// firewallPolicies converts Forseti firewall policies to the policies of the firewall template.
+// Fields the template doesn't check are dropped with a warning.
+func (c *converter) firewallPolicies(ruleID string, policies []map[string]interface{}) ([]interface{}, error) {
+	ret := []interface{}{}
+	for _, policy := range policies {
+		converted := map[string]interface{}{}
+		for _, field := range sortedFields(policy) {
+			value := policy[field]
+			switch {
+			case field == "direction":
+				direction, ok := value.(string)
+				if !ok {
+					return nil, fmt.Errorf("direction must be a string, got %v", value)
+				}
+				converted[field] = direction
+			case firewallListFields[field]:
+				strs, err := stringList(field, value)
+				if err != nil {
+					return nil, err
+				}
+				converted[field] = toInterfaces(strs)
+			case field == "allowed" || field == "denied":
+				entries, err := firewallEntries(field, value)
+				if err != nil {
+					return nil, err
+				}
+				converted[field] = entries
+			default:
+				c.warnf("firewall rule %q: field %q is not converted", ruleID, field)
+			}
+		}
+		ret = append(ret, converted)
+	}
+	return ret, nil
+}
+
+// firewallEntries converts a Forseti list of allowed or denied entries, where "*" is any protocol on
+// any port.
+func firewallEntries(field string, value interface{}) ([]interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list, got %v", field, value)
+	}
+	var entries []interface{}
+	for _, item := range list {
+		if item == "*" {
+			entries = append(entries, map[string]interface{}{"IPProtocol": "all"})
+			continue
+		}
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s entries must be \"*\" or objects, got %v", field, item)
+		}
+		protocol, ok := entry["IPProtocol"].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s entry %v missing IPProtocol", field, item)
+		}
+		converted := map[string]interface{}{"IPProtocol": protocol}
+		if ports, found := entry["ports"]; found {
+			strs, err := stringList(field+" ports", ports)
+			if err != nil {
+				return nil, err
+			}
+			converted["ports"] = toInterfaces(strs)
+		}
+		entries = append(entries, converted)
+	}
+	return entries, nil
+}
+
+// stringList converts a YAML list of strings or numbers, such as ports, to strings.
+func stringList(field string, value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list, got %v", field, value)
+	}
+	strs := make([]string, len(list))
+	for idx, item := range list {
+		switch item := item.(type) {
+		case string:
+			strs[idx] = item
+		case float64:
+			strs[idx] = fmt.Sprint(item)
+		default:
+			return nil, fmt.Errorf("%s must be a list of strings, got %v", field, value)
+		}
+	}
+	return strs, nil
+}
+
+func sortedFields(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
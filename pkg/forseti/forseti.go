@@ -0,0 +1,317 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package forseti converts legacy Forseti scanner rules to constraint templates and constraints.
+//
+// The bucket ACL, firewall and IAM scanner rules are supported.  Each rule becomes one or more
+// constraints of a template provided by this package, scoped with spec.match to the resources the
+// rule applied to.  Parts of rules that have no equivalent are reported as warnings.
+package forseti
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// RuleAnnotation is set on converted constraints to the name of the Forseti rule they came from.
+const RuleAnnotation = configs.GCPTargetName + "/forsetiRule"
+
+// RuleType is the type of a Forseti rule file, named after the file's conventional name.
+type RuleType string
+
+const (
+	BucketRules   RuleType = "bucket_rules"
+	FirewallRules RuleType = "firewall_rules"
+	IAMRules      RuleType = "iam_rules"
+)
+
+// RuleTypeOf returns the type of the Forseti rule file at path from its name, eg iam_rules.yaml.
+func RuleTypeOf(path string) (RuleType, bool) {
+	base := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".yaml"), ".yml")
+	switch ruleType := RuleType(base); ruleType {
+	case BucketRules, FirewallRules, IAMRules:
+		return ruleType, true
+	}
+	return "", false
+}
+
+// Option configures a conversion.
+type Option func(*converter)
+
+// ProjectNumbers sets the project numbers of project IDs.  Forseti rules refer to projects by ID while
+// ancestry paths use project numbers, so project IDs are replaced with their number in spec.match.
+// Project IDs without a number are kept and reported in a warning.
+func ProjectNumbers(projectNumbers map[string]string) Option {
+	return func(c *converter) {
+		c.projectNumbers = projectNumbers
+	}
+}
+
+// Conversion is the result of converting Forseti rules.
+type Conversion struct {
+	// Constraints are the converted rules.
+	Constraints []*unstructured.Unstructured
+	// Warnings describe the rules, or parts of rules, that were not converted.
+	Warnings []string
+}
+
+// PolicyFiles returns the constraints, and the templates they use, as YAML policy files, with
+// templates under templates/ and constraints under constraints/.
+func (c *Conversion) PolicyFiles() ([]*configs.PolicyFile, error) {
+	kinds := map[string]bool{}
+	for _, constraint := range c.Constraints {
+		kinds[constraint.GetKind()] = true
+	}
+	var files []*configs.PolicyFile
+	for _, kind := range sortedKeys(kinds) {
+		files = append(files, &configs.PolicyFile{
+			Path:    filepath.Join("templates", strings.ToLower(kind)+".yaml"),
+			Content: []byte(strings.TrimPrefix(templates[kind], "\n")),
+		})
+	}
+	for _, constraint := range c.Constraints {
+		content, err := yaml.Marshal(constraint.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal constraint %s: %w", constraint.GetName(), err)
+		}
+		files = append(files, &configs.PolicyFile{
+			Path:    filepath.Join("constraints", constraint.GetName()+".yaml"),
+			Content: content,
+		})
+	}
+	return files, nil
+}
+
+// Convert converts the rules of a Forseti rule file.
+func Convert(ruleType RuleType, data []byte, opts ...Option) (*Conversion, error) {
+	c := newConverter(opts...)
+	if err := c.convert(ruleType, data); err != nil {
+		return nil, err
+	}
+	return &c.conversion, nil
+}
+
+// ConvertFiles converts the rules of Forseti rule files, the type of each is given by its name, see
+// RuleTypeOf.
+func ConvertFiles(paths []string, opts ...Option) (*Conversion, error) {
+	c := newConverter(opts...)
+	for _, path := range paths {
+		ruleType, ok := RuleTypeOf(path)
+		if !ok {
+			return nil, fmt.Errorf("unsupported Forseti rule file %s, expected one of %s.yaml, %s.yaml or %s.yaml",
+				path, BucketRules, FirewallRules, IAMRules)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.convert(ruleType, data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return &c.conversion, nil
+}
+
+type converter struct {
+	projectNumbers map[string]string
+	// names counts the uses of each constraint name so that every constraint has a unique name.
+	names      map[string]int
+	conversion Conversion
+}
+
+func newConverter(opts ...Option) *converter {
+	c := &converter{names: map[string]int{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *converter) convert(ruleType RuleType, data []byte) error {
+	switch ruleType {
+	case BucketRules:
+		return c.convertBucketRules(data)
+	case FirewallRules:
+		return c.convertFirewallRules(data)
+	case IAMRules:
+		return c.convertIAMRules(data)
+	}
+	return fmt.Errorf("unsupported Forseti rule type %q", ruleType)
+}
+
+func (c *converter) warnf(format string, args ...interface{}) {
+	c.conversion.Warnings = append(c.conversion.Warnings, fmt.Sprintf(format, args...))
+}
+
+// addConstraint adds a constraint of kind for the named Forseti rule, with copies of match and
+// parameters.
+func (c *converter) addConstraint(ruleType RuleType, ruleName, kind string, match, parameters map[string]interface{}) {
+	constraint := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1beta1",
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name": c.constraintName(ruleType, ruleName),
+			"annotations": map[string]interface{}{
+				RuleAnnotation: ruleName,
+			},
+		},
+		"spec": map[string]interface{}{
+			"severity":   "high",
+			"match":      runtime.DeepCopyJSON(match),
+			"parameters": runtime.DeepCopyJSON(parameters),
+		},
+	}}
+	c.conversion.Constraints = append(c.conversion.Constraints, constraint)
+}
+
+var nonNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// constraintName returns a unique, K8S compatible name for a constraint of the Forseti rule.
+func (c *converter) constraintName(ruleType RuleType, ruleName string) string {
+	prefix := "forseti-" + strings.TrimSuffix(string(ruleType), "_rules")
+	slug := strings.Trim(nonNameChars.ReplaceAllString(strings.ToLower(ruleName), "-"), "-")
+	if len(slug) > 200 {
+		slug = strings.TrimRight(slug[:200], "-")
+	}
+	name := prefix
+	if slug != "" {
+		name += "-" + slug
+	}
+	c.names[name]++
+	if n := c.names[name]; n > 1 {
+		return fmt.Sprintf("%s-%d", name, n)
+	}
+	return name
+}
+
+// resource is the scope of a Forseti rule.
+type resource struct {
+	// Type is one of organization, folder or project.
+	Type string `json:"type"`
+	// AppliesTo is one of self, children or self_and_children.
+	AppliesTo   string   `json:"applies_to"`
+	ResourceIDs []string `json:"resource_ids"`
+}
+
+// hierarchy maps the Forseti resource types that may scope a rule to their collection in ancestry
+// paths and their asset type.
+var hierarchy = map[string]struct {
+	collection string
+	assetType  string
+}{
+	"organization": {"organizations", "cloudresourcemanager.googleapis.com/Organization"},
+	"folder":       {"folders", "cloudresourcemanager.googleapis.com/Folder"},
+	"project":      {"projects", "cloudresourcemanager.googleapis.com/Project"},
+}
+
+// match returns the spec.match of a constraint scoped to the Forseti resource.  Ancestry paths of
+// resources include the resource itself, so resources are told apart from their children by asset
+// type.
+func (c *converter) match(ruleName string, r resource) (map[string]interface{}, error) {
+	h, ok := hierarchy[r.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported resource type %q", r.Type)
+	}
+	if len(r.ResourceIDs) == 0 {
+		return nil, fmt.Errorf("no resource_ids")
+	}
+
+	var self, children bool
+	switch r.AppliesTo {
+	case "self":
+		self = true
+	case "children":
+		children = true
+	case "", "self_and_children":
+		self, children = true, true
+	default:
+		return nil, fmt.Errorf("unsupported applies_to %q", r.AppliesTo)
+	}
+
+	var ancestries []string
+	for _, id := range r.ResourceIDs {
+		if id == "*" {
+			ancestries = []string{"**"}
+			break
+		}
+		if r.Type == "project" {
+			id = c.projectNumber(ruleName, id)
+		}
+		path := h.collection + "/" + id
+		ancestries = append(ancestries, path)
+		if r.Type != "organization" {
+			ancestries = append(ancestries, "**/"+path)
+		}
+		if children && r.Type != "project" {
+			ancestries = append(ancestries, path+"/**")
+			if r.Type != "organization" {
+				ancestries = append(ancestries, "**/"+path+"/**")
+			}
+		}
+	}
+
+	match := map[string]interface{}{"ancestries": toInterfaces(ancestries)}
+	switch {
+	case self && !children:
+		match["assetTypes"] = []interface{}{h.assetType}
+	case children && !self:
+		match["excludedAssetTypes"] = []interface{}{h.assetType}
+	}
+	return match, nil
+}
+
+// projectNumber returns the number of the project, or the ID if the number is not known.
+func (c *converter) projectNumber(ruleName, id string) string {
+	if number, ok := c.projectNumbers[id]; ok {
+		return number
+	}
+	if strings.Trim(id, "0123456789") != "" {
+		c.warnf("rule %q: ancestry paths use project numbers, project ID %q will only match if it is replaced with its number", ruleName, id)
+	}
+	return id
+}
+
+// glob returns the Forseti pattern, where an empty pattern matches everything.
+func glob(pattern string) string {
+	if pattern == "" {
+		return "*"
+	}
+	return pattern
+}
+
+func toInterfaces(strs []string) []interface{} {
+	ret := make([]interface{}, len(strs))
+	for idx, s := range strs {
+		ret[idx] = s
+	}
+	return ret
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,346 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forseti
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const iamRulesYAML = `
+rules:
+  - name: Owners must be company users
+    mode: whitelist
+    resource:
+      - type: organization
+        applies_to: self_and_children
+        resource_ids: ['1']
+    bindings:
+      - role: roles/owner
+        members: ['user:*@example.com']
+  - name: No public project access
+    mode: blacklist
+    resource:
+      - type: project
+        applies_to: self
+        resource_ids: ['123']
+    inherit_from_parents: true
+    bindings:
+      - role: 'roles/*'
+        members: [allUsers, allAuthenticatedUsers]
+  - name: Admins own projects
+    mode: required
+    resource:
+      - type: project
+        resource_ids: [my-project]
+    bindings:
+      - role: roles/owner
+        members: ['group:admins@example.com']
+  - name: Unsupported
+    mode: audit
+    resource:
+      - type: project
+        resource_ids: ['*']
+`
+
+const bucketRulesYAML = `
+rules:
+  - name: No public buckets
+    bucket: '*'
+    entity: AllUsers
+    email: '*'
+    domain: '*'
+    role: '*'
+    resource:
+      - resource_ids: ['*']
+`
+
+const firewallRulesYAML = `
+rules:
+  - rule_id: no-public-ssh
+    mode: blacklist
+    match_policies:
+      - direction: ingress
+        allowed: ['*']
+    verify_policies:
+      - sourceRanges: ['0.0.0.0/0']
+        allowed:
+          - IPProtocol: tcp
+            ports: [22]
+  - rule_id: internal-only
+    mode: whitelist
+    match_policies:
+      - direction: ingress
+    verify_policies:
+      - sourceRanges: ['10.0.0.0/8']
+  - rule_id: unused
+    mode: blacklist
+    verify_policies:
+      - allowed: ['*']
+  - rule_id: required-rule
+    mode: required
+rule_groups:
+  - group_id: default
+    rule_ids: [no-public-ssh]
+org_policy:
+  resources:
+    - type: organization
+      resource_ids: ['1']
+      rules:
+        group_ids: [default]
+        rule_ids: [internal-only]
+`
+
+const projectJSON = `{
+  "name": "//cloudresourcemanager.googleapis.com/projects/123",
+  "asset_type": "cloudresourcemanager.googleapis.com/Project",
+  "ancestors": ["projects/123", "organizations/1"],
+  "iam_policy": {
+    "bindings": [
+      {"role": "roles/owner", "members": ["user:alice@example.com", "user:eve@gmail.com"]},
+      {"role": "roles/viewer", "members": ["allUsers"]}
+    ]
+  }
+}`
+
+const bucketJSON = `{
+  "name": "//storage.googleapis.com/public-bucket",
+  "asset_type": "storage.googleapis.com/Bucket",
+  "ancestors": ["projects/123", "organizations/1"],
+  "resource": {
+    "data": {
+      "name": "public-bucket",
+      "acl": [
+        {"entity": "allUsers", "role": "READER"},
+        {"entity": "user-alice@example.com", "email": "alice@example.com", "role": "OWNER"}
+      ]
+    }
+  }
+}`
+
+const publicSSHFirewallJSON = `{
+  "name": "//compute.googleapis.com/projects/123/global/firewalls/allow-ssh",
+  "asset_type": "compute.googleapis.com/Firewall",
+  "ancestors": ["projects/123", "organizations/1"],
+  "resource": {
+    "data": {
+      "direction": "INGRESS",
+      "sourceRanges": ["0.0.0.0/0"],
+      "allowed": [{"IPProtocol": "tcp", "ports": ["22", "80"]}]
+    }
+  }
+}`
+
+const internalFirewallJSON = `{
+  "name": "//compute.googleapis.com/projects/123/global/firewalls/allow-internal",
+  "asset_type": "compute.googleapis.com/Firewall",
+  "ancestors": ["projects/123", "organizations/1"],
+  "resource": {
+    "data": {
+      "direction": "INGRESS",
+      "sourceRanges": ["10.1.0.0/16"],
+      "allowed": [{"IPProtocol": "all"}]
+    }
+  }
+}`
+
+func writeRules(t *testing.T, dir string, files map[RuleType]string) []string {
+	t.Helper()
+	var paths []string
+	for ruleType, content := range files {
+		path := filepath.Join(dir, string(ruleType)+".yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestConvertFiles(t *testing.T) {
+	paths := writeRules(t, t.TempDir(), map[RuleType]string{
+		IAMRules:      iamRulesYAML,
+		BucketRules:   bucketRulesYAML,
+		FirewallRules: firewallRulesYAML,
+	})
+	conversion, err := ConvertFiles(paths, ProjectNumbers(map[string]string{"my-project": "123"}))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	var names []string
+	for _, constraint := range conversion.Constraints {
+		names = append(names, constraint.GetName())
+	}
+	wantNames := []string{
+		"forseti-bucket-no-public-buckets",
+		"forseti-firewall-no-public-ssh",
+		"forseti-firewall-internal-only",
+		"forseti-iam-owners-must-be-company-users",
+		"forseti-iam-no-public-project-access",
+		"forseti-iam-admins-own-projects",
+	}
+	if diff := cmp.Diff(wantNames, names); diff != "" {
+		t.Errorf("constraint names diff (-want +got):\n%s", diff)
+	}
+	if got := len(conversion.Warnings); got != 4 {
+		t.Errorf("got warnings %q, want 4: unused, required-rule, inherit_from_parents and audit", conversion.Warnings)
+	}
+
+	files, err := conversion.PolicyFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := gcv.NewValidatorFromContents(files, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal("converted policies failed to load", err)
+	}
+
+	ctx := context.Background()
+	testCases := []struct {
+		name      string
+		assetJSON string
+		want      []string
+	}{
+		{
+			name:      "project",
+			assetJSON: projectJSON,
+			want: []string{
+				"forseti-iam-admins-own-projects",
+				"forseti-iam-no-public-project-access",
+				"forseti-iam-owners-must-be-company-users",
+			},
+		},
+		{
+			name:      "bucket",
+			assetJSON: bucketJSON,
+			want:      []string{"forseti-bucket-no-public-buckets"},
+		},
+		{
+			name:      "public ssh firewall",
+			assetJSON: publicSSHFirewallJSON,
+			want:      []string{"forseti-firewall-internal-only", "forseti-firewall-no-public-ssh"},
+		},
+		{
+			name:      "internal firewall",
+			assetJSON: internalFirewallJSON,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := v.ReviewJSON(ctx, tc.assetJSON)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			var got []string
+			for _, cv := range result.ConstraintViolations {
+				got = append(got, cv.Constraint.GetName())
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("violated constraints diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConvertMatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		resource resource
+		want     map[string]interface{}
+	}{
+		{
+			name:     "organization self and children",
+			resource: resource{Type: "organization", ResourceIDs: []string{"1"}},
+			want: map[string]interface{}{
+				"ancestries": []interface{}{"organizations/1", "organizations/1/**"},
+			},
+		},
+		{
+			name:     "folder children",
+			resource: resource{Type: "folder", AppliesTo: "children", ResourceIDs: []string{"2"}},
+			want: map[string]interface{}{
+				"ancestries":         []interface{}{"folders/2", "**/folders/2", "folders/2/**", "**/folders/2/**"},
+				"excludedAssetTypes": []interface{}{"cloudresourcemanager.googleapis.com/Folder"},
+			},
+		},
+		{
+			name:     "any project",
+			resource: resource{Type: "project", AppliesTo: "self", ResourceIDs: []string{"3", "*"}},
+			want: map[string]interface{}{
+				"ancestries": []interface{}{"**"},
+				"assetTypes": []interface{}{"cloudresourcemanager.googleapis.com/Project"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := newConverter().match("rule", tc.resource)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("match diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := newConverter().match("rule", resource{Type: "bucket", ResourceIDs: []string{"b"}}); err == nil {
+		t.Errorf("expected error for bucket resource")
+	}
+}
+
+func TestConvertProjectIDWarning(t *testing.T) {
+	conversion, err := Convert(BucketRules, []byte(`
+rules:
+  - name: rule
+    entity: AllUsers
+    resource:
+      - resource_ids: [my-project]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conversion.Warnings) != 1 || !strings.Contains(conversion.Warnings[0], "my-project") {
+		t.Errorf("got warnings %q, want project ID warning", conversion.Warnings)
+	}
+	ancestries, _, _ := unstructured.NestedStringSlice(conversion.Constraints[0].Object, "spec", "match", "ancestries")
+	if diff := cmp.Diff([]string{"projects/my-project", "**/projects/my-project"}, ancestries); diff != "" {
+		t.Errorf("ancestries diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestRuleTypeOf(t *testing.T) {
+	for path, want := range map[string]RuleType{
+		"rules/iam_rules.yaml":     IAMRules,
+		"bucket_rules.yml":         BucketRules,
+		"/a/b/firewall_rules.yaml": FirewallRules,
+		"cloudsql_rules.yaml":      "",
+	} {
+		if got, _ := RuleTypeOf(path); got != want {
+			t.Errorf("RuleTypeOf(%s) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forseti
+
+import (
+	"fmt"
+
+	"github.com/ghodss/yaml"
+)
+
+// IAMBindingsKind is the kind of the constraints converted from IAM rules.
+const IAMBindingsKind = "GCPForsetiIAMBindingsConstraintV1"
+
+type iamRules struct {
+	Rules []iamRule `json:"rules"`
+}
+
+type iamRule struct {
+	Name string `json:"name"`
+	// Mode is one of whitelist, blacklist or required.
+	Mode               string       `json:"mode"`
+	Resource           []resource   `json:"resource"`
+	InheritFromParents bool         `json:"inherit_from_parents"`
+	Bindings           []iamBinding `json:"bindings"`
+}
+
+type iamBinding struct {
+	Role    string   `json:"role"`
+	Members []string `json:"members"`
+}
+
+// iamModes maps the Forseti IAM rule modes to the modes of the IAM bindings template.
+var iamModes = map[string]string{
+	"whitelist": "allowlist",
+	"blacklist": "denylist",
+	"required":  "required",
+}
+
+// convertIAMRules converts each binding of each resource of an IAM rule to a constraint.
+func (c *converter) convertIAMRules(data []byte) error {
+	var rules iamRules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("failed to parse IAM rules: %w", err)
+	}
+	for _, rule := range rules.Rules {
+		mode, ok := iamModes[rule.Mode]
+		if !ok {
+			c.warnf("IAM rule %q: skipped, unsupported mode %q", rule.Name, rule.Mode)
+			continue
+		}
+		if rule.InheritFromParents {
+			c.warnf("IAM rule %q: inherit_from_parents is not converted, only the bindings of each resource's own policy are checked", rule.Name)
+		}
+		for _, r := range rule.Resource {
+			match, err := c.match(rule.Name, r)
+			if err != nil {
+				c.warnf("IAM rule %q: skipped resource, %v", rule.Name, err)
+				continue
+			}
+			for _, binding := range rule.Bindings {
+				c.addConstraint(IAMRules, rule.Name, IAMBindingsKind, match, map[string]interface{}{
+					"mode":    mode,
+					"role":    glob(binding.Role),
+					"members": toInterfaces(binding.Members),
+				})
+			}
+		}
+	}
+	return nil
+}
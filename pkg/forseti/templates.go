@@ -0,0 +1,561 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forseti
+
+// templates are the constraint templates of the converted constraints by kind.  Forseti patterns are
+// shell globs where * matches any characters, so they are matched with glob.match without
+// delimiters.
+var templates = map[string]string{
+	IAMBindingsKind: iamBindingsTemplate,
+	BucketACLKind:   bucketACLTemplate,
+	FirewallKind:    firewallTemplate,
+}
+
+// iamBindingsTemplate checks the members granted a role in IAM policies.  In allowlist mode every
+// member of the role must match a member pattern, in denylist mode none may, and in required mode each
+// member pattern must match a member of the role.
+const iamBindingsTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpforsetiiambindingsconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPForsetiIAMBindingsConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: object
+          properties:
+            mode:
+              type: string
+              enum: [allowlist, denylist, required]
+            role:
+              type: string
+            members:
+              type: array
+              items:
+                type: string
+  targets:
+    - target: validation.gcp.forsetisecurity.org
+      rego: |
+        package templates.gcp.GCPForsetiIAMBindingsConstraintV1
+
+        violation[{"msg": message, "details": metadata}] {
+        	input.parameters.mode == "allowlist"
+        	binding := input.review.iam_policy.bindings[_]
+        	glob.match(input.parameters.role, null, binding.role)
+        	member := binding.members[_]
+        	not matches_any(input.parameters.members, member)
+        	message := sprintf("%v grants %v to %v, which is not allowed", [input.review.name, binding.role, member])
+        	metadata := {"resource": input.review.name, "role": binding.role, "member": member}
+        }
+
+        violation[{"msg": message, "details": metadata}] {
+        	input.parameters.mode == "denylist"
+        	binding := input.review.iam_policy.bindings[_]
+        	glob.match(input.parameters.role, null, binding.role)
+        	member := binding.members[_]
+        	matches_any(input.parameters.members, member)
+        	message := sprintf("%v grants %v to %v, which is denied", [input.review.name, binding.role, member])
+        	metadata := {"resource": input.review.name, "role": binding.role, "member": member}
+        }
+
+        violation[{"msg": message, "details": metadata}] {
+        	input.parameters.mode == "required"
+        	input.review.iam_policy
+        	member := input.parameters.members[_]
+        	not has_binding(input.parameters.role, member)
+        	message := sprintf("%v does not grant %v to %v, which is required", [input.review.name, input.parameters.role, member])
+        	metadata := {"resource": input.review.name, "role": input.parameters.role, "member": member}
+        }
+
+        matches_any(patterns, value) {
+        	glob.match(patterns[_], null, value)
+        }
+
+        has_binding(role, member) {
+        	binding := input.review.iam_policy.bindings[_]
+        	glob.match(role, null, binding.role)
+        	glob.match(member, null, binding.members[_])
+        }
+`
+
+// bucketACLTemplate flags the ACL entries of buckets that match every pattern.  Entities and roles
+// are matched case insensitively since Forseti rules use AllUsers where CAI has allUsers.
+const bucketACLTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpforsetibucketaclconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPForsetiBucketACLConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: object
+          properties:
+            bucket:
+              type: string
+            entity:
+              type: string
+            email:
+              type: string
+            domain:
+              type: string
+            role:
+              type: string
+  targets:
+    - target: validation.gcp.forsetisecurity.org
+      rego: |
+        package templates.gcp.GCPForsetiBucketACLConstraintV1
+
+        violation[{"msg": message, "details": metadata}] {
+        	bucket := input.review.resource.data
+        	glob.match(input.parameters.bucket, null, bucket.name)
+        	acl := bucket.acl[_]
+        	matches(input.parameters.entity, acl, "entity")
+        	matches(input.parameters.email, acl, "email")
+        	matches(input.parameters.domain, acl, "domain")
+        	matches(input.parameters.role, acl, "role")
+        	message := sprintf("%v grants %v to %v", [input.review.name, acl.role, acl.entity])
+        	metadata := {"resource": input.review.name, "entity": acl.entity, "role": acl.role}
+        }
+
+        matches(pattern, acl, field) {
+        	pattern == "*"
+        }
+
+        matches(pattern, acl, field) {
+        	pattern != "*"
+        	glob.match(lower(pattern), null, lower(object.get(acl, field, "")))
+        }
+`
+
+// firewallTemplate checks firewalls that overlap any match policy, or every firewall if there are
+// none.  In denylist mode the firewall must not cover any verify policy, in allowlist mode it must be
+// contained in one.  For each field set in the policy, a firewall overlaps the policy if they share a
+// value, covers it if the firewall includes all of the policy's values and is contained in it if the
+// policy includes all of the firewall's values.  "*" in a list field matches any value.
+const firewallTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpforsetifirewallconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPForsetiFirewallConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: object
+          properties:
+            mode:
+              type: string
+              enum: [allowlist, denylist]
+            match:
+              type: array
+              items:
+                type: object
+                properties:
+                  direction:
+                    type: string
+                  sourceRanges:
+                    type: array
+                    items:
+                      type: string
+                  destinationRanges:
+                    type: array
+                    items:
+                      type: string
+                  sourceTags:
+                    type: array
+                    items:
+                      type: string
+                  targetTags:
+                    type: array
+                    items:
+                      type: string
+                  sourceServiceAccounts:
+                    type: array
+                    items:
+                      type: string
+                  targetServiceAccounts:
+                    type: array
+                    items:
+                      type: string
+                  allowed:
+                    type: array
+                    items:
+                      type: object
+                      properties:
+                        IPProtocol:
+                          type: string
+                        ports:
+                          type: array
+                          items:
+                            type: string
+                  denied:
+                    type: array
+                    items:
+                      type: object
+                      properties:
+                        IPProtocol:
+                          type: string
+                        ports:
+                          type: array
+                          items:
+                            type: string
+            verify:
+              type: array
+              items:
+                type: object
+                properties:
+                  direction:
+                    type: string
+                  sourceRanges:
+                    type: array
+                    items:
+                      type: string
+                  destinationRanges:
+                    type: array
+                    items:
+                      type: string
+                  sourceTags:
+                    type: array
+                    items:
+                      type: string
+                  targetTags:
+                    type: array
+                    items:
+                      type: string
+                  sourceServiceAccounts:
+                    type: array
+                    items:
+                      type: string
+                  targetServiceAccounts:
+                    type: array
+                    items:
+                      type: string
+                  allowed:
+                    type: array
+                    items:
+                      type: object
+                      properties:
+                        IPProtocol:
+                          type: string
+                        ports:
+                          type: array
+                          items:
+                            type: string
+                  denied:
+                    type: array
+                    items:
+                      type: object
+                      properties:
+                        IPProtocol:
+                          type: string
+                        ports:
+                          type: array
+                          items:
+                            type: string
+  targets:
+    - target: validation.gcp.forsetisecurity.org
+      rego: |
+        package templates.gcp.GCPForsetiFirewallConstraintV1
+
+        tag_fields := ["sourceTags", "targetTags", "sourceServiceAccounts", "targetServiceAccounts"]
+
+        range_fields := ["sourceRanges", "destinationRanges"]
+
+        rule_fields := ["allowed", "denied"]
+
+        violation[{"msg": message, "details": metadata}] {
+        	input.parameters.mode == "denylist"
+        	firewall := input.review.resource.data
+        	in_scope(firewall)
+        	covers(firewall, input.parameters.verify[_])
+        	message := sprintf("%v covers a denied firewall policy", [input.review.name])
+        	metadata := {"resource": input.review.name}
+        }
+
+        violation[{"msg": message, "details": metadata}] {
+        	input.parameters.mode == "allowlist"
+        	firewall := input.review.resource.data
+        	in_scope(firewall)
+        	not allowed(firewall)
+        	message := sprintf("%v is not contained in any allowed firewall policy", [input.review.name])
+        	metadata := {"resource": input.review.name}
+        }
+
+        in_scope(firewall) {
+        	count(object.get(input.parameters, "match", [])) == 0
+        }
+
+        in_scope(firewall) {
+        	overlaps(firewall, input.parameters.match[_])
+        }
+
+        allowed(firewall) {
+        	contained(firewall, input.parameters.verify[_])
+        }
+
+        direction(firewall) = d {
+        	d := upper(object.get(firewall, "direction", "INGRESS"))
+        }
+
+        direction_mismatch(firewall, policy) {
+        	policy.direction != "*"
+        	upper(policy.direction) != direction(firewall)
+        }
+
+        glob_any(patterns, value) {
+        	glob.match(patterns[_], null, value)
+        }
+
+        port_range(port) = [lo, hi] {
+        	parts := split(port, "-")
+        	lo := to_number(parts[0])
+        	hi := to_number(parts[count(parts) - 1])
+        }
+
+        # overlaps
+
+        overlaps(firewall, policy) {
+        	not overlap_mismatch(firewall, policy)
+        }
+
+        overlap_mismatch(firewall, policy) {
+        	direction_mismatch(firewall, policy)
+        }
+
+        overlap_mismatch(firewall, policy) {
+        	field := tag_fields[_]
+        	patterns := policy[field]
+        	not tags_overlap(patterns, object.get(firewall, field, []))
+        }
+
+        overlap_mismatch(firewall, policy) {
+        	field := range_fields[_]
+        	ranges := policy[field]
+        	not ranges_overlap(ranges, object.get(firewall, field, []))
+        }
+
+        overlap_mismatch(firewall, policy) {
+        	field := rule_fields[_]
+        	specs := policy[field]
+        	not rules_overlap(specs, object.get(firewall, field, []))
+        }
+
+        tags_overlap(patterns, values) {
+        	glob_any(patterns, values[_])
+        }
+
+        ranges_overlap(ranges, values) {
+        	ranges[_] == "*"
+        	count(values) > 0
+        }
+
+        ranges_overlap(ranges, values) {
+        	r := ranges[_]
+        	r != "*"
+        	net.cidr_intersects(r, values[_])
+        }
+
+        rules_overlap(specs, rules) {
+        	spec := specs[_]
+        	rule := rules[_]
+        	protocols_overlap(spec, rule)
+        	ports_overlap(object.get(spec, "ports", []), object.get(rule, "ports", []))
+        }
+
+        protocols_overlap(spec, rule) {
+        	lower(spec.IPProtocol) == "all"
+        }
+
+        protocols_overlap(spec, rule) {
+        	lower(rule.IPProtocol) == "all"
+        }
+
+        protocols_overlap(spec, rule) {
+        	lower(spec.IPProtocol) == lower(rule.IPProtocol)
+        }
+
+        ports_overlap(a, b) {
+        	count(a) == 0
+        }
+
+        ports_overlap(a, b) {
+        	count(b) == 0
+        }
+
+        ports_overlap(a, b) {
+        	x := port_range(a[_])
+        	y := port_range(b[_])
+        	x[0] <= y[1]
+        	y[0] <= x[1]
+        }
+
+        # coverage
+
+        covers(firewall, policy) {
+        	not cover_mismatch(firewall, policy)
+        }
+
+        cover_mismatch(firewall, policy) {
+        	direction_mismatch(firewall, policy)
+        }
+
+        cover_mismatch(firewall, policy) {
+        	field := tag_fields[_]
+        	pattern := policy[field][_]
+        	not tag_covered(pattern, object.get(firewall, field, []))
+        }
+
+        cover_mismatch(firewall, policy) {
+        	field := range_fields[_]
+        	r := policy[field][_]
+        	not range_covered(r, object.get(firewall, field, []))
+        }
+
+        cover_mismatch(firewall, policy) {
+        	field := rule_fields[_]
+        	spec := policy[field][_]
+        	not rule_covered(spec, object.get(firewall, field, []))
+        }
+
+        tag_covered(pattern, values) {
+        	glob.match(pattern, null, values[_])
+        }
+
+        range_covered(r, values) {
+        	r == "*"
+        	count(values) > 0
+        }
+
+        range_covered(r, values) {
+        	r != "*"
+        	net.cidr_contains(values[_], r)
+        }
+
+        rule_covered(spec, rules) {
+        	rule := rules[_]
+        	protocol_contains(rule, spec)
+        	ports_contained(object.get(rule, "ports", []), object.get(spec, "ports", []))
+        }
+
+        # containment
+
+        contained(firewall, policy) {
+        	not containment_mismatch(firewall, policy)
+        }
+
+        containment_mismatch(firewall, policy) {
+        	direction_mismatch(firewall, policy)
+        }
+
+        containment_mismatch(firewall, policy) {
+        	field := tag_fields[_]
+        	patterns := policy[field]
+        	not tags_contained(patterns, object.get(firewall, field, []))
+        }
+
+        containment_mismatch(firewall, policy) {
+        	field := range_fields[_]
+        	ranges := policy[field]
+        	not ranges_contained(ranges, object.get(firewall, field, []))
+        }
+
+        containment_mismatch(firewall, policy) {
+        	field := rule_fields[_]
+        	specs := policy[field]
+        	rule := object.get(firewall, field, [])[_]
+        	not rule_contained(specs, rule)
+        }
+
+        tags_contained(patterns, values) {
+        	patterns[_] == "*"
+        }
+
+        tags_contained(patterns, values) {
+        	count(values) > 0
+        	not tag_outside(patterns, values)
+        }
+
+        tag_outside(patterns, values) {
+        	value := values[_]
+        	not glob_any(patterns, value)
+        }
+
+        ranges_contained(ranges, values) {
+        	ranges[_] == "*"
+        }
+
+        ranges_contained(ranges, values) {
+        	count(values) > 0
+        	not range_outside(ranges, values)
+        }
+
+        range_outside(ranges, values) {
+        	value := values[_]
+        	not range_within(ranges, value)
+        }
+
+        range_within(ranges, value) {
+        	r := ranges[_]
+        	r != "*"
+        	net.cidr_contains(r, value)
+        }
+
+        rule_contained(specs, rule) {
+        	spec := specs[_]
+        	protocol_contains(spec, rule)
+        	ports_contained(object.get(spec, "ports", []), object.get(rule, "ports", []))
+        }
+
+        # protocol_contains is true if the outer entry's protocol includes the inner entry's.
+        protocol_contains(outer, inner) {
+        	lower(outer.IPProtocol) == "all"
+        }
+
+        protocol_contains(outer, inner) {
+        	lower(outer.IPProtocol) == lower(inner.IPProtocol)
+        }
+
+        # ports_contained is true if the outer ports include the inner ports, no ports is every port.
+        ports_contained(outer, inner) {
+        	count(outer) == 0
+        }
+
+        ports_contained(outer, inner) {
+        	count(inner) > 0
+        	not port_outside(outer, inner)
+        }
+
+        port_outside(outer, inner) {
+        	r := port_range(inner[_])
+        	not port_within(outer, r)
+        }
+
+        port_within(outer, r) {
+        	s := port_range(outer[_])
+        	s[0] <= r[0]
+        	r[1] <= s[1]
+        }
+`
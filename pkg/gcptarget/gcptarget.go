@@ -16,19 +16,19 @@
 package gcptarget
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/config-validator/pkg/ancestry"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
 	asset2 "github.com/GoogleCloudPlatform/config-validator/pkg/asset"
+	"github.com/gobwas/glob"
 	"github.com/open-policy-agent/frameworks/constraint/pkg/core/constraints"
 	"github.com/open-policy-agent/frameworks/constraint/pkg/handler"
 	"github.com/open-policy-agent/frameworks/constraint/pkg/types"
-	"google.golang.org/protobuf/encoding/protojson"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
@@ -36,25 +36,49 @@ import (
 // Name is the target name for GCPTarget
 const Name = "validation.gcp.forsetisecurity.org"
 
+const (
+	// SamplingAnnotation is the constraint annotation that sets the fraction of assets, in (0, 1], the
+	// constraint is evaluated against when sampling is applied.
+	SamplingAnnotation = Name + "/sampling"
+)
+
+// SampledReview reviews Asset with sampling applied to the constraints with the SamplingAnnotation.
+// Sampling is requested outside of the asset so that assets can't turn it on themselves, and
+// templates don't see it in input.review.
+type SampledReview struct {
+	Asset map[string]interface{}
+}
+
 // GCPTarget is the constraint framework target for CAI asset data
 type GCPTarget struct {
+	// index selects the constraints that may match each review, nil disables it.
+	index *constraintIndex
 }
 
 var _ handler.TargetHandler = &GCPTarget{}
 
 // New returns a new GCPTarget
 func New() *GCPTarget {
-	return &GCPTarget{}
+	return &GCPTarget{index: newConstraintIndex()}
 }
 
 // ToMatcher converts .spec.match in mutators to Matcher.
 func (h *GCPTarget) ToMatcher(constraint *unstructured.Unstructured) (constraints.Matcher, error) {
+	sampleRate, err := samplingRate(constraint)
+	if err != nil {
+		return nil, err
+	}
 	match, ok, err := unstructured.NestedMap(constraint.Object, "spec", "match")
 	if err != nil {
 		return nil, fmt.Errorf("unable to get spec.match: %w", err)
 	}
 	if !ok {
-		return &matcher{ancestries: []string{"**"}, excludedAncestries: []string{}}, nil
+		return h.indexed(constraint, &matcher{
+			ancestries:         []string{"**"},
+			excludedAncestries: []string{},
+			constraintName:     constraint.GetName(),
+			sampleRate:         sampleRate,
+		}), nil
 	}
 
 	include, ok, err := unstructured.NestedStringSlice(match, "ancestries")
@@ -85,10 +109,103 @@ func (h *GCPTarget) ToMatcher(constraint *unstructured.Unstructured) (constraint
 		}
 	}
 
-	return &matcher{
-		ancestries:         include,
-		excludedAncestries: exclude,
-	}, nil
+	assetTypes, _, err := unstructured.NestedStringSlice(match, "assetTypes")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get string slice from spec.match.assetTypes: %w", err)
+	}
+	excludedAssetTypes, _, err := unstructured.NestedStringSlice(match, "excludedAssetTypes")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get string slice from spec.match.excludedAssetTypes: %w", err)
+	}
+
+	resourceLabels, err := labelSelectors(match, "resourceLabels")
+	if err != nil {
+		return nil, err
+	}
+	requiredTags, err := labelSelectors(match, "requiredTags")
+	if err != nil {
+		return nil, err
+	}
+	excludedTags, err := labelSelectors(match, "excludedTags")
+	if err != nil {
+		return nil, err
+	}
+
+	orgPolicyConstraints, _, err := unstructured.NestedStringSlice(match, "orgPolicyConstraints")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get string slice from spec.match.orgPolicyConstraints: %w", err)
+	}
+
+	return h.indexed(constraint, &matcher{
+		ancestries:           include,
+		excludedAncestries:   exclude,
+		assetTypes:           assetTypes,
+		excludedAssetTypes:   excludedAssetTypes,
+		resourceLabels:       resourceLabels,
+		requiredTags:         requiredTags,
+		excludedTags:         excludedTags,
+		orgPolicyConstraints: orgPolicyConstraints,
+		constraintName:       constraint.GetName(),
+		sampleRate:           sampleRate,
+	}), nil
+}
+
+// indexed adds the matcher of the constraint to the target's index.
+func (h *GCPTarget) indexed(constraint *unstructured.Unstructured, m *matcher) *matcher {
+	if h.index != nil {
+		m.index = h.index
+		m.key = constraint.GetKind() + "/" + constraint.GetName()
+		h.index.add(m.key, m)
+	}
+	return m
+}
+
+// labelSelectors returns the selectors from the spec.match field, eg resourceLabels or requiredTags.
+// Each selector has a key and an optional value, selectors without a value only require the label or
+// tag to exist.
+func labelSelectors(match map[string]interface{}, name string) ([]labelSelector, error) {
+	field, found, _ := unstructured.NestedFieldNoCopy(match, name)
+	if !found {
+		return nil, nil
+	}
+	items, ok := field.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("spec.match.%s must be a list", name)
+	}
+	var selectors []labelSelector
+	for idx, item := range items {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("spec.match.%s[%d] must be an object", name, idx)
+		}
+		key, found, err := unstructured.NestedString(itemMap, "key")
+		if err != nil || !found || key == "" {
+			return nil, fmt.Errorf("spec.match.%s[%d].key must be a non-empty string", name, idx)
+		}
+		value, hasValue, err := unstructured.NestedString(itemMap, "value")
+		if err != nil {
+			return nil, fmt.Errorf("spec.match.%s[%d].value must be a string", name, idx)
+		}
+		selectors = append(selectors, labelSelector{key: key, value: value, hasValue: hasValue})
+	}
+	return selectors, nil
+}
+
+// samplingRate returns the fraction of assets set in the constraint's sampling annotation, or 1 if
+// the constraint is not sampled.
+func samplingRate(constraint *unstructured.Unstructured) (float64, error) {
+	value, ok := constraint.GetAnnotations()[SamplingAnnotation]
+	if !ok {
+		return 1, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation %q: %w", SamplingAnnotation, value, err)
+	}
+	if rate <= 0 || rate > 1 {
+		return 0, fmt.Errorf("invalid %s annotation %q: must be greater than 0 and at most 1", SamplingAnnotation, value)
+	}
+	return rate, nil
 }
 
 // MatchSchema implements client.MatchSchemaProvider
@@ -128,6 +245,50 @@ func (g *GCPTarget) MatchSchema() apiextensions.JSONSchemaProps {
 					},
 				},
 			},
+			"assetTypes": {
+				Type: "array",
+				Items: &apiextensions.JSONSchemaPropsOrArray{
+					Schema: &apiextensions.JSONSchemaProps{
+						Type: "string",
+					},
+				},
+			},
+			"excludedAssetTypes": {
+				Type: "array",
+				Items: &apiextensions.JSONSchemaPropsOrArray{
+					Schema: &apiextensions.JSONSchemaProps{
+						Type: "string",
+					},
+				},
+			},
+			"orgPolicyConstraints": {
+				Type: "array",
+				Items: &apiextensions.JSONSchemaPropsOrArray{
+					Schema: &apiextensions.JSONSchemaProps{
+						Type: "string",
+					},
+				},
+			},
+			"resourceLabels": selectorSchema(),
+			"requiredTags":   selectorSchema(),
+			"excludedTags":   selectorSchema(),
+		},
+	}
+}
+
+// selectorSchema is the schema of the label and tag selectors in spec.match.
+func selectorSchema() apiextensions.JSONSchemaProps {
+	return apiextensions.JSONSchemaProps{
+		Type: "array",
+		Items: &apiextensions.JSONSchemaPropsOrArray{
+			Schema: &apiextensions.JSONSchemaProps{
+				Type:     "object",
+				Required: []string{"key"},
+				Properties: map[string]apiextensions.JSONSchemaProps{
+					"key":   {Type: "string"},
+					"value": {Type: "string"},
+				},
+			},
 		},
 	}
 }
@@ -137,13 +298,51 @@ func (g *GCPTarget) GetName() string {
 	return Name
 }
 
+// TagsKey is the key of the asset's Resource Manager tags in the review object, a map of namespaced
+// tag key, eg "123456789/env", to the short name of the tag value, eg "prod".  The tags include those
+// inherited from the asset's ancestors, as listed by Resource Manager's effective tags.
+const TagsKey = "tags"
+
+// AncestorsIAMKey is the key under data.inventory that holds the IAM policies of the ancestors of
+// reviewed assets, by ancestry path segment, eg data.inventory.ancestors_iam["folders/123"].
+const AncestorsIAMKey = "ancestors_iam"
+
+// AncestorIAMPolicy is the IAM policy of an organization, folder or project, stored for constraints to
+// evaluate inherited access with client.AddData.
+type AncestorIAMPolicy struct {
+	// Ancestor is the name of the resource as it appears in ancestry paths, eg "folders/123".
+	Ancestor string
+	// Policy is the iam_policy of the resource's CAI asset.
+	Policy map[string]interface{}
+}
+
 // ProcessData implements handler.TargetHandler
 func (g *GCPTarget) ProcessData(obj interface{}) (bool, []string, interface{}, error) {
-	return false, nil, nil, errors.New("storing data for referential constraint eval is not supported at this time.")
+	policy, ok := obj.(*AncestorIAMPolicy)
+	if !ok {
+		return false, nil, nil, errors.New("storing data for referential constraint eval is not supported at this time.")
+	}
+	if policy.Ancestor == "" {
+		return false, nil, nil, errors.New("ancestor IAM policy has no ancestor")
+	}
+	return true, []string{AncestorsIAMKey, policy.Ancestor}, policy.Policy, nil
 }
 
 // HandleReview implements handler.TargetHandler
 func (g *GCPTarget) HandleReview(obj interface{}) (bool, interface{}, error) {
+	sampled, applySampling := obj.(*SampledReview)
+	if applySampling {
+		obj = sampled.Asset
+	}
+	handled, review, err := g.handleReview(obj)
+	if !handled || err != nil {
+		return handled, review, err
+	}
+	return true, g.index.review(review.(map[string]interface{}), applySampling), nil
+}
+
+// handleReview converts obj to the review object for the asset.
+func (g *GCPTarget) handleReview(obj interface{}) (bool, interface{}, error) {
 	switch asset := obj.(type) {
 	case *validator.Asset:
 		return g.handleAsset(asset)
@@ -214,27 +413,66 @@ func (g *GCPTarget) HandleReview(obj interface{}) (bool, interface{}, error) {
 		if resourceTypes > 1 {
 			return false, nil, fmt.Errorf("malformed asset has more than one of: resource, iam policy, org policy, access context policy: %v", asset)
 		}
+		if foundV2OrgPolicy {
+			return true, withV2OrgPolicyByConstraint(asset), nil
+		}
 		return true, asset, nil
 	}
 	return false, nil, nil
 }
 
+// V2OrgPolicyByConstraintKey is the key in the review object of the asset's v2_org_policies by
+// constraint name, eg input.review.v2_org_policy_by_constraint["gcp.resourceLocations"], so that
+// templates can look up the policy for a constraint rather than iterate over v2_org_policies.
+const V2OrgPolicyByConstraintKey = "v2_org_policy_by_constraint"
+
+// V2OrgPolicyConstraint returns the constraint name of a v2 org policy from the policy's name, eg
+// "gcp.resourceLocations" for "projects/123/policies/gcp.resourceLocations".
+func V2OrgPolicyConstraint(policyName string) string {
+	idx := strings.LastIndex(policyName, "/policies/")
+	if idx < 0 {
+		return ""
+	}
+	return policyName[idx+len("/policies/"):]
+}
+
+// V2OrgPolicyByConstraint returns the v2_org_policies of the asset by constraint name.  Policies
+// without a name are omitted.
+func V2OrgPolicyByConstraint(asset map[string]interface{}) map[string]interface{} {
+	policies, _ := asset["v2_org_policies"].([]interface{})
+	byConstraint := map[string]interface{}{}
+	for _, policy := range policies {
+		policyMap, ok := policy.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := policyMap["name"].(string)
+		if constraint := V2OrgPolicyConstraint(name); constraint != "" {
+			byConstraint[constraint] = policyMap
+		}
+	}
+	return byConstraint
+}
+
+// withV2OrgPolicyByConstraint returns a shallow copy of the asset with V2OrgPolicyByConstraintKey set,
+// the asset itself belongs to the caller and is not modified.
+func withV2OrgPolicyByConstraint(asset map[string]interface{}) map[string]interface{} {
+	review := make(map[string]interface{}, len(asset)+1)
+	for k, v := range asset {
+		review[k] = v
+	}
+	review[V2OrgPolicyByConstraintKey] = V2OrgPolicyByConstraint(asset)
+	return review
+}
+
 // handleAsset handles input from CAI assets as received via the gRPC interface.
 func (g *GCPTarget) handleAsset(asset *validator.Asset) (bool, interface{}, error) {
-	if asset.Resource == nil {
-		return false, nil, fmt.Errorf("CAI asset's resource field is nil %s", asset)
-	}
-	asset2.CleanStructValue(asset.Resource.Data)
-	m := &protojson.MarshalOptions{
-		UseProtoNames: true,
+	if !asset2.HasData(asset) {
+		return false, nil, fmt.Errorf("CAI asset has no resource, IAM policy, org policy or access context policy %s", asset)
 	}
-	buf, err := m.Marshal(asset)
+	f, err := asset2.ConvertResourceViaJSONToInterface(asset)
 	if err != nil {
-		return false, nil, fmt.Errorf("marshalling to json with asset %s: %v. %w", asset.Name, asset, err)
-	}
-	var f interface{}
-	if err := json.Unmarshal(buf, &f); err != nil {
-		return false, nil, fmt.Errorf("marshalling from json with asset %s: %v. %w", asset.Name, asset, err)
+		return false, nil, fmt.Errorf("converting asset %s: %w", asset.Name, err)
 	}
 	return true, f, nil
 }
@@ -244,75 +482,15 @@ func (g *GCPTarget) HandleViolation(result *types.Result) error {
 	return nil
 }
 
-/*
-cases
-organizations/*
-organizations/[0-9]+/*
-organizations/[0-9]+/folders/*
-organizations/[0-9]+/folders/[0-9]+/*
-organizations/[0-9]+/folders/[0-9]+/projects/*
-organizations/[0-9]+/folders/[0-9]+/projects/[0-9]+
-folders/*
-folders/[0-9]+/*
-folders/[0-9]+/projects/*
-folders/[0-9]+/projects/[0-9]+
-projects/*
-projects/[0-9]+
-*/
-
-const (
-	organization = "organizations"
-	folder       = "folders"
-	project      = "projects"
-)
-
-const (
-	stateStart   = "stateStart"
-	stateFolder  = "stateFolder"
-	stateProject = "stateProject"
-)
-
-var numberRegex = regexp.MustCompile(`^[0-9]+\*{0,2}$`)
-
-// From https://cloud.google.com/resource-manager/docs/creating-managing-projects:
-// The project ID must be a unique string of 6 to 30 lowercase letters, digits, or hyphens. It must start with a letter, and cannot have a trailing hyphen.
-var projectIDRegex = regexp.MustCompile(`^[a-z][a-z0-9-]{5,27}[a-z0-9]$`)
-
-// checkPathGlob
-func checkPathGlob(expression string) error {
-	// check for path components / numbers
-	parts := strings.Split(expression, "/")
-	state := stateStart
-	for i := 0; i < len(parts); i++ {
-		item := parts[i]
-		switch {
-		case item == organization:
-			if state != stateStart {
-				return fmt.Errorf("unexpected %s element %d in %s", item, i, expression)
-			}
-			state = stateFolder
-		case item == folder:
-			if state != stateStart && state != stateFolder {
-				return fmt.Errorf("unexpected %s element %d in %s", item, i, expression)
-			}
-			state = stateFolder
-		case item == project:
-			state = stateProject
-		case item == "*":
-		case item == "**":
-		case item == "unknown":
-		case numberRegex.MatchString(item):
-		case state == stateProject && projectIDRegex.MatchString(item):
-		default:
-			return fmt.Errorf("unexpected item %s element %d in %s", item, i, expression)
-		}
-	}
-	return nil
+// checkPathGlobs returns an error if any of the expressions is not a glob of ancestry paths, see
+// ancestry.ValidateGlob.
+func checkPathGlobs(rs []string) error {
+	return ancestry.ValidateGlobs(rs)
 }
 
-func checkPathGlobs(rs []string) error {
+func checkAssetTypeGlobs(rs []string) error {
 	for idx, r := range rs {
-		if err := checkPathGlob(r); err != nil {
+		if _, err := glob.Compile(r, '/'); err != nil {
 			return fmt.Errorf("idx [%d]: %w", idx, err)
 		}
 	}
@@ -321,6 +499,9 @@ func checkPathGlobs(rs []string) error {
 
 // ValidateConstraint implements handler.TargetHandler
 func (g *GCPTarget) ValidateConstraint(constraint *unstructured.Unstructured) error {
+	if _, err := samplingRate(constraint); err != nil {
+		return err
+	}
 	ancestries, ancestriesFound, ancestriesErr := unstructured.NestedStringSlice(constraint.Object, "spec", "match", "ancestries")
 	targets, targetsFound, targetsErr := unstructured.NestedStringSlice(constraint.Object, "spec", "match", "target")
 	if ancestriesFound && targetsFound {
@@ -368,5 +549,23 @@ func (g *GCPTarget) ValidateConstraint(constraint *unstructured.Unstructured) er
 			return fmt.Errorf("invalid glob in spec.match.exclude: %w", excludesErr)
 		}
 	}
+
+	for _, field := range []string{"assetTypes", "excludedAssetTypes", "orgPolicyConstraints"} {
+		assetTypes, _, err := unstructured.NestedStringSlice(constraint.Object, "spec", "match", field)
+		if err != nil {
+			return fmt.Errorf("invalid spec.match.%s: %s", field, err)
+		}
+		if err := checkAssetTypeGlobs(assetTypes); err != nil {
+			return fmt.Errorf("invalid glob in spec.match.%s: %w", field, err)
+		}
+	}
+
+	match, _, _ := unstructured.NestedFieldNoCopy(constraint.Object, "spec", "match")
+	matchMap, _ := match.(map[string]interface{})
+	for _, field := range []string{"resourceLabels", "requiredTags", "excludedTags"} {
+		if _, err := labelSelectors(matchMap, field); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -19,6 +19,7 @@ import (
 	"regexp"
 	"testing"
 
+	"cloud.google.com/go/accesscontextmanager/apiv1/accesscontextmanagerpb"
 	v1 "cloud.google.com/go/asset/apiv1/assetpb"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/targettesting"
@@ -89,6 +90,11 @@ func (td *reviewTestData) legacySpecMatchTestcase() *targetHandlerTest.ReviewTes
 	if excludedAncestries, ok := td.match["excludedAncestries"]; ok {
 		legacyMatch["exclude"] = excludedAncestries
 	}
+	for _, field := range []string{"assetTypes", "excludedAssetTypes", "resourceLabels", "requiredTags", "excludedTags", "orgPolicyConstraints"} {
+		if value, ok := td.match[field]; ok {
+			legacyMatch[field] = value
+		}
+	}
 
 	tc := &targetHandlerTest.ReviewTestcase{
 		Name:                "legacy spec match " + td.name,
@@ -415,6 +421,83 @@ var matchTests = []reviewTestData{
 		},
 		wantConstraintError: true,
 	},
+	{
+		name: "Bad assetTypes glob",
+		match: map[string]interface{}{
+			"assetTypes": []interface{}{"compute.googleapis.com/["},
+		},
+		wantConstraintError: true,
+	},
+	{
+		name: "Bad excludedAssetTypes type",
+		match: map[string]interface{}{
+			"excludedAssetTypes": "compute.googleapis.com/*",
+		},
+		wantConstraintError: true,
+	},
+	{
+		name: "Bad orgPolicyConstraints glob",
+		match: map[string]interface{}{
+			"orgPolicyConstraints": []interface{}{"gcp.["},
+		},
+		wantConstraintError: true,
+	},
+	{
+		name: "orgPolicyConstraints does not match asset without org policies",
+		match: map[string]interface{}{
+			"orgPolicyConstraints": []interface{}{"gcp.resourceLocations"},
+		},
+		ancestryPath: "organizations/123454321/projects/557385378",
+		wantMatch:    false,
+	},
+	{
+		name: "resourceLabels missing key",
+		match: map[string]interface{}{
+			"resourceLabels": []interface{}{
+				map[string]interface{}{"value": "prod"},
+			},
+		},
+		wantConstraintError: true,
+	},
+	{
+		name: "resourceLabels does not match unlabeled asset",
+		match: map[string]interface{}{
+			"resourceLabels": []interface{}{
+				map[string]interface{}{"key": "env", "value": "prod"},
+			},
+		},
+		ancestryPath: "organizations/123454321/projects/557385378",
+		wantMatch:    false,
+	},
+	{
+		name: "requiredTags missing key",
+		match: map[string]interface{}{
+			"requiredTags": []interface{}{
+				map[string]interface{}{"value": "prod"},
+			},
+		},
+		wantConstraintError: true,
+	},
+	{
+		name: "requiredTags does not match untagged asset",
+		match: map[string]interface{}{
+			"requiredTags": []interface{}{
+				map[string]interface{}{"key": "123454321/env", "value": "prod"},
+			},
+		},
+		ancestryPath: "organizations/123454321/projects/557385378",
+		wantMatch:    false,
+	},
+	{
+		name: "excludedTags matches untagged asset",
+		match: map[string]interface{}{
+			"excludedTags": []interface{}{
+				map[string]interface{}{"key": "123454321/env"},
+			},
+		},
+		ancestryPath: "organizations/123454321/projects/557385378",
+		wantMatch:    true,
+	},
 }
 
 // Tests for legacy match conflicts and warnings
@@ -572,6 +655,15 @@ func TestToMatcher(t *testing.T) {
 			),
 			wantErr: true,
 		},
+		{
+			name: "invalid sampling annotation",
+			constraint: cts.MakeConstraint(t,
+				"kind",
+				"name",
+				cts.Set(map[string]interface{}{SamplingAnnotation: "1.5"}, "metadata", "annotations"),
+			),
+			wantErr: true,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -596,3 +688,78 @@ func TestToMatcher(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessData(t *testing.T) {
+	target := New()
+	policy := map[string]interface{}{"bindings": []interface{}{}}
+	handled, key, data, err := target.ProcessData(&AncestorIAMPolicy{Ancestor: "folders/2", Policy: policy})
+	if err != nil {
+		t.Fatalf("ProcessData() = %s, want = nil", err)
+	}
+	if !handled {
+		t.Errorf("ProcessData() not handled")
+	}
+	if diff := cmp.Diff([]string{AncestorsIAMKey, "folders/2"}, key); diff != "" {
+		t.Errorf("ProcessData() key diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(policy, data); diff != "" {
+		t.Errorf("ProcessData() data diff (-want +got):\n%s", diff)
+	}
+
+	if _, _, _, err := target.ProcessData(&AncestorIAMPolicy{Policy: policy}); err == nil {
+		t.Errorf("ProcessData() = nil, want = err for missing ancestor")
+	}
+	if _, _, _, err := target.ProcessData(map[string]interface{}{}); err == nil {
+		t.Errorf("ProcessData() = nil, want = err for unsupported data")
+	}
+}
+
+func TestHandleReviewV2OrgPolicies(t *testing.T) {
+	policy := map[string]interface{}{"name": "projects/123/policies/gcp.resourceLocations"}
+	asset := map[string]interface{}{
+		"name":            "//cloudresourcemanager.googleapis.com/projects/123",
+		"asset_type":      "cloudresourcemanager.googleapis.com/Project",
+		"ancestry_path":   "organizations/1/projects/123",
+		"v2_org_policies": []interface{}{policy},
+	}
+	handled, review, err := New().HandleReview(asset)
+	if err != nil {
+		t.Fatalf("HandleReview() = %s, want = nil", err)
+	}
+	if !handled {
+		t.Fatalf("HandleReview() not handled")
+	}
+	want := map[string]interface{}{"gcp.resourceLocations": policy}
+	if diff := cmp.Diff(want, review.(map[string]interface{})[V2OrgPolicyByConstraintKey]); diff != "" {
+		t.Errorf("%s diff (-want +got):\n%s", V2OrgPolicyByConstraintKey, diff)
+	}
+	if _, found := asset[V2OrgPolicyByConstraintKey]; found {
+		t.Errorf("HandleReview() modified the reviewed asset")
+	}
+}
+
+func TestHandleReviewAssetWithoutResource(t *testing.T) {
+	target := New()
+	perimeter := &validator.Asset{
+		Name:         "//accesscontextmanager.googleapis.com/accessPolicies/1/servicePerimeters/p",
+		AssetType:    "accesscontextmanager.googleapis.com/ServicePerimeter",
+		AncestryPath: "organizations/1",
+		AccessContextPolicy: &validator.Asset_ServicePerimeter{
+			ServicePerimeter: &accesscontextmanagerpb.ServicePerimeter{Name: "accessPolicies/1/servicePerimeters/p"},
+		},
+	}
+	handled, review, err := target.HandleReview(perimeter)
+	if err != nil {
+		t.Fatalf("HandleReview() = %s, want = nil", err)
+	}
+	if !handled {
+		t.Fatalf("HandleReview() not handled")
+	}
+	if _, found := review.(map[string]interface{})["service_perimeter"]; !found {
+		t.Errorf("HandleReview() review has no service_perimeter: %v", review)
+	}
+
+	if _, _, err := target.HandleReview(&validator.Asset{Name: "//storage.googleapis.com/b"}); err == nil {
+		t.Errorf("HandleReview() = nil, want = err for asset without data")
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcptarget
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// globMeta are the characters that make a glob pattern, or a path segment of one, match more than
+// its literal text.
+const globMeta = `*?[]{}\`
+
+// constraintIndex indexes the constraints of a GCPTarget by the asset types and ancestry prefixes
+// their spec.match can select.  The Constraint Framework calls the matcher of every constraint for
+// each review, so the index selects the candidate constraints for an asset once per review and the
+// matchers of the other constraints reject the asset without evaluating their globs.
+//
+// The index only narrows on necessary conditions, so candidates are still matched in full.  Entries
+// are replaced when a constraint is added again and are not removed with the constraint, which only
+// leaves a constraint that is never matched as a candidate.
+type constraintIndex struct {
+	mtx sync.RWMutex
+	// entries are the indexed constraints by key.
+	entries map[string]*indexEntry
+	// byAssetType are the constraints that select only literal asset types, by asset type.
+	byAssetType map[string]map[string]*indexEntry
+	// anyAssetType are the constraints that may select any asset type.
+	anyAssetType map[string]*indexEntry
+}
+
+// indexEntry is an indexed constraint.
+type indexEntry struct {
+	key string
+	// assetTypes are the literal asset types the constraint selects, empty for any asset type.
+	assetTypes []string
+	// ancestryPrefixes are the leading literal path segments of each of the constraint's ancestry
+	// patterns, an empty prefix selects every ancestry path.
+	ancestryPrefixes []string
+}
+
+func newConstraintIndex() *constraintIndex {
+	return &constraintIndex{
+		entries:      map[string]*indexEntry{},
+		byAssetType:  map[string]map[string]*indexEntry{},
+		anyAssetType: map[string]*indexEntry{},
+	}
+}
+
+// add indexes the matcher of the constraint with the given key, replacing any previous entry.
+func (idx *constraintIndex) add(key string, m *matcher) {
+	if idx == nil {
+		return
+	}
+	entry := &indexEntry{key: key}
+	for _, pattern := range m.ancestries {
+		entry.ancestryPrefixes = append(entry.ancestryPrefixes, literalPrefix(pattern))
+	}
+	for _, pattern := range m.assetTypes {
+		if strings.ContainsAny(pattern, globMeta) {
+			entry.assetTypes = nil
+			break
+		}
+		entry.assetTypes = append(entry.assetTypes, pattern)
+	}
+
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	idx.remove(key)
+	idx.entries[key] = entry
+	if len(entry.assetTypes) == 0 {
+		idx.anyAssetType[key] = entry
+		return
+	}
+	for _, assetType := range entry.assetTypes {
+		if idx.byAssetType[assetType] == nil {
+			idx.byAssetType[assetType] = map[string]*indexEntry{}
+		}
+		idx.byAssetType[assetType][key] = entry
+	}
+}
+
+// remove removes the entry with the given key, idx.mtx must be held.
+func (idx *constraintIndex) remove(key string) {
+	entry, ok := idx.entries[key]
+	if !ok {
+		return
+	}
+	delete(idx.entries, key)
+	delete(idx.anyAssetType, key)
+	for _, assetType := range entry.assetTypes {
+		delete(idx.byAssetType[assetType], key)
+		if len(idx.byAssetType[assetType]) == 0 {
+			delete(idx.byAssetType, assetType)
+		}
+	}
+}
+
+// literalPrefix returns the leading path segments of the glob pattern that have no wildcards.
+func literalPrefix(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for idx, segment := range segments {
+		if strings.ContainsAny(segment, globMeta) {
+			return strings.Join(segments[:idx], "/")
+		}
+	}
+	return pattern
+}
+
+// hasPathPrefix returns true if the leading path segments of path are prefix.
+func hasPathPrefix(path, prefix string) bool {
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// candidates returns the keys of the constraints that may match the review.  It returns false if
+// the review can't be narrowed, because it has no valid ancestry path or asset type and the matchers
+// must report the error, or if there are no indexed constraints.
+func (idx *constraintIndex) candidates(review map[string]interface{}) (map[string]bool, bool) {
+	if idx == nil {
+		return nil, false
+	}
+	ancestryPath, ok := review["ancestry_path"].(string)
+	if !ok || len(ancestryPath) > maxAncestryPathLength {
+		return nil, false
+	}
+	assetType, ok := review["asset_type"].(string)
+	if !ok {
+		return nil, false
+	}
+
+	idx.mtx.RLock()
+	defer idx.mtx.RUnlock()
+	if len(idx.entries) == 0 {
+		return nil, false
+	}
+	candidates := map[string]bool{}
+	add := func(entries map[string]*indexEntry) {
+		for key, entry := range entries {
+			for _, prefix := range entry.ancestryPrefixes {
+				if hasPathPrefix(ancestryPath, prefix) {
+					candidates[key] = true
+					break
+				}
+			}
+		}
+	}
+	add(idx.byAssetType[assetType])
+	add(idx.anyAssetType)
+	return candidates, true
+}
+
+// review returns the review object for the asset, with the candidate constraints for the asset when
+// the index can narrow them, and whether sampling is applied.
+func (idx *constraintIndex) review(asset map[string]interface{}, applySampling bool) interface{} {
+	candidates, ok := idx.candidates(asset)
+	if !ok && !applySampling {
+		return asset
+	}
+	return &indexedReview{asset: asset, index: idx, candidates: candidates, applySampling: applySampling}
+}
+
+// indexedReview is a review object with the constraints of the index that may match the asset, nil if
+// the index can't narrow them, and whether sampling is applied.  It marshals as the asset, which is
+// how the rego driver passes it to templates as input.review.
+type indexedReview struct {
+	asset         map[string]interface{}
+	index         *constraintIndex
+	candidates    map[string]bool
+	applySampling bool
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r *indexedReview) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.asset)
+}
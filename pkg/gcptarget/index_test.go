@@ -0,0 +1,198 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcptarget
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/core/constraints"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func indexTestConstraint(name string, match map[string]interface{}) *unstructured.Unstructured {
+	constraint := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "TestConstraint",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{},
+	}}
+	if match != nil {
+		constraint.Object["spec"].(map[string]interface{})["match"] = match
+	}
+	return constraint
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	for pattern, want := range map[string]string{
+		"**":                         "",
+		"organizations/123/**":       "organizations/123",
+		"organizations/*/projects/1": "organizations",
+		"organizations/12*/**":       "organizations",
+		"organizations/123":          "organizations/123",
+		"organizations/{1,2}":        "organizations",
+	} {
+		if got := literalPrefix(pattern); got != want {
+			t.Errorf("literalPrefix(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestConstraintIndex(t *testing.T) {
+	target := New()
+	matchers := map[string]constraints.Matcher{}
+	for name, match := range map[string]map[string]interface{}{
+		"everything":   nil,
+		"org-1":        {"ancestries": []interface{}{"organizations/1/**"}},
+		"org-12":       {"ancestries": []interface{}{"organizations/12/**"}},
+		"buckets":      {"ancestries": []interface{}{"**"}, "assetTypes": []interface{}{"storage.googleapis.com/Bucket"}},
+		"storage-glob": {"ancestries": []interface{}{"organizations/1/**"}, "assetTypes": []interface{}{"storage.googleapis.com/*"}},
+		"instances":    {"ancestries": []interface{}{"organizations/1/**"}, "assetTypes": []interface{}{"compute.googleapis.com/Instance"}},
+	} {
+		m, err := target.ToMatcher(indexTestConstraint(name, match))
+		if err != nil {
+			t.Fatal(err)
+		}
+		matchers[name] = m
+	}
+
+	bucket := map[string]interface{}{
+		"name":          "//storage.googleapis.com/b",
+		"asset_type":    "storage.googleapis.com/Bucket",
+		"ancestry_path": "organizations/1/projects/2",
+		"resource":      map[string]interface{}{"data": map[string]interface{}{}},
+	}
+	handled, review, err := target.HandleReview(bucket)
+	if err != nil || !handled {
+		t.Fatalf("HandleReview() = %v, %v", handled, err)
+	}
+	indexed, ok := review.(*indexedReview)
+	if !ok {
+		t.Fatalf("HandleReview() = %T, want an indexed review", review)
+	}
+	wantCandidates := map[string]bool{
+		"TestConstraint/everything":   true,
+		"TestConstraint/org-1":        true,
+		"TestConstraint/buckets":      true,
+		"TestConstraint/storage-glob": true,
+	}
+	if diff := cmp.Diff(wantCandidates, indexed.candidates); diff != "" {
+		t.Errorf("candidates diff (-want +got):\n%s", diff)
+	}
+
+	// Candidates are still matched in full, and the rest are rejected.
+	for name, want := range map[string]bool{
+		"everything": true, "org-1": true, "org-12": false, "buckets": true, "storage-glob": true, "instances": false,
+	} {
+		got, err := matchers[name].Match(review)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s Match() = %v, want %v", name, got, want)
+		}
+	}
+
+	// The review marshals as the asset.
+	got, err := json.Marshal(review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := json.Marshal(bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("review marshals as %s, want %s", got, want)
+	}
+
+	// Adding a constraint again replaces its entry.
+	if _, err := target.ToMatcher(indexTestConstraint("buckets", map[string]interface{}{
+		"assetTypes": []interface{}{"compute.googleapis.com/Instance"},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	candidates, _ := target.index.candidates(bucket)
+	if candidates["TestConstraint/buckets"] {
+		t.Errorf("replaced constraint is still a candidate for buckets")
+	}
+
+	// Matchers of another target ignore the candidates.
+	other, err := New().ToMatcher(indexTestConstraint("org-12", map[string]interface{}{"ancestries": []interface{}{"organizations/1/**"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := other.Match(review); err != nil || !got {
+		t.Errorf("Match() of another target = %v, %v, want true", got, err)
+	}
+}
+
+func TestConstraintIndexInvalidReview(t *testing.T) {
+	target := New()
+	m, err := target.ToMatcher(indexTestConstraint("org-1", map[string]interface{}{"ancestries": []interface{}{"organizations/1/**"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := target.index.review(map[string]interface{}{"name": "//storage.googleapis.com/b", "asset_type": "storage.googleapis.com/Bucket"}, false)
+	if _, ok := review.(*indexedReview); ok {
+		t.Fatalf("review without an ancestry path was indexed")
+	}
+	if _, err := m.Match(review); !errors.Is(err, ErrInvalidAncestryPath) {
+		t.Errorf("Match() = %v, want %v", err, ErrInvalidAncestryPath)
+	}
+}
+
+func BenchmarkMatchIndexed(b *testing.B) {
+	target := New()
+	var matchers []constraints.Matcher
+	for i := 0; i < 2000; i++ {
+		m, err := target.ToMatcher(indexTestConstraint(fmt.Sprintf("c-%d", i), map[string]interface{}{
+			"ancestries": []interface{}{fmt.Sprintf("organizations/%d/**", i%10)},
+			"assetTypes": []interface{}{fmt.Sprintf("service%d.googleapis.com/Resource", i%100)},
+		}))
+		if err != nil {
+			b.Fatal(err)
+		}
+		matchers = append(matchers, m)
+	}
+	asset := map[string]interface{}{
+		"name":          "//service1.googleapis.com/r",
+		"asset_type":    "service1.googleapis.com/Resource",
+		"ancestry_path": "organizations/1/folders/2/projects/3",
+		"resource":      map[string]interface{}{"data": map[string]interface{}{}},
+	}
+	for name, review := range map[string]func() interface{}{
+		"indexed": func() interface{} {
+			_, review, _ := target.HandleReview(asset)
+			return review
+		},
+		"unindexed": func() interface{} { return asset },
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r := review()
+				for _, m := range matchers {
+					if _, err := m.Match(r); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -16,45 +16,254 @@ package gcptarget
 
 import (
 	"fmt"
+	"hash/fnv"
+	"math"
 
+	"github.com/GoogleCloudPlatform/config-validator/pkg/ancestry"
 	"github.com/gobwas/glob"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 var ErrInvalidReview = fmt.Errorf("unexpected type of review, expect map[string]interface{}")
 var ErrInvalidAncestryPath = fmt.Errorf("unexpected type of ancestry path in review object")
+var ErrInvalidAssetType = fmt.Errorf("unexpected type of asset type in review object")
+var ErrAncestryPathTooLong = fmt.Errorf("ancestry path in review object is too long")
+
+// maxAncestryPathLength bounds the ancestry paths that are matched against constraint globs.  Globs
+// with several ** wildcards are superlinear in the path length, so the matcher rejects paths well
+// beyond any real resource hierarchy even if the caller did not validate them.
+const maxAncestryPathLength = 8192
+
+// labelSelector matches a resource label or tag.  If hasValue is false the label only needs to exist.
+type labelSelector struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+// String returns the selector as key=value, or key if it has no value.
+func (s labelSelector) String() string {
+	if !s.hasValue {
+		return s.key
+	}
+	return s.key + "=" + s.value
+}
+
+// matches returns true if the labels, or tags, satisfy the selector.
+func (s labelSelector) matches(labels map[string]interface{}) bool {
+	value, found := labels[s.key]
+	if !found {
+		return false
+	}
+	if !s.hasValue {
+		return true
+	}
+	str, ok := value.(string)
+	return ok && str == s.value
+}
 
 type matcher struct {
 	ancestries         []string
 	excludedAncestries []string
+	// assetTypes are globs for the asset types to match, empty matches all asset types.
+	assetTypes         []string
+	excludedAssetTypes []string
+	// resourceLabels must all match the labels in resource.data.labels.
+	resourceLabels []labelSelector
+	// requiredTags must all match the Resource Manager tags of the asset, see TagsKey, and the asset
+	// must match none of excludedTags.
+	requiredTags []labelSelector
+	excludedTags []labelSelector
+	// orgPolicyConstraints are globs for the org policy constraints, eg "gcp.resourceLocations", of
+	// which the asset must set at least one in v2_org_policies.  Empty matches all assets.
+	orgPolicyConstraints []string
+	// constraintName is mixed into the sampling hash so that sampled constraints don't all select
+	// the same assets.
+	constraintName string
+	// sampleRate is the fraction of assets matched when sampling is applied, zero or one disables
+	// sampling.
+	sampleRate float64
+	// index is the index of the target the matcher was made by, and key the constraint's key in it.
+	index *constraintIndex
+	key   string
 }
 
 func (m *matcher) Match(review interface{}) (bool, error) {
+	applySampling := false
+	if indexed, ok := review.(*indexedReview); ok {
+		if indexed.candidates != nil && indexed.index == m.index && !indexed.candidates[m.key] {
+			return false, nil
+		}
+		review, applySampling = indexed.asset, indexed.applySampling
+	}
+	decision, err := m.decide(review, applySampling)
+	return decision.Matched, err
+}
+
+// MatchDecision explains whether a constraint matches an asset, see ExplainMatch.
+type MatchDecision struct {
+	// Matched is true if the constraint applies to the asset.
+	Matched bool
+	// Field is the spec.match field that excluded the asset, eg "excludedAncestries", empty if the
+	// asset matched.
+	Field string
+	// Pattern is the glob or label selector of Field that excluded the asset, or the ancestries glob
+	// the asset matched.  It is empty if the asset matched no pattern of an inclusion field.
+	Pattern string
+	// Reason describes the decision.
+	Reason string
+}
+
+// ExplainMatch returns how the spec.match of constraint applies to the CAI asset, which must have
+// its ancestry_path set, for debugging constraints that don't fire on an asset.
+func ExplainMatch(constraint *unstructured.Unstructured, asset map[string]interface{}) (MatchDecision, error) {
+	// A target without an index, so that the matcher is not registered with one.
+	m, err := (&GCPTarget{}).ToMatcher(constraint)
+	if err != nil {
+		return MatchDecision{}, err
+	}
+	return m.(*matcher).decide(asset, false)
+}
+
+// decide matches the review against each criterion in turn and returns the first one that excludes
+// it.  The constraint's sample is only applied if applySampling is set.
+func (m *matcher) decide(review interface{}, applySampling bool) (MatchDecision, error) {
 	reviewObj, ok := review.(map[string]interface{})
 	if !ok {
-		return false, ErrInvalidReview
+		return MatchDecision{}, ErrInvalidReview
 	}
 	ancestryPath, ok := reviewObj["ancestry_path"].(string)
 	if !ok {
-		return false, ErrInvalidAncestryPath
+		return MatchDecision{}, ErrInvalidAncestryPath
+	}
+	if len(ancestryPath) > maxAncestryPathLength {
+		return MatchDecision{}, ErrAncestryPathTooLong
 	}
 
-	matchAncestries := false
-	for _, pattern := range m.ancestries {
-		g := glob.MustCompile(pattern, '/')
-		if g.Match(ancestryPath) {
-			matchAncestries = true
-			break
+	included, ok := ancestry.FirstMatch(m.ancestries, ancestryPath)
+	if !ok {
+		return MatchDecision{
+			Field:  "ancestries",
+			Reason: fmt.Sprintf("ancestry path %q matches none of %v", ancestryPath, m.ancestries),
+		}, nil
+	}
+
+	if pattern, ok := ancestry.FirstMatch(m.excludedAncestries, ancestryPath); ok {
+		return MatchDecision{
+			Field:   "excludedAncestries",
+			Pattern: pattern,
+			Reason:  fmt.Sprintf("ancestry path %q is excluded by %q", ancestryPath, pattern),
+		}, nil
+	}
+
+	if len(m.assetTypes) != 0 || len(m.excludedAssetTypes) != 0 {
+		assetType, ok := reviewObj["asset_type"].(string)
+		if !ok {
+			return MatchDecision{}, ErrInvalidAssetType
+		}
+		if _, ok := firstMatch(m.assetTypes, assetType); len(m.assetTypes) != 0 && !ok {
+			return MatchDecision{
+				Field:  "assetTypes",
+				Reason: fmt.Sprintf("asset type %q matches none of %v", assetType, m.assetTypes),
+			}, nil
+		}
+		if pattern, ok := firstMatch(m.excludedAssetTypes, assetType); ok {
+			return MatchDecision{
+				Field:   "excludedAssetTypes",
+				Pattern: pattern,
+				Reason:  fmt.Sprintf("asset type %q is excluded by %q", assetType, pattern),
+			}, nil
 		}
 	}
-	if !matchAncestries {
-		return false, nil
+
+	if len(m.resourceLabels) != 0 {
+		field, _, _ := unstructured.NestedFieldNoCopy(reviewObj, "resource", "data", "labels")
+		labels, _ := field.(map[string]interface{})
+		for _, selector := range m.resourceLabels {
+			if !selector.matches(labels) {
+				return MatchDecision{
+					Field:   "resourceLabels",
+					Pattern: selector.String(),
+					Reason:  fmt.Sprintf("resource labels don't match %s", selector),
+				}, nil
+			}
+		}
 	}
 
-	for _, pattern := range m.excludedAncestries {
+	if len(m.requiredTags) != 0 || len(m.excludedTags) != 0 {
+		tags, _ := reviewObj[TagsKey].(map[string]interface{})
+		for _, selector := range m.requiredTags {
+			if !selector.matches(tags) {
+				return MatchDecision{
+					Field:   "requiredTags",
+					Pattern: selector.String(),
+					Reason:  fmt.Sprintf("tags don't match %s", selector),
+				}, nil
+			}
+		}
+		for _, selector := range m.excludedTags {
+			if selector.matches(tags) {
+				return MatchDecision{
+					Field:   "excludedTags",
+					Pattern: selector.String(),
+					Reason:  fmt.Sprintf("tags are excluded by %s", selector),
+				}, nil
+			}
+		}
+	}
+
+	if len(m.orgPolicyConstraints) != 0 && !m.matchesOrgPolicy(reviewObj) {
+		return MatchDecision{
+			Field:  "orgPolicyConstraints",
+			Reason: fmt.Sprintf("asset sets no org policy for %v", m.orgPolicyConstraints),
+		}, nil
+	}
+
+	if applySampling && m.sampleRate > 0 && m.sampleRate < 1 {
+		name, _ := reviewObj["name"].(string)
+		if !inSample(m.constraintName+"/"+name, m.sampleRate) {
+			return MatchDecision{
+				Reason: fmt.Sprintf("asset is not in the %g sample of the constraint", m.sampleRate),
+			}, nil
+		}
+	}
+	return MatchDecision{
+		Matched: true,
+		Pattern: included,
+		Reason:  fmt.Sprintf("ancestry path %q matches %q", ancestryPath, included),
+	}, nil
+}
+
+// matchesOrgPolicy returns true if the review sets a v2 org policy for one of the matcher's constraints.
+func (m *matcher) matchesOrgPolicy(reviewObj map[string]interface{}) bool {
+	for constraint := range V2OrgPolicyByConstraint(reviewObj) {
+		if matchesAny(m.orgPolicyConstraints, constraint) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny returns true if value matches any of the glob patterns.
+func matchesAny(patterns []string, value string) bool {
+	_, ok := firstMatch(patterns, value)
+	return ok
+}
+
+// firstMatch returns the first of the glob patterns that matches value, false if none does.
+func firstMatch(patterns []string, value string) (string, bool) {
+	for _, pattern := range patterns {
 		g := glob.MustCompile(pattern, '/')
-		if g.Match(ancestryPath) {
-			return false, nil
+		if g.Match(value) {
+			return pattern, true
 		}
 	}
-	return true, nil
+	return "", false
+}
+
+// inSample deterministically selects key with probability rate by hashing it into [0, 1].
+func inSample(key string, rate float64) bool {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return float64(h.Sum64())/math.MaxUint64 < rate
 }
@@ -2,17 +2,27 @@ package gcptarget
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestMatch(t *testing.T) {
 	tests := []struct {
-		name    string
-		include []string
-		exclude []string
-		review  interface{}
-		want    bool
-		wantErr error
+		name               string
+		include            []string
+		exclude            []string
+		assetTypes         []string
+		excludedAssetTypes []string
+		resourceLabels     []labelSelector
+		requiredTags       []labelSelector
+		excludedTags       []labelSelector
+		orgPolicies        []string
+		review             interface{}
+		want               bool
+		wantErr            error
 	}{
 		{
 			name:    "include **",
@@ -65,6 +75,14 @@ func TestMatch(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name:    "ancestry path too long",
+			include: []string{"**/folders/1/**/folders/1/**/x"},
+			review: map[string]interface{}{
+				"ancestry_path": strings.Repeat("folders/1/", maxAncestryPathLength/10) + "projects/2",
+			},
+			wantErr: ErrAncestryPathTooLong,
+		},
 		{
 			name: "invalid address",
 			review: map[string]interface{}{
@@ -72,6 +90,214 @@ func TestMatch(t *testing.T) {
 			},
 			wantErr: ErrInvalidAncestryPath,
 		},
+		{
+			name:       "asset type match",
+			include:    []string{"**"},
+			assetTypes: []string{"compute.googleapis.com/*"},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"asset_type":    "compute.googleapis.com/Instance",
+			},
+			want: true,
+		},
+		{
+			name:       "asset type not match",
+			include:    []string{"**"},
+			assetTypes: []string{"compute.googleapis.com/*"},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"asset_type":    "storage.googleapis.com/Bucket",
+			},
+			want: false,
+		},
+		{
+			name:               "excluded asset type",
+			include:            []string{"**"},
+			assetTypes:         []string{"compute.googleapis.com/*"},
+			excludedAssetTypes: []string{"compute.googleapis.com/Disk"},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"asset_type":    "compute.googleapis.com/Disk",
+			},
+			want: false,
+		},
+		{
+			name:               "asset type not in excluded",
+			include:            []string{"**"},
+			excludedAssetTypes: []string{"compute.googleapis.com/Disk"},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"asset_type":    "compute.googleapis.com/Instance",
+			},
+			want: true,
+		},
+		{
+			name:       "invalid asset type",
+			include:    []string{"**"},
+			assetTypes: []string{"compute.googleapis.com/*"},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+			},
+			wantErr: ErrInvalidAssetType,
+		},
+		{
+			name:    "resource label equals",
+			include: []string{"**"},
+			resourceLabels: []labelSelector{
+				{key: "env", value: "prod", hasValue: true},
+			},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"resource": map[string]interface{}{
+					"data": map[string]interface{}{
+						"labels": map[string]interface{}{"env": "prod"},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name:    "resource label value differs",
+			include: []string{"**"},
+			resourceLabels: []labelSelector{
+				{key: "env", value: "prod", hasValue: true},
+			},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"resource": map[string]interface{}{
+					"data": map[string]interface{}{
+						"labels": map[string]interface{}{"env": "dev"},
+					},
+				},
+			},
+			want: false,
+		},
+		{
+			name:    "resource label exists",
+			include: []string{"**"},
+			resourceLabels: []labelSelector{
+				{key: "env", value: "prod", hasValue: true},
+				{key: "team"},
+			},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"resource": map[string]interface{}{
+					"data": map[string]interface{}{
+						"labels": map[string]interface{}{"env": "prod", "team": ""},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name:    "resource without labels",
+			include: []string{"**"},
+			resourceLabels: []labelSelector{
+				{key: "team"},
+			},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"resource": map[string]interface{}{
+					"data": map[string]interface{}{},
+				},
+			},
+			want: false,
+		},
+		{
+			name:        "org policy constraint",
+			include:     []string{"**"},
+			orgPolicies: []string{"gcp.resourceLocations"},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"v2_org_policies": []interface{}{
+					map[string]interface{}{"name": "projects/123/policies/compute.skipDefaultNetworkCreation"},
+					map[string]interface{}{"name": "projects/123/policies/gcp.resourceLocations"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:        "org policy constraint glob",
+			include:     []string{"**"},
+			orgPolicies: []string{"compute.*"},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"v2_org_policies": []interface{}{
+					map[string]interface{}{"name": "projects/123/policies/compute.skipDefaultNetworkCreation"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:        "org policy constraint not set",
+			include:     []string{"**"},
+			orgPolicies: []string{"gcp.resourceLocations"},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"v2_org_policies": []interface{}{
+					map[string]interface{}{"name": "projects/123/policies/compute.skipDefaultNetworkCreation"},
+				},
+			},
+			want: false,
+		},
+		{
+			name:        "org policy constraint without org policies",
+			include:     []string{"**"},
+			orgPolicies: []string{"gcp.resourceLocations"},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"resource":      map[string]interface{}{},
+			},
+			want: false,
+		},
+		{
+			name:    "required tag",
+			include: []string{"**"},
+			requiredTags: []labelSelector{
+				{key: "123/env", value: "prod", hasValue: true},
+			},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"tags":          map[string]interface{}{"123/env": "prod"},
+			},
+			want: true,
+		},
+		{
+			name:    "required tag value differs",
+			include: []string{"**"},
+			requiredTags: []labelSelector{
+				{key: "123/env", value: "prod", hasValue: true},
+			},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"tags":          map[string]interface{}{"123/env": "dev"},
+			},
+			want: false,
+		},
+		{
+			name:    "excluded tag",
+			include: []string{"**"},
+			excludedTags: []labelSelector{
+				{key: "123/sandbox"},
+			},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"tags":          map[string]interface{}{"123/env": "dev", "123/sandbox": "true"},
+			},
+			want: false,
+		},
+		{
+			name:    "excluded tag absent",
+			include: []string{"**"},
+			excludedTags: []labelSelector{
+				{key: "123/sandbox"},
+			},
+			review: map[string]interface{}{
+				"ancestry_path": "abc/def",
+				"tags":          map[string]interface{}{"123/env": "dev"},
+			},
+			want: true,
+		},
 		{
 			name:    "invalid review object",
 			review:  123,
@@ -81,8 +307,14 @@ func TestMatch(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			matcher := &matcher{
-				ancestries:         test.include,
-				excludedAncestries: test.exclude,
+				ancestries:           test.include,
+				excludedAncestries:   test.exclude,
+				assetTypes:           test.assetTypes,
+				excludedAssetTypes:   test.excludedAssetTypes,
+				resourceLabels:       test.resourceLabels,
+				requiredTags:         test.requiredTags,
+				excludedTags:         test.excludedTags,
+				orgPolicyConstraints: test.orgPolicies,
 			}
 			got, err := matcher.Match(test.review)
 			if got != test.want {
@@ -94,3 +326,135 @@ func TestMatch(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchSampling(t *testing.T) {
+	m := &matcher{
+		ancestries:         []string{"**"},
+		excludedAncestries: []string{},
+		constraintName:     "expensive-constraint",
+		sampleRate:         0.25,
+	}
+	review := func(idx int, applySampling bool) interface{} {
+		asset := map[string]interface{}{
+			"name":          fmt.Sprintf("//compute.googleapis.com/projects/p/zones/z/instances/%d", idx),
+			"ancestry_path": "organizations/1/projects/2",
+		}
+		if !applySampling {
+			// Only the review request can apply sampling, not the asset.
+			asset["apply_sampling"] = true
+			return asset
+		}
+		return &indexedReview{asset: asset, applySampling: true}
+	}
+
+	const total = 1000
+	sampled := 0
+	for idx := 0; idx < total; idx++ {
+		got, err := m.Match(review(idx, true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := m.Match(review(idx, true))
+		if got != again {
+			t.Fatalf("sampling is not deterministic for asset %d", idx)
+		}
+		if got {
+			sampled++
+		}
+
+		if full, _ := m.Match(review(idx, false)); !full {
+			t.Errorf("asset %d not matched without sampling applied", idx)
+		}
+	}
+	if sampled < total/5 || sampled > total*3/10 {
+		t.Errorf("sampled %d of %d assets, want about %d", sampled, total, total/4)
+	}
+}
+
+func TestExplainMatch(t *testing.T) {
+	constraint := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "GCPExampleConstraintV1",
+		"metadata":   map[string]interface{}{"name": "example"},
+		"spec": map[string]interface{}{
+			"match": map[string]interface{}{
+				"ancestries":         []interface{}{"organizations/1/folders/*/**", "organizations/1/**"},
+				"excludedAncestries": []interface{}{"**/projects/excluded"},
+				"assetTypes":         []interface{}{"storage.googleapis.com/*"},
+				"resourceLabels":     []interface{}{map[string]interface{}{"key": "env", "value": "prod"}},
+			},
+		},
+	}}
+	asset := func(ancestryPath, assetType, env string) map[string]interface{} {
+		return map[string]interface{}{
+			"ancestry_path": ancestryPath,
+			"asset_type":    assetType,
+			"resource": map[string]interface{}{
+				"data": map[string]interface{}{"labels": map[string]interface{}{"env": env}},
+			},
+		}
+	}
+	for _, tc := range []struct {
+		name        string
+		asset       map[string]interface{}
+		wantMatched bool
+		wantField   string
+		wantPattern string
+	}{
+		{
+			name:        "matched",
+			asset:       asset("organizations/1/projects/2", "storage.googleapis.com/Bucket", "prod"),
+			wantMatched: true,
+			wantPattern: "organizations/1/**",
+		},
+		{
+			name:      "not in ancestries",
+			asset:     asset("organizations/2/projects/2", "storage.googleapis.com/Bucket", "prod"),
+			wantField: "ancestries",
+		},
+		{
+			name:        "excluded ancestry",
+			asset:       asset("organizations/1/projects/excluded", "storage.googleapis.com/Bucket", "prod"),
+			wantField:   "excludedAncestries",
+			wantPattern: "**/projects/excluded",
+		},
+		{
+			name:      "asset type",
+			asset:     asset("organizations/1/projects/2", "compute.googleapis.com/Instance", "prod"),
+			wantField: "assetTypes",
+		},
+		{
+			name:        "resource labels",
+			asset:       asset("organizations/1/projects/2", "storage.googleapis.com/Bucket", "dev"),
+			wantField:   "resourceLabels",
+			wantPattern: "env=prod",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ExplainMatch(constraint, tc.asset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Matched != tc.wantMatched || got.Field != tc.wantField || got.Pattern != tc.wantPattern {
+				t.Errorf("got %+v, want matched %v field %q pattern %q", got, tc.wantMatched, tc.wantField, tc.wantPattern)
+			}
+			if got.Reason == "" {
+				t.Error("got no reason")
+			}
+		})
+	}
+}
+
+func TestV2OrgPolicyConstraint(t *testing.T) {
+	tests := map[string]string{
+		"projects/123/policies/gcp.resourceLocations":        "gcp.resourceLocations",
+		"organizations/1/policies/iam.disableServiceAccount": "iam.disableServiceAccount",
+		"gcp.resourceLocations":                              "",
+		"":                                                   "",
+	}
+	for name, want := range tests {
+		if got := V2OrgPolicyConstraint(name); got != want {
+			t.Errorf("V2OrgPolicyConstraint(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
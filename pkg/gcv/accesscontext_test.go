@@ -0,0 +1,193 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// restrictedServicesTemplate requires regular service perimeters to restrict the services in the
+// constraint's parameters.  It relies on perimeter_type being present for the default perimeter type.
+const restrictedServicesTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpvpcscrestrictedservicesconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPVPCSCRestrictedServicesConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: "object"
+          properties:
+            services:
+              type: array
+              items:
+                type: string
+  targets:
+    - target: "validation.gcp.forsetisecurity.org"
+      rego: |
+        package templates.gcp.GCPVPCSCRestrictedServicesConstraintV1
+
+        violation[{"msg": message}] {
+        	perimeter := input.review.service_perimeter
+        	perimeter.perimeter_type == "PERIMETER_TYPE_REGULAR"
+        	service := input.parameters.services[_]
+        	not restricted(perimeter, service)
+        	message := sprintf("%v does not restrict %v", [perimeter.name, service])
+        }
+
+        restricted(perimeter, service) {
+        	perimeter.status.restricted_services[_] == service
+        }
+`
+
+const restrictedServicesConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPVPCSCRestrictedServicesConstraintV1
+metadata:
+  name: restrict-storage
+spec:
+  severity: high
+  parameters:
+    services: ["storage.googleapis.com", "bigquery.googleapis.com"]
+`
+
+// servicePerimeterJSON omits perimeter_type, as protojson does for PERIMETER_TYPE_REGULAR.
+const servicePerimeterJSON = `{
+  "name": "//accesscontextmanager.googleapis.com/accessPolicies/1/servicePerimeters/prod",
+  "asset_type": "accesscontextmanager.googleapis.com/ServicePerimeter",
+  "ancestors": ["organizations/1"],
+  "service_perimeter": {
+    "name": "accessPolicies/1/servicePerimeters/prod",
+    "title": "prod",
+    "status": {
+      "resources": ["projects/2"],
+      "restricted_services": ["storage.googleapis.com"]
+    }
+  }
+}`
+
+const bridgePerimeterJSON = `{
+  "name": "//accesscontextmanager.googleapis.com/accessPolicies/1/servicePerimeters/bridge",
+  "asset_type": "accesscontextmanager.googleapis.com/ServicePerimeter",
+  "ancestors": ["organizations/1"],
+  "service_perimeter": {
+    "name": "accessPolicies/1/servicePerimeters/bridge",
+    "title": "bridge",
+    "perimeter_type": "PERIMETER_TYPE_BRIDGE",
+    "status": {
+      "resources": ["projects/2", "projects/3"]
+    }
+  }
+}`
+
+const accessLevelJSON = `{
+  "name": "//accesscontextmanager.googleapis.com/accessPolicies/1/accessLevels/corp",
+  "asset_type": "accesscontextmanager.googleapis.com/AccessLevel",
+  "ancestors": ["organizations/1"],
+  "access_level": {
+    "name": "accessPolicies/1/accessLevels/corp",
+    "title": "corp",
+    "basic": {
+      "conditions": [{"ip_subnetworks": ["10.0.0.0/8"]}]
+    }
+  }
+}`
+
+func newAccessContextValidator(t *testing.T) *Validator {
+	t.Helper()
+	v, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(restrictedServicesTemplate)},
+		{Path: "constraint.yaml", Content: []byte(restrictedServicesConstraint)},
+	}, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	return v
+}
+
+func TestReviewAccessContextPolicy(t *testing.T) {
+	ctx := context.Background()
+	v := newAccessContextValidator(t)
+	testCases := []struct {
+		name           string
+		assetJSON      string
+		wantViolations int
+	}{
+		{name: "regular perimeter", assetJSON: servicePerimeterJSON, wantViolations: 1},
+		{name: "bridge perimeter", assetJSON: bridgePerimeterJSON, wantViolations: 0},
+		{name: "access level", assetJSON: accessLevelJSON, wantViolations: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := v.ReviewJSON(ctx, tc.assetJSON)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if got := len(result.ConstraintViolations); got != tc.wantViolations {
+				t.Errorf("ReviewJSON got %d violations, want %d", got, tc.wantViolations)
+			}
+
+			asset := &validator.Asset{}
+			if err := protojson.Unmarshal([]byte(tc.assetJSON), asset); err != nil {
+				t.Fatal(err)
+			}
+			violations, err := v.ReviewAsset(ctx, asset)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if got := len(violations); got != tc.wantViolations {
+				t.Errorf("ReviewAsset got %d violations, want %d", got, tc.wantViolations)
+			}
+		})
+	}
+}
+
+func TestReviewCAIExportAccessContextPolicy(t *testing.T) {
+	v := newAccessContextValidator(t)
+	path := filepath.Join(t.TempDir(), "access_policy.json")
+	writeExportShard(t, path, []string{servicePerimeterJSON, bridgePerimeterJSON, accessLevelJSON}, false)
+	summary, err := v.ReviewCAIExport(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Assets != 3 {
+		t.Errorf("got %d assets, want 3", summary.Assets)
+	}
+	if summary.Violations != 1 {
+		t.Errorf("got %d violations, want 1", summary.Violations)
+	}
+}
+
+func TestReviewAccessContextPolicyMismatchedType(t *testing.T) {
+	v := newAccessContextValidator(t)
+	asset := &validator.Asset{}
+	if err := protojson.Unmarshal([]byte(accessLevelJSON), asset); err != nil {
+		t.Fatal(err)
+	}
+	asset.AssetType = "accesscontextmanager.googleapis.com/ServicePerimeter"
+	if _, err := v.ReviewAsset(context.Background(), asset); err == nil {
+		t.Errorf("expected error for access level with service perimeter asset type")
+	}
+}
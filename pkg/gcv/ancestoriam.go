@@ -0,0 +1,100 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	asset2 "github.com/GoogleCloudPlatform/config-validator/pkg/asset"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ErrAncestorIAMDisabled is returned when ancestor IAM policies are added to a Validator created without
+// the AncestorIAM option.
+var ErrAncestorIAMDisabled = errors.New("ancestor IAM policies are not enabled")
+
+// AddAncestorIAM stores the IAM policy of an organization, folder or project asset for GCP constraints
+// to evaluate against its descendants, replacing any policy stored for the same ancestor.  It returns
+// false if the asset is not an ancestor IAM policy and so was not stored.
+func (v *Validator) AddAncestorIAM(ctx context.Context, asset *validator.Asset) (bool, error) {
+	if asset.GetIamPolicy() == nil {
+		return false, nil
+	}
+	assetInterface, err := asset2.ConvertResourceViaJSONToInterface(asset)
+	if err != nil {
+		return false, err
+	}
+	return v.addAncestorIAM(ctx, assetInterface.(map[string]interface{}))
+}
+
+// addAncestorIAM stores the IAM policy of a CAI asset if it is an ancestor IAM policy.
+func (v *Validator) addAncestorIAM(ctx context.Context, asset map[string]interface{}) (bool, error) {
+	if !v.ancestorIAM {
+		return false, ErrAncestorIAMDisabled
+	}
+	ancestor, ok := asset2.AncestorName(asset)
+	if !ok {
+		return false, nil
+	}
+	policy, found, err := unstructured.NestedMap(asset, "iam_policy")
+	if err != nil {
+		return false, fmt.Errorf("invalid iam_policy for %s: %w", ancestor, err)
+	}
+	if !found {
+		return false, nil
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	for _, client := range v.gcpCFClients.clients {
+		if _, err := client.AddData(ctx, &gcptarget.AncestorIAMPolicy{Ancestor: ancestor, Policy: policy}); err != nil {
+			return false, fmt.Errorf("failed to add IAM policy for %s: %w", ancestor, err)
+		}
+	}
+	if v.ancestorIAMKeys == nil {
+		v.ancestorIAMKeys = map[string]bool{}
+	}
+	v.ancestorIAMKeys[ancestor] = true
+	return true, nil
+}
+
+// ResetAncestorIAM removes all stored ancestor IAM policies.
+func (v *Validator) ResetAncestorIAM(ctx context.Context) error {
+	if !v.ancestorIAM {
+		return ErrAncestorIAMDisabled
+	}
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	var errs multierror.Errors
+	for ancestor := range v.ancestorIAMKeys {
+		var failed bool
+		for _, client := range v.gcpCFClients.clients {
+			if _, err := client.RemoveData(ctx, &gcptarget.AncestorIAMPolicy{Ancestor: ancestor}); err != nil {
+				errs.Add(fmt.Errorf("failed to remove IAM policy for %s: %w", ancestor, err))
+				failed = true
+				break
+			}
+		}
+		if !failed {
+			delete(v.ancestorIAMKeys, ancestor)
+		}
+	}
+	return errs.ToError()
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const inheritedOwnerTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpinheritedownerconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPInheritedOwnerConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: "object"
+          properties: {}
+  targets:
+    - target: "validation.gcp.forsetisecurity.org"
+      rego: |
+        package templates.gcp.GCPInheritedOwnerConstraintV1
+
+        violation[{"msg": message}] {
+        	asset := input.review
+        	parts := split(asset.ancestry_path, "/")
+        	parts[i]
+        	i % 2 == 0
+        	ancestor := sprintf("%v/%v", [parts[i], parts[i + 1]])
+        	binding := data.inventory.ancestors_iam[ancestor].bindings[_]
+        	binding.role == "roles/owner"
+        	binding.members[_] == "allUsers"
+        	message := sprintf("%v inherits public ownership from %v", [asset.name, ancestor])
+        }
+`
+
+const inheritedOwnerConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPInheritedOwnerConstraintV1
+metadata:
+  name: no-inherited-public-owner
+spec:
+  severity: high
+  parameters: {}
+`
+
+const folderPublicOwnerJSON = `{
+  "name": "//cloudresourcemanager.googleapis.com/folders/2",
+  "asset_type": "cloudresourcemanager.googleapis.com/Folder",
+  "ancestors": ["folders/2", "organizations/1"],
+  "iam_policy": {
+    "bindings": [{"role": "roles/owner", "members": ["allUsers"]}]
+  }
+}`
+
+func newAncestorIAMValidator(t *testing.T, opts ...Option) *Validator {
+	t.Helper()
+	v, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(inheritedOwnerTemplate)},
+		{Path: "constraint.yaml", Content: []byte(inheritedOwnerConstraint)},
+	}, []string{"package validator.gcp.lib\n"}, opts...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	return v
+}
+
+func TestAncestorIAM(t *testing.T) {
+	ctx := context.Background()
+	v := newAncestorIAMValidator(t, AncestorIAM())
+	violations := func() int {
+		t.Helper()
+		result, err := v.ReviewJSON(ctx, storageAssetNoLoggingJSON)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		return len(result.ConstraintViolations)
+	}
+	if got := violations(); got != 0 {
+		t.Errorf("got %d violations before adding policy, want 0", got)
+	}
+
+	folder := &validator.Asset{}
+	if err := protojson.Unmarshal([]byte(folderPublicOwnerJSON), folder); err != nil {
+		t.Fatal(err)
+	}
+	added, err := v.AddAncestorIAM(ctx, folder)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !added {
+		t.Fatal("folder IAM policy not added")
+	}
+	if got := violations(); got != 1 {
+		t.Errorf("got %d violations after adding policy, want 1", got)
+	}
+
+	bucket := &validator.Asset{}
+	if err := protojson.Unmarshal([]byte(storageAssetNoLoggingJSON), bucket); err != nil {
+		t.Fatal(err)
+	}
+	if added, err := v.AddAncestorIAM(ctx, bucket); added || err != nil {
+		t.Errorf("AddAncestorIAM(bucket) = %v, %v, want false, nil", added, err)
+	}
+
+	if err := v.ResetAncestorIAM(ctx); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := violations(); got != 0 {
+		t.Errorf("got %d violations after reset, want 0", got)
+	}
+}
+
+func TestAncestorIAMDisabled(t *testing.T) {
+	ctx := context.Background()
+	v := newAncestorIAMValidator(t)
+	folder := &validator.Asset{}
+	if err := protojson.Unmarshal([]byte(folderPublicOwnerJSON), folder); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.AddAncestorIAM(ctx, folder); !errors.Is(err, ErrAncestorIAMDisabled) {
+		t.Errorf("got error %v, want %v", err, ErrAncestorIAMDisabled)
+	}
+	if err := v.ResetAncestorIAM(ctx); !errors.Is(err, ErrAncestorIAMDisabled) {
+		t.Errorf("got error %v, want %v", err, ErrAncestorIAMDisabled)
+	}
+}
+
+func TestReviewCAIExportAncestorIAM(t *testing.T) {
+	v := newAncestorIAMValidator(t, AncestorIAM())
+	path := filepath.Join(t.TempDir(), "resource.json")
+	// The bucket precedes the folder, policies are loaded before any asset is reviewed.
+	writeExportShard(t, path, []string{storageAssetNoLoggingJSON, folderPublicOwnerJSON}, false)
+	summary, err := v.ReviewCAIExport(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.AncestorIAMPolicies != 1 {
+		t.Errorf("got %d ancestor IAM policies, want 1", summary.AncestorIAMPolicies)
+	}
+	// The folder's own ancestry path includes it, so both the folder and the bucket are reported.
+	if summary.Violations != 2 {
+		t.Errorf("got %d violations, want 2", summary.Violations)
+	}
+}
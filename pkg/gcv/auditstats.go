@@ -0,0 +1,286 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/frameworks/constraint/pkg/core/constraints"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/instrumentation"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// AuditStats collects statistics for each constraint over the reviews of a context with
+// WithAuditStats, to find the slow or noisy constraints of a policy library.  It is safe for
+// concurrent use.
+type AuditStats struct {
+	mtx         sync.Mutex
+	constraints map[string]*ConstraintStats
+}
+
+// ConstraintStats are the statistics of a constraint over the reviews recorded in an AuditStats.
+type ConstraintStats struct {
+	// Constraint is the constraint, as "[Kind].[Name]".
+	Constraint string
+	// Assets is the number of assets the constraint was evaluated against, those its match selected.
+	Assets int
+	// Violations is the number of violations of the constraint.
+	Violations int
+	// Errors is the number of assets the constraint could not be evaluated against, because its
+	// match failed or the review of the asset failed.
+	Errors int
+	// EvaluationTime is the total rego evaluation time of the constraint.  The constraints of a kind
+	// are evaluated against an asset together, so the time for the kind is split evenly between them.
+	EvaluationTime time.Duration
+}
+
+// NewAuditStats returns an empty AuditStats.
+func NewAuditStats() *AuditStats {
+	return &AuditStats{constraints: map[string]*ConstraintStats{}}
+}
+
+type auditStatsContextKey struct{}
+
+// WithAuditStats returns a copy of ctx which records the statistics of every review with it, from
+// any of the Validator's review methods or a ParallelValidator, in stats.
+func WithAuditStats(ctx context.Context, stats *AuditStats) context.Context {
+	return context.WithValue(ctx, auditStatsContextKey{}, stats)
+}
+
+// auditStatsFrom returns the AuditStats set with WithAuditStats, nil if none.
+func auditStatsFrom(ctx context.Context) *AuditStats {
+	stats, _ := ctx.Value(auditStatsContextKey{}).(*AuditStats)
+	return stats
+}
+
+type recordEvaluatedContextKey struct{}
+
+type evaluatedContextKey struct{}
+
+// RecordEvaluated returns a copy of ctx which requests that the results of reviews with it list the
+// constraints that were evaluated against the resource in Result.Evaluated, eg to report the
+// constraints a resource complies with as well as those it violates.
+func RecordEvaluated(ctx context.Context) context.Context {
+	return context.WithValue(ctx, recordEvaluatedContextKey{}, true)
+}
+
+// evaluatedConstraints collects the constraints evaluated in the CF client reviews of a single
+// resource, which may run concurrently for sharded constraints.
+type evaluatedConstraints struct {
+	mtx   sync.Mutex
+	names map[string]bool
+}
+
+// withEvaluated returns a copy of ctx that collects the constraints evaluated in the reviews of a
+// resource if RecordEvaluated was requested, or ctx and nil.
+func withEvaluated(ctx context.Context) (context.Context, *evaluatedConstraints) {
+	if record, _ := ctx.Value(recordEvaluatedContextKey{}).(bool); !record {
+		return ctx, nil
+	}
+	evaluated := &evaluatedConstraints{names: map[string]bool{}}
+	return context.WithValue(ctx, evaluatedContextKey{}, evaluated), evaluated
+}
+
+// evaluatedFrom returns the constraints collected for the resource reviewed with ctx, nil if none.
+func evaluatedFrom(ctx context.Context) *evaluatedConstraints {
+	evaluated, _ := ctx.Value(evaluatedContextKey{}).(*evaluatedConstraints)
+	return evaluated
+}
+
+// add records the constraints evaluated without errors in a CF client review.
+func (e *evaluatedConstraints) add(rs *reviewStats, err error) {
+	if err != nil {
+		return
+	}
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	for _, m := range rs.matched {
+		if !rs.errored[m.name] {
+			e.names[m.name] = true
+		}
+	}
+}
+
+// list returns the sorted names of the constraints, nil for a nil evaluatedConstraints.
+func (e *evaluatedConstraints) list() []string {
+	if e == nil {
+		return nil
+	}
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	names := make([]string, 0, len(e.names))
+	for name := range e.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Constraints returns the statistics of the constraints evaluated so far, slowest first.
+func (s *AuditStats) Constraints() []ConstraintStats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	ret := make([]ConstraintStats, 0, len(s.constraints))
+	for _, cs := range s.constraints {
+		ret = append(ret, *cs)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].EvaluationTime != ret[j].EvaluationTime {
+			return ret[i].EvaluationTime > ret[j].EvaluationTime
+		}
+		return ret[i].Constraint < ret[j].Constraint
+	})
+	return ret
+}
+
+// constraint returns the statistics of the named constraint, s.mtx must be held.
+func (s *AuditStats) constraint(name string) *ConstraintStats {
+	cs, ok := s.constraints[name]
+	if !ok {
+		cs = &ConstraintStats{Constraint: name}
+		s.constraints[name] = cs
+	}
+	return cs
+}
+
+// add records the outcome of a CF client review.
+func (s *AuditStats) add(rs *reviewStats, responses *cftypes.Responses, err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, m := range rs.matched {
+		cs := s.constraint(m.name)
+		cs.Assets++
+		if err != nil || rs.errored[m.name] {
+			cs.Errors++
+		}
+	}
+	if err != nil || responses == nil {
+		return
+	}
+	for _, result := range responses.Results() {
+		name := constraintName(result.Constraint)
+		if !rs.errored[name] {
+			s.constraint(name).Violations++
+		}
+	}
+
+	byKind := map[string][]string{}
+	for _, m := range rs.matched {
+		if !rs.errored[m.name] {
+			byKind[m.kind] = append(byKind[m.kind], m.name)
+		}
+	}
+	for _, entry := range responses.StatsEntries {
+		names := byKind[entry.StatsFor]
+		if entry.Scope != instrumentation.TemplateScope || len(names) == 0 {
+			continue
+		}
+		for _, stat := range entry.Stats {
+			if ns, ok := stat.Value.(uint64); ok && stat.Name == templateRunTimeNS {
+				share := time.Duration(ns) / time.Duration(len(names))
+				for _, name := range names {
+					s.constraint(name).EvaluationTime += share
+				}
+			}
+		}
+	}
+}
+
+// constraintName returns the name of the constraint as "[Kind].[Name]", as in ConstraintViolation.
+func constraintName(constraint *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s.%s", constraint.GetKind(), originalName(constraint))
+}
+
+// reviewStats records the constraints the matchers selected in a single CF client review, which
+// calls them one at a time.
+type reviewStats struct {
+	matched []matchedConstraint
+	errored map[string]bool
+}
+
+type matchedConstraint struct {
+	name string
+	kind string
+}
+
+// statsReview is the review object for the CF client when statistics are recorded, a policy overlay is
+// set or the review has an evaluation time, so that the matchers can record the constraints they
+// select and skip those left out by the overlay.  It marshals as the review with its evaluation time,
+// which is how the rego driver passes it to templates as input.review.
+type statsReview struct {
+	review interface{}
+	// stats is nil unless statistics are recorded.
+	stats *reviewStats
+	// overlay is nil unless set with WithPolicyOverlay.
+	overlay *policyOverlay
+	// evaluationTime replaces the evaluation_time of the review, see setEvaluationTime.  It is nil for
+	// reviews that carry the time otherwise, such as K8S reviews.
+	evaluationTime *string
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r *statsReview) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(r.review)
+	if err != nil {
+		return nil, err
+	}
+	if r.evaluationTime == nil {
+		return data, nil
+	}
+	return setEvaluationTime(data, *r.evaluationTime)
+}
+
+// statsMatcher records the outcome of matching the constraint in statsReviews, and does not match
+// constraints left out by their overlay.
+type statsMatcher struct {
+	constraints.Matcher
+	name string
+	kind string
+}
+
+func newStatsMatcher(m constraints.Matcher, constraint *unstructured.Unstructured) constraints.Matcher {
+	return &statsMatcher{Matcher: m, name: constraintName(constraint), kind: constraint.GetKind()}
+}
+
+// Match implements constraints.Matcher.
+func (m *statsMatcher) Match(review interface{}) (bool, error) {
+	sr, ok := review.(*statsReview)
+	if !ok {
+		return m.Matcher.Match(review)
+	}
+	if sr.overlay != nil && !sr.overlay.includes(m.name) {
+		return false, nil
+	}
+	matched, err := m.Matcher.Match(sr.review)
+	if sr.stats == nil {
+		return matched, err
+	}
+	if err != nil {
+		if sr.stats.errored == nil {
+			sr.stats.errored = map[string]bool{}
+		}
+		sr.stats.errored[m.name] = true
+	}
+	if matched || err != nil {
+		sr.stats.matched = append(sr.stats.matched, matchedConstraint{name: m.name, kind: m.kind})
+	}
+	return matched, err
+}
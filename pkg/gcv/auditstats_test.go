@@ -0,0 +1,155 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/instrumentation"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAuditStats(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	stats := NewAuditStats()
+	ctx := WithAuditStats(context.Background(), stats)
+	for i := 0; i < 2; i++ {
+		if _, err := v.ReviewJSON(ctx, storageAssetNoLoggingJSON); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Reviews without the stats context are not recorded.
+	if _, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]ConstraintStats{}
+	for _, cs := range stats.Constraints() {
+		if cs.Assets != 2 {
+			t.Errorf("%s evaluated against %d assets, want 2", cs.Constraint, cs.Assets)
+		}
+		got[cs.Constraint] = cs
+	}
+	for _, name := range []string{
+		"CFGCPStorageLoggingConstraint.require-storage-logging",
+		"GCPStorageLoggingConstraint.require_storage_logging_XX",
+	} {
+		if cs := got[name]; cs.Violations != 2 || cs.Errors != 0 || cs.EvaluationTime <= 0 {
+			t.Errorf("got stats %+v for %s, want 2 violations with an evaluation time", cs, name)
+		}
+	}
+}
+
+func TestAuditStatsAdd(t *testing.T) {
+	constraint := func(kind, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetKind(kind)
+		u.SetName(name)
+		return u
+	}
+	a, b, c := constraint("KindA", "a"), constraint("KindA", "b"), constraint("KindB", "c")
+	rs := &reviewStats{}
+	review := &statsReview{review: map[string]interface{}{}, stats: rs}
+	for _, tc := range []struct {
+		constraint *unstructured.Unstructured
+		matched    bool
+		err        error
+	}{
+		{a, true, nil},
+		{b, true, nil},
+		{c, false, errors.New("invalid ancestry path")},
+		{constraint("KindB", "unmatched"), false, nil},
+	} {
+		m := newStatsMatcher(fakeMatcher{matched: tc.matched, err: tc.err}, tc.constraint)
+		if matched, err := m.Match(review); matched != tc.matched || err != tc.err {
+			t.Errorf("Match() = %v, %v, want %v, %v", matched, err, tc.matched, tc.err)
+		}
+	}
+
+	responses := &cftypes.Responses{
+		ByTarget: map[string]*cftypes.Response{"target": {Results: []*cftypes.Result{
+			{Msg: "violation", Constraint: a},
+			{Msg: "invalid ancestry path", Constraint: c},
+		}}},
+		StatsEntries: []*instrumentation.StatsEntry{{
+			Scope:    instrumentation.TemplateScope,
+			StatsFor: "KindA",
+			Stats:    []*instrumentation.Stat{{Name: templateRunTimeNS, Value: uint64(10 * time.Millisecond)}},
+		}},
+	}
+	stats := NewAuditStats()
+	stats.add(rs, responses, nil)
+	stats.add(rs, nil, errors.New("review failed"))
+	want := []ConstraintStats{
+		{Constraint: "KindA.a", Assets: 2, Violations: 1, Errors: 1, EvaluationTime: 5 * time.Millisecond},
+		{Constraint: "KindA.b", Assets: 2, Errors: 1, EvaluationTime: 5 * time.Millisecond},
+		{Constraint: "KindB.c", Assets: 2, Errors: 2},
+	}
+	if diff := cmp.Diff(want, stats.Constraints(), cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("stats diff (-want +got):\n%s", diff)
+	}
+}
+
+type fakeMatcher struct {
+	matched bool
+	err     error
+}
+
+func (m fakeMatcher) Match(review interface{}) (bool, error) {
+	if _, ok := review.(*statsReview); ok {
+		return false, errors.New("matcher got the stats review")
+	}
+	return m.matched, m.err
+}
+
+func TestRecordEvaluated(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	result, err := v.ReviewJSON(RecordEvaluated(context.Background()), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluated := map[string]bool{}
+	for _, name := range result.Evaluated {
+		evaluated[name] = true
+	}
+	for _, cv := range result.ConstraintViolations {
+		if !evaluated[cv.name()] {
+			t.Errorf("violated constraint %s is not in the evaluated constraints %v", cv.name(), result.Evaluated)
+		}
+	}
+	if len(result.Evaluated) == 0 {
+		t.Errorf("no evaluated constraints")
+	}
+
+	result, err = v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Evaluated != nil {
+		t.Errorf("got evaluated constraints %v without RecordEvaluated", result.Evaluated)
+	}
+}
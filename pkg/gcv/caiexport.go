@@ -0,0 +1,383 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	asset2 "github.com/GoogleCloudPlatform/config-validator/pkg/asset"
+	"github.com/golang/glog"
+	"google.golang.org/api/iterator"
+)
+
+// AuditSummary summarizes the review of a CAI export.
+type AuditSummary struct {
+	// Objects are the export shards that were read.
+	Objects []string
+	// Assets is the number of assets reviewed.
+	Assets int
+	// AssetsWithViolations is the number of assets with at least one violation.
+	AssetsWithViolations int
+	// Violations is the total number of violations.
+	Violations int
+	// ViolationsByConstraint is the number of violations for each constraint, by "[Kind].[Name]".
+	ViolationsByConstraint map[string]int
+	// ReviewErrors is the number of assets that could not be reviewed.
+	ReviewErrors int
+	// MalformedLines is the number of lines in the export that could not be parsed.
+	MalformedLines int
+	// AncestorIAMPolicies is the number of ancestor IAM policies loaded from the export when the
+	// Validator has the AncestorIAM option.
+	AncestorIAMPolicies int
+	// EvaluationTime is the time the assets were evaluated at, if fixed by WithEvaluationTime or the
+	// Deterministic option.
+	EvaluationTime time.Time
+	// Stats are the statistics of each constraint over the review, see WithAuditStats.  The AuditStats
+	// of the context is used if it has one.
+	Stats *AuditStats
+}
+
+// ExportOption configures ReviewCAIExport.
+type ExportOption func(*exportScan)
+
+// ExportWorkers sets the number of assets reviewed concurrently, the default is the Validator's
+// worker count, see WithWorkerCount.
+func ExportWorkers(n int) ExportOption {
+	return func(s *exportScan) {
+		if n > 0 {
+			s.workers = n
+		}
+	}
+}
+
+// ExportMaxErrorRatio sets the fraction of malformed lines tolerated in each shard before the review
+// is aborted, the default aborts on the first malformed line.
+func ExportMaxErrorRatio(ratio float64) ExportOption {
+	return func(s *exportScan) {
+		s.reader.MaxErrorRatio = ratio
+	}
+}
+
+// ExportStorageClient sets the GCS client used to read the export, by default a client is created
+// with the application default credentials.
+func ExportStorageClient(client *storage.Client) ExportOption {
+	return func(s *exportScan) {
+		s.client = client
+	}
+}
+
+// OnResult sets a function that is called with the result of each reviewed asset, for example to
+// write violations to a sink.  It is called from a single goroutine at a time and errors abort the
+// review.
+func OnResult(fn func(ctx context.Context, result *Result) error) ExportOption {
+	return func(s *exportScan) {
+		s.onResult = fn
+	}
+}
+
+// exportScan holds the state of a ReviewCAIExport call.
+type exportScan struct {
+	workers  int
+	reader   asset2.JSONLReader
+	client   *storage.Client
+	onResult func(ctx context.Context, result *Result) error
+
+	mtx     sync.Mutex
+	summary *AuditSummary
+}
+
+// exportSource lists and opens the shards of an export.
+type exportSource interface {
+	list(ctx context.Context) ([]string, error)
+	open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// ReviewCAIExport reviews every asset in a CAI export and returns a summary of the violations.  The
+// URI is a gs://bucket/prefix, every object under the prefix is read as a shard of the export.  Local
+// paths are also accepted and read as a file or directory.  Shards may be gzip compressed.  Assets
+// are streamed from each shard and reviewed concurrently, so memory use does not grow with the size
+// of the export.
+func (v *Validator) ReviewCAIExport(ctx context.Context, uri string, opts ...ExportOption) (*AuditSummary, error) {
+	s := &exportScan{
+		workers: v.workerCount,
+		summary: &AuditSummary{ViolationsByConstraint: map[string]int{}},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	source, err := s.source(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := source.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no export objects found at %s", uri)
+	}
+	s.summary.Objects = objects
+	ctx = v.runContext(ctx)
+	if s.summary.Stats = auditStatsFrom(ctx); s.summary.Stats == nil {
+		s.summary.Stats = NewAuditStats()
+		ctx = WithAuditStats(ctx, s.summary.Stats)
+	}
+	if t, ok := EvaluationTime(ctx); ok {
+		s.summary.EvaluationTime = t
+	}
+	if v.ancestorIAM {
+		if err := s.loadAncestorIAM(ctx, v, source, objects); err != nil {
+			return s.summary, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	assets := make(chan map[string]interface{}, s.workers)
+	errs := make(chan error, s.workers)
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for asset := range assets {
+				if err := s.review(ctx, v, asset); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+	readErr := s.read(ctx, source, objects, assets)
+	close(assets)
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return s.summary, err
+	}
+	if readErr != nil {
+		return s.summary, readErr
+	}
+	return s.summary, nil
+}
+
+// source returns the exportSource for uri.
+func (s *exportScan) source(ctx context.Context, uri string) (exportSource, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid export uri %s: %w", uri, err)
+	}
+	if u.Scheme != "gs" {
+		return localExport(uri), nil
+	}
+	if s.client == nil {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create storage client: %w", err)
+		}
+		s.client = client
+	}
+	return &gcsExport{
+		bucket: s.client.Bucket(u.Host),
+		name:   u.Host,
+		prefix: strings.TrimLeft(u.Path, "/"),
+	}, nil
+}
+
+// read streams the assets in each object to assets until all objects are read or ctx is done.
+func (s *exportScan) read(ctx context.Context, source exportSource, objects []string, assets chan<- map[string]interface{}) error {
+	for _, object := range objects {
+		glog.V(2).Infof("reading export object %s", object)
+		r, err := source.open(ctx, object)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", object, err)
+		}
+		report, err := s.reader.Read(object, r, func(asset map[string]interface{}) error {
+			select {
+			case assets <- asset:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		r.Close()
+		s.mtx.Lock()
+		s.summary.MalformedLines += len(report.Errors)
+		s.mtx.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAncestorIAM replaces the stored ancestor IAM policies with those in the export, so that every
+// asset is reviewed with the policies it inherits regardless of the order of the export.
+func (s *exportScan) loadAncestorIAM(ctx context.Context, v *Validator, source exportSource, objects []string) error {
+	if err := v.ResetAncestorIAM(ctx); err != nil {
+		return err
+	}
+	for _, object := range objects {
+		r, err := source.open(ctx, object)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", object, err)
+		}
+		// Malformed lines are counted when the object is read for review.
+		_, err = s.reader.Read(object, r, func(asset map[string]interface{}) error {
+			added, err := v.addAncestorIAM(ctx, asset)
+			if err != nil {
+				// The asset is still reviewed and counted as a review error if it is malformed.
+				glog.Warningf("failed to load IAM policy of %v: %v", asset["name"], err)
+			}
+			if added {
+				s.summary.AncestorIAMPolicies++
+			}
+			return ctx.Err()
+		})
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	glog.V(1).Infof("loaded %d ancestor IAM policies", s.summary.AncestorIAMPolicies)
+	return nil
+}
+
+// review reviews a single asset and adds it to the summary.  Only errors from onResult are returned,
+// assets that fail review are counted and logged.
+func (s *exportScan) review(ctx context.Context, v *Validator, asset map[string]interface{}) error {
+	result, err := v.ReviewUnmarshalledJSON(ctx, asset)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.summary.Assets++
+	if err != nil {
+		glog.Warningf("failed to review %v: %v", asset["name"], err)
+		s.summary.ReviewErrors++
+		return nil
+	}
+	if len(result.ConstraintViolations) > 0 {
+		s.summary.AssetsWithViolations++
+	}
+	for _, cv := range result.ConstraintViolations {
+		s.summary.Violations++
+		s.summary.ViolationsByConstraint[cv.name()]++
+	}
+	if s.onResult != nil {
+		return s.onResult(ctx, result)
+	}
+	return nil
+}
+
+// decompress returns a reader for r that transparently decompresses gzip content.  Shards are
+// detected by content rather than name since exports may be compressed after they are written.
+func decompress(r io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		// Short or empty shards are read as is, the JSONL reader reports any problem.
+		return readCloser{Reader: br, Closer: r}, nil
+	}
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return readCloser{Reader: gz, Closer: r}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// gcsExport is an export stored in GCS.
+type gcsExport struct {
+	bucket *storage.BucketHandle
+	name   string
+	prefix string
+}
+
+func (e *gcsExport) list(ctx context.Context) ([]string, error) {
+	var names []string
+	it := e.bucket.Objects(ctx, &storage.Query{Prefix: e.prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list gs://%s/%s: %w", e.name, e.prefix, err)
+		}
+		if strings.HasSuffix(attrs.Name, "/") {
+			continue
+		}
+		names = append(names, attrs.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (e *gcsExport) open(ctx context.Context, name string) (io.ReadCloser, error) {
+	// Objects stored with gzip content encoding are decompressed by the client.
+	r, err := e.bucket.Object(name).NewReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(r)
+}
+
+// localExport is an export stored as a local file or directory.
+type localExport string
+
+func (e localExport) list(ctx context.Context) ([]string, error) {
+	var names []string
+	err := filepath.Walk(string(e), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			names = append(names, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", string(e), err)
+	}
+	return names, nil
+}
+
+func (e localExport) open(ctx context.Context, name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(f)
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func writeExportShard(t *testing.T, path string, lines []string, compress bool) {
+	var buf bytes.Buffer
+	for _, line := range lines {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(compact.Bytes())
+		buf.WriteByte('\n')
+	}
+	content := buf.Bytes()
+	if compress {
+		var gz bytes.Buffer
+		w := gzip.NewWriter(&gz)
+		if _, err := w.Write(content); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		content = gz.Bytes()
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReviewCAIExport(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	dir := t.TempDir()
+	writeExportShard(t, filepath.Join(dir, "resource-0.json"), []string{storageAssetNoLoggingJSON, storageAssetNoLoggingJSON}, false)
+	writeExportShard(t, filepath.Join(dir, "resource-1.json.gz"), []string{storageAssetNoLoggingJSON}, true)
+
+	var results int
+	summary, err := v.ReviewCAIExport(context.Background(), dir, ExportWorkers(2), OnResult(func(ctx context.Context, result *Result) error {
+		results++
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &AuditSummary{
+		Objects:              []string{filepath.Join(dir, "resource-0.json"), filepath.Join(dir, "resource-1.json.gz")},
+		Assets:               3,
+		AssetsWithViolations: 3,
+		Violations:           6,
+		ViolationsByConstraint: map[string]int{
+			"CFGCPStorageLoggingConstraint.require-storage-logging":  3,
+			"GCPStorageLoggingConstraint.require_storage_logging_XX": 3,
+		},
+	}
+	if diff := cmp.Diff(want, summary, cmpopts.IgnoreFields(AuditSummary{}, "Stats")); diff != "" {
+		t.Errorf("summary diff (-want +got):\n%s", diff)
+	}
+	if results != 3 {
+		t.Errorf("got %d results, want 3", results)
+	}
+	stats := map[string]ConstraintStats{}
+	for _, cs := range summary.Stats.Constraints() {
+		stats[cs.Constraint] = cs
+	}
+	for name := range want.ViolationsByConstraint {
+		cs := stats[name]
+		if cs.Assets != 3 || cs.Violations != 3 || cs.Errors != 0 || cs.EvaluationTime <= 0 {
+			t.Errorf("got stats %+v for %s, want 3 assets and violations with an evaluation time", cs, name)
+		}
+	}
+}
+
+func TestReviewCAIExportErrors(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	ctx := context.Background()
+
+	if _, err := v.ReviewCAIExport(ctx, t.TempDir()); err == nil || !strings.Contains(err.Error(), "no export objects") {
+		t.Errorf("got error %v for empty export, want no export objects", err)
+	}
+
+	dir := t.TempDir()
+	writeExportShard(t, filepath.Join(dir, "resource-0.json"), []string{storageAssetNoLoggingJSON, storageAssetNoLoggingJSON}, false)
+	wantErr := errors.New("sink failed")
+	_, err = v.ReviewCAIExport(ctx, dir, OnResult(func(ctx context.Context, result *Result) error {
+		return wantErr
+	}))
+	if !errors.Is(err, wantErr) {
+		t.Errorf("got error %v, want %v", err, wantErr)
+	}
+}
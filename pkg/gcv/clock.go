@@ -0,0 +1,170 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/topdown"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Clock provides the time reviews are evaluated at.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock used by Deterministic when no other Clock is set.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// fixedClock always returns the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// FixedClock returns a Clock that always returns t.
+func FixedClock(t time.Time) Clock {
+	return fixedClock(t)
+}
+
+// WithClock sets the clock reviews are evaluated at.  Unless a review requests an evaluation time with
+// WithEvaluationTime, the time from the clock is exposed to templates as evaluation_time and returned
+// by time.now_ns(), the same way as a requested evaluation time.  Without a clock evaluation_time is
+// only set when requested.
+func WithClock(clock Clock) Option {
+	return func(o *initOptions) {
+		o.clock = clock
+	}
+}
+
+// Deterministic reads the clock once for each run of reviews, a ParallelValidator.Review request, a
+// ReviewCAIExport or a single asset review, so that every asset in the run is evaluated at the same
+// time.  Evaluating archived assets again at that time, with WithEvaluationTime, reproduces the results
+// of templates that use evaluation_time or time.now_ns().  The clock is the system clock unless set
+// with WithClock.
+func Deterministic() Option {
+	return func(o *initOptions) {
+		o.deterministic = true
+	}
+}
+
+// runContext returns ctx with the evaluation time fixed for a run of reviews in deterministic mode.
+func (v *Validator) runContext(ctx context.Context) context.Context {
+	if !v.deterministic {
+		return ctx
+	}
+	if _, ok := EvaluationTime(ctx); ok {
+		return ctx
+	}
+	return WithEvaluationTime(ctx, v.clock.Now())
+}
+
+func init() {
+	// The rego driver of the CF client has no option for the time of an evaluation, so time.now_ns() is
+	// replaced with a builtin that returns the evaluation time of the review context, and the wall clock
+	// time of the evaluation otherwise.
+	topdown.RegisterBuiltinFunc(ast.NowNanos.Name, func(bctx topdown.BuiltinContext, _ []*ast.Term, iter func(*ast.Term) error) error {
+		if bctx.Context != nil {
+			if t, ok := EvaluationTime(bctx.Context); ok {
+				return iter(ast.NumberTerm(json.Number(strconv.FormatInt(t.UnixNano(), 10))))
+			}
+		}
+		return iter(bctx.Time)
+	})
+}
+
+// evaluationContext returns ctx with the time the review is evaluated at, the time requested in ctx or
+// else the time from the clock, if any.
+func (v *Validator) evaluationContext(ctx context.Context) context.Context {
+	if _, ok := EvaluationTime(ctx); ok || v.clock == nil {
+		return ctx
+	}
+	return WithEvaluationTime(ctx, v.clock.Now())
+}
+
+// formatEvaluationTime returns the evaluation time of ctx as it is exposed to templates, or "" if none
+// is set.
+func formatEvaluationTime(ctx context.Context) string {
+	t, ok := EvaluationTime(ctx)
+	if !ok {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// setEvaluationTime sets evaluation_time in the JSON object of a review to evaluationTime, or removes
+// it if evaluationTime is "", so that templates only see the time of the review and never one from
+// the reviewed object.  Reviews that aren't objects are returned as is.
+func setEvaluationTime(data []byte, evaluationTime string) ([]byte, error) {
+	if len(data) == 0 || data[0] != '{' {
+		return data, nil
+	}
+	if evaluationTime == "" && !bytes.Contains(data, []byte(`"`+evaluationTimeKey+`"`)) {
+		return data, nil
+	}
+	review := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &review); err != nil {
+		return nil, err
+	}
+	delete(review, evaluationTimeKey)
+	if evaluationTime != "" {
+		review[evaluationTimeKey], _ = json.Marshal(evaluationTime)
+	}
+	return json.Marshal(review)
+}
+
+// annotateEvaluationTime sets the evaluation time annotation of the objects of a K8S admission request
+// converted from a CAI asset to the evaluation time of ctx, or removes it if none is set, so that the
+// annotation can't be set by the asset.
+func annotateEvaluationTime(ctx context.Context, request *admissionv1.AdmissionRequest) error {
+	evaluationTime := formatEvaluationTime(ctx)
+	for _, raw := range []*[]byte{&request.Object.Raw, &request.OldObject.Raw} {
+		if len(*raw) == 0 || (evaluationTime == "" && !bytes.Contains(*raw, []byte(evaluationTimeAnnotation))) {
+			continue
+		}
+		object := &unstructured.Unstructured{}
+		if err := object.UnmarshalJSON(*raw); err != nil {
+			return fmt.Errorf("failed to unmarshal admission request object: %w", err)
+		}
+		annotations := object.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		delete(annotations, evaluationTimeAnnotation)
+		if evaluationTime != "" {
+			annotations[evaluationTimeAnnotation] = evaluationTime
+		}
+		object.SetAnnotations(annotations)
+		data, err := object.MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("failed to marshal admission request object: %w", err)
+		}
+		*raw = data
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+)
+
+var clockTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// steppingClock advances by a second each time it is read.
+type steppingClock struct {
+	mtx   sync.Mutex
+	reads int
+}
+
+func (c *steppingClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.reads++
+	return clockTime.Add(time.Duration(c.reads-1) * time.Second)
+}
+
+// evaluationTimeTemplate reports the evaluation time of every asset.
+const evaluationTimeTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpevaluationtimeconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPEvaluationTimeConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: "object"
+          properties: {}
+  targets:
+    - target: "validation.gcp.forsetisecurity.org"
+      rego: |
+        package templates.gcp.GCPEvaluationTimeConstraintV1
+
+        violation[{"msg": message}] {
+        	message := object.get(input.review, "evaluation_time", "unset")
+        }
+`
+
+const evaluationTimeConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPEvaluationTimeConstraintV1
+metadata:
+  name: evaluation-time
+spec:
+  severity: low
+  parameters: {}
+`
+
+func newClockValidator(t *testing.T, opts ...Option) *Validator {
+	t.Helper()
+	v, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(evaluationTimeTemplate)},
+		{Path: "constraint.yaml", Content: []byte(evaluationTimeConstraint)},
+	}, []string{"package validator.gcp.lib\n"}, opts...)
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	return v
+}
+
+// reviewedMessage returns the message of the single violation of the asset.
+func reviewedMessage(t *testing.T, ctx context.Context, v *Validator, asset map[string]interface{}) string {
+	t.Helper()
+	result, err := v.ReviewUnmarshalledJSON(ctx, asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations, err := result.ToViolations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 {
+		t.Fatalf("got %d violations, want 1", len(violations))
+	}
+	return violations[0].Message
+}
+
+func storageAssetNoLoggingMap(t *testing.T) map[string]interface{} {
+	t.Helper()
+	asset := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(storageAssetNoLoggingJSON), &asset); err != nil {
+		t.Fatal(err)
+	}
+	return asset
+}
+
+func TestWithClock(t *testing.T) {
+	ctx := context.Background()
+	clock := &steppingClock{}
+	v := newClockValidator(t, WithClock(clock))
+	for _, want := range []string{"2020-01-02T03:04:05Z", "2020-01-02T03:04:06Z"} {
+		if got := reviewedMessage(t, ctx, v, storageAssetNoLoggingMap(t)); got != want {
+			t.Errorf("got %s %v, want %v", evaluationTimeKey, got, want)
+		}
+	}
+
+	// A requested evaluation time takes precedence over the clock.
+	got := reviewedMessage(t, WithEvaluationTime(ctx, clockTime.Add(time.Hour)), v, storageAssetNoLoggingMap(t))
+	if want := "2020-01-02T04:04:05Z"; got != want {
+		t.Errorf("got %s %v, want %v", evaluationTimeKey, got, want)
+	}
+}
+
+func TestEvaluationTimeNotInAsset(t *testing.T) {
+	ctx := WithEvaluationTime(context.Background(), clockTime)
+	v := newClockValidator(t)
+
+	asset := storageAssetNoLoggingMap(t)
+	if got, want := reviewedMessage(t, ctx, v, asset), "2020-01-02T03:04:05Z"; got != want {
+		t.Errorf("got %s %v, want %v", evaluationTimeKey, got, want)
+	}
+	if _, found := asset[evaluationTimeKey]; found {
+		t.Errorf("%s was added to the reviewed asset", evaluationTimeKey)
+	}
+
+	// An asset can't set its own evaluation time.
+	asset = storageAssetNoLoggingMap(t)
+	asset[evaluationTimeKey] = "2030-01-01T00:00:00Z"
+	if got, want := reviewedMessage(t, ctx, v, asset), "2020-01-02T03:04:05Z"; got != want {
+		t.Errorf("got %s %v, want %v", evaluationTimeKey, got, want)
+	}
+	if got := reviewedMessage(t, context.Background(), v, asset); got != "unset" {
+		t.Errorf("got %s %v without an evaluation time, want unset", evaluationTimeKey, got)
+	}
+	if got, want := asset[evaluationTimeKey], "2030-01-01T00:00:00Z"; got != want {
+		t.Errorf("got asset %s %v, want it unchanged %v", evaluationTimeKey, got, want)
+	}
+}
+
+// timeNowTemplate reports the time returned by time.now_ns() for every asset.
+const timeNowTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcptimenowconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPTimeNowConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: "object"
+          properties: {}
+  targets:
+    - target: "validation.gcp.forsetisecurity.org"
+      rego: |
+        package templates.gcp.GCPTimeNowConstraintV1
+
+        violation[{"msg": message}] {
+        	message := sprintf("%d", [time.now_ns()])
+        }
+`
+
+const timeNowConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPTimeNowConstraintV1
+metadata:
+  name: time-now
+spec:
+  severity: low
+  parameters: {}
+`
+
+func TestTimeNowFollowsClock(t *testing.T) {
+	ctx := context.Background()
+	v, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(timeNowTemplate)},
+		{Path: "constraint.yaml", Content: []byte(timeNowConstraint)},
+	}, []string{"package validator.gcp.lib\n"}, WithClock(FixedClock(clockTime)))
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	if got, want := reviewedMessage(t, ctx, v, storageAssetNoLoggingMap(t)), strconv.FormatInt(clockTime.UnixNano(), 10); got != want {
+		t.Errorf("got time.now_ns() %v, want the clock's %v", got, want)
+	}
+	asOf := clockTime.Add(-time.Hour)
+	if got, want := reviewedMessage(t, WithEvaluationTime(ctx, asOf), v, storageAssetNoLoggingMap(t)), strconv.FormatInt(asOf.UnixNano(), 10); got != want {
+		t.Errorf("got time.now_ns() %v, want the evaluation time's %v", got, want)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	ctx := context.Background()
+	clock := &steppingClock{}
+	v := newClockValidator(t, WithClock(clock), Deterministic())
+
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	pv := NewParallelValidator(stopChannel, v)
+	assets := []*validator.Asset{storageAssetNoLogging(), storageAssetNoLogging(), storageAssetNoLogging()}
+	if _, err := pv.Review(ctx, &validator.ReviewRequest{Assets: assets}); err != nil {
+		t.Fatal(err)
+	}
+	if clock.reads != 1 {
+		t.Errorf("got %d clock reads for one review request, want 1", clock.reads)
+	}
+
+	path := filepath.Join(t.TempDir(), "resource.json")
+	writeExportShard(t, path, []string{storageAssetNoLoggingJSON, storageAssetNoLoggingJSON}, false)
+	summary, err := v.ReviewCAIExport(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clock.reads != 2 {
+		t.Errorf("got %d clock reads after export, want 2", clock.reads)
+	}
+	if want := clockTime.Add(time.Second); !summary.EvaluationTime.Equal(want) {
+		t.Errorf("got evaluation time %v, want %v", summary.EvaluationTime, want)
+	}
+}
+
+func TestDeterministicSystemClock(t *testing.T) {
+	v := newClockValidator(t, Deterministic())
+	if got := reviewedMessage(t, context.Background(), v, storageAssetNoLoggingMap(t)); got == "unset" {
+		t.Errorf("expected %s in deterministic mode", evaluationTimeKey)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// BundleManifestName is the name of the manifest in a bundle archive.
+	BundleManifestName = "manifest.json"
+	// BundleArchiveFormatVersion is the version of the bundle archive format written by
+	// WriteBundleArchive.  Archives with a newer version are rejected.
+	BundleArchiveFormatVersion = 1
+	// BundleArchiveSuffix is the conventional suffix of bundle archives.
+	BundleArchiveSuffix = ".tar.gz"
+)
+
+// BundleManifest describes the contents of a bundle archive.
+type BundleManifest struct {
+	FormatVersion int `json:"formatVersion"`
+	// Templates, Constraints and Libraries are the number of constraint templates, constraints and
+	// rego library files in the bundle.
+	Templates   int `json:"templates"`
+	Constraints int `json:"constraints"`
+	Libraries   int `json:"libraries"`
+	// TemplateVersions and ConstraintVersions count the templates and constraints by apiVersion, eg
+	// templates.gatekeeper.sh/v1beta1.
+	TemplateVersions   map[string]int `json:"templateVersions"`
+	ConstraintVersions map[string]int `json:"constraintVersions"`
+	// Files are the files in the bundle, sorted by path.
+	Files []BundleManifestFile `json:"files"`
+}
+
+// BundleManifestFile is a file in a bundle archive.
+type BundleManifestFile struct {
+	// Path is the path of the file in the archive, under policies/ or lib/.
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// IsBundleArchive returns true if path has the conventional suffix of bundle archives.  Only local
+// files are detected.
+func IsBundleArchive(path string) bool {
+	return isLocalPath(path) && strings.HasSuffix(path, BundleArchiveSuffix)
+}
+
+// WriteBundleArchive writes the templates, constraints and rego library found in dirs and libDir,
+// resolved as in NewConfiguration, to w as a gzipped tarball.  The archive has the policy bundle
+// layout, with templates and constraints under policies/ and the library under lib/, and a manifest.
+// The policies are loaded before they are written so that only valid bundles are packaged.  The
+// archive only depends on the contents of the files, so packaging the same policies twice produces
+// the same archive.
+func WriteBundleArchive(w io.Writer, dirs []string, libDir string) (*BundleManifest, error) {
+	dirs, libDirs := ResolveBundles(dirs, libDir)
+	if len(libDirs) == 0 {
+		return nil, errors.New("no policy library set")
+	}
+	files := map[string][]byte{}
+	for _, dir := range dirs {
+		if err := readArchiveFiles(files, dir, bundlePoliciesDir, ".yaml"); err != nil {
+			return nil, err
+		}
+	}
+	for _, dir := range libDirs {
+		if err := readArchiveFiles(files, dir, bundleLibDir, ".rego"); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest, _, err := loadArchiveFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal bundle manifest")
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	write := func(name string, content []byte) error {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return errors.Wrapf(err, "failed to write %s", name)
+		}
+		_, err := tw.Write(content)
+		return errors.Wrapf(err, "failed to write %s", name)
+	}
+	if err := write(BundleManifestName, manifestJSON); err != nil {
+		return nil, err
+	}
+	for _, file := range manifest.Files {
+		if err := write(file.Path, files[file.Path]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to write bundle archive")
+	}
+	if err := gw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to write bundle archive")
+	}
+	return manifest, nil
+}
+
+// readArchiveFiles adds the files with suffix under dir to files, keyed by their path in the archive
+// under prefix.
+func readArchiveFiles(files map[string][]byte, dir, prefix, suffix string) error {
+	dirPath, err := NewPath(dir)
+	if err != nil {
+		return err
+	}
+	dirFiles, err := dirPath.ReadAll(context.Background(), SuffixPredicate(suffix))
+	if err != nil {
+		return err
+	}
+	for _, file := range dirFiles {
+		rel, err := relativePath(dir, file.Path)
+		if err != nil {
+			return err
+		}
+		name := path.Join(prefix, filepath.ToSlash(rel))
+		if _, found := files[name]; found {
+			return errors.Errorf("%s conflicts with another file at %s in the bundle", file.Path, name)
+		}
+		files[name] = file.Content
+	}
+	return nil
+}
+
+// relativePath returns the path of file relative to dir, or the name of file if dir is the file itself.
+func relativePath(dir, file string) (string, error) {
+	rel := strings.TrimPrefix(strings.TrimPrefix(file, strings.TrimSuffix(dir, "/")), "/")
+	if isLocalPath(dir) {
+		var err error
+		if rel, err = filepath.Rel(dir, file); err != nil {
+			return "", errors.Wrapf(err, "failed to find the path of %s in %s", file, dir)
+		}
+	}
+	if rel == "" || rel == "." {
+		rel = filepath.Base(file)
+	}
+	return rel, nil
+}
+
+// ReadBundleArchive reads a bundle archive written by WriteBundleArchive and returns its
+// configuration.  The files in the archive are checked against the manifest.
+func ReadBundleArchive(r io.Reader) (*Configuration, *BundleManifest, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read bundle archive")
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+
+	var manifestJSON []byte
+	files := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to read bundle archive")
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, nil, errors.Errorf("bundle archive entry %s is not a regular file", header.Name)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to read %s from bundle archive", header.Name)
+		}
+		if header.Name == BundleManifestName {
+			manifestJSON = content
+			continue
+		}
+		if path.Clean(header.Name) != header.Name || path.IsAbs(header.Name) || strings.HasPrefix(header.Name, "../") {
+			return nil, nil, errors.Errorf("bundle archive entry %s has an invalid path", header.Name)
+		}
+		files[header.Name] = content
+	}
+	if manifestJSON == nil {
+		return nil, nil, errors.Errorf("bundle archive has no %s", BundleManifestName)
+	}
+	var manifest BundleManifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to parse %s", BundleManifestName)
+	}
+	if manifest.FormatVersion > BundleArchiveFormatVersion {
+		return nil, nil, errors.Errorf("bundle archive format version %d is newer than the supported version %d",
+			manifest.FormatVersion, BundleArchiveFormatVersion)
+	}
+
+	want, config, err := loadArchiveFiles(files)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !reflect.DeepEqual(want.Files, manifest.Files) {
+		return nil, nil, errors.New("bundle archive files don't match the manifest")
+	}
+	want.FormatVersion = manifest.FormatVersion
+	if !reflect.DeepEqual(want, &manifest) {
+		return nil, nil, errors.New("bundle archive contents don't match the manifest counts")
+	}
+	return config, &manifest, nil
+}
+
+// loadArchiveFiles loads the configuration from the files of a bundle archive, keyed by path, and
+// returns the manifest describing them.
+func loadArchiveFiles(files map[string][]byte) (*BundleManifest, *Configuration, error) {
+	manifest := &BundleManifest{
+		FormatVersion:      BundleArchiveFormatVersion,
+		TemplateVersions:   map[string]int{},
+		ConstraintVersions: map[string]int{},
+	}
+	var policyFiles []*PolicyFile
+	var libs []string
+	for _, name := range sortedFileNames(files) {
+		content := files[name]
+		sum := sha256.Sum256(content)
+		manifest.Files = append(manifest.Files, BundleManifestFile{
+			Path:   name,
+			Size:   int64(len(content)),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+		switch {
+		case strings.HasPrefix(name, bundlePoliciesDir+"/") && strings.HasSuffix(name, ".yaml"):
+			policyFiles = append(policyFiles, &PolicyFile{Path: name, Content: content})
+		case strings.HasPrefix(name, bundleLibDir+"/") && strings.HasSuffix(name, ".rego"):
+			libs = append(libs, string(content))
+		default:
+			return nil, nil, errors.Errorf("unexpected file %s in bundle archive", name)
+		}
+	}
+	sort.Strings(libs)
+	manifest.Libraries = len(libs)
+
+	objects, err := LoadUnstructuredFromContents(policyFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, u := range objects {
+		countObject(manifest, u)
+	}
+	config, err := NewConfigurationFromContents(objects, libs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifest, config, nil
+}
+
+func countObject(manifest *BundleManifest, u *unstructured.Unstructured) {
+	if u.GetKind() == "ConstraintTemplate" {
+		manifest.Templates++
+		manifest.TemplateVersions[u.GetAPIVersion()]++
+		return
+	}
+	manifest.Constraints++
+	manifest.ConstraintVersions[u.GetAPIVersion()]++
+}
+
+func sortedFileNames(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
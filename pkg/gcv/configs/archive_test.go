@@ -0,0 +1,213 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBundleArchive(t *testing.T) {
+	bundle := newTestBundle(t)
+	var buf bytes.Buffer
+	manifest, err := WriteBundleArchive(&buf, []string{bundle}, "")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	var paths []string
+	for _, file := range manifest.Files {
+		paths = append(paths, file.Path)
+	}
+	wantPaths := []string{
+		"lib/constraints.rego",
+		"lib/util.rego",
+		"policies/constraints/gcp_storage_logging_constraint.yaml",
+		"policies/templates/gcp_storage_logging_template.yaml",
+	}
+	if diff := cmp.Diff(wantPaths, paths); diff != "" {
+		t.Errorf("manifest paths diff (-want +got):\n%s", diff)
+	}
+	if manifest.Templates != 1 || manifest.Constraints != 1 || manifest.Libraries != 2 {
+		t.Errorf("got %d templates, %d constraints and %d libraries, want 1, 1 and 2",
+			manifest.Templates, manifest.Constraints, manifest.Libraries)
+	}
+
+	var again bytes.Buffer
+	if _, err := WriteBundleArchive(&again, []string{bundle}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Errorf("bundle archive is not reproducible")
+	}
+
+	config, gotManifest, err := ReadBundleArchive(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if diff := cmp.Diff(manifest, gotManifest); diff != "" {
+		t.Errorf("manifest diff (-want +got):\n%s", diff)
+	}
+	want, err := NewConfiguration([]string{bundle}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.GCPTemplates) != len(want.GCPTemplates) || len(config.GCPConstraints) != len(want.GCPConstraints) {
+		t.Errorf("got %d templates and %d constraints, want %d and %d", len(config.GCPTemplates),
+			len(config.GCPConstraints), len(want.GCPTemplates), len(want.GCPConstraints))
+	}
+	if diff := cmp.Diff(want.regoLib, config.regoLib); diff != "" {
+		t.Errorf("rego library diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestBundleArchiveLibDir(t *testing.T) {
+	bundle := newTestBundle(t)
+	var buf bytes.Buffer
+	if _, err := WriteBundleArchive(&buf, []string{filepath.Join(bundle, "policies")}, ""); err == nil {
+		t.Errorf("expected error without a policy library")
+	}
+	if _, err := WriteBundleArchive(&buf, []string{filepath.Join(bundle, "policies")}, filepath.Join(bundle, "lib")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// rewriteArchive returns the bundle archive with each file's content replaced by edit.
+func rewriteArchive(t *testing.T, archive []byte, edit func(name string, content []byte) []byte) []byte {
+	t.Helper()
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content = edit(header.Name, content)
+		header.Size = int64(len(content))
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadBundleArchiveErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := WriteBundleArchive(&buf, []string{newTestBundle(t)}, ""); err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+
+	var testCases = []struct {
+		name    string
+		edit    func(name string, content []byte) []byte
+		wantErr string
+	}{
+		{
+			name: "tampered file",
+			edit: func(name string, content []byte) []byte {
+				if name == "lib/util.rego" {
+					return append(content, "\n# changed\n"...)
+				}
+				return content
+			},
+			wantErr: "don't match the manifest",
+		},
+		{
+			name: "tampered counts",
+			edit: func(name string, content []byte) []byte {
+				if name != BundleManifestName {
+					return content
+				}
+				var manifest BundleManifest
+				if err := json.Unmarshal(content, &manifest); err != nil {
+					t.Fatal(err)
+				}
+				manifest.Constraints = 2
+				content, err := json.Marshal(manifest)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return content
+			},
+			wantErr: "don't match the manifest counts",
+		},
+		{
+			name: "newer format",
+			edit: func(name string, content []byte) []byte {
+				if name == BundleManifestName {
+					return bytes.Replace(content, []byte(`"formatVersion": 1`), []byte(`"formatVersion": 2`), 1)
+				}
+				return content
+			},
+			wantErr: "newer than the supported version",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := ReadBundleArchive(bytes.NewReader(rewriteArchive(t, archive, tc.edit)))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	if _, _, err := ReadBundleArchive(strings.NewReader("not an archive")); err == nil {
+		t.Errorf("expected error for invalid archive")
+	}
+}
+
+func TestIsBundleArchive(t *testing.T) {
+	for path, want := range map[string]bool{
+		"bundle.tar.gz":    true,
+		"/a/policies":      false,
+		"gs://b/p.tar.gz":  false,
+		"policies.tar.zst": false,
+	} {
+		if got := IsBundleArchive(path); got != want {
+			t.Errorf("IsBundleArchive(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
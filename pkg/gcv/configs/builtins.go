@@ -0,0 +1,160 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TemplateBuiltins returns the sorted names of the builtin functions called by the template's rego,
+// including its libs.
+func TemplateBuiltins(templ *cftemplates.ConstraintTemplate) ([]string, error) {
+	called := map[string]bool{}
+	for _, target := range templ.Spec.Targets {
+		for idx, src := range append([]string{target.Rego}, target.Libs...) {
+			if strings.TrimSpace(src) == "" {
+				continue
+			}
+			module, err := ast.ParseModule(fmt.Sprintf("%s/%d.rego", templ.Name, idx), src)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse rego of template %s", templ.Name)
+			}
+			// Calls are either whole expressions, eg http.send(req, resp), or nested terms, eg
+			// resp := http.send(req).
+			ast.WalkTerms(module, func(term *ast.Term) bool {
+				if call, ok := term.Value.(ast.Call); ok {
+					if name := call[0].Value.String(); ast.BuiltinMap[name] != nil {
+						called[name] = true
+					}
+				}
+				return false
+			})
+			ast.WalkExprs(module, func(expr *ast.Expr) bool {
+				if expr.IsCall() {
+					if name := expr.Operator().String(); ast.BuiltinMap[name] != nil {
+						called[name] = true
+					}
+				}
+				return false
+			})
+		}
+	}
+	var names []string
+	for name := range called {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DisabledBuiltinUses returns an error issue for each template in the configuration that calls one of
+// the builtins.  Templates that can't be parsed are skipped, compiling them reports the error.
+func (c *Configuration) DisabledBuiltinUses(builtins []string) []*Issue {
+	disabled := map[string]bool{}
+	for _, builtin := range builtins {
+		if builtin != "" {
+			disabled[builtin] = true
+		}
+	}
+	if len(disabled) == 0 {
+		return nil
+	}
+	var issues []*Issue
+	for _, ct := range c.Templates() {
+		called, err := TemplateBuiltins(ct)
+		if err != nil {
+			continue
+		}
+		for _, name := range called {
+			if disabled[name] {
+				issues = append(issues, &Issue{
+					Path:    SourcePath(ct),
+					Kind:    "ConstraintTemplate",
+					Name:    ct.Name,
+					Message: "calls disabled builtin " + name,
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// WithoutTemplates returns a copy of the configuration without the named templates and the
+// constraints of their kinds.  The configuration is not modified.
+func (c *Configuration) WithoutTemplates(names map[string]bool) *Configuration {
+	return c.without(names, nil)
+}
+
+// WithoutConstraints returns a copy of the configuration without the given constraints.  The
+// configuration is not modified.
+func (c *Configuration) WithoutConstraints(constraints map[*unstructured.Unstructured]bool) *Configuration {
+	return c.without(nil, constraints)
+}
+
+// without returns a copy of the configuration without the named templates, the constraints of their
+// kinds and the given constraints.
+func (c *Configuration) without(names map[string]bool, skipped map[*unstructured.Unstructured]bool) *Configuration {
+	ret := newConfiguration()
+	kinds := map[string]bool{}
+	filterTemplates := func(templates []*cftemplates.ConstraintTemplate) []*cftemplates.ConstraintTemplate {
+		var kept []*cftemplates.ConstraintTemplate
+		for _, ct := range templates {
+			if names[ct.Name] {
+				kinds[ct.Spec.CRD.Spec.Names.Kind] = true
+				continue
+			}
+			ret.templateNames[ct.Name] = ct
+			ret.templateKinds[ct.Spec.CRD.Spec.Names.Kind] = ct
+			kept = append(kept, ct)
+		}
+		return kept
+	}
+	ret.GCPTemplates = filterTemplates(c.GCPTemplates)
+	ret.K8STemplates = filterTemplates(c.K8STemplates)
+	ret.TFTemplates = filterTemplates(c.TFTemplates)
+	for target, templates := range c.CustomTemplates {
+		if kept := filterTemplates(templates); len(kept) != 0 {
+			ret.CustomTemplates[target] = kept
+		}
+	}
+
+	filterConstraints := func(constraints []*unstructured.Unstructured) []*unstructured.Unstructured {
+		var kept []*unstructured.Unstructured
+		for _, constraint := range constraints {
+			if !kinds[constraint.GetKind()] && !skipped[constraint] {
+				kept = append(kept, constraint)
+			}
+		}
+		return kept
+	}
+	ret.GCPConstraints = filterConstraints(c.GCPConstraints)
+	ret.K8SConstraints = filterConstraints(c.K8SConstraints)
+	ret.TFConstraints = filterConstraints(c.TFConstraints)
+	for target, constraints := range c.CustomConstraints {
+		if kept := filterConstraints(constraints); len(kept) != 0 {
+			ret.CustomConstraints[target] = kept
+		}
+	}
+	ret.Warnings = append(ret.Warnings, c.Warnings...)
+	ret.ExpansionTemplates = c.ExpansionTemplates
+	return ret
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const builtinsTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpbuiltinstestconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPBuiltinsTestConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: object
+  targets:
+    - target: validation.gcp.forsetisecurity.org
+      rego: |
+        package templates.gcp.GCPBuiltinsTestConstraintV1
+
+        violation[{"msg": message}] {
+        	resp := http.send({"method": "get", "url": input.review.name})
+        	resp.status_code != 200
+        	time.now_ns() > 0
+        	message := sprintf("%v", [resp.status_code])
+        }
+`
+
+const builtinsConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: GCPBuiltinsTestConstraintV1
+metadata:
+  name: builtins
+`
+
+func TestDisabledBuiltinUses(t *testing.T) {
+	objects, err := LoadUnstructuredFromContents([]*PolicyFile{
+		{Path: "template.yaml", Content: []byte(builtinsTemplate)},
+		{Path: "constraint.yaml", Content: []byte(builtinsConstraint)},
+		{Path: "parameters_template.yaml", Content: []byte(parametersTemplate)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := NewConfigurationFromContents(objects, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	var templ = config.GCPTemplates[0]
+	if templ.Name != "gcpbuiltinstestconstraintv1" {
+		templ = config.GCPTemplates[1]
+	}
+	got, err := TemplateBuiltins(templ)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	want := []string{"assign", "gt", "http.send", "neq", "sprintf", "time.now_ns"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("builtins diff (-want +got):\n%s", diff)
+	}
+
+	if issues := config.DisabledBuiltinUses([]string{"", "net.lookup_ip_addr"}); len(issues) != 0 {
+		t.Errorf("got issues %v for unused builtins", issues)
+	}
+	var messages []string
+	for _, issue := range config.DisabledBuiltinUses([]string{"http.send", "time.now_ns"}) {
+		if issue.Warning || issue.Path != "template.yaml" || issue.Name != templ.Name {
+			t.Errorf("got issue %s, want error for template.yaml", issue)
+		}
+		messages = append(messages, issue.Message)
+	}
+	if diff := cmp.Diff([]string{"calls disabled builtin http.send", "calls disabled builtin time.now_ns"}, messages); diff != "" {
+		t.Errorf("issues diff (-want +got):\n%s", diff)
+	}
+
+	filtered := config.WithoutTemplates(map[string]bool{templ.Name: true})
+	if len(filtered.GCPTemplates) != 1 || filtered.GCPTemplates[0].Name != "gcpparameterstestconstraintv1" {
+		t.Errorf("got templates %v, want only the parameters template", filtered.GCPTemplates)
+	}
+	if len(filtered.GCPConstraints) != 0 {
+		t.Errorf("got constraints %v, want none", filtered.GCPConstraints)
+	}
+	if len(config.GCPTemplates) != 2 || len(config.GCPConstraints) != 1 {
+		t.Errorf("WithoutTemplates modified the configuration")
+	}
+}
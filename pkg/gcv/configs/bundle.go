@@ -0,0 +1,94 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// bundlePoliciesDir is the directory of a policy bundle holding the templates and constraints.
+	bundlePoliciesDir = "policies"
+	// bundleLibDir is the directory of a policy bundle holding the rego library.
+	bundleLibDir = "lib"
+)
+
+// IsBundle returns true if dir is the root of a policy bundle in the layout used by
+// `gcloud beta terraform vet`, with templates and constraints under policies/ and the rego library
+// under lib/.  Only local directories are detected.
+func IsBundle(dir string) bool {
+	for _, sub := range []string{bundlePoliciesDir, bundleLibDir} {
+		info, err := os.Stat(filepath.Join(dir, sub))
+		if err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// ResolveBundles returns the policy and library directories to load.  Each policy directory that is a
+// bundle root is replaced with its policies/ directory so that other files in the bundle, such as
+// samples, are not loaded.  If libDir is empty the lib/ directories of the bundles are used, a libDir
+// that is itself a bundle root is replaced with its lib/ directory.
+func ResolveBundles(dirs []string, libDir string) ([]string, []string) {
+	var policyDirs, bundleLibDirs []string
+	for _, dir := range dirs {
+		if !IsBundle(dir) {
+			policyDirs = append(policyDirs, dir)
+			continue
+		}
+		glog.V(1).Infof("loading %s as a policy bundle", dir)
+		policyDirs = append(policyDirs, filepath.Join(dir, bundlePoliciesDir))
+		bundleLibDirs = append(bundleLibDirs, filepath.Join(dir, bundleLibDir))
+	}
+	switch {
+	case libDir == "":
+		return policyDirs, bundleLibDirs
+	case IsBundle(libDir):
+		return policyDirs, []string{filepath.Join(libDir, bundleLibDir)}
+	default:
+		return policyDirs, []string{libDir}
+	}
+}
+
+// LoadRegoLibraryFiles loads the rego policy library files, with their paths, from each of the given
+// directories in the order LoadRegoLibrary returns their contents.
+func LoadRegoLibraryFiles(dirs []string) ([]*PolicyFile, error) {
+	var libs []*PolicyFile
+	for _, dir := range dirs {
+		dirLibs, err := loadRegoFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		libs = append(libs, dirLibs...)
+	}
+	return libs, nil
+}
+
+// LoadRegoLibrary loads the rego policy library files from each of the given directories.
+func LoadRegoLibrary(dirs []string) ([]string, error) {
+	var libs []string
+	for _, dir := range dirs {
+		dirLibs, err := LoadRegoFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		libs = append(libs, dirLibs...)
+	}
+	return libs, nil
+}
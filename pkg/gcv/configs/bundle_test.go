@@ -0,0 +1,166 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// copyFile copies src into the directory dst, creating it if needed.
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	content, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, filepath.Base(src)), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestBundle creates a policy bundle from the test/cf policies along with sample files that
+// should not be loaded.
+func newTestBundle(t *testing.T) string {
+	root := t.TempDir()
+	copyFile(t, "../../../test/cf/templates/gcp_storage_logging_template.yaml", filepath.Join(root, "policies", "templates"))
+	copyFile(t, "../../../test/cf/constraints/gcp_storage_logging_constraint.yaml", filepath.Join(root, "policies", "constraints"))
+	copyFile(t, "../../../test/cf/library/constraints.rego", filepath.Join(root, "lib"))
+	copyFile(t, "../../../test/cf/library/util.rego", filepath.Join(root, "lib"))
+	if err := os.MkdirAll(filepath.Join(root, "samples"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "samples", "broken.yaml"), []byte("apiVersion: ["), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestResolveBundles(t *testing.T) {
+	bundle := newTestBundle(t)
+	var testCases = []struct {
+		name        string
+		dirs        []string
+		libDir      string
+		wantDirs    []string
+		wantLibDirs []string
+	}{
+		{
+			name:        "plain directories",
+			dirs:        []string{"../../../test/cf"},
+			libDir:      "../../../test/cf/library",
+			wantDirs:    []string{"../../../test/cf"},
+			wantLibDirs: []string{"../../../test/cf/library"},
+		},
+		{
+			name:        "bundle without library",
+			dirs:        []string{bundle},
+			wantDirs:    []string{filepath.Join(bundle, "policies")},
+			wantLibDirs: []string{filepath.Join(bundle, "lib")},
+		},
+		{
+			name:        "bundle as library",
+			dirs:        []string{filepath.Join(bundle, "policies")},
+			libDir:      bundle,
+			wantDirs:    []string{filepath.Join(bundle, "policies")},
+			wantLibDirs: []string{filepath.Join(bundle, "lib")},
+		},
+		{
+			name:        "explicit library overrides bundle",
+			dirs:        []string{bundle},
+			libDir:      "../../../test/cf/library",
+			wantDirs:    []string{filepath.Join(bundle, "policies")},
+			wantLibDirs: []string{"../../../test/cf/library"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dirs, libDirs := ResolveBundles(tc.dirs, tc.libDir)
+			if diff := cmp.Diff(tc.wantDirs, dirs); diff != "" {
+				t.Errorf("dirs diff (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantLibDirs, libDirs); diff != "" {
+				t.Errorf("libDirs diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewConfigurationFromBundle(t *testing.T) {
+	config, err := NewConfiguration([]string{newTestBundle(t)}, "")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got := len(config.GCPTemplates); got != 1 {
+		t.Errorf("len(GCPTemplates) got %d, want 1", got)
+	}
+	if got := len(config.GCPConstraints); got != 1 {
+		t.Errorf("len(GCPConstraints) got %d, want 1", got)
+	}
+
+	if _, err := NewConfiguration([]string{"../../../test/cf"}, ""); err == nil {
+		t.Errorf("expected error without a policy library")
+	}
+}
+
+func TestNewConfigurationFromFS(t *testing.T) {
+	bundle := newTestBundle(t)
+	want, err := NewConfiguration([]string{bundle}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		name  string
+		fsys  fs.FS
+		libFS fs.FS
+	}{
+		{
+			name: "bundle",
+			fsys: os.DirFS(bundle),
+		},
+		{
+			name:  "policies and library",
+			fsys:  os.DirFS(filepath.Join(bundle, "policies")),
+			libFS: os.DirFS(filepath.Join(bundle, "lib")),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := NewConfigurationFromFS(tc.fsys, tc.libFS)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if len(config.GCPTemplates) != len(want.GCPTemplates) || len(config.GCPConstraints) != len(want.GCPConstraints) {
+				t.Errorf("got %d templates and %d constraints, want %d and %d", len(config.GCPTemplates),
+					len(config.GCPConstraints), len(want.GCPTemplates), len(want.GCPConstraints))
+			}
+			if diff := cmp.Diff(want.regoLib, config.regoLib); diff != "" {
+				t.Errorf("rego library diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := NewConfigurationFromFS(os.DirFS(filepath.Join(bundle, "policies")), nil); err == nil {
+		t.Errorf("expected error without a policy library")
+	}
+}
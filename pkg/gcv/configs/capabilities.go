@@ -0,0 +1,86 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"fmt"
+	"strings"
+
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/pkg/errors"
+)
+
+// UnsupportedFeatures returns the rego features the template relies on that are not in the
+// capabilities: future keywords that the capabilities don't list, and calls to builtins that they
+// don't declare.
+func UnsupportedFeatures(templ *cftemplates.ConstraintTemplate, capabilities *ast.Capabilities) []string {
+	var features []string
+	for _, target := range templ.Spec.Targets {
+		for idx, src := range append([]string{target.Rego}, target.Libs...) {
+			if strings.TrimSpace(src) == "" {
+				continue
+			}
+			_, err := ast.ParseModuleWithOpts(fmt.Sprintf("%s/%d.rego", templ.Name, idx), src, ast.ParserOptions{
+				Capabilities: capabilities,
+			})
+			if err != nil {
+				features = append(features, fmt.Sprintf("rego not supported by the capabilities: %s", err))
+			}
+		}
+	}
+	called, err := TemplateBuiltins(templ)
+	if err != nil {
+		// The parse error is already reported above, or when the template is compiled.
+		return features
+	}
+	declared := map[string]bool{}
+	for _, builtin := range capabilities.Builtins {
+		declared[builtin.Name] = true
+	}
+	for _, name := range called {
+		if !declared[name] {
+			features = append(features, "builtin "+name)
+		}
+	}
+	return features
+}
+
+// CheckCapabilities returns an error if the template relies on rego features that are not in the
+// capabilities, see UnsupportedFeatures.
+func CheckCapabilities(templ *cftemplates.ConstraintTemplate, capabilities *ast.Capabilities) error {
+	if features := UnsupportedFeatures(templ, capabilities); len(features) != 0 {
+		return errors.Errorf("template %s relies on features outside the rego capabilities: %s",
+			templ.Name, strings.Join(features, ", "))
+	}
+	return nil
+}
+
+// UnsupportedFeatureUses returns an error issue for each template in the configuration that relies
+// on a rego feature that is not in the capabilities.
+func (c *Configuration) UnsupportedFeatureUses(capabilities *ast.Capabilities) []*Issue {
+	var issues []*Issue
+	for _, ct := range c.Templates() {
+		for _, feature := range UnsupportedFeatures(ct, capabilities) {
+			issues = append(issues, &Issue{
+				Path:    SourcePath(ct),
+				Kind:    "ConstraintTemplate",
+				Name:    ct.Name,
+				Message: "relies on " + feature + ", which is not in the rego capabilities",
+			})
+		}
+	}
+	return issues
+}
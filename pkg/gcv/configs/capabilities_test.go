@@ -0,0 +1,93 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+const futureKeywordsTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpfuturekeywordsconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPFutureKeywordsConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: object
+  targets:
+    - target: validation.gcp.forsetisecurity.org
+      rego: |
+        package templates.gcp.GCPFutureKeywordsConstraintV1
+
+        import future.keywords.in
+
+        violation[{"msg": message}] {
+        	input.review.name in ["denied"]
+        	message := sprintf("%v", [time.now_ns()])
+        }
+`
+
+func TestUnsupportedFeatureUses(t *testing.T) {
+	objects, err := LoadUnstructuredFromContents([]*PolicyFile{
+		{Path: "template.yaml", Content: []byte(futureKeywordsTemplate)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := NewConfigurationFromContents(objects, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if issues := config.UnsupportedFeatureUses(ast.CapabilitiesForThisVersion()); len(issues) != 0 {
+		t.Errorf("got issues %v with the current capabilities, want none", issues)
+	}
+
+	capabilities := ast.CapabilitiesForThisVersion()
+	capabilities.FutureKeywords = nil
+	var builtins []*ast.Builtin
+	for _, builtin := range capabilities.Builtins {
+		if builtin.Name != "time.now_ns" {
+			builtins = append(builtins, builtin)
+		}
+	}
+	capabilities.Builtins = builtins
+	issues := config.UnsupportedFeatureUses(capabilities)
+	if len(issues) != 2 {
+		t.Fatalf("got issues %v, want the future keyword and the builtin", issues)
+	}
+	for _, issue := range issues {
+		if issue.Warning || issue.Path != "template.yaml" || issue.Name != "gcpfuturekeywordsconstraintv1" {
+			t.Errorf("got issue %s, want error for template.yaml", issue)
+		}
+	}
+	if !strings.Contains(issues[0].Message, "future.keywords.in") {
+		t.Errorf("got message %q, want the future keyword", issues[0].Message)
+	}
+	if !strings.Contains(issues[1].Message, "builtin time.now_ns") {
+		t.Errorf("got message %q, want the builtin", issues[1].Message)
+	}
+	if err := CheckCapabilities(config.GCPTemplates[0], capabilities); err == nil {
+		t.Errorf("CheckCapabilities() = nil, want error")
+	}
+}
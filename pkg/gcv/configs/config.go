@@ -18,12 +18,17 @@ package configs
 import (
 	"context"
 	"fmt"
+	"io/fs"
+	"path/filepath"
 	"regexp"
+	goruntime "runtime"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/golang/glog"
 
+	"github.com/GoogleCloudPlatform/config-validator/pkg/ancestry"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
 	cfapis "github.com/open-policy-agent/frameworks/constraint/pkg/apis"
 	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
@@ -32,6 +37,7 @@ import (
 	"github.com/pkg/errors"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
 	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -63,6 +69,8 @@ const (
 	gcpConstraint = "gcp"
 	k8sConstraint = "k8s"
 	tfConstraint  = "terraform"
+	// customConstraint is a constraint for a target registered with the Validator.
+	customConstraint = "custom"
 )
 
 func setAnnotation(u *unstructured.Unstructured, key, value string) {
@@ -74,6 +82,18 @@ func setAnnotation(u *unstructured.Unstructured, key, value string) {
 	u.SetAnnotations(annotations)
 }
 
+// IsLoaderAnnotation returns true for the annotations that are added to objects when they are loaded,
+// rather than declared in their source.
+func IsLoaderAnnotation(key string) bool {
+	return key == yamlPath || key == OriginalName
+}
+
+// SourcePath returns the path of the file the object was loaded from, or an empty string if the object
+// was not loaded from a file.
+func SourcePath(u metav1.Object) string {
+	return u.GetAnnotations()[yamlPath]
+}
+
 // PolicyFile represents a .yaml file with its path and contents,
 // which may or may not have been loaded from the file system.
 type PolicyFile struct {
@@ -84,6 +104,23 @@ type PolicyFile struct {
 // LoadUnstructured loads .yaml files from the provided directories as k8s
 // unstructured.Unstructured types.
 func LoadUnstructured(dirs []string) ([]*unstructured.Unstructured, error) {
+	files, err := ReadPolicyFiles(dirs)
+	if err != nil {
+		return nil, err
+	}
+
+	yamlDocs, err := LoadUnstructuredFromContents(files)
+	if err != nil {
+		return nil, err
+	}
+	if len(yamlDocs) == 0 {
+		return nil, fmt.Errorf("zero configurations found in the provided directories: %v", dirs)
+	}
+	return yamlDocs, nil
+}
+
+// ReadPolicyFiles reads the .yaml files from the provided directories.
+func ReadPolicyFiles(dirs []string) ([]*PolicyFile, error) {
 	var files []*PolicyFile
 	for _, dir := range dirs {
 		dirPath, err := NewPath(dir)
@@ -101,15 +138,7 @@ func LoadUnstructured(dirs []string) ([]*unstructured.Unstructured, error) {
 			})
 		}
 	}
-
-	yamlDocs, err := LoadUnstructuredFromContents(files)
-	if err != nil {
-		return nil, err
-	}
-	if len(yamlDocs) == 0 {
-		return nil, fmt.Errorf("zero configurations found in the provided directories: %v", dirs)
-	}
-	return yamlDocs, nil
+	return files, nil
 }
 
 // LoadUnstructuredFromContents loads provided file contents as k8s unstructured.Unstructured types.
@@ -129,7 +158,7 @@ func LoadUnstructuredFromContents(files []*PolicyFile) ([]*unstructured.Unstruct
 				return nil, errors.Wrapf(err, "failed to decode %s", file.Path)
 			}
 
-			setAnnotation(&u, yamlPath, file.Path)
+			setAnnotation(&u, yamlPath, filepath.ToSlash(file.Path))
 			yamlDocs = append(yamlDocs, &u)
 		}
 	}
@@ -152,7 +181,7 @@ func injectRegoAdapter(rego string) string {
 // Legacy constraint templates use `deny` as an entrypoint and the expected inputs are:
 // - `input.asset`: the CAI asset being reviewed (new templates use `input.review`)
 // - `input.constraint.spec.parameters`: the parameters from the constraint template (new templates use `input.parameters`)
-func convertLegacyConstraintTemplate(u *unstructured.Unstructured, regoLib []string) error {
+func convertLegacyConstraintTemplate(u *unstructured.Unstructured, regoLib []*ast.Module) error {
 	targetMap, found, err := unstructured.NestedMap(u.Object, "spec", "targets")
 	if err != nil && !found {
 		return nil
@@ -198,12 +227,8 @@ func convertLegacyConstraintTemplate(u *unstructured.Unstructured, regoLib []str
 			return errors.Wrapf(err, "failed to create rego rewriter")
 		}
 		for idx, lib := range regoLib {
-			path := fmt.Sprintf("idx-%d.rego", idx)
-			m, err := ast.ParseModule(path, lib)
-			if err != nil {
-				return fmt.Errorf("failed to ParseModule with path %s: %w", path, err)
-			}
-			if err := rr.AddLib(path, m); err != nil {
+			// The rewriter modifies the modules it is given, so each template gets its own copy.
+			if err := rr.AddLib(fmt.Sprintf("idx-%d.rego", idx), lib.Copy()); err != nil {
 				return errors.Wrapf(err, "failed to add lib %d", idx)
 			}
 		}
@@ -263,18 +288,10 @@ func fixLegacyMatcher(ancestry string) string {
 	)
 }
 
+// NormalizeAncestry converts legacy ancestry paths and globs to their plural form, see
+// ancestry.Normalize.
 func NormalizeAncestry(val string) string {
-	for _, r := range []struct {
-		old string
-		new string
-	}{
-		{"organization/", "organizations/"},
-		{"folder/", "folders/"},
-		{"project/", "projects/"},
-	} {
-		val = strings.ReplaceAll(val, r.old, r.new)
-	}
-	return val
+	return ancestry.Normalize(val)
 }
 
 func convertLegacyResourceName(u *unstructured.Unstructured) {
@@ -320,26 +337,62 @@ type Configuration struct {
 	K8SConstraints []*unstructured.Unstructured      // Constraints for GKE
 	TFTemplates    []*cftemplates.ConstraintTemplate // Constraint Templates for TF
 	TFConstraints  []*unstructured.Unstructured      // Constraints for TF
+	Warnings       []*Issue                          // Non-fatal problems found while loading
+	// ExpansionTemplates are the Gatekeeper ExpansionTemplates for K8S resources.
+	ExpansionTemplates []*ExpansionTemplate
+	// Library is the dependency graph between the legacy templates and the policy library, nil if
+	// there are no legacy templates.
+	Library *LibraryGraph
+
+	// CustomTemplates are the Constraint Templates for targets other than GCP, GKE and TF, by target name.
+	CustomTemplates map[string][]*cftemplates.ConstraintTemplate
+	// CustomConstraints are the Constraints for targets other than GCP, GKE and TF, by target name.
+	CustomConstraints map[string][]*unstructured.Unstructured
 
 	// regoLib contains the set of rego libraries, it is only used during construction of Configuration
 	regoLib []string
+	// regoLibPaths are the paths of the regoLib files, nil if only their contents were given.
+	regoLibPaths []string
+	// regoModules is regoLib parsed once for all legacy template conversions, see parseRegoLib.
+	regoModules []*ast.Module
+	// regoLibErr is the error from parsing regoLib.
+	regoLibErr error
+	// regoLibParsed is true once regoLib has been parsed.
+	regoLibParsed bool
+	// legacyConversions holds the result of converting legacy templates ahead of loading, see
+	// convertLegacyTemplates.
+	legacyConversions map[*unstructured.Unstructured]error
 	// allConstraints contains all input constraints, it is only used during construction of Configuration
 	allConstraints []*unstructured.Unstructured
 	// templateNames is a set of the names of all templates for checking exclusivity.
 	templateNames map[string]*cftemplates.ConstraintTemplate
-	// templateNames is a set of the kinds of all templates for checking exclusivity.
+	// templateKinds is a set of the kinds of all templates for checking exclusivity.
 	templateKinds map[string]*cftemplates.ConstraintTemplate
+	// conflictPolicy resolves templates and constraints that are declared more than once.
+	conflictPolicy ConflictPolicy
 }
 
 func newConfiguration() *Configuration {
 	return &Configuration{
-		templateNames: map[string]*cftemplates.ConstraintTemplate{},
-		templateKinds: map[string]*cftemplates.ConstraintTemplate{},
+		CustomTemplates:   map[string][]*cftemplates.ConstraintTemplate{},
+		CustomConstraints: map[string][]*unstructured.Unstructured{},
+		templateNames:     map[string]*cftemplates.ConstraintTemplate{},
+		templateKinds:     map[string]*cftemplates.ConstraintTemplate{},
 	}
 }
 
 // LoadRegoFiles load rego policy library files from the given directory.
 func LoadRegoFiles(dir string) ([]string, error) {
+	files, err := loadRegoFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	libs, _ := libraryContents(files)
+	return libs, nil
+}
+
+// loadRegoFiles loads the rego policy library files from the given directory, sorted by content.
+func loadRegoFiles(dir string) ([]*PolicyFile, error) {
 	dirPath, err := NewPath(dir)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to handle path for %s", dir)
@@ -350,20 +403,128 @@ func LoadRegoFiles(dir string) ([]string, error) {
 		return nil, errors.Wrapf(err, "failed to read files from %s", dir)
 	}
 
-	var libs []string
+	var libs []*PolicyFile
 	for _, f := range files {
-		libs = append(libs, string(f.Content))
+		libs = append(libs, &PolicyFile{Path: f.Path, Content: f.Content})
 	}
-	sort.Strings(libs)
+	sortByContent(libs)
 	return libs, nil
 }
 
+// sortByContent sorts library files by their content, the order library contents have always been
+// loaded in.
+func sortByContent(files []*PolicyFile) {
+	sort.SliceStable(files, func(i, j int) bool {
+		return string(files[i].Content) < string(files[j].Content)
+	})
+}
+
+// libraryContents returns the contents and paths of the library files.
+func libraryContents(files []*PolicyFile) ([]string, []string) {
+	contents := make([]string, 0, len(files))
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		contents = append(contents, string(f.Content))
+		paths = append(paths, f.Path)
+	}
+	return contents, paths
+}
+
+// parseRegoLib parses the rego library the first time it is called, the parsed modules are shared by
+// all legacy template conversions.
+func (c *Configuration) parseRegoLib() ([]*ast.Module, error) {
+	if c.regoLibParsed {
+		return c.regoModules, c.regoLibErr
+	}
+	c.regoLibParsed = true
+	for idx, lib := range c.regoLib {
+		path := fmt.Sprintf("idx-%d.rego", idx)
+		m, err := ast.ParseModule(path, lib)
+		if err != nil {
+			c.regoLibErr = fmt.Errorf("failed to ParseModule with path %s: %w", path, err)
+			return nil, c.regoLibErr
+		}
+		c.regoModules = append(c.regoModules, m)
+	}
+	return c.regoModules, nil
+}
+
+// convertLegacyTemplate validates a v1alpha1 template and converts it to the constraint framework
+// format.  It is safe to call concurrently once parseRegoLib has been called.
+func (c *Configuration) convertLegacyTemplate(u *unstructured.Unstructured) error {
+	openAPIResult := configValidatorV1Alpha1SchemaValidator.Validate(u.Object)
+	if openAPIResult.HasErrorsOrWarnings() {
+		return errors.Wrapf(openAPIResult.AsError(), "v1alpha1 validation failure")
+	}
+
+	regoLib, err := c.parseRegoLib()
+	if err == nil {
+		err = convertLegacyConstraintTemplate(u, regoLib)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to convert legacy forseti ConstraintTemplate "+
+			"to ConstraintFramework format, this is likely due to an issue in the spec.crd.spec.validation field")
+	}
+	return nil
+}
+
+// convertLegacyTemplates converts the legacy templates in objects using a worker per CPU, rewriting
+// the rego of each legacy template dominates load time for large policy libraries.  The results are
+// picked up by loadUnstructured.
+func (c *Configuration) convertLegacyTemplates(objects []*unstructured.Unstructured) {
+	var legacy []*unstructured.Unstructured
+	for _, u := range objects {
+		gvk := u.GroupVersionKind()
+		if gvk.Group == templateGroup && gvk.Kind == "ConstraintTemplate" && gvk.Version == "v1alpha1" {
+			legacy = append(legacy, u)
+		}
+	}
+	if len(legacy) == 0 {
+		return
+	}
+	// Parse the library before starting the workers so they only read the parsed modules.
+	_, _ = c.parseRegoLib()
+	// The conversion rewrites the rego, so the library refs are analysed from the original.
+	sources := make([]string, len(legacy))
+	for idx, u := range legacy {
+		sources[idx] = legacyTemplateRego(u)
+	}
+
+	errs := make([]error, len(legacy))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	workers := goruntime.GOMAXPROCS(0)
+	if workers > len(legacy) {
+		workers = len(legacy)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range next {
+				errs[idx] = c.convertLegacyTemplate(legacy[idx])
+			}
+		}()
+	}
+	for idx := range legacy {
+		next <- idx
+	}
+	close(next)
+	wg.Wait()
+
+	c.legacyConversions = map[*unstructured.Unstructured]error{}
+	for idx, u := range legacy {
+		c.legacyConversions[u] = errs[idx]
+	}
+	c.checkLibrary(legacy, sources)
+}
+
 func (c *Configuration) loadUnstructured(u *unstructured.Unstructured) error {
 	switch u.GroupVersionKind().Group {
 	case constraintGroup:
 		if u.GroupVersionKind().Version == "v1alpha1" {
-			glog.Warning(
-				"v1alpha1 constraints are deprecated and will be removed in a future release. " +
+			c.warn(u,
+				"v1alpha1 constraints are deprecated and will be removed in a future release. "+
 					"Please upgrade: https://github.com/GoogleCloudPlatform/policy-library/blob/main/docs/constraint_template_authoring.md#updating-from-v1alpha1-templates",
 			)
 		}
@@ -376,24 +537,27 @@ func (c *Configuration) loadUnstructured(u *unstructured.Unstructured) error {
 
 		switch u.GroupVersionKind().Version {
 		case "v1alpha1":
-			glog.Warning(
-				"v1alpha1 constraint templates are deprecated and will be removed in a future release. " +
+			c.warn(u,
+				"v1alpha1 constraint templates are deprecated and will be removed in a future release. "+
 					"Please upgrade: https://github.com/GoogleCloudPlatform/policy-library/blob/main/docs/constraint_template_authoring.md#updating-from-v1alpha1-templates",
 			)
-			openAPIResult := configValidatorV1Alpha1SchemaValidator.Validate(u.Object)
-			if openAPIResult.HasErrorsOrWarnings() {
-				return errors.Wrapf(openAPIResult.AsError(), "v1alpha1 validation failure")
+			err, converted := c.legacyConversions[u]
+			if !converted {
+				err = c.convertLegacyTemplate(u)
 			}
-
-			if err := convertLegacyConstraintTemplate(u, c.regoLib); err != nil {
-				return errors.Wrapf(err, "failed to convert legacy forseti ConstraintTemplate "+
-					"to ConstraintFramework format, this is likely due to an issue in the spec.crd.spec.validation field")
+			if err != nil {
+				return err
 			}
 		case "v1beta1":
 			openAPIResult := configValidatorV1Beta1SchemaValidator.Validate(u.Object)
 			if openAPIResult.HasErrorsOrWarnings() {
 				return errors.Wrapf(openAPIResult.AsError(), "v1beta1 validation failure")
 			}
+		case "v1":
+			openAPIResult := configValidatorV1SchemaValidator.Validate(u.Object)
+			if openAPIResult.HasErrorsOrWarnings() {
+				return errors.Wrapf(openAPIResult.AsError(), "v1 validation failure")
+			}
 		default:
 			return errors.Errorf("unrecognized ConstraintTemplate version %s", u.GroupVersionKind().Version)
 		}
@@ -409,26 +573,23 @@ func (c *Configuration) loadUnstructured(u *unstructured.Unstructured) error {
 			return errors.Wrapf(err, "failed to convert to versioned constraint template internal struct")
 		}
 
+		if ct.Spec.CRD.Spec.Validation == nil || ct.Spec.CRD.Spec.Validation.OpenAPIV3Schema == nil {
+			// Templates without parameters may leave out the schema.
+			ct.Spec.CRD.Spec.Validation = &cftemplates.Validation{
+				OpenAPIV3Schema: &apiextensions.JSONSchemaProps{Type: "object"},
+			}
+		}
 		if ct.Spec.CRD.Spec.Validation.OpenAPIV3Schema.Type == "" {
-			glog.Warning(
-				"spec.crd.spec.validation.openAPIV3Schema is missing the type: declaration. " +
+			c.warn(u,
+				"spec.crd.spec.validation.openAPIV3Schema is missing the type: declaration. "+
 					"Please upgrade: https://open-policy-agent.github.io/gatekeeper/website/docs/constrainttemplates#v1-constraint-template",
 			)
 			ct.Spec.CRD.Spec.Validation.OpenAPIV3Schema.Type = "object"
 		}
 
-		if dup, found := c.templateNames[ct.Name]; found {
-			return errors.Errorf(
-				"ConstraintTemplate %q declared at path %q has duplicate name conflict with template declared at path %q",
-				ct.Name, ct.GetAnnotations()[yamlPath], dup.GetAnnotations()[yamlPath])
+		if added, err := c.addTemplate(u, &ct); !added {
+			return err
 		}
-		c.templateNames[ct.Name] = &ct
-		if dup, found := c.templateKinds[ct.Name]; found {
-			return errors.Errorf(
-				"ConstraintTemplate %q crd kind %q declared at path %q has duplicate kind conflict with template declared at path %q",
-				ct.Name, ct.Spec.CRD.Spec.Names.Kind, ct.GetAnnotations()[yamlPath], dup.GetAnnotations()[yamlPath])
-		}
-		c.templateKinds[ct.Name] = &ct
 
 		for _, target := range ct.Spec.Targets {
 			switch target.Target {
@@ -442,27 +603,47 @@ func (c *Configuration) loadUnstructured(u *unstructured.Unstructured) error {
 				c.TFTemplates = append(c.TFTemplates, &ct)
 			case K8STargetName:
 				c.K8STemplates = append(c.K8STemplates, &ct)
+			case "":
+				return errors.Errorf("ConstraintTemplate %q has a target without a name", ct.Name)
 			default:
-				return errors.Errorf("")
+				// Custom targets are only known to the Validator, which rejects templates for targets that
+				// are not registered.
+				c.CustomTemplates[target.Target] = append(c.CustomTemplates[target.Target], &ct)
 			}
 		}
 
+	case expansionGroup:
+		return c.loadExpansionTemplate(u)
+
 	default:
 		glog.V(1).Infof("Ignoring %s %s", u.GroupVersionKind(), u.GetName())
 	}
 	return nil
 }
 
-func (c *Configuration) finishLoad() error {
+// finishLoad sorts the constraints by target, onError is called for each constraint that can't be loaded.
+func (c *Configuration) finishLoad(onError func(u *unstructured.Unstructured, err error)) {
 	templates := map[string]string{}
+	kindTemplates := map[string]*cftemplates.ConstraintTemplate{}
 	for _, t := range c.GCPTemplates {
 		templates[t.Spec.CRD.Spec.Names.Kind] = gcpConstraint
+		kindTemplates[t.Spec.CRD.Spec.Names.Kind] = t
 	}
 	for _, t := range c.TFTemplates {
 		templates[t.Spec.CRD.Spec.Names.Kind] = tfConstraint
+		kindTemplates[t.Spec.CRD.Spec.Names.Kind] = t
 	}
 	for _, t := range c.K8STemplates {
 		templates[t.Spec.CRD.Spec.Names.Kind] = k8sConstraint
+		kindTemplates[t.Spec.CRD.Spec.Names.Kind] = t
+	}
+	customTargets := map[string]string{}
+	for target, custom := range c.CustomTemplates {
+		for _, t := range custom {
+			templates[t.Spec.CRD.Spec.Names.Kind] = customConstraint
+			customTargets[t.Spec.CRD.Spec.Names.Kind] = target
+			kindTemplates[t.Spec.CRD.Spec.Names.Kind] = t
+		}
 	}
 
 	byTemplate := map[string]map[string]*unstructured.Unstructured{}
@@ -472,7 +653,8 @@ func (c *Configuration) finishLoad() error {
 		gvk := constraint.GroupVersionKind()
 		if gvk.Version == "v1alpha1" {
 			if err := convertLegacyConstraint(constraint); err != nil {
-				return fmt.Errorf("failed to convert constraint: %w", err)
+				onError(constraint, fmt.Errorf("failed to convert constraint: %w", err))
+				continue
 			}
 		}
 
@@ -482,9 +664,19 @@ func (c *Configuration) finishLoad() error {
 			byTemplate[constraint.GetKind()] = templateConstraints
 		}
 		if dup, found := templateConstraints[constraint.GetName()]; found {
-			return errors.Errorf(
-				"Constraint %q declared at path %q has duplicate name conflict with constraint declared at path %q",
-				dup.GetName(), dup.GetAnnotations()[yamlPath], constraint.GetAnnotations()[yamlPath])
+			switch c.conflictPolicy {
+			case ConflictFirstWins:
+				c.warn(constraint, fmt.Sprintf("conflicts with constraint declared at path %q, skipping it", SourcePath(dup)))
+				continue
+			case ConflictLastWins:
+				c.removeConstraint(dup)
+				c.warn(constraint, fmt.Sprintf("replaces conflicting constraint declared at path %q", SourcePath(dup)))
+			default:
+				onError(constraint, errors.Errorf(
+					"Constraint %q declared at path %q has duplicate name conflict with constraint declared at path %q",
+					dup.GetName(), dup.GetAnnotations()[yamlPath], constraint.GetAnnotations()[yamlPath]))
+				continue
+			}
 		}
 
 		switch templates[gvk.Kind] {
@@ -494,34 +686,150 @@ func (c *Configuration) finishLoad() error {
 			c.TFConstraints = append(c.TFConstraints, constraint)
 		case k8sConstraint:
 			c.K8SConstraints = append(c.K8SConstraints, constraint)
+		case customConstraint:
+			target := customTargets[gvk.Kind]
+			c.CustomConstraints[target] = append(c.CustomConstraints[target], constraint)
 		default:
-			return errors.Errorf("constraint %s does not correspond to any templates", gvk)
+			onError(constraint, errors.Errorf("constraint %s does not correspond to any templates", gvk))
+			continue
 		}
+		templateConstraints[constraint.GetName()] = constraint
+		c.checkParameters(kindTemplates[gvk.Kind], constraint)
 	}
-	return nil
 }
 
-// NewConfiguration returns the configuration from the list of provided directories.
+// NewConfiguration returns the configuration from the list of provided directories.  Directories
+// using the policy bundle layout are loaded as described in ResolveBundles, libDir may be empty if
+// the library comes from a bundle.
 func NewConfiguration(dirs []string, libDir string) (*Configuration, error) {
-	unstructuredObjects, err := LoadUnstructured(dirs)
+	return NewConfigurationWithOptions(dirs, libDir, LoadOptions{})
+}
+
+// NewLenientConfiguration is NewConfiguration, except that the files, templates and constraints that
+// fail to load are skipped with a warning instead of failing the load, as are the constraints of the
+// templates that are skipped.  Failing to read the files, or the library, still fails the load.
+func NewLenientConfiguration(dirs []string, libDir string) (*Configuration, error) {
+	return NewConfigurationWithOptions(dirs, libDir, LoadOptions{Lenient: true})
+}
+
+// NewConfigurationWithOptions is NewConfiguration, loaded leniently and with the conflict policy given
+// in options.
+func NewConfigurationWithOptions(dirs []string, libDir string, options LoadOptions) (*Configuration, error) {
+	dirs, libDirs := ResolveBundles(dirs, libDir)
+	if len(libDirs) == 0 {
+		return nil, errors.New("no policy library set")
+	}
+	if !options.Lenient {
+		unstructuredObjects, err := LoadUnstructured(dirs)
+		if err != nil {
+			return nil, err
+		}
+		libFiles, err := LoadRegoLibraryFiles(libDirs)
+		if err != nil {
+			return nil, err
+		}
+		regoLib, paths := libraryContents(libFiles)
+		return newConfigurationFromLibrary(unstructuredObjects, regoLib, paths, options.ConflictPolicy)
+	}
+
+	files, err := ReadPolicyFiles(dirs)
 	if err != nil {
 		return nil, err
 	}
+	lib, err := LoadRegoLibraryFiles(libDirs)
+	if err != nil {
+		return nil, err
+	}
+	regoLib, paths := libraryContents(lib)
+	configuration, issues := lintFiles(files, regoLib, paths, options.ConflictPolicy)
+	// The issues include the configuration's warnings.
+	configuration.Warnings = nil
+	for _, issue := range issues {
+		if !issue.Warning {
+			skipped := *issue
+			skipped.Warning = true
+			skipped.Message += ", skipping it"
+			glog.Warningf("%s", &skipped)
+			issue = &skipped
+		}
+		configuration.Warnings = append(configuration.Warnings, issue)
+	}
+	return configuration, nil
+}
+
+// NewConfigurationFromFS returns the configuration from the templates and constraints in fsys and the
+// rego library in libFS, such as file systems embedded in a binary with go:embed.  If libFS is nil,
+// fsys must be a policy bundle with policies/ and lib/ directories at its root.
+func NewConfigurationFromFS(fsys, libFS fs.FS) (*Configuration, error) {
+	if libFS == nil {
+		if !isFSBundle(fsys) {
+			return nil, errors.New("no policy library set")
+		}
+		var err error
+		if libFS, err = fs.Sub(fsys, bundleLibDir); err != nil {
+			return nil, err
+		}
+		if fsys, err = fs.Sub(fsys, bundlePoliciesDir); err != nil {
+			return nil, err
+		}
+	}
 
-	regoLib, err := LoadRegoFiles(libDir)
+	ctx := context.Background()
+	policyFiles, err := NewFSPath("fs://"+bundlePoliciesDir, fsys).ReadAll(ctx, SuffixPredicate(".yaml"))
 	if err != nil {
 		return nil, err
 	}
+	var files []*PolicyFile
+	for _, f := range policyFiles {
+		files = append(files, &PolicyFile{Path: f.Path, Content: f.Content})
+	}
+	unstructuredObjects, err := LoadUnstructuredFromContents(files)
+	if err != nil {
+		return nil, err
+	}
+	if len(unstructuredObjects) == 0 {
+		return nil, errors.New("zero configurations found in the provided file system")
+	}
 
-	return NewConfigurationFromContents(unstructuredObjects, regoLib)
+	libFiles, err := NewFSPath("fs://"+bundleLibDir, libFS).ReadAll(ctx, SuffixPredicate(".rego"))
+	if err != nil {
+		return nil, err
+	}
+	var lib []*PolicyFile
+	for _, f := range libFiles {
+		lib = append(lib, &PolicyFile{Path: f.Path, Content: f.Content})
+	}
+	sortByContent(lib)
+	regoLib, paths := libraryContents(lib)
+	return newConfigurationFromLibrary(unstructuredObjects, regoLib, paths, ConflictError)
+}
+
+// isFSBundle returns true if fsys has the layout of a policy bundle, see IsBundle.
+func isFSBundle(fsys fs.FS) bool {
+	for _, sub := range []string{bundlePoliciesDir, bundleLibDir} {
+		info, err := fs.Stat(fsys, sub)
+		if err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
 }
 
 // NewConfigurationFromContents returns the configuration from the given
 // unstructured objects and the rego library file contents.
 // This can be used by code that may not have access to a file system and passes in the contents directly.
 func NewConfigurationFromContents(unstructuredObjects []*unstructured.Unstructured, regoLib []string) (*Configuration, error) {
+	return newConfigurationFromLibrary(unstructuredObjects, regoLib, nil, ConflictError)
+}
+
+// newConfigurationFromLibrary is NewConfigurationFromContents with the paths of the library files,
+// which are used to report library issues, or nil if they are not known, and the conflict policy.
+func newConfigurationFromLibrary(unstructuredObjects []*unstructured.Unstructured, regoLib, regoLibPaths []string, conflictPolicy ConflictPolicy) (*Configuration, error) {
 	configuration := newConfiguration()
+	configuration.conflictPolicy = conflictPolicy
 	configuration.regoLib = regoLib
+	configuration.regoLibPaths = regoLibPaths
+	configuration.convertLegacyTemplates(unstructuredObjects)
 	var errs multierror.Errors
 	for _, u := range unstructuredObjects {
 		if err := configuration.loadUnstructured(u); err != nil {
@@ -535,8 +843,11 @@ func NewConfigurationFromContents(unstructuredObjects []*unstructured.Unstructur
 
 	}
 
-	if err := configuration.finishLoad(); err != nil {
-		return nil, errors.Wrapf(err, "config error")
+	configuration.finishLoad(func(u *unstructured.Unstructured, err error) {
+		errs.Add(err)
+	})
+	if !errs.Empty() {
+		return nil, errors.Wrapf(errs.ToError(), "config error")
 	}
 
 	return configuration, nil
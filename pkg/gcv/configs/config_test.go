@@ -16,8 +16,10 @@ package configs
 
 import (
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -129,7 +131,7 @@ func TestLegacyTemplateConversion(t *testing.T) {
 
 			u := unst[0]
 			origName := u.GetName()
-			err = convertLegacyConstraintTemplate(u, []string{})
+			err = convertLegacyConstraintTemplate(u, nil)
 			if err != nil {
 				t.Fatalf("unexpected error: %s", err)
 			}
@@ -152,3 +154,122 @@ func TestLegacyTemplateConversion(t *testing.T) {
 func TestLegacyConstraintConversion(t *testing.T) {
 
 }
+
+// requiredLabelsTemplate is a K8S template with the apiVersion and extra CRD fields as placeholders.
+const requiredLabelsTemplate = `
+apiVersion: templates.gatekeeper.sh/VERSION
+kind: ConstraintTemplate
+metadata:
+  name: k8srequiredlabels
+spec:
+  crd:
+    spec:
+      names:
+        kind: K8sRequiredLabels
+      validation:
+        LEGACYopenAPIV3Schema:
+          type: object
+          properties:
+            labels:
+              type: array
+              items:
+                type: string
+  targets:
+    - target: admission.k8s.gatekeeper.sh
+      rego: |
+        package k8srequiredlabels
+
+        violation[{"msg": msg}] {
+          provided := {label | input.review.object.metadata.labels[label]}
+          required := {label | label := input.parameters.labels[_]}
+          missing := required - provided
+          count(missing) > 0
+          msg := sprintf("you must provide labels: %v", [missing])
+        }
+`
+
+func loadTemplate(t *testing.T, version, legacy string) (*Configuration, error) {
+	t.Helper()
+	content := strings.NewReplacer("VERSION", version, "LEGACY", legacy).Replace(requiredLabelsTemplate)
+	objects, err := LoadUnstructuredFromContents([]*PolicyFile{{Path: "template.yaml", Content: []byte(content)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewConfigurationFromContents(objects, []string{"package validator.gcp.lib\n"})
+}
+
+func TestV1TemplateConversion(t *testing.T) {
+	beta, err := loadTemplate(t, "v1beta1", "")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	testCases := []struct {
+		name    string
+		legacy  string
+		wantErr bool
+	}{
+		{name: "same as v1beta1"},
+		{name: "legacy schema", legacy: "legacySchema: false\n        "},
+		{name: "unknown field", legacy: "unknown: true\n        ", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v1, err := loadTemplate(t, "v1", tc.legacy)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(v1.K8STemplates) != 1 {
+				t.Fatalf("got %d K8S templates, want 1", len(v1.K8STemplates))
+			}
+			got, want := v1.K8STemplates[0], beta.K8STemplates[0]
+			if diff := cmp.Diff(want.Spec.Targets, got.Spec.Targets); diff != "" {
+				t.Errorf("targets diff (-v1beta1 +v1):\n%s", diff)
+			}
+			if diff := cmp.Diff(want.Spec.CRD.Spec.Names, got.Spec.CRD.Spec.Names); diff != "" {
+				t.Errorf("names diff (-v1beta1 +v1):\n%s", diff)
+			}
+			if diff := cmp.Diff(want.Spec.CRD.Spec.Validation.OpenAPIV3Schema, got.Spec.CRD.Spec.Validation.OpenAPIV3Schema); diff != "" {
+				t.Errorf("schema diff (-v1beta1 +v1):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestV1TemplateWithoutSchema(t *testing.T) {
+	objects, err := LoadUnstructuredFromContents([]*PolicyFile{{Path: "template.yaml", Content: []byte(`
+apiVersion: templates.gatekeeper.sh/v1
+kind: ConstraintTemplate
+metadata:
+  name: k8sdenyall
+spec:
+  crd:
+    spec:
+      names:
+        kind: K8sDenyAll
+        shortNames: [denyall]
+  targets:
+    - target: admission.k8s.gatekeeper.sh
+      rego: |
+        package k8sdenyall
+
+        violation[{"msg": "denied"}] {
+          true
+        }
+`)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := NewConfigurationFromContents(objects, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(config.K8STemplates) != 1 {
+		t.Fatalf("got %d K8S templates, want 1", len(config.K8STemplates))
+	}
+	if got := config.K8STemplates[0].Spec.CRD.Spec.Validation.OpenAPIV3Schema.Type; got != "object" {
+		t.Errorf("got schema type %q, want object", got)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"fmt"
+
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ConflictPolicy decides what happens when templates with the same name or CRD kind, or constraints
+// with the same kind and name, are declared more than once, eg in different policy paths.
+type ConflictPolicy string
+
+const (
+	// ConflictError fails the load with the path of each declaration, the default.
+	ConflictError ConflictPolicy = "error"
+	// ConflictFirstWins keeps the declaration loaded first and skips the others with a warning.
+	ConflictFirstWins ConflictPolicy = "first-wins"
+	// ConflictLastWins keeps the declaration loaded last, replacing the others with a warning.
+	ConflictLastWins ConflictPolicy = "last-wins"
+)
+
+// ParseConflictPolicy returns the ConflictPolicy with the given name, an empty name is ConflictError.
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(name); policy {
+	case "":
+		return ConflictError, nil
+	case ConflictError, ConflictFirstWins, ConflictLastWins:
+		return policy, nil
+	}
+	return "", errors.Errorf("unknown conflict policy %q, want one of %s, %s or %s",
+		name, ConflictError, ConflictFirstWins, ConflictLastWins)
+}
+
+// LoadOptions control how NewConfigurationWithOptions loads the policies.
+type LoadOptions struct {
+	// Lenient skips the files, templates and constraints that fail to load, see NewLenientConfiguration.
+	Lenient bool
+	// ConflictPolicy resolves templates and constraints that are declared more than once, empty for
+	// ConflictError.
+	ConflictPolicy ConflictPolicy
+}
+
+// addTemplate records ct, loaded from u, as the template of its name and kind.  It returns false if
+// ct conflicts with a template that was already loaded and is skipped under the conflict policy.
+func (c *Configuration) addTemplate(u *unstructured.Unstructured, ct *cftemplates.ConstraintTemplate) (bool, error) {
+	kind := ct.Spec.CRD.Spec.Names.Kind
+	byName, byKind := c.templateNames[ct.Name], c.templateKinds[kind]
+	if byName == nil && byKind == nil {
+		c.templateNames[ct.Name] = ct
+		c.templateKinds[kind] = ct
+		return true, nil
+	}
+
+	var dups []*cftemplates.ConstraintTemplate
+	for _, dup := range []*cftemplates.ConstraintTemplate{byName, byKind} {
+		if dup != nil && (len(dups) == 0 || dups[0] != dup) {
+			dups = append(dups, dup)
+		}
+	}
+	switch c.conflictPolicy {
+	case ConflictFirstWins:
+		c.warn(u, fmt.Sprintf("conflicts with template %q declared at path %q, skipping it",
+			dups[0].Name, SourcePath(dups[0])))
+		return false, nil
+	case ConflictLastWins:
+		for _, dup := range dups {
+			c.removeTemplate(dup)
+			c.warn(u, fmt.Sprintf("replaces conflicting template %q declared at path %q", dup.Name, SourcePath(dup)))
+		}
+		c.templateNames[ct.Name] = ct
+		c.templateKinds[kind] = ct
+		return true, nil
+	}
+	if byName != nil {
+		return false, errors.Errorf(
+			"ConstraintTemplate %q declared at path %q has duplicate name conflict with template declared at path %q",
+			ct.Name, SourcePath(ct), SourcePath(byName))
+	}
+	return false, errors.Errorf(
+		"ConstraintTemplate %q crd kind %q declared at path %q has duplicate kind conflict with template %q declared at path %q",
+		ct.Name, kind, SourcePath(ct), byKind.Name, SourcePath(byKind))
+}
+
+// removeTemplate removes a template that was replaced under ConflictLastWins.
+func (c *Configuration) removeTemplate(ct *cftemplates.ConstraintTemplate) {
+	delete(c.templateNames, ct.Name)
+	delete(c.templateKinds, ct.Spec.CRD.Spec.Names.Kind)
+	c.GCPTemplates = withoutTemplate(c.GCPTemplates, ct)
+	c.K8STemplates = withoutTemplate(c.K8STemplates, ct)
+	c.TFTemplates = withoutTemplate(c.TFTemplates, ct)
+	for target, templates := range c.CustomTemplates {
+		c.CustomTemplates[target] = withoutTemplate(templates, ct)
+	}
+}
+
+// removeConstraint removes a constraint that was replaced under ConflictLastWins.
+func (c *Configuration) removeConstraint(constraint *unstructured.Unstructured) {
+	c.GCPConstraints = withoutConstraint(c.GCPConstraints, constraint)
+	c.K8SConstraints = withoutConstraint(c.K8SConstraints, constraint)
+	c.TFConstraints = withoutConstraint(c.TFConstraints, constraint)
+	for target, constraints := range c.CustomConstraints {
+		c.CustomConstraints[target] = withoutConstraint(constraints, constraint)
+	}
+}
+
+func withoutTemplate(templates []*cftemplates.ConstraintTemplate, ct *cftemplates.ConstraintTemplate) []*cftemplates.ConstraintTemplate {
+	var ret []*cftemplates.ConstraintTemplate
+	for _, t := range templates {
+		if t != ct {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+func withoutConstraint(constraints []*unstructured.Unstructured, constraint *unstructured.Unstructured) []*unstructured.Unstructured {
+	var ret []*unstructured.Unstructured
+	for _, u := range constraints {
+		if u != constraint {
+			ret = append(ret, u)
+		}
+	}
+	return ret
+}
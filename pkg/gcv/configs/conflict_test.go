@@ -0,0 +1,135 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const conflictTemplate = `
+apiVersion: templates.gatekeeper.sh/v1
+kind: ConstraintTemplate
+metadata:
+  name: %s
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPConflictConstraintV1
+  targets:
+    - target: validation.gcp.forsetisecurity.org
+      rego: |
+        package templates.gcp.GCPConflictConstraintV1
+
+        violation[{"msg": "%s"}] {
+          true
+        }
+`
+
+const conflictConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: GCPConflictConstraintV1
+metadata:
+  name: conflict
+  annotations:
+    source: %s
+`
+
+// writeConflictDirs writes two policy directories with templates of different names but the same
+// kind, each with a constraint of the same name, and returns them with a library directory.
+func writeConflictDirs(t *testing.T) ([]string, string) {
+	var dirs []string
+	for _, name := range []string{"first", "second"} {
+		dir := t.TempDir()
+		files := map[string]string{
+			"template.yaml":   fmt.Sprintf(conflictTemplate, "gcpconflict"+name, name),
+			"constraint.yaml": fmt.Sprintf(conflictConstraint, name),
+		}
+		for file, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		dirs = append(dirs, dir)
+	}
+	libDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(libDir, "lib.rego"), []byte("package validator.gcp.lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dirs, libDir
+}
+
+func TestConflictPolicy(t *testing.T) {
+	dirs, libDir := writeConflictDirs(t)
+
+	_, err := NewConfiguration(dirs, libDir)
+	if err == nil || !strings.Contains(err.Error(), "duplicate kind conflict") {
+		t.Fatalf("got error %v, want duplicate kind conflict", err)
+	}
+
+	for _, tc := range []struct {
+		policy ConflictPolicy
+		want   string
+	}{
+		{policy: ConflictFirstWins, want: "first"},
+		{policy: ConflictLastWins, want: "second"},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			config, err := NewConfigurationWithOptions(dirs, libDir, LoadOptions{ConflictPolicy: tc.policy})
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if len(config.GCPTemplates) != 1 || config.GCPTemplates[0].Name != "gcpconflict"+tc.want {
+				t.Errorf("got templates %v, want only gcpconflict%s", config.GCPTemplates, tc.want)
+			}
+			if len(config.GCPConstraints) != 1 || config.GCPConstraints[0].GetAnnotations()["source"] != tc.want {
+				t.Errorf("got constraints %v, want only the %s one", config.GCPConstraints, tc.want)
+			}
+			if len(config.Warnings) != 2 {
+				t.Errorf("got warnings %v, want one for the template and one for the constraint", config.Warnings)
+			}
+		})
+	}
+}
+
+func TestConstraintNameConflict(t *testing.T) {
+	dirs, libDir := writeConflictDirs(t)
+	// Only the template of the first directory, so that the constraints are the only conflict.
+	dirs = append(dirs[:1], filepath.Join(dirs[1], "constraint.yaml"))
+	_, err := NewConfiguration(dirs, libDir)
+	if err == nil || !strings.Contains(err.Error(), "Constraint \"conflict\"") {
+		t.Fatalf("got error %v, want constraint name conflict", err)
+	}
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	for name, want := range map[string]ConflictPolicy{
+		"":           ConflictError,
+		"error":      ConflictError,
+		"first-wins": ConflictFirstWins,
+		"last-wins":  ConflictLastWins,
+	} {
+		if got, err := ParseConflictPolicy(name); err != nil || got != want {
+			t.Errorf("ParseConflictPolicy(%q) got %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseConflictPolicy("newest"); err == nil {
+		t.Error("expected error for unknown conflict policy")
+	}
+}
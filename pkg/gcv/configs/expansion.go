@@ -0,0 +1,159 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	expansionGroup = "expansion.gatekeeper.sh"
+	expansionKind  = "ExpansionTemplate"
+)
+
+// ExpansionTemplate is a Gatekeeper ExpansionTemplate, which expands generator resources such as
+// Deployments into the resources they generate, such as Pods, so that workload policies are enforced
+// on the generator.  See https://open-policy-agent.github.io/gatekeeper/website/docs/expansion.
+type ExpansionTemplate struct {
+	// Name is the name of the template.
+	Name string
+	// Path is the file the template was loaded from.
+	Path string
+	// ApplyTo selects the generator resources that are expanded.
+	ApplyTo []ApplyTo
+	// TemplateSource is the dotted path of the field of the generator that holds the generated
+	// resource, eg spec.template.
+	TemplateSource string
+	// GeneratedGVK is the group, version and kind of the generated resource.
+	GeneratedGVK schema.GroupVersionKind
+	// EnforcementAction, if set, replaces the enforcement action of the constraints violated by the
+	// generated resource.
+	EnforcementAction string
+}
+
+// ApplyTo selects the resources with any of the groups, versions and kinds.
+type ApplyTo struct {
+	Groups   []string `json:"groups,omitempty"`
+	Versions []string `json:"versions,omitempty"`
+	Kinds    []string `json:"kinds,omitempty"`
+}
+
+// Matches returns true if the group, version and kind are selected.
+func (a ApplyTo) Matches(gvk schema.GroupVersionKind) bool {
+	return containsString(a.Groups, gvk.Group) && containsString(a.Versions, gvk.Version) && containsString(a.Kinds, gvk.Kind)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AppliesTo returns true if resources of the group, version and kind are expanded by the template.
+func (t *ExpansionTemplate) AppliesTo(gvk schema.GroupVersionKind) bool {
+	for _, a := range t.ApplyTo {
+		if a.Matches(gvk) {
+			return true
+		}
+	}
+	return false
+}
+
+// SourcePath returns the path of the template source field.
+func (t *ExpansionTemplate) SourcePath() []string {
+	return strings.Split(t.TemplateSource, ".")
+}
+
+// expansionTemplateSpec is the spec of the v1alpha1 and v1beta1 ExpansionTemplate, which are the same.
+type expansionTemplateSpec struct {
+	ApplyTo        []ApplyTo `json:"applyTo,omitempty"`
+	TemplateSource string    `json:"templateSource,omitempty"`
+	GeneratedGVK   struct {
+		Group   string `json:"group,omitempty"`
+		Version string `json:"version,omitempty"`
+		Kind    string `json:"kind,omitempty"`
+	} `json:"generatedGVK,omitempty"`
+	EnforcementAction string `json:"enforcementAction,omitempty"`
+}
+
+// NewExpansionTemplate converts an ExpansionTemplate, checking it as Gatekeeper does.
+func NewExpansionTemplate(u *unstructured.Unstructured) (*ExpansionTemplate, error) {
+	spec, _, err := unstructured.NestedMap(u.Object, "spec")
+	if err != nil {
+		return nil, errors.Wrapf(err, "ExpansionTemplate %q has an invalid spec", u.GetName())
+	}
+	var s expansionTemplateSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &s); err != nil {
+		return nil, errors.Wrapf(err, "ExpansionTemplate %q has an invalid spec", u.GetName())
+	}
+	t := &ExpansionTemplate{
+		Name:           u.GetName(),
+		Path:           SourcePath(u),
+		ApplyTo:        s.ApplyTo,
+		TemplateSource: s.TemplateSource,
+		GeneratedGVK: schema.GroupVersionKind{
+			Group:   s.GeneratedGVK.Group,
+			Version: s.GeneratedGVK.Version,
+			Kind:    s.GeneratedGVK.Kind,
+		},
+		EnforcementAction: s.EnforcementAction,
+	}
+	switch {
+	case t.Name == "":
+		return nil, errors.Errorf("ExpansionTemplate has empty name field")
+	case t.TemplateSource == "":
+		return nil, errors.Errorf("ExpansionTemplate %q has empty templateSource field", t.Name)
+	case t.GeneratedGVK.Empty():
+		return nil, errors.Errorf("ExpansionTemplate %q has empty generatedGVK field", t.Name)
+	case len(t.ApplyTo) == 0:
+		return nil, errors.Errorf("ExpansionTemplate %q must specify applyTo", t.Name)
+	case t.AppliesTo(t.GeneratedGVK):
+		// A template that expands its own output would expand forever.
+		return nil, errors.Errorf("ExpansionTemplate %q generates %v, but also applies to it", t.Name, t.GeneratedGVK)
+	}
+	return t, nil
+}
+
+// loadExpansionTemplate adds an ExpansionTemplate to the configuration.
+func (c *Configuration) loadExpansionTemplate(u *unstructured.Unstructured) error {
+	gvk := u.GroupVersionKind()
+	if gvk.Kind != expansionKind {
+		return errors.Errorf("unexpected data type %s in group %s", gvk, expansionGroup)
+	}
+	if gvk.Version != "v1alpha1" && gvk.Version != "v1beta1" {
+		return errors.Errorf("unrecognized ExpansionTemplate version %s", gvk.Version)
+	}
+	t, err := NewExpansionTemplate(u)
+	if err != nil {
+		return err
+	}
+	for _, dup := range c.ExpansionTemplates {
+		if dup.Name == t.Name {
+			return errors.Errorf(
+				"ExpansionTemplate %q declared at path %q has duplicate name conflict with template declared at path %q",
+				t.Name, t.Path, dup.Path)
+		}
+	}
+	c.ExpansionTemplates = append(c.ExpansionTemplates, t)
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func expansionTemplate(spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "expansion.gatekeeper.sh/v1alpha1",
+		"kind":       "ExpansionTemplate",
+		"metadata":   map[string]interface{}{"name": "expand-deployments"},
+		"spec":       spec,
+	}}
+}
+
+func TestNewExpansionTemplate(t *testing.T) {
+	deployments := []interface{}{map[string]interface{}{
+		"groups":   []interface{}{"apps"},
+		"versions": []interface{}{"v1"},
+		"kinds":    []interface{}{"Deployment"},
+	}}
+	pod := map[string]interface{}{"version": "v1", "kind": "Pod"}
+	testCases := []struct {
+		name    string
+		spec    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			spec: map[string]interface{}{"applyTo": deployments, "templateSource": "spec.template", "generatedGVK": pod},
+		},
+		{
+			name:    "no source",
+			spec:    map[string]interface{}{"applyTo": deployments, "generatedGVK": pod},
+			wantErr: true,
+		},
+		{
+			name:    "no generated kind",
+			spec:    map[string]interface{}{"applyTo": deployments, "templateSource": "spec.template"},
+			wantErr: true,
+		},
+		{
+			name:    "no applyTo",
+			spec:    map[string]interface{}{"templateSource": "spec.template", "generatedGVK": pod},
+			wantErr: true,
+		},
+		{
+			name: "expands own output",
+			spec: map[string]interface{}{
+				"applyTo":        deployments,
+				"templateSource": "spec.template",
+				"generatedGVK":   map[string]interface{}{"group": "apps", "version": "v1", "kind": "Deployment"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewExpansionTemplate(expansionTemplate(tc.spec))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !got.AppliesTo(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}) {
+				t.Errorf("template does not apply to Deployments")
+			}
+			if got.AppliesTo(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}) {
+				t.Errorf("template applies to StatefulSets")
+			}
+		})
+	}
+}
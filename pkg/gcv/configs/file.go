@@ -51,13 +51,25 @@ func configGCSClient() {
 	}
 }
 
-// NewPath returns a new Path to a local or gcs file.
+// NewPath returns a new Path to a local or gcs file, or to a file of a Source registered for the
+// path's scheme, see RegisterSource.
 func NewPath(path string) (Path, error) {
+	if hasVolumeName(path) {
+		return &localPath{path: path}, nil
+	}
 	fileURL, err := url.Parse(path)
 	if err != nil {
 		return nil, err
 	}
 
+	if factory, ok := sourceFactory(path); ok {
+		source, err := factory(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create source for %s", path)
+		}
+		return NewSourcePath(source), nil
+	}
+
 	if fileURL.Scheme == "gs" {
 		globals.once.Do(configGCSClient)
 		return &gcsPath{
@@ -72,7 +84,7 @@ func NewPath(path string) (Path, error) {
 
 // File represents the contents of a file
 type File struct {
-	// Path is the path to the file.
+	// Path is the path to the file, separated by "/" on every OS.
 	Path string
 	// Content is the full contents for the file.
 	Content []byte
@@ -103,7 +115,7 @@ type Path interface {
 	ReadAll(ctx context.Context, predicates ...readPredicate) ([]File, error)
 }
 
-// localPath handles local file paths.
+// localPath handles local file paths, separated by "/" or the OS separator.
 type localPath struct {
 	path string
 }
@@ -118,7 +130,8 @@ func (p *localPath) ReadAll(ctx context.Context, predicates ...readPredicate) ([
 		if f.IsDir() {
 			return nil
 		}
-		if !matchesPredicates(path, predicates) {
+		slashPath := filepath.ToSlash(path)
+		if !matchesPredicates(slashPath, predicates) {
 			return nil
 		}
 
@@ -126,10 +139,10 @@ func (p *localPath) ReadAll(ctx context.Context, predicates ...readPredicate) ([
 		if err != nil {
 			return errors.Wrapf(err, "failed to read %s", path)
 		}
-		files = append(files, File{Path: path, Content: content})
+		files = append(files, File{Path: slashPath, Content: content})
 		return nil
 	}
-	err := filepath.Walk(p.path, visit)
+	err := filepath.Walk(filepath.FromSlash(p.path), visit)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to read files in %s", p.path)
 	}
@@ -17,6 +17,7 @@ package configs
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"sort"
 	"strings"
 	"testing"
@@ -132,6 +133,48 @@ func TestLocalPath(t *testing.T) {
 	}
 }
 
+func TestLocalPathSeparators(t *testing.T) {
+	// Paths given with the OS separator are read as files separated by "/", so that the yamlpath
+	// annotations and library paths are the same on every OS.
+	for _, tc := range []pathTestcase{
+		{
+			name:       "OS separator",
+			path:       filepath.Join("..", "..", "..", "test", "cf", "library"),
+			predicates: []readPredicate{SuffixPredicate(".rego")},
+			wantFiles: []string{
+				"../../../test/cf/library/constraints.rego",
+				"../../../test/cf/library/util.rego",
+			},
+		},
+		{
+			name:      "slash separator",
+			path:      "../../../test/cf/library/util.rego",
+			wantFiles: []string{"../../../test/cf/library/util.rego"},
+		},
+	} {
+		t.Run(tc.name, tc.Run)
+	}
+}
+
+func TestYAMLPathAnnotation(t *testing.T) {
+	files, err := ReadPolicyFiles([]string{filepath.Join("..", "..", "..", "test", "cf", "constraints")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := LoadUnstructuredFromContents(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) == 0 {
+		t.Fatal("got no documents")
+	}
+	for _, doc := range docs {
+		if got := doc.GetAnnotations()[yamlPath]; !strings.HasPrefix(got, "../../../test/cf/constraints/") {
+			t.Errorf("got yamlpath %q, want it separated by /", got)
+		}
+	}
+}
+
 func TestGCSPath(t *testing.T) {
 	// Mocking out the GCS client would require wrapping storage.Client, storage.BucketHandle and storage.ObjectIterator
 	// and creating proper interfaces for it all.  To simplify, I've decided to trade some amount of stability for
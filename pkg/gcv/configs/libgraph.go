@@ -0,0 +1,310 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/open-policy-agent/opa/ast"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// libraryNamespace is the root of the packages of the policy library, legacy templates refer to the
+// library under it.
+var libraryNamespace = ast.MustParseRef("data.validator")
+
+// LibraryGraph is the dependency graph between the legacy templates and the files of the policy
+// library.
+type LibraryGraph struct {
+	// Files are the library files, in load order.
+	Files []*LibraryFile
+	// Templates are the library files each legacy template refers to directly, by template kind.
+	Templates map[string][]*LibraryFile
+	// Missing are the issues for library refs, in templates or library files, that the library does
+	// not define.
+	Missing []*Issue
+}
+
+// LibraryFile is a file of the policy library.
+type LibraryFile struct {
+	// Path is the path of the file, or "library[idx]" if only the library contents were loaded.
+	Path string
+	// Package is the package of the file, eg data.validator.gcp.lib.
+	Package string
+	// Imports are the library files the file refers to.
+	Imports []*LibraryFile
+	// Used is true if a legacy template refers to the file, directly or through other library files.
+	Used bool
+
+	module *ast.Module
+	rules  map[string]bool
+}
+
+// Unused returns the library files that no legacy template uses, other than test files.
+func (g *LibraryGraph) Unused() []*LibraryFile {
+	var unused []*LibraryFile
+	for _, f := range g.Files {
+		if !f.Used && !f.isTest() {
+			unused = append(unused, f)
+		}
+	}
+	return unused
+}
+
+// isTest returns true for rego unit test files, which templates never refer to.
+func (f *LibraryFile) isTest() bool {
+	if strings.HasSuffix(f.Path, "_test.rego") {
+		return true
+	}
+	for name := range f.rules {
+		if !strings.HasPrefix(name, "test_") {
+			return false
+		}
+	}
+	return len(f.rules) != 0
+}
+
+// defines returns true if the file defines the rule, or package, ref refers to.
+func (f *LibraryFile) defines(ref ast.Ref) bool {
+	pkg := f.module.Package.Path
+	if pkg.HasPrefix(ref) {
+		return true
+	}
+	if !ref.HasPrefix(pkg) {
+		return false
+	}
+	name, ok := ref[len(pkg)].Value.(ast.String)
+	return ok && f.rules[string(name)]
+}
+
+// libraryRef is a ref to the library in a module.
+type libraryRef struct {
+	// ref is the constant prefix of the ref, with import aliases resolved.
+	ref ast.Ref
+	// imported is true for imports, which are only checked to exist as the refs through them are
+	// what use the library.
+	imported bool
+	// implicit is true for unqualified refs to a rule that the module's package may define in
+	// another file.  They may also be local variables or builtins, so they are only resolved if the
+	// library defines them.
+	implicit bool
+}
+
+// libraryRefs returns the refs under the library namespace in the module.
+func libraryRefs(m *ast.Module) []libraryRef {
+	var refs []libraryRef
+	aliases := map[ast.Var]ast.Ref{}
+	for _, imp := range m.Imports {
+		path, ok := imp.Path.Value.(ast.Ref)
+		if !ok || !path.HasPrefix(libraryNamespace) {
+			continue
+		}
+		alias := imp.Alias
+		if alias == "" {
+			last, ok := path[len(path)-1].Value.(ast.String)
+			if !ok {
+				continue
+			}
+			alias = ast.Var(last)
+		}
+		aliases[alias] = path
+		refs = append(refs, libraryRef{ref: path.ConstantPrefix(), imported: true})
+	}
+	own := map[ast.Var]bool{}
+	for _, rule := range m.Rules {
+		own[ruleName(rule)] = true
+	}
+	inLibrary := m.Package.Path.HasPrefix(libraryNamespace)
+
+	for _, rule := range m.Rules {
+		ast.WalkRefs(rule, func(ref ast.Ref) bool {
+			head, ok := ref[0].Value.(ast.Var)
+			if !ok {
+				return false
+			}
+			r := libraryRef{}
+			switch {
+			case ref[0].Equal(ast.DefaultRootDocument):
+				r.ref = ref
+			case aliases[head] != nil:
+				r.ref = aliases[head].Concat(ref[1:])
+			case inLibrary && !own[head]:
+				r.ref = m.Package.Path.Append(ast.StringTerm(string(head))).Concat(ref[1:])
+				r.implicit = true
+			default:
+				return false
+			}
+			r.ref = r.ref.ConstantPrefix()
+			if r.ref.HasPrefix(libraryNamespace) && len(r.ref) > len(libraryNamespace) {
+				refs = append(refs, r)
+			}
+			return false
+		})
+	}
+	return refs
+}
+
+// ruleName returns the name of the rule, the first part of its head.
+func ruleName(rule *ast.Rule) ast.Var {
+	if ref := rule.Head.Ref(); len(ref) != 0 {
+		if name, ok := ref[0].Value.(ast.Var); ok {
+			return name
+		}
+	}
+	return rule.Head.Name
+}
+
+// NewLibraryGraph returns the dependency graph between the legacy templates, with the given rego, and
+// the library modules.  paths are the paths of the modules, or nil if they are not known.
+func NewLibraryGraph(templates []*unstructured.Unstructured, templateRego []string, modules []*ast.Module, paths []string) *LibraryGraph {
+	g := &LibraryGraph{Templates: map[string][]*LibraryFile{}}
+	for idx, m := range modules {
+		f := &LibraryFile{
+			Path:    fmt.Sprintf("library[%d]", idx),
+			Package: m.Package.Path.String(),
+			module:  m,
+			rules:   map[string]bool{},
+		}
+		if idx < len(paths) && paths[idx] != "" {
+			f.Path = paths[idx]
+		}
+		for _, rule := range m.Rules {
+			f.rules[string(ruleName(rule))] = true
+		}
+		g.Files = append(g.Files, f)
+	}
+
+	// resolve returns the files that define each ref, reporting refs that no file defines.
+	resolve := func(m *ast.Module, report func(ref ast.Ref, imported bool)) []*LibraryFile {
+		seen := map[*LibraryFile]bool{}
+		var files []*LibraryFile
+		for _, r := range libraryRefs(m) {
+			found := false
+			for _, f := range g.Files {
+				if f.module == m || !f.defines(r.ref) {
+					continue
+				}
+				found = true
+				if !r.imported && !seen[f] {
+					seen[f] = true
+					files = append(files, f)
+				}
+			}
+			if !found && !r.implicit && !m.Package.Path.HasPrefix(r.ref) {
+				report(r.ref, r.imported)
+			}
+		}
+		return files
+	}
+	missing := func(ref ast.Ref, imported bool) string {
+		if imported {
+			return fmt.Sprintf("imports %s, which is not defined in the policy library", ref)
+		}
+		return fmt.Sprintf("refers to %s, which is not defined in the policy library", ref)
+	}
+
+	for _, f := range g.Files {
+		f := f
+		reported := map[string]bool{}
+		f.Imports = resolve(f.module, func(ref ast.Ref, imported bool) {
+			// Refs to the file's own rules are not library refs.
+			if f.defines(ref) || reported[ref.String()] {
+				return
+			}
+			reported[ref.String()] = true
+			g.Missing = append(g.Missing, &Issue{Path: f.Path, Warning: true, Message: missing(ref, imported)})
+		})
+	}
+
+	var used []*LibraryFile
+	for idx, u := range templates {
+		if idx >= len(templateRego) {
+			break
+		}
+		m, err := ast.ParseModule(SourcePath(u), templateRego[idx])
+		if err != nil {
+			// The conversion of the template reports the error.
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(u.Object, "spec", "crd", "spec", "names", "kind")
+		reported := map[string]bool{}
+		files := resolve(m, func(ref ast.Ref, imported bool) {
+			if reported[ref.String()] {
+				return
+			}
+			reported[ref.String()] = true
+			g.Missing = append(g.Missing, NewIssue(u, true, missing(ref, imported)))
+		})
+		g.Templates[kind] = files
+		used = append(used, files...)
+	}
+	for len(used) != 0 {
+		f := used[0]
+		used = used[1:]
+		if f.Used {
+			continue
+		}
+		f.Used = true
+		used = append(used, f.Imports...)
+	}
+	return g
+}
+
+// Issues returns the warnings for the library refs that are not defined and the library files that are
+// not used.
+func (g *LibraryGraph) Issues() []*Issue {
+	issues := append([]*Issue{}, g.Missing...)
+	for _, f := range g.Unused() {
+		issues = append(issues, &Issue{
+			Path:    f.Path,
+			Warning: true,
+			Message: fmt.Sprintf("library file with package %s is not used by any template", f.Package),
+		})
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Path < issues[j].Path
+	})
+	return issues
+}
+
+// legacyTemplateRego returns the rego of a legacy template before conversion, empty if it has none.
+func legacyTemplateRego(u *unstructured.Unstructured) string {
+	targets, _, _ := unstructured.NestedMap(u.Object, "spec", "targets")
+	for _, target := range targets {
+		if targetMap, ok := target.(map[string]interface{}); ok {
+			rego, _ := targetMap["rego"].(string)
+			return rego
+		}
+	}
+	return ""
+}
+
+// checkLibrary records warnings for the library refs of the legacy templates that the library does
+// not define and for the library files that no template uses.
+func (c *Configuration) checkLibrary(templates []*unstructured.Unstructured, templateRego []string) {
+	modules, err := c.parseRegoLib()
+	if err != nil {
+		// The conversion of the templates reports the error.
+		return
+	}
+	c.Library = NewLibraryGraph(templates, templateRego, modules, c.regoLibPaths)
+	for _, issue := range c.Library.Issues() {
+		glog.Warning(issue)
+		c.Warnings = append(c.Warnings, issue)
+	}
+}
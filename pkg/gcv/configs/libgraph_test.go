@@ -0,0 +1,147 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const libGraphTemplate = `
+apiVersion: templates.gatekeeper.sh/v1alpha1
+kind: ConstraintTemplate
+metadata:
+  name: lib-graph
+spec:
+  crd:
+    spec:
+      names:
+        kind: LibGraphConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: object
+  targets:
+    validation.gcp.forsetisecurity.org:
+      rego: |
+        package templates.gcp.LibGraphConstraintV1
+
+        import data.validator.gcp.lib as lib
+        import data.validator.gcp.removed
+
+        deny[{"msg": msg}] {
+          params := lib.get_default(input.constraint, "spec", {})
+          lib.renamed(params)
+          msg := "violation"
+        }
+`
+
+var libGraphLibrary = map[string]string{
+	"lib/constraints.rego": `package validator.gcp.lib
+
+get_default(obj, key, fallback) = output {
+  output := has_field(obj, key)
+}
+`,
+	"lib/util.rego": `package validator.gcp.lib
+
+has_field(obj, field) {
+  obj[field]
+}
+`,
+	"lib/k8s.rego": `package validator.k8s.lib
+
+object_name(review) = review.object.metadata.name
+`,
+	"lib/broken.rego": `package validator.k8s.util
+
+import data.validator.k8s.missing
+
+namespace(review) = data.validator.k8s.lib.namespace(review)
+`,
+	"lib/util_test.rego": `package validator.gcp.lib
+
+test_has_field {
+  has_field({"a": 1}, "a")
+}
+`,
+}
+
+func TestLibraryGraph(t *testing.T) {
+	var lib []*PolicyFile
+	for path, content := range libGraphLibrary {
+		lib = append(lib, &PolicyFile{Path: path, Content: []byte(content)})
+	}
+	sortByContent(lib)
+	config, issues := LintFilesWithLibrary([]*PolicyFile{{Path: "templates/lib_graph.yaml", Content: []byte(libGraphTemplate)}}, lib)
+	if config.Library == nil {
+		t.Fatal("no library graph")
+	}
+
+	var used []string
+	for _, f := range config.Library.Files {
+		if f.Used {
+			used = append(used, f.Path)
+		}
+	}
+	sort.Strings(used)
+	if diff := cmp.Diff([]string{"lib/constraints.rego", "lib/util.rego"}, used); diff != "" {
+		t.Errorf("used files diff (-want +got):\n%s", diff)
+	}
+	var direct []string
+	for _, f := range config.Library.Templates["LibGraphConstraintV1"] {
+		direct = append(direct, f.Path)
+	}
+	sort.Strings(direct)
+	// util.rego is only used through constraints.rego.
+	if diff := cmp.Diff([]string{"lib/constraints.rego"}, direct); diff != "" {
+		t.Errorf("template imports diff (-want +got):\n%s", diff)
+	}
+
+	var got []string
+	for _, issue := range issues {
+		if issue.Warning && !strings.Contains(issue.Message, "deprecated") {
+			got = append(got, issue.String())
+		}
+	}
+	want := []string{
+		"lib/broken.rego: warning: imports data.validator.k8s.missing, which is not defined in the policy library",
+		"lib/broken.rego: warning: refers to data.validator.k8s.lib.namespace, which is not defined in the policy library",
+		"lib/broken.rego: warning: library file with package data.validator.k8s.util is not used by any template",
+		"lib/k8s.rego: warning: library file with package data.validator.k8s.lib is not used by any template",
+		"templates/lib_graph.yaml: warning: ConstraintTemplate libgraphconstraintv1: imports data.validator.gcp.removed, which is not defined in the policy library",
+		"templates/lib_graph.yaml: warning: ConstraintTemplate libgraphconstraintv1: refers to data.validator.gcp.lib.renamed, which is not defined in the policy library",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("warnings diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestLibraryGraphWithoutPaths(t *testing.T) {
+	objects, err := LoadUnstructuredFromContents([]*PolicyFile{{Path: "lib_graph.yaml", Content: []byte(libGraphTemplate)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := NewConfigurationFromContents(objects, []string{libGraphLibrary["lib/constraints.rego"], libGraphLibrary["lib/k8s.rego"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused := config.Library.Unused()
+	if len(unused) != 1 || unused[0].Path != "library[1]" || !strings.Contains(unused[0].Package, "k8s") {
+		t.Errorf("got unused files %v, want library[1]", unused)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Issue is a problem found in a policy file.
+type Issue struct {
+	// Path is the file the problem was found in.
+	Path string
+	// Kind is the kind of the object the problem was found in, empty if the file could not be decoded.
+	Kind string
+	// Name is the name of the object the problem was found in, empty if the file could not be decoded.
+	Name string
+	// Warning is true for problems that don't prevent the policy from being loaded.
+	Warning bool
+	// Message describes the problem.
+	Message string
+}
+
+// String implements fmt.Stringer.
+func (i *Issue) String() string {
+	level := "error"
+	if i.Warning {
+		level = "warning"
+	}
+	if i.Kind == "" {
+		return fmt.Sprintf("%s: %s: %s", i.Path, level, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s %s: %s", i.Path, level, i.Kind, i.Name, i.Message)
+}
+
+// NewIssue returns an Issue for an object that was loaded from a policy file.
+func NewIssue(u *unstructured.Unstructured, warning bool, message string) *Issue {
+	return &Issue{
+		Path:    SourcePath(u),
+		Kind:    u.GetKind(),
+		Name:    u.GetName(),
+		Warning: warning,
+		Message: message,
+	}
+}
+
+// warn logs a warning about u and records it in the configuration.
+func (c *Configuration) warn(u *unstructured.Unstructured, message string) {
+	glog.Warningf("%s %s: %s", u.GetKind(), u.GetName(), message)
+	c.Warnings = append(c.Warnings, NewIssue(u, true, message))
+}
+
+// LintFiles loads the given policy files, returning the configuration that could be loaded along with
+// the errors and warnings found in each file.  Unlike NewConfigurationFromContents, loading continues
+// past errors so that all problems are reported at once.
+func LintFiles(files []*PolicyFile, regoLib []string) (*Configuration, []*Issue) {
+	return lintFiles(files, regoLib, nil, ConflictError)
+}
+
+// LintFilesWithLibrary is LintFiles with the library files, so that library issues are reported
+// against their paths.
+func LintFilesWithLibrary(files []*PolicyFile, lib []*PolicyFile) (*Configuration, []*Issue) {
+	regoLib, paths := libraryContents(lib)
+	return lintFiles(files, regoLib, paths, ConflictError)
+}
+
+func lintFiles(files []*PolicyFile, regoLib, regoLibPaths []string, conflictPolicy ConflictPolicy) (*Configuration, []*Issue) {
+	var issues []*Issue
+	var objects []*unstructured.Unstructured
+	for _, file := range files {
+		fileObjects, err := LoadUnstructuredFromContents([]*PolicyFile{file})
+		if err != nil {
+			issues = append(issues, &Issue{Path: file.Path, Message: err.Error()})
+			continue
+		}
+		objects = append(objects, fileObjects...)
+	}
+
+	configuration := newConfiguration()
+	configuration.conflictPolicy = conflictPolicy
+	configuration.regoLib = regoLib
+	configuration.regoLibPaths = regoLibPaths
+	configuration.convertLegacyTemplates(objects)
+	for _, u := range objects {
+		if err := configuration.loadUnstructured(u); err != nil {
+			issues = append(issues, NewIssue(u, false, err.Error()))
+		}
+	}
+	configuration.finishLoad(func(u *unstructured.Unstructured, err error) {
+		issues = append(issues, NewIssue(u, false, err.Error()))
+	})
+	return configuration, append(issues, configuration.Warnings...)
+}
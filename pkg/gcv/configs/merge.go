@@ -0,0 +1,146 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"sort"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Merge returns a new Configuration with the templates, constraints and warnings of both a and b, for
+// example a central security bundle layered with a team bundle.  Templates with the same name or kind
+// and constraints with the same kind and name in both configurations are conflicts, all conflicts are
+// returned in the error along with the path of each declaration.  Neither a nor b is modified.
+func Merge(a, b *Configuration) (*Configuration, error) {
+	merged := newConfiguration()
+	var errs multierror.Errors
+
+	templateKinds := map[string]*cftemplates.ConstraintTemplate{}
+	for _, ct := range a.Templates() {
+		merged.templateNames[ct.Name] = ct
+		templateKinds[ct.Spec.CRD.Spec.Names.Kind] = ct
+	}
+	for _, ct := range b.Templates() {
+		if dup, found := merged.templateNames[ct.Name]; found {
+			errs.Add(errors.Errorf(
+				"ConstraintTemplate %q declared at path %q has duplicate name conflict with template declared at path %q",
+				ct.Name, SourcePath(ct), SourcePath(dup)))
+		}
+		if dup, found := templateKinds[ct.Spec.CRD.Spec.Names.Kind]; found {
+			errs.Add(errors.Errorf(
+				"ConstraintTemplate %q crd kind %q declared at path %q has duplicate kind conflict with template %q declared at path %q",
+				ct.Name, ct.Spec.CRD.Spec.Names.Kind, SourcePath(ct), dup.Name, SourcePath(dup)))
+		}
+	}
+
+	constraints := map[string]*unstructured.Unstructured{}
+	for _, constraint := range a.Constraints() {
+		constraints[constraintKey(constraint)] = constraint
+	}
+	for _, constraint := range b.Constraints() {
+		if dup, found := constraints[constraintKey(constraint)]; found {
+			errs.Add(errors.Errorf(
+				"Constraint %s %q declared at path %q has duplicate name conflict with constraint declared at path %q",
+				constraint.GetKind(), constraint.GetName(), SourcePath(constraint), SourcePath(dup)))
+		}
+	}
+	expansionTemplates := map[string]*ExpansionTemplate{}
+	for _, t := range a.ExpansionTemplates {
+		expansionTemplates[t.Name] = t
+	}
+	for _, t := range b.ExpansionTemplates {
+		if dup, found := expansionTemplates[t.Name]; found {
+			errs.Add(errors.Errorf(
+				"ExpansionTemplate %q declared at path %q has duplicate name conflict with template declared at path %q",
+				t.Name, t.Path, dup.Path))
+		}
+	}
+	if !errs.Empty() {
+		return nil, errors.Wrapf(errs.ToError(), "failed to merge configurations")
+	}
+
+	for _, c := range []*Configuration{a, b} {
+		for _, ct := range c.Templates() {
+			merged.templateNames[ct.Name] = ct
+			merged.templateKinds[ct.Spec.CRD.Spec.Names.Kind] = ct
+		}
+		merged.GCPTemplates = append(merged.GCPTemplates, c.GCPTemplates...)
+		merged.GCPConstraints = append(merged.GCPConstraints, c.GCPConstraints...)
+		merged.K8STemplates = append(merged.K8STemplates, c.K8STemplates...)
+		merged.K8SConstraints = append(merged.K8SConstraints, c.K8SConstraints...)
+		merged.TFTemplates = append(merged.TFTemplates, c.TFTemplates...)
+		merged.TFConstraints = append(merged.TFConstraints, c.TFConstraints...)
+		merged.Warnings = append(merged.Warnings, c.Warnings...)
+		merged.ExpansionTemplates = append(merged.ExpansionTemplates, c.ExpansionTemplates...)
+		for target, templates := range c.CustomTemplates {
+			merged.CustomTemplates[target] = append(merged.CustomTemplates[target], templates...)
+		}
+		for target, constraints := range c.CustomConstraints {
+			merged.CustomConstraints[target] = append(merged.CustomConstraints[target], constraints...)
+		}
+	}
+	return merged, nil
+}
+
+// CustomTargets returns the sorted names of the custom targets with templates in the configuration.
+func (c *Configuration) CustomTargets() []string {
+	var targets []string
+	for target := range c.CustomTemplates {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Templates returns each template in the configuration once, templates with multiple targets are in
+// more than one of the per target lists.
+func (c *Configuration) Templates() []*cftemplates.ConstraintTemplate {
+	lists := [][]*cftemplates.ConstraintTemplate{c.GCPTemplates, c.K8STemplates, c.TFTemplates}
+	for _, target := range c.CustomTargets() {
+		lists = append(lists, c.CustomTemplates[target])
+	}
+	var templates []*cftemplates.ConstraintTemplate
+	seen := map[*cftemplates.ConstraintTemplate]bool{}
+	for _, list := range lists {
+		for _, ct := range list {
+			if !seen[ct] {
+				seen[ct] = true
+				templates = append(templates, ct)
+			}
+		}
+	}
+	return templates
+}
+
+// Constraints returns the constraints for all targets.
+func (c *Configuration) Constraints() []*unstructured.Unstructured {
+	var constraints []*unstructured.Unstructured
+	constraints = append(constraints, c.GCPConstraints...)
+	constraints = append(constraints, c.K8SConstraints...)
+	constraints = append(constraints, c.TFConstraints...)
+	for _, target := range c.CustomTargets() {
+		constraints = append(constraints, c.CustomConstraints[target]...)
+	}
+	return constraints
+}
+
+// constraintKey identifies a constraint, names are only unique within a kind.
+func constraintKey(constraint *unstructured.Unstructured) string {
+	return constraint.GetKind() + "/" + constraint.GetName()
+}
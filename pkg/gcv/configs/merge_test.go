@@ -0,0 +1,67 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	gcp, err := NewConfiguration([]string{
+		"../../../test/cf/templates/gcp_storage_logging_template.yaml",
+		"../../../test/cf/constraints/gcp_storage_logging_constraint.yaml",
+	}, "../../../test/cf/library")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	tf, err := NewConfiguration([]string{
+		"../../../test/cf/templates/tf_compute_instance_machine_type.yaml",
+		"../../../test/cf/constraints/tf_compute_instance_mt_constraint.yaml",
+	}, "../../../test/cf/library")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	merged, err := Merge(gcp, tf)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(merged.GCPTemplates) != 1 || len(merged.GCPConstraints) != 1 {
+		t.Errorf("got %d GCP templates and %d constraints, want 1 and 1", len(merged.GCPTemplates), len(merged.GCPConstraints))
+	}
+	if len(merged.TFTemplates) != 1 || len(merged.TFConstraints) != 1 {
+		t.Errorf("got %d TF templates and %d constraints, want 1 and 1", len(merged.TFTemplates), len(merged.TFConstraints))
+	}
+
+	all, err := NewConfiguration([]string{"../../../test/cf"}, "../../../test/cf/library")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	_, err = Merge(all, gcp)
+	if err == nil {
+		t.Fatalf("expected conflict error")
+	}
+	for _, want := range []string{
+		"gcp_storage_logging_template.yaml",
+		"duplicate name conflict",
+		"duplicate kind conflict",
+		"require-storage-logging-xx",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ConvertLegacyTemplate converts a legacy v1alpha1 ConstraintTemplate to v1beta1, as it is converted
+// when loaded: the deny rule is adapted to the violation entrypoint and the parts of regoLib, the
+// policy library, that the template uses are rewritten into the libs of its target.  The template is
+// named after its kind.  u is not modified.
+func ConvertLegacyTemplate(u *unstructured.Unstructured, regoLib []string) (*unstructured.Unstructured, error) {
+	gvk := u.GroupVersionKind()
+	if gvk.Group != templateGroup || gvk.Kind != "ConstraintTemplate" || gvk.Version != "v1alpha1" {
+		return nil, errors.Errorf("%s is not a v1alpha1 ConstraintTemplate", gvk)
+	}
+	c := newConfiguration()
+	c.regoLib = regoLib
+	converted := u.DeepCopy()
+	if err := c.convertLegacyTemplate(converted); err != nil {
+		return nil, err
+	}
+	converted.SetAPIVersion(templateGroup + "/v1beta1")
+	removeLoaderAnnotations(converted)
+	return converted, nil
+}
+
+// ConvertLegacyConstraint converts a legacy v1alpha1 constraint to v1beta1, as it is converted when
+// loaded: the name is made a valid resource name and the spec.match.target and spec.match.exclude
+// ancestries are normalized to spec.match.ancestries and spec.match.excludedAncestries.  u is not
+// modified.
+func ConvertLegacyConstraint(u *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	gvk := u.GroupVersionKind()
+	if gvk.Group != constraintGroup || gvk.Version != "v1alpha1" {
+		return nil, errors.Errorf("%s is not a v1alpha1 constraint", gvk)
+	}
+	converted := u.DeepCopy()
+	if err := convertLegacyConstraint(converted); err != nil {
+		return nil, err
+	}
+	for from, to := range map[string]string{"target": "ancestries", "exclude": "excludedAncestries"} {
+		value, found, err := unstructured.NestedFieldNoCopy(converted.Object, "spec", "match", from)
+		if err != nil || !found {
+			continue
+		}
+		if _, found, _ := unstructured.NestedFieldNoCopy(converted.Object, "spec", "match", to); found {
+			return nil, errors.Errorf("only one of spec.match.%s and spec.match.%s can be specified", from, to)
+		}
+		unstructured.RemoveNestedField(converted.Object, "spec", "match", from)
+		if err := unstructured.SetNestedField(converted.Object, value, "spec", "match", to); err != nil {
+			return nil, errors.Wrapf(err, "failed to set spec.match.%s", to)
+		}
+	}
+	converted.SetAPIVersion(constraintGroup + "/v1beta1")
+	removeLoaderAnnotations(converted)
+	return converted, nil
+}
+
+// removeLoaderAnnotations removes the annotations that IsLoaderAnnotation reports, so that converted
+// objects can be written back to their source.
+func removeLoaderAnnotations(u *unstructured.Unstructured) {
+	annotations := u.GetAnnotations()
+	for key := range annotations {
+		if IsLoaderAnnotation(key) {
+			delete(annotations, key)
+		}
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	u.SetAnnotations(annotations)
+}
+
+// MigrateFile converts the legacy v1alpha1 templates and constraints in the content of a policy file,
+// which may hold several YAML documents, to v1beta1 with ConvertLegacyTemplate and
+// ConvertLegacyConstraint.  The other documents are kept as they are.  Converted documents keep the
+// comments that precede them, such as license headers, but lose those inside them.  It returns the
+// migrated content and whether any document was converted.
+func MigrateFile(content []byte, regoLib []string) ([]byte, bool, error) {
+	documents := strings.Split(string(content), "\n---")
+	migrated := false
+	for idx, document := range documents {
+		converted, err := migrateDocument(document, regoLib)
+		if err != nil {
+			return nil, false, fmt.Errorf("document %d: %w", idx+1, err)
+		}
+		if converted != "" {
+			documents[idx] = converted
+			migrated = true
+		}
+	}
+	if !migrated {
+		return content, false, nil
+	}
+	return []byte(strings.Join(documents, "\n---")), true, nil
+}
+
+// migrateDocument returns the converted YAML document, or an empty string if it is not a legacy
+// template or constraint.
+func migrateDocument(document string, regoLib []string) (string, error) {
+	var u unstructured.Unstructured
+	if err := yaml.Unmarshal([]byte(document), &u.Object); err != nil {
+		return "", err
+	}
+	if u.Object == nil {
+		return "", nil
+	}
+	gvk := u.GroupVersionKind()
+	if gvk.Version != "v1alpha1" {
+		return "", nil
+	}
+	var converted *unstructured.Unstructured
+	var err error
+	switch {
+	case gvk.Group == templateGroup && gvk.Kind == "ConstraintTemplate":
+		converted, err = ConvertLegacyTemplate(&u, regoLib)
+	case gvk.Group == constraintGroup:
+		converted, err = ConvertLegacyConstraint(&u)
+	default:
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to convert %s %s", gvk.Kind, u.GetName())
+	}
+	out, err := yaml.Marshal(converted.Object)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to marshal %s %s", gvk.Kind, converted.GetName())
+	}
+	if !strings.HasSuffix(document, "\n") {
+		// The document is followed by a separator, which starts with the newline.
+		out = bytes.TrimSuffix(out, []byte("\n"))
+	}
+	return leadingComments(document) + string(out), nil
+}
+
+// leadingComments returns the blank, comment and document separator lines at the start of the YAML
+// document.
+func leadingComments(document string) string {
+	var buf strings.Builder
+	for _, line := range strings.SplitAfter(document, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && trimmed != "---" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		buf.WriteString(line)
+	}
+	return buf.String()
+}
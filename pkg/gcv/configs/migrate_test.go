@@ -0,0 +1,144 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMigrateFile(t *testing.T) {
+	regoLib, err := LoadRegoFiles("../../../test/cf/library")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []*PolicyFile
+	for _, path := range []string{
+		"../../../test/cf/templates/gcp_storage_logging_template.yaml",
+		"../../../test/cf/constraints/gcp_storage_logging_constraint.yaml",
+	} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		migrated, changed, err := MigrateFile(content, regoLib)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if !changed {
+			t.Fatalf("%s: got no change", path)
+		}
+		if !strings.HasPrefix(string(migrated), "# Copyright 2019 Google LLC\n") {
+			t.Errorf("%s: got license header dropped:\n%s", path, migrated)
+		}
+		files = append(files, &PolicyFile{Path: path, Content: migrated})
+	}
+
+	objects, err := LoadUnstructuredFromContents(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range objects {
+		if got := u.GroupVersionKind().Version; got != "v1beta1" {
+			t.Errorf("%s %s: got version %s, want v1beta1", u.GetKind(), u.GetName(), got)
+		}
+	}
+	// The migrated template carries the library it uses, so it loads without one.
+	config, err := NewConfigurationFromContents(objects, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.GCPTemplates) != 1 || len(config.GCPConstraints) != 1 {
+		t.Fatalf("got %d templates and %d constraints, want 1 of each", len(config.GCPTemplates), len(config.GCPConstraints))
+	}
+	if len(config.Warnings) != 0 {
+		t.Errorf("got warnings %v", config.Warnings)
+	}
+	constraint := config.GCPConstraints[0]
+	if got, want := constraint.GetName(), "require-storage-logging-xx"; got != want {
+		t.Errorf("got constraint name %q, want %q", got, want)
+	}
+	match, _, _ := unstructured.NestedMap(constraint.Object, "spec", "match")
+	wantMatch := map[string]interface{}{
+		"ancestries":         []interface{}{"organizations/**"},
+		"excludedAncestries": []interface{}{},
+	}
+	if diff := cmp.Diff(wantMatch, match); diff != "" {
+		t.Errorf("match diff (-want +got):\n%s", diff)
+	}
+	if got := constraint.GetAnnotations()["benchmark"]; got != "CIS11_5.03" {
+		t.Errorf("got benchmark annotation %q, want it kept", got)
+	}
+}
+
+func TestMigrateFileUnchanged(t *testing.T) {
+	content, err := os.ReadFile("../../../test/cf/templates/cf_gcp_storage_logging_template.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated, changed, err := MigrateFile(content, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || string(migrated) != string(content) {
+		t.Errorf("got v1beta1 template changed")
+	}
+}
+
+func TestMigrateFileMultipleDocuments(t *testing.T) {
+	content := `apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: GCPStorageLoggingConstraint
+metadata:
+  name: current
+---
+# legacy
+apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPStorageLoggingConstraint
+metadata:
+  name: legacy_constraint
+spec:
+  match:
+    target: ["organization/*"]
+`
+	migrated, changed, err := MigrateFile([]byte(content), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("got no change")
+	}
+	want := `apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: GCPStorageLoggingConstraint
+metadata:
+  name: current
+---
+# legacy
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: GCPStorageLoggingConstraint
+metadata:
+  name: legacy-constraint
+spec:
+  match:
+    ancestries:
+    - organizations/**
+`
+	if diff := cmp.Diff(want, string(migrated)); diff != "" {
+		t.Errorf("migrated diff (-want +got):\n%s", diff)
+	}
+}
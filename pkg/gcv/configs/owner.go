@@ -0,0 +1,40 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OwnerAnnotation is the constraint annotation naming the team or person that owns the constraint.
+const OwnerAnnotation = GCPTargetName + "/owner"
+
+// Owner returns the owner of the constraint, or an empty string if it has no owner.
+func Owner(u metav1.Object) string {
+	return strings.TrimSpace(u.GetAnnotations()[OwnerAnnotation])
+}
+
+// MissingOwners returns an error issue for each constraint in the configuration without an owner.
+func (c *Configuration) MissingOwners() []*Issue {
+	var issues []*Issue
+	for _, constraint := range c.Constraints() {
+		if Owner(constraint) == "" {
+			issues = append(issues, NewIssue(constraint, false, "missing required annotation "+OwnerAnnotation))
+		}
+	}
+	return issues
+}
@@ -0,0 +1,167 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"fmt"
+	"sort"
+
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"github.com/pkg/errors"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ValidateParameters returns an error for each of the constraint's spec.parameters that isn't
+// declared in the template's openAPIV3Schema, or doesn't have the declared type.  Rego reads an
+// unknown parameter as undefined, so a misspelled parameter silently falls back to the policy's
+// default.  Objects whose schema declares no properties, or that preserve unknown fields, accept any
+// keys.
+func ValidateParameters(templ *cftemplates.ConstraintTemplate, constraint *unstructured.Unstructured) []error {
+	parameters, found, err := unstructured.NestedFieldNoCopy(constraint.Object, "spec", "parameters")
+	if err != nil || !found {
+		return nil
+	}
+	var schema *apiextensions.JSONSchemaProps
+	if templ.Spec.CRD.Spec.Validation != nil {
+		schema = templ.Spec.CRD.Spec.Validation.OpenAPIV3Schema
+	}
+	var errs []error
+	checkParameter("spec.parameters", schema, parameters, &errs)
+	return errs
+}
+
+// checkParameter appends an error to errs for each value in value that doesn't match schema.
+func checkParameter(path string, schema *apiextensions.JSONSchemaProps, value interface{}, errs *[]error) {
+	if schema == nil || value == nil {
+		return
+	}
+	if schema.XIntOrString {
+		switch value.(type) {
+		case int64, string:
+			return
+		}
+		*errs = append(*errs, errors.Errorf("parameter %s must be an integer or string, got %s", path, jsonType(value)))
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		if _, ok := value.(map[string]interface{}); !ok {
+			*errs = append(*errs, errors.Errorf("parameter %s must be of type object, got %s", path, jsonType(value)))
+			return
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			*errs = append(*errs, errors.Errorf("parameter %s must be of type array, got %s", path, jsonType(value)))
+			return
+		}
+		if schema.Items != nil {
+			for idx, item := range items {
+				checkParameter(fmt.Sprintf("%s[%d]", path, idx), schema.Items.Schema, item, errs)
+			}
+		}
+		return
+	case "string", "boolean", "integer", "number":
+		if got := jsonType(value); got != schema.Type && !(schema.Type == "number" && got == "integer") {
+			*errs = append(*errs, errors.Errorf("parameter %s must be of type %s, got %s", path, schema.Type, got))
+		}
+		return
+	}
+
+	// Legacy templates may declare properties without an object type.
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		keyPath := path + "." + key
+		if property, found := schema.Properties[key]; found {
+			checkParameter(keyPath, &property, object[key], errs)
+			continue
+		}
+		switch {
+		case schema.AdditionalProperties != nil:
+			if schema.AdditionalProperties.Schema != nil {
+				checkParameter(keyPath, schema.AdditionalProperties.Schema, object[key], errs)
+			} else if !schema.AdditionalProperties.Allows {
+				*errs = append(*errs, errors.Errorf("unknown parameter %s", keyPath))
+			}
+		case schema.XPreserveUnknownFields != nil && *schema.XPreserveUnknownFields:
+		case len(schema.Properties) == 0:
+		default:
+			*errs = append(*errs, errors.Errorf("unknown parameter %s", keyPath))
+		}
+	}
+}
+
+// jsonType returns the JSON schema type of a value decoded from YAML or JSON.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int64, int:
+		return "integer"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// InvalidParameters returns an error issue for each constraint parameter in the configuration that
+// doesn't match its template's schema, see ValidateParameters.  The same problems are recorded as
+// warnings when the configuration is loaded.
+func (c *Configuration) InvalidParameters() []*Issue {
+	kinds := map[string]*cftemplates.ConstraintTemplate{}
+	for _, ct := range c.Templates() {
+		kinds[ct.Spec.CRD.Spec.Names.Kind] = ct
+	}
+	var issues []*Issue
+	for _, constraint := range c.Constraints() {
+		templ, found := kinds[constraint.GetKind()]
+		if !found {
+			continue
+		}
+		for _, err := range ValidateParameters(templ, constraint) {
+			issues = append(issues, NewIssue(constraint, false, err.Error()))
+		}
+	}
+	return issues
+}
+
+// checkParameters warns about each of the constraint's parameters that doesn't match the template's
+// schema.
+func (c *Configuration) checkParameters(templ *cftemplates.ConstraintTemplate, constraint *unstructured.Unstructured) {
+	for _, err := range ValidateParameters(templ, constraint) {
+		c.warn(constraint, err.Error())
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const parametersTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpparameterstestconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPParametersTestConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: object
+          properties:
+            mode:
+              type: string
+            locations:
+              type: array
+              items:
+                type: string
+            limits:
+              type: object
+              additionalProperties:
+                type: integer
+            extra:
+              type: object
+  targets:
+    - target: validation.gcp.forsetisecurity.org
+      rego: |
+        package templates.gcp.GCPParametersTestConstraintV1
+
+        violation[{"msg": "never"}] {
+        	false
+        }
+`
+
+const parametersConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: GCPParametersTestConstraintV1
+metadata:
+  name: typos
+spec:
+  parameters:
+    mode: 3
+    locaitons: ["us-east1"]
+    locations: ["us-east1", true]
+    limits:
+      cpu: 2
+      memory: "lots"
+    extra:
+      anything: {}
+`
+
+func TestValidateParameters(t *testing.T) {
+	objects, err := LoadUnstructuredFromContents([]*PolicyFile{
+		{Path: "template.yaml", Content: []byte(parametersTemplate)},
+		{Path: "constraint.yaml", Content: []byte(parametersConstraint)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := NewConfigurationFromContents(objects, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	var got []string
+	for _, err := range ValidateParameters(config.GCPTemplates[0], config.GCPConstraints[0]) {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"parameter spec.parameters.limits.memory must be of type integer, got string",
+		"unknown parameter spec.parameters.locaitons",
+		"parameter spec.parameters.locations[1] must be of type string, got boolean",
+		"parameter spec.parameters.mode must be of type string, got integer",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("errors diff (-want +got):\n%s", diff)
+	}
+
+	var warnings, invalid []string
+	for _, issue := range config.Warnings {
+		warnings = append(warnings, issue.Message)
+	}
+	for _, issue := range config.InvalidParameters() {
+		if issue.Warning || issue.Path != "constraint.yaml" {
+			t.Errorf("got issue %s, want error in constraint.yaml", issue)
+		}
+		invalid = append(invalid, issue.Message)
+	}
+	if diff := cmp.Diff(want, warnings); diff != "" {
+		t.Errorf("warnings diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, invalid); diff != "" {
+		t.Errorf("invalid parameters diff (-want +got):\n%s", diff)
+	}
+
+	preserve := true
+	config.GCPTemplates[0].Spec.CRD.Spec.Validation.OpenAPIV3Schema.XPreserveUnknownFields = &preserve
+	if errs := ValidateParameters(config.GCPTemplates[0], config.GCPConstraints[0]); len(errs) != 3 {
+		t.Errorf("got errors %v, want only type errors when unknown fields are preserved", errs)
+	}
+}
+
+func TestJSONType(t *testing.T) {
+	for value, want := range map[interface{}]string{
+		int64(1): "integer",
+		1.0:      "integer",
+		1.5:      "number",
+		"s":      "string",
+		false:    "boolean",
+	} {
+		if got := jsonType(value); got != want {
+			t.Errorf("jsonType(%v) = %s, want %s", value, got, want)
+		}
+	}
+}
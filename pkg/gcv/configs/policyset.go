@@ -0,0 +1,36 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"strings"
+)
+
+// PolicySetsAnnotation is the constraint annotation listing the policy sets the constraint is in,
+// separated by comma, eg "cis,pci".  Review requests can be limited to the constraints of a policy set.
+const PolicySetsAnnotation = GCPTargetName + "/policy-sets"
+
+// InPolicySet returns true if the annotations, those of a constraint, put it in the named policy set.
+func InPolicySet(annotations map[string]string, policySet string) bool {
+	if policySet == "" {
+		return false
+	}
+	for _, name := range strings.Split(annotations[PolicySetsAnnotation], ",") {
+		if strings.TrimSpace(name) == policySet {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"testing"
+)
+
+func TestInPolicySet(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		policySet   string
+		want        bool
+	}{
+		{name: "single", annotations: map[string]string{PolicySetsAnnotation: "cis"}, policySet: "cis", want: true},
+		{name: "list", annotations: map[string]string{PolicySetsAnnotation: "cis, pci"}, policySet: "pci", want: true},
+		{name: "other set", annotations: map[string]string{PolicySetsAnnotation: "cis"}, policySet: "pci"},
+		{name: "no annotation", policySet: "cis"},
+		{name: "empty set", annotations: map[string]string{PolicySetsAnnotation: ""}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := InPolicySet(tc.annotations, tc.policySet); got != tc.want {
+				t.Errorf("InPolicySet(%v, %q) = %v, want %v", tc.annotations, tc.policySet, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RegoTestSuffix is the suffix of the files holding rego unit tests.
+const RegoTestSuffix = "_test.rego"
+
+// PolicyTestFiles are the files rego unit tests are run with, see ReadPolicyTestFiles.
+type PolicyTestFiles struct {
+	// Modules maps the path of each rego file to its content, including the test files.
+	Modules map[string]string
+	// Data is the document built from the JSON files.
+	Data map[string]interface{}
+}
+
+// HasTests returns true if any of the modules are test files.
+func (f *PolicyTestFiles) HasTests() bool {
+	for path := range f.Modules {
+		if strings.HasSuffix(path, RegoTestSuffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadPolicyTestFiles reads the rego and JSON files under dirs.  As for `opa test`, the content of
+// each JSON file is stored in the data document at the path of its directory relative to the dir it
+// was found in, so lib/fixtures/buckets/data.json is data.fixtures.buckets.
+func ReadPolicyTestFiles(dirs []string) (*PolicyTestFiles, error) {
+	ctx := context.Background()
+	files := &PolicyTestFiles{Modules: map[string]string{}, Data: map[string]interface{}{}}
+	for _, dir := range dirs {
+		dirPath, err := NewPath(dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to handle path for %s", dir)
+		}
+		regoFiles, err := dirPath.ReadAll(ctx, SuffixPredicate(".rego"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read files from %s", dir)
+		}
+		for _, f := range regoFiles {
+			files.Modules[f.Path] = string(f.Content)
+		}
+
+		jsonFiles, err := dirPath.ReadAll(ctx, SuffixPredicate(".json"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read files from %s", dir)
+		}
+		for _, f := range jsonFiles {
+			rel, err := relativePath(dir, f.Path)
+			if err != nil {
+				return nil, err
+			}
+			var doc interface{}
+			if err := json.Unmarshal(f.Content, &doc); err != nil {
+				return nil, errors.Wrapf(err, "failed to decode %s", f.Path)
+			}
+			var keys []string
+			if docDir := path.Dir(filepath.ToSlash(rel)); docDir != "." {
+				keys = strings.Split(docDir, "/")
+			}
+			if err := mergeData(files.Data, keys, doc); err != nil {
+				return nil, errors.Wrapf(err, "failed to add %s to the data document", f.Path)
+			}
+		}
+	}
+	return files, nil
+}
+
+// mergeData stores doc in data at the path of keys, merging objects.
+func mergeData(data map[string]interface{}, keys []string, doc interface{}) error {
+	if len(keys) != 0 {
+		child, found := data[keys[0]]
+		if !found {
+			child = map[string]interface{}{}
+			data[keys[0]] = child
+		}
+		childData, ok := child.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("conflicting value at %s", keys[0])
+		}
+		return mergeData(childData, keys[1:], doc)
+	}
+
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return errors.New("only objects can be merged into the data document")
+	}
+	for key, value := range obj {
+		existing, found := data[key]
+		if !found {
+			data[key] = value
+			continue
+		}
+		existingObj, existingIsObj := existing.(map[string]interface{})
+		if _, valueIsObj := value.(map[string]interface{}); !existingIsObj || !valueIsObj {
+			return errors.Errorf("conflicting value at %s", key)
+		}
+		if err := mergeData(existingObj, nil, value); err != nil {
+			return errors.Wrapf(err, "in %s", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMergeData(t *testing.T) {
+	data := map[string]interface{}{}
+	if err := mergeData(data, []string{"a", "b"}, map[string]interface{}{"x": 1.0, "y": map[string]interface{}{"z": true}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mergeData(data, []string{"a"}, map[string]interface{}{"b": map[string]interface{}{"y": map[string]interface{}{"w": false}}}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{
+				"x": 1.0,
+				"y": map[string]interface{}{"z": true, "w": false},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, data); diff != "" {
+		t.Errorf("data diff (-want +got):\n%s", diff)
+	}
+
+	if err := mergeData(data, []string{"a", "b"}, map[string]interface{}{"x": 2.0}); err == nil {
+		t.Errorf("expected error for conflicting value")
+	}
+	if err := mergeData(data, []string{"a", "b", "x"}, map[string]interface{}{}); err == nil {
+		t.Errorf("expected error for object under a value")
+	}
+	if err := mergeData(data, nil, []interface{}{}); err == nil {
+		t.Errorf("expected error for array document")
+	}
+}
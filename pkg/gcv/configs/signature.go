@@ -0,0 +1,150 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// BundleSignatureSuffix is the suffix of the detached signature of a bundle archive, which is read
+// from the archive's path with the suffix appended.
+const BundleSignatureSuffix = ".sig"
+
+// ErrBundleSignature is returned when the signature of a bundle archive is missing or does not verify.
+var ErrBundleSignature = errors.New("bundle archive signature does not verify")
+
+// LoadBundleVerificationKey reads the PEM encoded PKIX public key that bundle archive signatures are
+// verified with from the file at keyRef.  ECDSA, Ed25519 and RSA keys are supported, which includes
+// the public keys generated by cosign generate-key-pair.
+func LoadBundleVerificationKey(keyRef string) (crypto.PublicKey, error) {
+	content, err := os.ReadFile(keyRef)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read bundle verification key")
+	}
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.Errorf("bundle verification key %s is not a PEM encoded public key", keyRef)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse bundle verification key %s", keyRef)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey, *rsa.PublicKey:
+		return key, nil
+	default:
+		return nil, errors.Errorf("bundle verification key %s has unsupported type %T", keyRef, key)
+	}
+}
+
+// LoadBundleSigningKey reads the unencrypted PEM encoded PKCS #8, or SEC 1 for ECDSA, private key that
+// bundle archives are signed with from the file at path.
+func LoadBundleSigningKey(path string) (crypto.Signer, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read bundle signing key")
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.Errorf("bundle signing key %s is not PEM encoded", path)
+	}
+	var key interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, errors.Errorf("bundle signing key %s has unsupported PEM type %s", path, block.Type)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse bundle signing key %s", path)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("bundle signing key %s has unsupported type %T", path, key)
+	}
+	return signer, nil
+}
+
+// SignBundleArchive returns the detached signature of the archive, base64 encoded as written by
+// cosign sign-blob.  ECDSA and RSA keys sign the SHA256 digest of the archive, Ed25519 keys sign the
+// archive itself.
+func SignBundleArchive(key crypto.Signer, archive []byte) ([]byte, error) {
+	var sig []byte
+	var err error
+	if _, ok := key.Public().(ed25519.PublicKey); ok {
+		sig, err = key.Sign(rand.Reader, archive, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(archive)
+		sig, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign bundle archive")
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sig)), nil
+}
+
+// VerifyBundleSignature returns ErrBundleSignature unless signature, as written by SignBundleArchive,
+// is the signature of the archive by the private key of key.
+func VerifyBundleSignature(key crypto.PublicKey, archive, signature []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil || len(sig) == 0 {
+		return errors.Wrap(ErrBundleSignature, "signature is not base64 encoded")
+	}
+	digest := sha256.Sum256(archive)
+	verified := false
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		verified = ecdsa.VerifyASN1(key, digest[:], sig)
+	case ed25519.PublicKey:
+		verified = ed25519.Verify(key, archive, sig)
+	case *rsa.PublicKey:
+		verified = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	default:
+		return errors.Errorf("unsupported bundle verification key type %T", key)
+	}
+	if !verified {
+		return ErrBundleSignature
+	}
+	return nil
+}
+
+// ReadSignedBundleArchive is ReadBundleArchive for an archive that must have been signed by the
+// private key of key, see VerifyBundleSignature.  Nothing in the archive is parsed before the
+// signature is verified.
+func ReadSignedBundleArchive(r io.Reader, signature []byte, key crypto.PublicKey) (*Configuration, *BundleManifest, error) {
+	archive, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read bundle archive")
+	}
+	if err := VerifyBundleSignature(key, archive, signature); err != nil {
+		return nil, nil, err
+	}
+	return ReadBundleArchive(bytes.NewReader(archive))
+}
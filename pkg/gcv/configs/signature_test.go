@@ -0,0 +1,136 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeKeyPair writes the PEM encoded private and public keys of key to dir and returns their paths.
+func writeKeyPair(t *testing.T, dir string, key crypto.Signer) (string, string) {
+	t.Helper()
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePath := filepath.Join(dir, "key.pem")
+	publicPath := filepath.Join(dir, "key.pub")
+	if err := os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return privatePath, publicPath
+}
+
+func TestSignedBundleArchive(t *testing.T) {
+	var archive bytes.Buffer
+	if _, err := WriteBundleArchive(&archive, []string{newTestBundle(t)}, ""); err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, key := range map[string]crypto.Signer{"ecdsa": ecdsaKey, "ed25519": ed25519Key, "rsa": rsaKey} {
+		t.Run(name, func(t *testing.T) {
+			privatePath, publicPath := writeKeyPair(t, t.TempDir(), key)
+			signer, err := LoadBundleSigningKey(privatePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			public, err := LoadBundleVerificationKey(publicPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signature, err := SignBundleArchive(signer, archive.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config, manifest, err := ReadSignedBundleArchive(bytes.NewReader(archive.Bytes()), append(signature, '\n'), public)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if len(config.Templates()) != 1 || manifest.Constraints != 1 {
+				t.Errorf("got %d templates and %d constraints, want 1 and 1", len(config.Templates()), manifest.Constraints)
+			}
+
+			tampered := append([]byte{}, archive.Bytes()...)
+			tampered[len(tampered)/2] ^= 0xff
+			if _, _, err := ReadSignedBundleArchive(bytes.NewReader(tampered), signature, public); !errors.Is(err, ErrBundleSignature) {
+				t.Errorf("got error %v for a tampered archive, want %v", err, ErrBundleSignature)
+			}
+			for _, bad := range [][]byte{nil, []byte("not base64!"), []byte("AAAA")} {
+				if err := VerifyBundleSignature(public, archive.Bytes(), bad); !errors.Is(err, ErrBundleSignature) {
+					t.Errorf("got error %v for signature %q, want %v", err, bad, ErrBundleSignature)
+				}
+			}
+		})
+	}
+
+	// A signature by another key doesn't verify.
+	_, otherPublic := writeKeyPair(t, t.TempDir(), rsaKey)
+	public, err := LoadBundleVerificationKey(otherPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := SignBundleArchive(ecdsaKey, archive.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBundleSignature(public, archive.Bytes(), signature); !errors.Is(err, ErrBundleSignature) {
+		t.Errorf("got error %v for a signature by another key, want %v", err, ErrBundleSignature)
+	}
+}
+
+func TestLoadBundleVerificationKeyErrors(t *testing.T) {
+	dir := t.TempDir()
+	privatePath, _ := writeKeyPair(t, dir, ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	for name, path := range map[string]string{
+		"missing":     filepath.Join(dir, "missing.pub"),
+		"private key": privatePath,
+	} {
+		if _, err := LoadBundleVerificationKey(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Source is a backend that policy files are read from, such as a secret store or an embedded file
+// system.  Sources are registered for a URI scheme with RegisterSource, after which policy paths
+// with that scheme are read from them.
+type Source interface {
+	// Name returns the URI the source was created from.  The paths of the files read from the source
+	// are the name followed by the names returned by List.
+	Name() string
+	// List returns the names of the files in the source, relative to the source and separated by
+	// "/".
+	List(ctx context.Context) ([]string, error)
+	// Read returns the content of a file returned by List.
+	Read(ctx context.Context, name string) ([]byte, error)
+}
+
+// SourceFactory returns the Source for a URI with the scheme the factory is registered for.
+type SourceFactory func(uri string) (Source, error)
+
+var sources = struct {
+	sync.RWMutex
+	factories map[string]SourceFactory
+}{factories: map[string]SourceFactory{}}
+
+// RegisterSource registers the factory of the sources for URIs with the scheme, eg "vault" for
+// vault://secret/policies.  It is typically called from an init function.  RegisterSource panics if
+// the scheme is empty, is "gs", or is already registered.
+func RegisterSource(scheme string, factory SourceFactory) {
+	if scheme == "" || scheme == "gs" {
+		panic(fmt.Sprintf("configs: can't register source for reserved scheme %q", scheme))
+	}
+	sources.Lock()
+	defer sources.Unlock()
+	if _, found := sources.factories[scheme]; found {
+		panic(fmt.Sprintf("configs: source already registered for scheme %q", scheme))
+	}
+	sources.factories[scheme] = factory
+}
+
+// sourceFactory returns the registered factory for the scheme of path.
+func sourceFactory(path string) (SourceFactory, bool) {
+	if hasVolumeName(path) {
+		return nil, false
+	}
+	u, err := url.Parse(path)
+	if err != nil || u.Scheme == "" {
+		return nil, false
+	}
+	sources.RLock()
+	defer sources.RUnlock()
+	factory, ok := sources.factories[u.Scheme]
+	return factory, ok
+}
+
+// hasVolumeName returns true if path starts with a Windows drive letter, eg C:\policies or
+// C:/policies, or is a UNC path, eg \\server\share, which url.Parse would take for a URI with a
+// single letter scheme or fail to parse.  It doesn't depend on the OS, so that such paths are never
+// read from a source.
+func hasVolumeName(path string) bool {
+	if strings.HasPrefix(path, `\\`) {
+		return true
+	}
+	if len(path) < 2 || path[1] != ':' {
+		return false
+	}
+	letter := path[0] | 0x20
+	return 'a' <= letter && letter <= 'z' && (len(path) == 2 || path[2] == '\\' || path[2] == '/')
+}
+
+// isLocalPath returns true if NewPath reads path from the local file system.
+func isLocalPath(path string) bool {
+	if _, isSource := sourceFactory(path); isSource {
+		return false
+	}
+	return !strings.HasPrefix(path, "gs://")
+}
+
+// NewSourcePath returns a Path that reads the files of the source.
+func NewSourcePath(source Source) Path {
+	return &sourcePath{source: source}
+}
+
+// sourcePath adapts a Source to a Path.
+type sourcePath struct {
+	source Source
+}
+
+// ReadAll implements Path
+func (p *sourcePath) ReadAll(ctx context.Context, predicates ...readPredicate) ([]File, error) {
+	name := p.source.Name()
+	names, err := p.source.List(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list files in %s", name)
+	}
+	sort.Strings(names)
+	var files []File
+	for _, fileName := range names {
+		path := strings.TrimSuffix(name, "/") + "/" + fileName
+		if !matchesPredicates(path, predicates) {
+			continue
+		}
+		content, err := p.source.Read(ctx, fileName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", path)
+		}
+		files = append(files, File{Path: path, Content: content})
+	}
+	return files, nil
+}
+
+// NewFSSource returns a Source for the files in fsys, such as policies embedded in a binary with
+// go:embed.  Name is used as the source's URI.
+func NewFSSource(name string, fsys fs.FS) Source {
+	return &fsSource{name: name, fsys: fsys}
+}
+
+// NewFSPath returns a Path that reads the files in fsys, named name.
+func NewFSPath(name string, fsys fs.FS) Path {
+	return NewSourcePath(NewFSSource(name, fsys))
+}
+
+type fsSource struct {
+	name string
+	fsys fs.FS
+}
+
+// Name implements Source
+func (s *fsSource) Name() string {
+	return s.name
+}
+
+// List implements Source
+func (s *fsSource) List(ctx context.Context) ([]string, error) {
+	var names []string
+	err := fs.WalkDir(s.fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			names = append(names, path)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// Read implements Source
+func (s *fsSource) Read(ctx context.Context, name string) ([]byte, error) {
+	return fs.ReadFile(s.fsys, name)
+}
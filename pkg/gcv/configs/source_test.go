@@ -0,0 +1,128 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testSourceScheme = "configs-test"
+
+func init() {
+	RegisterSource(testSourceScheme, func(uri string) (Source, error) {
+		if strings.HasSuffix(uri, "/missing") {
+			return nil, errors.New("no such source")
+		}
+		template, err := os.ReadFile("../../../test/cf/templates/gcp_storage_logging_template.yaml")
+		if err != nil {
+			return nil, err
+		}
+		return NewFSSource(uri, fstest.MapFS{
+			"templates/gcp_storage_logging_template.yaml": {Data: template},
+			"README.md": {Data: []byte("not a policy")},
+		}), nil
+	})
+}
+
+func TestSourcePath(t *testing.T) {
+	p, err := NewPath(testSourceScheme + "://policies/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := p.ReadAll(context.Background(), SuffixPredicate(".yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	want := []string{testSourceScheme + "://policies/templates/gcp_storage_logging_template.yaml"}
+	if diff := cmp.Diff(want, paths); diff != "" {
+		t.Errorf("paths diff (-want +got):\n%s", diff)
+	}
+
+	if _, err := NewPath(testSourceScheme + "://policies/missing"); err == nil {
+		t.Errorf("expected error from source factory")
+	}
+}
+
+func TestNewConfigurationMixedSources(t *testing.T) {
+	config, err := NewConfiguration([]string{
+		testSourceScheme + "://policies",
+		"../../../test/cf/constraints/gcp_storage_logging_constraint.yaml",
+	}, "../../../test/cf/library")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(config.GCPTemplates) != 1 || len(config.GCPConstraints) != 1 {
+		t.Errorf("got %d templates and %d constraints, want 1 and 1", len(config.GCPTemplates), len(config.GCPConstraints))
+	}
+}
+
+func TestRegisterSourcePanics(t *testing.T) {
+	for _, scheme := range []string{"", "gs", testSourceScheme} {
+		t.Run(scheme, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterSource(%q) did not panic", scheme)
+				}
+			}()
+			RegisterSource(scheme, func(uri string) (Source, error) { return nil, nil })
+		})
+	}
+}
+
+func TestIsLocalPath(t *testing.T) {
+	for path, want := range map[string]bool{
+		"policies":                true,
+		"/a/b.yaml":               true,
+		"gs://bucket/policies":    false,
+		testSourceScheme + "://a": false,
+		"unregistered://a":        true,
+		`C:\policies`:             true,
+		"c:/policies":             true,
+		`\\server\share`:          true,
+	} {
+		if got := isLocalPath(path); got != want {
+			t.Errorf("isLocalPath(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestHasVolumeName(t *testing.T) {
+	for path, want := range map[string]bool{
+		`C:\policies\a.yaml`: true,
+		"d:/policies":        true,
+		"C:":                 true,
+		`\\server\share\a`:   true,
+		"policies":           false,
+		"/a/b.yaml":          false,
+		"gs://bucket/a":      false,
+		"ab:/policies":       false,
+		"1:/policies":        false,
+	} {
+		if got := hasVolumeName(path); got != want {
+			t.Errorf("hasVolumeName(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -131,6 +131,74 @@ var constraintDefinitions = map[string]spec.Schema{
 			},
 		},
 	},
+	// v1speccrd is speccrd with the fields added in templates.gatekeeper.sh/v1.
+	"v1speccrd": {
+		SchemaProps: spec.SchemaProps{
+			AdditionalProperties: &spec.SchemaOrBool{Allows: false},
+			Required:             []string{"spec"},
+			Properties: map[string]spec.Schema{
+				"spec": {
+					SchemaProps: spec.SchemaProps{
+						AdditionalProperties: &spec.SchemaOrBool{Allows: false},
+						Required:             []string{"names"},
+						Properties: map[string]spec.Schema{
+							"names": {
+								SchemaProps: spec.SchemaProps{
+									AdditionalProperties: &spec.SchemaOrBool{Allows: false},
+									Required:             []string{"kind"},
+									Properties: map[string]spec.Schema{
+										"kind":       *spec.StringProperty(),
+										"shortNames": *spec.ArrayProperty(spec.StringProperty()),
+									},
+								},
+							},
+							"validation": {
+								SchemaProps: spec.SchemaProps{
+									AdditionalProperties: &spec.SchemaOrBool{Allows: false},
+									Properties: map[string]spec.Schema{
+										"openAPIV3Schema": *refProperty("#/definitions/jsonschemaprops"),
+										"legacySchema":    *spec.BoolProperty(),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+	"v1spec": {
+		SchemaProps: spec.SchemaProps{
+			Type:                 objectType,
+			AdditionalProperties: &spec.SchemaOrBool{Allows: false},
+			Required:             []string{"crd", "targets"},
+			Properties: map[string]spec.Schema{
+				"crd": *refProperty("#/definitions/v1speccrd"),
+				"targets": *spec.ArrayProperty(&spec.Schema{
+					SchemaProps: spec.SchemaProps{
+						Type:                 objectType,
+						AdditionalProperties: &spec.SchemaOrBool{Allows: false},
+						// Templates for other engines only have code, the rego driver needs rego.
+						Required: []string{"target", "rego"},
+						Properties: map[string]spec.Schema{
+							"target": *spec.StringProperty(),
+							"rego":   *spec.StringProperty(),
+							"libs":   *spec.ArrayProperty(spec.StringProperty()),
+							"code": *spec.ArrayProperty(&spec.Schema{
+								SchemaProps: spec.SchemaProps{
+									Type:     objectType,
+									Required: []string{"engine", "source"},
+									Properties: map[string]spec.Schema{
+										"engine": *spec.StringProperty(),
+									},
+								},
+							}),
+						},
+					},
+				}),
+			},
+		},
+	},
 }
 
 // configValidatorV1Alpha1Schema is the legacy config validator schema for CF-like templates.  Note that there's
@@ -166,3 +234,21 @@ var configValidatorV1Beta1Schema = spec.Schema{
 
 var configValidatorV1Beta1SchemaValidator = validate.NewSchemaValidator(
 	&configValidatorV1Beta1Schema, nil, "", strfmt.Default)
+
+// configValidatorV1Schema is the schema of templates.gatekeeper.sh/v1 templates, the Gatekeeper
+// default, which add short names, legacySchema and code for other engines to v1beta1.
+var configValidatorV1Schema = spec.Schema{
+	SchemaProps: spec.SchemaProps{
+		Definitions:          mustMergeDefs(openAPISpecSchemaDefinitions, constraintDefinitions),
+		AdditionalProperties: &spec.SchemaOrBool{Allows: false},
+		Properties: map[string]spec.Schema{
+			"apiVersion": *spec.StringProperty(),
+			"kind":       *spec.StringProperty(),
+			"metadata":   *refProperty("#/definitions/metadata"),
+			"spec":       *refProperty("#/definitions/v1spec"),
+		},
+	},
+}
+
+var configValidatorV1SchemaValidator = validate.NewSchemaValidator(
+	&configValidatorV1Schema, nil, "", strfmt.Default)
@@ -0,0 +1,157 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	asset2 "github.com/GoogleCloudPlatform/config-validator/pkg/asset"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+)
+
+// ConstraintCoverage is how much of an asset corpus a constraint applies to, see Coverage.
+type ConstraintCoverage struct {
+	// Constraint is the constraint, as "[Kind].[Name]".
+	Constraint string
+	// Matched is the number of assets the constraint's match selected.
+	Matched int
+	// Evaluated is the number of matched assets the constraint was evaluated against without errors.
+	Evaluated int
+	// Violations is the number of violations of the constraint.
+	Violations int
+}
+
+// CoverageReport is the coverage of the loaded constraints over an asset corpus.
+type CoverageReport struct {
+	// Assets is the number of assets that were reviewed.
+	Assets int
+	// Errors are the assets that could not be read or reviewed, they are not counted in Assets.
+	Errors []error
+	// Constraints is the coverage of every loaded constraint, sorted by name.
+	Constraints []ConstraintCoverage
+}
+
+// Uncovered returns the constraints that matched no asset, most likely because their match is
+// narrower than intended, eg an ancestries glob that names the wrong folder.
+func (r *CoverageReport) Uncovered() []ConstraintCoverage {
+	var ret []ConstraintCoverage
+	for _, c := range r.Constraints {
+		if c.Matched == 0 {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// Coverage reviews the sample assets in the local or GCS paths and reports, for every loaded
+// constraint, how many of them it matched and was evaluated against.  Paths are files or directories
+// of JSON files, each holding an asset, an array of assets or newline delimited assets as in a CAI
+// export.  Assets that fail to parse or review are recorded in the report's Errors.
+func (v *Validator) Coverage(ctx context.Context, paths []string) (*CoverageReport, error) {
+	stats := NewAuditStats()
+	ctx = WithAuditStats(ctx, stats)
+	report := &CoverageReport{}
+	review := func(source string, asset map[string]interface{}) {
+		if _, err := v.ReviewUnmarshalledJSON(ctx, asset); err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("%s: %w", source, err))
+			return
+		}
+		report.Assets++
+	}
+
+	for _, path := range paths {
+		p, err := configs.NewPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %s: %w", path, err)
+		}
+		files, err := p.ReadAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			report.Errors = append(report.Errors, readCoverageAssets(file, review)...)
+		}
+	}
+
+	byName := map[string]ConstraintStats{}
+	for _, cs := range stats.Constraints() {
+		byName[cs.Constraint] = cs
+	}
+	v.mtx.RLock()
+	constraints := v.config.Constraints()
+	v.mtx.RUnlock()
+	for _, constraint := range constraints {
+		name := constraintName(constraint)
+		cs := byName[name]
+		report.Constraints = append(report.Constraints, ConstraintCoverage{
+			Constraint: name,
+			Matched:    cs.Assets,
+			Evaluated:  cs.Assets - cs.Errors,
+			Violations: cs.Violations,
+		})
+	}
+	sort.Slice(report.Constraints, func(i, j int) bool {
+		return report.Constraints[i].Constraint < report.Constraints[j].Constraint
+	})
+	return report, nil
+}
+
+// readCoverageAssets calls review for each asset in the file, which holds a JSON asset, a JSON array
+// of assets or newline delimited assets, and returns the errors reading them.
+func readCoverageAssets(file configs.File, review func(source string, asset map[string]interface{})) []error {
+	content := bytes.TrimSpace(file.Content)
+	if len(content) == 0 {
+		return nil
+	}
+	if content[0] == '[' {
+		var assets []map[string]interface{}
+		if err := json.Unmarshal(content, &assets); err != nil {
+			return []error{fmt.Errorf("%s: %w", file.Path, err)}
+		}
+		for idx, asset := range assets {
+			review(fmt.Sprintf("%s[%d]", file.Path, idx), asset)
+		}
+		return nil
+	}
+	asset := map[string]interface{}{}
+	if err := json.Unmarshal(content, &asset); err == nil {
+		review(file.Path, asset)
+		return nil
+	}
+
+	reader := &asset2.JSONLReader{MaxErrorRatio: 1}
+	idx := 0
+	report, err := reader.Read(file.Path, bytes.NewReader(content), func(asset map[string]interface{}) error {
+		idx++
+		review(fmt.Sprintf("%s[%d]", file.Path, idx), asset)
+		return nil
+	})
+	if err != nil {
+		return []error{err}
+	}
+	if len(report.Errors) == report.Lines {
+		// Files that are neither JSON nor JSONL, such as a README, have no valid lines.
+		return []error{fmt.Errorf("%s: not JSON or newline delimited JSON", file.Path)}
+	}
+	var errs []error
+	for _, lineErr := range report.Errors {
+		errs = append(errs, lineErr)
+	}
+	return errs
+}
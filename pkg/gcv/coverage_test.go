@@ -0,0 +1,72 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"bucket.json":  storageAssetNoLoggingJSON,
+		"buckets.json": "[" + storageAssetNoLoggingJSON + "," + storageAssetWithLoggingJSON + "]",
+		"README.md":    "Sample assets.\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := v.Coverage(context.Background(), []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Assets != 3 {
+		t.Errorf("got %d assets, want 3", report.Assets)
+	}
+	if len(report.Errors) != 1 {
+		t.Errorf("got errors %v, want one for the README", report.Errors)
+	}
+
+	byName := map[string]ConstraintCoverage{}
+	for _, c := range report.Constraints {
+		byName[c.Constraint] = c
+	}
+	got := byName["GCPStorageLoggingConstraint.require_storage_logging_XX"]
+	if got.Matched != 3 || got.Evaluated != 3 || got.Violations != 2 {
+		t.Errorf("got storage logging coverage %+v, want 3 matched and evaluated with 2 violations", got)
+	}
+	if len(report.Constraints) != len(v.config.Constraints()) {
+		t.Errorf("got %d constraints, want all %d loaded", len(report.Constraints), len(v.config.Constraints()))
+	}
+	uncovered := report.Uncovered()
+	if len(uncovered) == 0 {
+		t.Error("got no uncovered constraints, want those that don't match buckets")
+	}
+	for _, c := range uncovered {
+		if c.Matched != 0 {
+			t.Errorf("got uncovered constraint %+v with matches", c)
+		}
+	}
+}
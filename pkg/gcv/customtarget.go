@@ -0,0 +1,158 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/orgpolicytarget"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/tftarget"
+	cfclient "github.com/open-policy-agent/frameworks/constraint/pkg/client"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/handler"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Classifier selects the objects reviewed with a custom target registered with WithTarget.  It returns
+// the name the object's violations are reported under and true if the object is reviewed with the
+// target.
+type Classifier func(obj map[string]interface{}) (name string, ok bool)
+
+// customTarget is a target registered with WithTarget.
+type customTarget struct {
+	handler handler.TargetHandler
+	// classify is nil for built-in targets that are not offered objects, see reviewOrgPolicies.
+	classify Classifier
+	// client is the CF client for the target, nil until the Validator is created.
+	client *cfclient.Client
+}
+
+// name returns the name templates use to target the custom target.
+func (t *customTarget) name() string {
+	return t.handler.GetName()
+}
+
+// WithTarget registers a custom target, eg for the records of an internal CMDB, alongside the GCP, TF
+// and K8S targets.  Templates with targetHandler's name as a target are added to a CF client for the
+// target.  Objects reviewed with ReviewUnmarshalledJSON, ReviewJSON or ReviewStruct are offered to
+// the classifier of each custom target, in the order they were registered, before the built-in
+// targets, and are reviewed with the first target that accepts them.  Templates for targets that are
+// neither built in nor registered fail to load.
+func WithTarget(targetHandler handler.TargetHandler, classify Classifier) Option {
+	return func(o *initOptions) {
+		o.customTargets = append(o.customTargets, &customTarget{handler: targetHandler, classify: classify})
+	}
+}
+
+// targets returns the custom targets registered with WithTarget, and the org policy target if the
+// configuration has templates for it.  The org policy target reviews the org policies of GCP assets,
+// it is registered like a custom target so that its templates are loaded, listed and updated as
+// theirs are.  Without org policy templates in the configuration, none can be added with AddTemplate.
+func (o *initOptions) targets(config *configs.Configuration) []*customTarget {
+	if len(config.CustomTemplates[orgpolicytarget.Name]) == 0 {
+		return o.customTargets
+	}
+	targets := append([]*customTarget{}, o.customTargets...)
+	return append(targets, &customTarget{handler: orgpolicytarget.New()})
+}
+
+// checkCustomTargets returns an error if a custom target reuses the name of another target, or if the
+// configuration has templates for targets that are not registered.
+func checkCustomTargets(config *configs.Configuration, targets []*customTarget) error {
+	registered := map[string]bool{}
+	for _, tgt := range targets {
+		switch name := tgt.name(); {
+		case name == gcptarget.Name || name == tftarget.Name || name == configs.K8STargetName,
+			name == orgpolicytarget.Name && tgt.classify != nil:
+			return fmt.Errorf("custom target %s has the name of a built-in target", name)
+		case registered[name]:
+			return fmt.Errorf("custom target %s is registered more than once", name)
+		default:
+			registered[name] = true
+		}
+	}
+	for _, name := range config.CustomTargets() {
+		if !registered[name] {
+			var templates []string
+			for _, templ := range config.CustomTemplates[name] {
+				templates = append(templates, templ.Name)
+			}
+			return fmt.Errorf("templates %v have target %s which is not registered, see WithTarget", templates, name)
+		}
+	}
+	return nil
+}
+
+// copyCustomTargets returns a copy of the configuration with its own custom target maps, so that
+// templates and constraints added to the Validator don't modify the caller's configuration.
+func copyCustomTargets(config *configs.Configuration) *configs.Configuration {
+	c := *config
+	c.CustomTemplates = map[string][]*cftemplates.ConstraintTemplate{}
+	for name, templates := range config.CustomTemplates {
+		c.CustomTemplates[name] = templates
+	}
+	c.CustomConstraints = map[string][]*unstructured.Unstructured{}
+	for name, constraints := range config.CustomConstraints {
+		c.CustomConstraints[name] = constraints
+	}
+	return &c
+}
+
+// customTarget returns the custom target that accepts obj and the name of obj, or nil if none does.
+func (v *Validator) customTarget(obj map[string]interface{}) (*customTarget, string) {
+	for _, tgt := range v.customTargets {
+		if tgt.classify == nil {
+			continue
+		}
+		if name, ok := tgt.classify(obj); ok {
+			return tgt, name
+		}
+	}
+	return nil, ""
+}
+
+// reviewCustom reviews obj with a custom target.  v.mtx must be held.
+func (v *Validator) reviewCustom(ctx context.Context, tgt *customTarget, name string, obj map[string]interface{}) (*Result, error) {
+	responses, err := cfReview(ctx, tgt.client, tgt.name(), len(v.config.CustomConstraints[tgt.name()]), obj)
+	if err != nil {
+		return nil, fmt.Errorf("%s target Constraint Framework review call failed: %w", tgt.name(), err)
+	}
+	return NewResult(tgt.name(), name, obj, obj, responses)
+}
+
+// reviewOrgPolicies reviews each org policy of the GCP asset with the org policy target, see
+// orgpolicytarget.Reviews, and adds the violations to the asset's result.  v.mtx must be held.
+func (v *Validator) reviewOrgPolicies(ctx context.Context, asset map[string]interface{}, result *Result) error {
+	var tgt *customTarget
+	for _, custom := range v.customTargets {
+		if custom.name() == orgpolicytarget.Name {
+			tgt = custom
+		}
+	}
+	if tgt == nil || len(v.config.CustomConstraints[orgpolicytarget.Name]) == 0 {
+		return nil
+	}
+	for _, review := range orgpolicytarget.Reviews(asset) {
+		policyResult, err := v.reviewCustom(ctx, tgt, result.Name, review)
+		if err != nil {
+			return fmt.Errorf("org policy %s: %w", review[orgpolicytarget.ConstraintKey], err)
+		}
+		result.ConstraintViolations = append(result.ConstraintViolations, policyResult.ConstraintViolations...)
+	}
+	return nil
+}
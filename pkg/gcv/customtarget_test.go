@@ -0,0 +1,294 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/orgpolicytarget"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/tftarget"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/core/constraints"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/handler"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	"github.com/pkg/errors"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const cmdbTargetName = "validation.cmdb.example.com"
+
+// cmdbTarget is a custom target for CMDB records, it matches every record.
+type cmdbTarget struct {
+	name string
+}
+
+var _ handler.TargetHandler = &cmdbTarget{}
+
+func (t *cmdbTarget) GetName() string { return t.name }
+
+func (t *cmdbTarget) MatchSchema() apiextensions.JSONSchemaProps {
+	return apiextensions.JSONSchemaProps{Type: "object"}
+}
+
+func (t *cmdbTarget) ToMatcher(*unstructured.Unstructured) (constraints.Matcher, error) {
+	return cmdbMatcher{}, nil
+}
+
+func (t *cmdbTarget) ProcessData(interface{}) (bool, []string, interface{}, error) {
+	return false, nil, nil, errors.New("not supported")
+}
+
+func (t *cmdbTarget) HandleReview(obj interface{}) (bool, interface{}, error) {
+	record, ok := obj.(map[string]interface{})
+	if !ok {
+		return false, nil, nil
+	}
+	_, found := record["cmdb"]
+	return found, record, nil
+}
+
+func (t *cmdbTarget) HandleViolation(*types.Result) error { return nil }
+
+func (t *cmdbTarget) ValidateConstraint(*unstructured.Unstructured) error { return nil }
+
+type cmdbMatcher struct{}
+
+func (cmdbMatcher) Match(interface{}) (bool, error) { return true, nil }
+
+// classifyCMDB accepts CMDB records, named by their id.
+func classifyCMDB(obj map[string]interface{}) (string, bool) {
+	record, ok := obj["cmdb"].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	id, _ := record["id"].(string)
+	return "//cmdb.example.com/records/" + id, true
+}
+
+const cmdbOwnerTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: cmdbownerconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: CMDBOwnerConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: "object"
+  targets:
+    - target: "validation.cmdb.example.com"
+      rego: |
+        package templates.cmdb.CMDBOwnerConstraintV1
+
+        violation[{"msg": message}] {
+        	not input.review.cmdb.owner
+        	message := sprintf("record %v has no owner", [input.review.cmdb.id])
+        }
+`
+
+const cmdbOwnerConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: CMDBOwnerConstraintV1
+metadata:
+  name: require-owner
+`
+
+func cmdbPolicyFiles() []*configs.PolicyFile {
+	return []*configs.PolicyFile{
+		{Path: "cmdb_template.yaml", Content: []byte(cmdbOwnerTemplate)},
+		{Path: "cmdb_constraint.yaml", Content: []byte(cmdbOwnerConstraint)},
+	}
+}
+
+func TestCustomTarget(t *testing.T) {
+	cv, err := NewValidatorFromContents(cmdbPolicyFiles(), []string{"package validator.gcp.lib\n"},
+		WithTarget(&cmdbTarget{name: cmdbTargetName}, classifyCMDB))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	result, err := cv.ReviewUnmarshalledJSON(context.Background(), map[string]interface{}{
+		"cmdb": map[string]interface{}{"id": "host-1"},
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if result.Name != "//cmdb.example.com/records/host-1" {
+		t.Errorf("got name %q, want the classifier's name", result.Name)
+	}
+	if len(result.ConstraintViolations) != 1 {
+		t.Fatalf("got %d violations, want 1: %v", len(result.ConstraintViolations), result.ConstraintViolations)
+	}
+	if got, want := result.ConstraintViolations[0].Message, "record host-1 has no owner"; got != want {
+		t.Errorf("got message %q, want %q", got, want)
+	}
+
+	result, err = cv.ReviewUnmarshalledJSON(context.Background(), map[string]interface{}{
+		"cmdb": map[string]interface{}{"id": "host-2", "owner": "infra"},
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(result.ConstraintViolations) != 0 {
+		t.Errorf("got violations %v for an owned record, want none", result.ConstraintViolations)
+	}
+
+	constraints, err := cv.ListConstraints()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(constraints) != 1 || constraints[0].Target != cmdbTargetName {
+		t.Errorf("ListConstraints() = %v, want the custom target's constraint", constraints)
+	}
+
+	// Removing the constraint from the custom target stops the violations.
+	if err := cv.RemoveConstraint(context.Background(), "CMDBOwnerConstraintV1", "require-owner"); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	result, err = cv.ReviewUnmarshalledJSON(context.Background(), map[string]interface{}{
+		"cmdb": map[string]interface{}{"id": "host-1"},
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(result.ConstraintViolations) != 0 {
+		t.Errorf("got violations %v after removing the constraint, want none", result.ConstraintViolations)
+	}
+}
+
+func TestCustomTargetErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    []Option
+		wantErr string
+	}{
+		{
+			name:    "unregistered target",
+			wantErr: "not registered",
+		},
+		{
+			name:    "built-in name",
+			opts:    []Option{WithTarget(&cmdbTarget{name: tftarget.Name}, classifyCMDB)},
+			wantErr: "name of a built-in target",
+		},
+		{
+			name: "registered twice",
+			opts: []Option{
+				WithTarget(&cmdbTarget{name: cmdbTargetName}, classifyCMDB),
+				WithTarget(&cmdbTarget{name: cmdbTargetName}, classifyCMDB),
+			},
+			wantErr: "registered more than once",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewValidatorFromContents(cmdbPolicyFiles(), []string{"package validator.gcp.lib\n"}, tc.opts...)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+const serialPortTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: orgpolicyenforcedconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: OrgPolicyEnforcedConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: "object"
+  targets:
+    - target: "validation.orgpolicy.gcp.forsetisecurity.org"
+      rego: |
+        package templates.orgpolicy.OrgPolicyEnforcedConstraintV1
+
+        violation[{"msg": message}] {
+        	not input.review.policy.boolean_policy.enforced
+        	message := sprintf("%v is not enforced on %v", [input.review.constraint, input.review.name])
+        }
+`
+
+const serialPortConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: OrgPolicyEnforcedConstraintV1
+metadata:
+  name: serial-port-disabled
+spec:
+  match:
+    constraints: ["compute.disableSerialPortAccess"]
+`
+
+func TestReviewOrgPolicies(t *testing.T) {
+	v, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(serialPortTemplate)},
+		{Path: "constraint.yaml", Content: []byte(serialPortConstraint)},
+	}, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := v.ReviewJSON(context.Background(), `{
+  "name": "//cloudresourcemanager.googleapis.com/projects/3",
+  "asset_type": "cloudresourcemanager.googleapis.com/Project",
+  "ancestry_path": "organizations/1/projects/3",
+  "org_policy": [
+    {"constraint": "constraints/compute.disableSerialPortAccess", "boolean_policy": {}},
+    {"constraint": "constraints/compute.requireOsLogin", "boolean_policy": {}}
+  ]
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.ConstraintViolations) != 1 {
+		t.Fatalf("got violations %v, want 1", result.ConstraintViolations)
+	}
+	want := "constraints/compute.disableSerialPortAccess is not enforced on //cloudresourcemanager.googleapis.com/projects/3"
+	if got := result.ConstraintViolations[0].Message; got != want {
+		t.Errorf("got message %q, want %q", got, want)
+	}
+}
+
+func TestOrgPolicyTargetName(t *testing.T) {
+	_, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(serialPortTemplate)},
+		{Path: "constraint.yaml", Content: []byte(serialPortConstraint)},
+	}, []string{"package validator.gcp.lib\n"}, WithTarget(&cmdbTarget{name: orgpolicytarget.Name}, classifyCMDB))
+	if err == nil || !strings.Contains(err.Error(), "built-in target") {
+		t.Errorf("got error %v, want the org policy target to be built in", err)
+	}
+}
+
+func TestOrgPolicyTargetOnlyWithTemplates(t *testing.T) {
+	v, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(everyAssetTemplate)},
+	}, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.customTargets) != 0 {
+		t.Errorf("got targets %v without org policy templates, want none", v.customTargets)
+	}
+}
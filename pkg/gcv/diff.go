@@ -0,0 +1,161 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
+)
+
+// PolicyDiff is the change in the violations of a corpus of assets between two versions of the
+// policies, as returned by DiffPolicies.
+type PolicyDiff struct {
+	// Assets is the number of assets reviewed with both versions.
+	Assets int `json:"assets"`
+	// Introduced is the number of violations that only the new policies report.
+	Introduced int `json:"introduced"`
+	// Resolved is the number of violations that only the old policies report.
+	Resolved int `json:"resolved"`
+	// Constraints are the constraints whose violations changed, sorted by name.
+	Constraints []*ConstraintDiff `json:"constraints,omitempty"`
+}
+
+// ConstraintDiff is the change in the violations of a constraint.
+type ConstraintDiff struct {
+	// Constraint is the constraint, as "[Kind].[Name]".
+	Constraint string `json:"constraint"`
+	// OldViolations is the number of violations of the constraint with the old policies, zero if the
+	// constraint is new.
+	OldViolations int `json:"oldViolations"`
+	// NewViolations is the number of violations of the constraint with the new policies, zero if the
+	// constraint was removed.
+	NewViolations int `json:"newViolations"`
+	// Introduced are the violations that only the new policies report, sorted by resource.
+	Introduced []*DiffViolation `json:"introduced,omitempty"`
+	// Resolved are the violations that only the old policies report, sorted by resource.
+	Resolved []*DiffViolation `json:"resolved,omitempty"`
+}
+
+// DiffViolation is a violation that was introduced or resolved.
+type DiffViolation struct {
+	// Resource is the name of the violating resource.
+	Resource string `json:"resource"`
+	// Message is the message of the violation.
+	Message string `json:"message"`
+}
+
+// violationKey identifies a violation across policy versions.  A violation whose message changes is
+// reported as resolved and introduced, as the message usually carries the reason for it.
+type violationKey struct {
+	constraint string
+	resource   string
+	message    string
+}
+
+// DiffPolicies reviews the assets with the validators of the old and new versions of the policies,
+// eg before and after a policy library upgrade, and returns the violations that the upgrade
+// introduces and resolves, by constraint.  Constraints are told apart by kind and name, so a renamed
+// constraint shows all its violations as resolved under the old name and introduced under the new
+// one.  The assets are reviewed as with ReviewAssets, so their ancestry paths are set in place.
+// Assets that fail to be reviewed with either validator are left out of the diff and reported in
+// the error, the diff of the other assets is still returned.
+func DiffPolicies(ctx context.Context, oldValidator, newValidator *Validator, assets []*validator.Asset) (*PolicyDiff, error) {
+	var errs multierror.Errors
+	oldResults, err := oldValidator.ReviewAssets(ctx, assets)
+	if err != nil {
+		errs.Add(fmt.Errorf("old policies: %w", err))
+	}
+	newResults, err := newValidator.ReviewAssets(ctx, assets)
+	if err != nil {
+		errs.Add(fmt.Errorf("new policies: %w", err))
+	}
+
+	diff := &PolicyDiff{}
+	oldViolations := map[violationKey]int{}
+	newViolations := map[violationKey]int{}
+	for idx := range assets {
+		if oldResults[idx] == nil || newResults[idx] == nil {
+			continue
+		}
+		diff.Assets++
+		countViolations(oldResults[idx], oldViolations)
+		countViolations(newResults[idx], newViolations)
+	}
+
+	constraints := map[string]*ConstraintDiff{}
+	constraint := func(name string) *ConstraintDiff {
+		c, found := constraints[name]
+		if !found {
+			c = &ConstraintDiff{Constraint: name}
+			constraints[name] = c
+		}
+		return c
+	}
+	for key, count := range oldViolations {
+		constraint(key.constraint).OldViolations += count
+	}
+	for key, count := range newViolations {
+		c := constraint(key.constraint)
+		c.NewViolations += count
+		for i := oldViolations[key]; i < count; i++ {
+			c.Introduced = append(c.Introduced, &DiffViolation{Resource: key.resource, Message: key.message})
+		}
+	}
+	for key, count := range oldViolations {
+		c := constraint(key.constraint)
+		for i := newViolations[key]; i < count; i++ {
+			c.Resolved = append(c.Resolved, &DiffViolation{Resource: key.resource, Message: key.message})
+		}
+	}
+
+	for _, c := range constraints {
+		if len(c.Introduced) == 0 && len(c.Resolved) == 0 {
+			continue
+		}
+		sortDiffViolations(c.Introduced)
+		sortDiffViolations(c.Resolved)
+		diff.Introduced += len(c.Introduced)
+		diff.Resolved += len(c.Resolved)
+		diff.Constraints = append(diff.Constraints, c)
+	}
+	sort.Slice(diff.Constraints, func(i, j int) bool {
+		return diff.Constraints[i].Constraint < diff.Constraints[j].Constraint
+	})
+	return diff, errs.ToError()
+}
+
+// countViolations counts the violations of the result by key.
+func countViolations(result *Result, counts map[violationKey]int) {
+	for _, cv := range result.ConstraintViolations {
+		counts[violationKey{
+			constraint: constraintName(cv.Constraint),
+			resource:   result.Name,
+			message:    cv.Message,
+		}]++
+	}
+}
+
+func sortDiffViolations(violations []*DiffViolation) {
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Resource != violations[j].Resource {
+			return violations[i].Resource < violations[j].Resource
+		}
+		return violations[i].Message < violations[j].Message
+	})
+}
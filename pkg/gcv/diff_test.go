@@ -0,0 +1,106 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/google/go-cmp/cmp"
+)
+
+// deniedNamesValidator returns a validator with a GCPDeniedNamesConstraintV1 constraint for each
+// name, denying the buckets with the names.
+func deniedNamesValidator(t *testing.T, constraints map[string][]string) *Validator {
+	files := []*configs.PolicyFile{{Path: "template.yaml", Content: []byte(deniedNamesTemplate)}}
+	for name, names := range constraints {
+		content := fmt.Sprintf(`
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: GCPDeniedNamesConstraintV1
+metadata:
+  name: %s
+spec:
+  parameters:
+    names: ["//storage.googleapis.com/%s"]
+`, name, strings.Join(names, `", "//storage.googleapis.com/`))
+		files = append(files, &configs.PolicyFile{Path: name + ".yaml", Content: []byte(content)})
+	}
+	v, err := NewValidatorFromContents(files, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func bucketAsset(name string) *validator.Asset {
+	return mustMakeAsset(fmt.Sprintf(`{
+  "name": "//storage.googleapis.com/%s",
+  "asset_type": "storage.googleapis.com/Bucket",
+  "ancestry_path": "organizations/1/projects/3",
+  "resource": {"data": {}}
+}`, name))
+}
+
+func TestDiffPolicies(t *testing.T) {
+	oldValidator := deniedNamesValidator(t, map[string][]string{
+		"unchanged": {"a"},
+		"changed":   {"b", "c"},
+		"removed":   {"a"},
+	})
+	newValidator := deniedNamesValidator(t, map[string][]string{
+		"unchanged": {"a"},
+		"changed":   {"c", "d"},
+		"added":     {"d"},
+	})
+	invalid := bucketAsset("e")
+	invalid.Name = ""
+	assets := []*validator.Asset{bucketAsset("a"), bucketAsset("b"), bucketAsset("c"), bucketAsset("d"), invalid}
+
+	got, err := DiffPolicies(context.Background(), oldValidator, newValidator, assets)
+	if err == nil || !strings.Contains(err.Error(), "assets[4]") {
+		t.Errorf("got error %v, want error for assets[4]", err)
+	}
+	want := &PolicyDiff{
+		Assets:     4,
+		Introduced: 2,
+		Resolved:   2,
+		Constraints: []*ConstraintDiff{
+			{
+				Constraint:    "GCPDeniedNamesConstraintV1.added",
+				NewViolations: 1,
+				Introduced:    []*DiffViolation{{Resource: "//storage.googleapis.com/d", Message: "//storage.googleapis.com/d"}},
+			},
+			{
+				Constraint:    "GCPDeniedNamesConstraintV1.changed",
+				OldViolations: 2,
+				NewViolations: 2,
+				Introduced:    []*DiffViolation{{Resource: "//storage.googleapis.com/d", Message: "//storage.googleapis.com/d"}},
+				Resolved:      []*DiffViolation{{Resource: "//storage.googleapis.com/b", Message: "//storage.googleapis.com/b"}},
+			},
+			{
+				Constraint:    "GCPDeniedNamesConstraintV1.removed",
+				OldViolations: 1,
+				Resolved:      []*DiffViolation{{Resource: "//storage.googleapis.com/a", Message: "//storage.googleapis.com/a"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("policy diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// maxExpansionDepth bounds the expansion of generated resources that are generators themselves, as
+// in Gatekeeper.  Templates can't expand their own output, but two templates could form a cycle.
+const maxExpansionDepth = 30
+
+// K8SExpansion expands K8S resources with the Gatekeeper ExpansionTemplates in the configuration
+// before they are reviewed, so that workload policies written for Pods are enforced on the resources
+// that generate them, eg Deployments in a CAI export, as Gatekeeper does in the cluster.  Violations of
+// a generated resource are reported for the generator, with messages prefixed by "[Implied by
+// <template>]".  Without it ExpansionTemplates are ignored.
+func K8SExpansion() Option {
+	return func(o *initOptions) {
+		o.k8sExpansion = true
+	}
+}
+
+// expandedResource is a resource generated by expanding a K8S resource with a template.
+type expandedResource struct {
+	template *configs.ExpansionTemplate
+	object   *unstructured.Unstructured
+}
+
+// expand returns the resources generated by obj with the templates, and those they generate in turn.
+func expand(templates []*configs.ExpansionTemplate, obj *unstructured.Unstructured, depth int) ([]expandedResource, error) {
+	if depth >= maxExpansionDepth {
+		return nil, fmt.Errorf("maximum expansion depth of %d reached", maxExpansionDepth)
+	}
+	var expanded []expandedResource
+	for _, t := range templates {
+		if !t.AppliesTo(obj.GroupVersionKind()) {
+			continue
+		}
+		src, found, err := unstructured.NestedMap(obj.Object, t.SourcePath()...)
+		if err != nil {
+			return nil, fmt.Errorf("ExpansionTemplate %s: could not read source field %q: %w", t.Name, t.TemplateSource, err)
+		}
+		if !found {
+			return nil, fmt.Errorf("ExpansionTemplate %s: could not find source field %q in %s", t.Name, t.TemplateSource, obj.GetName())
+		}
+		generated := &unstructured.Unstructured{Object: src}
+		generated.SetGroupVersionKind(t.GeneratedGVK)
+		generated.SetNamespace(obj.GetNamespace())
+		// Generated resources are named <generator name>-<kind>, eg nginx-deployment-pod, as in
+		// Gatekeeper.
+		generated.SetName(strings.ToLower(obj.GetName() + "-" + t.GeneratedGVK.Kind))
+		expanded = append(expanded, expandedResource{template: t, object: generated})
+
+		children, err := expand(templates, generated, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, children...)
+	}
+	return expanded, nil
+}
+
+// expandedRequest returns a copy of the admission request for the generated resource.
+func expandedRequest(request *admissionv1.AdmissionRequest, obj *unstructured.Unstructured) (*admissionv1.AdmissionRequest, error) {
+	raw, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal expanded resource %s: %w", obj.GetName(), err)
+	}
+	gvk := obj.GroupVersionKind()
+	expanded := request.DeepCopy()
+	expanded.Kind = metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind}
+	expanded.Resource = metav1.GroupVersionResource{}
+	expanded.SubResource = ""
+	expanded.Name = obj.GetName()
+	expanded.Namespace = obj.GetNamespace()
+	expanded.Object = runtime.RawExtension{Raw: raw}
+	expanded.OldObject = runtime.RawExtension{}
+	return expanded, nil
+}
+
+// reviewExpanded reviews the resources generated by object with the K8S CF client and adds their
+// violations to responses.  The resources of DELETE requests are not expanded.
+func (v *Validator) reviewExpanded(ctx context.Context, object map[string]interface{}, request *admissionv1.AdmissionRequest, responses *cftypes.Responses) error {
+	if !v.k8sExpansion || len(v.config.ExpansionTemplates) == 0 || request.Operation == admissionv1.Delete {
+		return nil
+	}
+	obj := &unstructured.Unstructured{Object: runtime.DeepCopyJSON(object)}
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(request.Namespace)
+	}
+	expanded, err := expand(v.config.ExpansionTemplates, obj, 0)
+	if err != nil {
+		return err
+	}
+	target := responses.ByTarget[configs.K8STargetName]
+	for _, e := range expanded {
+		review, err := expandedRequest(request, e.object)
+		if err != nil {
+			return err
+		}
+		generated, err := cfReview(ctx, v.k8sCFClient, configs.K8STargetName, len(v.config.K8SConstraints), review)
+		if err != nil {
+			return fmt.Errorf("review of %s expanded by ExpansionTemplate %s failed: %w", e.object.GetName(), e.template.Name, err)
+		}
+		resp, found := generated.ByTarget[configs.K8STargetName]
+		if !found || target == nil {
+			continue
+		}
+		for _, result := range resp.Results {
+			result.Msg = fmt.Sprintf("[Implied by %s] %s", e.template.Name, result.Msg)
+			if action := e.template.EnforcementAction; action != "" && result.Constraint != nil {
+				result.Constraint = result.Constraint.DeepCopy()
+				if err := unstructured.SetNestedField(result.Constraint.Object, action, "spec", "enforcementAction"); err != nil {
+					return err
+				}
+				result.EnforcementAction = action
+			}
+			target.Results = append(target.Results, result)
+		}
+		responses.StatsEntries = append(responses.StatsEntries, generated.StatsEntries...)
+	}
+	return nil
+}
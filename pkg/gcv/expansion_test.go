@@ -0,0 +1,161 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// privilegedTemplate reports privileged containers.
+const privilegedTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: k8spspprivilegedcontainer
+spec:
+  crd:
+    spec:
+      names:
+        kind: K8sPSPPrivilegedContainer
+      validation:
+        openAPIV3Schema:
+          type: object
+  targets:
+    - target: admission.k8s.gatekeeper.sh
+      rego: |
+        package k8spspprivileged
+
+        violation[{"msg": msg}] {
+          c := input.review.object.spec.containers[_]
+          c.securityContext.privileged
+          msg := sprintf("Privileged container is not allowed: %v", [c.name])
+        }
+`
+
+// privilegedConstraint applies to Pods only.
+const privilegedConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: K8sPSPPrivilegedContainer
+metadata:
+  name: psp-privileged-container
+spec:
+  match:
+    kinds:
+      - apiGroups: [""]
+        kinds: ["Pod"]
+`
+
+const deploymentExpansion = `
+apiVersion: expansion.gatekeeper.sh/v1alpha1
+kind: ExpansionTemplate
+metadata:
+  name: expand-deployments
+spec:
+  applyTo:
+    - groups: ["apps"]
+      kinds: ["Deployment"]
+      versions: ["v1"]
+  templateSource: "spec.template"
+  enforcementAction: "warn"
+  generatedGVK:
+    kind: "Pod"
+    group: ""
+    version: "v1"
+`
+
+func privilegedDeploymentRequest(t *testing.T) *admissionv1.AdmissionRequest {
+	t.Helper()
+	raw, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "nginx-deployment", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "nginx"}},
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name":            "nginx",
+							"image":           "nginx",
+							"securityContext": map[string]interface{}{"privileged": true},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		Operation: admissionv1.Create,
+		Name:      "nginx-deployment",
+		Namespace: "default",
+		Object:    runtime.RawExtension{Raw: raw},
+	}
+}
+
+func TestK8SExpansion(t *testing.T) {
+	files := []*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(privilegedTemplate)},
+		{Path: "constraint.yaml", Content: []byte(privilegedConstraint)},
+		{Path: "expansion.yaml", Content: []byte(deploymentExpansion)},
+	}
+	testCases := []struct {
+		name           string
+		opts           []Option
+		wantViolations int
+	}{
+		{name: "disabled"},
+		{name: "enabled", opts: []Option{K8SExpansion()}, wantViolations: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := NewValidatorFromContents(files, []string{"package validator.gcp.lib\n"}, tc.opts...)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			result, err := v.ReviewAdmissionRequest(context.Background(), privilegedDeploymentRequest(t))
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if result.Name != "default/nginx-deployment" {
+				t.Errorf("got name %q, want the generator", result.Name)
+			}
+			if got := len(result.ConstraintViolations); got != tc.wantViolations {
+				t.Fatalf("got %d violations, want %d", got, tc.wantViolations)
+			}
+			if tc.wantViolations == 0 {
+				return
+			}
+			cv := result.ConstraintViolations[0]
+			if !strings.HasPrefix(cv.Message, "[Implied by expand-deployments] ") {
+				t.Errorf("got message %q, want it implied by the template", cv.Message)
+			}
+			if action, _ := cv.Constraint.Object["spec"].(map[string]interface{})["enforcementAction"].(string); action != "warn" {
+				t.Errorf("got enforcement action %q, want the template's", action)
+			}
+		})
+	}
+}
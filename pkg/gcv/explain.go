@@ -0,0 +1,124 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	asset2 "github.com/GoogleCloudPlatform/config-validator/pkg/asset"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ErrUnknownConstraint is returned by ExplainAsset for constraints that are not loaded.
+var ErrUnknownConstraint = errors.New("unknown constraint")
+
+// Explanation is how a constraint applies to an asset, see ExplainAsset.
+type Explanation struct {
+	// Constraint is the name of the constraint as "[Kind].[Name]".
+	Constraint string
+	// Match is the decision of the constraint's spec.match for the asset.
+	Match gcptarget.MatchDecision
+	// Violations are the violations of the constraint by the asset.
+	Violations []*validator.Violation
+	// Trace is the Rego evaluation trace of the review, empty if the constraint doesn't match the
+	// asset, since its template is then not evaluated.
+	Trace string
+}
+
+// ExplainAsset reviews a GCP asset with only the named constraint, with Rego tracing enabled, to help
+// policy authors find out why a constraint did or didn't fire on the asset.  The constraint is named
+// either as "[Kind].[Name]", as in violations, or by its name alone if that is unambiguous.  The asset
+// is normalized as for ReviewAsset.
+func (v *Validator) ExplainAsset(ctx context.Context, asset *validator.Asset, name string) (*Explanation, error) {
+	if err := asset2.SanitizeAncestryPath(asset); err != nil {
+		return nil, err
+	}
+	if err := asset2.ValidateAsset(asset); err != nil {
+		return nil, err
+	}
+	assetInterface, err := asset2.ConvertResourceViaJSONToInterface(asset)
+	if err != nil {
+		return nil, err
+	}
+	input := assetInterface.(map[string]interface{})
+
+	ctx = v.evaluationContext(v.runContext(ctx))
+	v.normalizeName(input)
+	if err := v.fixAncestry(input); err != nil {
+		return nil, err
+	}
+	if err := asset2.NormalizeAccessContextPolicy(input); err != nil {
+		return nil, err
+	}
+	if err := v.setTags(ctx, input); err != nil {
+		return nil, err
+	}
+
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+	constraint, err := v.findGCPConstraint(name)
+	if err != nil {
+		return nil, err
+	}
+	explanation := &Explanation{Constraint: constraintName(constraint)}
+	explanation.Match, err = gcptarget.ExplainMatch(constraint, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match %s: %w", explanation.Constraint, err)
+	}
+	if !explanation.Match.Matched {
+		return explanation, nil
+	}
+
+	ctx = withRegoTrace(WithPolicyOverlay(ctx, &PolicyOverlay{OnlyConstraints: []string{explanation.Constraint}}))
+	responses, _, err := v.gcpCFClients.review(ctx, gcptarget.Name, v.config.GCPConstraints, input)
+	if err != nil {
+		return nil, fmt.Errorf("GCP target Constraint Framework review call failed: %w", err)
+	}
+	result, err := NewResult(gcptarget.Name, input["name"].(string), input, input, responses)
+	if err != nil {
+		return nil, err
+	}
+	if explanation.Violations, err = result.ToViolations(); err != nil {
+		return nil, err
+	}
+	explanation.Trace = responses.TraceDump()
+	return explanation, nil
+}
+
+// findGCPConstraint returns the loaded GCP constraint named "[Kind].[Name]", or name alone if only
+// one constraint has it.
+func (v *Validator) findGCPConstraint(name string) (*unstructured.Unstructured, error) {
+	var found []*unstructured.Unstructured
+	for _, constraint := range v.config.GCPConstraints {
+		if constraintName(constraint) == name {
+			return constraint, nil
+		}
+		if originalName(constraint) == name {
+			found = append(found, constraint)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%w: no GCP constraint %s", ErrUnknownConstraint, name)
+	case 1:
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("constraint name %s is ambiguous, it is the name of %s and %s",
+		name, constraintName(found[0]), constraintName(found[1]))
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExplainAsset(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	ctx := context.Background()
+
+	explanation, err := v.ExplainAsset(ctx, storageAssetNoLogging(), "require_storage_logging_XX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Constraint != "GCPStorageLoggingConstraint.require_storage_logging_XX" {
+		t.Errorf("got constraint %s", explanation.Constraint)
+	}
+	if !explanation.Match.Matched {
+		t.Errorf("got match decision %+v, want matched", explanation.Match)
+	}
+	if len(explanation.Violations) != 1 {
+		t.Errorf("got violations %v, want one", explanation.Violations)
+	}
+	for _, violation := range explanation.Violations {
+		if violation.Constraint != explanation.Constraint {
+			t.Errorf("got violation of %s, want only %s", violation.Constraint, explanation.Constraint)
+		}
+	}
+	if !strings.Contains(explanation.Trace, "Enter") {
+		t.Errorf("got trace %q, want the rego evaluation trace", explanation.Trace)
+	}
+
+	_, err = v.ExplainAsset(ctx, storageAssetNoLogging(), "GCPStorageLoggingConstraint.missing")
+	if !errors.Is(err, ErrUnknownConstraint) {
+		t.Errorf("got error %v, want %v", err, ErrUnknownConstraint)
+	}
+}
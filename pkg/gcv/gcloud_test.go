@@ -0,0 +1,72 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// gcloudStorageAssetNoLogging is storageAssetNoLoggingJSON as printed by gcloud asset list --format=json.
+const gcloudStorageAssetNoLogging = `{
+  "name": "//storage.googleapis.com/my-storage-bucket",
+  "ancestors": ["projects/3", "folders/2", "organizations/1"],
+  "assetType": "storage.googleapis.com/Bucket",
+  "resource": {
+    "version": "v1",
+    "discoveryDocumentUri": "https://www.googleapis.com/discovery/v1/apis/storage/v1/rest",
+    "discoveryName": "Bucket",
+    "data": {"name": "my-storage-bucket", "logging": {}}
+  }
+}`
+
+// gcloudSearchResultNoLogging is a bucket without logging as printed by gcloud asset
+// search-all-resources --format=json.
+const gcloudSearchResultNoLogging = `{
+  "name": "//storage.googleapis.com/my-storage-bucket",
+  "assetType": "storage.googleapis.com/Bucket",
+  "project": "projects/3",
+  "folders": ["folders/2"],
+  "organization": "organizations/1",
+  "location": "us-central1"
+}`
+
+func TestReviewGcloudAssets(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	for name, data := range map[string]string{
+		"list":   gcloudStorageAssetNoLogging,
+		"search": gcloudSearchResultNoLogging,
+	} {
+		t.Run(name, func(t *testing.T) {
+			result, err := v.ReviewJSON(context.Background(), data)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			var logging bool
+			for _, violation := range result.ConstraintViolations {
+				if strings.Contains(violation.Message, "does not have the required logging destination") {
+					logging = true
+				}
+			}
+			if !logging {
+				t.Errorf("got violations %v, want a storage logging violation", result.ConstraintViolations)
+			}
+		})
+	}
+}
@@ -59,6 +59,12 @@ type Insight struct {
 	// Category for the insight, scanners may populate this member.
 	// One of: COST, SECURITY, PERFORMANCE, MANAGEABILITY
 	Category string `json:"category,omitempty"`
+
+	// Fingerprint identifies the violation the insight was generated for, see Fingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// Remediation is the guidance on how to fix the violation, see ConstraintViolation.Remediation.
+	Remediation string `json:"remediation,omitempty"`
 }
 
 // StateInfo is the state of the data.
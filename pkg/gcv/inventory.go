@@ -0,0 +1,205 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/tftarget"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ConstraintDescriptor describes a constraint loaded in the Validator.
+type ConstraintDescriptor struct {
+	// Kind is the kind of the constraint, this is the kind declared by its template.
+	Kind string
+	// Name is the name of the constraint as declared in its source file.
+	Name string
+	// Severity is the severity from spec.severity.
+	Severity string
+	// Target is the name of the target the constraint is evaluated against.
+	Target string
+	// Parameters are the parameters from spec.parameters.
+	Parameters map[string]interface{}
+	// SourcePath is the path of the file the constraint was loaded from.
+	SourcePath string
+}
+
+// TemplateDescriptor describes a constraint template loaded in the Validator.
+type TemplateDescriptor struct {
+	// Kind is the kind of constraint the template declares.
+	Kind string
+	// Name is the name of the template as declared in its source file.
+	Name string
+	// Targets are the names of the targets the template has rego for.
+	Targets []string
+	// ParametersSchema is the openAPIV3Schema for the constraint parameters in JSON form.
+	ParametersSchema map[string]interface{}
+	// SourcePath is the path of the file the template was loaded from.
+	SourcePath string
+}
+
+// ListConstraints returns the constraints loaded in the Validator, sorted by kind and name.
+func (v *Validator) ListConstraints() ([]*ConstraintDescriptor, error) {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+	var descriptors []*ConstraintDescriptor
+	add := func(target string, constraints []*unstructured.Unstructured) error {
+		for _, constraint := range constraints {
+			d, err := newConstraintDescriptor(target, constraint)
+			if err != nil {
+				return err
+			}
+			descriptors = append(descriptors, d)
+		}
+		return nil
+	}
+	if err := add(gcptarget.Name, v.config.GCPConstraints); err != nil {
+		return nil, err
+	}
+	if v.k8sCFClient != nil {
+		if err := add(configs.K8STargetName, v.config.K8SConstraints); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(tftarget.Name, v.config.TFConstraints); err != nil {
+		return nil, err
+	}
+	for _, tgt := range v.customTargets {
+		if err := add(tgt.name(), v.config.CustomConstraints[tgt.name()]); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(descriptors, func(i, j int) bool {
+		if descriptors[i].Kind != descriptors[j].Kind {
+			return descriptors[i].Kind < descriptors[j].Kind
+		}
+		return descriptors[i].Name < descriptors[j].Name
+	})
+	return descriptors, nil
+}
+
+// ListTemplates returns the constraint templates loaded in the Validator, sorted by kind.
+func (v *Validator) ListTemplates() ([]*TemplateDescriptor, error) {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+	templates := map[string]*cftemplates.ConstraintTemplate{}
+	for _, t := range v.config.GCPTemplates {
+		templates[t.Spec.CRD.Spec.Names.Kind] = t
+	}
+	if v.k8sCFClient != nil {
+		for _, t := range v.config.K8STemplates {
+			templates[t.Spec.CRD.Spec.Names.Kind] = t
+		}
+	}
+	for _, t := range v.config.TFTemplates {
+		templates[t.Spec.CRD.Spec.Names.Kind] = t
+	}
+	for _, tgt := range v.customTargets {
+		for _, t := range v.config.CustomTemplates[tgt.name()] {
+			templates[t.Spec.CRD.Spec.Names.Kind] = t
+		}
+	}
+
+	var descriptors []*TemplateDescriptor
+	for _, t := range templates {
+		d, err := newTemplateDescriptor(t)
+		if err != nil {
+			return nil, err
+		}
+		descriptors = append(descriptors, d)
+	}
+	sort.Slice(descriptors, func(i, j int) bool {
+		return descriptors[i].Kind < descriptors[j].Kind
+	})
+	return descriptors, nil
+}
+
+func newConstraintDescriptor(target string, constraint *unstructured.Unstructured) (*ConstraintDescriptor, error) {
+	severity, _, err := unstructured.NestedString(constraint.Object, "spec", "severity")
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec.severity in constraint %s: %w", constraint.GetName(), err)
+	}
+	params, _, err := unstructured.NestedMap(constraint.Object, "spec", "parameters")
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec.parameters in constraint %s: %w", constraint.GetName(), err)
+	}
+	return &ConstraintDescriptor{
+		Kind:       constraint.GetKind(),
+		Name:       originalName(constraint),
+		Severity:   severity,
+		Target:     target,
+		Parameters: params,
+		SourcePath: configs.SourcePath(constraint),
+	}, nil
+}
+
+func newTemplateDescriptor(t *cftemplates.ConstraintTemplate) (*TemplateDescriptor, error) {
+	d := &TemplateDescriptor{
+		Kind:       t.Spec.CRD.Spec.Names.Kind,
+		Name:       originalName(t),
+		SourcePath: configs.SourcePath(t),
+	}
+	for _, target := range t.Spec.Targets {
+		d.Targets = append(d.Targets, target.Target)
+	}
+	if t.Spec.CRD.Spec.Validation != nil && t.Spec.CRD.Spec.Validation.OpenAPIV3Schema != nil {
+		schemaJSON, err := json.Marshal(t.Spec.CRD.Spec.Validation.OpenAPIV3Schema)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal schema for template %s: %w", t.Name, err)
+		}
+		if err := json.Unmarshal(schemaJSON, &d.ParametersSchema); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal schema for template %s: %w", t.Name, err)
+		}
+	}
+	return d, nil
+}
+
+// ToProto converts the descriptor to its RPC representation.
+func (d *ConstraintDescriptor) ToProto() (*validator.ConstraintDescriptor, error) {
+	params, err := convertToProtoVal(d.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert parameters for constraint %s.%s: %w", d.Kind, d.Name, err)
+	}
+	return &validator.ConstraintDescriptor{
+		Kind:       d.Kind,
+		Name:       d.Name,
+		Severity:   d.Severity,
+		Target:     d.Target,
+		Parameters: params,
+		SourcePath: d.SourcePath,
+	}, nil
+}
+
+// ToProto converts the descriptor to its RPC representation.
+func (d *TemplateDescriptor) ToProto() (*validator.TemplateDescriptor, error) {
+	schema, err := convertToProtoVal(d.ParametersSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert parameters schema for template %s: %w", d.Kind, err)
+	}
+	return &validator.TemplateDescriptor{
+		Kind:             d.Kind,
+		Name:             d.Name,
+		Targets:          d.Targets,
+		ParametersSchema: schema,
+		SourcePath:       d.SourcePath,
+	}, nil
+}
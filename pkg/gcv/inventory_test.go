@@ -0,0 +1,95 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/tftarget"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListConstraints(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	constraints, err := v.ListConstraints()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	var got []string
+	byKind := map[string]*ConstraintDescriptor{}
+	for _, c := range constraints {
+		got = append(got, c.Kind+"."+c.Name+"@"+c.Target)
+		byKind[c.Kind] = c
+	}
+	want := []string{
+		"CFGCPStorageLoggingConstraint.require-storage-logging@" + gcptarget.Name,
+		"GCPStorageLoggingConstraint.require_storage_logging_XX@" + gcptarget.Name,
+		"K8sRequiredLabels.namespace-cost-center-label@" + configs.K8STargetName,
+		"TFComputeInstanceMachineTypeAllowlistConstraintV1.must-have-machine-type-e2-medium@" + tftarget.Name,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ListConstraints() diff (-want +got):\n%s", diff)
+	}
+
+	tf := byKind["TFComputeInstanceMachineTypeAllowlistConstraintV1"]
+	if tf.Severity != "medium" {
+		t.Errorf("got severity %q, want medium", tf.Severity)
+	}
+	if diff := cmp.Diff(map[string]interface{}{"allowlist": []interface{}{"e2-medium"}}, tf.Parameters); diff != "" {
+		t.Errorf("parameters diff (-want +got):\n%s", diff)
+	}
+	if want := testRoot + "/constraints/tf_compute_instance_mt_constraint.yaml"; tf.SourcePath != want {
+		t.Errorf("got source path %q, want %q", tf.SourcePath, want)
+	}
+	if _, err := tf.ToProto(); err != nil {
+		t.Errorf("ToProto: %v", err)
+	}
+}
+
+func TestListTemplates(t *testing.T) {
+	policyPaths, policyLibPath := testOptions()
+	v, err := NewValidator(policyPaths, policyLibPath, DisableK8STarget())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	templates, err := v.ListTemplates()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	for _, tmpl := range templates {
+		if tmpl.Kind == "K8sRequiredLabels" {
+			t.Errorf("K8S template listed with K8S target disabled")
+		}
+		if tmpl.SourcePath == "" {
+			t.Errorf("template %s missing source path", tmpl.Kind)
+		}
+		if len(tmpl.Targets) == 0 {
+			t.Errorf("template %s missing targets", tmpl.Kind)
+		}
+		if _, err := tmpl.ToProto(); err != nil {
+			t.Errorf("ToProto: %v", err)
+		}
+	}
+	if len(templates) == 0 {
+		t.Errorf("expected templates to be listed")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	asset2 "github.com/GoogleCloudPlatform/config-validator/pkg/asset"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
+	"github.com/ghodss/yaml"
+	"github.com/golang/glog"
+)
+
+// ReviewKRMManifests reviews the Config Connector resources in a multi-document YAML manifest, such as
+// a file of a GitOps repo, with the GCP constraints, see asset.ConvertKRMToCAI.  Documents that are
+// not Config Connector resources, or are of kinds without a CAI asset type, are skipped.  Documents
+// that fail to be reviewed are reported together in the error, the violations of the other documents
+// are still returned.
+func (v *Validator) ReviewKRMManifests(ctx context.Context, content []byte) ([]*validator.Violation, error) {
+	ctx = v.runContext(ctx)
+	var violations []*validator.Violation
+	var errs multierror.Errors
+	for idx, document := range strings.Split(string(content), "\n---") {
+		obj := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(document), &obj); err != nil {
+			errs.Add(fmt.Errorf("document %d: %w", idx, err))
+			continue
+		}
+		if !asset2.IsKRM(obj) {
+			continue
+		}
+		result, err := v.reviewKRM(ctx, obj)
+		if errors.Is(err, asset2.ErrUnsupportedKRMKind) {
+			glog.V(1).Infof("skipping document %d: %v", idx, err)
+			continue
+		}
+		if err == nil {
+			var docViolations []*validator.Violation
+			docViolations, err = result.ToViolations()
+			violations = append(violations, docViolations...)
+		}
+		if err != nil {
+			errs.Add(fmt.Errorf("document %d: %w", idx, err))
+		}
+	}
+	return violations, errs.ToError()
+}
+
+// reviewKRM reviews a Config Connector resource with the GCP target as the CAI asset of the resource it
+// manages.  The result's input resource is the Config Connector resource.
+func (v *Validator) reviewKRM(ctx context.Context, obj map[string]interface{}) (*Result, error) {
+	asset, err := asset2.ConvertKRMToCAI(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Config Connector resource: %w", err)
+	}
+	v.normalizeName(asset)
+	if err := v.fixAncestry(asset); err != nil {
+		return nil, err
+	}
+	ctx = v.evaluationContext(ctx)
+
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+	result, err := v.reviewGCPResource(ctx, asset)
+	if result != nil {
+		result.InputResource = obj
+	}
+	return result, err
+}
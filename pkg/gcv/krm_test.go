@@ -0,0 +1,136 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+)
+
+// uniformAccessTemplate requires buckets to have uniform bucket-level access.
+const uniformAccessTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpuniformaccessconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPUniformAccessConstraintV1
+  targets:
+    - target: "validation.gcp.forsetisecurity.org"
+      rego: |
+        package templates.gcp.GCPUniformAccessConstraintV1
+
+        violation[{"msg": message}] {
+        	asset := input.review
+        	asset.asset_type == "storage.googleapis.com/Bucket"
+        	not asset.resource.data.iamConfiguration.uniformBucketLevelAccess.enabled
+        	message := sprintf("%v in %v", [asset.name, asset.ancestry_path])
+        }
+`
+
+const uniformAccessConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: GCPUniformAccessConstraintV1
+metadata:
+  name: uniform-access
+spec:
+  severity: high
+  match:
+    ancestries: ["projects/**"]
+`
+
+const krmManifest = `
+apiVersion: storage.cnrm.cloud.google.com/v1beta1
+kind: StorageBucket
+metadata:
+  name: compliant
+  namespace: my-project
+spec:
+  uniformBucketLevelAccess: true
+---
+apiVersion: storage.cnrm.cloud.google.com/v1beta1
+kind: StorageBucket
+metadata:
+  name: legacy-acls
+  annotations:
+    cnrm.cloud.google.com/project-id: other-project
+spec:
+  location: us-east1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+---
+apiVersion: dns.cnrm.cloud.google.com/v1beta1
+kind: DNSManagedZone
+metadata:
+  name: unsupported
+  namespace: my-project
+`
+
+func TestReviewKRMManifests(t *testing.T) {
+	v, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(uniformAccessTemplate)},
+		{Path: "constraint.yaml", Content: []byte(uniformAccessConstraint)},
+	}, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	violations, err := v.ReviewKRMManifests(context.Background(), []byte(krmManifest))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(violations) != 1 {
+		t.Fatalf("got %d violations, want 1: %v", len(violations), violations)
+	}
+	if got, want := violations[0].Message, "//storage.googleapis.com/legacy-acls in projects/other-project"; got != want {
+		t.Errorf("got message %q, want %q", got, want)
+	}
+}
+
+func TestReviewUnmarshalledJSONKRM(t *testing.T) {
+	v, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(uniformAccessTemplate)},
+		{Path: "constraint.yaml", Content: []byte(uniformAccessConstraint)},
+	}, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	bucket := map[string]interface{}{
+		"apiVersion": "storage.cnrm.cloud.google.com/v1beta1",
+		"kind":       "StorageBucket",
+		"metadata":   map[string]interface{}{"name": "legacy-acls", "namespace": "my-project"},
+	}
+	result, err := v.ReviewUnmarshalledJSON(context.Background(), bucket)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if result.Name != "//storage.googleapis.com/legacy-acls" {
+		t.Errorf("got name %q, want the CAI asset name", result.Name)
+	}
+	if result.InputResource["kind"] != "StorageBucket" {
+		t.Errorf("got input resource %v, want the Config Connector resource", result.InputResource)
+	}
+	if len(result.ConstraintViolations) != 1 {
+		t.Errorf("got %d violations, want 1", len(result.ConstraintViolations))
+	}
+}
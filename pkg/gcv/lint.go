@@ -0,0 +1,193 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/tftarget"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/handler"
+	k8starget "github.com/open-policy-agent/gatekeeper/v3/pkg/target"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// PolicyReport is the result of validating a set of policies with ValidatePolicies.
+type PolicyReport struct {
+	// Issues are the errors and warnings found, sorted by path.
+	Issues []*configs.Issue
+}
+
+// HasErrors returns true if any of the issues are errors rather than warnings.
+func (r *PolicyReport) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if !issue.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// ByFile returns the issues grouped by the path of the file they were found in.
+func (r *PolicyReport) ByFile() map[string][]*configs.Issue {
+	byFile := map[string][]*configs.Issue{}
+	for _, issue := range r.Issues {
+		byFile[issue.Path] = append(byFile[issue.Path], issue)
+	}
+	return byFile
+}
+
+// ValidatePolicies loads and compiles the templates and constraints in policyPaths, including legacy
+// conversion and schema checks, and reports the problems found in each file.  No reviews are run, this
+// gives policy authors feedback on their changes without starting a server.  An error is only returned
+// if the policies can't be read.
+func ValidatePolicies(policyPaths []string, policyLibraryPath string, opts ...Option) (*PolicyReport, error) {
+	if len(policyPaths) == 0 {
+		return nil, fmt.Errorf("No policy path set")
+	}
+	policyPaths, libPaths := configs.ResolveBundles(policyPaths, policyLibraryPath)
+	if len(libPaths) == 0 {
+		return nil, fmt.Errorf("No policy library set")
+	}
+	files, err := configs.ReadPolicyFiles(policyPaths)
+	if err != nil {
+		return nil, err
+	}
+	lib, err := configs.LoadRegoLibraryFiles(libPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	config, issues := configs.LintFilesWithLibrary(files, lib)
+	if newInitOptions(opts...).requireOwner {
+		issues = append(issues, config.MissingOwners()...)
+	}
+	if newInitOptions(opts...).strictParameters {
+		issues = promoteIssues(issues, config.InvalidParameters())
+	}
+	// Templates calling disabled builtins are reported here rather than as compilation errors.
+	if builtinIssues := config.DisabledBuiltinUses(newInitOptions(opts...).disabledBuiltins); len(builtinIssues) != 0 {
+		skipped := map[string]bool{}
+		for _, issue := range builtinIssues {
+			issue.Warning = newInitOptions(opts...).skipDisabledBuiltins
+			skipped[issue.Name] = true
+		}
+		issues = append(issues, builtinIssues...)
+		config = config.WithoutTemplates(skipped)
+	}
+	if capabilities := newInitOptions(opts...).regoCapabilities; capabilities != nil {
+		issues = append(issues, config.UnsupportedFeatureUses(capabilities)...)
+	}
+	report := &PolicyReport{Issues: issues}
+	add := func(targetHandler handler.TargetHandler, templates []*cftemplates.ConstraintTemplate, constraints []*unstructured.Unstructured) error {
+		targetIssues, err := compileIssues(targetHandler, templates, constraints, opts...)
+		if err != nil {
+			return err
+		}
+		report.Issues = append(report.Issues, targetIssues...)
+		return nil
+	}
+	if err := add(gcptarget.New(), config.GCPTemplates, config.GCPConstraints); err != nil {
+		return nil, err
+	}
+	if !newInitOptions(opts...).disableK8STarget && len(config.K8STemplates) != 0 {
+		if err := add(&k8starget.K8sValidationTarget{}, config.K8STemplates, config.K8SConstraints); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(tftarget.New(), config.TFTemplates, config.TFConstraints); err != nil {
+		return nil, err
+	}
+	registered := map[string]bool{}
+	for _, tgt := range newInitOptions(opts...).targets(config) {
+		registered[tgt.name()] = true
+		if err := add(tgt.handler, config.CustomTemplates[tgt.name()], config.CustomConstraints[tgt.name()]); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range config.CustomTargets() {
+		if registered[name] {
+			continue
+		}
+		for _, template := range config.CustomTemplates[name] {
+			report.Issues = append(report.Issues, &configs.Issue{
+				Path:    configs.SourcePath(template),
+				Kind:    "ConstraintTemplate",
+				Name:    template.Name,
+				Message: fmt.Sprintf("target %s is not registered", name),
+			})
+		}
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return report.Issues[i].Path < report.Issues[j].Path
+	})
+	return report, nil
+}
+
+// compileIssues adds each template and constraint to a new CF client for the target and returns an issue
+// for each one that fails to compile or validate.
+func compileIssues(
+	targetHandler handler.TargetHandler,
+	templates []*cftemplates.ConstraintTemplate,
+	constraints []*unstructured.Unstructured,
+	opts ...Option) (
+	[]*configs.Issue, error) {
+
+	cfClient, err := newEmptyCFClient(targetHandler, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var issues []*configs.Issue
+	for _, template := range templates {
+		if _, err := cfClient.AddTemplate(ctx, template); err != nil {
+			issues = append(issues, &configs.Issue{
+				Path:    configs.SourcePath(template),
+				Kind:    "ConstraintTemplate",
+				Name:    template.Name,
+				Message: err.Error(),
+			})
+		}
+	}
+	for _, constraint := range constraints {
+		if _, err := cfClient.AddConstraint(ctx, constraint); err != nil {
+			issues = append(issues, configs.NewIssue(constraint, false, err.Error()))
+		}
+	}
+	return issues, nil
+}
+
+// promoteIssues replaces the warnings in issues that match one of the errors with the error.
+func promoteIssues(issues, errs []*configs.Issue) []*configs.Issue {
+	promoted := map[string]bool{}
+	for _, issue := range errs {
+		warning := *issue
+		warning.Warning = true
+		promoted[warning.String()] = true
+	}
+	var ret []*configs.Issue
+	for _, issue := range issues {
+		if !issue.Warning || !promoted[issue.String()] {
+			ret = append(ret, issue)
+		}
+	}
+	return append(ret, errs...)
+}
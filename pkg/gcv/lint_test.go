@@ -0,0 +1,116 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const lintTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: tfbrokenconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: TFBrokenConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: object
+  targets:
+    - target: validation.resourcechange.terraform.cloud.google.com
+      rego: |
+        package templates.terraform.TFBrokenConstraintV1
+
+        violation[{"msg": message}] {
+          message :=
+        }
+`
+
+const lintConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: TFMissingTemplateConstraintV1
+metadata:
+  name: missing-template
+spec:
+  severity: high
+`
+
+func TestValidatePolicies(t *testing.T) {
+	report, err := ValidatePolicies(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if report.HasErrors() {
+		t.Errorf("unexpected errors in test policies: %v", report.Issues)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"template.yaml":   lintTemplate,
+		"constraint.yaml": lintConstraint,
+		"garbage.yaml":    "apiVersion: [",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err = ValidatePolicies([]string{dir}, localPolicyDepDir)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !report.HasErrors() {
+		t.Fatalf("expected errors")
+	}
+	byFile := report.ByFile()
+	for name := range files {
+		path := filepath.Join(dir, name)
+		if len(byFile[path]) == 0 {
+			t.Errorf("expected issue for %s, got %v", name, report.Issues)
+		}
+	}
+}
+
+func TestValidatePoliciesDisabledBuiltins(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		opts := []Option{DisableBuiltins("http.send")}
+		if skip {
+			opts = append(opts, SkipDisabledBuiltins())
+		}
+		policyPaths, libPath := testOptions()
+		report, err := ValidatePolicies(policyPaths, libPath, opts...)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if report.HasErrors() == skip {
+			t.Errorf("got errors %v with SkipDisabledBuiltins %v: %v", report.HasErrors(), skip, report.Issues)
+		}
+		var found int
+		for _, issue := range report.Issues {
+			if issue.Name == "cfhttpsendv1" && issue.Message == "calls disabled builtin http.send" {
+				found++
+			}
+		}
+		if found != 1 {
+			t.Errorf("got %d issues for the template calling http.send with SkipDisabledBuiltins %v, want 1", found, skip)
+		}
+	}
+}
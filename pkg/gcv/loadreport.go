@@ -0,0 +1,164 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/golang/glog"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// LoadedObject identifies a template or constraint in a LoadReport.
+type LoadedObject struct {
+	// Kind is the kind of the object, ConstraintTemplate for templates.
+	Kind string
+	// Name is the name of the object.
+	Name string
+	// Path is the file the object was loaded from.
+	Path string
+	// Target is the target the object was added to, empty if it wasn't added to a target.
+	Target string
+	// Message is why the object was skipped or failed to load, or the warning.
+	Message string
+	// RegoErrors locate the errors in the rego of a template that failed to compile.
+	RegoErrors []*RegoError
+}
+
+func templateObject(templ *cftemplates.ConstraintTemplate, target, message string) *LoadedObject {
+	return &LoadedObject{
+		Kind:    "ConstraintTemplate",
+		Name:    templ.Name,
+		Path:    configs.SourcePath(templ),
+		Target:  target,
+		Message: message,
+	}
+}
+
+func constraintObject(constraint *unstructured.Unstructured, target, message string) *LoadedObject {
+	return &LoadedObject{
+		Kind:    constraint.GetKind(),
+		Name:    constraint.GetName(),
+		Path:    configs.SourcePath(constraint),
+		Target:  target,
+		Message: message,
+	}
+}
+
+func issueObject(issue *configs.Issue) *LoadedObject {
+	return &LoadedObject{Kind: issue.Kind, Name: issue.Name, Path: issue.Path, Message: issue.Message}
+}
+
+// ToProto converts the object to its proto representation.
+func (o *LoadedObject) ToProto() *validator.LoadedObject {
+	pb := &validator.LoadedObject{
+		Kind:       o.Kind,
+		Name:       o.Name,
+		SourcePath: o.Path,
+		Target:     o.Target,
+		Message:    o.Message,
+	}
+	for _, e := range o.RegoErrors {
+		pb.RegoErrors = append(pb.RegoErrors, e.ToProto())
+	}
+	return pb
+}
+
+// LoadReport describes the outcome of loading policies into a Validator, see Validator.LoadReport and
+// LoadError.
+type LoadReport struct {
+	// StartTime is when the load started.
+	StartTime time.Time
+	// ConfigDuration is how long reading and converting the policy files took, it is zero for
+	// Validators created from a configs.Configuration.
+	ConfigDuration time.Duration
+	// CompileDuration is how long adding the templates and constraints to the CF clients took.
+	CompileDuration time.Duration
+	// Loaded are the templates and constraints added to the CF clients, once per target.
+	Loaded []*LoadedObject
+	// Skipped are the templates and constraints that were not loaded, eg because the K8S target is
+	// disabled or a template calls a disabled builtin.
+	Skipped []*LoadedObject
+	// Errored are the templates and constraints that failed to load.
+	Errored []*LoadedObject
+	// Warnings are the non-fatal problems found in the configuration.
+	Warnings []*configs.Issue
+	// Err is the error that stopped the load, nil if the load succeeded.
+	Err error
+}
+
+// ToProto converts the report to its proto representation.
+func (r *LoadReport) ToProto() *validator.LoadReport {
+	pb := &validator.LoadReport{
+		StartTime:       timestamppb.New(r.StartTime),
+		ConfigDuration:  durationpb.New(r.ConfigDuration),
+		CompileDuration: durationpb.New(r.CompileDuration),
+	}
+	for _, o := range r.Loaded {
+		pb.Loaded = append(pb.Loaded, o.ToProto())
+	}
+	for _, o := range r.Skipped {
+		pb.Skipped = append(pb.Skipped, o.ToProto())
+	}
+	for _, o := range r.Errored {
+		pb.Errored = append(pb.Errored, o.ToProto())
+	}
+	for _, issue := range r.Warnings {
+		pb.Warnings = append(pb.Warnings, issueObject(issue).ToProto())
+	}
+	if r.Err != nil {
+		pb.Error = r.Err.Error()
+	}
+	return pb
+}
+
+// log writes the report to the log as a single JSON line.
+func (r *LoadReport) log() {
+	line, err := protojson.Marshal(r.ToProto())
+	if err != nil {
+		glog.Errorf("failed to marshal load report: %v", err)
+		return
+	}
+	glog.Infof("policy load report: %s", line)
+}
+
+// LoadError is returned when policies fail to load into a Validator, it holds the report of the
+// failed load.  The templates and constraints that failed are in Report.Errored, with the file they
+// were loaded from and, for templates that failed to compile, the location of each rego error.
+type LoadError struct {
+	Report *LoadReport
+}
+
+// Error implements error.
+func (e *LoadError) Error() string {
+	return e.Report.Err.Error()
+}
+
+// Unwrap returns the error that stopped the load.
+func (e *LoadError) Unwrap() error {
+	return e.Report.Err
+}
+
+// LoadReport returns the report of loading the Validator's policies.  Templates and constraints added
+// or removed since are not reflected.
+func (v *Validator) LoadReport() *LoadReport {
+	return v.loadReport
+}
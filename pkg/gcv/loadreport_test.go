@@ -0,0 +1,258 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+)
+
+// undefinedFunctionTemplate fails to compile as it calls a function that doesn't exist.
+const undefinedFunctionTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpundefinedfunctionconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPUndefinedFunctionConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: object
+  targets:
+    - target: "validation.gcp.forsetisecurity.org"
+      rego: |
+        package templates.gcp.GCPUndefinedFunctionConstraintV1
+
+        violation[{"msg": message}] {
+        	message := undefined_function(input.review.name)
+        }
+`
+
+func findObject(objects []*LoadedObject, name string) *LoadedObject {
+	for _, o := range objects {
+		if o.Name == name {
+			return o
+		}
+	}
+	return nil
+}
+
+func TestLoadReport(t *testing.T) {
+	policyPaths, libPath := testOptions()
+	v, err := NewValidator(policyPaths, libPath, DisableBuiltins("http.send"), SkipDisabledBuiltins())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	report := v.LoadReport()
+	if report.Err != nil || len(report.Errored) != 0 {
+		t.Errorf("got error %v and errored objects %v, want none", report.Err, report.Errored)
+	}
+	if report.StartTime.IsZero() || report.ConfigDuration <= 0 || report.CompileDuration <= 0 {
+		t.Errorf("got start time %v, durations %v and %v, want them set", report.StartTime, report.ConfigDuration, report.CompileDuration)
+	}
+	loaded := findObject(report.Loaded, "cfgcpstorageloggingconstraint")
+	if loaded == nil || loaded.Kind != "ConstraintTemplate" || loaded.Target != gcptarget.Name || loaded.Path == "" {
+		t.Errorf("got loaded template %+v, want GCP template with a path", loaded)
+	}
+	if skipped := findObject(report.Skipped, "cfhttpsendv1"); skipped == nil || skipped.Message != "calls a disabled builtin" {
+		t.Errorf("got skipped template %+v, want template calling http.send", skipped)
+	}
+	if len(report.Warnings) != len(v.config.Warnings) {
+		t.Errorf("got %d warnings, want %d", len(report.Warnings), len(v.config.Warnings))
+	}
+	pb := report.ToProto()
+	if len(pb.Loaded) != len(report.Loaded) || len(pb.Skipped) != len(report.Skipped) || pb.Error != "" {
+		t.Errorf("proto %v does not match report", pb)
+	}
+
+	_, err = NewValidator(policyPaths, libPath, DisableBuiltins("http.send"))
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("got error %v, want *LoadError", err)
+	}
+	if errored := findObject(loadErr.Report.Errored, "cfhttpsendv1"); errored == nil {
+		t.Errorf("template calling http.send not reported as errored: %v", loadErr.Report.Errored)
+	}
+	if loadErr.Report.ToProto().Error == "" {
+		t.Errorf("load error not in report proto")
+	}
+}
+
+func TestLoadReportCompileError(t *testing.T) {
+	_, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(undefinedFunctionTemplate)},
+		{Path: "every_template.yaml", Content: []byte(everyAssetTemplate)},
+	}, []string{"package validator.gcp.lib\n"})
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("got error %v, want *LoadError", err)
+	}
+	errored := findObject(loadErr.Report.Errored, "gcpundefinedfunctionconstraintv1")
+	if errored == nil || errored.Target != gcptarget.Name || errored.Path != "template.yaml" || errored.Message == "" {
+		t.Errorf("got errored template %+v, want compile error", errored)
+	}
+	if findObject(loadErr.Report.Loaded, "gcpeveryassetconstraintv1") == nil {
+		t.Errorf("template that compiled not reported as loaded: %v", loadErr.Report.Loaded)
+	}
+	if errored == nil || len(errored.RegoErrors) != 1 {
+		t.Fatalf("got rego errors %v, want 1", errored)
+	}
+	if got := errored.RegoErrors[0]; got.Module != "template" || got.Row != 4 || got.Col == 0 || got.Code != "rego_type_error" {
+		t.Errorf("got rego error %v, want the type error at template:4 with its column", got)
+	}
+	if !strings.Contains(err.Error(), "template.yaml") {
+		t.Errorf("got error %v, want the template's path", err)
+	}
+	if pb := loadErr.Report.ToProto(); len(pb.Errored) != 1 || len(pb.Errored[0].RegoErrors) != 1 {
+		t.Errorf("rego errors not in report proto: %v", pb.Errored)
+	}
+}
+
+// undefinedLibFunctionTemplate fails to compile as its library calls a function that doesn't exist.
+const undefinedLibFunctionTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: tfundefinedlibfunctionconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: TFUndefinedLibFunctionConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: object
+  targets:
+    - target: "validation.resourcechange.terraform.cloud.google.com"
+      rego: |
+        package templates.tf.TFUndefinedLibFunctionConstraintV1
+
+        import data.lib.broken
+
+        violation[{"msg": message}] {
+        	message := broken.name(input.review)
+        }
+      libs:
+        - |
+          package lib.broken
+
+          name(review) = n {
+          	n := undefined_function(review.name)
+          }
+`
+
+func TestLoadReportCompileErrorsAcrossTargets(t *testing.T) {
+	_, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "gcp.yaml", Content: []byte(undefinedFunctionTemplate)},
+		{Path: "tf.yaml", Content: []byte(undefinedLibFunctionTemplate)},
+	}, []string{"package validator.gcp.lib\n"})
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("got error %v, want *LoadError", err)
+	}
+	for _, path := range []string{"gcp.yaml", "tf.yaml"} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("got error %v, want the failure of %s", err, path)
+		}
+	}
+	errored := findObject(loadErr.Report.Errored, "tfundefinedlibfunctionconstraintv1")
+	if errored == nil || len(errored.RegoErrors) != 1 {
+		t.Fatalf("got errored template %+v, want 1 rego error", errored)
+	}
+	if got := errored.RegoErrors[0]; got.Module != "lib_0" || got.Row != 4 || got.Code != "rego_type_error" {
+		t.Errorf("got rego error %v, want the type error at lib_0:4", got)
+	}
+}
+
+func TestLenientLoad(t *testing.T) {
+	policyDir, libDir := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		"undefined_template.yaml": undefinedFunctionTemplate,
+		"undefined_constraint.yaml": `
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: GCPUndefinedFunctionConstraintV1
+metadata:
+  name: undefined
+`,
+		"every_template.yaml":   everyAssetTemplate,
+		"every_constraint.yaml": everyAssetConstraint,
+		"orphan_constraint.yaml": `
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: GCPMissingTemplateConstraintV1
+metadata:
+  name: orphan
+`,
+		"broken.yaml": "kind: [",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(policyDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(libDir, "lib.rego"), []byte("package validator.gcp.lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewValidator([]string{policyDir}, libDir); err == nil {
+		t.Fatal("expected error loading broken policies without WithLenientLoad")
+	}
+	v, err := NewValidator([]string{policyDir}, libDir, WithLenientLoad())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	constraints, err := v.ListConstraints()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(constraints) != 1 || constraints[0].Name != "every-asset" {
+		t.Errorf("got constraints %v, want only every-asset", constraints)
+	}
+	report := v.LoadReport()
+	for _, name := range []string{"gcpundefinedfunctionconstraintv1", "undefined"} {
+		if findObject(report.Skipped, name) == nil {
+			t.Errorf("%s not reported as skipped: %v", name, report.Skipped)
+		}
+	}
+	var warned []string
+	for _, issue := range report.Warnings {
+		if !issue.Warning {
+			t.Errorf("got error issue %v, want warnings", issue)
+		}
+		warned = append(warned, issue.String())
+	}
+	for _, want := range []string{"broken.yaml", "orphan", "gcpundefinedfunctionconstraintv1"} {
+		if !strings.Contains(strings.Join(warned, "\n"), want) {
+			t.Errorf("got warnings %v, want one for %s", warned, want)
+		}
+	}
+
+	violations, err := v.ReviewAsset(context.Background(), storageAssetNoLogging())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(violations) != 1 {
+		t.Errorf("got %d violations, want 1 from the loaded constraint", len(violations))
+	}
+}
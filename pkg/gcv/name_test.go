@@ -0,0 +1,123 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+)
+
+// everyAssetTemplate reports every asset it matches by name.
+const everyAssetTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpeveryassetconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPEveryAssetConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: "object"
+          properties: {}
+  targets:
+    - target: "validation.gcp.forsetisecurity.org"
+      rego: |
+        package templates.gcp.GCPEveryAssetConstraintV1
+
+        violation[{"msg": message}] {
+        	message := input.review.name
+        }
+`
+
+// everyAssetConstraint excludes the project with number 123.
+const everyAssetConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPEveryAssetConstraintV1
+metadata:
+  name: every-asset
+spec:
+  severity: high
+  match:
+    ancestries: ["**"]
+    excludedAncestries: ["organizations/1/projects/123"]
+  parameters: {}
+`
+
+func TestReviewNormalizesNames(t *testing.T) {
+	ctx := context.Background()
+	v, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(everyAssetTemplate)},
+		{Path: "constraint.yaml", Content: []byte(everyAssetConstraint)},
+	}, []string{"package validator.gcp.lib\n"}, ProjectNumbers(map[string]string{"my-project": "123", "other-project": "456"}))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	testCases := []struct {
+		name      string
+		assetJSON string
+		wantName  string
+		// wantViolation is false if the asset is excluded.
+		wantViolation bool
+	}{
+		{
+			name: "excluded by project ID",
+			assetJSON: `{
+  "name": "https://www.googleapis.com/compute/v1/projects/my-project/zones/us-central1-a/instances/i",
+  "asset_type": "compute.googleapis.com/Instance",
+  "ancestors": ["projects/my-project", "organizations/1"],
+  "resource": {"data": {}}
+}`,
+			wantName: "//compute.googleapis.com/projects/123/zones/us-central1-a/instances/i",
+		},
+		{
+			name: "relative name",
+			assetJSON: `{
+  "name": "projects/Other-Project/datasets/d",
+  "asset_type": "bigquery.googleapis.com/Dataset",
+  "ancestry_path": "organization/1/project/other-project",
+  "resource": {"data": {}}
+}`,
+			wantName:      "//bigquery.googleapis.com/projects/456/datasets/d",
+			wantViolation: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := v.ReviewJSON(ctx, tc.assetJSON)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if result.Name != tc.wantName {
+				t.Errorf("got name %q, want %q", result.Name, tc.wantName)
+			}
+			violations, err := result.ToViolations()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotViolation := len(violations) != 0; gotViolation != tc.wantViolation {
+				t.Fatalf("got violations %v, want violation %v", violations, tc.wantViolation)
+			}
+			if tc.wantViolation && (violations[0].Resource != tc.wantName || violations[0].Message != tc.wantName) {
+				t.Errorf("got violation %v for %q", violations[0], tc.wantName)
+			}
+		})
+	}
+}
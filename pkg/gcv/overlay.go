@@ -0,0 +1,121 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+)
+
+// ErrInvalidPolicyOverlay is returned for policy overlays that list constraints that are not loaded.
+var ErrInvalidPolicyOverlay = errors.New("invalid policy overlay")
+
+// PolicyOverlay selects the constraints evaluated for a single review, so that one Validator can run
+// different policy subsets for different pipelines.  Constraints are named as "[Kind].[Name]", as in
+// ConstraintViolation.
+type PolicyOverlay struct {
+	// OnlyConstraints, if not empty, are the only constraints that are evaluated.
+	OnlyConstraints []string
+	// SkipConstraints are not evaluated, also if they are in OnlyConstraints.
+	SkipConstraints []string
+}
+
+// PolicyOverlayFromProto converts the policy overlay of a review request, nil if it has none.
+func PolicyOverlayFromProto(overlay *validator.PolicyOverlay) *PolicyOverlay {
+	if len(overlay.GetOnlyConstraints()) == 0 && len(overlay.GetSkipConstraints()) == 0 {
+		return nil
+	}
+	return &PolicyOverlay{
+		OnlyConstraints: overlay.GetOnlyConstraints(),
+		SkipConstraints: overlay.GetSkipConstraints(),
+	}
+}
+
+// policyOverlay is a PolicyOverlay indexed by constraint name.
+type policyOverlay struct {
+	only map[string]bool
+	skip map[string]bool
+}
+
+// includes returns true if the named constraint is evaluated.
+func (o *policyOverlay) includes(name string) bool {
+	if o.skip[name] {
+		return false
+	}
+	return len(o.only) == 0 || o.only[name]
+}
+
+type policyOverlayContextKey struct{}
+
+// WithPolicyOverlay returns a copy of ctx which requests that reviews with it only evaluate the
+// constraints selected by the overlay.  Skipped constraints are not matched against the resource, so
+// they are neither in the violations nor in Result.Evaluated.  Use CheckPolicyOverlay to reject
+// overlays of unknown constraints, which are ignored.
+func WithPolicyOverlay(ctx context.Context, overlay *PolicyOverlay) context.Context {
+	if overlay == nil || (len(overlay.OnlyConstraints) == 0 && len(overlay.SkipConstraints) == 0) {
+		return ctx
+	}
+	o := &policyOverlay{}
+	if len(overlay.OnlyConstraints) != 0 {
+		o.only = map[string]bool{}
+		for _, name := range overlay.OnlyConstraints {
+			o.only[name] = true
+		}
+	}
+	if len(overlay.SkipConstraints) != 0 {
+		o.skip = map[string]bool{}
+		for _, name := range overlay.SkipConstraints {
+			o.skip[name] = true
+		}
+	}
+	return context.WithValue(ctx, policyOverlayContextKey{}, o)
+}
+
+// policyOverlayFrom returns the overlay set by WithPolicyOverlay, nil if none.
+func policyOverlayFrom(ctx context.Context) *policyOverlay {
+	overlay, _ := ctx.Value(policyOverlayContextKey{}).(*policyOverlay)
+	return overlay
+}
+
+// CheckPolicyOverlay returns an error wrapping ErrInvalidPolicyOverlay if the overlay lists a
+// constraint that isn't loaded, most likely a typo that would otherwise skip every constraint.
+func (v *Validator) CheckPolicyOverlay(overlay *PolicyOverlay) error {
+	if overlay == nil {
+		return nil
+	}
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	loaded := map[string]bool{}
+	for _, constraint := range v.config.Constraints() {
+		loaded[constraintName(constraint)] = true
+	}
+	var unknown []string
+	for _, names := range [][]string{overlay.OnlyConstraints, overlay.SkipConstraints} {
+		for _, name := range names {
+			if !loaded[name] {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+	if len(unknown) != 0 {
+		return fmt.Errorf("%w: constraints %s are not loaded", ErrInvalidPolicyOverlay, strings.Join(unknown, ", "))
+	}
+	return nil
+}
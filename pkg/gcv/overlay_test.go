@@ -0,0 +1,111 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+)
+
+func TestReviewPolicyOverlay(t *testing.T) {
+	const (
+		cfLogging = "CFGCPStorageLoggingConstraint.require-storage-logging"
+		logging   = "GCPStorageLoggingConstraint.require_storage_logging_XX"
+	)
+	policyPaths, policyLibPath := testOptions()
+	cv, err := NewValidator(policyPaths, policyLibPath)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	v := NewParallelValidator(stopChannel, cv)
+
+	testCases := []struct {
+		name    string
+		overlay *validator.PolicyOverlay
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "no overlay",
+			want: []string{cfLogging, logging},
+		},
+		{
+			name:    "only",
+			overlay: &validator.PolicyOverlay{OnlyConstraints: []string{logging}},
+			want:    []string{logging},
+		},
+		{
+			name:    "skip",
+			overlay: &validator.PolicyOverlay{SkipConstraints: []string{logging}},
+			want:    []string{cfLogging},
+		},
+		{
+			name:    "skip wins over only",
+			overlay: &validator.PolicyOverlay{OnlyConstraints: []string{cfLogging, logging}, SkipConstraints: []string{cfLogging}},
+			want:    []string{logging},
+		},
+		{
+			name:    "unknown constraint",
+			overlay: &validator.PolicyOverlay{SkipConstraints: []string{"GCPStorageLoggingConstraint.missing"}},
+			wantErr: ErrInvalidPolicyOverlay,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := v.Review(context.Background(), &validator.ReviewRequest{
+				Assets:  []*validator.Asset{storageAssetNoLogging()},
+				Overlay: tc.overlay,
+			})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr != nil {
+				if code := ReviewStatus(err).Code(); code != codes.InvalidArgument {
+					t.Errorf("got code %v, want %v", code, codes.InvalidArgument)
+				}
+				return
+			}
+			var got []string
+			for _, v := range resp.Violations {
+				got = append(got, v.Constraint)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("violations diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPolicyOverlayEvaluated(t *testing.T) {
+	v := newShardedValidator(t, 2)
+	ctx := RecordEvaluated(WithPolicyOverlay(context.Background(), &PolicyOverlay{
+		OnlyConstraints: []string{"GCPStorageLoggingConstraint.require_storage_logging_XX"},
+	}))
+	result, err := v.ReviewJSON(ctx, storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	want := []string{"GCPStorageLoggingConstraint.require_storage_logging_XX"}
+	if diff := cmp.Diff(want, result.Evaluated); diff != "" {
+		t.Errorf("evaluated diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
+	"github.com/golang/glog"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/client/drivers"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ErrInvalidParameterOverrides is returned for parameter overrides of constraints that are not loaded or
+// with parameters that don't match the template's schema.
+var ErrInvalidParameterOverrides = errors.New("invalid parameter overrides")
+
+// ParameterOverride replaces parameters of a constraint for a single review.
+type ParameterOverride struct {
+	// Kind is the kind of the constraint.
+	Kind string
+	// Name is the name of the constraint as it was declared.
+	Name string
+	// Parameters replace the constraint's parameters with the same names, the other parameters of the
+	// constraint keep their values.
+	Parameters map[string]interface{}
+}
+
+// key returns the name of the overridden constraint as reported in violations.
+func (o *ParameterOverride) key() string {
+	return o.Kind + "." + o.Name
+}
+
+// apply returns a copy of the constraint with the overridden parameters.
+func (o *ParameterOverride) apply(constraint *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	clone := constraint.DeepCopy()
+	parameters, _, err := unstructured.NestedMap(clone.Object, "spec", "parameters")
+	if err != nil {
+		return nil, err
+	}
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+	for name, value := range o.Parameters {
+		parameters[name] = runtime.DeepCopyJSONValue(value)
+	}
+	if err := unstructured.SetNestedMap(clone.Object, parameters, "spec", "parameters"); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// ParameterOverridesFromProto converts the parameter overrides of a review request.
+func ParameterOverridesFromProto(overrides []*validator.ParameterOverride) []*ParameterOverride {
+	var ret []*ParameterOverride
+	for _, override := range overrides {
+		ret = append(ret, &ParameterOverride{
+			Kind:       override.GetKind(),
+			Name:       override.GetName(),
+			Parameters: override.GetParameters().AsMap(),
+		})
+	}
+	return ret
+}
+
+type parameterOverridesContextKey struct{}
+
+// WithParameterOverrides returns a copy of ctx which requests that the constraints are reviewed with
+// the overridden parameters.  Each overridden constraint is evaluated as an ephemeral clone for the
+// reviews made with ctx, the loaded constraint is not modified and other reviews are not affected.
+// Violations of an overridden constraint have the overridden parameters in their constraint config.
+// Use CheckParameterOverrides to reject overrides of unknown constraints, which are ignored.
+func WithParameterOverrides(ctx context.Context, overrides []*ParameterOverride) context.Context {
+	if len(overrides) == 0 {
+		return ctx
+	}
+	byKey := map[string]*ParameterOverride{}
+	for _, override := range overrides {
+		byKey[override.key()] = override
+	}
+	return context.WithValue(ctx, parameterOverridesContextKey{}, byKey)
+}
+
+// parameterOverrides returns the overrides set by WithParameterOverrides, keyed by constraint.
+func parameterOverrides(ctx context.Context) map[string]*ParameterOverride {
+	overrides, _ := ctx.Value(parameterOverridesContextKey{}).(map[string]*ParameterOverride)
+	return overrides
+}
+
+// CheckParameterOverrides returns an error wrapping ErrInvalidParameterOverrides if any of the
+// overrides is for a constraint that isn't loaded, or results in parameters that don't match the
+// template's schema, see configs.ValidateParameters.
+func (v *Validator) CheckParameterOverrides(overrides []*ParameterOverride) error {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	constraints := map[string]*unstructured.Unstructured{}
+	for _, constraint := range v.config.Constraints() {
+		constraints[constraint.GetKind()+"."+originalName(constraint)] = constraint
+	}
+	var errs multierror.Errors
+	for _, override := range overrides {
+		constraint, found := constraints[override.key()]
+		if !found {
+			errs.Add(fmt.Errorf("constraint %s is not loaded", override.key()))
+			continue
+		}
+		clone, err := override.apply(constraint)
+		if err != nil {
+			errs.Add(fmt.Errorf("constraint %s: %w", override.key(), err))
+			continue
+		}
+		templ := v.findTemplate(func(t *cftemplates.ConstraintTemplate) bool {
+			return t.Spec.CRD.Spec.Names.Kind == override.Kind
+		})
+		if templ == nil {
+			continue
+		}
+		for _, err := range configs.ValidateParameters(templ, clone) {
+			errs.Add(fmt.Errorf("constraint %s: %w", override.key(), err))
+		}
+	}
+	if !errs.Empty() {
+		return fmt.Errorf("%w: %s", ErrInvalidParameterOverrides, errs.ToError())
+	}
+	return nil
+}
+
+// overrideCount makes the names of the ephemeral constraints unique across concurrent reviews.
+var overrideCount uint64
+
+// overrideDriver applies the parameter overrides of a review to ephemeral copies of the overridden
+// constraints.  The rego driver reads constraint parameters from its storage, so each copy is added
+// to the driver under a unique name for the duration of the query.
+type overrideDriver struct {
+	drivers.Driver
+}
+
+// Query implements drivers.Driver.
+func (d *overrideDriver) Query(ctx context.Context, target string, constraints []*unstructured.Unstructured, review interface{}, opts ...drivers.QueryOpt) (*drivers.QueryResponse, error) {
+	overrides := parameterOverrides(ctx)
+	if len(overrides) == 0 {
+		return d.Driver.Query(ctx, target, constraints, review, opts...)
+	}
+
+	queried := make([]*unstructured.Unstructured, len(constraints))
+	// overridden maps the names of the ephemeral constraints to the overridden constraint.
+	overridden := map[string]*unstructured.Unstructured{}
+	for idx, constraint := range constraints {
+		queried[idx] = constraint
+		override, found := overrides[constraint.GetKind()+"."+originalName(constraint)]
+		if !found {
+			continue
+		}
+		clone, err := override.apply(constraint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to override parameters of %s: %w", override.key(), err)
+		}
+		ephemeral := clone.DeepCopy()
+		ephemeral.SetName(fmt.Sprintf("%s-override-%d", constraint.GetName(), atomic.AddUint64(&overrideCount, 1)))
+		if err := d.Driver.AddConstraint(ctx, ephemeral); err != nil {
+			return nil, fmt.Errorf("failed to override parameters of %s: %w", override.key(), err)
+		}
+		defer func() {
+			// The ephemeral constraint is removed even if the review was cancelled.
+			if err := d.Driver.RemoveConstraint(context.Background(), ephemeral); err != nil {
+				glog.Errorf("failed to remove ephemeral constraint %s: %v", ephemeral.GetName(), err)
+			}
+		}()
+		queried[idx] = ephemeral
+		overridden[ephemeral.GetName()] = clone
+	}
+
+	resp, err := d.Driver.Query(ctx, target, queried, review, opts...)
+	if resp != nil {
+		for _, result := range resp.Results {
+			if result.Constraint == nil {
+				continue
+			}
+			if clone, found := overridden[result.Constraint.GetName()]; found {
+				result.Constraint = clone
+			}
+		}
+	}
+	return resp, err
+}
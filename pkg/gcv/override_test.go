@@ -0,0 +1,159 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// deniedNamesTemplate reports assets with a name in the names parameter.
+const deniedNamesTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: gcpdeniednamesconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPDeniedNamesConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: "object"
+          properties:
+            names:
+              type: array
+              items:
+                type: string
+  targets:
+    - target: "validation.gcp.forsetisecurity.org"
+      rego: |
+        package templates.gcp.GCPDeniedNamesConstraintV1
+
+        violation[{"msg": message}] {
+        	input.review.name == input.parameters.names[_]
+        	message := input.review.name
+        }
+`
+
+// deniedNamesConstraint denies no names.
+const deniedNamesConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPDeniedNamesConstraintV1
+metadata:
+  name: denied-names
+spec:
+  parameters:
+    names: []
+`
+
+const deniedBucket = "//storage.googleapis.com/my-storage-bucket"
+
+func newParameterOverride(t *testing.T, kind, name string, parameters map[string]interface{}) *validator.ParameterOverride {
+	t.Helper()
+	s, err := structpb.NewStruct(parameters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &validator.ParameterOverride{Kind: kind, Name: name, Parameters: s}
+}
+
+func TestReviewParameterOverrides(t *testing.T) {
+	cv, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(deniedNamesTemplate)},
+		{Path: "constraint.yaml", Content: []byte(deniedNamesConstraint)},
+	}, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	v := NewParallelValidator(stopChannel, cv)
+	asset := storageAssetNoLogging()
+
+	override := newParameterOverride(t, "GCPDeniedNamesConstraintV1", "denied-names", map[string]interface{}{
+		"names": []interface{}{deniedBucket},
+	})
+	resp, err := v.Review(context.Background(), &validator.ReviewRequest{
+		Assets:             []*validator.Asset{asset},
+		ParameterOverrides: []*validator.ParameterOverride{override},
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(resp.Violations) != 1 {
+		t.Fatalf("got %d violations with override, want 1: %v", len(resp.Violations), resp.Violations)
+	}
+	violation := resp.Violations[0]
+	if violation.Constraint != "GCPDeniedNamesConstraintV1.denied-names" {
+		t.Errorf("got constraint %q, want the overridden constraint", violation.Constraint)
+	}
+	names := violation.ConstraintConfig.GetSpec().GetStructValue().GetFields()["parameters"].GetStructValue().GetFields()["names"].GetListValue().GetValues()
+	if len(names) != 1 || names[0].GetStringValue() != deniedBucket {
+		t.Errorf("got constraint config names %v, want overridden names", names)
+	}
+
+	// The override only applies to the request that carried it.
+	resp, err = v.Review(context.Background(), &validator.ReviewRequest{Assets: []*validator.Asset{asset}})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(resp.Violations) != 0 {
+		t.Errorf("got violations %v without override, want none", resp.Violations)
+	}
+
+	testCases := []struct {
+		name     string
+		override *validator.ParameterOverride
+	}{
+		{
+			name:     "unknown constraint",
+			override: newParameterOverride(t, "GCPDeniedNamesConstraintV1", "missing", map[string]interface{}{}),
+		},
+		{
+			name: "unknown parameter",
+			override: newParameterOverride(t, "GCPDeniedNamesConstraintV1", "denied-names", map[string]interface{}{
+				"nmaes": []interface{}{deniedBucket},
+			}),
+		},
+		{
+			name: "wrong type",
+			override: newParameterOverride(t, "GCPDeniedNamesConstraintV1", "denied-names", map[string]interface{}{
+				"names": deniedBucket,
+			}),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := v.Review(context.Background(), &validator.ReviewRequest{
+				Assets:             []*validator.Asset{asset},
+				ParameterOverrides: []*validator.ParameterOverride{tc.override},
+			})
+			if !errors.Is(err, ErrInvalidParameterOverrides) {
+				t.Fatalf("got error %v, want %v", err, ErrInvalidParameterOverrides)
+			}
+			if got := ReviewStatus(err).Code(); got != codes.InvalidArgument {
+				t.Errorf("got code %v, want %v", got, codes.InvalidArgument)
+			}
+		})
+	}
+}
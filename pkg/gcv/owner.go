@@ -0,0 +1,65 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+)
+
+// PartitionByOwner splits the results by the owner of the violated constraints, as given by the
+// configs.OwnerAnnotation annotation.  Each returned result only holds the violations of constraints
+// with that owner, violations of constraints without an owner are keyed by the empty string.  Results
+// without violations are dropped.
+func PartitionByOwner(results []*Result) map[string][]*Result {
+	partitions := map[string][]*Result{}
+	for _, result := range results {
+		byOwner := map[string]*Result{}
+		var owners []string
+		for _, cv := range result.ConstraintViolations {
+			owner := configs.Owner(cv.Constraint)
+			ownerResult, found := byOwner[owner]
+			if !found {
+				ownerResult = &Result{
+					Name:           result.Name,
+					InputResource:  result.InputResource,
+					ReviewResource: result.ReviewResource,
+				}
+				byOwner[owner] = ownerResult
+				owners = append(owners, owner)
+			}
+			ownerResult.ConstraintViolations = append(ownerResult.ConstraintViolations, cv)
+		}
+		for _, owner := range owners {
+			partitions[owner] = append(partitions[owner], byOwner[owner])
+		}
+	}
+	return partitions
+}
+
+// PartitionViolationsByOwner splits the violations by the owner annotation in their constraint
+// config, violations of constraints without an owner are keyed by the empty string.
+func PartitionViolationsByOwner(violations []*validator.Violation) map[string][]*validator.Violation {
+	partitions := map[string][]*validator.Violation{}
+	for _, v := range violations {
+		annotations := v.GetConstraintConfig().GetMetadata().GetStructValue().GetFields()["annotations"]
+		owner := annotations.GetStructValue().GetFields()[configs.OwnerAnnotation].GetStringValue()
+		owner = strings.TrimSpace(owner)
+		partitions[owner] = append(partitions[owner], v)
+	}
+	return partitions
+}
@@ -0,0 +1,109 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func ownedConstraint(name, owner string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("constraints.gatekeeper.sh/v1beta1")
+	u.SetKind("GCPStorageLoggingConstraint")
+	u.SetName(name)
+	if owner != "" {
+		u.SetAnnotations(map[string]string{configs.OwnerAnnotation: owner})
+	}
+	return u
+}
+
+func TestRequireOwner(t *testing.T) {
+	policyPaths, policyLibPath := testOptions()
+	_, err := NewValidator(policyPaths, policyLibPath, RequireOwner())
+	if err == nil || !strings.Contains(err.Error(), configs.OwnerAnnotation) {
+		t.Fatalf("got error %v, want missing owner error", err)
+	}
+
+	report, err := ValidatePolicies(policyPaths, policyLibPath, RequireOwner())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !report.HasErrors() {
+		t.Errorf("expected missing owner errors")
+	}
+}
+
+func TestPartitionByOwner(t *testing.T) {
+	security := ownedConstraint("security-owned", "security")
+	team := ownedConstraint("team-owned", "team-a")
+	unowned := ownedConstraint("unowned", "")
+	results := []*Result{
+		{
+			Name: "//storage.googleapis.com/bucket-1",
+			ConstraintViolations: []ConstraintViolation{
+				{Message: "one", Constraint: security},
+				{Message: "two", Constraint: team},
+				{Message: "three", Constraint: security},
+			},
+		},
+		{
+			Name: "//storage.googleapis.com/bucket-2",
+			ConstraintViolations: []ConstraintViolation{
+				{Message: "four", Constraint: unowned},
+			},
+		},
+		{
+			Name: "//storage.googleapis.com/bucket-3",
+		},
+	}
+
+	partitions := PartitionByOwner(results)
+	want := map[string]int{"security": 2, "team-a": 1, "": 1}
+	if len(partitions) != len(want) {
+		t.Errorf("got %d partitions, want %d", len(partitions), len(want))
+	}
+	var violations int
+	for owner, count := range want {
+		got := 0
+		for _, result := range partitions[owner] {
+			got += len(result.ConstraintViolations)
+			for _, cv := range result.ConstraintViolations {
+				if configs.Owner(cv.Constraint) != owner {
+					t.Errorf("violation of %s in partition %q", cv.Constraint.GetName(), owner)
+				}
+			}
+			vs, err := result.ToViolations()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for owner, vs := range PartitionViolationsByOwner(vs) {
+				if _, ok := want[owner]; !ok {
+					t.Errorf("unexpected violation owner %q", owner)
+				}
+				violations += len(vs)
+			}
+		}
+		if got != count {
+			t.Errorf("partition %q got %d violations, want %d", owner, got, count)
+		}
+	}
+	if violations != 4 {
+		t.Errorf("got %d partitioned violations, want 4", violations)
+	}
+}
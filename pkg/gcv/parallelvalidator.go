@@ -16,31 +16,146 @@ package gcv
 
 import (
 	"context"
-	"flag"
-	"runtime"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
 	"github.com/golang/glog"
-	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
-var flags struct {
-	workerCount int
+// AssetError is the error for a single asset that failed review in ParallelValidator.Review.
+type AssetError struct {
+	// Index is the index of the asset in the review request.
+	Index int
+	// Name is the name of the asset.
+	Name string
+	// Err is the review error.
+	Err error
+}
+
+// Error implements error.
+func (e *AssetError) Error() string {
+	return fmt.Sprintf("index %d: %s", e.Index, e.Err)
+}
+
+// Unwrap returns the review error.
+func (e *AssetError) Unwrap() error {
+	return e.Err
+}
+
+// ReviewStatus converts an error returned by ParallelValidator.Review to a gRPC status.  Each
+// AssetError becomes a BadRequest field violation for assets[index] so clients can retry or report
+// only the failing assets.  Reviews stopped by the context are DeadlineExceeded or Canceled.
+func ReviewStatus(err error) *status.Status {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.New(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.New(codes.Canceled, err.Error())
+	case errors.Is(err, ErrInvalidParameterOverrides), errors.Is(err, ErrInvalidReviewOptions),
+		errors.Is(err, ErrInvalidPolicyOverlay):
+		return status.New(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrRequestTooLarge):
+		return status.New(codes.ResourceExhausted, err.Error())
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	var unwrap func(err error)
+	unwrap = func(err error) {
+		var assetErr *AssetError
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				unwrap(err)
+			}
+		default:
+			if errors.As(err, &assetErr) {
+				violations = append(violations, &errdetails.BadRequest_FieldViolation{
+					Field:       fmt.Sprintf("assets[%d]", assetErr.Index),
+					Description: fmt.Sprintf("%s: %s", assetErr.Name, assetErr.Err),
+				})
+			}
+		}
+	}
+	unwrap(err)
+
+	if len(violations) == 0 {
+		return status.New(codes.Internal, err.Error())
+	}
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("review failed for %d assets: %s", len(violations), err))
+	detailed, detailErr := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if detailErr != nil {
+		glog.Errorf("failed to add review error details: %s", detailErr)
+		return st
+	}
+	return detailed
 }
 
-func init() {
-	flag.IntVar(
-		&flags.workerCount,
-		"workerCount",
-		runtime.NumCPU(),
-		"Number of workers that Validator will spawn to handle validate calls, this defaults to core count on the host")
+// ErrRequestTooLarge is returned by ParallelValidator.Review for requests over the limits set with
+// MaxAssetsPerRequest or MaxAssetBytes.
+var ErrRequestTooLarge = errors.New("review request too large")
+
+// requestLimits bound the assets of a review request, zero fields are unlimited.
+type requestLimits struct {
+	maxAssets     int
+	maxAssetBytes int
+}
+
+// check returns an error wrapping ErrRequestTooLarge if the request is over the limits.
+func (l requestLimits) check(request *validator.ReviewRequest) error {
+	if l.maxAssets > 0 && len(request.Assets) > l.maxAssets {
+		return fmt.Errorf("%w: request has %d assets, the limit is %d", ErrRequestTooLarge, len(request.Assets), l.maxAssets)
+	}
+	if l.maxAssetBytes > 0 {
+		for idx, asset := range request.Assets {
+			if size := proto.Size(asset); size > l.maxAssetBytes {
+				return fmt.Errorf("%w: assets[%d] %s is %d bytes, the limit is %d", ErrRequestTooLarge, idx, asset.GetName(), size, l.maxAssetBytes)
+			}
+		}
+	}
+	return nil
 }
 
+// errStopped is returned by reviews of a ParallelValidator that is stopped, or stopped before the
+// review finished.
+var errStopped = errors.New("validator is stopped")
+
 // ParallelValidator handles making parallel calls to Validator during a Review call.
 type ParallelValidator struct {
 	cv   ConfigValidator
 	work chan func()
+	// limits bound the assets of each review request.
+	limits requestLimits
+	// enforceOnly are the enforcement actions of the violations that are returned, any if empty.
+	enforceOnly map[string]bool
+
+	// mtx guards the workers, workerCount and run.
+	mtx sync.Mutex
+	// workers holds a quit channel for each running worker.
+	workers []chan struct{}
+	// workerCount is the number of workers started by Start.
+	workerCount int
+	// run is the current run of the validator, nil while it is stopped.
+	run *parallelRun
+}
+
+// parallelRun is a run of a ParallelValidator, from Start to Stop.
+type parallelRun struct {
+	// done is closed to shut down the workers and the reviews still in flight, work is never closed so
+	// that reviews dispatching assets can't send on a closed channel.
+	done chan struct{}
+	// reviews are the reviews in flight.
+	reviews sync.WaitGroup
+	// workers are the running workers.
+	workers sync.WaitGroup
 }
 
 type assetResult struct {
@@ -48,46 +163,197 @@ type assetResult struct {
 	err        error
 }
 
-// NewParallelValidator creates a new instance with the given stop channel and validator
-func NewParallelValidator(stopChannel <-chan struct{}, cv ConfigValidator) *ParallelValidator {
+// NewParallelValidator creates a new instance with the given stop channel and validator, and starts it.
+// The number of workers is set with WithWorkerCount, the request limits with MaxAssetsPerRequest and
+// MaxAssetBytes and the returned violations with EnforceOnly, other options are ignored.  Options that
+// are not set are taken from cv when it is a *Validator, otherwise the worker count is the number of
+// CPUs and requests are unlimited.  Closing the stop channel stops the validator without draining the
+// reviews in flight, the stop channel may be nil if the lifecycle is managed with Start and Stop.
+func NewParallelValidator(stopChannel <-chan struct{}, cv ConfigValidator, opts ...Option) *ParallelValidator {
+	options := newInitOptions(opts...)
+	workerCount := options.workers()
+	limits := options.requestLimits
+	if v, ok := cv.(*Validator); ok {
+		if options.workerCount < 1 {
+			workerCount = v.workerCount
+		}
+		if limits.maxAssets == 0 {
+			limits.maxAssets = v.requestLimits.maxAssets
+		}
+		if limits.maxAssetBytes == 0 {
+			limits.maxAssetBytes = v.requestLimits.maxAssetBytes
+		}
+		if len(options.enforceOnly) == 0 {
+			options.enforceOnly = v.enforceOnly
+		}
+	}
 	pv := &ParallelValidator{
 		// channel size of number of workers seems sufficient to prevent blocking,
 		// this is really just an assumption with no actual perf benchmarking.
-		work: make(chan func(), flags.workerCount),
-		cv:   cv,
+		work:        make(chan func(), workerCount),
+		cv:          cv,
+		limits:      limits,
+		workerCount: workerCount,
+	}
+	if len(options.enforceOnly) != 0 {
+		pv.enforceOnly = map[string]bool{}
+		for _, action := range options.enforceOnly {
+			pv.enforceOnly[strings.ToLower(strings.TrimSpace(action))] = true
+		}
+	}
+
+	if stopChannel != nil {
+		go func() {
+			<-stopChannel
+			glog.Infof("validator shutdown requested via stopChannel close")
+			// A done context stops the validator without waiting for the reviews in flight.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_ = pv.Stop(ctx)
+		}()
+	}
+
+	if err := pv.Start(context.Background()); err != nil {
+		glog.Errorf("failed to start workers: %v", err)
+	}
+	return pv
+}
+
+// Start starts the workers, with the worker count of the last run, so that the validator accepts
+// reviews.  Starting a running validator does nothing, a stopped validator can be started again.
+func (v *ParallelValidator) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if v.run != nil {
+		return nil
+	}
+	glog.Infof("validator starting %d workers", v.workerCount)
+	v.run = &parallelRun{done: make(chan struct{})}
+	v.setWorkerCount(v.workerCount)
+	return nil
+}
+
+// Stop stops the validator.  New reviews are rejected at once, the reviews in flight are given until
+// ctx is done to finish and are then stopped with an error, and the workers are shut down.  It
+// returns ctx's error if reviews had to be stopped.  Stopping a stopped validator does nothing, it can
+// be started again with Start.
+func (v *ParallelValidator) Stop(ctx context.Context) error {
+	v.mtx.Lock()
+	run := v.run
+	v.run = nil
+	v.workers = nil
+	v.mtx.Unlock()
+	if run == nil {
+		return nil
 	}
 
+	drained := make(chan struct{})
 	go func() {
-		<-stopChannel
-		glog.Infof("validator shutdown requested via stopChannel close")
-		close(pv.work)
+		run.reviews.Wait()
+		close(drained)
 	}()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		glog.Warningf("validator stopping with reviews in flight: %v", err)
+	}
+	close(run.done)
+	run.workers.Wait()
+	// Assets queued by stopped reviews are dropped rather than reviewed by the next run.
+	for {
+		select {
+		case <-v.work:
+		default:
+			return err
+		}
+	}
+}
+
+// WorkerCount returns the number of running workers.
+func (v *ParallelValidator) WorkerCount() int {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	return len(v.workers)
+}
 
-	workerCount := flags.workerCount
-	glog.Infof("validator starting %d workers", workerCount)
-	for i := 0; i < workerCount; i++ {
-		go pv.reviewWorker(i)
+// SetWorkerCount starts or stops workers so that n are running, and are started by Start.  Stopped
+// workers finish the review they are running first.  Reviews already queued are unaffected.  It fails
+// if the validator is stopped.
+func (v *ParallelValidator) SetWorkerCount(n int) error {
+	if n < 1 {
+		return fmt.Errorf("worker count must be at least 1, got %d", n)
 	}
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if v.run == nil {
+		return errStopped
+	}
+	v.workerCount = n
+	v.setWorkerCount(n)
+	return nil
+}
 
-	return pv
+// setWorkerCount starts or stops workers of the current run so that n are running.  v.mtx must be
+// held and the validator running.
+func (v *ParallelValidator) setWorkerCount(n int) {
+	for len(v.workers) < n {
+		quit := make(chan struct{})
+		v.run.workers.Add(1)
+		go v.reviewWorker(len(v.workers), quit, v.run)
+		v.workers = append(v.workers, quit)
+	}
+	for len(v.workers) > n {
+		last := len(v.workers) - 1
+		close(v.workers[last])
+		v.workers = v.workers[:last]
+	}
+}
+
+// startReview registers a review with the current run, which is returned, or returns errStopped if
+// the validator is stopped.  The review must call run.reviews.Done when it finishes.
+func (v *ParallelValidator) startReview() (*parallelRun, error) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if v.run == nil {
+		return nil, errStopped
+	}
+	v.run.reviews.Add(1)
+	return v.run, nil
 }
 
 // reviewWorker is the function that each worker goroutine will use
-func (v *ParallelValidator) reviewWorker(idx int) {
+func (v *ParallelValidator) reviewWorker(idx int, quit <-chan struct{}, run *parallelRun) {
 	glog.V(1).Infof("worker %d starting", idx)
-	for f := range v.work {
-		f()
+	defer glog.V(1).Infof("worker %d terminated", idx)
+	defer run.workers.Done()
+	for {
+		select {
+		case f := <-v.work:
+			f()
+		case <-quit:
+			return
+		case <-run.done:
+			return
+		}
 	}
-	glog.V(1).Infof("worker %d terminated", idx)
 }
 
 // handleReview is the wrapper function for individual asset reviews.
 func (v *ParallelValidator) handleReview(ctx context.Context, idx int, asset *validator.Asset, resultChan chan<- *assetResult) func() {
 	return func() {
 		resultChan <- func() *assetResult {
+			// Assets queued before the request was cancelled are skipped.
+			if err := ctx.Err(); err != nil {
+				return &assetResult{err: err}
+			}
 			violations, err := v.cv.ReviewAsset(ctx, asset)
 			if err != nil {
-				return &assetResult{err: errors.Wrapf(err, "index %d", idx)}
+				return &assetResult{err: &AssetError{Index: idx, Name: asset.GetName(), Err: err}}
 			}
 			return &assetResult{violations: violations}
 		}()
@@ -95,31 +361,133 @@ func (v *ParallelValidator) handleReview(ctx context.Context, idx int, asset *va
 }
 
 // Review evaluates each asset in the review request in parallel and returns any
-// violations found.
-func (v *ParallelValidator) Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
+// violations found.  Requests over the limits set with MaxAssetsPerRequest or MaxAssetBytes are
+// rejected with ErrRequestTooLarge before any asset is reviewed.  If ctx is done before all assets
+// are reviewed, the remaining assets are not dispatched, in-flight evaluations are cancelled and the
+// violations found so far are returned with the context error.  The request's ReviewOptions select
+// the violations that are returned, and with fail_fast the review stops at the first asset that
+// fails, and with stop_at_severity at the first violation of at least the severity.  Invalid options
+// are rejected with ErrInvalidReviewOptions.  The request's PolicyOverlay selects the constraints
+// that are evaluated, see WithPolicyOverlay.
+func (v *ParallelValidator) Review(ctx context.Context, request *validator.ReviewRequest) (_ *validator.ReviewResponse, err error) {
+	ctx, span := tracer().Start(ctx, "Validator.Review", trace.WithAttributes(attrAssetCount.Int(len(request.Assets))))
+	defer func() { endSpan(span, err) }()
+
+	if err := v.limits.check(request); err != nil {
+		return nil, err
+	}
+	run, err := v.startReview()
+	if err != nil {
+		return nil, err
+	}
+	defer run.reviews.Done()
+	if request.EvaluationTime != nil {
+		ctx = WithEvaluationTime(ctx, request.EvaluationTime.AsTime())
+	}
+	if cv, ok := v.cv.(*Validator); ok {
+		ctx = cv.runContext(ctx)
+	}
+	if len(request.ParameterOverrides) != 0 {
+		overrides := ParameterOverridesFromProto(request.ParameterOverrides)
+		if cv, ok := v.cv.(*Validator); ok {
+			if err := cv.CheckParameterOverrides(overrides); err != nil {
+				return nil, err
+			}
+		}
+		ctx = WithParameterOverrides(ctx, overrides)
+	}
+	if overlay := PolicyOverlayFromProto(request.GetOverlay()); overlay != nil {
+		if cv, ok := v.cv.(*Validator); ok {
+			if err := cv.CheckPolicyOverlay(overlay); err != nil {
+				return nil, err
+			}
+		}
+		ctx = WithPolicyOverlay(ctx, overlay)
+	}
+	if request.ApplySampling {
+		ctx = WithSampling(ctx)
+	}
+	filter, err := newReviewFilter(request.GetOptions())
+	if err != nil {
+		return nil, err
+	}
+	filter.enforceOnly = v.enforceOnly
+	if policySet := request.GetOptions().GetPolicySet(); policySet != "" {
+		if cv, ok := v.cv.(*Validator); ok {
+			if err := cv.CheckPolicySet(policySet); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var stop *stopCondition
+	if severity := request.GetOptions().GetStopAtSeverity(); severity != "" {
+		rank, err := parseSeverity("stop_at_severity", severity)
+		if err != nil {
+			return nil, err
+		}
+		stop = &stopCondition{rank: rank, filter: filter}
+		ctx = context.WithValue(ctx, stopConditionContextKey{}, stop)
+	}
+	failFast := request.GetOptions().GetFailFast()
 	assetCount := len(request.Assets)
-	// channel size of number of workers seems sufficient to prevent blocking,
-	// this is really just an assumption with no actual perf benchmarking.
-	resultChan := make(chan *assetResult, flags.workerCount)
-	defer close(resultChan)
+	// The result channel holds every result so that in-flight reviews never block once Review has
+	// returned early, it is not closed as workers may still send to it.
+	resultChan := make(chan *assetResult, assetCount)
+	// The assets still queued or in flight are cancelled when a fail fast review fails.
+	workCtx, cancelWork := context.WithCancel(ctx)
+	defer cancelWork()
 
 	go func() {
 		for idx, asset := range request.Assets {
-			v.work <- v.handleReview(ctx, idx, asset, resultChan)
+			select {
+			case v.work <- v.handleReview(workCtx, idx, asset, resultChan):
+			case <-workCtx.Done():
+				return
+			case <-run.done:
+				return
+			}
 		}
 	}()
 
 	response := &validator.ReviewResponse{}
 	var errs multierror.Errors
+	reviewed := 0
+collect:
 	for i := 0; i < assetCount; i++ {
-		result := <-resultChan
-		if result.err != nil {
-			errs.Add(result.err)
-			continue
+		select {
+		case result := <-resultChan:
+			if result.err != nil && ctx.Err() != nil && errors.Is(result.err, ctx.Err()) {
+				// Assets stopped by the context are reported once below.
+				continue
+			}
+			reviewed++
+			if result.err != nil {
+				errs.Add(result.err)
+				if failFast {
+					cancelWork()
+					break collect
+				}
+				continue
+			}
+			kept := filter.apply(result.violations)
+			response.Violations = append(response.Violations, kept...)
+			if stop != nil && stop.stopsAny(kept) {
+				response.Stopped = true
+				cancelWork()
+				break collect
+			}
+		case <-ctx.Done():
+			break collect
+		case <-run.done:
+			errs.Add(errStopped)
+			break collect
 		}
-		response.Violations = append(response.Violations, result.violations...)
+	}
+	if err := ctx.Err(); err != nil && reviewed < assetCount {
+		errs.Add(fmt.Errorf("review stopped after %d of %d assets: %w", reviewed, assetCount, err))
 	}
 
+	span.SetAttributes(attrViolations.Int(len(response.Violations)))
 	if !errs.Empty() {
 		return response, errs.ToError()
 	}
@@ -16,10 +16,15 @@ package gcv
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
 
 	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
 )
@@ -134,12 +139,6 @@ func TestReview(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			oldWorkerCount := flags.workerCount
-			defer func() {
-				flags.workerCount = oldWorkerCount
-			}()
-			flags.workerCount = tc.workerCount
-
 			stopChannel := make(chan struct{})
 			defer close(stopChannel)
 			cv := NewFakeConfigValidator(
@@ -168,7 +167,7 @@ func TestReview(t *testing.T) {
 					"//cloudresourcemanager.googleapis.com/projects/123":           nil,
 				},
 			)
-			v := NewParallelValidator(stopChannel, cv)
+			v := NewParallelValidator(stopChannel, cv, WithWorkerCount(tc.workerCount))
 
 			var groupDone sync.WaitGroup
 			for callIdx, call := range tc.calls {
@@ -210,3 +209,294 @@ func TestReview(t *testing.T) {
 		})
 	}
 }
+
+func TestReviewStatus(t *testing.T) {
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	cv := NewFakeConfigValidator(map[string][]*validator.Violation{
+		"//storage.googleapis.com/my-storage-bucket": nil,
+	})
+	v := NewParallelValidator(stopChannel, cv)
+
+	_, err := v.Review(context.Background(), &validator.ReviewRequest{
+		Assets: []*validator.Asset{
+			{Name: "//storage.googleapis.com/my-storage-bucket"},
+			{Name: "invalid name"},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected error, got none")
+	}
+
+	st := ReviewStatus(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Errorf("got code %v, want %v", st.Code(), codes.InvalidArgument)
+	}
+	var fields []*errdetails.BadRequest_FieldViolation
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			fields = append(fields, br.FieldViolations...)
+		}
+	}
+	if len(fields) != 1 {
+		t.Fatalf("got %d field violations, want 1: %v", len(fields), fields)
+	}
+	if fields[0].Field != "assets[1]" {
+		t.Errorf("got field %q, want %q", fields[0].Field, "assets[1]")
+	}
+	if !strings.Contains(fields[0].Description, "invalid name") {
+		t.Errorf("description %q does not name the asset", fields[0].Description)
+	}
+
+	if got := ReviewStatus(errors.New("boom")).Code(); got != codes.Internal {
+		t.Errorf("got code %v for non-asset error, want %v", got, codes.Internal)
+	}
+}
+
+func TestSetWorkerCount(t *testing.T) {
+	stopChannel := make(chan struct{})
+	cv := NewFakeConfigValidator(map[string][]*validator.Violation{
+		"//storage.googleapis.com/my-storage-bucket": nil,
+	})
+	v := NewParallelValidator(stopChannel, cv, WithWorkerCount(2))
+	if got := v.WorkerCount(); got != 2 {
+		t.Errorf("got %d workers, want 2", got)
+	}
+
+	review := func() {
+		t.Helper()
+		if _, err := v.Review(context.Background(), &validator.ReviewRequest{
+			Assets: []*validator.Asset{{Name: "//storage.googleapis.com/my-storage-bucket"}},
+		}); err != nil {
+			t.Errorf("review error: %v", err)
+		}
+	}
+	for _, n := range []int{8, 1, 3} {
+		if err := v.SetWorkerCount(n); err != nil {
+			t.Fatal(err)
+		}
+		if got := v.WorkerCount(); got != n {
+			t.Errorf("got %d workers, want %d", got, n)
+		}
+		review()
+	}
+	if err := v.SetWorkerCount(0); err == nil {
+		t.Errorf("expected error for zero workers")
+	}
+
+	close(stopChannel)
+	for i := 0; ; i++ {
+		if err := v.SetWorkerCount(2); err != nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal("expected error after stop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewParallelValidatorWorkerCount(t *testing.T) {
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	cv := &Validator{workerCount: 3}
+	if got := NewParallelValidator(stopChannel, cv).WorkerCount(); got != 3 {
+		t.Errorf("got %d workers, want the validator's 3", got)
+	}
+	if got := NewParallelValidator(stopChannel, cv, WithWorkerCount(5)).WorkerCount(); got != 5 {
+		t.Errorf("got %d workers, want 5", got)
+	}
+}
+
+func TestReviewRequestLimits(t *testing.T) {
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	bucket := "//storage.googleapis.com/my-storage-bucket"
+	cv := NewFakeConfigValidator(map[string][]*validator.Violation{bucket: nil})
+	large := &validator.Asset{Name: bucket, AssetType: strings.Repeat("x", 200)}
+
+	testCases := []struct {
+		name    string
+		opts    []Option
+		assets  []*validator.Asset
+		wantErr bool
+	}{
+		{name: "unlimited", assets: []*validator.Asset{{Name: bucket}, {Name: bucket}, large}},
+		{name: "under limits", opts: []Option{MaxAssetsPerRequest(2), MaxAssetBytes(100)}, assets: []*validator.Asset{{Name: bucket}, {Name: bucket}}},
+		{name: "too many assets", opts: []Option{MaxAssetsPerRequest(2)}, assets: []*validator.Asset{{Name: bucket}, {Name: bucket}, {Name: bucket}}, wantErr: true},
+		{name: "asset too large", opts: []Option{MaxAssetBytes(100)}, assets: []*validator.Asset{{Name: bucket}, large}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := NewParallelValidator(stopChannel, cv, tc.opts...)
+			_, err := v.Review(context.Background(), &validator.ReviewRequest{Assets: tc.assets})
+			if !tc.wantErr {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrRequestTooLarge) {
+				t.Fatalf("got error %v, want ErrRequestTooLarge", err)
+			}
+			if got := ReviewStatus(err).Code(); got != codes.ResourceExhausted {
+				t.Errorf("got code %v, want %v", got, codes.ResourceExhausted)
+			}
+		})
+	}
+
+	// The limits of a *Validator apply unless they are set on the ParallelValidator.
+	validatorLimits := &Validator{workerCount: 1, requestLimits: requestLimits{maxAssets: 1}}
+	if _, err := NewParallelValidator(stopChannel, validatorLimits).Review(context.Background(), &validator.ReviewRequest{
+		Assets: []*validator.Asset{{Name: bucket}, {Name: bucket}},
+	}); !errors.Is(err, ErrRequestTooLarge) {
+		t.Errorf("got error %v, want the validator's limit to apply", err)
+	}
+}
+
+// blockingConfigValidator returns a violation for the first asset and blocks on the others until
+// the context is done.
+type blockingConfigValidator struct {
+	mtx   sync.Mutex
+	calls int
+}
+
+func (v *blockingConfigValidator) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error) {
+	v.mtx.Lock()
+	v.calls++
+	first := v.calls == 1
+	v.mtx.Unlock()
+	if first {
+		return []*validator.Violation{{Resource: asset.Name}}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestReviewDeadline(t *testing.T) {
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	cv := &blockingConfigValidator{}
+	v := NewParallelValidator(stopChannel, cv, WithWorkerCount(1))
+
+	var assets []*validator.Asset
+	for i := 0; i < 16; i++ {
+		assets = append(assets, &validator.Asset{Name: fmt.Sprintf("//storage.googleapis.com/bucket-%d", i)})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	response, err := v.Review(ctx, &validator.ReviewRequest{Assets: assets})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := len(response.GetViolations()); got != 1 {
+		t.Errorf("got %d partial violations, want 1", got)
+	}
+	if code := ReviewStatus(err).Code(); code != codes.DeadlineExceeded {
+		t.Errorf("got code %v, want %v", code, codes.DeadlineExceeded)
+	}
+
+	// The remaining assets are skipped rather than reviewed once the deadline has passed.
+	time.Sleep(50 * time.Millisecond)
+	cv.mtx.Lock()
+	defer cv.mtx.Unlock()
+	if cv.calls > 3 {
+		t.Errorf("got %d reviews after deadline, want at most 3", cv.calls)
+	}
+}
+
+// gatedConfigValidator blocks each review until release is closed or the context is done.
+type gatedConfigValidator struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (v *gatedConfigValidator) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error) {
+	v.started <- struct{}{}
+	select {
+	case <-v.release:
+		return []*validator.Violation{{Resource: asset.Name}}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestParallelValidatorStartStop(t *testing.T) {
+	cv := NewFakeConfigValidator(map[string][]*validator.Violation{
+		"//storage.googleapis.com/my-storage-bucket": nil,
+	})
+	v := NewParallelValidator(nil, cv, WithWorkerCount(2))
+	request := &validator.ReviewRequest{Assets: []*validator.Asset{{Name: "//storage.googleapis.com/my-storage-bucket"}}}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := v.Stop(ctx); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if got := v.WorkerCount(); got != 0 {
+			t.Errorf("got %d workers after Stop, want 0", got)
+		}
+		if _, err := v.Review(ctx, request); !errors.Is(err, errStopped) {
+			t.Errorf("got error %v after Stop, want %v", err, errStopped)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := v.Start(ctx); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if got := v.WorkerCount(); got != 2 {
+			t.Errorf("got %d workers after Start, want 2", got)
+		}
+	}
+	if _, err := v.Review(ctx, request); err != nil {
+		t.Errorf("unexpected error %v after restart", err)
+	}
+}
+
+func TestParallelValidatorStopDrains(t *testing.T) {
+	cv := &gatedConfigValidator{started: make(chan struct{}), release: make(chan struct{})}
+	v := NewParallelValidator(nil, cv, WithWorkerCount(1))
+	request := &validator.ReviewRequest{Assets: []*validator.Asset{{Name: "//storage.googleapis.com/my-storage-bucket"}}}
+
+	errs := make(chan error)
+	go func() {
+		_, err := v.Review(context.Background(), request)
+		errs <- err
+	}()
+	<-cv.started
+	stopped := make(chan error)
+	go func() {
+		stopped <- v.Stop(context.Background())
+	}()
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop returned %v before the review in flight finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(cv.release)
+	if err := <-errs; err != nil {
+		t.Errorf("got review error %v, want the review in flight to finish", err)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("unexpected Stop error %v", err)
+	}
+
+	// Reviews still in flight when the drain times out are stopped.
+	cv.release = make(chan struct{})
+	if err := v.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_, err := v.Review(context.Background(), request)
+		errs <- err
+	}()
+	<-cv.started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := v.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got Stop error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := <-errs; !errors.Is(err, errStopped) {
+		t.Errorf("got review error %v, want %v", err, errStopped)
+	}
+}
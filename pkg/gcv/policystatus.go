@@ -0,0 +1,183 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/tftarget"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// PolicyStatus describes the policies a Validator is serving, see Validator.PolicyStatus.
+type PolicyStatus struct {
+	// Fingerprint is the hex encoded SHA-256 of the templates, including their rego and libraries,
+	// constraints and expansion templates being served.  The annotations added when loading, such as
+	// the source path, are left out so that replicas loading the same policies from different paths
+	// have the same fingerprint.
+	Fingerprint string
+	// Targets are the number of templates and constraints of each target, sorted by target.
+	Targets []TargetPolicyStatus
+	// LoadTime is when the policies were loaded.
+	LoadTime time.Time
+	// LoadDuration is how long loading the policies took.
+	LoadDuration time.Duration
+	// Warnings are the non-fatal problems found while loading the policies.
+	Warnings []*configs.Issue
+}
+
+// TargetPolicyStatus counts the templates and constraints of a target.
+type TargetPolicyStatus struct {
+	Target      string
+	Templates   int
+	Constraints int
+}
+
+// PolicyStatus returns the status of the policies the Validator is serving.  Unlike the LoadReport,
+// the fingerprint and counts reflect templates and constraints added or removed since the load.
+func (v *Validator) PolicyStatus() (*PolicyStatus, error) {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	status := &PolicyStatus{}
+	if v.loadReport != nil {
+		status.LoadTime = v.loadReport.StartTime
+		status.LoadDuration = v.loadReport.ConfigDuration + v.loadReport.CompileDuration
+		status.Warnings = v.loadReport.Warnings
+	}
+	var digests []string
+	add := func(target string, templates []*cftemplates.ConstraintTemplate, constraints []*unstructured.Unstructured) error {
+		for _, templ := range templates {
+			digest, err := policyDigest("template", target, templ)
+			if err != nil {
+				return fmt.Errorf("template %s: %w", templ.Name, err)
+			}
+			digests = append(digests, digest)
+		}
+		for _, constraint := range constraints {
+			digest, err := policyDigest("constraint", target, constraint.Object)
+			if err != nil {
+				return fmt.Errorf("constraint %s: %w", constraintName(constraint), err)
+			}
+			digests = append(digests, digest)
+		}
+		status.Targets = append(status.Targets, TargetPolicyStatus{
+			Target:      target,
+			Templates:   len(templates),
+			Constraints: len(constraints),
+		})
+		return nil
+	}
+	if err := add(gcptarget.Name, v.config.GCPTemplates, v.config.GCPConstraints); err != nil {
+		return nil, err
+	}
+	if v.k8sCFClient != nil {
+		if err := add(configs.K8STargetName, v.config.K8STemplates, v.config.K8SConstraints); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(tftarget.Name, v.config.TFTemplates, v.config.TFConstraints); err != nil {
+		return nil, err
+	}
+	for _, tgt := range v.customTargets {
+		name := tgt.name()
+		if err := add(name, v.config.CustomTemplates[name], v.config.CustomConstraints[name]); err != nil {
+			return nil, err
+		}
+	}
+	if v.k8sExpansion {
+		for _, et := range v.config.ExpansionTemplates {
+			unloaded := *et
+			unloaded.Path = ""
+			digest, err := policyDigest("expansion", "", &unloaded)
+			if err != nil {
+				return nil, fmt.Errorf("expansion template %s: %w", et.Name, err)
+			}
+			digests = append(digests, digest)
+		}
+	}
+	sort.Slice(status.Targets, func(i, j int) bool {
+		return status.Targets[i].Target < status.Targets[j].Target
+	})
+
+	// The digests are sorted so that the fingerprint does not depend on the order the policies were
+	// loaded or updated in.
+	sort.Strings(digests)
+	h := sha256.New()
+	for _, digest := range digests {
+		h.Write([]byte(digest))
+	}
+	status.Fingerprint = hex.EncodeToString(h.Sum(nil))
+	return status, nil
+}
+
+// policyDigest returns the hex encoded SHA-256 of the policy object of the target, without the
+// annotations added when it was loaded.  Objects are marshalled twice so that the digest is of JSON
+// with sorted keys.
+func policyDigest(kind, target string, obj interface{}) (string, error) {
+	content, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(content, &object); err != nil {
+		return "", err
+	}
+	if annotations, ok, _ := unstructured.NestedMap(object, "metadata", "annotations"); ok {
+		for key := range annotations {
+			if configs.IsLoaderAnnotation(key) {
+				unstructured.RemoveNestedField(object, "metadata", "annotations", key)
+			}
+		}
+	}
+	if content, err = json.Marshal(object); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", kind, target)
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ToProto converts the status to its RPC representation.
+func (s *PolicyStatus) ToProto() *validator.PolicyStatus {
+	pb := &validator.PolicyStatus{
+		Fingerprint:  s.Fingerprint,
+		LoadTime:     timestamppb.New(s.LoadTime),
+		LoadDuration: durationpb.New(s.LoadDuration),
+	}
+	for _, t := range s.Targets {
+		pb.Targets = append(pb.Targets, &validator.TargetPolicyStatus{
+			Target:      t.Target,
+			Templates:   int32(t.Templates),
+			Constraints: int32(t.Constraints),
+		})
+	}
+	for _, issue := range s.Warnings {
+		pb.Warnings = append(pb.Warnings, issueObject(issue).ToProto())
+	}
+	return pb
+}
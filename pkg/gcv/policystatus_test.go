@@ -0,0 +1,94 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPolicyStatus(t *testing.T) {
+	ctx := context.Background()
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	other, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	status := func(v *Validator) *PolicyStatus {
+		t.Helper()
+		s, err := v.PolicyStatus()
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		return s
+	}
+
+	loaded := status(v)
+	if loaded.Fingerprint == "" {
+		t.Fatal("empty fingerprint")
+	}
+	if got := status(other).Fingerprint; got != loaded.Fingerprint {
+		t.Errorf("got fingerprint %s for the same policies, want %s", got, loaded.Fingerprint)
+	}
+	if loaded.LoadTime.IsZero() {
+		t.Error("load time is not set")
+	}
+	var gcp *TargetPolicyStatus
+	for idx := range loaded.Targets {
+		if loaded.Targets[idx].Target == gcptarget.Name {
+			gcp = &loaded.Targets[idx]
+		}
+	}
+	if gcp == nil || gcp.Templates != len(v.config.GCPTemplates) || gcp.Constraints != len(v.config.GCPConstraints) {
+		t.Errorf("got GCP target status %+v, want %d templates and %d constraints", gcp, len(v.config.GCPTemplates), len(v.config.GCPConstraints))
+	}
+
+	var constraint *unstructured.Unstructured
+	for _, c := range v.config.GCPConstraints {
+		if c.GetKind() == "CFGCPStorageLoggingConstraint" {
+			constraint = c
+		}
+	}
+	if err := v.RemoveConstraint(ctx, "CFGCPStorageLoggingConstraint", "require-storage-logging"); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := status(v).Fingerprint; got == loaded.Fingerprint {
+		t.Error("fingerprint did not change when a constraint was removed")
+	}
+
+	// The constraint loaded from another path is the same policy.
+	moved := constraint.DeepCopy()
+	annotations := moved.GetAnnotations()
+	annotations[configs.GCPTargetName+"/yamlpath"] = "/elsewhere/constraint.yaml"
+	moved.SetAnnotations(annotations)
+	if err := v.AddConstraint(ctx, moved); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := status(v).Fingerprint; got != loaded.Fingerprint {
+		t.Errorf("got fingerprint %s after re-adding the constraint, want %s", got, loaded.Fingerprint)
+	}
+
+	pb := loaded.ToProto()
+	if pb.Fingerprint != loaded.Fingerprint || len(pb.Targets) != len(loaded.Targets) {
+		t.Errorf("proto %v does not match status %+v", pb, loaded)
+	}
+}
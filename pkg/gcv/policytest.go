@@ -0,0 +1,156 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/open-policy-agent/opa/tester"
+)
+
+// PolicyTestResult is the outcome of a rego test rule.
+type PolicyTestResult struct {
+	// Path is the file the test is in.
+	Path string
+	// Package is the package of the test, eg data.validator.gcp.lib.
+	Package string
+	// Name is the name of the test rule.
+	Name string
+	// Fail is true if the test rule is not true.
+	Fail bool
+	// Skip is true for todo_ tests, which are not run.
+	Skip bool
+	// Error is the error that stopped the test from being evaluated.
+	Error error
+	// Duration is how long the test took.
+	Duration time.Duration
+}
+
+// Pass returns true if the test was evaluated and passed.
+func (r *PolicyTestResult) Pass() bool {
+	return !r.Fail && !r.Skip && r.Error == nil
+}
+
+// String implements fmt.Stringer.
+func (r *PolicyTestResult) String() string {
+	outcome := "PASS"
+	switch {
+	case r.Error != nil:
+		outcome = "ERROR"
+	case r.Fail:
+		outcome = "FAIL"
+	case r.Skip:
+		outcome = "SKIPPED"
+	}
+	s := fmt.Sprintf("%s: %s: %s.%s (%v)", r.Path, outcome, r.Package, r.Name, r.Duration)
+	if r.Error != nil {
+		s += ": " + r.Error.Error()
+	}
+	return s
+}
+
+// PolicyTestReport is the result of running rego unit tests with RunPolicyTests.
+type PolicyTestReport struct {
+	// Results are the results of each test, sorted by path.
+	Results []*PolicyTestResult
+}
+
+// Failed returns true if any of the tests failed or could not be evaluated.
+func (r *PolicyTestReport) Failed() bool {
+	for _, result := range r.Results {
+		if result.Fail || result.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// RunPolicyTests runs the rego unit tests, in files ending with configs.RegoTestSuffix, found in the
+// policy library and policy paths.  The tests are compiled with the rest of the rego files in those
+// paths and with the same builtins as the validator, so builtins disabled with DisableBuiltins fail
+// compilation.  JSON files are loaded as data, see configs.ReadPolicyTestFiles.  Template rego that
+// is only inlined in ConstraintTemplates is not compiled, tests of templates must be next to the
+// template's rego source.  An error is returned if the files can't be read or compiled, failing tests
+// are reported in the results.
+func RunPolicyTests(policyPaths []string, policyLibraryPath string, opts ...Option) (*PolicyTestReport, error) {
+	if len(policyPaths) == 0 {
+		return nil, fmt.Errorf("No policy path set")
+	}
+	policyPaths, libPaths := configs.ResolveBundles(policyPaths, policyLibraryPath)
+	if len(libPaths) == 0 {
+		return nil, fmt.Errorf("No policy library set")
+	}
+	files, err := configs.ReadPolicyTestFiles(append(libPaths, policyPaths...))
+	if err != nil {
+		return nil, err
+	}
+	report := &PolicyTestReport{}
+	if !files.HasTests() {
+		return report, nil
+	}
+
+	modules := map[string]*ast.Module{}
+	for path, content := range files.Modules {
+		module, err := ast.ParseModule(path, content)
+		if err != nil {
+			return nil, err
+		}
+		modules[path] = module
+	}
+
+	capabilities := ast.CapabilitiesForThisVersion()
+	if pinned := newInitOptions(opts...).regoCapabilities; pinned != nil {
+		copied := *pinned
+		capabilities = &copied
+	}
+	disabled := map[string]bool{}
+	for _, builtin := range newInitOptions(opts...).disabledBuiltins {
+		disabled[builtin] = true
+	}
+	var builtins []*ast.Builtin
+	for _, builtin := range capabilities.Builtins {
+		if !disabled[builtin.Name] {
+			builtins = append(builtins, builtin)
+		}
+	}
+	capabilities.Builtins = builtins
+
+	ctx := context.Background()
+	runner := tester.NewRunner().
+		SetCompiler(ast.NewCompiler().WithCapabilities(capabilities).WithEnablePrintStatements(true)).
+		SetStore(inmem.NewFromObject(files.Data)).
+		SetModules(modules)
+	ch, err := runner.RunTests(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile policy tests: %w", err)
+	}
+	for result := range ch {
+		report.Results = append(report.Results, &PolicyTestResult{
+			Path:     result.Location.File,
+			Package:  result.Package,
+			Name:     result.Name,
+			Fail:     result.Fail,
+			Skip:     result.Skip,
+			Error:    result.Error,
+			Duration: result.Duration,
+		})
+	}
+	return report, nil
+}
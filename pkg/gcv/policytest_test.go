@@ -0,0 +1,121 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const policyTestLib = `package validator.gcp.lib
+
+has_logging(bucket) {
+	bucket.logging
+}
+`
+
+const policyTestLibTest = `package validator.gcp.lib
+
+test_has_logging {
+	has_logging(data.fixtures.buckets.with_logging)
+}
+
+test_no_logging {
+	not has_logging(data.fixtures.buckets.no_logging)
+}
+
+test_wrong {
+	has_logging(data.fixtures.buckets.no_logging)
+}
+
+todo_test_later {
+	false
+}
+`
+
+const policyTestTimeTest = `package validator.gcp.lib
+
+test_now {
+	time.now_ns() > 0
+}
+`
+
+func writePolicyTestFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunPolicyTests(t *testing.T) {
+	libDir := writePolicyTestFiles(t, map[string]string{
+		"lib.rego":                           policyTestLib,
+		"lib_test.rego":                      policyTestLibTest,
+		"fixtures/buckets/data.json":         `{"with_logging": {"logging": true}}`,
+		"fixtures/buckets/no_logging/x.json": `{"name": "bucket"}`,
+	})
+	policyDir := writePolicyTestFiles(t, map[string]string{"time_test.rego": policyTestTimeTest})
+
+	report, err := RunPolicyTests([]string{policyDir}, libDir)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	type outcome struct {
+		File, Name       string
+		Pass, Fail, Skip bool
+	}
+	var got []outcome
+	for _, result := range report.Results {
+		got = append(got, outcome{filepath.Base(result.Path), result.Name, result.Pass(), result.Fail, result.Skip})
+	}
+	want := []outcome{
+		{File: "lib_test.rego", Name: "test_has_logging", Pass: true},
+		{File: "lib_test.rego", Name: "test_no_logging", Pass: true},
+		{File: "lib_test.rego", Name: "test_wrong", Fail: true},
+		{File: "lib_test.rego", Name: "todo_test_later", Skip: true},
+		{File: "time_test.rego", Name: "test_now", Pass: true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("results diff (-want +got):\n%s", diff)
+	}
+	if !report.Failed() {
+		t.Errorf("expected report to fail")
+	}
+
+	if _, err := RunPolicyTests([]string{policyDir}, libDir, DisableBuiltins("time.now_ns")); err == nil {
+		t.Errorf("expected compilation error for disabled builtin")
+	}
+}
+
+func TestRunPolicyTestsNoTests(t *testing.T) {
+	report, err := RunPolicyTests(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(report.Results) != 0 || report.Failed() {
+		t.Errorf("got results %v, want none", report.Results)
+	}
+}
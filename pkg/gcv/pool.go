@@ -0,0 +1,306 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/golang/glog"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ErrInvalidBundleName is returned by DirBundleLoader for bundle names that are not a single path
+// element.
+var ErrInvalidBundleName = errors.New("invalid bundle name")
+
+// BundleLoader loads the policies of a bundle by name.
+type BundleLoader func(ctx context.Context, bundle string) (*configs.Configuration, error)
+
+// DirBundleLoader loads each bundle from the directory of the same name under root, eg a policy
+// bundle with policies/ and lib/ directories.  libDir is the library of bundles without their own,
+// it may be empty if every bundle has one.
+func DirBundleLoader(root, libDir string) BundleLoader {
+	return func(ctx context.Context, bundle string) (*configs.Configuration, error) {
+		if bundle == "" || bundle == "." || bundle == ".." || strings.ContainsAny(bundle, `/\`) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidBundleName, bundle)
+		}
+		return NewValidatorConfig([]string{filepath.Join(root, bundle)}, libDir)
+	}
+}
+
+// PoolOption configures a Pool.
+type PoolOption func(*Pool)
+
+// PoolMaxValidators sets the number of Validators the pool keeps built, zero is unlimited.
+func PoolMaxValidators(n int) PoolOption {
+	return func(p *Pool) {
+		p.maxValidators = n
+	}
+}
+
+// PoolMaxBytes sets the total estimated size of the Validators the pool keeps built, see PoolSizer,
+// zero is unlimited.
+func PoolMaxBytes(n int64) PoolOption {
+	return func(p *Pool) {
+		p.maxBytes = n
+	}
+}
+
+// PoolSizer sets the function that estimates the memory used by the Validator built from a bundle.
+// The default is EstimateSize.
+func PoolSizer(sizer func(*configs.Configuration) int64) PoolOption {
+	return func(p *Pool) {
+		p.sizer = sizer
+	}
+}
+
+// PoolValidatorOptions sets the options each Validator in the pool is built with.
+func PoolValidatorOptions(opts ...Option) PoolOption {
+	return func(p *Pool) {
+		p.validatorOpts = opts
+	}
+}
+
+// poolEntry is a bundle in the pool.  The loaded configuration is kept for the life of the pool,
+// the Validator is built from it on demand and dropped on eviction.
+type poolEntry struct {
+	bundle string
+	config *configs.Configuration
+	size   int64
+	// validator is nil while evicted.
+	validator *Validator
+	// elem is the entry's element in the LRU list, nil while evicted.
+	elem *list.Element
+	// building is closed once a build started by Get completes, nil when no build is in progress.
+	building chan struct{}
+	err      error
+}
+
+// Pool serves Validators for many independent policy bundles.  Each bundle has its own Validator so
+// that the policies of one bundle never apply to another.  Bundles are loaded with the BundleLoader
+// the first time they are requested and their configuration is cached.  The Validators of the least
+// recently used bundles are evicted once the pool exceeds its limits, and rebuilt from the cached
+// configuration, without reading the bundle again, the next time they are requested.
+//
+// Evicting a Validator only removes it from the pool, callers holding it can keep using it.
+type Pool struct {
+	load          BundleLoader
+	maxValidators int
+	maxBytes      int64
+	sizer         func(*configs.Configuration) int64
+	validatorOpts []Option
+
+	mtx     sync.Mutex
+	entries map[string]*poolEntry
+	// lru holds the entries with a built Validator, most recently used first.
+	lru *list.List
+	// size is the total estimated size of the built Validators.
+	size int64
+}
+
+// NewPool returns a Pool that loads bundles with load.
+func NewPool(load BundleLoader, opts ...PoolOption) *Pool {
+	p := &Pool{
+		load:    load,
+		sizer:   EstimateSize,
+		entries: map[string]*poolEntry{},
+		lru:     list.New(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Get returns the Validator of the bundle, loading the bundle or building its Validator if needed.
+// Concurrent calls for the same bundle share a single build.
+func (p *Pool) Get(ctx context.Context, bundle string) (*Validator, error) {
+	for {
+		p.mtx.Lock()
+		entry, ok := p.entries[bundle]
+		if !ok {
+			entry = &poolEntry{bundle: bundle}
+			p.entries[bundle] = entry
+		}
+		if entry.validator != nil {
+			p.lru.MoveToFront(entry.elem)
+			p.mtx.Unlock()
+			return entry.validator, nil
+		}
+		if building := entry.building; building != nil {
+			p.mtx.Unlock()
+			select {
+			case <-building:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			p.mtx.Lock()
+			v, err := entry.validator, entry.err
+			p.mtx.Unlock()
+			if v != nil {
+				return v, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			// Evicted again before this caller could use it.
+			continue
+		}
+		entry.building = make(chan struct{})
+		config := entry.config
+		p.mtx.Unlock()
+
+		return p.build(ctx, entry, config)
+	}
+}
+
+// build loads the bundle, unless its configuration is cached, and builds its Validator.
+func (p *Pool) build(ctx context.Context, entry *poolEntry, config *configs.Configuration) (*Validator, error) {
+	size := entry.size
+	var err error
+	if config == nil {
+		glog.V(1).Infof("loading policy bundle %s", entry.bundle)
+		config, err = p.load(ctx, entry.bundle)
+		if err == nil {
+			size = p.sizer(config)
+		}
+	}
+	var v *Validator
+	if err == nil {
+		glog.V(1).Infof("building validator for policy bundle %s", entry.bundle)
+		v, err = NewValidatorFromConfig(cloneConfig(config), p.validatorOpts...)
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	close(entry.building)
+	entry.building = nil
+	if err != nil {
+		entry.err = fmt.Errorf("policy bundle %s: %w", entry.bundle, err)
+		// Failed bundles are not cached, the next Get loads them again.
+		if p.entries[entry.bundle] == entry {
+			delete(p.entries, entry.bundle)
+		}
+		return nil, entry.err
+	}
+	if p.entries[entry.bundle] != entry {
+		// Invalidated while building, the caller gets the Validator but the pool doesn't keep it.
+		return v, nil
+	}
+	entry.config = config
+	entry.size = size
+	entry.validator = v
+	entry.elem = p.lru.PushFront(entry)
+	p.size += size
+	p.evict()
+	return v, nil
+}
+
+// evict drops the Validators of the least recently used bundles until the pool is within its limits.
+// The most recently used Validator is always kept.
+func (p *Pool) evict() {
+	for p.lru.Len() > 1 && p.overLimit() {
+		entry := p.lru.Remove(p.lru.Back()).(*poolEntry)
+		glog.V(1).Infof("evicting validator for policy bundle %s", entry.bundle)
+		entry.validator = nil
+		entry.elem = nil
+		p.size -= entry.size
+	}
+}
+
+func (p *Pool) overLimit() bool {
+	return (p.maxValidators > 0 && p.lru.Len() > p.maxValidators) || (p.maxBytes > 0 && p.size > p.maxBytes)
+}
+
+// Invalidate drops the bundle's Validator and cached configuration, the next Get loads the bundle
+// again.  Use it when a bundle's policies have changed.
+func (p *Pool) Invalidate(bundle string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	entry, ok := p.entries[bundle]
+	if !ok {
+		return
+	}
+	if entry.elem != nil {
+		p.lru.Remove(entry.elem)
+		p.size -= entry.size
+	}
+	delete(p.entries, bundle)
+}
+
+// Len returns the number of Validators the pool keeps built.
+func (p *Pool) Len() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.lru.Len()
+}
+
+// Size returns the total estimated size of the Validators the pool keeps built.
+func (p *Pool) Size() int64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.size
+}
+
+// EstimateSize estimates the memory used by a Validator built from config as the size of its rego
+// and constraints.  The compiled policies take a multiple of their source size, so the estimate is
+// for comparing bundles rather than an absolute measure.
+func EstimateSize(config *configs.Configuration) int64 {
+	var size int64
+	for _, t := range config.Templates() {
+		for _, target := range t.Spec.Targets {
+			size += int64(len(target.Rego))
+			for _, lib := range target.Libs {
+				size += int64(len(lib))
+			}
+		}
+	}
+	for _, c := range config.Constraints() {
+		if buf, err := json.Marshal(c.Object); err == nil {
+			size += int64(len(buf))
+		}
+	}
+	return size
+}
+
+// cloneConfig returns a copy of config whose template and constraint lists can be changed by the
+// Validator, eg with AddConstraint, without changing the cached configuration.
+func cloneConfig(config *configs.Configuration) *configs.Configuration {
+	c := *config
+	c.GCPTemplates = append([]*cftemplates.ConstraintTemplate(nil), config.GCPTemplates...)
+	c.K8STemplates = append([]*cftemplates.ConstraintTemplate(nil), config.K8STemplates...)
+	c.TFTemplates = append([]*cftemplates.ConstraintTemplate(nil), config.TFTemplates...)
+	c.GCPConstraints = append([]*unstructured.Unstructured(nil), config.GCPConstraints...)
+	c.K8SConstraints = append([]*unstructured.Unstructured(nil), config.K8SConstraints...)
+	c.TFConstraints = append([]*unstructured.Unstructured(nil), config.TFConstraints...)
+	c.CustomTemplates = map[string][]*cftemplates.ConstraintTemplate{}
+	for name, templates := range config.CustomTemplates {
+		c.CustomTemplates[name] = append([]*cftemplates.ConstraintTemplate(nil), templates...)
+	}
+	c.CustomConstraints = map[string][]*unstructured.Unstructured{}
+	for name, constraints := range config.CustomConstraints {
+		c.CustomConstraints[name] = append([]*unstructured.Unstructured(nil), constraints...)
+	}
+	return &c
+}
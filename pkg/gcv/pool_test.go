@@ -0,0 +1,224 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+)
+
+// testBundles are the policies of the bundles served by testBundleLoader.
+var testBundles = map[string][]*configs.PolicyFile{
+	"vpcsc": {
+		{Path: "template.yaml", Content: []byte(restrictedServicesTemplate)},
+		{Path: "constraint.yaml", Content: []byte(restrictedServicesConstraint)},
+	},
+	"iam": {
+		{Path: "template.yaml", Content: []byte(inheritedOwnerTemplate)},
+		{Path: "constraint.yaml", Content: []byte(inheritedOwnerConstraint)},
+	},
+	"terraform": {
+		{Path: "template.yaml", Content: []byte(approvedModuleTemplate)},
+		{Path: "constraint.yaml", Content: []byte(approvedModuleConstraint)},
+	},
+}
+
+// testBundleLoader loads testBundles and counts the loads of each bundle.
+type testBundleLoader struct {
+	mtx   sync.Mutex
+	loads map[string]int
+}
+
+func (l *testBundleLoader) load(ctx context.Context, bundle string) (*configs.Configuration, error) {
+	l.mtx.Lock()
+	if l.loads == nil {
+		l.loads = map[string]int{}
+	}
+	l.loads[bundle]++
+	l.mtx.Unlock()
+	files, ok := testBundles[bundle]
+	if !ok {
+		return nil, errors.New("no such bundle")
+	}
+	objects, err := configs.LoadUnstructuredFromContents(files)
+	if err != nil {
+		return nil, err
+	}
+	return configs.NewConfigurationFromContents(objects, []string{"package validator.gcp.lib\n"})
+}
+
+func (l *testBundleLoader) count(bundle string) int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.loads[bundle]
+}
+
+func TestPoolIsolation(t *testing.T) {
+	ctx := context.Background()
+	pool := NewPool((&testBundleLoader{}).load)
+	for bundle, want := range map[string]int{"vpcsc": 1, "iam": 0} {
+		v, err := pool.Get(ctx, bundle)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", bundle, err)
+		}
+		result, err := v.ReviewJSON(ctx, servicePerimeterJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(result.ConstraintViolations); got != want {
+			t.Errorf("bundle %s: got %d violations, want %d", bundle, got, want)
+		}
+	}
+}
+
+func TestPoolEviction(t *testing.T) {
+	ctx := context.Background()
+	loader := &testBundleLoader{}
+	pool := NewPool(loader.load, PoolMaxValidators(2))
+
+	first, err := pool.Get(ctx, "vpcsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bundle := range []string{"iam", "vpcsc", "terraform"} {
+		if _, err := pool.Get(ctx, bundle); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// vpcsc was used more recently than iam, so iam was evicted.
+	if got := pool.Len(); got != 2 {
+		t.Errorf("got %d validators, want 2", got)
+	}
+	if again, _ := pool.Get(ctx, "vpcsc"); again != first {
+		t.Errorf("recently used validator was evicted")
+	}
+
+	rebuilt, err := pool.Get(ctx, "iam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt == nil {
+		t.Fatal("got nil validator")
+	}
+	if got := loader.count("iam"); got != 1 {
+		t.Errorf("iam loaded %d times, want 1, evicted validators are rebuilt from the cached bundle", got)
+	}
+}
+
+func TestPoolMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	pool := NewPool((&testBundleLoader{}).load,
+		PoolSizer(func(*configs.Configuration) int64 { return 10 }),
+		PoolMaxBytes(25),
+	)
+	for _, bundle := range []string{"vpcsc", "iam", "terraform"} {
+		if _, err := pool.Get(ctx, bundle); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := pool.Len(); got != 2 {
+		t.Errorf("got %d validators, want 2", got)
+	}
+	if got := pool.Size(); got != 20 {
+		t.Errorf("got size %d, want 20", got)
+	}
+}
+
+func TestPoolConcurrentGet(t *testing.T) {
+	ctx := context.Background()
+	loader := &testBundleLoader{}
+	pool := NewPool(loader.load)
+
+	const callers = 8
+	validators := make([]*Validator, callers)
+	var wg sync.WaitGroup
+	for idx := 0; idx < callers; idx++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			v, err := pool.Get(ctx, "vpcsc")
+			if err != nil {
+				t.Error(err)
+			}
+			validators[idx] = v
+		}(idx)
+	}
+	wg.Wait()
+	for _, v := range validators {
+		if v != validators[0] {
+			t.Errorf("concurrent Get returned different validators")
+		}
+	}
+	if got := loader.count("vpcsc"); got != 1 {
+		t.Errorf("vpcsc loaded %d times, want 1", got)
+	}
+}
+
+func TestPoolInvalidate(t *testing.T) {
+	ctx := context.Background()
+	loader := &testBundleLoader{}
+	pool := NewPool(loader.load)
+	if _, err := pool.Get(ctx, "vpcsc"); err != nil {
+		t.Fatal(err)
+	}
+	pool.Invalidate("vpcsc")
+	if got := pool.Len(); got != 0 {
+		t.Errorf("got %d validators after Invalidate, want 0", got)
+	}
+	if _, err := pool.Get(ctx, "vpcsc"); err != nil {
+		t.Fatal(err)
+	}
+	if got := loader.count("vpcsc"); got != 2 {
+		t.Errorf("vpcsc loaded %d times, want 2", got)
+	}
+}
+
+func TestPoolLoadError(t *testing.T) {
+	ctx := context.Background()
+	loader := &testBundleLoader{}
+	pool := NewPool(loader.load)
+	for i := 0; i < 2; i++ {
+		if _, err := pool.Get(ctx, "missing"); err == nil {
+			t.Fatal("expected error for missing bundle")
+		}
+	}
+	if got := loader.count("missing"); got != 2 {
+		t.Errorf("missing bundle loaded %d times, want 2, failures are not cached", got)
+	}
+}
+
+func TestDirBundleLoader(t *testing.T) {
+	load := DirBundleLoader(t.TempDir(), "")
+	for _, bundle := range []string{"", ".", "..", "a/b", `a\b`} {
+		if _, err := load(context.Background(), bundle); !errors.Is(err, ErrInvalidBundleName) {
+			t.Errorf("load(%q) = %v, want %v", bundle, err, ErrInvalidBundleName)
+		}
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	loader := &testBundleLoader{}
+	small, err := loader.load(context.Background(), "iam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if EstimateSize(small) <= int64(len(inheritedOwnerConstraint)) {
+		t.Errorf("EstimateSize() = %d, want more than the constraint size", EstimateSize(small))
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/storage"
+	asset2 "github.com/GoogleCloudPlatform/config-validator/pkg/asset"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	"github.com/golang/glog"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/core/constraints"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// defaultPreviewSampleSize is the number of matched assets returned by PreviewMatch by default.
+const defaultPreviewSampleSize = 10
+
+// MatchPreview is the result of PreviewMatch.
+type MatchPreview struct {
+	// Constraint is the previewed constraint, as "[Kind].[Name]".
+	Constraint string
+	// Assets is the number of assets read from the corpus.
+	Assets int
+	// Matched is the number of assets selected by the constraint's spec.match.
+	Matched int
+	// Skipped is the number of assets that would not be reviewed against GCP constraints, such as
+	// Kubernetes resources and assets without ancestry or data.
+	Skipped int
+	// MalformedLines is the number of lines in the corpus that could not be parsed.
+	MalformedLines int
+	// Sample is the first of the matched assets, in corpus order.
+	Sample []*MatchedAsset
+}
+
+// MatchedAsset is an asset selected by a constraint's spec.match.
+type MatchedAsset struct {
+	Name         string
+	AssetType    string
+	AncestryPath string
+}
+
+// PreviewOption configures PreviewMatch.
+type PreviewOption func(*matchPreview)
+
+// PreviewSampleSize sets the number of matched assets returned, the default is 10.
+func PreviewSampleSize(n int) PreviewOption {
+	return func(p *matchPreview) {
+		if n >= 0 {
+			p.sampleSize = n
+		}
+	}
+}
+
+// PreviewStorageClient sets the GCS client used to read the corpus, by default a client is created
+// with the application default credentials.
+func PreviewStorageClient(client *storage.Client) PreviewOption {
+	return func(p *matchPreview) {
+		p.scan.client = client
+	}
+}
+
+// matchPreview holds the state of a PreviewMatch call.
+type matchPreview struct {
+	sampleSize int
+	scan       exportScan
+	matcher    constraints.Matcher
+	preview    *MatchPreview
+}
+
+// PreviewMatch returns the assets of a corpus that the GCP constraint's spec.match selects, before
+// any rego is evaluated, so that the scope of a constraint can be checked independently of its
+// policy.  The corpus is a CAI export as read by ReviewCAIExport.  Assets are prepared as they would
+// be for review, so names and ancestry paths are normalized and project numbers applied.  The
+// constraint does not need to be loaded in the Validator and its template is not required.
+func (v *Validator) PreviewMatch(ctx context.Context, constraint *unstructured.Unstructured, uri string, opts ...PreviewOption) (*MatchPreview, error) {
+	target := gcptarget.New()
+	if err := target.ValidateConstraint(constraint); err != nil {
+		return nil, fmt.Errorf("invalid constraint %s: %w", constraint.GetName(), err)
+	}
+	matcher, err := target.ToMatcher(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec.match in constraint %s: %w", constraint.GetName(), err)
+	}
+	p := &matchPreview{
+		sampleSize: defaultPreviewSampleSize,
+		matcher:    matcher,
+		preview:    &MatchPreview{Constraint: fmt.Sprintf("%s.%s", constraint.GetKind(), originalName(constraint))},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	source, err := p.scan.source(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := source.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no export objects found at %s", uri)
+	}
+	for _, object := range objects {
+		r, err := source.open(ctx, object)
+		if err != nil {
+			return p.preview, fmt.Errorf("failed to open %s: %w", object, err)
+		}
+		report, err := p.scan.reader.Read(object, r, func(asset map[string]interface{}) error {
+			p.match(v, asset)
+			return ctx.Err()
+		})
+		r.Close()
+		p.preview.MalformedLines += len(report.Errors)
+		if err != nil {
+			return p.preview, err
+		}
+	}
+	return p.preview, nil
+}
+
+// match prepares the asset as ReviewUnmarshalledJSON would and adds it to the preview if the
+// constraint matches it.
+func (p *matchPreview) match(v *Validator, asset map[string]interface{}) {
+	p.preview.Assets++
+	if asset2.IsK8S(asset) {
+		p.preview.Skipped++
+		return
+	}
+	v.normalizeName(asset)
+	if err := v.fixAncestry(asset); err != nil {
+		glog.V(2).Infof("skipping asset %v: %v", asset["name"], err)
+		p.preview.Skipped++
+		return
+	}
+	handled, review, err := gcptarget.New().HandleReview(asset)
+	if err != nil || !handled {
+		glog.V(2).Infof("skipping asset %v: not a reviewable GCP asset: %v", asset["name"], err)
+		p.preview.Skipped++
+		return
+	}
+	matched, err := p.matcher.Match(review)
+	if err != nil {
+		glog.V(2).Infof("skipping asset %v: %v", asset["name"], err)
+		p.preview.Skipped++
+		return
+	}
+	if !matched {
+		return
+	}
+	p.preview.Matched++
+	if len(p.preview.Sample) < p.sampleSize {
+		name, _ := asset["name"].(string)
+		assetType, _ := asset["asset_type"].(string)
+		ancestryPath, _ := asset[ancestryPathKey].(string)
+		p.preview.Sample = append(p.preview.Sample, &MatchedAsset{
+			Name:         name,
+			AssetType:    assetType,
+			AncestryPath: ancestryPath,
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func previewConstraint(match map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1beta1",
+		"kind":       "GCPAnyConstraintV1",
+		"metadata":   map[string]interface{}{"name": "preview"},
+		"spec":       map[string]interface{}{"match": match},
+	}}
+}
+
+func TestPreviewMatch(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	dir := t.TempDir()
+	writeExportShard(t, filepath.Join(dir, "resource-0.json"), []string{
+		storageAssetNoLoggingJSON,
+		storageAssetWithLoggingJSON,
+		resourceAssetJSON,
+		namespaceAssetWithNoLabelJSON,
+	}, false)
+	writeExportShard(t, filepath.Join(dir, "resource-1.json.gz"), []string{storageAssetWithSecureLoggingJSON}, true)
+
+	testCases := []struct {
+		name  string
+		match map[string]interface{}
+		opts  []PreviewOption
+		want  *MatchPreview
+	}{
+		{
+			name: "ancestries and asset types",
+			match: map[string]interface{}{
+				"ancestries": []interface{}{"organizations/1/**"},
+				"assetTypes": []interface{}{"storage.googleapis.com/*"},
+			},
+			opts: []PreviewOption{PreviewSampleSize(2)},
+			want: &MatchPreview{
+				Constraint: "GCPAnyConstraintV1.preview",
+				Assets:     5,
+				Matched:    3,
+				Skipped:    1,
+				Sample: []*MatchedAsset{
+					{
+						Name:         "//storage.googleapis.com/my-storage-bucket",
+						AssetType:    "storage.googleapis.com/Bucket",
+						AncestryPath: "organizations/1/folders/2/projects/3",
+					},
+					{
+						Name:         "//storage.googleapis.com/my-storage-bucket-with-logging",
+						AssetType:    "storage.googleapis.com/Bucket",
+						AncestryPath: "organizations/1/folders/2/projects/3",
+					},
+				},
+			},
+		},
+		{
+			name: "excluded ancestries",
+			match: map[string]interface{}{
+				"excludedAncestries": []interface{}{"organizations/1/**"},
+			},
+			want: &MatchPreview{
+				Constraint: "GCPAnyConstraintV1.preview",
+				Assets:     5,
+				Matched:    1,
+				Skipped:    1,
+				Sample: []*MatchedAsset{{
+					Name:         "//bigquery.googleapis.com/projects/123/datasets/test-dataset",
+					AssetType:    "bigquery.googleapis.com/Dataset",
+					AncestryPath: "organizations/12331/folders/2323/projects/123",
+				}},
+			},
+		},
+		{
+			name:  "no sample",
+			match: map[string]interface{}{},
+			opts:  []PreviewOption{PreviewSampleSize(0)},
+			want: &MatchPreview{
+				Constraint: "GCPAnyConstraintV1.preview",
+				Assets:     5,
+				Matched:    4,
+				Skipped:    1,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := v.PreviewMatch(context.Background(), previewConstraint(tc.match), dir, tc.opts...)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("preview diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPreviewMatchInvalidConstraint(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	constraint := previewConstraint(map[string]interface{}{"ancestries": []interface{}{"organizations/**/x/***"}})
+	if _, err := v.PreviewMatch(context.Background(), constraint, t.TempDir()); err == nil {
+		t.Errorf("expected error for invalid ancestries glob")
+	}
+}
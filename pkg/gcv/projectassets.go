@@ -0,0 +1,118 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
+	crm "google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxFolderDepth bounds the folders walked to resolve a project's ancestry, Resource Manager allows
+// folders to be nested 10 deep.
+const maxFolderDepth = 20
+
+// ProjectOption configures ReviewProjectAssets.
+type ProjectOption func(*projectReview)
+
+// ProjectResourceManager sets the Resource Manager client used to resolve the project's ancestry, by
+// default a client is created with the application default credentials.
+func ProjectResourceManager(service *crm.Service) ProjectOption {
+	return func(r *projectReview) {
+		r.service = service
+	}
+}
+
+// projectReview holds the state of a ReviewProjectAssets call.
+type projectReview struct {
+	service *crm.Service
+}
+
+// ReviewProjectAssets reviews assets of a single project, eg from a project scoped CAI export or
+// inventory tool that does not record ancestry.  The ancestry of the project, as project, folder and
+// organization numbers, is resolved once with Resource Manager and set on every asset that has neither
+// an ancestry path nor ancestors, instead of failing their review with missing ancestry information.
+// Assets with ancestry are reviewed as is, and the assets are not modified.  The project is given by
+// ID or number, with or without the "projects/" prefix.  Assets that fail to be reviewed are reported
+// together in the error, the violations of the other assets are still returned.
+func (v *Validator) ReviewProjectAssets(ctx context.Context, projectID string, assets []*validator.Asset, opts ...ProjectOption) ([]*validator.Violation, error) {
+	r := &projectReview{}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	var ancestors []string
+	var violations []*validator.Violation
+	var errs multierror.Errors
+	for idx, asset := range assets {
+		if asset.GetAncestryPath() == "" && len(asset.GetAncestors()) == 0 {
+			if ancestors == nil {
+				var err error
+				if ancestors, err = r.ancestors(ctx, projectID); err != nil {
+					return nil, err
+				}
+			}
+			asset = proto.Clone(asset).(*validator.Asset)
+			asset.Ancestors = ancestors
+		}
+		assetViolations, err := v.ReviewAsset(ctx, asset)
+		if err != nil {
+			errs.Add(fmt.Errorf("assets[%d] %s: %w", idx, asset.GetName(), err))
+			continue
+		}
+		violations = append(violations, assetViolations...)
+	}
+	return violations, errs.ToError()
+}
+
+// ancestors returns the ancestors of the project, starting with the project itself, in the format of
+// validator.Asset.Ancestors, eg ["projects/3", "folders/2", "organizations/1"].
+func (r *projectReview) ancestors(ctx context.Context, projectID string) ([]string, error) {
+	if r.service == nil {
+		service, err := crm.NewService(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create resource manager client: %w", err)
+		}
+		r.service = service
+	}
+
+	name := "projects/" + strings.TrimPrefix(projectID, "projects/")
+	project, err := r.service.Projects.Get(name).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project %s: %w", name, err)
+	}
+	ancestors := []string{project.Name}
+	parent := project.Parent
+	for depth := 0; strings.HasPrefix(parent, "folders/"); depth++ {
+		if depth == maxFolderDepth {
+			return nil, fmt.Errorf("ancestry of project %s is deeper than %d folders", name, maxFolderDepth)
+		}
+		ancestors = append(ancestors, parent)
+		folder, err := r.service.Folders.Get(parent).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get folder %s of project %s: %w", parent, name, err)
+		}
+		parent = folder.Parent
+	}
+	if parent != "" {
+		ancestors = append(ancestors, parent)
+	}
+	return ancestors, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	crm "google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/api/option"
+)
+
+// newFakeResourceManager returns a Resource Manager client of a server with the project
+// projects/3 in folders/2 of organizations/1, which counts the project lookups.
+func newFakeResourceManager(t *testing.T, lookups *int) *crm.Service {
+	t.Helper()
+	resources := map[string]interface{}{
+		"/v3/projects/my-project": &crm.Project{Name: "projects/3", ProjectId: "my-project", Parent: "folders/2"},
+		"/v3/folders/2":           &crm.Folder{Name: "folders/2", Parent: "organizations/1"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v3/projects/") {
+			*lookups++
+		}
+		resource, ok := resources[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(resource)
+	}))
+	t.Cleanup(server.Close)
+	service, err := crm.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func TestReviewProjectAssets(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	var lookups int
+	service := newFakeResourceManager(t, &lookups)
+
+	var assets []*validator.Asset
+	for i := 0; i < 3; i++ {
+		asset := storageAssetNoLogging()
+		asset.AncestryPath, asset.Ancestors = "", nil
+		assets = append(assets, asset)
+	}
+	violations, err := v.ReviewProjectAssets(context.Background(), "my-project", assets, ProjectResourceManager(service))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 6 {
+		t.Errorf("got %d violations, want 6", len(violations))
+	}
+	for _, violation := range violations {
+		got := violation.GetMetadata().GetStructValue().GetFields()["ancestry_path"].GetStringValue()
+		if want := "organizations/1/folders/2/projects/3"; got != want {
+			t.Errorf("got ancestry path %q, want %q", got, want)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("got %d project lookups, want 1", lookups)
+	}
+	for _, asset := range assets {
+		if len(asset.Ancestors) != 0 {
+			t.Errorf("asset %s was modified", asset.Name)
+		}
+	}
+}
+
+func TestReviewProjectAssetsWithAncestry(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	var lookups int
+	service := newFakeResourceManager(t, &lookups)
+
+	violations, err := v.ReviewProjectAssets(context.Background(), "my-project", []*validator.Asset{storageAssetNoLogging()}, ProjectResourceManager(service))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 2 {
+		t.Errorf("got %d violations, want 2", len(violations))
+	}
+	if lookups != 0 {
+		t.Errorf("got %d project lookups for assets with ancestry, want 0", lookups)
+	}
+}
+
+func TestReviewProjectAssetsUnknownProject(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	var lookups int
+	service := newFakeResourceManager(t, &lookups)
+
+	asset := storageAssetNoLogging()
+	asset.AncestryPath, asset.Ancestors = "", nil
+	_, err = v.ReviewProjectAssets(context.Background(), "projects/other", []*validator.Asset{asset}, ProjectResourceManager(service))
+	if err == nil || !strings.Contains(err.Error(), "failed to get project projects/other") {
+		t.Errorf("got error %v, want failed to get project", err)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/client/drivers/rego/schema"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// templateModule is the module of a RegoError in the template's own rego.
+const templateModule = "template"
+
+// RegoError locates an error in the rego of a template that failed to compile.
+type RegoError struct {
+	// Module is "template" for the template's rego, or lib_<n> for the nth library of its target.
+	Module string
+	// Row is the line of the error in the module, starting at 1.
+	Row int
+	// Col is the column of the error in the row, starting at 1, or zero if it is not known.
+	Col int
+	// Code is the OPA error code, eg rego_type_error.
+	Code string
+	// Message is the error message.
+	Message string
+}
+
+// String returns the error as module:row:col: code: message, without the column if it is not known.
+func (e *RegoError) String() string {
+	location := fmt.Sprintf("%s:%d", e.Module, e.Row)
+	if e.Col != 0 {
+		location += fmt.Sprintf(":%d", e.Col)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, e.Code, e.Message)
+}
+
+// ToProto converts the error to its proto representation.
+func (e *RegoError) ToProto() *validator.RegoError {
+	return &validator.RegoError{
+		Module:  e.Module,
+		Row:     int32(e.Row),
+		Col:     int32(e.Col),
+		Code:    e.Code,
+		Message: e.Message,
+	}
+}
+
+// regoErrorPattern matches the OPA errors in the error of a CF client that failed to add a template,
+// which only has them as text, eg
+// "unable to compile modules: 1 error occurred: template:4: rego_type_error: undefined function f".
+// Libraries are named libs["lib_<n>"].
+var regoErrorPattern = regexp.MustCompile(`(template|libs\["lib_\d+"\]):(\d+): (rego_[a-z_]+): ([^\n]*)`)
+
+// libModulePattern matches the module name of a library in regoErrorPattern.
+var libModulePattern = regexp.MustCompile(`lib_\d+`)
+
+// regoErrors returns the rego errors in the error of a CF client that failed to add the template to
+// the target, nil if it is not a rego error.  The CF client reports the line of each error but not
+// its column, which is taken from compiling the template's rego again if it fails the same way.
+func regoErrors(templ *cftemplates.ConstraintTemplate, target string, err error, capabilities *ast.Capabilities) []*RegoError {
+	var errs []*RegoError
+	for _, match := range regoErrorPattern.FindAllStringSubmatch(err.Error(), -1) {
+		row, _ := strconv.Atoi(match[2])
+		module := templateModule
+		if match[1] != templateModule {
+			module = libModulePattern.FindString(match[1])
+		}
+		errs = append(errs, &RegoError{Module: module, Row: row, Code: match[3], Message: match[4]})
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	columns := map[string]int{}
+	for _, astErr := range compileTemplateRego(templ, target, capabilities) {
+		if astErr.Location != nil {
+			key := fmt.Sprintf("%s:%d:%s", astErr.Location.File, astErr.Location.Row, astErr.Code)
+			if _, ok := columns[key]; !ok {
+				columns[key] = astErr.Location.Col
+			}
+		}
+	}
+	for _, e := range errs {
+		e.Col = columns[fmt.Sprintf("%s:%d:%s", e.Module, e.Row, e.Code)]
+	}
+	return errs
+}
+
+// compileTemplateRego compiles the rego of the template's target and its libraries, with modules named
+// as in RegoError, and returns the errors.  Unlike the CF client, the packages are not rewritten and
+// the target's builtins are not available, so errors can differ from the client's.
+func compileTemplateRego(templ *cftemplates.ConstraintTemplate, target string, capabilities *ast.Capabilities) ast.Errors {
+	var rego string
+	var libs []string
+	for _, t := range templ.Spec.Targets {
+		if t.Target != target {
+			continue
+		}
+		rego, libs = t.Rego, t.Libs
+		for _, code := range t.Code {
+			if code.Engine != schema.Name {
+				continue
+			}
+			if source, err := schema.GetSource(code); err == nil {
+				rego, libs = source.Rego, source.Libs
+			}
+		}
+	}
+
+	modules := map[string]*ast.Module{}
+	parse := func(name, rego string) ast.Errors {
+		module, err := ast.ParseModule(name, rego)
+		if err != nil {
+			if astErrs, ok := err.(ast.Errors); ok {
+				return astErrs
+			}
+			return nil
+		}
+		if module != nil {
+			modules[name] = module
+		}
+		return nil
+	}
+	if astErrs := parse(templateModule, rego); astErrs != nil {
+		return astErrs
+	}
+	for idx, lib := range libs {
+		if astErrs := parse(fmt.Sprintf("lib_%d", idx), lib); astErrs != nil {
+			return astErrs
+		}
+	}
+	compiler := ast.NewCompiler()
+	if capabilities != nil {
+		compiler = compiler.WithCapabilities(capabilities)
+	}
+	compiler.Compile(modules)
+	return compiler.Errors
+}
@@ -15,9 +15,12 @@
 package gcv
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
@@ -25,11 +28,22 @@ import (
 	"github.com/pkg/errors"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/structpb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 const (
 	ConstraintKey = "constraint"
+	// RemediationKey is the key of the violation metadata that templates set to the guidance on how to
+	// fix a violation, see ConstraintViolation.Remediation.
+	RemediationKey = "remediation"
+	// CategoryKey is the constraint spec field, or annotation, that sets ConstraintViolation.Category.
+	CategoryKey = "category"
+	// BenchmarkKey is the constraint spec field, or annotation, that sets ConstraintViolation.Benchmark.
+	BenchmarkKey = "benchmark"
+	// DefaultEnforcementAction is the enforcement action of constraints without a
+	// spec.enforcementAction, as in Gatekeeper.
+	DefaultEnforcementAction = "deny"
 )
 
 // Result is the result of reviewing an individual resource
@@ -44,6 +58,12 @@ type Result struct {
 	ReviewResource map[string]interface{}
 	// ConstraintViolations are the constraints that were not satisfied during review.
 	ConstraintViolations []ConstraintViolation
+	// Evaluated are the constraints, as "[Kind].[Name]", that were evaluated against the resource,
+	// sorted.  It is only set for reviews with a context from RecordEvaluated.
+	Evaluated []string
+	// Stopped is true if the review stopped at a violation of at least the severity set with
+	// WithStopAtSeverity, before every constraint was evaluated, so violations may be missing.
+	Stopped bool
 }
 
 // NewResult creates a Result from the provided CF Response.
@@ -74,10 +94,14 @@ func NewResult(
 			severity = ""
 		}
 		result.ConstraintViolations[idx] = ConstraintViolation{
-			Message:    cfResult.Msg,
-			Metadata:   cfResult.Metadata,
-			Constraint: cfResult.Constraint,
-			Severity:   severity,
+			Message:           cfResult.Msg,
+			Metadata:          cfResult.Metadata,
+			Constraint:        cfResult.Constraint,
+			Severity:          severity,
+			Remediation:       remediation(cfResult.Metadata, cfResult.Constraint),
+			Category:          taxonomy(cfResult.Constraint, CategoryKey),
+			Benchmark:         taxonomy(cfResult.Constraint, BenchmarkKey),
+			EnforcementAction: enforcementAction(cfResult.Constraint),
 		}
 	}
 	return result, nil
@@ -93,6 +117,60 @@ type ConstraintViolation struct {
 	Constraint *unstructured.Unstructured
 	// Constraint Severity
 	Severity string
+	// Remediation is the guidance on how to fix the violation, the string value of RemediationKey in the
+	// metadata if the template sets it, else the spec.remediation of the constraint.
+	Remediation string
+	// Category is the category of the constraint, its spec.category or else its category annotation.
+	Category string
+	// Benchmark is the compliance benchmark control of the constraint, its spec.benchmark or else its
+	// benchmark annotation.
+	Benchmark string
+	// EnforcementAction is the spec.enforcementAction of the constraint, or DefaultEnforcementAction.
+	EnforcementAction string
+}
+
+// enforcementAction returns the spec.enforcementAction of the constraint, or DefaultEnforcementAction.
+func enforcementAction(constraint *unstructured.Unstructured) string {
+	if action, _, _ := unstructured.NestedString(constraint.Object, "spec", "enforcementAction"); action != "" {
+		return action
+	}
+	return DefaultEnforcementAction
+}
+
+// taxonomy returns the string spec field key of the constraint, or else its annotation key.
+func taxonomy(constraint *unstructured.Unstructured, key string) string {
+	if value, found, err := unstructured.NestedString(constraint.Object, "spec", key); err == nil && found && value != "" {
+		return value
+	}
+	return constraint.GetAnnotations()[key]
+}
+
+// annotations returns the annotations of the constraint without those the validator added when
+// loading it, nil if there are none.
+func (cv *ConstraintViolation) annotations() map[string]string {
+	var ret map[string]string
+	for k, v := range cv.Constraint.GetAnnotations() {
+		if configs.IsLoaderAnnotation(k) {
+			continue
+		}
+		if ret == nil {
+			ret = map[string]string{}
+		}
+		ret[k] = v
+	}
+	return ret
+}
+
+// remediation returns the remediation of a violation with the template metadata of the constraint.
+func remediation(metadata map[string]interface{}, constraint *unstructured.Unstructured) string {
+	if remediation, ok := metadata[RemediationKey].(string); ok && remediation != "" {
+		return remediation
+	}
+	remediation, _, err := unstructured.NestedString(constraint.Object, "spec", "remediation")
+	if err != nil {
+		return ""
+	}
+	return remediation
 }
 
 // ToInsights returns the result represented as a slice of insights.
@@ -111,7 +189,9 @@ func (r *Result) ToInsights() []*Insight {
 				"resource": r.InputResource,
 				"metadata": cv.metadata(nil),
 			},
-			Category: "SECURITY",
+			Category:    "SECURITY",
+			Fingerprint: cv.fingerprint(r.Name),
+			Remediation: cv.Remediation,
 		}
 		insights[idx] = i
 	}
@@ -128,7 +208,7 @@ func (r *Result) ToViolations() ([]*validator.Violation, error) {
 		auxMetadata[ancestryPathKey] = ancestryPath
 	}
 
-	var violations []*validator.Violation
+	violations := make([]*validator.Violation, 0, len(r.ConstraintViolations))
 	for _, rv := range r.ConstraintViolations {
 		violation, err := rv.toViolation(r.Name, auxMetadata)
 		if err != nil {
@@ -173,17 +253,58 @@ func (cv *ConstraintViolation) metadata(auxMetadata map[string]interface{}) map[
 	return metadata
 }
 
+// fingerprintVersion is hashed into every fingerprint so that a change to the fingerprint inputs
+// produces new fingerprints rather than colliding with old ones.
+const fingerprintVersion = "v1"
+
+// Fingerprint returns the fingerprint for a violation of constraint, given as "[Kind].[Name]", by
+// resource with the metadata returned by the constraint template.  The fingerprint is the hex encoded
+// SHA-256 of the constraint, the resource name and the JSON encoded template metadata.  It does not
+// depend on the message, severity, ancestry or evaluation time, so it is stable across reviews and
+// releases and may be used as a dedup key for stored violations.
+func Fingerprint(constraint, resource string, metadata map[string]interface{}) (string, error) {
+	// json.Marshal sorts map keys, so the encoding is canonical.
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to marshal metadata for fingerprint")
+	}
+	h := sha256.New()
+	for _, part := range []string{fingerprintVersion, constraint, normalizeResourceName(resource), string(metadataJSON)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// normalizeResourceName strips the surrounding whitespace and trailing slashes that CAI exports and
+// terraform conversions occasionally include in resource names.
+func normalizeResourceName(name string) string {
+	return strings.TrimRight(strings.TrimSpace(name), "/")
+}
+
+// fingerprint returns the fingerprint of the violation for the named resource.  Template metadata is
+// decoded from rego JSON so it always marshals, an empty fingerprint is returned if it does not.
+func (cv *ConstraintViolation) fingerprint(name string) string {
+	fp, err := Fingerprint(cv.name(), name, cv.Metadata)
+	if err != nil {
+		return ""
+	}
+	return fp
+}
+
 // name returns the name for the constraint, this is given as "[Kind].[Name]" to uniquely identify which template and
 // constraint the violation came from.
 func (cv *ConstraintViolation) name() string {
-	name := cv.Constraint.GetName()
-	ans := cv.Constraint.GetAnnotations()
-	if ans != nil {
-		if originalName, ok := ans[configs.OriginalName]; ok {
-			name = originalName
-		}
+	return constraintName(cv.Constraint)
+}
+
+// originalName returns the name of the object as it was declared, before any conversion to a K8S
+// compatible name.
+func originalName(u metav1.Object) string {
+	if originalName, ok := u.GetAnnotations()[configs.OriginalName]; ok {
+		return originalName
 	}
-	return fmt.Sprintf("%s.%s", cv.Constraint.GetKind(), name)
+	return u.GetName()
 }
 
 // toViolation converts the constriant to a violation.
@@ -230,13 +351,24 @@ func (cv *ConstraintViolation) toViolation(name string, auxMetadata map[string]i
 		Spec:       pbSpec,
 	}
 
+	fingerprint, err := Fingerprint(cv.name(), name, cv.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
 	return &validator.Violation{
-		Constraint:       cv.name(),
-		ConstraintConfig: constraintConfig,
-		Resource:         name,
-		Message:          cv.Message,
-		Metadata:         metadata,
-		Severity:         cv.Severity,
+		Constraint:        cv.name(),
+		ConstraintConfig:  constraintConfig,
+		Resource:          name,
+		Message:           cv.Message,
+		Metadata:          metadata,
+		Severity:          cv.Severity,
+		Fingerprint:       fingerprint,
+		Remediation:       cv.Remediation,
+		Category:          cv.Category,
+		Benchmark:         cv.Benchmark,
+		Annotations:       cv.annotations(),
+		EnforcementAction: cv.EnforcementAction,
 	}, nil
 }
 
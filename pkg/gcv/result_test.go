@@ -21,9 +21,13 @@ import (
 	"testing"
 
 	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	"google.golang.org/protobuf/testing/protocmp"
 	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 type ConversionTestCase struct {
@@ -96,7 +100,8 @@ var conversionTestCases = []ConversionTestCase{
 						},
 					},
 				},
-				Category: "SECURITY",
+				Category:    "SECURITY",
+				Fingerprint: "3ef8779b59479cc04303e15e1a6bbc9efb89979409705f30124ede09b1139e42",
 			},
 			{
 				Description:     "//storage.googleapis.com/my-storage-bucket does not have the required logging destination.",
@@ -157,7 +162,8 @@ var conversionTestCases = []ConversionTestCase{
 						},
 					},
 				},
-				Category: "SECURITY",
+				Category:    "SECURITY",
+				Fingerprint: "fe62918520bfce87709d8881e14edcc1d0a4617f7d480e1374b93a91d903b2b4",
 			},
 		},
 		wantViolations: []*validator.Violation{
@@ -201,7 +207,11 @@ var conversionTestCases = []ConversionTestCase{
 						"parameters": map[string]interface{}{},
 					},
 				}),
-				Severity: "high",
+				Severity:          "high",
+				Fingerprint:       "3ef8779b59479cc04303e15e1a6bbc9efb89979409705f30124ede09b1139e42",
+				Benchmark:         "CIS11_5.03",
+				Annotations:       map[string]string{"benchmark": "CIS11_5.03"},
+				EnforcementAction: "deny",
 			},
 			{
 				Constraint: "GCPStorageLoggingConstraint.require_storage_logging_XX",
@@ -245,7 +255,11 @@ var conversionTestCases = []ConversionTestCase{
 						"parameters": map[string]interface{}{},
 					},
 				}),
-				Severity: "medium",
+				Severity:          "medium",
+				Fingerprint:       "fe62918520bfce87709d8881e14edcc1d0a4617f7d480e1374b93a91d903b2b4",
+				Benchmark:         "CIS11_5.03",
+				Annotations:       map[string]string{"benchmark": "CIS11_5.03"},
+				EnforcementAction: "deny",
 			},
 		},
 	},
@@ -326,3 +340,151 @@ func TestConversion(t *testing.T) {
 		})
 	}
 }
+
+func TestFingerprint(t *testing.T) {
+	metadata := map[string]interface{}{
+		"details": map[string]interface{}{"member": "user:a@example.com", "role": "roles/owner"},
+	}
+	want, err := Fingerprint("GCPIAMAllowedBindingsConstraint.no-owners", "//cloudresourcemanager.googleapis.com/projects/123", metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	same, err := Fingerprint("GCPIAMAllowedBindingsConstraint.no-owners", " //cloudresourcemanager.googleapis.com/projects/123/", map[string]interface{}{
+		"details": map[string]interface{}{"role": "roles/owner", "member": "user:a@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same != want {
+		t.Errorf("fingerprint changed for equivalent inputs: %s != %s", same, want)
+	}
+
+	for _, tc := range []struct {
+		constraint string
+		resource   string
+		metadata   map[string]interface{}
+	}{
+		{"GCPIAMAllowedBindingsConstraint.no-editors", "//cloudresourcemanager.googleapis.com/projects/123", metadata},
+		{"GCPIAMAllowedBindingsConstraint.no-owners", "//cloudresourcemanager.googleapis.com/projects/456", metadata},
+		{"GCPIAMAllowedBindingsConstraint.no-owners", "//cloudresourcemanager.googleapis.com/projects/123", map[string]interface{}{
+			"details": map[string]interface{}{"member": "user:b@example.com", "role": "roles/owner"},
+		}},
+	} {
+		got, err := Fingerprint(tc.constraint, tc.resource, tc.metadata)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == want {
+			t.Errorf("fingerprint for %s on %s with %v collides with %s", tc.constraint, tc.resource, tc.metadata, want)
+		}
+	}
+}
+
+func TestRemediation(t *testing.T) {
+	constraint := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "GCPStorageLoggingConstraintV1",
+		"metadata":   map[string]interface{}{"name": "require-logging"},
+		"spec":       map[string]interface{}{"remediation": "Enable access logging on the bucket."},
+	}}
+	unset := constraint.DeepCopy()
+	unstructured.RemoveNestedField(unset.Object, "spec", "remediation")
+	responses := &cftypes.Responses{ByTarget: map[string]*cftypes.Response{
+		"target": {Results: []*cftypes.Result{
+			{Msg: "from constraint", Constraint: constraint, Metadata: map[string]interface{}{}},
+			{Msg: "from metadata", Constraint: constraint, Metadata: map[string]interface{}{
+				RemediationKey: "Set logging.logBucket to the audit bucket.",
+			}},
+			{Msg: "not a string", Constraint: constraint, Metadata: map[string]interface{}{
+				RemediationKey: map[string]interface{}{"steps": []interface{}{}},
+			}},
+			{Msg: "unset", Constraint: unset, Metadata: map[string]interface{}{}},
+		}},
+	}}
+	result, err := NewResult("target", "//storage.googleapis.com/b", map[string]interface{}{}, map[string]interface{}{}, responses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Enable access logging on the bucket.",
+		"Set logging.logBucket to the audit bucket.",
+		"Enable access logging on the bucket.",
+		"",
+	}
+
+	var got []string
+	for _, cv := range result.ConstraintViolations {
+		got = append(got, cv.Remediation)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ConstraintViolation remediation diff (-want +got):\n%s", diff)
+	}
+	got = nil
+	for _, insight := range result.ToInsights() {
+		got = append(got, insight.Remediation)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Insight remediation diff (-want +got):\n%s", diff)
+	}
+	violations, err := result.ToViolations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, violation := range violations {
+		got = append(got, violation.GetRemediation())
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Violation remediation diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestTaxonomy(t *testing.T) {
+	constraint := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "GCPStorageLoggingConstraintV1",
+		"metadata": map[string]interface{}{
+			"name": "require-logging",
+			"annotations": map[string]interface{}{
+				"benchmark":             "CIS11_5.03",
+				"category":              "from-annotation",
+				configs.OwnerAnnotation: "security-team",
+				configs.OriginalName:    "require_logging",
+			},
+		},
+		"spec": map[string]interface{}{"severity": "high", "category": "logging"},
+	}}
+	responses := &cftypes.Responses{ByTarget: map[string]*cftypes.Response{
+		"target": {Results: []*cftypes.Result{
+			{Msg: "violation", Constraint: constraint, Metadata: map[string]interface{}{}},
+		}},
+	}}
+	result, err := NewResult("target", "//storage.googleapis.com/b", map[string]interface{}{}, map[string]interface{}{}, responses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations, err := result.ToViolations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &validator.Violation{
+		Severity:  "high",
+		Category:  "logging",
+		Benchmark: "CIS11_5.03",
+		Annotations: map[string]string{
+			"benchmark":             "CIS11_5.03",
+			"category":              "from-annotation",
+			configs.OwnerAnnotation: "security-team",
+		},
+	}
+	got := &validator.Violation{
+		Severity:    violations[0].GetSeverity(),
+		Category:    violations[0].GetCategory(),
+		Benchmark:   violations[0].GetBenchmark(),
+		Annotations: violations[0].GetAnnotations(),
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("violation taxonomy diff (-want +got):\n%s", diff)
+	}
+}
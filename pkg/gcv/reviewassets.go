@@ -0,0 +1,105 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	asset2 "github.com/GoogleCloudPlatform/config-validator/pkg/asset"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ReviewAssets reviews a batch of assets concurrently, with the worker count of the Validator, and
+// returns their results in the order of the assets.  It is the library counterpart of a
+// ParallelValidator.Review request: the assets are evaluated at the same time in deterministic mode,
+// the ancestry path of assets with the same ancestors is computed once for the batch, and the assets
+// are converted without copying them first, with the JSON buffers pooled across the batch.  As with
+// ReviewAsset, the ancestry path of each asset is set in place.  Assets that fail to be reviewed have
+// a nil result and are reported together in the error, the results of the other assets are still
+// returned.
+func (v *Validator) ReviewAssets(ctx context.Context, assets []*validator.Asset) (_ []*Result, err error) {
+	ctx, span := tracer().Start(ctx, "Validator.ReviewAssets", trace.WithAttributes(
+		attrAssetCount.Int(len(assets)),
+	))
+	defer func() { endSpan(span, err) }()
+	ctx = v.runContext(ctx)
+
+	assetErrs := make([]error, len(assets))
+	ancestryPaths := map[string]string{}
+	for idx, asset := range assets {
+		assetErrs[idx] = sanitizeBatchAncestry(asset, ancestryPaths)
+	}
+
+	results := make([]*Result, len(assets))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < v.workerCount && i < len(assets); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				results[idx], assetErrs[idx] = v.reviewBatchAsset(ctx, assets[idx])
+			}
+		}()
+	}
+	for idx := range assets {
+		if assetErrs[idx] == nil {
+			indexes <- idx
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	var errs multierror.Errors
+	for idx, assetErr := range assetErrs {
+		if assetErr != nil {
+			errs.Add(fmt.Errorf("assets[%d] %s: %w", idx, assets[idx].GetName(), assetErr))
+		}
+	}
+	return results, errs.ToError()
+}
+
+// sanitizeBatchAncestry sets the ancestry path of the asset as asset.SanitizeAncestryPath does, reusing
+// the paths of the ancestors already seen in the batch.
+func sanitizeBatchAncestry(asset *validator.Asset, ancestryPaths map[string]string) error {
+	if len(asset.GetAncestors()) == 0 {
+		return asset2.SanitizeAncestryPath(asset)
+	}
+	key := strings.Join(asset.GetAncestors(), "\x00")
+	path, ok := ancestryPaths[key]
+	if !ok {
+		path = asset2.AncestryPath(asset.GetAncestors())
+		ancestryPaths[key] = path
+	}
+	asset.AncestryPath = path
+	return nil
+}
+
+// reviewBatchAsset reviews an asset of ReviewAssets with a sanitized ancestry path.
+func (v *Validator) reviewBatchAsset(ctx context.Context, asset *validator.Asset) (*Result, error) {
+	if err := asset2.ValidateAsset(asset); err != nil {
+		return nil, err
+	}
+	assetInterface, err := asset2.ConvertResourceViaJSONToInterface(asset)
+	if err != nil {
+		return nil, err
+	}
+	return v.ReviewUnmarshalledJSON(ctx, assetInterface.(map[string]interface{}))
+}
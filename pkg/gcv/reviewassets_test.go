@@ -0,0 +1,65 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+)
+
+func TestReviewAssets(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	invalid := storageAssetNoLogging()
+	invalid.Name = ""
+	assets := []*validator.Asset{
+		storageAssetNoLogging(),
+		storageAssetWithLogging(),
+		invalid,
+		storageAssetNoLogging(),
+	}
+
+	results, err := v.ReviewAssets(context.Background(), assets)
+	if err == nil || !strings.Contains(err.Error(), "assets[2]") {
+		t.Errorf("got error %v, want error for assets[2]", err)
+	}
+	if len(results) != len(assets) {
+		t.Fatalf("got %d results, want %d", len(results), len(assets))
+	}
+	if results[2] != nil {
+		t.Errorf("got result %v for invalid asset, want nil", results[2])
+	}
+	for _, idx := range []int{0, 1, 3} {
+		want, err := v.ReviewAsset(context.Background(), assets[idx])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := results[idx].ToViolations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Errorf("assets[%d]: got %d violations, want %d as from ReviewAsset", idx, len(got), len(want))
+		}
+		if results[idx].Name != assets[idx].Name {
+			t.Errorf("assets[%d]: got result for %s", idx, results[idx].Name)
+		}
+	}
+}
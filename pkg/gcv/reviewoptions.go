@@ -0,0 +1,203 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ErrInvalidReviewOptions is returned for review options with an unknown severity or a policy set
+// that no loaded constraint is in.
+var ErrInvalidReviewOptions = errors.New("invalid review options")
+
+// dryRunEnforcementAction is the spec.enforcementAction of constraints whose violations should not
+// fail a review.
+const dryRunEnforcementAction = "dryrun"
+
+// severityRanks orders the severities accepted as review options, other severities rank 0.
+var severityRanks = map[string]int{
+	"low":      1,
+	"medium":   2,
+	"high":     3,
+	"critical": 4,
+}
+
+func severityRank(severity string) int {
+	return severityRanks[strings.ToLower(severity)]
+}
+
+// parseSeverity returns the rank of a severity given as the named option, or an error wrapping
+// ErrInvalidReviewOptions if it is not one of severityRanks.
+func parseSeverity(option, severity string) (int, error) {
+	rank := severityRank(severity)
+	if rank == 0 {
+		return 0, fmt.Errorf("%w: unknown %s %q, must be one of low, medium, high or critical", ErrInvalidReviewOptions, option, severity)
+	}
+	return rank, nil
+}
+
+// EnforceOnly limits the violations returned by a ParallelValidator to those of constraints with one
+// of the enforcement actions, eg deny, warn or dryrun, see ConstraintViolation.EnforcementAction.  It
+// applies to every review request, in addition to the request's ReviewOptions, so dryrun violations
+// are left out of requests that exclude them.
+func EnforceOnly(actions ...string) Option {
+	return func(o *initOptions) {
+		o.enforceOnly = append(o.enforceOnly, actions...)
+	}
+}
+
+// reviewFilter selects the violations returned for a review request, see validator.ReviewOptions.
+type reviewFilter struct {
+	excludeDryRun bool
+	minSeverity   int
+	policySet     string
+	// enforceOnly are the enforcement actions set with EnforceOnly, in lower case.
+	enforceOnly map[string]bool
+}
+
+// newReviewFilter returns the filter for the options, which may be nil.
+func newReviewFilter(options *validator.ReviewOptions) (*reviewFilter, error) {
+	f := &reviewFilter{
+		excludeDryRun: options.GetExcludeDryrun(),
+		policySet:     options.GetPolicySet(),
+	}
+	if severity := options.GetMinSeverity(); severity != "" {
+		var err error
+		if f.minSeverity, err = parseSeverity("min_severity", severity); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// keep returns true if violations of a constraint with the severity, enforcement action and
+// annotations are returned.
+func (f *reviewFilter) keep(severity, action string, annotations map[string]string) bool {
+	if f.excludeDryRun && strings.EqualFold(action, dryRunEnforcementAction) {
+		return false
+	}
+	if len(f.enforceOnly) != 0 && !f.enforceOnly[strings.ToLower(action)] {
+		return false
+	}
+	if severityRank(severity) < f.minSeverity {
+		return false
+	}
+	if f.policySet != "" && !configs.InPolicySet(annotations, f.policySet) {
+		return false
+	}
+	return true
+}
+
+// keepViolation returns true if the violation is returned.
+func (f *reviewFilter) keepViolation(v *validator.Violation) bool {
+	action := v.GetEnforcementAction()
+	if action == "" {
+		action = DefaultEnforcementAction
+	}
+	return f.keep(v.GetSeverity(), action, v.GetAnnotations())
+}
+
+// keepConstraint returns true if the violations of the constraint are returned.
+func (f *reviewFilter) keepConstraint(constraint *unstructured.Unstructured) bool {
+	severity, _, _ := unstructured.NestedString(constraint.Object, "spec", "severity")
+	return f.keep(severity, enforcementAction(constraint), constraint.GetAnnotations())
+}
+
+// apply returns the violations that are kept.
+func (f *reviewFilter) apply(violations []*validator.Violation) []*validator.Violation {
+	var kept []*validator.Violation
+	for _, v := range violations {
+		if f.keepViolation(v) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// stopCondition is the condition for stopping a review set by WithStopAtSeverity.
+type stopCondition struct {
+	rank int
+	// filter is the filter of the review request, violations it leaves out never stop the review.  It
+	// is nil for reviews that return every violation.
+	filter *reviewFilter
+}
+
+// stopsConstraint returns true if a violation of the constraint stops the review.
+func (c *stopCondition) stopsConstraint(constraint *unstructured.Unstructured) bool {
+	if c.filter != nil && !c.filter.keepConstraint(constraint) {
+		return false
+	}
+	severity, _, _ := unstructured.NestedString(constraint.Object, "spec", "severity")
+	return severityRank(severity) >= c.rank
+}
+
+// stopsViolation returns true if the violation stops the review.
+func (c *stopCondition) stopsViolation(v *validator.Violation) bool {
+	if c.filter != nil && !c.filter.keepViolation(v) {
+		return false
+	}
+	return severityRank(v.GetSeverity()) >= c.rank
+}
+
+// stopsAny returns true if any of the violations stops the review.
+func (c *stopCondition) stopsAny(violations []*validator.Violation) bool {
+	for _, v := range violations {
+		if c.stopsViolation(v) {
+			return true
+		}
+	}
+	return false
+}
+
+type stopConditionContextKey struct{}
+
+// WithStopAtSeverity returns a copy of ctx which requests that reviews stop at the first violation of
+// a constraint with at least the severity, one of low, medium, high or critical.  When the GCP
+// constraints are split with ConstraintShards, the shards that are still evaluating the asset are
+// cancelled and their violations left out of the Result, which is marked as Stopped.  A
+// ParallelValidator stops reviewing the remaining assets of the request.
+func WithStopAtSeverity(ctx context.Context, severity string) (context.Context, error) {
+	rank, err := parseSeverity("stop_at_severity", severity)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, stopConditionContextKey{}, &stopCondition{rank: rank}), nil
+}
+
+// stopConditionFrom returns the condition set by WithStopAtSeverity, nil if none.
+func stopConditionFrom(ctx context.Context) *stopCondition {
+	condition, _ := ctx.Value(stopConditionContextKey{}).(*stopCondition)
+	return condition
+}
+
+// CheckPolicySet returns an error wrapping ErrInvalidReviewOptions if no loaded constraint is in the
+// policy set, which is most likely a typo that would otherwise hide every violation.
+func (v *Validator) CheckPolicySet(policySet string) error {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+	for _, constraint := range v.config.Constraints() {
+		if configs.InPolicySet(constraint.GetAnnotations(), policySet) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no loaded constraint is in policy set %q", ErrInvalidReviewOptions, policySet)
+}
@@ -0,0 +1,282 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestReviewOptions(t *testing.T) {
+	violations := []*validator.Violation{
+		{Constraint: "low", Severity: "low"},
+		{Constraint: "high", Severity: "HIGH", Annotations: map[string]string{configs.PolicySetsAnnotation: "cis, pci"}},
+		{Constraint: "critical", Severity: "critical", Annotations: map[string]string{configs.PolicySetsAnnotation: "pci"}},
+		{Constraint: "unspecified"},
+		{Constraint: "dryrun", Severity: "high", EnforcementAction: "dryrun"},
+		{Constraint: "warn", Severity: "low", EnforcementAction: "warn"},
+	}
+	testCases := []struct {
+		name    string
+		options *validator.ReviewOptions
+		opts    []Option
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "no options",
+			want: []string{"low", "high", "critical", "unspecified", "dryrun", "warn"},
+		},
+		{
+			name:    "options without exclude dryrun",
+			options: &validator.ReviewOptions{MinSeverity: "low"},
+			want:    []string{"low", "high", "critical", "dryrun", "warn"},
+		},
+		{
+			name:    "exclude dryrun",
+			options: &validator.ReviewOptions{ExcludeDryrun: true},
+			want:    []string{"low", "high", "critical", "unspecified", "warn"},
+		},
+		{
+			name:    "min severity",
+			options: &validator.ReviewOptions{MinSeverity: "high"},
+			want:    []string{"high", "critical", "dryrun"},
+		},
+		{
+			name:    "policy set",
+			options: &validator.ReviewOptions{PolicySet: "cis"},
+			want:    []string{"high"},
+		},
+		{
+			name:    "min severity and policy set",
+			options: &validator.ReviewOptions{PolicySet: "pci", MinSeverity: "critical"},
+			want:    []string{"critical"},
+		},
+		{
+			name: "enforce only deny",
+			opts: []Option{EnforceOnly("deny")},
+			want: []string{"low", "high", "critical", "unspecified"},
+		},
+		{
+			name: "enforce only dryrun and warn",
+			opts: []Option{EnforceOnly("DryRun", "warn")},
+			want: []string{"dryrun", "warn"},
+		},
+		{
+			name:    "enforce only dryrun with exclude dryrun",
+			options: &validator.ReviewOptions{ExcludeDryrun: true},
+			opts:    []Option{EnforceOnly("dryrun")},
+			want:    nil,
+		},
+		{
+			name:    "unknown severity",
+			options: &validator.ReviewOptions{MinSeverity: "severe"},
+			wantErr: ErrInvalidReviewOptions,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stopChannel := make(chan struct{})
+			defer close(stopChannel)
+			cv := NewFakeConfigValidator(map[string][]*validator.Violation{"//storage.googleapis.com/my-bucket": violations})
+			v := NewParallelValidator(stopChannel, cv, tc.opts...)
+
+			response, err := v.Review(context.Background(), &validator.ReviewRequest{
+				Assets:  []*validator.Asset{{Name: "//storage.googleapis.com/my-bucket"}},
+				Options: tc.options,
+			})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr != nil {
+				if code := ReviewStatus(err).Code(); code != codes.InvalidArgument {
+					t.Errorf("got code %v, want %v", code, codes.InvalidArgument)
+				}
+				return
+			}
+			var got []string
+			for _, v := range response.Violations {
+				got = append(got, v.Constraint)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("violations diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// failingConfigValidator fails the first asset and blocks on the others until the context is done.
+type failingConfigValidator struct {
+	mtx   sync.Mutex
+	calls int
+}
+
+func (v *failingConfigValidator) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error) {
+	v.mtx.Lock()
+	v.calls++
+	first := v.calls == 1
+	v.mtx.Unlock()
+	if first {
+		return nil, fmt.Errorf("failed to review %s", asset.Name)
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestReviewFailFast(t *testing.T) {
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	v := NewParallelValidator(stopChannel, &failingConfigValidator{}, WithWorkerCount(2))
+
+	var assets []*validator.Asset
+	for i := 0; i < 8; i++ {
+		assets = append(assets, &validator.Asset{Name: fmt.Sprintf("//storage.googleapis.com/bucket-%d", i)})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := v.Review(ctx, &validator.ReviewRequest{
+		Assets:  assets,
+		Options: &validator.ReviewOptions{FailFast: true},
+	})
+	if err == nil {
+		t.Fatal("expected error, got none")
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("review did not stop at the first error: %v", err)
+	}
+	if code := ReviewStatus(err).Code(); code != codes.InvalidArgument {
+		t.Errorf("got code %v, want %v: %v", code, codes.InvalidArgument, err)
+	}
+}
+
+// stoppingConfigValidator returns a critical violation for the first asset and blocks on the others
+// until the context is done.
+type stoppingConfigValidator struct {
+	mtx    sync.Mutex
+	calls  int
+	action string
+}
+
+func (v *stoppingConfigValidator) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error) {
+	v.mtx.Lock()
+	v.calls++
+	first := v.calls == 1
+	v.mtx.Unlock()
+	if first {
+		return []*validator.Violation{{Resource: asset.Name, Severity: "critical", EnforcementAction: v.action}}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestReviewStopAtSeverity(t *testing.T) {
+	var assets []*validator.Asset
+	for i := 0; i < 8; i++ {
+		assets = append(assets, &validator.Asset{Name: fmt.Sprintf("//storage.googleapis.com/bucket-%d", i)})
+	}
+	testCases := []struct {
+		name        string
+		action      string
+		options     *validator.ReviewOptions
+		wantStopped bool
+	}{
+		{
+			name:        "stops",
+			options:     &validator.ReviewOptions{StopAtSeverity: "high"},
+			wantStopped: true,
+		},
+		{
+			name:    "dryrun excluded",
+			options: &validator.ReviewOptions{StopAtSeverity: "critical", ExcludeDryrun: true},
+			action:  "dryrun",
+		},
+		{
+			name:        "dryrun",
+			action:      "dryrun",
+			options:     &validator.ReviewOptions{StopAtSeverity: "critical"},
+			wantStopped: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stopChannel := make(chan struct{})
+			defer close(stopChannel)
+			v := NewParallelValidator(stopChannel, &stoppingConfigValidator{action: tc.action}, WithWorkerCount(2))
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			response, err := v.Review(ctx, &validator.ReviewRequest{Assets: assets, Options: tc.options})
+			if !tc.wantStopped {
+				// Violations that are filtered out do not stop the review, which runs until the deadline.
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !response.Stopped {
+				t.Error("response is not marked as stopped")
+			}
+			if len(response.Violations) != 1 {
+				t.Errorf("got %d violations, want 1", len(response.Violations))
+			}
+		})
+	}
+}
+
+func TestStopConditionConstraint(t *testing.T) {
+	constraint := func(severity, action string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{"severity": severity, "enforcementAction": action},
+		}}
+	}
+	testCases := []struct {
+		name       string
+		condition  *stopCondition
+		constraint *unstructured.Unstructured
+		want       bool
+	}{
+		{name: "at severity", condition: &stopCondition{rank: 3}, constraint: constraint("high", ""), want: true},
+		{name: "above severity", condition: &stopCondition{rank: 3}, constraint: constraint("Critical", ""), want: true},
+		{name: "below severity", condition: &stopCondition{rank: 3}, constraint: constraint("medium", ""), want: false},
+		{name: "no filter", condition: &stopCondition{rank: 3}, constraint: constraint("high", "dryrun"), want: true},
+		{name: "filtered", condition: &stopCondition{rank: 3, filter: &reviewFilter{excludeDryRun: true}}, constraint: constraint("high", "dryrun"), want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.condition.stopsConstraint(tc.constraint); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStopAtSeverityInvalid(t *testing.T) {
+	if _, err := WithStopAtSeverity(context.Background(), "severe"); !errors.Is(err, ErrInvalidReviewOptions) {
+		t.Errorf("got error %v, want %v", err, ErrInvalidReviewOptions)
+	}
+}
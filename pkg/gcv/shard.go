@@ -0,0 +1,218 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	cfclient "github.com/open-policy-agent/frameworks/constraint/pkg/client"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ConstraintShards splits the GCP templates and constraints across n Constraint Framework clients,
+// each with its own rego driver, and reviews each GCP asset on all of them concurrently.  A single
+// client evaluates every constraint for an asset one after another, so very large assets, such as
+// organization IAM policies, are slow to review when there are many constraints.  Constraints of the
+// same kind are always on the same shard, and kinds are spread so that each shard has about the same
+// number of constraints.  Each shard compiles its own copy of the policy library, so memory use grows
+// with n.  Values less than two use a single client.
+func ConstraintShards(n int) Option {
+	return func(o *initOptions) {
+		o.constraintShards = n
+	}
+}
+
+// clientShards are the CF clients of a target that has its constraint kinds split across clients.
+type clientShards struct {
+	clients []*cfclient.Client
+	// kinds maps each constraint kind to the index of the client it is on.
+	kinds map[string]int
+}
+
+// assignShards assigns the kinds of the templates to n shards, placing the kinds with the most
+// constraints first, each on the shard with the fewest constraints.
+func assignShards(n int, templates []*cftemplates.ConstraintTemplate, constraints []*unstructured.Unstructured) map[string]int {
+	counts := map[string]int{}
+	for _, templ := range templates {
+		counts[templ.Spec.CRD.Spec.Names.Kind] = 0
+	}
+	for _, constraint := range constraints {
+		counts[constraint.GetKind()]++
+	}
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if counts[kinds[i]] != counts[kinds[j]] {
+			return counts[kinds[i]] > counts[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+
+	assignments := map[string]int{}
+	load := make([]int, n)
+	for _, kind := range kinds {
+		shard := leastLoaded(load)
+		assignments[kind] = shard
+		load[shard] += counts[kind]
+	}
+	return assignments
+}
+
+func leastLoaded(load []int) int {
+	shard := 0
+	for idx := range load {
+		if load[idx] < load[shard] {
+			shard = idx
+		}
+	}
+	return shard
+}
+
+// split returns the templates and constraints of each shard.
+func (s *clientShards) split(templates []*cftemplates.ConstraintTemplate, constraints []*unstructured.Unstructured) ([][]*cftemplates.ConstraintTemplate, [][]*unstructured.Unstructured) {
+	shardTemplates := make([][]*cftemplates.ConstraintTemplate, len(s.clients))
+	shardConstraints := make([][]*unstructured.Unstructured, len(s.clients))
+	for _, templ := range templates {
+		shard := s.kinds[templ.Spec.CRD.Spec.Names.Kind]
+		shardTemplates[shard] = append(shardTemplates[shard], templ)
+	}
+	for _, constraint := range constraints {
+		// Constraints without a template are given to the first shard, which reports the error.
+		shard := s.kinds[constraint.GetKind()]
+		shardConstraints[shard] = append(shardConstraints[shard], constraint)
+	}
+	return shardTemplates, shardConstraints
+}
+
+// client returns the client for the constraint kind, assigning a new kind to the shard with the
+// fewest of the constraints.  v.mtx must be held for writing when a new kind may be assigned.
+func (s *clientShards) client(kind string, constraints []*unstructured.Unstructured) *cfclient.Client {
+	if len(s.clients) == 1 {
+		return s.clients[0]
+	}
+	shard, ok := s.kinds[kind]
+	if !ok {
+		load := make([]int, len(s.clients))
+		for _, constraint := range constraints {
+			if idx, ok := s.kinds[constraint.GetKind()]; ok {
+				load[idx]++
+			}
+		}
+		shard = leastLoaded(load)
+		s.kinds[kind] = shard
+	}
+	return s.clients[shard]
+}
+
+// review reviews obj on every shard concurrently and merges the responses.  The results are sorted
+// by constraint kind and name, as for a single client.  With WithStopAtSeverity, the first shard to
+// find a violation of at least the severity cancels the others, whose responses are left out, and
+// stopped is true.
+func (s *clientShards) review(ctx context.Context, target string, constraints []*unstructured.Unstructured, obj interface{}) (_ *cftypes.Responses, stopped bool, _ error) {
+	if len(s.clients) == 1 {
+		responses, err := cfReview(ctx, s.clients[0], target, len(constraints), obj)
+		return responses, false, err
+	}
+	counts := make([]int, len(s.clients))
+	for _, constraint := range constraints {
+		counts[s.kinds[constraint.GetKind()]]++
+	}
+
+	stop := stopConditionFrom(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stopOnce sync.Once
+	responses := make([]*cftypes.Responses, len(s.clients))
+	errs := make([]error, len(s.clients))
+	// cancelled records the shards that finished after the review was stopped, whose evaluation may
+	// have been cut short.  The rego driver reports cancelled evaluations as violations.
+	cancelled := make([]bool, len(s.clients))
+	var wg sync.WaitGroup
+	for idx, client := range s.clients {
+		if counts[idx] == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(idx int, client *cfclient.Client) {
+			defer wg.Done()
+			responses[idx], errs[idx] = cfReview(ctx, client, target, counts[idx], obj)
+			if cancelled[idx] = ctx.Err() != nil; cancelled[idx] {
+				return
+			}
+			if errs[idx] == nil && stop != nil && stopsReview(stop, responses[idx], target) {
+				stopOnce.Do(func() {
+					stopped = true
+					cancel()
+				})
+			}
+		}(idx, client)
+	}
+	wg.Wait()
+
+	merged := cftypes.NewResponses()
+	merged.ByTarget[target] = &cftypes.Response{Target: target}
+	for idx, resp := range responses {
+		if stopped && cancelled[idx] {
+			continue
+		}
+		if errs[idx] != nil {
+			return nil, false, errs[idx]
+		}
+		if resp == nil {
+			continue
+		}
+		for name, handled := range resp.Handled {
+			merged.Handled[name] = merged.Handled[name] || handled
+		}
+		merged.StatsEntries = append(merged.StatsEntries, resp.StatsEntries...)
+		if targetResp, ok := resp.ByTarget[target]; ok {
+			merged.ByTarget[target].Results = append(merged.ByTarget[target].Results, targetResp.Results...)
+			if targetResp.Trace != nil {
+				merged.ByTarget[target].Trace = appendTrace(merged.ByTarget[target].Trace, *targetResp.Trace)
+			}
+		}
+	}
+	merged.ByTarget[target].Sort()
+	return merged, stopped, nil
+}
+
+// appendTrace appends the Rego trace of a shard to the trace of the merged response.
+func appendTrace(merged *string, trace string) *string {
+	if merged == nil {
+		return &trace
+	}
+	joined := *merged + "\n" + trace
+	return &joined
+}
+
+// stopsReview returns true if the responses have a violation that meets the stop condition.
+func stopsReview(stop *stopCondition, responses *cftypes.Responses, target string) bool {
+	resp, ok := responses.ByTarget[target]
+	if !ok {
+		return false
+	}
+	for _, result := range resp.Results {
+		if stop.stopsConstraint(result.Constraint) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	cfclient "github.com/open-policy-agent/frameworks/constraint/pkg/client"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"google.golang.org/protobuf/encoding/protojson"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newShardedValidator(t *testing.T, shards int) *Validator {
+	t.Helper()
+	policyPaths, policyLibPath := testOptions()
+	v, err := NewValidator(policyPaths, policyLibPath, ConstraintShards(shards))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	return v
+}
+
+func violatedConstraints(t *testing.T, v *Validator, assetJSON string) []string {
+	t.Helper()
+	result, err := v.ReviewJSON(context.Background(), assetJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	var names []string
+	for _, cv := range result.ConstraintViolations {
+		names = append(names, cv.name()+": "+cv.Message)
+	}
+	return names
+}
+
+func TestConstraintShardsReview(t *testing.T) {
+	single := newShardedValidator(t, 1)
+	sharded := newShardedValidator(t, 3)
+	if got := len(sharded.gcpCFClients.clients); got != 3 {
+		t.Fatalf("got %d GCP clients, want 3", got)
+	}
+	used := map[int]bool{}
+	for _, shard := range sharded.gcpCFClients.kinds {
+		used[shard] = true
+	}
+	if len(used) < 2 {
+		t.Errorf("constraint kinds %v are not spread across shards", sharded.gcpCFClients.kinds)
+	}
+
+	for name, assetJSON := range defaultReviewTestAssetJSONs {
+		t.Run(name, func(t *testing.T) {
+			want := violatedConstraints(t, single, assetJSON)
+			got := violatedConstraints(t, sharded, assetJSON)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("violations diff (-single +sharded):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConstraintShardsUpdate(t *testing.T) {
+	ctx := context.Background()
+	v := newShardedValidator(t, 2)
+	violations := func() int {
+		return len(violatedConstraints(t, v, storageAssetNoLoggingJSON))
+	}
+	if got := violations(); got != 2 {
+		t.Fatalf("got %d violations, want 2", got)
+	}
+
+	var templ *cftemplates.ConstraintTemplate
+	for _, ct := range v.config.GCPTemplates {
+		if ct.Name == "cfgcpstorageloggingconstraint" {
+			templ = ct
+		}
+	}
+	var constraint *unstructured.Unstructured
+	for _, c := range v.config.GCPConstraints {
+		if c.GetKind() == "CFGCPStorageLoggingConstraint" {
+			constraint = c
+		}
+	}
+	if err := v.RemoveTemplate(ctx, templ.Name); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := violations(); got != 1 {
+		t.Errorf("got %d violations after removing template, want 1", got)
+	}
+	if err := v.AddTemplate(ctx, templ); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if err := v.AddConstraint(ctx, constraint); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := violations(); got != 2 {
+		t.Errorf("got %d violations after adding template and constraint, want 2", got)
+	}
+}
+
+func TestConstraintShardsAncestorIAM(t *testing.T) {
+	ctx := context.Background()
+	v := newAncestorIAMValidator(t, AncestorIAM(), ConstraintShards(2))
+	folder := &validator.Asset{}
+	if err := protojson.Unmarshal([]byte(folderPublicOwnerJSON), folder); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.AddAncestorIAM(ctx, folder); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := len(violatedConstraints(t, v, storageAssetNoLoggingJSON)); got != 1 {
+		t.Errorf("got %d violations after adding policy, want 1", got)
+	}
+	if err := v.ResetAncestorIAM(ctx); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := len(violatedConstraints(t, v, storageAssetNoLoggingJSON)); got != 0 {
+		t.Errorf("got %d violations after reset, want 0", got)
+	}
+}
+
+func TestAssignShards(t *testing.T) {
+	template := func(kind string) *cftemplates.ConstraintTemplate {
+		templ := &cftemplates.ConstraintTemplate{}
+		templ.Spec.CRD.Spec.Names.Kind = kind
+		return templ
+	}
+	constraint := func(kind string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetKind(kind)
+		return u
+	}
+	templates := []*cftemplates.ConstraintTemplate{template("A"), template("B"), template("C"), template("D")}
+	var constraints []*unstructured.Unstructured
+	for kind, n := range map[string]int{"A": 5, "B": 3, "C": 1, "D": 0} {
+		for i := 0; i < n; i++ {
+			constraints = append(constraints, constraint(kind))
+		}
+	}
+	want := map[string]int{"A": 0, "B": 1, "C": 1, "D": 1}
+	if diff := cmp.Diff(want, assignShards(2, templates, constraints)); diff != "" {
+		t.Errorf("assignments diff (-want +got):\n%s", diff)
+	}
+
+	shards := &clientShards{clients: make([]*cfclient.Client, 2), kinds: want}
+	shards.client("E", constraints)
+	if got := shards.kinds["E"]; got != 1 {
+		t.Errorf("new kind assigned to shard %d, want the least loaded shard 1", got)
+	}
+}
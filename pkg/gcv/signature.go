@@ -0,0 +1,91 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/golang/glog"
+)
+
+// ErrUnverifiedPolicies is returned when WithBundleVerification is given and policies would be loaded
+// from anything other than a bundle archive with a signature that verifies.
+var ErrUnverifiedPolicies = errors.New("policies must be loaded from a signed bundle archive")
+
+// WithBundleVerification requires the policies of the Validator to come from a single bundle archive,
+// written by configs.WriteBundleArchive, with a detached signature by the private key of the PEM
+// encoded public key in the file keyRef, see configs.VerifyBundleSignature.  NewValidator reads the
+// signature of the archive from the archive's path with configs.BundleSignatureSuffix appended, and
+// NewValidatorFromSignedBundle takes it as an argument.  Loading fails if the signature is missing or
+// doesn't verify, and every other way of loading templates and constraints, including AddTemplate
+// and AddConstraint, fails with ErrUnverifiedPolicies.
+func WithBundleVerification(keyRef string) Option {
+	return func(o *initOptions) {
+		o.bundleVerificationKey = keyRef
+	}
+}
+
+// readSignedBundleArchive reads the configuration of the signed bundle archive that must be the only
+// policy path.
+func readSignedBundleArchive(policyPaths []string, policyLibraryPath, keyRef string) (*configs.Configuration, error) {
+	if len(policyPaths) != 1 || !configs.IsBundleArchive(policyPaths[0]) || policyLibraryPath != "" {
+		return nil, fmt.Errorf("%w, got policy paths %v and policy library %q", ErrUnverifiedPolicies, policyPaths, policyLibraryPath)
+	}
+	path := policyPaths[0]
+	key, err := configs.LoadBundleVerificationKey(keyRef)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := os.ReadFile(path + configs.BundleSignatureSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature of policy bundle archive %s: %w: %v", path, configs.ErrBundleSignature, err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	config, manifest, err := configs.ReadSignedBundleArchive(f, signature, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policy bundle archive %s: %w", path, err)
+	}
+	glog.Infof("verified signature of policy bundle archive %s with %s, loaded %d templates and %d constraints",
+		path, keyRef, manifest.Templates, manifest.Constraints)
+	return config, nil
+}
+
+// NewValidatorFromSignedBundle returns a new Validator built from a policy bundle archive and its
+// detached signature, which must verify with the key given to WithBundleVerification.
+func NewValidatorFromSignedBundle(r io.Reader, signature []byte, opts ...Option) (*Validator, error) {
+	start := time.Now()
+	options := newInitOptions(opts...)
+	if options.bundleVerificationKey == "" {
+		return nil, errors.New("no bundle verification key set, provide one with WithBundleVerification")
+	}
+	key, err := configs.LoadBundleVerificationKey(options.bundleVerificationKey)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := configs.ReadSignedBundleArchive(r, signature, key)
+	if err != nil {
+		return nil, err
+	}
+	return newValidatorFromConfig(config, &LoadReport{StartTime: start, ConfigDuration: time.Since(start)}, opts...)
+}
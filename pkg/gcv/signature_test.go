@@ -0,0 +1,106 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestBundleVerification(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "key.pub")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	policyFilePaths, policyLibPath := testOptions()
+	var archive bytes.Buffer
+	if _, err := configs.WriteBundleArchive(&archive, policyFilePaths, policyLibPath); err != nil {
+		t.Fatal(err)
+	}
+	signature, err := configs.SignBundleArchive(key, archive.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	archivePath := filepath.Join(dir, "bundle"+configs.BundleArchiveSuffix)
+	if err := os.WriteFile(archivePath, archive.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the signature file the archive is refused.
+	if _, err := NewValidator([]string{archivePath}, "", WithBundleVerification(keyPath)); !errors.Is(err, configs.ErrBundleSignature) {
+		t.Fatalf("got error %v without a signature, want %v", err, configs.ErrBundleSignature)
+	}
+	if err := os.WriteFile(archivePath+configs.BundleSignatureSuffix, signature, 0644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewValidator([]string{archivePath}, "", WithBundleVerification(keyPath))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	result, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(result.ConstraintViolations) == 0 {
+		t.Errorf("got no violations from the signed bundle")
+	}
+	constraint := &unstructured.Unstructured{}
+	constraint.SetKind("GCPStorageLoggingConstraintV1")
+	constraint.SetName("added")
+	if err := v.AddConstraint(context.Background(), constraint); !errors.Is(err, ErrUnverifiedPolicies) {
+		t.Errorf("AddConstraint() = %v, want %v", err, ErrUnverifiedPolicies)
+	}
+
+	if _, err := NewValidatorFromSignedBundle(bytes.NewReader(archive.Bytes()), signature, WithBundleVerification(keyPath)); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	other, err := configs.SignBundleArchive(key, []byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewValidatorFromSignedBundle(bytes.NewReader(archive.Bytes()), other, WithBundleVerification(keyPath)); !errors.Is(err, configs.ErrBundleSignature) {
+		t.Errorf("got error %v for another signature, want %v", err, configs.ErrBundleSignature)
+	}
+
+	// Policies that aren't a signed bundle archive are refused.
+	if _, err := NewValidator(policyFilePaths, policyLibPath, WithBundleVerification(keyPath)); !errors.Is(err, ErrUnverifiedPolicies) {
+		t.Errorf("got error %v for policy directories, want %v", err, ErrUnverifiedPolicies)
+	}
+	if _, err := NewValidatorFromBundle(bytes.NewReader(archive.Bytes()), WithBundleVerification(keyPath)); !errors.Is(err, ErrUnverifiedPolicies) {
+		t.Errorf("got error %v for an unsigned bundle, want %v", err, ErrUnverifiedPolicies)
+	}
+}
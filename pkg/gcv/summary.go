@@ -0,0 +1,113 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"sort"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/ancestry"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// unspecifiedSeverity is the severity violations of constraints without spec.severity are counted
+// under.
+const unspecifiedSeverity = "unspecified"
+
+// AncestrySummary is a node in a tree of review results rolled up by ancestry.  The root summarizes
+// every result, its descendants are the organizations, folders and projects in the ancestry paths of
+// the reviewed resources.
+type AncestrySummary struct {
+	// Ancestor is the organization, folder or project of the node, eg folders/456.  It is empty at
+	// the root.
+	Ancestor string `json:"ancestor,omitempty"`
+	// AncestryPath is the ancestry path of the node, eg organizations/123/folders/456.
+	AncestryPath string `json:"ancestryPath,omitempty"`
+	// Resources is the number of reviewed resources under the node.
+	Resources int `json:"resources"`
+	// ViolatingResources is the number of resources under the node with at least one violation.
+	ViolatingResources int `json:"violatingResources"`
+	// Violations is the number of violations of the resources under the node.
+	Violations int `json:"violations"`
+	// Severities counts the violations by the severity of the violated constraint.  Constraints
+	// without a severity are counted as "unspecified".
+	Severities map[string]int `json:"severities,omitempty"`
+	// Children are the nodes directly under this one, with the most violations first.  Results
+	// without an ancestry path, such as those of Kubernetes resources, are only counted at the root.
+	Children []*AncestrySummary `json:"children,omitempty"`
+}
+
+// SummarizeByAncestry rolls up the results by the organizations, folders and projects in the
+// ancestry paths of the reviewed resources.
+func SummarizeByAncestry(results []*Result) *AncestrySummary {
+	root := &AncestrySummary{}
+	children := map[*AncestrySummary]map[string]*AncestrySummary{}
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		ancestryPath, _, _ := unstructured.NestedString(result.InputResource, ancestryPathKey)
+		node := root
+		node.add(result)
+		for _, ancestor := range ancestry.Containers(ancestryPath) {
+			if children[node] == nil {
+				children[node] = map[string]*AncestrySummary{}
+			}
+			child, ok := children[node][ancestor]
+			if !ok {
+				child = &AncestrySummary{Ancestor: ancestor, AncestryPath: ancestor}
+				if node.AncestryPath != "" {
+					child.AncestryPath = node.AncestryPath + "/" + ancestor
+				}
+				children[node][ancestor] = child
+				node.Children = append(node.Children, child)
+			}
+			node = child
+			node.add(result)
+		}
+	}
+	root.sort()
+	return root
+}
+
+func (s *AncestrySummary) add(result *Result) {
+	s.Resources++
+	if len(result.ConstraintViolations) == 0 {
+		return
+	}
+	s.ViolatingResources++
+	s.Violations += len(result.ConstraintViolations)
+	if s.Severities == nil {
+		s.Severities = map[string]int{}
+	}
+	for _, cv := range result.ConstraintViolations {
+		severity := cv.Severity
+		if severity == "" {
+			severity = unspecifiedSeverity
+		}
+		s.Severities[severity]++
+	}
+}
+
+func (s *AncestrySummary) sort() {
+	sort.Slice(s.Children, func(i, j int) bool {
+		if s.Children[i].Violations != s.Children[j].Violations {
+			return s.Children[i].Violations > s.Children[j].Violations
+		}
+		return s.Children[i].Ancestor < s.Children[j].Ancestor
+	})
+	for _, child := range s.Children {
+		child.sort()
+	}
+}
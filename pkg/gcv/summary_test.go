@@ -0,0 +1,106 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func summaryResult(ancestryPath string, severities ...string) *Result {
+	result := &Result{InputResource: map[string]interface{}{}}
+	if ancestryPath != "" {
+		result.InputResource[ancestryPathKey] = ancestryPath
+	}
+	for _, severity := range severities {
+		result.ConstraintViolations = append(result.ConstraintViolations, ConstraintViolation{Severity: severity})
+	}
+	return result
+}
+
+func TestSummarizeByAncestry(t *testing.T) {
+	results := []*Result{
+		summaryResult("organizations/1/folders/2/projects/3", "high", "low"),
+		summaryResult("organizations/1/folders/2/projects/3"),
+		summaryResult("organizations/1/projects/4", "high"),
+		summaryResult("organizations/1/folders/2/projects/5", ""),
+		summaryResult("", "high"),
+	}
+	want := &AncestrySummary{
+		Resources:          5,
+		ViolatingResources: 4,
+		Violations:         5,
+		Severities:         map[string]int{"high": 3, "low": 1, "unspecified": 1},
+		Children: []*AncestrySummary{{
+			Ancestor:           "organizations/1",
+			AncestryPath:       "organizations/1",
+			Resources:          4,
+			ViolatingResources: 3,
+			Violations:         4,
+			Severities:         map[string]int{"high": 2, "low": 1, "unspecified": 1},
+			Children: []*AncestrySummary{
+				{
+					Ancestor:           "folders/2",
+					AncestryPath:       "organizations/1/folders/2",
+					Resources:          3,
+					ViolatingResources: 2,
+					Violations:         3,
+					Severities:         map[string]int{"high": 1, "low": 1, "unspecified": 1},
+					Children: []*AncestrySummary{
+						{
+							Ancestor:           "projects/3",
+							AncestryPath:       "organizations/1/folders/2/projects/3",
+							Resources:          2,
+							ViolatingResources: 1,
+							Violations:         2,
+							Severities:         map[string]int{"high": 1, "low": 1},
+						},
+						{
+							Ancestor:           "projects/5",
+							AncestryPath:       "organizations/1/folders/2/projects/5",
+							Resources:          1,
+							ViolatingResources: 1,
+							Violations:         1,
+							Severities:         map[string]int{"unspecified": 1},
+						},
+					},
+				},
+				{
+					Ancestor:           "projects/4",
+					AncestryPath:       "organizations/1/projects/4",
+					Resources:          1,
+					ViolatingResources: 1,
+					Violations:         1,
+					Severities:         map[string]int{"high": 1},
+				},
+			},
+		}},
+	}
+	got := SummarizeByAncestry(results)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("summary diff (-want +got):\n%s", diff)
+	}
+
+	data, err := json.Marshal(got.Children[0].Children[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJSON := `{"ancestor":"projects/4","ancestryPath":"organizations/1/projects/4","resources":1,"violatingResources":1,"violations":1,"severities":{"high":1}}`
+	if string(data) != wantJSON {
+		t.Errorf("got JSON %s, want %s", data, wantJSON)
+	}
+}
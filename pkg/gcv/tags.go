@@ -0,0 +1,88 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	crm "google.golang.org/api/cloudresourcemanager/v3"
+)
+
+// TagResolver resolves the Resource Manager tags of assets that don't provide them, for constraints
+// that match on spec.match.requiredTags or spec.match.excludedTags.
+type TagResolver interface {
+	// Tags returns the effective tags of the resource with the given CAI asset name, including those
+	// inherited from its ancestors, as a map of namespaced tag key, eg "123456789/env", to the short
+	// name of the tag value, eg "prod".
+	Tags(ctx context.Context, assetName string) (map[string]string, error)
+}
+
+// WithTagResolver sets the resolver of the tags of GCP assets that have no tags field.  Without a
+// resolver such assets have no tags, so they match no spec.match.requiredTags.
+func WithTagResolver(resolver TagResolver) Option {
+	return func(o *initOptions) {
+		o.tagResolver = resolver
+	}
+}
+
+// resourceManagerTagResolver resolves tags with the effective tags of Resource Manager.
+type resourceManagerTagResolver struct {
+	service *crm.Service
+}
+
+// ResourceManagerTagResolver returns a TagResolver that lists the effective tags of assets with the
+// Resource Manager client.
+func ResourceManagerTagResolver(service *crm.Service) TagResolver {
+	return &resourceManagerTagResolver{service: service}
+}
+
+func (r *resourceManagerTagResolver) Tags(ctx context.Context, assetName string) (map[string]string, error) {
+	tags := map[string]string{}
+	err := r.service.EffectiveTags.List().Parent(assetName).Pages(ctx, func(page *crm.ListEffectiveTagsResponse) error {
+		for _, tag := range page.EffectiveTags {
+			tags[tag.NamespacedTagKey] = strings.TrimPrefix(tag.NamespacedTagValue, tag.NamespacedTagKey+"/")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list effective tags of %s: %w", assetName, err)
+	}
+	return tags, nil
+}
+
+// setTags resolves the tags of the asset, see gcptarget.TagsKey, if it has none and a tag resolver is
+// set.
+func (v *Validator) setTags(ctx context.Context, input map[string]interface{}) error {
+	if v.tagResolver == nil {
+		return nil
+	}
+	if _, found := input[gcptarget.TagsKey]; found {
+		return nil
+	}
+	name, _ := input["name"].(string)
+	tags, err := v.tagResolver.Tags(ctx, name)
+	if err != nil {
+		return err
+	}
+	tagsMap := make(map[string]interface{}, len(tags))
+	for key, value := range tags {
+		tagsMap[key] = value
+	}
+	input[gcptarget.TagsKey] = tagsMap
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	"github.com/google/go-cmp/cmp"
+	crm "google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/api/option"
+)
+
+type fakeTagResolver struct {
+	tags    map[string]string
+	lookups int
+}
+
+func (r *fakeTagResolver) Tags(ctx context.Context, assetName string) (map[string]string, error) {
+	r.lookups++
+	return r.tags, nil
+}
+
+func TestSetTags(t *testing.T) {
+	resolver := &fakeTagResolver{tags: map[string]string{"123/env": "prod"}}
+	v := &Validator{tagResolver: resolver}
+
+	input := map[string]interface{}{"name": "//storage.googleapis.com/my-bucket"}
+	if err := v.setTags(context.Background(), input); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"123/env": "prod"}
+	if diff := cmp.Diff(want, input[gcptarget.TagsKey]); diff != "" {
+		t.Errorf("tags diff (-want +got):\n%s", diff)
+	}
+
+	tagged := map[string]interface{}{
+		"name":            "//storage.googleapis.com/my-bucket",
+		gcptarget.TagsKey: map[string]interface{}{"123/env": "dev"},
+	}
+	if err := v.setTags(context.Background(), tagged); err != nil {
+		t.Fatal(err)
+	}
+	if resolver.lookups != 1 {
+		t.Errorf("got %d lookups, want tags of the tagged asset not to be resolved", resolver.lookups)
+	}
+}
+
+func TestResourceManagerTagResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Query().Get("parent"), "//storage.googleapis.com/my-bucket"; got != want {
+			t.Errorf("got parent %q, want %q", got, want)
+		}
+		json.NewEncoder(w).Encode(&crm.ListEffectiveTagsResponse{EffectiveTags: []*crm.EffectiveTag{
+			{NamespacedTagKey: "123/env", NamespacedTagValue: "123/env/prod", Inherited: true},
+			{NamespacedTagKey: "my-project/team", NamespacedTagValue: "my-project/team/data"},
+		}})
+	}))
+	defer server.Close()
+	service, err := crm.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ResourceManagerTagResolver(service).Tags(context.Background(), "//storage.googleapis.com/my-bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"123/env": "prod", "my-project/team": "data"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("tags diff (-want +got):\n%s", diff)
+	}
+}
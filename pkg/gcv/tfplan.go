@@ -0,0 +1,180 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/tftarget"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ReviewTFPlan reviews each resource change of a terraform plan, in the JSON format of
+// `terraform show -json`.  Besides the resource change, the review object of each change has the
+// module path, the values of the root module's variables, the version constraint of the change's
+// provider and the plan metadata, with the workspace set with WithTFWorkspace, see the tftarget keys.
+// Every change of the plan is evaluated at the same time in
+// deterministic mode.  Violations suppressed with WithTFSuppressions are returned with their
+// suppression recorded.  Changes that fail to be reviewed are reported together in the error, the
+// violations of the other changes are still returned.  The plan is not modified.
+func (v *Validator) ReviewTFPlan(ctx context.Context, plan map[string]interface{}) ([]*validator.Violation, error) {
+	field, found, _ := unstructured.NestedFieldNoCopy(plan, "resource_changes")
+	if !found {
+		return nil, nil
+	}
+	changes, ok := field.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("resource_changes of terraform plan must be a list")
+	}
+
+	ctx = v.runContext(ctx)
+	metadata := newTFPlanMetadata(plan)
+	metadata.plan["workspace"] = TFWorkspace(ctx)
+	var violations []*validator.Violation
+	var errs multierror.Errors
+	for idx, item := range changes {
+		change, ok := item.(map[string]interface{})
+		if !ok {
+			errs.Add(fmt.Errorf("resource_changes[%d] must be an object", idx))
+			continue
+		}
+		changeViolations, err := v.ReviewTFResourceChange(ctx, metadata.review(change))
+		if err != nil {
+			errs.Add(fmt.Errorf("resource_changes[%d] %v: %w", idx, change["address"], err))
+			continue
+		}
+		suppressTFViolations(ctx, change, changeViolations)
+		violations = append(violations, changeViolations...)
+	}
+	return violations, errs.ToError()
+}
+
+type tfWorkspaceContextKey struct{}
+
+// WithTFWorkspace returns a copy of ctx which sets the terraform workspace of the plans reviewed with
+// ReviewTFPlan, exposed to templates as plan_metadata.workspace, since the plan does not record it.
+func WithTFWorkspace(ctx context.Context, workspace string) context.Context {
+	return context.WithValue(ctx, tfWorkspaceContextKey{}, workspace)
+}
+
+// TFWorkspace returns the workspace set with WithTFWorkspace, empty if none is set.
+func TFWorkspace(ctx context.Context) string {
+	workspace, _ := ctx.Value(tfWorkspaceContextKey{}).(string)
+	return workspace
+}
+
+// tfProviderConfig is an entry of configuration.provider_config in a terraform plan.
+type tfProviderConfig struct {
+	name              string
+	fullName          string
+	versionConstraint string
+	// moduleAddress is the module the provider is configured in, empty for the root module.
+	moduleAddress string
+}
+
+// tfPlanMetadata is the metadata of a terraform plan added to the review of its resource changes.
+type tfPlanMetadata struct {
+	variables map[string]interface{}
+	providers []tfProviderConfig
+	// plan is the value of tftarget.PlanMetadataKey.
+	plan map[string]interface{}
+}
+
+func newTFPlanMetadata(plan map[string]interface{}) *tfPlanMetadata {
+	metadata := &tfPlanMetadata{variables: map[string]interface{}{}}
+	// The values of variables are not marked sensitive in the plan, only their declaration is.
+	declarations, _, _ := unstructured.NestedFieldNoCopy(plan, "configuration", "root_module", "variables")
+	declarationMap, _ := declarations.(map[string]interface{})
+	nonSensitive := map[string]interface{}{}
+	variables, _, _ := unstructured.NestedFieldNoCopy(plan, "variables")
+	variableMap, _ := variables.(map[string]interface{})
+	for name, variable := range variableMap {
+		if variable, ok := variable.(map[string]interface{}); ok {
+			metadata.variables[name] = variable["value"]
+			if sensitive, _, _ := unstructured.NestedBool(declarationMap, name, "sensitive"); !sensitive {
+				nonSensitive[name] = variable["value"]
+			}
+		}
+	}
+	metadata.plan = map[string]interface{}{"variables": nonSensitive}
+	for _, key := range []string{"terraform_version", "format_version"} {
+		metadata.plan[key], _ = plan[key].(string)
+	}
+
+	providers, _, _ := unstructured.NestedFieldNoCopy(plan, "configuration", "provider_config")
+	providerMap, _ := providers.(map[string]interface{})
+	for _, provider := range providerMap {
+		provider, ok := provider.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		config := tfProviderConfig{}
+		config.name, _ = provider["name"].(string)
+		config.fullName, _ = provider["full_name"].(string)
+		config.versionConstraint, _ = provider["version_constraint"].(string)
+		config.moduleAddress, _ = provider["module_address"].(string)
+		metadata.providers = append(metadata.providers, config)
+	}
+	return metadata
+}
+
+// review returns a shallow copy of the resource change with the plan metadata.
+func (m *tfPlanMetadata) review(change map[string]interface{}) map[string]interface{} {
+	review := make(map[string]interface{}, len(change)+4)
+	for k, v := range change {
+		review[k] = v
+	}
+	modulePath, _ := change["module_address"].(string)
+	review[tftarget.ModulePathKey] = modulePath
+	review[tftarget.RootModuleVariablesKey] = m.variables
+	review[tftarget.PlanMetadataKey] = m.plan
+	providerName, _ := change["provider_name"].(string)
+	if constraint, ok := m.versionConstraint(providerName, modulePath); ok {
+		review[tftarget.ProviderVersionConstraintKey] = constraint
+	}
+	return review
+}
+
+// versionConstraint returns the version constraint of the provider, from its configuration in the
+// resource's module or else in the root module.
+func (m *tfPlanMetadata) versionConstraint(providerName, modulePath string) (string, bool) {
+	if providerName == "" {
+		return "", false
+	}
+	var root *tfProviderConfig
+	for idx := range m.providers {
+		config := &m.providers[idx]
+		if config.fullName != providerName && config.name != path.Base(providerName) {
+			continue
+		}
+		if config.versionConstraint == "" {
+			continue
+		}
+		if config.moduleAddress == modulePath {
+			return config.versionConstraint, true
+		}
+		if config.moduleAddress == "" {
+			root = config
+		}
+	}
+	if root == nil {
+		return "", false
+	}
+	return root.versionConstraint, true
+}
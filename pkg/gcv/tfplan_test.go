@@ -0,0 +1,232 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/google/go-cmp/cmp"
+)
+
+// approvedModuleTemplate requires buckets in prod to be created by the approved module.
+const approvedModuleTemplate = `
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: tfapprovedmoduleconstraintv1
+spec:
+  crd:
+    spec:
+      names:
+        kind: TFApprovedModuleConstraintV1
+      validation:
+        openAPIV3Schema:
+          type: "object"
+          properties: {}
+  targets:
+    - target: validation.resourcechange.terraform.cloud.google.com
+      rego: |
+        package templates.terraform.TFApprovedModuleConstraintV1
+
+        violation[{"msg": message}] {
+        	resource := input.review
+        	resource.type == "google_storage_bucket"
+        	resource.root_module_variables.env == "prod"
+        	not startswith(resource.module_path, "module.approved")
+        	constraint := object.get(resource, "provider_version_constraint", "none")
+        	message := sprintf("%v module=%q provider=%v", [resource.address, resource.module_path, constraint])
+        }
+`
+
+const approvedModuleConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: TFApprovedModuleConstraintV1
+metadata:
+  name: approved-module
+spec:
+  severity: high
+  parameters: {}
+`
+
+const tfPlanJSON = `{
+  "format_version": "1.1",
+  "variables": {
+    "env": {"value": "prod"}
+  },
+  "configuration": {
+    "provider_config": {
+      "google": {
+        "name": "google",
+        "full_name": "registry.terraform.io/hashicorp/google",
+        "version_constraint": ">= 4.0.0"
+      },
+      "module.legacy:google": {
+        "name": "google",
+        "full_name": "registry.terraform.io/hashicorp/google",
+        "version_constraint": "~> 3.0",
+        "module_address": "module.legacy"
+      }
+    }
+  },
+  "resource_changes": [
+    {
+      "address": "module.approved.google_storage_bucket.a",
+      "module_address": "module.approved",
+      "mode": "managed",
+      "type": "google_storage_bucket",
+      "name": "a",
+      "provider_name": "registry.terraform.io/hashicorp/google",
+      "change": {"actions": ["create"], "after": {}}
+    },
+    {
+      "address": "module.legacy.google_storage_bucket.b",
+      "module_address": "module.legacy",
+      "mode": "managed",
+      "type": "google_storage_bucket",
+      "name": "b",
+      "provider_name": "registry.terraform.io/hashicorp/google",
+      "change": {"actions": ["create"], "after": {}}
+    },
+    {
+      "address": "google_storage_bucket.c",
+      "mode": "managed",
+      "type": "google_storage_bucket",
+      "name": "c",
+      "provider_name": "registry.terraform.io/hashicorp/google",
+      "change": {"actions": ["create"], "after": {}}
+    }
+  ]
+}`
+
+func newTFPlanValidator(t *testing.T) *Validator {
+	t.Helper()
+	v, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(approvedModuleTemplate)},
+		{Path: "constraint.yaml", Content: []byte(approvedModuleConstraint)},
+	}, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	return v
+}
+
+func TestReviewTFPlan(t *testing.T) {
+	v := newTFPlanValidator(t)
+	var plan map[string]interface{}
+	if err := json.Unmarshal([]byte(tfPlanJSON), &plan); err != nil {
+		t.Fatal(err)
+	}
+	violations, err := v.ReviewTFPlan(context.Background(), plan)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	var got []string
+	for _, violation := range violations {
+		got = append(got, violation.Message)
+	}
+	sort.Strings(got)
+	want := []string{
+		`google_storage_bucket.c module="" provider=>= 4.0.0`,
+		`module.legacy.google_storage_bucket.b module="module.legacy" provider=~> 3.0`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("violations diff (-want +got):\n%s", diff)
+	}
+
+	change := plan["resource_changes"].([]interface{})[0].(map[string]interface{})
+	if _, found := change["module_path"]; found {
+		t.Errorf("ReviewTFPlan modified the plan")
+	}
+}
+
+func TestReviewTFPlanBadInput(t *testing.T) {
+	v := newTFPlanValidator(t)
+	plan := map[string]interface{}{
+		"resource_changes": []interface{}{
+			"not a resource change",
+			map[string]interface{}{"address": "google_storage_bucket.missing_fields"},
+		},
+	}
+	if _, err := v.ReviewTFPlan(context.Background(), plan); err == nil {
+		t.Errorf("expected error for malformed resource changes")
+	}
+	violations, err := v.ReviewTFPlan(context.Background(), map[string]interface{}{})
+	if err != nil || len(violations) != 0 {
+		t.Errorf("ReviewTFPlan(empty plan) = %v, %v, want no violations", violations, err)
+	}
+}
+
+func TestReviewTFResourceChangeModulePath(t *testing.T) {
+	v := newTFPlanValidator(t)
+	change := map[string]interface{}{
+		"address":        "module.other.google_storage_bucket.d",
+		"module_address": "module.other",
+		"type":           "google_storage_bucket",
+		"name":           "d",
+		"change":         map[string]interface{}{"actions": []interface{}{"create"}},
+		// Without the plan, the variables are set by the caller.
+		"root_module_variables": map[string]interface{}{"env": "prod"},
+	}
+	violations, err := v.ReviewTFResourceChange(context.Background(), change)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(violations) != 1 || violations[0].Message != `module.other.google_storage_bucket.d module="module.other" provider=none` {
+		t.Errorf("got violations %v", violations)
+	}
+}
+
+func TestTFPlanMetadata(t *testing.T) {
+	var plan map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+  "format_version": "1.1",
+  "terraform_version": "1.5.2",
+  "variables": {
+    "deletion_protection": {"value": false},
+    "db_password": {"value": "hunter2"}
+  },
+  "configuration": {
+    "root_module": {
+      "variables": {
+        "deletion_protection": {},
+        "db_password": {"sensitive": true}
+      }
+    }
+  }
+}`), &plan); err != nil {
+		t.Fatal(err)
+	}
+	metadata := newTFPlanMetadata(plan)
+	metadata.plan["workspace"] = TFWorkspace(WithTFWorkspace(context.Background(), "production"))
+	review := metadata.review(map[string]interface{}{"address": "google_sql_database_instance.db"})
+
+	want := map[string]interface{}{
+		"format_version":    "1.1",
+		"terraform_version": "1.5.2",
+		"workspace":         "production",
+		"variables":         map[string]interface{}{"deletion_protection": false},
+	}
+	if diff := cmp.Diff(want, review["plan_metadata"]); diff != "" {
+		t.Errorf("plan_metadata diff (-want +got):\n%s", diff)
+	}
+	wantVariables := map[string]interface{}{"deletion_protection": false, "db_password": "hunter2"}
+	if diff := cmp.Diff(wantVariables, review["root_module_variables"]); diff != "" {
+		t.Errorf("root_module_variables diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	// tfIgnoreComment matches suppression comments, eg
+	// # gcv:ignore=GCPStorageLoggingConstraintV1 reason="logs bucket"
+	tfIgnoreComment = regexp.MustCompile(`^\s*(?:#|//)\s*gcv:ignore=([\w,]+)(?:\s+reason=(.*))?$`)
+	// tfResourceBlock matches the first line of a managed resource block.
+	tfResourceBlock = regexp.MustCompile(`^\s*resource\s+"([^"]+)"\s+"([^"]+)"`)
+	// tfComment matches lines that only hold a comment.
+	tfComment = regexp.MustCompile(`^\s*(?:#|//)`)
+)
+
+// TFSuppression suppresses the violations of constraints of the given kinds by a terraform resource.
+// It is declared with a comment in the terraform source, either on the lines directly above the
+// resource block or inside it:
+//
+//	# gcv:ignore=GCPStorageLoggingConstraintV1,GCPStorageCMEKEncryptionConstraintV1 reason="public dataset"
+//	resource "google_storage_bucket" "public" {
+type TFSuppression struct {
+	// Address is the configuration address of the resource, eg module.net.google_compute_network.vpc,
+	// without instance keys so that it applies to every instance of the resource.
+	Address string
+	// Kinds are the constraint kinds whose violations are suppressed.
+	Kinds []string
+	// Reason is the reason given in the comment.
+	Reason string
+	// Path is the file the comment is in.
+	Path string
+	// Line is the line of the comment, starting at 1.
+	Line int
+}
+
+// ToProto returns the suppression recorded on suppressed violations.
+func (s *TFSuppression) ToProto() *validator.Suppression {
+	return &validator.Suppression{Reason: s.Reason, SourcePath: s.Path, Line: int32(s.Line)}
+}
+
+// ParseTFSuppressions returns the suppressions declared in the terraform source of a module, with
+// addresses prefixed by modulePrefix, eg "module.net.", or empty for the root module.
+func ParseTFSuppressions(path string, src []byte, modulePrefix string) []*TFSuppression {
+	var suppressions, pending []*TFSuppression
+	// address is the resource whose block is being scanned, depth the nesting of braces in it.
+	address, depth := "", 0
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if match := tfIgnoreComment.FindStringSubmatch(text); match != nil {
+			suppression := &TFSuppression{
+				Kinds:  strings.Split(strings.Trim(match[1], ","), ","),
+				Reason: strings.Trim(strings.TrimSpace(match[2]), `"`),
+				Path:   path,
+				Line:   line,
+			}
+			if depth > 0 {
+				suppression.Address = address
+				suppressions = append(suppressions, suppression)
+			} else {
+				pending = append(pending, suppression)
+			}
+			continue
+		}
+		if tfComment.MatchString(text) {
+			continue
+		}
+		if depth == 0 {
+			if match := tfResourceBlock.FindStringSubmatch(text); match != nil {
+				address = modulePrefix + match[1] + "." + match[2]
+				for _, suppression := range pending {
+					suppression.Address = address
+					suppressions = append(suppressions, suppression)
+				}
+			}
+			// Comments only apply to the block that directly follows them.
+			pending = nil
+		}
+		depth += tfBraceDepth(text)
+		if depth < 0 {
+			depth = 0
+		}
+	}
+	return suppressions
+}
+
+// tfBraceDepth returns the change in brace nesting over a line, ignoring braces in strings and
+// trailing comments.
+func tfBraceDepth(line string) int {
+	delta, quoted := 0, false
+	for idx := 0; idx < len(line); idx++ {
+		switch c := line[idx]; {
+		case quoted && c == '\\':
+			idx++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '#' || (c == '/' && strings.HasPrefix(line[idx:], "//")):
+			return delta
+		case c == '{':
+			delta++
+		case c == '}':
+			delta--
+		}
+	}
+	return delta
+}
+
+// ReadTFSuppressions reads the suppressions declared in the .tf files of the terraform root module in
+// dir, and of the local modules it calls, as listed in the configuration of the plan.  Modules with
+// registry or remote sources are not read.
+func ReadTFSuppressions(dir string, plan map[string]interface{}) ([]*TFSuppression, error) {
+	rootModule, _, _ := unstructured.NestedMap(plan, "configuration", "root_module")
+	return readTFModuleSuppressions(dir, "", rootModule)
+}
+
+func readTFModuleSuppressions(dir, modulePrefix string, module map[string]interface{}) ([]*TFSuppression, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	var suppressions []*TFSuppression
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		suppressions = append(suppressions, ParseTFSuppressions(path, src, modulePrefix)...)
+	}
+
+	calls, _, _ := unstructured.NestedMap(module, "module_calls")
+	names := make([]string, 0, len(calls))
+	for name := range calls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		call, ok := calls[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		source, _ := call["source"].(string)
+		if !strings.HasPrefix(source, "./") && !strings.HasPrefix(source, "../") {
+			continue
+		}
+		child, _, _ := unstructured.NestedMap(call, "module")
+		callSuppressions, err := readTFModuleSuppressions(filepath.Join(dir, source), modulePrefix+"module."+name+".", child)
+		if err != nil {
+			return nil, fmt.Errorf("module %s: %w", name, err)
+		}
+		suppressions = append(suppressions, callSuppressions...)
+	}
+	return suppressions, nil
+}
+
+type tfSuppressionsContextKey struct{}
+
+// WithTFSuppressions returns a copy of ctx which requests that ReviewTFPlan suppresses the violations
+// matching the suppressions.  Suppressed violations are still returned, with the suppression recorded
+// in their suppression field.
+func WithTFSuppressions(ctx context.Context, suppressions []*TFSuppression) context.Context {
+	if len(suppressions) == 0 {
+		return ctx
+	}
+	byAddress := map[string][]*TFSuppression{}
+	for _, suppression := range suppressions {
+		byAddress[suppression.Address] = append(byAddress[suppression.Address], suppression)
+	}
+	return context.WithValue(ctx, tfSuppressionsContextKey{}, byAddress)
+}
+
+// suppressTFViolations records the suppression on the violations of the resource change that are
+// suppressed by the suppressions set with WithTFSuppressions.
+func suppressTFViolations(ctx context.Context, change map[string]interface{}, violations []*validator.Violation) {
+	byAddress, _ := ctx.Value(tfSuppressionsContextKey{}).(map[string][]*TFSuppression)
+	if len(byAddress) == 0 || len(violations) == 0 {
+		return
+	}
+	suppressions := byAddress[tfConfigAddress(change)]
+	for _, violation := range violations {
+		kind := violation.GetConstraintConfig().GetKind()
+		for _, suppression := range suppressions {
+			if contains(suppression.Kinds, kind) {
+				violation.Suppression = suppression.ToProto()
+				break
+			}
+		}
+	}
+}
+
+// tfModuleInstanceKey matches the instance keys in module addresses, eg ["a"] in module.net["a"].
+var tfModuleInstanceKey = regexp.MustCompile(`\[(?:"(?:[^"\\]|\\.)*"|[^\]]*)\]`)
+
+// tfConfigAddress returns the configuration address of the resource of a change, which has no
+// instance keys.
+func tfConfigAddress(change map[string]interface{}) string {
+	modulePath, _ := change["module_address"].(string)
+	resourceType, _ := change["type"].(string)
+	name, _ := change["name"].(string)
+	address := resourceType + "." + name
+	if modulePath != "" {
+		address = tfModuleInstanceKey.ReplaceAllString(modulePath, "") + "." + address
+	}
+	return address
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
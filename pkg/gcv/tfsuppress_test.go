@@ -0,0 +1,151 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const suppressedTF = `
+# gcv:ignore=TFApprovedModuleConstraintV1 reason="legacy bucket, migrating in Q3"
+resource "google_storage_bucket" "c" {
+  name = "c"
+  labels = {
+    owner = "team-{a}" # not a block
+  }
+}
+
+# gcv:ignore=OtherConstraintV1
+
+resource "google_storage_bucket" "unsuppressed" {
+  name = "unsuppressed"
+}
+
+resource "google_storage_bucket" "inside" {
+  // gcv:ignore=TFApprovedModuleConstraintV1,OtherConstraintV1 reason=inherited
+  name = "inside"
+}
+
+module "legacy" {
+  source = "./modules/legacy"
+}
+`
+
+func TestParseTFSuppressions(t *testing.T) {
+	got := ParseTFSuppressions("main.tf", []byte(suppressedTF), "module.parent.")
+	want := []*TFSuppression{
+		{
+			Address: "module.parent.google_storage_bucket.c",
+			Kinds:   []string{"TFApprovedModuleConstraintV1"},
+			Reason:  "legacy bucket, migrating in Q3",
+			Path:    "main.tf",
+			Line:    2,
+		},
+		{
+			Address: "module.parent.google_storage_bucket.inside",
+			Kinds:   []string{"TFApprovedModuleConstraintV1", "OtherConstraintV1"},
+			Reason:  "inherited",
+			Path:    "main.tf",
+			Line:    17,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("suppressions diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestReviewTFPlanSuppressions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "modules", "legacy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(suppressedTF), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	legacyTF := "resource \"google_storage_bucket\" \"b\" {\n  # gcv:ignore=TFApprovedModuleConstraintV1 reason=\"module owned\"\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "modules", "legacy", "main.tf"), []byte(legacyTF), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	v := newTFPlanValidator(t)
+	var plan map[string]interface{}
+	if err := json.Unmarshal([]byte(tfPlanJSON), &plan); err != nil {
+		t.Fatal(err)
+	}
+	plan["configuration"].(map[string]interface{})["root_module"] = map[string]interface{}{
+		"module_calls": map[string]interface{}{
+			"legacy":   map[string]interface{}{"source": "./modules/legacy", "module": map[string]interface{}{}},
+			"approved": map[string]interface{}{"source": "terraform-google-modules/cloud-storage/google"},
+		},
+	}
+	suppressions, err := ReadTFSuppressions(dir, plan)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(suppressions) != 3 {
+		t.Fatalf("got %d suppressions, want 3: %v", len(suppressions), suppressions)
+	}
+
+	violations, err := v.ReviewTFPlan(WithTFSuppressions(context.Background(), suppressions), plan)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(violations) != 2 {
+		t.Fatalf("got %d violations, want 2: %v", len(violations), violations)
+	}
+	got := map[string]string{}
+	for _, violation := range violations {
+		if violation.Suppression == nil {
+			t.Errorf("violation %s was not suppressed", violation.Message)
+			continue
+		}
+		got[violation.Message] = violation.Suppression.Reason
+	}
+	want := map[string]string{
+		`google_storage_bucket.c module="" provider=>= 4.0.0`:                          "legacy bucket, migrating in Q3",
+		`module.legacy.google_storage_bucket.b module="module.legacy" provider=~> 3.0`: "module owned",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("suppression reasons diff (-want +got):\n%s", diff)
+	}
+
+	// Without the suppressions the violations are reported as before.
+	violations, err = v.ReviewTFPlan(context.Background(), plan)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	for _, violation := range violations {
+		if violation.Suppression != nil {
+			t.Errorf("violation %s was suppressed without suppressions", violation.Message)
+		}
+	}
+}
+
+func TestTFConfigAddress(t *testing.T) {
+	change := map[string]interface{}{
+		"module_address": `module.net["a.b"].module.subnet[0]`,
+		"type":           "google_compute_subnetwork",
+		"name":           "s",
+	}
+	if got, want := tfConfigAddress(change), "module.net.module.subnet.google_compute_subnetwork.s"; got != want {
+		t.Errorf("tfConfigAddress() = %q, want %q", got, want)
+	}
+}
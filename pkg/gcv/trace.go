@@ -0,0 +1,183 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	cfclient "github.com/open-policy-agent/frameworks/constraint/pkg/client"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/client/drivers"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/core/constraints"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/handler"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// tracerName is the instrumentation name of the spans recorded by this package.
+const tracerName = "github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+
+// tracer returns the tracer of the global tracer provider, which is a no-op unless the application
+// configures one.  It is looked up on each use so that a provider set after a review is respected.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+const (
+	// templateRunTimeNS is the name of the rego driver stat for template evaluation time.
+	templateRunTimeNS = "templateRunTimeNS"
+
+	attrAssetName   = attribute.Key("asset.name")
+	attrAssetType   = attribute.Key("asset.type")
+	attrAssetCount  = attribute.Key("asset.count")
+	attrTarget      = attribute.Key("target")
+	attrConstraints = attribute.Key("constraint.count")
+	attrViolations  = attribute.Key("violation.count")
+	attrRegoEvalNS  = attribute.Key("rego.eval_ns")
+)
+
+// endSpan records err on the span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracedReview carries the context of a review through the CF client to the target handler, which
+// has no context parameter, so that HandleReview is recorded as a child of the review span.  stats
+// are the statistics of the review if they are recorded, see WithAuditStats.
+type tracedReview struct {
+	ctx   context.Context
+	obj   interface{}
+	stats *reviewStats
+}
+
+// tracedTarget records a span for HandleReview, which converts the review object for the target.
+type tracedTarget struct {
+	handler.TargetHandler
+}
+
+func (t *tracedTarget) HandleReview(obj interface{}) (bool, interface{}, error) {
+	review, ok := obj.(tracedReview)
+	if !ok {
+		return t.TargetHandler.HandleReview(obj)
+	}
+	_, span := tracer().Start(review.ctx, t.GetName()+".HandleReview")
+	handled, result, err := t.TargetHandler.HandleReview(review.obj)
+	endSpan(span, err)
+	overlay := policyOverlayFrom(review.ctx)
+	// The evaluation time of reviews other than K8S ones, which carry it in an annotation, is set in
+	// the review object rather than in the reviewed object, see statsReview.
+	timed := t.GetName() != configs.K8STargetName
+	if handled && err == nil && (review.stats != nil || overlay != nil || timed) {
+		sr := &statsReview{review: result, stats: review.stats, overlay: overlay}
+		if timed {
+			evaluationTime := formatEvaluationTime(review.ctx)
+			sr.evaluationTime = &evaluationTime
+		}
+		result = sr
+	}
+	return handled, result, err
+}
+
+// ToMatcher wraps the matcher of the constraint so that it records the constraints selected for
+// reviews with statistics, and skips the constraints left out by the policy overlay of the review.
+func (t *tracedTarget) ToMatcher(constraint *unstructured.Unstructured) (constraints.Matcher, error) {
+	m, err := t.TargetHandler.ToMatcher(constraint)
+	if err != nil {
+		return nil, err
+	}
+	return newStatsMatcher(m, constraint), nil
+}
+
+// tracedCacherTarget is a tracedTarget for handlers that also implement handler.Cacher, the CF client
+// checks for the interface on the registered target.
+type tracedCacherTarget struct {
+	tracedTarget
+	handler.Cacher
+}
+
+// traceTarget wraps a target handler so that HandleReview is traced.
+func traceTarget(h handler.TargetHandler) handler.TargetHandler {
+	if cacher, ok := h.(handler.Cacher); ok {
+		return &tracedCacherTarget{tracedTarget: tracedTarget{TargetHandler: h}, Cacher: cacher}
+	}
+	return &tracedTarget{TargetHandler: h}
+}
+
+type regoTraceContextKey struct{}
+
+// withRegoTrace returns a copy of ctx which requests the Rego evaluation trace of reviews with it, in
+// the Trace of the CF responses.
+func withRegoTrace(ctx context.Context) context.Context {
+	return context.WithValue(ctx, regoTraceContextKey{}, true)
+}
+
+// regoTraceRequested returns true if ctx was returned by withRegoTrace.
+func regoTraceRequested(ctx context.Context) bool {
+	requested, _ := ctx.Value(regoTraceContextKey{}).(bool)
+	return requested
+}
+
+// cfReview reviews obj with the CF client for target in a span.  When the span is recorded the rego
+// evaluation time is collected from the driver, so the time spent matching constraints is the span
+// duration less HandleReview and rego.eval_ns.  The statistics of the review are added to the
+// AuditStats of ctx, if any, and the evaluated constraints are recorded if requested with
+// RecordEvaluated.
+func cfReview(ctx context.Context, client *cfclient.Client, target string, constraints int, obj interface{}) (*cftypes.Responses, error) {
+	ctx, span := tracer().Start(ctx, "cfclient.Review", trace.WithAttributes(
+		attrTarget.String(target),
+		attrConstraints.Int(constraints),
+	))
+	auditStats := auditStatsFrom(ctx)
+	evaluated := evaluatedFrom(ctx)
+	var rs *reviewStats
+	var opts []drivers.QueryOpt
+	if span.IsRecording() || auditStats != nil {
+		opts = append(opts, drivers.Stats(true))
+	}
+	if regoTraceRequested(ctx) {
+		opts = append(opts, drivers.Tracing(true))
+	}
+	if auditStats != nil || evaluated != nil {
+		rs = &reviewStats{}
+	}
+	responses, err := client.Review(ctx, tracedReview{ctx: ctx, obj: obj, stats: rs}, opts...)
+	if auditStats != nil {
+		auditStats.add(rs, responses, err)
+	}
+	if evaluated != nil {
+		evaluated.add(rs, err)
+	}
+	if err == nil && span.IsRecording() {
+		var evalNS uint64
+		for _, entry := range responses.StatsEntries {
+			for _, stat := range entry.Stats {
+				if ns, ok := stat.Value.(uint64); ok && stat.Name == templateRunTimeNS {
+					evalNS += ns
+				}
+			}
+		}
+		span.SetAttributes(attrRegoEvalNS.Int64(int64(evalNS)), attrViolations.Int(len(responses.Results())))
+	}
+	endSpan(span, err)
+	return responses, err
+}
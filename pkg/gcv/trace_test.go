@@ -0,0 +1,96 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcptarget"
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestReviewAssetSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	asset := storageAssetNoLogging()
+	violations, err := v.ReviewAsset(context.Background(), asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	var names []string
+	for _, name := range []string{"Validator.ReviewAsset", "asset.Convert", "cfclient.Review", gcptarget.Name + ".HandleReview"} {
+		if _, ok := spans[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) != 0 {
+		t.Fatalf("missing spans %v", names)
+	}
+
+	review := spans["Validator.ReviewAsset"]
+	for _, name := range []string{"asset.Convert", "cfclient.Review"} {
+		if got := spans[name].Parent().SpanID(); got != review.SpanContext().SpanID() {
+			t.Errorf("%s parent is %v, want Validator.ReviewAsset", name, got)
+		}
+	}
+	if got := spans[gcptarget.Name+".HandleReview"].Parent().SpanID(); got != spans["cfclient.Review"].SpanContext().SpanID() {
+		t.Errorf("HandleReview parent is %v, want cfclient.Review", got)
+	}
+
+	attrs := func(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		m := map[attribute.Key]attribute.Value{}
+		for _, kv := range span.Attributes() {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+	got := attrs(review)
+	want := map[attribute.Key]attribute.Value{
+		attrAssetName:  attribute.StringValue(asset.Name),
+		attrAssetType:  attribute.StringValue(asset.AssetType),
+		attrViolations: attribute.IntValue(len(violations)),
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(attribute.Value{})); diff != "" {
+		t.Errorf("ReviewAsset attributes diff (-want +got):\n%s", diff)
+	}
+	cf := attrs(spans["cfclient.Review"])
+	if cf[attrTarget].AsString() != gcptarget.Name {
+		t.Errorf("got target %q, want %q", cf[attrTarget].AsString(), gcptarget.Name)
+	}
+	if cf[attrConstraints].AsInt64() == 0 {
+		t.Errorf("got no constraints")
+	}
+	if cf[attrRegoEvalNS].AsInt64() == 0 {
+		t.Errorf("got no %s", attrRegoEvalNS)
+	}
+}
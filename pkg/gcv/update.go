@@ -0,0 +1,287 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/multierror"
+	cfclient "github.com/open-policy-agent/frameworks/constraint/pkg/client"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// target holds the CF client for a target and the templates and constraints it was given.
+type target struct {
+	client      *cfclient.Client
+	templates   *[]*cftemplates.ConstraintTemplate
+	constraints *[]*unstructured.Unstructured
+	// save stores changes to templates and constraints in the configuration, nil if they point into
+	// the configuration.
+	save func()
+}
+
+// commit stores the changes made to the target's templates and constraints.
+func (t *target) commit() {
+	if t.save != nil {
+		t.save()
+	}
+}
+
+// target returns the CF client for the constraint kind and the configuration of the named target.
+// v.mtx must be held.
+func (v *Validator) target(name, kind string) (*target, error) {
+	switch name {
+	case configs.GCPTargetName:
+		client := v.gcpCFClients.client(kind, v.config.GCPConstraints)
+		return &target{client, &v.config.GCPTemplates, &v.config.GCPConstraints, nil}, nil
+	case configs.TFTargetName:
+		return &target{v.tfCFClient, &v.config.TFTemplates, &v.config.TFConstraints, nil}, nil
+	case configs.K8STargetName:
+		if v.k8sCFClient == nil && v.disableK8STarget {
+			return nil, fmt.Errorf("K8S target is disabled")
+		}
+		if v.k8sCFClient == nil {
+			return nil, fmt.Errorf("K8S target was skipped as the configuration had no K8S templates when the Validator was created")
+		}
+		return &target{v.k8sCFClient, &v.config.K8STemplates, &v.config.K8SConstraints, nil}, nil
+	}
+	for _, custom := range v.customTargets {
+		if custom.name() == name {
+			// Map entries can't be referenced, so the slices are stored back on commit.
+			templates, constraints := v.config.CustomTemplates[name], v.config.CustomConstraints[name]
+			return &target{custom.client, &templates, &constraints, func() {
+				v.config.CustomTemplates[name], v.config.CustomConstraints[name] = templates, constraints
+			}}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown target %q", name)
+}
+
+// templateTargets returns the targets of the template.  v.mtx must be held.
+func (v *Validator) templateTargets(templ *cftemplates.ConstraintTemplate) ([]*target, error) {
+	var targets []*target
+	for _, t := range templ.Spec.Targets {
+		tgt, err := v.target(t.Target, templ.Spec.CRD.Spec.Names.Kind)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", templ.Name, err)
+		}
+		targets = append(targets, tgt)
+	}
+	return targets, nil
+}
+
+// findTemplate returns the loaded template matching fn, or nil.  v.mtx must be held.
+func (v *Validator) findTemplate(fn func(*cftemplates.ConstraintTemplate) bool) *cftemplates.ConstraintTemplate {
+	for _, templ := range v.config.Templates() {
+		if fn(templ) {
+			return templ
+		}
+	}
+	return nil
+}
+
+// AddTemplate adds a constraint template to the running Validator, replacing any loaded template with
+// the same name.  The template's kind must not belong to another loaded template.  Reviews in progress
+// complete before the template is added.  It fails with ErrUnverifiedPolicies if the Validator was
+// created with WithBundleVerification.
+func (v *Validator) AddTemplate(ctx context.Context, templ *cftemplates.ConstraintTemplate) error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	if v.bundleVerification {
+		return fmt.Errorf("%w, can't add template %s", ErrUnverifiedPolicies, templ.Name)
+	}
+	if err := v.checkBuiltins(templ); err != nil {
+		return err
+	}
+	kind := templ.Spec.CRD.Spec.Names.Kind
+	if other := v.findTemplate(func(t *cftemplates.ConstraintTemplate) bool {
+		return t.Spec.CRD.Spec.Names.Kind == kind && t.Name != templ.Name
+	}); other != nil {
+		return fmt.Errorf("template %s: kind %s already belongs to template %s", templ.Name, kind, other.Name)
+	}
+	targets, err := v.templateTargets(templ)
+	if err != nil {
+		return err
+	}
+	for _, tgt := range targets {
+		if _, err := tgt.client.AddTemplate(ctx, templ); err != nil {
+			return fmt.Errorf("failed to add template %s: %w", templ.Name, err)
+		}
+		// The slices may be shared with the Configuration the Validator was created from, so they
+		// are copied rather than modified in place.
+		templates := []*cftemplates.ConstraintTemplate{templ}
+		for _, t := range *tgt.templates {
+			if t.Name != templ.Name {
+				templates = append(templates, t)
+			}
+		}
+		*tgt.templates = templates
+		tgt.commit()
+	}
+	return nil
+}
+
+// checkBuiltins returns an error if the template calls a builtin disabled with DisableBuiltins, or
+// relies on a feature outside the capabilities set with WithRegoCapabilities.
+func (v *Validator) checkBuiltins(templ *cftemplates.ConstraintTemplate) error {
+	disabled := map[string]bool{}
+	for _, builtin := range v.disabledBuiltins {
+		disabled[builtin] = true
+	}
+	called, err := configs.TemplateBuiltins(templ)
+	if err != nil {
+		return err
+	}
+	for _, name := range called {
+		if disabled[name] {
+			return fmt.Errorf("template %s calls disabled builtin %s", templ.Name, name)
+		}
+	}
+	if v.regoCapabilities != nil {
+		return configs.CheckCapabilities(templ, v.regoCapabilities)
+	}
+	return nil
+}
+
+// RemoveTemplate removes the named constraint template and all of its constraints from the running
+// Validator.
+func (v *Validator) RemoveTemplate(ctx context.Context, name string) error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	templ := v.findTemplate(func(t *cftemplates.ConstraintTemplate) bool {
+		return t.Name == name || originalName(t) == name
+	})
+	if templ == nil {
+		return fmt.Errorf("template %s not found", name)
+	}
+	targets, err := v.templateTargets(templ)
+	if err != nil {
+		return err
+	}
+	kind := templ.Spec.CRD.Spec.Names.Kind
+	for _, tgt := range targets {
+		if _, err := tgt.client.RemoveTemplate(ctx, templ); err != nil {
+			return fmt.Errorf("failed to remove template %s: %w", name, err)
+		}
+		var templates []*cftemplates.ConstraintTemplate
+		for _, t := range *tgt.templates {
+			if t != templ {
+				templates = append(templates, t)
+			}
+		}
+		*tgt.templates = templates
+		var constraints []*unstructured.Unstructured
+		for _, c := range *tgt.constraints {
+			if c.GetKind() != kind {
+				constraints = append(constraints, c)
+			}
+		}
+		*tgt.constraints = constraints
+		tgt.commit()
+	}
+	return nil
+}
+
+// AddConstraint adds a constraint to the running Validator, replacing any loaded constraint with the
+// same kind and name.  The template for the constraint's kind must already be loaded, and the
+// constraint must have an owner if the Validator was created with RequireOwner.  It fails with
+// ErrUnverifiedPolicies if the Validator was created with WithBundleVerification.
+func (v *Validator) AddConstraint(ctx context.Context, constraint *unstructured.Unstructured) error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	if v.bundleVerification {
+		return fmt.Errorf("%w, can't add constraint %s %s", ErrUnverifiedPolicies, constraint.GetKind(), constraint.GetName())
+	}
+	kind := constraint.GetKind()
+	templ := v.findTemplate(func(t *cftemplates.ConstraintTemplate) bool {
+		return t.Spec.CRD.Spec.Names.Kind == kind
+	})
+	if templ == nil {
+		return fmt.Errorf("constraint %s %s does not correspond to any templates", kind, constraint.GetName())
+	}
+	if v.requireOwner && configs.Owner(constraint) == "" {
+		return fmt.Errorf("constraint %s %s: missing required annotation %s", kind, constraint.GetName(), configs.OwnerAnnotation)
+	}
+	if v.strictParameters {
+		var errs multierror.Errors
+		for _, err := range configs.ValidateParameters(templ, constraint) {
+			errs.Add(err)
+		}
+		if !errs.Empty() {
+			return fmt.Errorf("invalid parameters for constraint %s %s: %w", kind, constraint.GetName(), errs.ToError())
+		}
+	}
+	targets, err := v.templateTargets(templ)
+	if err != nil {
+		return err
+	}
+	for _, tgt := range targets {
+		if _, err := tgt.client.AddConstraint(ctx, constraint); err != nil {
+			return fmt.Errorf("failed to add constraint %s %s: %w", kind, constraint.GetName(), err)
+		}
+		constraints := []*unstructured.Unstructured{constraint}
+		for _, c := range *tgt.constraints {
+			if c.GetKind() != kind || c.GetName() != constraint.GetName() {
+				constraints = append(constraints, c)
+			}
+		}
+		*tgt.constraints = constraints
+		tgt.commit()
+	}
+	return nil
+}
+
+// RemoveConstraint removes the constraint with the given kind and name from the running Validator.
+func (v *Validator) RemoveConstraint(ctx context.Context, kind, name string) error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	templ := v.findTemplate(func(t *cftemplates.ConstraintTemplate) bool {
+		return t.Spec.CRD.Spec.Names.Kind == kind
+	})
+	if templ == nil {
+		return fmt.Errorf("no template for constraint kind %s", kind)
+	}
+	targets, err := v.templateTargets(templ)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, tgt := range targets {
+		var constraints []*unstructured.Unstructured
+		for _, c := range *tgt.constraints {
+			if c.GetKind() != kind || (c.GetName() != name && originalName(c) != name) {
+				constraints = append(constraints, c)
+				continue
+			}
+			found = true
+			if _, err := tgt.client.RemoveConstraint(ctx, c); err != nil {
+				return fmt.Errorf("failed to remove constraint %s %s: %w", kind, name, err)
+			}
+		}
+		*tgt.constraints = constraints
+		tgt.commit()
+	}
+	if !found {
+		return fmt.Errorf("constraint %s %s not found", kind, name)
+	}
+	return nil
+}
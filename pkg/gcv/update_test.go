@@ -0,0 +1,241 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAddRemoveConstraint(t *testing.T) {
+	ctx := context.Background()
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	violations := func() int {
+		result, err := v.ReviewJSON(ctx, storageAssetNoLoggingJSON)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		return len(result.ConstraintViolations)
+	}
+	if got := violations(); got != 2 {
+		t.Fatalf("got %d violations, want 2", got)
+	}
+
+	var constraint *unstructured.Unstructured
+	for _, c := range v.config.GCPConstraints {
+		if c.GetKind() == "CFGCPStorageLoggingConstraint" {
+			constraint = c
+		}
+	}
+	if err := v.RemoveConstraint(ctx, "CFGCPStorageLoggingConstraint", "require-storage-logging"); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := violations(); got != 1 {
+		t.Errorf("got %d violations after remove, want 1", got)
+	}
+	if err := v.RemoveConstraint(ctx, "CFGCPStorageLoggingConstraint", "require-storage-logging"); err == nil {
+		t.Errorf("expected error removing missing constraint")
+	}
+
+	if err := v.AddConstraint(ctx, constraint); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := violations(); got != 2 {
+		t.Errorf("got %d violations after add, want 2", got)
+	}
+	// Adding the same constraint again replaces it.
+	if err := v.AddConstraint(ctx, constraint); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := violations(); got != 2 {
+		t.Errorf("got %d violations after re-add, want 2", got)
+	}
+
+	unknown := constraint.DeepCopy()
+	unknown.SetKind("UnknownConstraint")
+	if err := v.AddConstraint(ctx, unknown); err == nil {
+		t.Errorf("expected error adding constraint without template")
+	}
+}
+
+func TestAddRemoveTemplate(t *testing.T) {
+	ctx := context.Background()
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	var templ *cftemplates.ConstraintTemplate
+	for _, ct := range v.config.GCPTemplates {
+		if ct.Name == "cfgcpstorageloggingconstraint" {
+			templ = ct
+		}
+	}
+
+	if err := v.RemoveTemplate(ctx, "cfgcpstorageloggingconstraint"); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	result, err := v.ReviewJSON(ctx, storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := len(result.ConstraintViolations); got != 1 {
+		t.Errorf("got %d violations after remove, want 1", got)
+	}
+	constraints, err := v.ListConstraints()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	for _, c := range constraints {
+		if c.Kind == "CFGCPStorageLoggingConstraint" {
+			t.Errorf("constraint %s still listed after template removal", c.Name)
+		}
+	}
+
+	if err := v.AddTemplate(ctx, templ); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	templates, err := v.ListTemplates()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	found := false
+	for _, d := range templates {
+		found = found || d.Kind == "CFGCPStorageLoggingConstraint"
+	}
+	if !found {
+		t.Errorf("template not listed after add")
+	}
+	if err := v.RemoveTemplate(ctx, "missing"); err == nil {
+		t.Errorf("expected error removing missing template")
+	}
+}
+
+func TestConcurrentReviewAndUpdate(t *testing.T) {
+	ctx := context.Background()
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	var constraint *unstructured.Unstructured
+	for _, c := range v.config.GCPConstraints {
+		if c.GetKind() == "CFGCPStorageLoggingConstraint" {
+			constraint = c
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := v.ReviewJSON(ctx, storageAssetNoLoggingJSON); err != nil {
+					t.Error("unexpected error", err)
+				}
+			}
+		}()
+	}
+	for j := 0; j < 10; j++ {
+		if err := v.RemoveConstraint(ctx, constraint.GetKind(), constraint.GetName()); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if err := v.AddConstraint(ctx, constraint); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestAddConstraintRequireOwner(t *testing.T) {
+	ctx := context.Background()
+	owned := `
+apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPEveryAssetConstraintV1
+metadata:
+  name: every-asset
+  annotations:
+    validation.gcp.forsetisecurity.org/owner: security
+spec:
+  severity: high
+  parameters: {}
+`
+	v, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(everyAssetTemplate)},
+		{Path: "constraint.yaml", Content: []byte(owned)},
+	}, []string{"package validator.gcp.lib\n"}, RequireOwner())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	constraint := v.config.GCPConstraints[0].DeepCopy()
+	if err := v.AddConstraint(ctx, constraint); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	constraint.SetAnnotations(nil)
+	if err := v.AddConstraint(ctx, constraint); err == nil || !strings.Contains(err.Error(), configs.OwnerAnnotation) {
+		t.Errorf("got error %v, want missing owner", err)
+	}
+}
+
+func TestAddTemplateDuplicateKind(t *testing.T) {
+	ctx := context.Background()
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	templ := v.config.GCPTemplates[0].DeepCopy()
+	templ.Name = "othertemplate"
+	if err := v.AddTemplate(ctx, templ); err == nil || !strings.Contains(err.Error(), "already belongs") {
+		t.Errorf("got error %v, want kind conflict", err)
+	}
+}
+
+func TestAddTemplateK8STargetNotSetUp(t *testing.T) {
+	ctx := context.Background()
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	templ := v.config.K8STemplates[0]
+	testCases := []struct {
+		name    string
+		opts    []Option
+		wantErr string
+	}{
+		{name: "skipped", wantErr: "no K8S templates"},
+		{name: "disabled", opts: []Option{DisableK8STarget()}, wantErr: "K8S target is disabled"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := NewValidatorFromContents([]*configs.PolicyFile{
+				{Path: "template.yaml", Content: []byte(everyAssetTemplate)},
+			}, []string{"package validator.gcp.lib\n"}, tc.opts...)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if err := v.AddTemplate(ctx, templ); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v, want %s", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -19,6 +19,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"runtime"
+	"sync"
+	"time"
 
 	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
 	asset2 "github.com/GoogleCloudPlatform/config-validator/pkg/asset"
@@ -32,6 +38,10 @@ import (
 	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
 	"github.com/open-policy-agent/frameworks/constraint/pkg/handler"
 	k8starget "github.com/open-policy-agent/gatekeeper/v3/pkg/target"
+	"github.com/open-policy-agent/opa/ast"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/structpb"
+	admissionv1 "k8s.io/api/admission/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -41,8 +51,46 @@ const (
 	ancestryPathKey = "ancestry_path"
 	// The JSON object key for ancestors list
 	ancestorSliceKey = "ancestors"
+	// The JSON object key for the time the policies are evaluated at
+	evaluationTimeKey = "evaluation_time"
+	// The annotation of K8S resources for the time the policies are evaluated at
+	evaluationTimeAnnotation = "validator.forsetisecurity.org/evaluationTime"
 )
 
+type evaluationTimeContextKey struct{}
+
+// WithEvaluationTime returns a copy of ctx which requests that reviews are evaluated as of the given time
+// rather than the current time.  The time is exposed to templates as the evaluation_time field of
+// input.review (or the validator.forsetisecurity.org/evaluationTime annotation for K8S resources)
+// in RFC 3339 format, and returned by time.now_ns(), so that templates evaluate historical exports as
+// of the time.  The time is set outside of the reviewed object, which is left unchanged, and replaces
+// any evaluation_time the object has.
+func WithEvaluationTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, evaluationTimeContextKey{}, t)
+}
+
+// EvaluationTime returns the evaluation time set by WithEvaluationTime.
+func EvaluationTime(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(evaluationTimeContextKey{}).(time.Time)
+	return t, ok
+}
+
+type samplingContextKey struct{}
+
+// WithSampling returns a copy of ctx which requests that constraints annotated with
+// gcptarget.SamplingAnnotation are only evaluated against their deterministic sample of assets.
+// This trades completeness for latency and is intended for continuous review, scheduled audits
+// should use a context without sampling so that every constraint is fully evaluated.
+func WithSampling(ctx context.Context) context.Context {
+	return context.WithValue(ctx, samplingContextKey{}, true)
+}
+
+// SamplingApplied returns true if sampling was requested with WithSampling.
+func SamplingApplied(ctx context.Context) bool {
+	applied, _ := ctx.Value(samplingContextKey{}).(bool)
+	return applied
+}
+
 type ConfigValidator interface {
 	ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error)
 }
@@ -60,49 +108,409 @@ type ConfigValidator interface {
 // Any data added in AddData stays in the underlying rule evaluation engine's memory.
 // To avoid out of memory errors, callers can invoke Reset to delete existing data.
 type Validator struct {
-	gcpCFClient *cfclient.Client
-	k8sCFClient *cfclient.Client
-	tfCFClient  *cfclient.Client
+	// gcpCFClients are the GCP CF clients, see ConstraintShards.
+	gcpCFClients *clientShards
+	k8sCFClient  *cfclient.Client
+	tfCFClient   *cfclient.Client
+	// config is the configuration the CF clients were created from.
+	config *configs.Configuration
+	// mtx is held for writing while templates and constraints are added or removed, so that reviews
+	// and listings see the CF clients and config in a consistent state.
+	mtx sync.RWMutex
+	// ancestryLimits bounds the ancestry paths of reviewed assets.
+	ancestryLimits asset2.AncestryLimits
+	// workerCount is the number of concurrent reviews for ParallelValidator and ReviewCAIExport.
+	workerCount int
+	// requestLimits bound the assets of a ParallelValidator review request.
+	requestLimits requestLimits
+	// enforceOnly are the enforcement actions set with EnforceOnly.
+	enforceOnly []string
+	// ancestorIAM enables storing ancestor IAM policies for GCP constraints.
+	ancestorIAM bool
+	// k8sExpansion expands K8S resources with the ExpansionTemplates of the configuration.
+	k8sExpansion bool
+	// ancestorIAMKeys are the ancestors with stored IAM policies.
+	ancestorIAMKeys map[string]bool
+	// clock is the time reviews are evaluated at, nil if evaluation_time is only set on request.
+	clock Clock
+	// deterministic fixes the evaluation time for each run of reviews.
+	deterministic bool
+	// projectNumbers maps project IDs to project numbers in asset names and ancestry paths.
+	projectNumbers map[string]string
+	// tagResolver resolves the tags of GCP assets without tags, nil if not set.
+	tagResolver TagResolver
+	// strictParameters rejects constraints with parameters that don't match their template's schema.
+	strictParameters bool
+	// requireOwner rejects constraints added with AddConstraint without an owner annotation.
+	requireOwner bool
+	// disableK8STarget is set if the K8S CF client was disabled with DisableK8STarget rather than
+	// skipped for lack of K8S templates.
+	disableK8STarget bool
+	// disabledBuiltins are the builtins disabled with DisableBuiltins, templates added with
+	// AddTemplate must not call them.
+	disabledBuiltins []string
+	// regoCapabilities are the capabilities set with WithRegoCapabilities, templates added with
+	// AddTemplate must not rely on features outside them.  Nil if not pinned.
+	regoCapabilities *ast.Capabilities
+	// loadReport is the outcome of loading the configuration.
+	loadReport *LoadReport
+	// customTargets are the targets registered with WithTarget, with their CF clients.
+	customTargets []*customTarget
+	// bundleVerification is true if the policies were loaded from a signed bundle archive, see
+	// WithBundleVerification, and may not be changed.
+	bundleVerification bool
 }
 
 // Stores functional options for CF client
 type initOptions struct {
 	driverArgs []rego.Arg
 	clientArgs []cfclient.Opt
+	// disabledBuiltins are the builtins disabled with DisableBuiltins.
+	disabledBuiltins []string
+	// disableK8STarget skips creating the K8S CF client.
+	disableK8STarget bool
+	// requireOwner rejects constraints without an owner annotation.
+	requireOwner bool
+	// strictParameters rejects constraints with parameters that don't match their template's schema.
+	strictParameters bool
+	// skipDisabledBuiltins skips the templates that call disabled builtins instead of rejecting them.
+	skipDisabledBuiltins bool
+	// lenientLoad skips the templates and constraints that fail to load instead of failing the load.
+	lenientLoad bool
+	// conflictPolicy resolves templates and constraints that are declared more than once.
+	conflictPolicy configs.ConflictPolicy
+	// regoCapabilities are the capabilities templates are compiled with, nil for those of the linked
+	// OPA version.
+	regoCapabilities *ast.Capabilities
+	// ancestryLimits bounds the ancestry paths of reviewed assets.
+	ancestryLimits asset2.AncestryLimits
+	// workerCount is the number of review workers, zero if not set.
+	workerCount int
+	// requestLimits bound the assets of a ParallelValidator review request.
+	requestLimits requestLimits
+	// enforceOnly are the enforcement actions of the violations returned by a ParallelValidator.
+	enforceOnly []string
+	// ancestorIAM enables storing ancestor IAM policies for GCP constraints.
+	ancestorIAM bool
+	// k8sExpansion expands K8S resources with the ExpansionTemplates of the configuration.
+	k8sExpansion bool
+	// clock is the time reviews are evaluated at.
+	clock Clock
+	// deterministic fixes the evaluation time for each run of reviews.
+	deterministic bool
+	// projectNumbers maps project IDs to project numbers in asset names and ancestry paths.
+	projectNumbers map[string]string
+	// tagResolver resolves the tags of GCP assets without tags, nil if not set.
+	tagResolver TagResolver
+	// constraintShards is the number of GCP CF clients, see ConstraintShards.
+	constraintShards int
+	// customTargets are the targets registered with WithTarget.
+	customTargets []*customTarget
+	// bundleVerificationKey is the path of the public key that the bundle archive signature must
+	// verify with, empty if policies are not verified.
+	bundleVerificationKey string
 }
 
 type Option = func(*initOptions)
 
+func newInitOptions(opts ...Option) *initOptions {
+	options := &initOptions{ancestryLimits: asset2.DefaultAncestryLimits}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// DisableBuiltins disables the named rego builtins, eg http.send, in the Constraint Framework clients of
+// every target.  Configurations with templates that call a disabled builtin are rejected, unless
+// SkipDisabledBuiltins is also given.  Templates added with AddTemplate that call a disabled builtin
+// are always rejected.
 func DisableBuiltins(builtins ...string) Option {
 	return func(o *initOptions) {
 		o.driverArgs = append(o.driverArgs, rego.DisableBuiltins(builtins...))
+		o.disabledBuiltins = append(o.disabledBuiltins, builtins...)
+	}
+}
+
+// DisableK8STarget skips setting up the K8S Constraint Framework client.  K8S assets are not reviewed
+// and any K8S templates and constraints in the configuration are ignored.  The K8S client is also
+// skipped automatically when the configuration contains no K8S templates.
+func DisableK8STarget() Option {
+	return func(o *initOptions) {
+		o.disableK8STarget = true
+	}
+}
+
+// RequireOwner rejects configurations with constraints that don't have the configs.OwnerAnnotation
+// annotation, so that every violation can be routed to an owner with PartitionByOwner.
+func RequireOwner() Option {
+	return func(o *initOptions) {
+		o.requireOwner = true
+	}
+}
+
+// StrictParameters rejects configurations, and constraints added with AddConstraint, that have
+// parameters which are not declared in the template's openAPIV3Schema or have the wrong type.  Without
+// it these problems are only logged as warnings when the configuration is loaded, see
+// configs.ValidateParameters.
+func StrictParameters() Option {
+	return func(o *initOptions) {
+		o.strictParameters = true
+	}
+}
+
+// SkipDisabledBuiltins skips the templates that call a builtin disabled with DisableBuiltins, along
+// with their constraints, and logs them as warnings, instead of rejecting the configuration.
+func SkipDisabledBuiltins() Option {
+	return func(o *initOptions) {
+		o.skipDisabledBuiltins = true
 	}
 }
 
+// WithLenientLoad skips the templates and constraints that fail to load, eg a constraint without a
+// template or a template whose rego doesn't compile, as well as the constraints of skipped templates,
+// instead of failing to create the Validator, so that a bad policy degrades enforcement rather than
+// stopping it.  Each skipped object is reported as a warning and in LoadReport.Skipped.  Policy files
+// that can't be read, templates that call disabled builtins without SkipDisabledBuiltins, and the checks
+// requested with options such as RequireOwner, still fail the load.
+func WithLenientLoad() Option {
+	return func(o *initOptions) {
+		o.lenientLoad = true
+	}
+}
+
+// WithConflictPolicy sets how templates with the same name or CRD kind, and constraints with the same
+// kind and name, declared more than once across the policy paths are resolved, see
+// configs.ConflictPolicy.  By default they fail to create the Validator.
+func WithConflictPolicy(policy configs.ConflictPolicy) Option {
+	return func(o *initOptions) {
+		o.conflictPolicy = policy
+	}
+}
+
+// WithRegoCapabilities pins the OPA capabilities templates are compiled with, eg as loaded with
+// ast.LoadCapabilitiesVersion, so that policies have the same semantics across OPA upgrades.  Builtins
+// of the linked OPA version that the capabilities don't declare are disabled in the Constraint
+// Framework clients.  Configurations with templates that rely on future keywords or builtins outside
+// the capabilities are rejected, as are such templates added with AddTemplate.
+func WithRegoCapabilities(capabilities *ast.Capabilities) Option {
+	return func(o *initOptions) {
+		declared := map[string]bool{}
+		for _, builtin := range capabilities.Builtins {
+			declared[builtin.Name] = true
+		}
+		var unpinned []string
+		for _, builtin := range ast.CapabilitiesForThisVersion().Builtins {
+			if !declared[builtin.Name] {
+				unpinned = append(unpinned, builtin.Name)
+			}
+		}
+		if len(unpinned) != 0 {
+			o.driverArgs = append(o.driverArgs, rego.DisableBuiltins(unpinned...))
+		}
+		o.regoCapabilities = capabilities
+	}
+}
+
+// LimitAncestry sets the maximum number of components and length in bytes of the ancestry paths of
+// reviewed assets, zero disables a limit.  Assets exceeding the limits fail review.  The defaults are
+// asset.DefaultMaxAncestryDepth and asset.DefaultMaxAncestryLength.
+func LimitAncestry(maxDepth, maxLength int) Option {
+	return func(o *initOptions) {
+		o.ancestryLimits = asset2.AncestryLimits{MaxDepth: maxDepth, MaxLength: maxLength}
+	}
+}
+
+// AncestorIAM makes the IAM policies of organizations, folders and projects added with AddAncestorIAM,
+// or read from the export in ReviewCAIExport, available to GCP constraints as
+// data.inventory.ancestors_iam keyed by ancestry path segment, eg "folders/123".  Constraints can then
+// evaluate the access a resource inherits from its ancestors.
+func AncestorIAM() Option {
+	return func(o *initOptions) {
+		o.ancestorIAM = true
+	}
+}
+
+// WithWorkerCount sets the number of assets reviewed concurrently by a ParallelValidator, and by
+// ReviewCAIExport unless ExportWorkers is given.  Values less than one use the default, the number of
+// CPUs.
+func WithWorkerCount(n int) Option {
+	return func(o *initOptions) {
+		o.workerCount = n
+	}
+}
+
+// MaxAssetsPerRequest limits the number of assets in a review request of a ParallelValidator, requests
+// with more are rejected with ErrRequestTooLarge.  Zero, the default, disables the limit.
+func MaxAssetsPerRequest(n int) Option {
+	return func(o *initOptions) {
+		o.requestLimits.maxAssets = n
+	}
+}
+
+// MaxAssetBytes limits the size of each asset, in its proto wire format, in a review request of a
+// ParallelValidator, requests with a larger asset are rejected with ErrRequestTooLarge.  Zero, the
+// default, disables the limit.
+func MaxAssetBytes(n int) Option {
+	return func(o *initOptions) {
+		o.requestLimits.maxAssetBytes = n
+	}
+}
+
+// ProjectNumbers sets the project numbers of project IDs.  Asset names and ancestry paths that use a
+// project ID are normalized to use the project number, so that constraints and exclusions written
+// against project numbers match regardless of which form the asset producer used.
+func ProjectNumbers(projectNumbers map[string]string) Option {
+	return func(o *initOptions) {
+		o.projectNumbers = projectNumbers
+	}
+}
+
+// workers returns the configured worker count or the default.
+func (o *initOptions) workers() int {
+	if o.workerCount < 1 {
+		return runtime.NumCPU()
+	}
+	return o.workerCount
+}
+
 // NewValidatorConfig returns a new ValidatorConfig.
 // By default it will initialize the underlying query evaluation engine by loading supporting library, constraints, and constraint templates.
 // We may want to make this initialization behavior configurable in the future.
 func NewValidatorConfig(policyPaths []string, policyLibraryPath string) (*configs.Configuration, error) {
+	return newValidatorConfig(policyPaths, policyLibraryPath, configs.LoadOptions{})
+}
+
+// newValidatorConfig is NewValidatorConfig loaded with the given options, see
+// configs.NewConfigurationWithOptions.  Bundle archives are always loaded strictly.
+func newValidatorConfig(policyPaths []string, policyLibraryPath string, loadOptions configs.LoadOptions) (*configs.Configuration, error) {
 	if len(policyPaths) == 0 {
 		return nil, fmt.Errorf("No policy path set, provide an option to set the policy path gcv.PolicyPath")
 	}
-	if policyLibraryPath == "" {
+	if len(policyPaths) == 1 && configs.IsBundleArchive(policyPaths[0]) {
+		if policyLibraryPath != "" {
+			return nil, fmt.Errorf("policy bundle archive %s provides its own library, policy library must not be set", policyPaths[0])
+		}
+		glog.V(logRequestsVerboseLevel).Infof("loading policy bundle archive: %s", policyPaths[0])
+		return readBundleArchive(policyPaths[0])
+	}
+	if policyLibraryPath == "" && !anyBundle(policyPaths) {
 		return nil, fmt.Errorf("No policy library set")
 	}
 	glog.V(logRequestsVerboseLevel).Infof("loading policy dir: %v lib dir: %s", policyPaths, policyLibraryPath)
-	return configs.NewConfiguration(policyPaths, policyLibraryPath)
+	return configs.NewConfigurationWithOptions(policyPaths, policyLibraryPath, loadOptions)
+}
+
+func readBundleArchive(path string) (*configs.Configuration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	config, manifest, err := configs.ReadBundleArchive(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policy bundle archive %s: %w", path, err)
+	}
+	glog.V(logRequestsVerboseLevel).Infof("loaded %d templates and %d constraints from %s",
+		manifest.Templates, manifest.Constraints, path)
+	return config, nil
+}
+
+// anyBundle returns true if any of the policy paths is a policy bundle that provides its own library.
+func anyBundle(policyPaths []string) bool {
+	for _, p := range policyPaths {
+		if configs.IsBundle(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// skippedPolicies are the templates and constraints that failed to load into a CF client with
+// WithLenientLoad.
+type skippedPolicies struct {
+	templates   map[string]bool
+	constraints map[*unstructured.Unstructured]bool
+}
+
+func newSkippedPolicies() *skippedPolicies {
+	return &skippedPolicies{templates: map[string]bool{}, constraints: map[*unstructured.Unstructured]bool{}}
 }
 
+// newCFClient creates a CF client for the target with the templates and constraints, recording the
+// objects that were loaded or failed to load in report.  If skipped is not nil the objects that fail
+// to load are recorded in it, and as skipped in report, rather than failing the client.
 func newCFClient(
 	targetHandler handler.TargetHandler,
 	templates []*cftemplates.ConstraintTemplate,
 	constraints []*unstructured.Unstructured,
+	report *LoadReport,
+	skipped *skippedPolicies,
 	opts ...Option) (
 	*cfclient.Client, error) {
 
+	cfClient, err := newEmptyCFClient(targetHandler, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	target := targetHandler.GetName()
+	capabilities := newInitOptions(opts...).regoCapabilities
+	skippedKinds := map[string]bool{}
+	var errs multierror.Errors
+	for _, template := range templates {
+		if _, err := cfClient.AddTemplate(ctx, template); err != nil {
+			errored := templateObject(template, target, err.Error())
+			errored.RegoErrors = regoErrors(template, target, err, capabilities)
+			if skipped != nil {
+				skipped.templates[template.Name] = true
+				skippedKinds[template.Spec.CRD.Spec.Names.Kind] = true
+				report.Skipped = append(report.Skipped, errored)
+				continue
+			}
+			report.Errored = append(report.Errored, errored)
+			if errored.Path != "" {
+				errs.Add(fmt.Errorf("failed to add template %s from %s: %w", template.Name, errored.Path, err))
+			} else {
+				errs.Add(fmt.Errorf("failed to add template %s: %w", template.Name, err))
+			}
+			continue
+		}
+		report.Loaded = append(report.Loaded, templateObject(template, target, ""))
+	}
+	if !errs.Empty() {
+		return nil, errs.ToError()
+	}
+
+	for _, constraint := range constraints {
+		if skippedKinds[constraint.GetKind()] {
+			report.Skipped = append(report.Skipped, constraintObject(constraint, target, "template failed to load"))
+			continue
+		}
+		if _, err := cfClient.AddConstraint(ctx, constraint); err != nil {
+			if skipped != nil {
+				skipped.constraints[constraint] = true
+				report.Skipped = append(report.Skipped, constraintObject(constraint, target, err.Error()))
+				continue
+			}
+			errs.Add(fmt.Errorf("failed to add constraint %s: %w", constraint, err))
+			report.Errored = append(report.Errored, constraintObject(constraint, target, err.Error()))
+			continue
+		}
+		report.Loaded = append(report.Loaded, constraintObject(constraint, target, ""))
+	}
+	if !errs.Empty() {
+		return nil, errs.ToError()
+	}
+	return cfClient, nil
+}
+
+// newEmptyCFClient creates a CF client for the target without any templates or constraints.
+func newEmptyCFClient(targetHandler handler.TargetHandler, opts ...Option) (*cfclient.Client, error) {
 	options := &initOptions{
 		driverArgs: []rego.Arg{rego.Tracing(false)},
-		clientArgs: []cfclient.Opt{cfclient.Targets(targetHandler)},
+		clientArgs: []cfclient.Opt{cfclient.Targets(traceTarget(targetHandler))},
 	}
 
 	for _, opt := range opts {
@@ -114,68 +522,275 @@ func newCFClient(
 		return nil, fmt.Errorf("unable to create new driver: %w", err)
 	}
 	// Append driver option after creation
-	args := append(options.clientArgs, cfclient.Driver(driver))
+	args := append(options.clientArgs, cfclient.Driver(&overrideDriver{driver}))
 	cfClient, err := cfclient.NewClient(args...)
 	if err != nil {
 		return nil, fmt.Errorf("unable to set up Constraint Framework client: %w", err)
 	}
+	return cfClient, nil
+}
 
-	ctx := context.Background()
-	var errs multierror.Errors
-	for _, template := range templates {
-		if _, err := cfClient.AddTemplate(ctx, template); err != nil {
-			errs.Add(fmt.Errorf("failed to add template %v: %w", template, err))
+// NewValidatorFromConfig creates the validator from a config.  The outcome of the load is logged and
+// available from LoadReport, or from the *LoadError returned if the load fails.  It fails with
+// ErrUnverifiedPolicies if WithBundleVerification is given.
+func NewValidatorFromConfig(config *configs.Configuration, opts ...Option) (*Validator, error) {
+	if newInitOptions(opts...).bundleVerificationKey != "" {
+		return nil, ErrUnverifiedPolicies
+	}
+	return newValidatorFromConfig(config, &LoadReport{StartTime: time.Now()}, opts...)
+}
+
+// newValidatorFromConfig creates the validator from a config, completing the report of the load.
+func newValidatorFromConfig(config *configs.Configuration, report *LoadReport, opts ...Option) (*Validator, error) {
+	compileStart := time.Now()
+	fail := func(err error) (*Validator, error) {
+		report.Err = err
+		report.CompileDuration = time.Since(compileStart)
+		report.Warnings = config.Warnings
+		report.log()
+		return nil, &LoadError{Report: report}
+	}
+	failIssues := func(issues []*configs.Issue) (*Validator, error) {
+		var errs multierror.Errors
+		for _, issue := range issues {
+			errs.Add(fmt.Errorf("%s", issue))
+			report.Errored = append(report.Errored, issueObject(issue))
 		}
+		return fail(errs.ToError())
 	}
-	if !errs.Empty() {
-		return nil, errs.ToError()
+
+	options := newInitOptions(opts...)
+	if options.requireOwner {
+		if issues := config.MissingOwners(); len(issues) != 0 {
+			return failIssues(issues)
+		}
+	}
+	if options.strictParameters {
+		if issues := config.InvalidParameters(); len(issues) != 0 {
+			return failIssues(issues)
+		}
+	}
+	if issues := config.DisabledBuiltinUses(options.disabledBuiltins); len(issues) != 0 {
+		if !options.skipDisabledBuiltins {
+			return failIssues(issues)
+		}
+		skipped := map[string]bool{}
+		for _, issue := range issues {
+			skipped[issue.Name] = true
+		}
+		report.Skipped = append(report.Skipped, skippedObjects(config, skipped, "calls a disabled builtin")...)
+		config = config.WithoutTemplates(skipped)
+		for _, issue := range issues {
+			warning := *issue
+			warning.Warning = true
+			warning.Message += ", skipping the template and its constraints"
+			glog.Warningf("%s", &warning)
+			config.Warnings = append(config.Warnings, &warning)
+		}
 	}
 
-	for _, constraint := range constraints {
-		if _, err := cfClient.AddConstraint(ctx, constraint); err != nil {
-			errs.Add(fmt.Errorf("failed to add constraint %s: %w", constraint, err))
+	if options.regoCapabilities != nil {
+		if issues := config.UnsupportedFeatureUses(options.regoCapabilities); len(issues) != 0 {
+			return failIssues(issues)
 		}
 	}
-	if !errs.Empty() {
-		return nil, errs.ToError()
+
+	registeredTargets := options.targets(config)
+	if err := checkCustomTargets(config, registeredTargets); err != nil {
+		return fail(err)
 	}
-	return cfClient, nil
-}
+	config = copyCustomTargets(config)
 
-// NewValidatorFromConfig creates the validator from a config.
-func NewValidatorFromConfig(config *configs.Configuration, opts ...Option) (*Validator, error) {
-	gcpCFClient, err := newCFClient(gcptarget.New(), config.GCPTemplates, config.GCPConstraints, opts...)
-	if err != nil {
-		return nil, fmt.Errorf("unable to set up GCP Constraint Framework client: %w", err)
+	// Each target has its own CF client and rego driver, so the clients are built concurrently.  Within
+	// a client templates are compiled one at a time as the client serializes AddTemplate.  Each client
+	// records the objects it loaded in its own report, which are merged once all are built.
+	var k8sCFClient, tfCFClient *cfclient.Client
+	var k8sErr, tfErr error
+	var wg sync.WaitGroup
+	var targetReports []*LoadReport
+	var targetSkips []*skippedPolicies
+	build := func(client **cfclient.Client, err *error, targetHandler handler.TargetHandler,
+		templates []*cftemplates.ConstraintTemplate, constraints []*unstructured.Unstructured) {
+		targetReport := &LoadReport{}
+		targetReports = append(targetReports, targetReport)
+		var skipped *skippedPolicies
+		if options.lenientLoad {
+			skipped = newSkippedPolicies()
+			targetSkips = append(targetSkips, skipped)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			*client, *err = newCFClient(targetHandler, templates, constraints, targetReport, skipped, opts...)
+		}()
 	}
 
-	k8sCFClient, err := newCFClient(&k8starget.K8sValidationTarget{}, config.K8STemplates, config.K8SConstraints, opts...)
-	if err != nil {
-		return nil, fmt.Errorf("unable to set up K8S Constraint Framework client: %w", err)
+	// The GCP constraints may be split across several clients, which are also built concurrently.
+	gcpCFClients := &clientShards{clients: make([]*cfclient.Client, 1), kinds: map[string]int{}}
+	if options.constraintShards > 1 {
+		gcpCFClients.clients = make([]*cfclient.Client, options.constraintShards)
+		gcpCFClients.kinds = assignShards(options.constraintShards, config.GCPTemplates, config.GCPConstraints)
+	}
+	gcpErrs := make([]error, len(gcpCFClients.clients))
+	shardTemplates, shardConstraints := gcpCFClients.split(config.GCPTemplates, config.GCPConstraints)
+	for idx := range gcpCFClients.clients {
+		build(&gcpCFClients.clients[idx], &gcpErrs[idx], gcptarget.New(), shardTemplates[idx], shardConstraints[idx])
+	}
+	switch {
+	case options.disableK8STarget:
+		if len(config.K8STemplates) != 0 {
+			glog.Warningf("K8S target disabled, ignoring %d K8S templates and %d K8S constraints",
+				len(config.K8STemplates), len(config.K8SConstraints))
+			k8sTarget := (&k8starget.K8sValidationTarget{}).GetName()
+			for _, templ := range config.K8STemplates {
+				report.Skipped = append(report.Skipped, templateObject(templ, k8sTarget, "K8S target disabled"))
+			}
+			for _, constraint := range config.K8SConstraints {
+				report.Skipped = append(report.Skipped, constraintObject(constraint, k8sTarget, "K8S target disabled"))
+			}
+		}
+	case len(config.K8STemplates) == 0:
+		glog.V(1).Infof("no K8S templates found, skipping K8S target")
+	default:
+		build(&k8sCFClient, &k8sErr, &k8starget.K8sValidationTarget{}, config.K8STemplates, config.K8SConstraints)
+	}
+	build(&tfCFClient, &tfErr, tftarget.New(), config.TFTemplates, config.TFConstraints)
+	// The registered targets are copied as the options may be used to create several Validators.
+	customTargets := make([]*customTarget, len(registeredTargets))
+	customErrs := make([]error, len(registeredTargets))
+	for idx, registered := range registeredTargets {
+		tgt := &customTarget{handler: registered.handler, classify: registered.classify}
+		customTargets[idx] = tgt
+		build(&tgt.client, &customErrs[idx], tgt.handler, config.CustomTemplates[tgt.name()], config.CustomConstraints[tgt.name()])
+	}
+	wg.Wait()
+	for _, targetReport := range targetReports {
+		report.Loaded = append(report.Loaded, targetReport.Loaded...)
+		report.Skipped = append(report.Skipped, targetReport.Skipped...)
+		report.Errored = append(report.Errored, targetReport.Errored...)
+		for _, o := range targetReport.Skipped {
+			warning := &configs.Issue{Path: o.Path, Kind: o.Kind, Name: o.Name, Warning: true, Message: o.Message + ", skipping it"}
+			glog.Warningf("%s", warning)
+			config.Warnings = append(config.Warnings, warning)
+		}
+	}
+	// The skipped templates and constraints are left out of the configuration, so that they are not
+	// listed as loaded.
+	skippedTemplates := map[string]bool{}
+	skippedConstraints := map[*unstructured.Unstructured]bool{}
+	for _, skipped := range targetSkips {
+		for name := range skipped.templates {
+			skippedTemplates[name] = true
+		}
+		for constraint := range skipped.constraints {
+			skippedConstraints[constraint] = true
+		}
+	}
+	if len(skippedTemplates) != 0 || len(skippedConstraints) != 0 {
+		config = config.WithoutTemplates(skippedTemplates).WithoutConstraints(skippedConstraints)
 	}
 
-	tfCFClient, err := newCFClient(tftarget.New(), config.TFTemplates, config.TFConstraints, opts...)
-	if err != nil {
-		return nil, fmt.Errorf("unable to set up TF Constraint Framework client: %w", err)
+	// The failures of every target are reported together, so that all the broken templates are fixed
+	// in one go.
+	var clientErrs multierror.Errors
+	for _, gcpErr := range gcpErrs {
+		if gcpErr != nil {
+			clientErrs.Add(fmt.Errorf("unable to set up GCP Constraint Framework client: %w", gcpErr))
+		}
+	}
+	if k8sErr != nil {
+		clientErrs.Add(fmt.Errorf("unable to set up K8S Constraint Framework client: %w", k8sErr))
+	}
+	if tfErr != nil {
+		clientErrs.Add(fmt.Errorf("unable to set up TF Constraint Framework client: %w", tfErr))
+	}
+	for idx, customErr := range customErrs {
+		if customErr != nil {
+			clientErrs.Add(fmt.Errorf("unable to set up %s Constraint Framework client: %w", customTargets[idx].name(), customErr))
+		}
 	}
+	if !clientErrs.Empty() {
+		return fail(clientErrs.ToError())
+	}
+	report.CompileDuration = time.Since(compileStart)
+	report.Warnings = config.Warnings
+	report.log()
 
 	ret := &Validator{
-		gcpCFClient: gcpCFClient,
-		k8sCFClient: k8sCFClient,
-		tfCFClient:  tfCFClient,
+		gcpCFClients:   gcpCFClients,
+		k8sCFClient:    k8sCFClient,
+		tfCFClient:     tfCFClient,
+		config:         config,
+		ancestryLimits: options.ancestryLimits,
+		workerCount:    options.workers(),
+		requestLimits:  options.requestLimits,
+		enforceOnly:    options.enforceOnly,
+		ancestorIAM:    options.ancestorIAM,
+		k8sExpansion:   options.k8sExpansion,
+		clock:          options.clock,
+		deterministic:  options.deterministic,
+		projectNumbers: options.projectNumbers,
+		tagResolver:    options.tagResolver,
+		loadReport:     report,
+		customTargets:  customTargets,
+
+		strictParameters: options.strictParameters,
+		requireOwner:     options.requireOwner,
+		disableK8STarget: options.disableK8STarget,
+		disabledBuiltins: options.disabledBuiltins,
+		regoCapabilities: options.regoCapabilities,
+
+		bundleVerification: options.bundleVerificationKey != "",
+	}
+	if ret.deterministic && ret.clock == nil {
+		ret.clock = systemClock{}
 	}
 	return ret, nil
 }
 
+// skippedObjects returns the named templates and the constraints of their kinds.
+func skippedObjects(config *configs.Configuration, names map[string]bool, message string) []*LoadedObject {
+	var objects []*LoadedObject
+	kinds := map[string]bool{}
+	for _, templ := range config.Templates() {
+		if names[templ.Name] {
+			kinds[templ.Spec.CRD.Spec.Names.Kind] = true
+			objects = append(objects, templateObject(templ, "", message))
+		}
+	}
+	for _, constraint := range config.Constraints() {
+		if kinds[constraint.GetKind()] {
+			objects = append(objects, constraintObject(constraint, "", message))
+		}
+	}
+	return objects
+}
+
 // NewValidator returns a new Validator.
 // By default it will initialize the underlying query evaluation engine by loading supporting library, constraints, and constraint templates.
 // We may want to make this initialization behavior configurable in the future.
+//
+// If WithBundleVerification is given, the only policy path must be a bundle archive with a signature
+// that verifies, and the policy library must not be set.
 func NewValidator(policyPaths []string, policyLibraryPath string, opts ...Option) (*Validator, error) {
-	config, err := NewValidatorConfig(policyPaths, policyLibraryPath)
+	start := time.Now()
+	var config *configs.Configuration
+	var err error
+	options := newInitOptions(opts...)
+	if options.bundleVerificationKey != "" {
+		config, err = readSignedBundleArchive(policyPaths, policyLibraryPath, options.bundleVerificationKey)
+	} else {
+		config, err = newValidatorConfig(policyPaths, policyLibraryPath, configs.LoadOptions{
+			Lenient:        options.lenientLoad,
+			ConflictPolicy: options.conflictPolicy,
+		})
+	}
 	if err != nil {
-		return nil, err
+		report := &LoadReport{StartTime: start, ConfigDuration: time.Since(start), Err: err}
+		report.log()
+		return nil, &LoadError{Report: report}
 	}
-	return NewValidatorFromConfig(config, opts...)
+	return newValidatorFromConfig(config, &LoadReport{StartTime: start, ConfigDuration: time.Since(start)}, opts...)
 }
 
 // NewValidatorFromContents returns a new Validator built from the provided contents of the policy constraints and policy library.
@@ -201,8 +816,35 @@ func NewValidatorFromContents(policyFiles []*configs.PolicyFile, policyLibrary [
 	return NewValidatorFromConfig(config, opts...)
 }
 
+// NewValidatorFromFS returns a new Validator built from the templates and constraints in fsys and the
+// rego library in libFS, see configs.NewConfigurationFromFS.  This allows policies embedded in a binary
+// with go:embed to be used without any file system or network access at runtime.
+func NewValidatorFromFS(fsys, libFS fs.FS, opts ...Option) (*Validator, error) {
+	config, err := configs.NewConfigurationFromFS(fsys, libFS)
+	if err != nil {
+		return nil, err
+	}
+	return NewValidatorFromConfig(config, opts...)
+}
+
+// NewValidatorFromBundle returns a new Validator built from a policy bundle archive, as written by
+// configs.WriteBundleArchive.
+func NewValidatorFromBundle(r io.Reader, opts ...Option) (*Validator, error) {
+	config, _, err := configs.ReadBundleArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewValidatorFromConfig(config, opts...)
+}
+
 // ReviewAsset reviews a single asset.
-func (v *Validator) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error) {
+func (v *Validator) ReviewAsset(ctx context.Context, asset *validator.Asset) (_ []*validator.Violation, err error) {
+	ctx, span := tracer().Start(ctx, "Validator.ReviewAsset", trace.WithAttributes(
+		attrAssetName.String(asset.GetName()),
+		attrAssetType.String(asset.GetAssetType()),
+	))
+	defer func() { endSpan(span, err) }()
+
 	// Sanitize the ancestry path first, so that an asset that only provides ancestors
 	// can still pass ValidateAsset.
 	if err := asset2.SanitizeAncestryPath(asset); err != nil {
@@ -213,7 +855,9 @@ func (v *Validator) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]
 		return nil, err
 	}
 
+	_, convertSpan := tracer().Start(ctx, "asset.Convert")
 	assetInterface, err := asset2.ConvertResourceViaJSONToInterface(asset)
+	endSpan(convertSpan, err)
 	if err != nil {
 		return nil, err
 	}
@@ -224,21 +868,36 @@ func (v *Validator) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]
 		return nil, err
 	}
 
-	return result.ToViolations()
+	violations, err := result.ToViolations()
+	span.SetAttributes(attrViolations.Int(len(violations)))
+	return violations, err
 }
 
 // ReviewTFResourceChange evaluates a single terraform resource change without any threading in the background.
+// The change's module_address is exposed to templates as module_path, empty for the root module.
 func (v *Validator) ReviewTFResourceChange(ctx context.Context, inputResource map[string]interface{}) ([]*validator.Violation, error) {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
 	target := tftarget.New()
 	handled, _, err := target.HandleReview(inputResource)
 	if !handled {
 		return nil, fmt.Errorf("Unhandled resource: %w", err)
 	}
-	responses, err := v.tfCFClient.Review(ctx, inputResource)
+	review := inputResource
+	if _, found := inputResource[tftarget.ModulePathKey]; !found {
+		// The module path is added to a shallow copy, the caller's resource change is left as is.
+		review = make(map[string]interface{}, len(inputResource)+1)
+		for key, value := range inputResource {
+			review[key] = value
+		}
+		review[tftarget.ModulePathKey], _ = inputResource["module_address"].(string)
+	}
+	ctx = v.evaluationContext(v.runContext(ctx))
+	responses, err := cfReview(ctx, v.tfCFClient, tftarget.Name, len(v.config.TFConstraints), review)
 	if err != nil {
 		return nil, fmt.Errorf("TF target Constraint Framework review call failed: %w", err)
 	}
-	result, err := NewResult(tftarget.Name, inputResource["address"].(string), inputResource, inputResource, responses)
+	result, err := NewResult(tftarget.Name, inputResource["address"].(string), inputResource, review, responses)
 	if err != nil {
 		return nil, err
 	}
@@ -247,20 +906,39 @@ func (v *Validator) ReviewTFResourceChange(ctx context.Context, inputResource ma
 }
 
 // fixAncestry will try to use the ancestors array to create the ancestorPath
-// value if it is not present.
+// value if it is not present.  The resulting path is checked against the ancestry limits.
 func (v *Validator) fixAncestry(input map[string]interface{}) error {
-	ancestors, found, err := unstructured.NestedStringSlice(input, ancestorSliceKey)
-	if found && err == nil {
-		input[ancestryPathKey] = asset2.AncestryPath(ancestors)
-		return nil
+	var ancestryPath string
+	if ancestors, found, err := unstructured.NestedStringSlice(input, ancestorSliceKey); found && err == nil {
+		ancestryPath = asset2.AncestryPath(ancestors)
+	} else if ancestry, found, err := unstructured.NestedString(input, ancestryPathKey); found && err == nil {
+		ancestryPath = configs.NormalizeAncestry(ancestry)
+	} else {
+		return fmt.Errorf("asset missing ancestry information: %v", input)
 	}
+	if err := v.ancestryLimits.Validate(ancestryPath); err != nil {
+		return fmt.Errorf("invalid ancestry for asset %v: %w", input["name"], err)
+	}
+	input[ancestryPathKey] = asset2.NormalizeName(ancestryPath, asset2.NameProjectNumbers(v.projectNumbers))
+	return nil
+}
 
-	ancestry, found, err := unstructured.NestedString(input, ancestryPathKey)
-	if found && err == nil {
-		input[ancestryPathKey] = configs.NormalizeAncestry(ancestry)
-		return nil
+// normalizeName replaces the asset's name with its canonical form, see asset.NormalizeName, so that
+// the asset is matched and its violations are reported under the same name whichever form the asset
+// producer used.
+func (v *Validator) normalizeName(input map[string]interface{}) {
+	name, ok := input["name"].(string)
+	if !ok {
+		return
 	}
-	return fmt.Errorf("asset missing ancestry information: %v", input)
+	assetType, _ := input["asset_type"].(string)
+	input["name"] = v.NormalizeName(name, assetType)
+}
+
+// NormalizeName returns the canonical form of the name of an asset of the type, the resource its
+// violations are reported on.
+func (v *Validator) NormalizeName(name, assetType string) string {
+	return asset2.NormalizeName(name, asset2.NameAssetType(assetType), asset2.NameProjectNumbers(v.projectNumbers))
 }
 
 // ReviewJSON reviews the content of a JSON string
@@ -272,36 +950,165 @@ func (v *Validator) ReviewJSON(ctx context.Context, data string) (*Result, error
 	return v.ReviewUnmarshalledJSON(ctx, asset)
 }
 
+// ReviewStruct reviews an asset held as a proto Struct value, such as an asset decoded from a CAI feed,
+// without marshalling it to JSON first.
+func (v *Validator) ReviewStruct(ctx context.Context, value *structpb.Value) (*Result, error) {
+	s := value.GetStructValue()
+	if s == nil {
+		return nil, fmt.Errorf("asset must be a struct value, got %T", value.GetKind())
+	}
+	// AsMap produces the same types as json.Unmarshal, nil values are converted to nil.
+	return v.ReviewUnmarshalledJSON(ctx, s.AsMap())
+}
+
 // ReviewJSON evaluates a single asset without any threading in the background.
+// Objects accepted by a custom target registered with WithTarget are reviewed with that target as
+// given, without the name and ancestry normalization of CAI assets.  Config Connector resources are
+// reviewed with the GCP target as the CAI asset of the resource they manage, see
+// asset.ConvertKRMToCAI.  Assets in the output format of gcloud, with assetType rather than asset_type,
+// are normalized first, see asset.NormalizeGcloudAsset.
 func (v *Validator) ReviewUnmarshalledJSON(ctx context.Context, asset map[string]interface{}) (*Result, error) {
+	ctx, evaluated := withEvaluated(ctx)
+	result, err := v.reviewUnmarshalledJSON(ctx, asset)
+	if result != nil {
+		result.Evaluated = evaluated.list()
+	}
+	return result, err
+}
+
+func (v *Validator) reviewUnmarshalledJSON(ctx context.Context, asset map[string]interface{}) (*Result, error) {
+	ctx = v.evaluationContext(v.runContext(ctx))
+	if tgt, name := v.customTarget(asset); tgt != nil {
+		v.mtx.RLock()
+		defer v.mtx.RUnlock()
+		return v.reviewCustom(ctx, tgt, name, asset)
+	}
+	if normalized, ok := asset2.NormalizeGcloudAsset(asset); ok {
+		asset = normalized
+	}
+	if asset2.IsKRM(asset) {
+		return v.reviewKRM(ctx, asset)
+	}
+
+	v.normalizeName(asset)
 	if err := v.fixAncestry(asset); err != nil {
 		return nil, err
 	}
 
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
 	if asset2.IsK8S(asset) {
 		return v.reviewK8SResource(ctx, asset)
 	}
 	return v.reviewGCPResource(ctx, asset)
 }
 
-// reviewK8SResource will convert CAI assets to k8s resources then pass them to the cf client with the gatekeeper target.
+// reviewK8SResource will convert CAI assets to k8s admission requests then pass them to the cf client with the gatekeeper
+// target.
+// If the K8S target is not set up, K8S assets have no violations.
 func (v *Validator) reviewK8SResource(ctx context.Context, asset map[string]interface{}) (*Result, error) {
-	k8sResource, err := asset2.ConvertCAIToK8s(asset)
+	if v.k8sCFClient == nil {
+		return &Result{
+			Name:           asset["name"].(string),
+			InputResource:  asset,
+			ReviewResource: asset,
+		}, nil
+	}
+	request, err := asset2.ConvertToAdmissionRequest(asset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert asset to admission request: %w", err)
 	}
-	responses, err := v.k8sCFClient.Review(ctx, k8sResource)
+	if err := annotateEvaluationTime(ctx, request); err != nil {
+		return nil, err
+	}
+	k8sResource, err := admissionObject(request)
+	if err != nil {
+		return nil, err
+	}
+	return v.reviewK8S(ctx, asset["name"].(string), asset, k8sResource, request)
+}
+
+// ReviewAdmissionRequest reviews the object in a K8S admission request with the K8S target, without
+// any conversion from CAI.  The old object is reviewed for DELETE requests, which have no object.  The
+// result is named namespace/name for namespaced objects.  If the K8S target is not set up the result
+// has no violations.
+func (v *Validator) ReviewAdmissionRequest(ctx context.Context, request *admissionv1.AdmissionRequest) (*Result, error) {
+	object, err := admissionObject(request)
+	if err != nil {
+		return nil, err
+	}
+
+	name := request.Name
+	if request.Namespace != "" {
+		name = request.Namespace + "/" + name
+	}
+	ctx = v.evaluationContext(v.runContext(ctx))
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+	if v.k8sCFClient == nil {
+		return &Result{
+			Name:           name,
+			InputResource:  object,
+			ReviewResource: object,
+		}, nil
+	}
+	return v.reviewK8S(ctx, name, object, object, request)
+}
+
+// admissionObject returns the object in an admission request, or the old object for DELETE requests.
+func admissionObject(request *admissionv1.AdmissionRequest) (map[string]interface{}, error) {
+	raw := request.Object.Raw
+	if len(raw) == 0 {
+		raw = request.OldObject.Raw
+	}
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal admission request object: %w", err)
+	}
+	return object, nil
+}
+
+// reviewK8S reviews the K8S object of an admission request with the K8S CF client, along with the
+// resources it generates if K8SExpansion is enabled.
+func (v *Validator) reviewK8S(
+	ctx context.Context,
+	name string,
+	inputResource map[string]interface{},
+	reviewResource map[string]interface{},
+	review *admissionv1.AdmissionRequest) (*Result, error) {
+	responses, err := cfReview(ctx, v.k8sCFClient, configs.K8STargetName, len(v.config.K8SConstraints), review)
 	if err != nil {
 		return nil, fmt.Errorf("K8S target Constraint Framework review call failed: %w", err)
 	}
-	return NewResult(configs.K8STargetName, asset["name"].(string), asset, k8sResource.Object, responses)
+	if err := v.reviewExpanded(ctx, reviewResource, review, responses); err != nil {
+		return nil, fmt.Errorf("K8S target expansion failed: %w", err)
+	}
+	return NewResult(configs.K8STargetName, name, inputResource, reviewResource, responses)
 }
 
 // reviewGCPResource will pass CAI assets to the cf client with the GCP target.
 func (v *Validator) reviewGCPResource(ctx context.Context, asset map[string]interface{}) (*Result, error) {
-	responses, err := v.gcpCFClient.Review(ctx, asset)
+	if err := asset2.NormalizeAccessContextPolicy(asset); err != nil {
+		return nil, err
+	}
+	if err := v.setTags(ctx, asset); err != nil {
+		return nil, err
+	}
+	var review interface{} = asset
+	if SamplingApplied(ctx) {
+		review = &gcptarget.SampledReview{Asset: asset}
+	}
+	responses, stopped, err := v.gcpCFClients.review(ctx, gcptarget.Name, v.config.GCPConstraints, review)
 	if err != nil {
 		return nil, fmt.Errorf("GCP target Constraint Framework review call failed: %w", err)
 	}
-	return NewResult(gcptarget.Name, asset["name"].(string), asset, asset, responses)
+	result, err := NewResult(gcptarget.Name, asset["name"].(string), asset, asset, responses)
+	if err != nil {
+		return nil, err
+	}
+	result.Stopped = stopped
+	if err := v.reviewOrgPolicies(ctx, asset, result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
@@ -17,13 +17,24 @@ package gcv
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/GoogleCloudPlatform/config-validator/pkg/asset"
 	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"github.com/open-policy-agent/opa/ast"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 const (
@@ -59,6 +70,71 @@ func TestDefaultTestDataWithDisabledBuiltins(t *testing.T) {
 	}
 }
 
+func TestDefaultTestDataSkipsDisabledBuiltins(t *testing.T) {
+	policyFilePaths, policyLibPath := testOptions()
+	v, err := NewValidator(policyFilePaths, policyLibPath, DisableBuiltins("http.send"), SkipDisabledBuiltins())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if v.findTemplate(func(t *cftemplates.ConstraintTemplate) bool { return t.Name == "cfhttpsendv1" }) != nil {
+		t.Errorf("template calling http.send was loaded")
+	}
+	var warned bool
+	for _, issue := range v.config.Warnings {
+		warned = warned || (issue.Name == "cfhttpsendv1" && strings.Contains(issue.Message, "calls disabled builtin http.send"))
+	}
+	if !warned {
+		t.Errorf("no warning for the skipped template")
+	}
+
+	unrestricted, err := NewValidator(policyFilePaths, policyLibPath)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	templ := unrestricted.findTemplate(func(t *cftemplates.ConstraintTemplate) bool { return t.Name == "cfhttpsendv1" })
+	if templ == nil {
+		t.Fatal("template calling http.send not loaded")
+	}
+	if err := v.AddTemplate(context.Background(), templ); err == nil || !strings.Contains(err.Error(), "disabled builtin") {
+		t.Errorf("got AddTemplate error %v, want disabled builtin error", err)
+	}
+}
+
+func TestDefaultTestDataWithRegoCapabilities(t *testing.T) {
+	policyFilePaths, policyLibPath := testOptions()
+	v, err := NewValidator(policyFilePaths, policyLibPath, WithRegoCapabilities(ast.CapabilitiesForThisVersion()))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	templ := v.findTemplate(func(t *cftemplates.ConstraintTemplate) bool { return t.Name == "cfhttpsendv1" })
+	if templ == nil {
+		t.Fatal("template calling http.send not loaded")
+	}
+
+	capabilities := ast.CapabilitiesForThisVersion()
+	var builtins []*ast.Builtin
+	for _, builtin := range capabilities.Builtins {
+		if builtin.Name != "http.send" {
+			builtins = append(builtins, builtin)
+		}
+	}
+	capabilities.Builtins = builtins
+	_, err = NewValidator(policyFilePaths, policyLibPath, WithRegoCapabilities(capabilities))
+	if err == nil || !strings.Contains(err.Error(), "relies on builtin http.send") {
+		t.Fatalf("got error %v, want error since http.send is not in the capabilities", err)
+	}
+
+	pinned, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(deniedNamesTemplate)},
+	}, []string{"package validator.gcp.lib\n"}, WithRegoCapabilities(capabilities))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if err := pinned.AddTemplate(context.Background(), templ); err == nil || !strings.Contains(err.Error(), "outside the rego capabilities") {
+		t.Errorf("got AddTemplate error %v, want capabilities error", err)
+	}
+}
+
 func TestDefaultTestDataCreatesValidatorFromContents(t *testing.T) {
 	policyFilePaths, policyLibPath := testOptions()
 
@@ -92,6 +168,50 @@ func TestDefaultTestDataCreatesValidatorFromContents(t *testing.T) {
 	}
 }
 
+func TestDefaultTestDataCreatesValidatorFromFS(t *testing.T) {
+	v, err := NewValidatorFromFS(os.DirFS(localPolicyDir), os.DirFS(localPolicyDepDir))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	result, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := len(result.ConstraintViolations); got != 2 {
+		t.Errorf("got %d violations, want 2", got)
+	}
+}
+
+func TestDefaultTestDataCreatesValidatorFromBundle(t *testing.T) {
+	policyFilePaths, policyLibPath := testOptions()
+	archive := filepath.Join(t.TempDir(), "bundle"+configs.BundleArchiveSuffix)
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configs.WriteBundleArchive(f, policyFilePaths, policyLibPath); err != nil {
+		t.Fatal("unexpected error writing bundle archive", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := NewValidatorFromBundle(f); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if _, err := NewValidator([]string{archive}, ""); err != nil {
+		t.Fatal("unexpected error loading bundle archive from policy path", err)
+	}
+	if _, err := NewValidator([]string{archive}, policyLibPath); err == nil {
+		t.Fatal("expected error for policy library with a bundle archive")
+	}
+}
+
 type reviewAssetTestcase struct {
 	name           string
 	assetJson      string
@@ -184,6 +304,132 @@ func TestReviewAsset(t *testing.T) {
 		})
 	}
 }
+
+func TestReviewWithK8STargetDisabled(t *testing.T) {
+	policyPaths, policyLibPath := testOptions()
+	v, err := NewValidator(policyPaths, policyLibPath, DisableK8STarget())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if v.k8sCFClient != nil {
+		t.Fatal("expected K8S client to be disabled")
+	}
+
+	result, err := v.ReviewJSON(context.Background(), namespaceAssetWithNoLabelJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := len(result.ConstraintViolations); got != 0 {
+		t.Errorf("wanted 0 violations, got %d", got)
+	}
+
+	result, err = v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got := len(result.ConstraintViolations); got != 2 {
+		t.Errorf("wanted 2 violations, got %d", got)
+	}
+}
+
+func TestReviewAdmissionRequest(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	namespace := func(labels map[string]interface{}) []byte {
+		raw, err := json.Marshal(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata": map[string]interface{}{
+				"name":   "test-ns",
+				"labels": labels,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	testCases := []struct {
+		name           string
+		request        *admissionv1.AdmissionRequest
+		wantViolations int
+	}{
+		{
+			name: "missing label",
+			request: &admissionv1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"},
+				Operation: admissionv1.Create,
+				Name:      "test-ns",
+				Object:    runtime.RawExtension{Raw: namespace(nil)},
+			},
+			wantViolations: 1,
+		},
+		{
+			name: "has label",
+			request: &admissionv1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"},
+				Operation: admissionv1.Create,
+				Name:      "test-ns",
+				Object:    runtime.RawExtension{Raw: namespace(map[string]interface{}{"cost-center": "123"})},
+			},
+		},
+		{
+			name: "delete reviews old object",
+			request: &admissionv1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"},
+				Operation: admissionv1.Delete,
+				Name:      "test-ns",
+				OldObject: runtime.RawExtension{Raw: namespace(nil)},
+			},
+			wantViolations: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := v.ReviewAdmissionRequest(context.Background(), tc.request)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if result.Name != "test-ns" {
+				t.Errorf("got name %q, want test-ns", result.Name)
+			}
+			if got := len(result.ConstraintViolations); got != tc.wantViolations {
+				t.Errorf("got %d violations, want %d", got, tc.wantViolations)
+			}
+		})
+	}
+}
+
+func TestReviewWithEvaluationTime(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	asOf := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	ctx := WithEvaluationTime(context.Background(), asOf)
+
+	cv := newClockValidator(t)
+	if got, want := reviewedMessage(t, ctx, cv, storageAssetNoLoggingMap(t)), "2020-01-02T03:04:05Z"; got != want {
+		t.Errorf("got %s %v, want %v", evaluationTimeKey, got, want)
+	}
+
+	result, err := v.ReviewJSON(ctx, namespaceAssetWithNoLabelJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	annotations, _, _ := unstructured.NestedStringMap(result.ReviewResource, "metadata", "annotations")
+	if got, want := annotations[evaluationTimeAnnotation], "2020-01-02T03:04:05Z"; got != want {
+		t.Errorf("got evaluationTime annotation %v, want %v", got, want)
+	}
+
+	if got := reviewedMessage(t, context.Background(), cv, storageAssetNoLoggingMap(t)); got != "unset" {
+		t.Errorf("got %s %v without evaluation time in context, want unset", evaluationTimeKey, got)
+	}
+}
+
 func TestCreateNoDir(t *testing.T) {
 	emptyFolder, err := os.MkdirTemp("", "emptyPolicyDir")
 	defer cleanup(t, emptyFolder)
@@ -581,6 +827,15 @@ var defaultReviewTestAssetJSONs = map[string]string{
 	"orgPolicyPolicyJSON":               orgPolicyPolicyJSON,
 }
 
+func BenchmarkNewValidator(b *testing.B) {
+	policyPaths, policyLibPath := testOptions()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewValidator(policyPaths, policyLibPath); err != nil {
+			b.Fatal("unexpected error", err)
+		}
+	}
+}
+
 func BenchmarkReviewJSON(b *testing.B) {
 	v, err := NewValidator(testOptions())
 	if err != nil {
@@ -600,6 +855,115 @@ func BenchmarkReviewJSON(b *testing.B) {
 	}
 }
 
+func TestLimitAncestry(t *testing.T) {
+	policyPaths, policyLibPath := testOptions()
+	v, err := NewValidator(policyPaths, policyLibPath, LimitAncestry(2, 0))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if _, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON); !errors.Is(err, asset.ErrAncestryTooDeep) {
+		t.Errorf("got error %v, want %v", err, asset.ErrAncestryTooDeep)
+	}
+
+	v, err = NewValidator(policyPaths, policyLibPath, LimitAncestry(3, 0))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if _, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestReviewEmptyAncestryPath(t *testing.T) {
+	v, err := NewValidatorFromContents([]*configs.PolicyFile{
+		{Path: "template.yaml", Content: []byte(everyAssetTemplate)},
+		{Path: "constraint.yaml", Content: []byte(everyAssetConstraint)},
+	}, []string{"package validator.gcp.lib\n"})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	result, err := v.ReviewJSON(context.Background(), `{
+  "name": "//storage.googleapis.com/my-storage-bucket",
+  "asset_type": "storage.googleapis.com/Bucket",
+  "ancestry_path": "",
+  "resource": {"data": {}}
+}`)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(result.ConstraintViolations) != 1 {
+		t.Errorf("got %d violations, want 1 from the constraint matching **", len(result.ConstraintViolations))
+	}
+}
+
+func TestReviewStruct(t *testing.T) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	ctx := context.Background()
+	for name, asset := range defaultReviewTestAssetJSONs {
+		t.Run(name, func(t *testing.T) {
+			value := &structpb.Value{}
+			if err := protojson.Unmarshal([]byte(asset), value); err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			got, err := v.ReviewStruct(ctx, value)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			want, err := v.ReviewJSON(ctx, asset)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			gotViolations, err := got.ToViolations()
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			wantViolations, err := want.ToViolations()
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if len(gotViolations) != len(wantViolations) {
+				t.Fatalf("got %d violations, want %d", len(gotViolations), len(wantViolations))
+			}
+			for i := range gotViolations {
+				if gotViolations[i].Fingerprint != wantViolations[i].Fingerprint {
+					t.Errorf("violation %d: got %s on %s, want %s on %s", i,
+						gotViolations[i].Constraint, gotViolations[i].Resource, wantViolations[i].Constraint, wantViolations[i].Resource)
+				}
+			}
+		})
+	}
+
+	if _, err := v.ReviewStruct(ctx, structpb.NewStringValue("asset")); err == nil {
+		t.Error("expected error for non struct value")
+	}
+}
+
+func BenchmarkReviewStruct(b *testing.B) {
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		b.Fatal("unexpected error", err)
+	}
+
+	b.ResetTimer()
+	for name, asset := range defaultReviewTestAssetJSONs {
+		value := &structpb.Value{}
+		if err := protojson.Unmarshal([]byte(asset), value); err != nil {
+			b.Fatal("unexpected error", err)
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err = v.ReviewStruct(context.Background(), value)
+				if err != nil {
+					b.Fatalf("unexpected error %s", err)
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkReviewAsset(b *testing.B) {
 	v, err := NewValidator(testOptions())
 	if err != nil {
@@ -1020,3 +1384,68 @@ func BenchmarkReviewResourceChange(b *testing.B) {
 		})
 	}
 }
+
+// typoParametersConstraint misspells the locations parameter of GCPBigQueryDatasetLocationConstraintV1.
+const typoParametersConstraint = `
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: GCPBigQueryDatasetLocationConstraintV1
+metadata:
+  name: typo
+spec:
+  parameters:
+    mode: allowlist
+    locaitons: ["us-east1"]
+`
+
+func TestStrictParameters(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "typo.yaml"), []byte(typoParametersConstraint), 0644); err != nil {
+		t.Fatal(err)
+	}
+	policyPaths := []string{testRoot + "/templates", dir}
+
+	if _, err := NewValidator(policyPaths, localPolicyDepDir); err != nil {
+		t.Fatal("unexpected error without StrictParameters", err)
+	}
+	if _, err := NewValidator(policyPaths, localPolicyDepDir, StrictParameters()); err == nil {
+		t.Errorf("expected unknown parameter error with StrictParameters")
+	}
+
+	for _, strict := range []bool{false, true} {
+		var opts []Option
+		if strict {
+			opts = append(opts, StrictParameters())
+		}
+		report, err := ValidatePolicies(policyPaths, localPolicyDepDir, opts...)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		var found int
+		for _, issue := range report.Issues {
+			if issue.Message == "unknown parameter spec.parameters.locaitons" {
+				found++
+				if issue.Warning == strict {
+					t.Errorf("got issue %s with StrictParameters %v", issue, strict)
+				}
+			}
+		}
+		if found != 1 {
+			t.Errorf("got %d unknown parameter issues with StrictParameters %v, want 1", found, strict)
+		}
+	}
+
+	v, err := NewValidator(testOptions())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	v.strictParameters = true
+	objects, err := configs.LoadUnstructuredFromContents([]*configs.PolicyFile{
+		{Path: "typo.yaml", Content: []byte(typoParametersConstraint)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.AddConstraint(context.Background(), objects[0]); err == nil || !strings.Contains(err.Error(), "unknown parameter") {
+		t.Errorf("got AddConstraint error %v, want unknown parameter error with StrictParameters", err)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package identity extracts the identity of the caller of the validator RPC service so that it can be
+// attributed in violations and audit logs.
+package identity
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/golang/glog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// MetadataKey is the violation metadata key that holds the caller identity.
+const MetadataKey = "caller_identity"
+
+const (
+	authorizationHeader = "authorization"
+	bearerPrefix        = "bearer "
+)
+
+type contextKey struct{}
+
+// verifiedKey holds the identity of NewVerifiedContext, apart from contextKey so that a later
+// NewContext can't pass as verified.
+type verifiedKey struct{}
+
+// NewContext returns a copy of ctx that carries the caller identity.
+func NewContext(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the caller identity stored in ctx, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(contextKey{}).(string)
+	return identity, ok && identity != ""
+}
+
+// NewVerifiedContext returns a copy of ctx that carries the caller identity, as NewContext, and marks
+// it as verified.  Only callers that authenticated the caller, such as the auth package, may use it.
+func NewVerifiedContext(ctx context.Context, identity string) context.Context {
+	return context.WithValue(NewContext(ctx, identity), verifiedKey{}, identity)
+}
+
+// VerifiedFromContext returns the caller identity stored in ctx by NewVerifiedContext, if any.
+// Identities from the interceptors of this package are not verified, and are not returned.
+func VerifiedFromContext(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(verifiedKey{}).(string)
+	return identity, ok && identity != ""
+}
+
+// Extractor determines the identity of the caller from an incoming request context.
+type Extractor interface {
+	// Extract returns the caller identity, or an empty string if the caller could not be identified.
+	Extract(ctx context.Context) (string, error)
+}
+
+// ExtractorFunc adapts a function to the Extractor interface.
+type ExtractorFunc func(ctx context.Context) (string, error)
+
+// Extract implements Extractor.
+func (f ExtractorFunc) Extract(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// IDTokenExtractor reads the caller identity from a claim of the ID token passed as a bearer token in
+// the request's authorization metadata.
+//
+// IDTokenExtractor does not verify the token signature, it relies on the token having been verified
+// by an authentication layer in front of the service.
+type IDTokenExtractor struct {
+	// Claims are the token claims to check in order, the first non-empty claim is used as the identity.
+	Claims []string
+}
+
+// NewIDTokenExtractor returns an IDTokenExtractor which uses the email claim, falling back to the
+// subject claim.
+func NewIDTokenExtractor() *IDTokenExtractor {
+	return &IDTokenExtractor{Claims: []string{"email", "sub"}}
+}
+
+// Extract implements Extractor.
+func (e *IDTokenExtractor) Extract(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", nil
+	}
+	for _, value := range md.Get(authorizationHeader) {
+		if len(value) < len(bearerPrefix) || !strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+			continue
+		}
+		claims, err := parseClaims(value[len(bearerPrefix):])
+		if err != nil {
+			return "", err
+		}
+		for _, claim := range e.Claims {
+			if identity, ok := claims[claim].(string); ok && identity != "" {
+				return identity, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// parseClaims decodes the payload of a JWT.
+func parseClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed ID token, expected 3 parts got %d", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("malformed ID token payload: %w", err)
+	}
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed ID token claims: %w", err)
+	}
+	return claims, nil
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that stores the caller identity determined by
+// extractor in the request context.  Requests where extraction fails are still served, the failure
+// is only logged.
+func UnaryServerInterceptor(extractor Extractor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		identity, err := extractor.Extract(ctx)
+		if err != nil {
+			glog.Warningf("failed to extract caller identity for %s: %v", info.FullMethod, err)
+		}
+		if identity != "" {
+			ctx = NewContext(ctx, identity)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that stores the caller identity determined by
+// extractor in the stream context, as UnaryServerInterceptor.
+func StreamServerInterceptor(extractor Extractor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		identity, err := extractor.Extract(ss.Context())
+		if err != nil {
+			glog.Warningf("failed to extract caller identity for %s: %v", info.FullMethod, err)
+		}
+		if identity != "" {
+			ss = &serverStream{ServerStream: ss, ctx: NewContext(ss.Context(), identity)}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// serverStream is a stream with a context that carries the caller identity.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context implements grpc.ServerStream.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// StampViolations adds the identity to the metadata of each violation under MetadataKey.
+func StampViolations(identity string, violations []*validator.Violation) {
+	for _, v := range violations {
+		if v.Metadata == nil {
+			v.Metadata = structpb.NewStructValue(&structpb.Struct{})
+		}
+		s := v.Metadata.GetStructValue()
+		if s == nil {
+			continue
+		}
+		if s.Fields == nil {
+			s.Fields = map[string]*structpb.Value{}
+		}
+		s.Fields[MetadataKey] = structpb.NewStringValue(identity)
+	}
+}
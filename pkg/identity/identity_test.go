@@ -0,0 +1,161 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func token(payload string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"RS256"}`)) + "." + enc.EncodeToString([]byte(payload)) + ".sig"
+}
+
+func TestIDTokenExtractor(t *testing.T) {
+	testCases := []struct {
+		name    string
+		md      metadata.MD
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no metadata",
+		},
+		{
+			name: "email claim",
+			md:   metadata.Pairs("authorization", "Bearer "+token(`{"email":"ci@example.iam.gserviceaccount.com","sub":"123"}`)),
+			want: "ci@example.iam.gserviceaccount.com",
+		},
+		{
+			name: "subject fallback",
+			md:   metadata.Pairs("authorization", "bearer "+token(`{"sub":"123"}`)),
+			want: "123",
+		},
+		{
+			name: "non bearer authorization",
+			md:   metadata.Pairs("authorization", "Basic Zm9vOmJhcg=="),
+		},
+		{
+			name:    "malformed token",
+			md:      metadata.Pairs("authorization", "Bearer not-a-jwt"),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tc.md)
+			}
+			got, err := NewIDTokenExtractor().Extract(ctx)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("got identity %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	extractor := ExtractorFunc(func(ctx context.Context) (string, error) {
+		return "pipeline-a", nil
+	})
+	var got string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		got, _ = FromContext(ctx)
+		return nil, nil
+	}
+	_, err := UnaryServerInterceptor(extractor)(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/validator.Validator/Review"}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "pipeline-a" {
+		t.Errorf("got identity %q, want pipeline-a", got)
+	}
+}
+
+func TestVerifiedFromContext(t *testing.T) {
+	ctx := NewVerifiedContext(context.Background(), "pipeline-a")
+	if got, _ := VerifiedFromContext(ctx); got != "pipeline-a" {
+		t.Errorf("got verified identity %q, want pipeline-a", got)
+	}
+	if got, _ := FromContext(ctx); got != "pipeline-a" {
+		t.Errorf("got identity %q, want pipeline-a", got)
+	}
+
+	// An identity set later without verification does not pass as verified.
+	ctx = NewContext(ctx, "pipeline-b")
+	if got, _ := VerifiedFromContext(ctx); got != "pipeline-a" {
+		t.Errorf("got verified identity %q, want pipeline-a", got)
+	}
+	if _, ok := VerifiedFromContext(NewContext(context.Background(), "pipeline-b")); ok {
+		t.Errorf("got a verified identity from NewContext, want none")
+	}
+}
+
+// fakeServerStream is a grpc.ServerStream with only a context.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	extractor := ExtractorFunc(func(ctx context.Context) (string, error) {
+		return "pipeline-a", nil
+	})
+	var got string
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		got, _ = FromContext(ss.Context())
+		return nil
+	}
+	ss := &fakeServerStream{ctx: context.Background()}
+	err := StreamServerInterceptor(extractor)(nil, ss, &grpc.StreamServerInfo{FullMethod: "/validator.Validator/ReviewStream"}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "pipeline-a" {
+		t.Errorf("got identity %q, want pipeline-a", got)
+	}
+}
+
+func TestStampViolations(t *testing.T) {
+	existing, err := structpb.NewValue(map[string]interface{}{"details": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations := []*validator.Violation{{Metadata: existing}, {}}
+	StampViolations("pipeline-a", violations)
+	for idx, v := range violations {
+		if got := v.Metadata.GetStructValue().GetFields()[MetadataKey].GetStringValue(); got != "pipeline-a" {
+			t.Errorf("violation %d: got %s %q, want pipeline-a", idx, MetadataKey, got)
+		}
+	}
+	if _, ok := violations[0].Metadata.GetStructValue().GetFields()["details"]; !ok {
+		t.Errorf("existing metadata was dropped")
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inframanager reviews Infrastructure Manager previews.
+//
+// An Infrastructure Manager preview runs terraform plan for a deployment on Google's side.  The Client
+// exports the plan of a finished preview in the JSON format of `terraform show -json` and reviews it
+// with the TF constraints, so that a change can be checked before it is applied without running
+// terraform locally.
+package inframanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+const (
+	// DefaultEndpoint is the Infrastructure Manager API endpoint.
+	DefaultEndpoint = "https://config.googleapis.com/"
+	// stateSucceeded is the state of a preview whose plan has been created.
+	stateSucceeded = "SUCCEEDED"
+	// cloudPlatformScope is the OAuth scope required by the Infrastructure Manager API.
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// previewName matches the resource names of previews.
+var previewName = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/previews/[^/]+$`)
+
+// Reviewer reviews a terraform plan, it is implemented by gcv.Validator.
+type Reviewer interface {
+	ReviewTFPlan(ctx context.Context, plan map[string]interface{}) ([]*validator.Violation, error)
+}
+
+// Client fetches the plans of Infrastructure Manager previews.
+type Client struct {
+	// api is authorized for the Infrastructure Manager API.
+	api      *http.Client
+	endpoint string
+	// download fetches the signed URLs of exported plans, which must not be sent credentials.
+	download *http.Client
+}
+
+// NewClient returns a Client authorized with the application default credentials, or as set by opts.
+func NewClient(ctx context.Context, opts ...option.ClientOption) (*Client, error) {
+	opts = append([]option.ClientOption{
+		option.WithEndpoint(DefaultEndpoint),
+		option.WithScopes(cloudPlatformScope),
+	}, opts...)
+	api, endpoint, err := htransport.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Infrastructure Manager client: %w", err)
+	}
+	return &Client{api: api, endpoint: strings.TrimSuffix(endpoint, "/") + "/", download: http.DefaultClient}, nil
+}
+
+// preview is the part of a preview resource that is checked before its plan is exported.
+type preview struct {
+	State     string `json:"state"`
+	ErrorCode string `json:"errorCode"`
+}
+
+// exportPreviewResultResponse is the response of previews.export.
+type exportPreviewResultResponse struct {
+	Result struct {
+		JSONSignedURI string `json:"jsonSignedUri"`
+	} `json:"result"`
+}
+
+// Plan returns the terraform plan of the preview, named as
+// projects/<project>/locations/<location>/previews/<preview>, in the JSON format of
+// `terraform show -json`.  The preview must have succeeded.
+func (c *Client) Plan(ctx context.Context, name string) (map[string]interface{}, error) {
+	if !previewName.MatchString(name) {
+		return nil, fmt.Errorf("invalid preview %q, expected projects/<project>/locations/<location>/previews/<preview>", name)
+	}
+	var p preview
+	if err := c.call(ctx, http.MethodGet, "v1/"+name, nil, &p); err != nil {
+		return nil, err
+	}
+	if p.State != stateSucceeded {
+		if p.ErrorCode != "" {
+			return nil, fmt.Errorf("preview %s is %s with error %s", name, p.State, p.ErrorCode)
+		}
+		return nil, fmt.Errorf("preview %s is %s, not %s", name, p.State, stateSucceeded)
+	}
+
+	var exported exportPreviewResultResponse
+	if err := c.call(ctx, http.MethodPost, "v1/"+name+":export", struct{}{}, &exported); err != nil {
+		return nil, err
+	}
+	if exported.Result.JSONSignedURI == "" {
+		return nil, fmt.Errorf("export of preview %s has no JSON plan", name)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, exported.Result.JSONSignedURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var plan map[string]interface{}
+	if err := do(c.download, req, &plan); err != nil {
+		return nil, fmt.Errorf("failed to download plan of preview %s: %w", name, err)
+	}
+	return plan, nil
+}
+
+// Review reviews the resource changes of the preview's plan with reviewer, see Plan.
+func (c *Client) Review(ctx context.Context, reviewer Reviewer, name string) ([]*validator.Violation, error) {
+	plan, err := c.Plan(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return reviewer.ReviewTFPlan(ctx, plan)
+}
+
+// call calls the Infrastructure Manager API method at path and decodes the response into out.
+func (c *Client) call(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if err := do(c.api, req, out); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// do sends the request and decodes the JSON response into out.
+func do(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inframanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+)
+
+const testPreview = "projects/p/locations/us-central1/previews/pr"
+
+type fakeReviewer struct {
+	plan map[string]interface{}
+}
+
+func (r *fakeReviewer) ReviewTFPlan(ctx context.Context, plan map[string]interface{}) ([]*validator.Violation, error) {
+	r.plan = plan
+	return []*validator.Violation{{Constraint: "TFTest.test", Resource: "google_compute_instance.vm"}}, nil
+}
+
+func newTestClient(t *testing.T, state string) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/"+testPreview:
+			fmt.Fprintf(w, `{"name": %q, "state": %q}`, testPreview, state)
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/"+testPreview+":export":
+			fmt.Fprintf(w, `{"result": {"jsonSignedUri": "http://%s/signed/plan.json", "binarySignedUri": "http://%s/signed/plan"}}`, r.Host, r.Host)
+		case r.Method == http.MethodGet && r.URL.Path == "/signed/plan.json":
+			if r.Header.Get("Authorization") != "" {
+				t.Errorf("signed URL request sent credentials")
+			}
+			fmt.Fprint(w, `{"format_version": "1.1", "resource_changes": [{"address": "google_compute_instance.vm"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client, err := NewClient(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestReview(t *testing.T) {
+	client := newTestClient(t, stateSucceeded)
+	reviewer := &fakeReviewer{}
+	violations, err := client.Review(context.Background(), reviewer, testPreview)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(violations) != 1 {
+		t.Errorf("got %d violations, want 1", len(violations))
+	}
+	want := map[string]interface{}{
+		"format_version":   "1.1",
+		"resource_changes": []interface{}{map[string]interface{}{"address": "google_compute_instance.vm"}},
+	}
+	if diff := cmp.Diff(want, reviewer.plan); diff != "" {
+		t.Errorf("plan diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestPlanErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		state   string
+		preview string
+		wantErr string
+	}{
+		{
+			name:    "invalid name",
+			state:   stateSucceeded,
+			preview: "projects/p/previews/pr",
+			wantErr: "invalid preview",
+		},
+		{
+			name:    "not found",
+			state:   stateSucceeded,
+			preview: "projects/p/locations/us-central1/previews/missing",
+			wantErr: "404",
+		},
+		{
+			name:    "not succeeded",
+			state:   "APPLYING",
+			preview: testPreview,
+			wantErr: "is APPLYING",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newTestClient(t, tc.state)
+			_, err := client.Plan(context.Background(), tc.preview)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
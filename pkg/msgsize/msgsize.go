@@ -0,0 +1,228 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package msgsize limits the size of requests to the validator RPC service by method, and reports
+// requests over the limit with an error that tells the client to split the request, or to send it on
+// the streaming variant of the method, see Streams.
+//
+// gRPC only supports a single receive limit for the whole server, enforced before the request reaches
+// any handler.  The server's limit is set to the largest of the method limits, see Limits.ServerMax,
+// and the interceptors from Limits.UnaryServerInterceptor and Limits.StreamServerInterceptor enforce
+// the limit of each method on each message.
+package msgsize
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Reason is the ErrorInfo reason of requests rejected for their size.
+	Reason = "REQUEST_TOO_LARGE"
+	// Domain is the ErrorInfo domain of requests rejected for their size.
+	Domain = "validator.forsetisecurity.org"
+
+	// serviceName is the name of the validator RPC service, method names without a service are
+	// methods of this service.
+	serviceName = "validator.Validator"
+)
+
+// Streams maps the unary methods that take any number of assets to the client streaming methods that
+// take them in several requests, which requests too large for the unary method can be sent on.
+var Streams = map[string]string{
+	validator.Validator_AddData_FullMethodName: validator.Validator_AddDataStream_FullMethodName,
+	validator.Validator_Review_FullMethodName:  validator.Validator_ReviewStream_FullMethodName,
+}
+
+// Limits are the maximum sizes, in bytes, of requests to the RPC service.
+type Limits struct {
+	// Default is the limit of methods without their own limit.
+	Default int
+	// Methods are limits by full method name, eg "/validator.Validator/AddData".
+	Methods map[string]int
+}
+
+// For returns the limit of the method.
+func (l Limits) For(fullMethod string) int {
+	if limit, ok := l.Methods[fullMethod]; ok {
+		return limit
+	}
+	return l.Default
+}
+
+// ServerMax returns the largest limit, the receive limit to set on the server with
+// grpc.MaxRecvMsgSize.  Requests over it are rejected by gRPC before reaching the interceptor.
+func (l Limits) ServerMax() int {
+	max := l.Default
+	for _, limit := range l.Methods {
+		if limit > max {
+			max = limit
+		}
+	}
+	return max
+}
+
+// UnaryServerInterceptor returns an interceptor which rejects requests over the limit of their method
+// with a TooLargeError.
+func (l Limits) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.check(info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor which rejects the requests received on a stream that
+// are over the limit of their method with a TooLargeError, each request is limited on its own.
+func (l Limits) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, limits: l, method: info.FullMethod})
+	}
+}
+
+// check returns a TooLargeError if the request is over the limit of the method.
+func (l Limits) check(fullMethod string, req interface{}) error {
+	if msg, ok := req.(proto.Message); ok {
+		limit := l.For(fullMethod)
+		if size := proto.Size(msg); limit > 0 && size > limit {
+			return &TooLargeError{Method: fullMethod, Stream: Streams[fullMethod], Size: size, Limit: limit}
+		}
+	}
+	return nil
+}
+
+// serverStream checks the size of each request received on the stream.
+type serverStream struct {
+	grpc.ServerStream
+	limits Limits
+	method string
+}
+
+// RecvMsg implements grpc.ServerStream.
+func (s *serverStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.limits.check(s.method, m)
+}
+
+// ParseMethodLimits parses method limits as a comma separated list of method=bytes, eg
+// "AddData=268435456,Review=67108864".  Methods without a service are methods of the validator
+// service.
+func ParseMethodLimits(value string) (map[string]int, error) {
+	limits := map[string]int{}
+	if value == "" {
+		return limits, nil
+	}
+	for _, item := range strings.Split(value, ",") {
+		method, size, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || method == "" {
+			return nil, fmt.Errorf("invalid method limit %q, want method=bytes", item)
+		}
+		limit, err := strconv.Atoi(size)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid method limit %q, bytes must be a positive integer", item)
+		}
+		if !strings.HasPrefix(method, "/") {
+			method = "/" + serviceName + "/" + method
+		}
+		limits[method] = limit
+	}
+	return limits, nil
+}
+
+// TooLargeError is returned for requests over the size limit of their method.  The request can be
+// split, as AddData and Review take any number of assets, and sent as several smaller requests, on the
+// streaming method if there is one.
+type TooLargeError struct {
+	// Method is the full method name, empty if the request was rejected by gRPC before reaching the
+	// method.
+	Method string
+	// Stream is the full name of the streaming method to send the split request on, see Streams, empty
+	// if the method has none.
+	Stream string
+	// Size is the size of the request in bytes.
+	Size int
+	// Limit is the maximum size in bytes.
+	Limit int
+}
+
+// Error implements error.
+func (e *TooLargeError) Error() string {
+	method := e.Method
+	if method == "" {
+		method = "request"
+	}
+	if e.Stream != "" {
+		return fmt.Sprintf("%s of %d bytes is larger than the limit of %d bytes, send the assets in several smaller requests on %s", method, e.Size, e.Limit, e.Stream)
+	}
+	return fmt.Sprintf("%s of %d bytes is larger than the limit of %d bytes, split the assets into several smaller requests", method, e.Size, e.Limit)
+}
+
+// GRPCStatus returns the error as a ResourceExhausted status with the sizes in an ErrorInfo detail.
+func (e *TooLargeError) GRPCStatus() *status.Status {
+	st := status.New(codes.ResourceExhausted, e.Error())
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: Reason,
+		Domain: Domain,
+		Metadata: map[string]string{
+			"method": e.Method,
+			"stream": e.Stream,
+			"size":   strconv.Itoa(e.Size),
+			"limit":  strconv.Itoa(e.Limit),
+		},
+	})
+	if err != nil {
+		return st
+	}
+	return detailed
+}
+
+// transportTooLarge matches the error of requests rejected by the gRPC receive limit.
+var transportTooLarge = regexp.MustCompile(`received message larger than max \((\d+) vs\. (\d+)\)`)
+
+// FromError returns the TooLargeError of an error returned by the RPC service, for requests rejected
+// by the interceptor or by the server's gRPC receive limit.
+func FromError(err error) (*TooLargeError, bool) {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.ResourceExhausted {
+		return nil, false
+	}
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.GetReason() != Reason || info.GetDomain() != Domain {
+			continue
+		}
+		size, _ := strconv.Atoi(info.GetMetadata()["size"])
+		limit, _ := strconv.Atoi(info.GetMetadata()["limit"])
+		return &TooLargeError{Method: info.GetMetadata()["method"], Stream: info.GetMetadata()["stream"], Size: size, Limit: limit}, true
+	}
+	if match := transportTooLarge.FindStringSubmatch(st.Message()); match != nil {
+		size, _ := strconv.Atoi(match[1])
+		limit, _ := strconv.Atoi(match[2])
+		return &TooLargeError{Size: size, Limit: limit}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,192 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package msgsize
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestParseMethodLimits(t *testing.T) {
+	got, err := ParseMethodLimits("AddData=1024, /other.Service/Method=2048")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		"/validator.Validator/AddData": 1024,
+		"/other.Service/Method":        2048,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseMethodLimits() diff (-want +got):\n%s", diff)
+	}
+
+	for _, value := range []string{"AddData", "=1024", "AddData=big", "AddData=0"} {
+		if _, err := ParseMethodLimits(value); err == nil {
+			t.Errorf("ParseMethodLimits(%q) = nil, want error", value)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	limits := Limits{Default: 100, Methods: map[string]int{"/validator.Validator/AddData": 1000}}
+	if got := limits.For("/validator.Validator/AddData"); got != 1000 {
+		t.Errorf("For(AddData) = %d, want 1000", got)
+	}
+	if got := limits.For("/validator.Validator/Review"); got != 100 {
+		t.Errorf("For(Review) = %d, want 100", got)
+	}
+	if got := limits.ServerMax(); got != 1000 {
+		t.Errorf("ServerMax() = %d, want 1000", got)
+	}
+}
+
+type fakeServer struct {
+	validator.UnimplementedValidatorServer
+}
+
+func (*fakeServer) AddData(context.Context, *validator.AddDataRequest) (*validator.AddDataResponse, error) {
+	return &validator.AddDataResponse{}, nil
+}
+
+func (*fakeServer) Review(context.Context, *validator.ReviewRequest) (*validator.ReviewResponse, error) {
+	return &validator.ReviewResponse{}, nil
+}
+
+func (*fakeServer) ReviewStream(stream validator.Validator_ReviewStreamServer) error {
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			return stream.SendAndClose(&validator.ReviewResponse{})
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func assets(bytes int) []*validator.Asset {
+	return []*validator.Asset{{Name: strings.Repeat("a", bytes)}}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	limits := Limits{Default: 1024, Methods: map[string]int{"/validator.Validator/AddData": 16 * 1024}}
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(
+		grpc.MaxRecvMsgSize(limits.ServerMax()),
+		grpc.UnaryInterceptor(limits.UnaryServerInterceptor()),
+	)
+	validator.RegisterValidatorServer(srv, &fakeServer{})
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	ctx := context.Background()
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := validator.NewValidatorClient(conn)
+
+	// AddData has its own, larger limit.
+	if _, err := client.AddData(ctx, &validator.AddDataRequest{Assets: assets(8 * 1024)}); err != nil {
+		t.Errorf("AddData under its limit: %v", err)
+	}
+
+	// Review is over the default limit, rejected by the interceptor.
+	_, err = client.Review(ctx, &validator.ReviewRequest{Assets: assets(8 * 1024)})
+	tooLarge, ok := FromError(err)
+	if !ok {
+		t.Fatalf("Review over the default limit: got error %v, want TooLargeError", err)
+	}
+	if tooLarge.Method != "/validator.Validator/Review" || tooLarge.Stream != "/validator.Validator/ReviewStream" ||
+		tooLarge.Limit != 1024 || tooLarge.Size <= 8*1024 {
+		t.Errorf("got %+v", tooLarge)
+	}
+
+	// AddData over the server limit is rejected by gRPC before the interceptor.
+	_, err = client.AddData(ctx, &validator.AddDataRequest{Assets: assets(32 * 1024)})
+	tooLarge, ok = FromError(err)
+	if !ok {
+		t.Fatalf("AddData over the server limit: got error %v, want TooLargeError", err)
+	}
+	if tooLarge.Method != "" || tooLarge.Limit != 16*1024 {
+		t.Errorf("got %+v", tooLarge)
+	}
+
+	if _, ok := FromError(errors.New("other")); ok {
+		t.Errorf("FromError(other) = true, want false")
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	limits := Limits{Default: 1024}
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(grpc.StreamInterceptor(limits.StreamServerInterceptor()))
+	validator.RegisterValidatorServer(srv, &fakeServer{})
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	ctx := context.Background()
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := validator.NewValidatorClient(conn)
+
+	// Each request is limited on its own, the stream as a whole is not.
+	stream, err := client.ReviewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := stream.Send(&validator.ReviewRequest{Assets: assets(512)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		t.Errorf("ReviewStream of requests under the limit: %v", err)
+	}
+
+	stream, err = client.ReviewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&validator.ReviewRequest{Assets: assets(2048)}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.CloseAndRecv()
+	tooLarge, ok := FromError(err)
+	if !ok {
+		t.Fatalf("ReviewStream request over the limit: got error %v, want TooLargeError", err)
+	}
+	if tooLarge.Method != "/validator.Validator/ReviewStream" || tooLarge.Stream != "" || tooLarge.Limit != 1024 {
+		t.Errorf("got %+v", tooLarge)
+	}
+}
@@ -32,6 +32,11 @@ func (errs errorImpl) Error() string {
 	return strings.Join(s, ", ")
 }
 
+// Unwrap returns the errors so that errors.Is and errors.As check each of them.
+func (errs errorImpl) Unwrap() []error {
+	return errs
+}
+
 // Format implements fmt.Formatter to make this play nice with handling stack traces produced from
 // github.com/pkg/errors
 func (errs errorImpl) Format(s fmt.State, verb rune) {
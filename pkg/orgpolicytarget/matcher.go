@@ -0,0 +1,74 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orgpolicytarget
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/ancestry"
+	"github.com/gobwas/glob"
+)
+
+var ErrInvalidReview = fmt.Errorf("unexpected type of review, expect map[string]interface{}")
+var ErrInvalidAncestryPath = fmt.Errorf("unexpected type of ancestry path in review object")
+var ErrInvalidConstraint = fmt.Errorf("unexpected type of constraint in review object")
+
+type matcher struct {
+	ancestries         []string
+	excludedAncestries []string
+	// constraints are globs for the org policy constraints to match, with the constraints/ prefix.
+	// Empty matches all constraints.
+	constraints         []string
+	excludedConstraints []string
+}
+
+// Match returns true if the Matcher's Constraint should run against the
+// passed review object.
+func (m *matcher) Match(review interface{}) (bool, error) {
+	reviewObj, ok := review.(map[string]interface{})
+	if !ok {
+		return false, ErrInvalidReview
+	}
+	ancestryPath, ok := reviewObj[AncestryPathKey].(string)
+	if !ok {
+		return false, ErrInvalidAncestryPath
+	}
+	if _, ok := ancestry.FirstMatch(m.ancestries, ancestryPath); !ok {
+		return false, nil
+	}
+	if _, ok := ancestry.FirstMatch(m.excludedAncestries, ancestryPath); ok {
+		return false, nil
+	}
+
+	constraint, ok := reviewObj[ConstraintKey].(string)
+	if !ok {
+		return false, ErrInvalidConstraint
+	}
+	if len(m.constraints) != 0 && !matchesAny(m.constraints, constraint) {
+		return false, nil
+	}
+	return !matchesAny(m.excludedConstraints, constraint), nil
+}
+
+// matchesAny returns true if value matches any of the glob patterns.
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		g := glob.MustCompile(pattern, '/')
+		if g.Match(value) {
+			return true
+		}
+	}
+	return false
+}
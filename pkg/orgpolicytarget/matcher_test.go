@@ -0,0 +1,108 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orgpolicytarget
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMatch(t *testing.T) {
+	review := map[string]interface{}{
+		"name":          "//cloudresourcemanager.googleapis.com/projects/3",
+		"ancestry_path": "organizations/1/folders/2/projects/3",
+		"constraint":    "constraints/compute.disableSerialPortAccess",
+		"version":       "v1",
+		"policy":        map[string]interface{}{},
+	}
+	tests := []struct {
+		name    string
+		match   map[string]interface{}
+		review  interface{}
+		want    bool
+		wantErr error
+	}{
+		{
+			name:   "no match",
+			review: review,
+			want:   true,
+		},
+		{
+			name:   "ancestry",
+			match:  map[string]interface{}{"ancestries": []interface{}{"organizations/1/**"}},
+			review: review,
+			want:   true,
+		},
+		{
+			name:   "excluded ancestry",
+			match:  map[string]interface{}{"excludedAncestries": []interface{}{"organizations/1/folders/2/**"}},
+			review: review,
+			want:   false,
+		},
+		{
+			name:   "constraint without prefix",
+			match:  map[string]interface{}{"constraints": []interface{}{"compute.*"}},
+			review: review,
+			want:   true,
+		},
+		{
+			name:   "constraint with prefix",
+			match:  map[string]interface{}{"constraints": []interface{}{"constraints/compute.disableSerialPortAccess"}},
+			review: review,
+			want:   true,
+		},
+		{
+			name:   "other constraint",
+			match:  map[string]interface{}{"constraints": []interface{}{"gcp.resourceLocations"}},
+			review: review,
+			want:   false,
+		},
+		{
+			name: "excluded constraint",
+			match: map[string]interface{}{
+				"constraints":         []interface{}{"compute.*"},
+				"excludedConstraints": []interface{}{"compute.disableSerialPortAccess"},
+			},
+			review: review,
+			want:   false,
+		},
+		{
+			name:    "invalid review object",
+			review:  123,
+			wantErr: ErrInvalidReview,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := map[string]interface{}{}
+			if tc.match != nil {
+				spec["match"] = tc.match
+			}
+			m, err := New().ToMatcher(&unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.Match(tc.review)
+			if got != tc.want {
+				t.Errorf("Match() = %v, want %v", got, tc.want)
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("Match() = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package orgpolicytarget is a constraint framework target for the org policies of CAI assets.  Each
+// org policy set on an asset, from org_policy or v2_org_policies, is reviewed on its own, so that
+// templates check input.review.policy rather than iterate over the asset's policies, and constraints
+// select the org policy constraints they apply to with spec.match.constraints.
+package orgpolicytarget
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/ancestry"
+	"github.com/gobwas/glob"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/core/constraints"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/handler"
+	"github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Name is the target name for OrgPolicyTarget
+const Name = "validation.orgpolicy.gcp.forsetisecurity.org"
+
+// Keys of the review object of an org policy.
+const (
+	// NameKey is the name of the asset the policy is set on.
+	NameKey = "name"
+	// AssetTypeKey is the asset type of the asset the policy is set on.
+	AssetTypeKey = "asset_type"
+	// AncestryPathKey is the ancestry path of the asset the policy is set on.
+	AncestryPathKey = "ancestry_path"
+	// ConstraintKey is the org policy constraint of the policy, eg
+	// "constraints/compute.disableSerialPortAccess".
+	ConstraintKey = "constraint"
+	// VersionKey is "v1" for policies from org_policy and "v2" for policies from v2_org_policies.
+	VersionKey = "version"
+	// PolicyKey is the policy as it appears in the asset.
+	PolicyKey = "policy"
+)
+
+// constraintPrefix is the prefix of org policy constraint names.
+const constraintPrefix = "constraints/"
+
+// Reviews returns a review object for each org policy of the CAI asset, in the order of org_policy and
+// then v2_org_policies.  The policies are not copied.  Policies without a constraint are skipped.
+func Reviews(asset map[string]interface{}) []map[string]interface{} {
+	var reviews []map[string]interface{}
+	add := func(version, constraint string, policy map[string]interface{}) {
+		if constraint == "" {
+			return
+		}
+		reviews = append(reviews, map[string]interface{}{
+			NameKey:         asset["name"],
+			AssetTypeKey:    asset["asset_type"],
+			AncestryPathKey: asset["ancestry_path"],
+			ConstraintKey:   constraint,
+			VersionKey:      version,
+			PolicyKey:       policy,
+		})
+	}
+	v1, _ := asset["org_policy"].([]interface{})
+	for _, item := range v1 {
+		if policy, ok := item.(map[string]interface{}); ok {
+			constraint, _ := policy["constraint"].(string)
+			add("v1", constraint, policy)
+		}
+	}
+	v2, _ := asset["v2_org_policies"].([]interface{})
+	for _, item := range v2 {
+		if policy, ok := item.(map[string]interface{}); ok {
+			name, _ := policy["name"].(string)
+			add("v2", V2Constraint(name), policy)
+		}
+	}
+	return reviews
+}
+
+// V2Constraint returns the constraint of a v2 org policy from the policy's name, eg
+// "constraints/gcp.resourceLocations" for "projects/123/policies/gcp.resourceLocations", or an empty
+// string if the name is not that of a policy.
+func V2Constraint(policyName string) string {
+	idx := strings.LastIndex(policyName, "/policies/")
+	if idx < 0 {
+		return ""
+	}
+	return constraintPrefix + policyName[idx+len("/policies/"):]
+}
+
+// OrgPolicyTarget is the constraint framework target for the org policies of CAI assets
+type OrgPolicyTarget struct {
+}
+
+var _ handler.TargetHandler = &OrgPolicyTarget{}
+
+// New returns a new OrgPolicyTarget
+func New() *OrgPolicyTarget {
+	return &OrgPolicyTarget{}
+}
+
+// ToMatcher implements client.ToMatcher
+func (g *OrgPolicyTarget) ToMatcher(constraint *unstructured.Unstructured) (constraints.Matcher, error) {
+	match, ok, err := unstructured.NestedMap(constraint.Object, "spec", "match")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get spec.match: %w", err)
+	}
+	if !ok {
+		return &matcher{ancestries: []string{"**"}}, nil
+	}
+
+	include, ok, err := unstructured.NestedStringSlice(match, "ancestries")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get string slice from spec.match.ancestries: %w", err)
+	}
+	if !ok {
+		include = []string{"**"}
+	}
+	exclude, _, err := unstructured.NestedStringSlice(match, "excludedAncestries")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get string slice from spec.match.excludedAncestries: %w", err)
+	}
+	orgPolicyConstraints, _, err := unstructured.NestedStringSlice(match, "constraints")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get string slice from spec.match.constraints: %w", err)
+	}
+	excludedConstraints, _, err := unstructured.NestedStringSlice(match, "excludedConstraints")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get string slice from spec.match.excludedConstraints: %w", err)
+	}
+
+	return &matcher{
+		ancestries:          include,
+		excludedAncestries:  exclude,
+		constraints:         withConstraintPrefix(orgPolicyConstraints),
+		excludedConstraints: withConstraintPrefix(excludedConstraints),
+	}, nil
+}
+
+// withConstraintPrefix returns the constraint patterns with the constraints/ prefix, which may be
+// left out in spec.match.
+func withConstraintPrefix(patterns []string) []string {
+	prefixed := make([]string, len(patterns))
+	for idx, pattern := range patterns {
+		if !strings.HasPrefix(pattern, constraintPrefix) {
+			pattern = constraintPrefix + pattern
+		}
+		prefixed[idx] = pattern
+	}
+	return prefixed
+}
+
+// MatchSchema implements client.MatchSchemaProvider
+func (g *OrgPolicyTarget) MatchSchema() apiextensions.JSONSchemaProps {
+	stringList := apiextensions.JSONSchemaProps{
+		Type: "array",
+		Items: &apiextensions.JSONSchemaPropsOrArray{
+			Schema: &apiextensions.JSONSchemaProps{
+				Type: "string",
+			},
+		},
+	}
+	return apiextensions.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensions.JSONSchemaProps{
+			"ancestries":          stringList,
+			"excludedAncestries":  stringList,
+			"constraints":         stringList,
+			"excludedConstraints": stringList,
+		},
+	}
+}
+
+// GetName implements handler.TargetHandler
+func (g *OrgPolicyTarget) GetName() string {
+	return Name
+}
+
+// ProcessData implements handler.TargetHandler
+func (g *OrgPolicyTarget) ProcessData(obj interface{}) (bool, []string, interface{}, error) {
+	return false, nil, nil, errors.New("storing data for referential constraint eval is not supported at this time.")
+}
+
+// HandleReview implements handler.TargetHandler, it handles the review objects returned by Reviews.
+func (g *OrgPolicyTarget) HandleReview(obj interface{}) (bool, interface{}, error) {
+	review, ok := obj.(map[string]interface{})
+	if !ok {
+		return false, nil, nil
+	}
+	for _, key := range []string{NameKey, AncestryPathKey, ConstraintKey, VersionKey} {
+		if _, found, err := unstructured.NestedString(review, key); !found || err != nil {
+			return false, nil, err
+		}
+	}
+	if _, found, err := unstructured.NestedMap(review, PolicyKey); !found || err != nil {
+		return false, nil, err
+	}
+	return true, review, nil
+}
+
+// HandleViolation implements handler.TargetHandler
+func (g *OrgPolicyTarget) HandleViolation(result *types.Result) error {
+	return nil
+}
+
+// ValidateConstraint implements handler.TargetHandler
+func (g *OrgPolicyTarget) ValidateConstraint(constraint *unstructured.Unstructured) error {
+	for _, field := range []string{"ancestries", "excludedAncestries"} {
+		ancestries, _, err := unstructured.NestedStringSlice(constraint.Object, "spec", "match", field)
+		if err != nil {
+			return fmt.Errorf("invalid spec.match.%s: %s", field, err)
+		}
+		if err := ancestry.ValidateGlobs(ancestries); err != nil {
+			return fmt.Errorf("invalid glob in spec.match.%s: %w", field, err)
+		}
+	}
+	for _, field := range []string{"constraints", "excludedConstraints"} {
+		patterns, _, err := unstructured.NestedStringSlice(constraint.Object, "spec", "match", field)
+		if err != nil {
+			return fmt.Errorf("invalid spec.match.%s: %s", field, err)
+		}
+		for idx, pattern := range patterns {
+			if _, err := glob.Compile(pattern, '/'); err != nil {
+				return fmt.Errorf("invalid glob in spec.match.%s: idx [%d]: %w", field, idx, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orgpolicytarget
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestReviews(t *testing.T) {
+	v1Policy := map[string]interface{}{
+		"constraint":      "constraints/compute.disableSerialPortAccess",
+		"boolean_policy":  map[string]interface{}{"enforced": true},
+		"update_time":     "2023-01-01T00:00:00Z",
+		"etag":            "BwWK",
+		"list_policy":     nil,
+		"restore_default": nil,
+	}
+	v2Policy := map[string]interface{}{
+		"name": "projects/3/policies/gcp.resourceLocations",
+		"spec": map[string]interface{}{},
+	}
+	asset := map[string]interface{}{
+		"name":            "//cloudresourcemanager.googleapis.com/projects/3",
+		"asset_type":      "cloudresourcemanager.googleapis.com/Project",
+		"ancestry_path":   "organizations/1/projects/3",
+		"org_policy":      []interface{}{v1Policy, map[string]interface{}{}},
+		"v2_org_policies": []interface{}{v2Policy, "not a policy"},
+	}
+	want := []map[string]interface{}{
+		{
+			"name":          "//cloudresourcemanager.googleapis.com/projects/3",
+			"asset_type":    "cloudresourcemanager.googleapis.com/Project",
+			"ancestry_path": "organizations/1/projects/3",
+			"constraint":    "constraints/compute.disableSerialPortAccess",
+			"version":       "v1",
+			"policy":        v1Policy,
+		},
+		{
+			"name":          "//cloudresourcemanager.googleapis.com/projects/3",
+			"asset_type":    "cloudresourcemanager.googleapis.com/Project",
+			"ancestry_path": "organizations/1/projects/3",
+			"constraint":    "constraints/gcp.resourceLocations",
+			"version":       "v2",
+			"policy":        v2Policy,
+		},
+	}
+	got := Reviews(asset)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Reviews() diff (-want +got):\n%s", diff)
+	}
+	for _, review := range got {
+		if handled, _, err := New().HandleReview(review); !handled || err != nil {
+			t.Errorf("HandleReview(%v) = %v, %v, want handled", review["constraint"], handled, err)
+		}
+	}
+
+	if got := Reviews(map[string]interface{}{"name": "//storage.googleapis.com/b"}); len(got) != 0 {
+		t.Errorf("Reviews() of asset without org policies = %v, want none", got)
+	}
+}
+
+func TestV2Constraint(t *testing.T) {
+	tests := map[string]string{
+		"projects/123/policies/gcp.resourceLocations":        "constraints/gcp.resourceLocations",
+		"organizations/1/policies/iam.disableServiceAccount": "constraints/iam.disableServiceAccount",
+		"gcp.resourceLocations":                              "",
+	}
+	for name, want := range tests {
+		if got := V2Constraint(name); got != want {
+			t.Errorf("V2Constraint(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestHandleReviewRejectsAssets(t *testing.T) {
+	asset := map[string]interface{}{
+		"name":          "//storage.googleapis.com/b",
+		"asset_type":    "storage.googleapis.com/Bucket",
+		"ancestry_path": "organizations/1/projects/3",
+		"resource":      map[string]interface{}{},
+	}
+	if handled, _, err := New().HandleReview(asset); handled || err != nil {
+		t.Errorf("HandleReview(asset) = %v, %v, want not handled", handled, err)
+	}
+}
+
+func TestValidateConstraint(t *testing.T) {
+	tests := []struct {
+		name    string
+		match   map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			match: map[string]interface{}{
+				"ancestries":          []interface{}{"organizations/1/**"},
+				"excludedAncestries":  []interface{}{"organizations/1/folders/2/**"},
+				"constraints":         []interface{}{"compute.*", "constraints/gcp.resourceLocations"},
+				"excludedConstraints": []interface{}{"compute.vmExternalIpAccess"},
+			},
+		},
+		{
+			name:    "invalid ancestry",
+			match:   map[string]interface{}{"ancestries": []interface{}{"organizations/folders/1"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid constraint glob",
+			match:   map[string]interface{}{"constraints": []interface{}{"compute.["}},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			constraint := &unstructured.Unstructured{Object: map[string]interface{}{
+				"spec": map[string]interface{}{"match": tc.match},
+			}}
+			err := New().ValidateConstraint(constraint)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ValidateConstraint() = %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package peerlimit limits the requests of each client of the validator RPC service, so that a single
+// noisy client can't starve the others of the shared review workers.
+//
+// Each client, or peer, is limited to a number of concurrent requests and to a rate of requests with
+// a token bucket.  Peers are identified by their caller identity if it was verified, see
+// identity.VerifiedFromContext, and otherwise by the host of their address.  The unverified identity
+// of the identity interceptors is not used, a client could otherwise escape its limits by sending a
+// new identity with each request.
+package peerlimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/identity"
+	"github.com/golang/glog"
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// idleTimeout is how long a peer without requests is kept before its state is dropped, which
+	// resets its token bucket and statistics.
+	idleTimeout = 10 * time.Minute
+	// sweepInterval is the minimum time between scans for idle peers.
+	sweepInterval = time.Minute
+	// defaultMaxPeers is the default number of peers whose state is kept.
+	defaultMaxPeers = 10000
+)
+
+// Limits are the limits of each peer.
+type Limits struct {
+	// MaxConcurrent is the maximum number of requests of a peer in flight, further requests are
+	// rejected.  Zero means no limit.
+	MaxConcurrent int
+	// Rate is the number of requests per second a peer may make on average.  Zero means no limit.
+	Rate float64
+	// Burst is the number of requests a peer may make at once above Rate, at least 1.
+	Burst int
+	// MaxPeers is the number of peers whose state is kept, 10000 if zero.  Once reached, the least
+	// recently seen peer without requests in flight is dropped for a new peer, and requests of new peers
+	// are rejected if every peer has requests in flight.
+	MaxPeers int
+}
+
+// Stats are the requests of a peer since its state was created.
+type Stats struct {
+	// Peer is the identity or host of the peer.
+	Peer string `json:"peer"`
+	// InFlight is the number of requests of the peer in flight.
+	InFlight int `json:"inFlight"`
+	// Allowed is the number of requests of the peer that were let through.
+	Allowed int64 `json:"allowed"`
+	// RejectedConcurrency is the number of requests rejected for MaxConcurrent.
+	RejectedConcurrency int64 `json:"rejectedConcurrency"`
+	// RejectedRate is the number of requests rejected for Rate.
+	RejectedRate int64 `json:"rejectedRate"`
+}
+
+// peerState is the limiter state of a peer.
+type peerState struct {
+	stats    Stats
+	bucket   *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter applies Limits to each peer.
+type Limiter struct {
+	limits Limits
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
+
+	mtx       sync.Mutex
+	peers     map[string]*peerState
+	lastSweep time.Time
+}
+
+// New returns a Limiter with the limits.
+func New(limits Limits) *Limiter {
+	if limits.Burst < 1 {
+		limits.Burst = 1
+	}
+	if limits.MaxPeers < 1 {
+		limits.MaxPeers = defaultMaxPeers
+	}
+	return &Limiter{limits: limits, now: time.Now, peers: map[string]*peerState{}}
+}
+
+// UnaryServerInterceptor returns an interceptor which rejects the requests of peers over their limits
+// with a RESOURCE_EXHAUSTED status.  Requests rejected for the rate have a RetryInfo detail with the
+// delay after which the request would be allowed.
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key := peerKey(ctx)
+		if err := l.acquire(key); err != nil {
+			glog.V(1).Infof("rejected %s request of %s: %v", info.FullMethod, key, err)
+			return nil, err
+		}
+		defer l.release(key)
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor which rejects the streams of peers over their limits,
+// as UnaryServerInterceptor.  A stream counts as a single request, however many messages it carries.
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		key := peerKey(ss.Context())
+		if err := l.acquire(key); err != nil {
+			glog.V(1).Infof("rejected %s stream of %s: %v", info.FullMethod, key, err)
+			return err
+		}
+		defer l.release(key)
+		return handler(srv, ss)
+	}
+}
+
+// peerKey returns the verified caller identity of the request, or the host of the peer's address.
+func peerKey(ctx context.Context) string {
+	if caller, ok := identity.VerifiedFromContext(ctx); ok {
+		return caller
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// acquire admits a request of the peer, or returns the status error it is rejected with.
+func (l *Limiter) acquire(key string) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	now := l.now()
+	l.sweep(now)
+	state, ok := l.peers[key]
+	if !ok {
+		if len(l.peers) >= l.limits.MaxPeers && !l.evict() {
+			return status.Errorf(codes.ResourceExhausted, "too many clients with requests in flight, the limit is %d", l.limits.MaxPeers)
+		}
+		state = &peerState{stats: Stats{Peer: key}}
+		if l.limits.Rate > 0 {
+			state.bucket = rate.NewLimiter(rate.Limit(l.limits.Rate), l.limits.Burst)
+		}
+		l.peers[key] = state
+	}
+	state.lastSeen = now
+
+	if l.limits.MaxConcurrent > 0 && state.stats.InFlight >= l.limits.MaxConcurrent {
+		state.stats.RejectedConcurrency++
+		return status.Errorf(codes.ResourceExhausted, "%s has %d requests in flight, the limit is %d", key, state.stats.InFlight, l.limits.MaxConcurrent)
+	}
+	if state.bucket != nil {
+		reservation := state.bucket.ReserveN(now, 1)
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			reservation.CancelAt(now)
+			state.stats.RejectedRate++
+			return rateError(key, l.limits.Rate, delay)
+		}
+	}
+	state.stats.InFlight++
+	state.stats.Allowed++
+	return nil
+}
+
+// release ends a request of the peer admitted by acquire.
+func (l *Limiter) release(key string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if state, ok := l.peers[key]; ok {
+		state.stats.InFlight--
+		state.lastSeen = l.now()
+	}
+}
+
+// sweep drops the peers idle for longer than idleTimeout.  l.mtx must be held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, state := range l.peers {
+		if state.stats.InFlight == 0 && now.Sub(state.lastSeen) > idleTimeout {
+			delete(l.peers, key)
+		}
+	}
+}
+
+// evict drops the least recently seen peer without requests in flight, and returns false if there is
+// none.  l.mtx must be held.
+func (l *Limiter) evict() bool {
+	var oldest string
+	var oldestSeen time.Time
+	for key, state := range l.peers {
+		if state.stats.InFlight == 0 && (oldest == "" || state.lastSeen.Before(oldestSeen)) {
+			oldest, oldestSeen = key, state.lastSeen
+		}
+	}
+	if oldest == "" {
+		return false
+	}
+	delete(l.peers, oldest)
+	return true
+}
+
+// rateError returns the status of a request rejected for the rate, with the delay as RetryInfo.
+func rateError(key string, limit float64, delay time.Duration) error {
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf("%s is over the limit of %g requests per second, retry in %v", key, limit, delay))
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// Stats returns the statistics of the peers, sorted by peer.
+func (l *Limiter) Stats() []Stats {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	stats := make([]Stats, 0, len(l.peers))
+	for _, state := range l.peers {
+		stats = append(stats, state.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Peer < stats[j].Peer
+	})
+	return stats
+}
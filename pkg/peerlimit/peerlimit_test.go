@@ -0,0 +1,178 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peerlimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/identity"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var reviewInfo = &grpc.UnaryServerInfo{FullMethod: "/validator.Validator/Review"}
+
+func peerContext(addr string) context.Context {
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: tcpAddr})
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	l := New(Limits{MaxConcurrent: 1})
+	interceptor := l.UnaryServerInterceptor()
+	ctx := peerContext("10.0.0.1:1234")
+
+	var nestedErr, otherErr error
+	_, err := interceptor(ctx, nil, reviewInfo, func(context.Context, interface{}) (interface{}, error) {
+		// The same peer from another port is over its limit, other peers are not.
+		_, nestedErr = interceptor(peerContext("10.0.0.1:5678"), nil, reviewInfo, noop)
+		_, otherErr = interceptor(peerContext("10.0.0.2:1234"), nil, reviewInfo, noop)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Code(nestedErr) != codes.ResourceExhausted {
+		t.Errorf("got error %v for concurrent request, want RESOURCE_EXHAUSTED", nestedErr)
+	}
+	if otherErr != nil {
+		t.Errorf("got error %v for other peer, want none", otherErr)
+	}
+	if _, err := interceptor(ctx, nil, reviewInfo, noop); err != nil {
+		t.Errorf("got error %v once the request finished, want none", err)
+	}
+
+	want := []Stats{
+		{Peer: "10.0.0.1", Allowed: 2, RejectedConcurrency: 1},
+		{Peer: "10.0.0.2", Allowed: 1},
+	}
+	if diff := cmp.Diff(want, l.Stats()); diff != "" {
+		t.Errorf("stats diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestRate(t *testing.T) {
+	l := New(Limits{Rate: 1, Burst: 2})
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+	interceptor := l.UnaryServerInterceptor()
+	ctx := identity.NewVerifiedContext(peerContext("10.0.0.1:1234"), "pipeline-a")
+
+	for i := 0; i < 2; i++ {
+		if _, err := interceptor(ctx, nil, reviewInfo, noop); err != nil {
+			t.Fatalf("request %d within the burst: %v", i, err)
+		}
+	}
+	_, err := interceptor(ctx, nil, reviewInfo, noop)
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("got error %v over the rate, want RESOURCE_EXHAUSTED", err)
+	}
+	var retry *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		retry, _ = detail.(*errdetails.RetryInfo)
+	}
+	if retry == nil || retry.GetRetryDelay().AsDuration() != time.Second {
+		t.Errorf("got details %v, want a retry delay of 1s", st.Details())
+	}
+
+	now = now.Add(time.Second)
+	if _, err := interceptor(ctx, nil, reviewInfo, noop); err != nil {
+		t.Errorf("got error %v after the delay, want none", err)
+	}
+	want := []Stats{{Peer: "pipeline-a", Allowed: 3, RejectedRate: 1}}
+	if diff := cmp.Diff(want, l.Stats()); diff != "" {
+		t.Errorf("stats diff (-want +got):\n%s", diff)
+	}
+
+	now = now.Add(idleTimeout + sweepInterval + time.Second)
+	if _, err := interceptor(peerContext("10.0.0.2:1234"), nil, reviewInfo, noop); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Stats(); len(got) != 1 || got[0].Peer != "10.0.0.2" {
+		t.Errorf("got stats %v, want the idle peer dropped", got)
+	}
+}
+
+func TestUnverifiedIdentity(t *testing.T) {
+	l := New(Limits{MaxConcurrent: 1})
+	interceptor := l.UnaryServerInterceptor()
+
+	// A client can't escape its limits by claiming a new identity with each request.
+	var nestedErr error
+	_, err := interceptor(identity.NewContext(peerContext("10.0.0.1:1234"), "pipeline-a"), nil, reviewInfo, func(context.Context, interface{}) (interface{}, error) {
+		_, nestedErr = interceptor(identity.NewContext(peerContext("10.0.0.1:1234"), "pipeline-b"), nil, reviewInfo, noop)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Code(nestedErr) != codes.ResourceExhausted {
+		t.Errorf("got error %v for concurrent request, want RESOURCE_EXHAUSTED", nestedErr)
+	}
+	want := []Stats{{Peer: "10.0.0.1", Allowed: 1, RejectedConcurrency: 1}}
+	if diff := cmp.Diff(want, l.Stats()); diff != "" {
+		t.Errorf("stats diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestMaxPeers(t *testing.T) {
+	l := New(Limits{MaxConcurrent: 1, MaxPeers: 2})
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+	interceptor := l.UnaryServerInterceptor()
+
+	for _, addr := range []string{"10.0.0.1:1234", "10.0.0.2:1234"} {
+		if _, err := interceptor(peerContext(addr), nil, reviewInfo, noop); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Second)
+	}
+	// The least recently seen peer is dropped for a new one.
+	if _, err := interceptor(peerContext("10.0.0.3:1234"), nil, reviewInfo, noop); err != nil {
+		t.Fatal(err)
+	}
+	want := []Stats{{Peer: "10.0.0.2", Allowed: 1}, {Peer: "10.0.0.3", Allowed: 1}}
+	if diff := cmp.Diff(want, l.Stats()); diff != "" {
+		t.Errorf("stats diff (-want +got):\n%s", diff)
+	}
+
+	// New peers are rejected while every tracked peer has a request in flight.
+	var newErr error
+	_, err := interceptor(peerContext("10.0.0.2:1234"), nil, reviewInfo, func(context.Context, interface{}) (interface{}, error) {
+		_, err := interceptor(peerContext("10.0.0.3:1234"), nil, reviewInfo, func(context.Context, interface{}) (interface{}, error) {
+			_, newErr = interceptor(peerContext("10.0.0.4:1234"), nil, reviewInfo, noop)
+			return nil, nil
+		})
+		return nil, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Code(newErr) != codes.ResourceExhausted {
+		t.Errorf("got error %v for a new peer, want RESOURCE_EXHAUSTED", newErr)
+	}
+}
+
+func noop(context.Context, interface{}) (interface{}, error) {
+	return nil, nil
+}
@@ -0,0 +1,311 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scaffold generates the skeleton of a new ConstraintTemplate and a sample constraint of it,
+// with the v1beta1 structure, target block and violation rule signature that the validator expects,
+// for policy authors to fill in.
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv/configs"
+)
+
+// Targets maps the short names of the targets that templates can be generated for to the target
+// names.
+var Targets = map[string]string{
+	"gcp": configs.GCPTargetName,
+	"tf":  configs.TFTargetName,
+	"k8s": configs.K8STargetName,
+}
+
+// parameterTypes are the OpenAPI types of parameters, with the value they have in the sample
+// constraint.  Arrays are arrays of strings.
+var parameterTypes = map[string]string{
+	"string":  `""`,
+	"integer": "0",
+	"number":  "0",
+	"boolean": "false",
+	"array":   "[]",
+	"object":  "{}",
+}
+
+var (
+	kindPattern      = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	parameterPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Parameter is a parameter of the generated template.
+type Parameter struct {
+	// Name is the name of the parameter, eg allowlist.
+	Name string
+	// Type is the OpenAPI type of the parameter: string, integer, number, boolean, array, of strings,
+	// or object.
+	Type string
+}
+
+// Options are the options of Generate.
+type Options struct {
+	// Kind is the kind of the constraints of the template, eg GCPStorageLocationConstraintV1.  The
+	// template is named after it in lower case.
+	Kind string
+	// Target is the short name of the target of the template, see Targets.
+	Target string
+	// ResourceType is the type of the resources the rule checks, if set: the CAI asset type for gcp,
+	// eg storage.googleapis.com/Bucket, the resource type for tf, eg google_storage_bucket, or the
+	// kind matched by the sample constraint for k8s, eg Namespace.
+	ResourceType string
+	// Parameters are the parameters of the template.
+	Parameters []Parameter
+}
+
+// Scaffold is a generated template and sample constraint.
+type Scaffold struct {
+	// TemplateName is the name of the template.
+	TemplateName string
+	// Template is the YAML of the template.
+	Template []byte
+	// ConstraintName is the name of the sample constraint.
+	ConstraintName string
+	// Constraint is the YAML of the sample constraint.
+	Constraint []byte
+}
+
+// ParseParameter parses a parameter given as name or name:type, the type defaulting to string.  The
+// parameter is validated by Generate.
+func ParseParameter(value string) Parameter {
+	name, paramType, found := strings.Cut(value, ":")
+	if !found {
+		paramType = "string"
+	}
+	return Parameter{Name: name, Type: paramType}
+}
+
+// Generate generates a template and sample constraint with the options.
+func Generate(opts Options) (*Scaffold, error) {
+	if !kindPattern.MatchString(opts.Kind) {
+		return nil, fmt.Errorf("invalid kind %q, expected a CamelCase name such as GCPStorageLocationConstraintV1", opts.Kind)
+	}
+	targetName, found := Targets[opts.Target]
+	if !found {
+		return nil, fmt.Errorf("unknown target %q, expected one of %s", opts.Target, strings.Join(targetNames(), ", "))
+	}
+	seen := map[string]bool{}
+	var params []templateParameter
+	for _, param := range opts.Parameters {
+		if !parameterPattern.MatchString(param.Name) {
+			return nil, fmt.Errorf("invalid parameter name %q, expected a Rego identifier", param.Name)
+		}
+		sample, found := parameterTypes[param.Type]
+		if !found {
+			return nil, fmt.Errorf("parameter %s has unknown type %q", param.Name, param.Type)
+		}
+		if seen[param.Name] {
+			return nil, fmt.Errorf("duplicate parameter %s", param.Name)
+		}
+		seen[param.Name] = true
+		params = append(params, templateParameter{Parameter: param, Sample: sample})
+	}
+
+	s := &Scaffold{
+		TemplateName:   strings.ToLower(opts.Kind),
+		ConstraintName: strings.ToLower(opts.Kind) + "-sample",
+	}
+	data := templateData{
+		Options:        opts,
+		TargetName:     targetName,
+		TemplateName:   s.TemplateName,
+		ConstraintName: s.ConstraintName,
+		Parameters:     params,
+	}
+	var err error
+	if s.Template, err = execute("template", data); err != nil {
+		return nil, err
+	}
+	if s.Constraint, err = execute("constraint", data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// PolicyFiles returns the template under templates/ and the sample constraint under constraints/, as
+// laid out in a policy library.
+func (s *Scaffold) PolicyFiles() []*configs.PolicyFile {
+	return []*configs.PolicyFile{
+		{Path: filepath.Join("templates", s.TemplateName+".yaml"), Content: s.Template},
+		{Path: filepath.Join("constraints", s.ConstraintName+".yaml"), Content: s.Constraint},
+	}
+}
+
+// targetNames returns the sorted short names of Targets.
+func targetNames() []string {
+	var names []string
+	for name := range Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type templateParameter struct {
+	Parameter
+	Sample string
+}
+
+type templateData struct {
+	Options
+	TargetName     string
+	TemplateName   string
+	ConstraintName string
+	Parameters     []templateParameter
+}
+
+func execute(name string, data templateData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, fmt.Errorf("failed to generate %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+var templates = template.Must(template.New("").Parse(`
+{{- define "template" -}}
+apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: {{.TemplateName}}
+spec:
+  crd:
+    spec:
+      names:
+        kind: {{.Kind}}
+      validation:
+        openAPIV3Schema:
+          type: object
+{{- if .Parameters}}
+          properties:
+{{- range .Parameters}}
+            {{.Name}}:
+              description: "TODO: describe {{.Name}}."
+              type: {{.Type}}
+{{- if eq .Type "array"}}
+              items:
+                type: string
+{{- end}}
+{{- end}}
+{{- else}}
+          properties: {}
+{{- end}}
+  targets:
+    - target: {{.TargetName}}
+      rego: |
+{{- if eq .Target "gcp"}}
+        package templates.gcp.{{.Kind}}
+
+        violation[{
+        	"msg": message,
+        	"details": metadata,
+        }] {
+        	asset := input.review
+{{- if .ResourceType}}
+        	asset.asset_type == "{{.ResourceType}}"
+{{- end}}
+{{template "params" .}}
+        	# TODO: add the conditions under which the asset violates the constraint, eg on
+        	# asset.resource.data.  As is, every matched asset is a violation.
+
+        	message := sprintf("%v violates {{.Kind}}.", [asset.name])
+        	metadata := {"resource": asset.name}
+        }
+{{- else if eq .Target "tf"}}
+        package templates.terraform.{{.Kind}}
+
+        violation[{
+        	"msg": message,
+        	"details": metadata,
+        }] {
+        	resource := input.review
+{{- if .ResourceType}}
+        	resource.type == "{{.ResourceType}}"
+{{- end}}
+        	count({"create", "update"} & {action | action := resource.change.actions[_]}) > 0
+{{template "params" .}}
+        	# TODO: add the conditions under which the resource change violates the constraint, eg on
+        	# resource.change.after.  As is, every matched change is a violation.
+
+        	message := sprintf("%v violates {{.Kind}}.", [resource.address])
+        	metadata := {"resource": resource.address}
+        }
+{{- else}}
+        package templates.k8s.{{.Kind}}
+
+        violation[{
+        	"msg": message,
+        	"details": metadata,
+        }] {
+        	object := input.review.object
+{{template "params" .}}
+        	# TODO: add the conditions under which the object violates the constraint.  As is,
+        	# every object of the kinds matched by the constraint is a violation.
+
+        	message := sprintf("%v violates {{.Kind}}.", [object.metadata.name])
+        	metadata := {"resource": object.metadata.name}
+        }
+{{- end}}
+{{end}}
+
+{{- define "params"}}
+{{- range .Parameters}}
+        	# {{.Name}} := input.parameters.{{.Name}}
+{{- end}}
+{{- end}}
+
+{{- define "constraint" -}}
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: {{.Kind}}
+metadata:
+  name: {{.ConstraintName}}
+spec:
+  severity: medium
+{{- if eq .Target "gcp"}}
+  match:
+    ancestries:
+      - "organizations/**"
+{{- else if eq .Target "tf"}}
+  match:
+    addresses:
+      - "**"
+{{- else if .ResourceType}}
+  match:
+    kinds:
+      - apiGroups: ["*"]
+        kinds: ["{{.ResourceType}}"]
+{{- end}}
+{{- if .Parameters}}
+  parameters:
+{{- range .Parameters}}
+    {{.Name}}: {{.Sample}}
+{{- end}}
+{{- else}}
+  parameters: {}
+{{- end}}
+{{end}}
+`))
@@ -0,0 +1,143 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaffold
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/gcv"
+)
+
+func TestGenerate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   Options
+		review map[string]interface{}
+	}{
+		{
+			name: "gcp",
+			opts: Options{
+				Kind:         "GCPBucketLocationConstraintV1",
+				Target:       "gcp",
+				ResourceType: "storage.googleapis.com/Bucket",
+				Parameters:   []Parameter{{Name: "locations", Type: "array"}, {Name: "exempt", Type: "boolean"}},
+			},
+			review: map[string]interface{}{
+				"name":          "//storage.googleapis.com/my-bucket",
+				"asset_type":    "storage.googleapis.com/Bucket",
+				"ancestry_path": "organizations/123/projects/456",
+				"resource":      map[string]interface{}{"data": map[string]interface{}{}},
+			},
+		},
+		{
+			name: "tf",
+			opts: Options{
+				Kind:         "TFBucketLocationConstraintV1",
+				Target:       "tf",
+				ResourceType: "google_storage_bucket",
+				Parameters:   []Parameter{{Name: "locations", Type: "array"}},
+			},
+			review: map[string]interface{}{
+				"address":       "google_storage_bucket.my_bucket",
+				"type":          "google_storage_bucket",
+				"name":          "my_bucket",
+				"provider_name": "registry.terraform.io/hashicorp/google",
+				"change": map[string]interface{}{
+					"actions": []interface{}{"create"},
+					"after":   map[string]interface{}{"location": "US"},
+				},
+			},
+		},
+		{
+			name: "k8s",
+			opts: Options{
+				Kind:         "K8sNamespaceOwnerConstraintV1",
+				Target:       "k8s",
+				ResourceType: "Namespace",
+				Parameters:   []Parameter{{Name: "owner"}},
+			},
+			review: map[string]interface{}{
+				"name":       "//container.googleapis.com/projects/my-project/zones/us-central1-a/clusters/c/k8s/namespaces/ns",
+				"asset_type": "k8s.io/Namespace",
+				"ancestors":  []interface{}{"projects/456", "organizations/123"},
+				"resource": map[string]interface{}{
+					"version":        "v1",
+					"discovery_name": "io.k8s.api.core.v1.Namespace",
+					"data":           map[string]interface{}{"metadata": map[string]interface{}{"name": "ns"}},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for idx := range tc.opts.Parameters {
+				if tc.opts.Parameters[idx].Type == "" {
+					tc.opts.Parameters[idx].Type = "string"
+				}
+			}
+			s, err := Generate(tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The skeleton rule flags every matched resource, so the sample constraint must load and
+			// report the review.
+			v, err := gcv.NewValidatorFromContents(s.PolicyFiles(), []string{"package validator.gcp.lib\n"}, gcv.StrictParameters())
+			if err != nil {
+				t.Fatalf("generated policies don't load: %v\n%s\n%s", err, s.Template, s.Constraint)
+			}
+			var got int
+			if tc.opts.Target == "tf" {
+				violations, err := v.ReviewTFResourceChange(context.Background(), tc.review)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = len(violations)
+			} else {
+				result, err := v.ReviewUnmarshalledJSON(context.Background(), tc.review)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = len(result.ConstraintViolations)
+			}
+			if got != 1 {
+				t.Errorf("got %d violations, want 1", got)
+			}
+		})
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"lower case kind":    {Kind: "bucketLocation", Target: "gcp"},
+		"unknown target":     {Kind: "BucketLocation", Target: "azure"},
+		"invalid parameter":  {Kind: "BucketLocation", Target: "gcp", Parameters: []Parameter{{Name: "a-b", Type: "string"}}},
+		"unknown type":       {Kind: "BucketLocation", Target: "gcp", Parameters: []Parameter{{Name: "a", Type: "map"}}},
+		"repeated parameter": {Kind: "BucketLocation", Target: "gcp", Parameters: []Parameter{{Name: "a", Type: "string"}, {Name: "a", Type: "array"}}},
+	} {
+		if _, err := Generate(opts); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+func TestParseParameter(t *testing.T) {
+	if got, want := ParseParameter("locations:array"), (Parameter{Name: "locations", Type: "array"}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got, want := ParseParameter("owner"), (Parameter{Name: "owner", Type: "string"}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shadow evaluates a sample of the assets reviewed by the validator RPC service against a
+// second, shadow, version of the policies, and records how its violations differ from those returned
+// to clients, so that new policy versions can be canaried on live traffic.
+//
+// Shadow reviews run in the background after the response is returned, their violations are never
+// returned to clients.
+package shadow
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/golang/glog"
+)
+
+const (
+	// defaultTimeout is the default deadline of a shadow review.
+	defaultTimeout = time.Minute
+	// defaultMaxInFlight is the default number of shadow reviews that may run at once.
+	defaultMaxInFlight = 4
+)
+
+// Reviewer reviews assets, such as a gcv.ParallelValidator.
+type Reviewer interface {
+	Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error)
+}
+
+// Stats are the results of the shadow reviews since the Evaluator was created.
+type Stats struct {
+	// SampledAssets is the number of assets reviewed with the shadow policies.
+	SampledAssets int64 `json:"sampledAssets"`
+	// DifferingAssets is the number of sampled assets whose violations differ.
+	DifferingAssets int64 `json:"differingAssets"`
+	// Introduced is the number of violations that only the shadow policies report.
+	Introduced int64 `json:"introduced"`
+	// Resolved is the number of violations that only the served policies report.
+	Resolved int64 `json:"resolved"`
+	// Errors is the number of shadow reviews that failed.
+	Errors int64 `json:"errors"`
+	// Dropped is the number of sampled requests that were not shadowed because too many shadow
+	// reviews were in flight, or because they override parameters or select constraints by name,
+	// which may not exist in the shadow policies.
+	Dropped int64 `json:"dropped"`
+}
+
+// Option configures an Evaluator.
+type Option func(*Evaluator)
+
+// WithTimeout sets the deadline of each shadow review, one minute by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Evaluator) {
+		e.timeout = timeout
+	}
+}
+
+// WithMaxInFlight sets the number of shadow reviews that may run at once, 4 by default.  Sampled
+// requests beyond it are dropped rather than queued, so that shadowing can't build up a backlog.
+func WithMaxInFlight(n int) Option {
+	return func(e *Evaluator) {
+		e.maxInFlight = n
+	}
+}
+
+// WithNameNormalizer sets the function that returns the resource name the violations of an asset are
+// reported on, such as gcv.Validator.NormalizeName, to match the served violations of the sampled
+// assets.  Asset names are used as they are by default.
+func WithNameNormalizer(normalize func(name, assetType string) string) Option {
+	return func(e *Evaluator) {
+		e.normalizeName = normalize
+	}
+}
+
+// Evaluator reviews a sample of assets with the shadow policies.
+type Evaluator struct {
+	shadow      Reviewer
+	rate        float64
+	timeout     time.Duration
+	maxInFlight int
+	// normalizeName returns the resource name of the violations of an asset, nil if it is the name.
+	normalizeName func(name, assetType string) string
+
+	inFlight chan struct{}
+	wg       sync.WaitGroup
+	mtx      sync.Mutex
+	stats    Stats
+}
+
+// New returns an Evaluator that reviews the fraction rate, in [0, 1], of assets with the shadow
+// reviewer.  Assets are sampled by a hash of their name, so the same assets are always shadowed.
+func New(shadow Reviewer, rate float64, opts ...Option) *Evaluator {
+	e := &Evaluator{
+		shadow:      shadow,
+		rate:        rate,
+		timeout:     defaultTimeout,
+		maxInFlight: defaultMaxInFlight,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.inFlight = make(chan struct{}, e.maxInFlight)
+	return e
+}
+
+// inSample returns true if the asset is in the sample.  Asset names mostly differ in their last
+// characters, so they are hashed with SHA-256 rather than FNV, whose high bits barely change with them.
+func (e *Evaluator) inSample(name string) bool {
+	if e.rate >= 1 {
+		return true
+	}
+	sum := sha256.Sum256([]byte(name))
+	return float64(binary.BigEndian.Uint64(sum[:8]))/math.MaxUint64 < e.rate
+}
+
+// Observe starts the shadow review of the sampled assets of a request, given the response returned
+// to the client, and returns without waiting for it.
+func (e *Evaluator) Observe(request *validator.ReviewRequest, response *validator.ReviewResponse) {
+	var sampled []*validator.Asset
+	for _, asset := range request.GetAssets() {
+		if e.inSample(asset.GetName()) {
+			sampled = append(sampled, asset)
+		}
+	}
+	if len(sampled) == 0 {
+		return
+	}
+	if len(request.GetParameterOverrides()) > 0 || request.GetOverlay() != nil {
+		e.add(Stats{Dropped: 1})
+		return
+	}
+	select {
+	case e.inFlight <- struct{}{}:
+	default:
+		e.add(Stats{Dropped: 1})
+		return
+	}
+	shadowRequest := &validator.ReviewRequest{
+		Assets:         sampled,
+		EvaluationTime: request.GetEvaluationTime(),
+		ApplySampling:  request.GetApplySampling(),
+		Options:        request.GetOptions(),
+	}
+	served := response.GetViolations()
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		defer func() { <-e.inFlight }()
+		e.compare(shadowRequest, served)
+	}()
+}
+
+// compare reviews the request with the shadow policies and records how its violations differ from the
+// served violations of its assets.
+func (e *Evaluator) compare(request *validator.ReviewRequest, served []*validator.Violation) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	response, err := e.shadow.Review(ctx, request)
+	if err != nil {
+		glog.Warningf("shadow review of %d assets failed: %v", len(request.Assets), err)
+		e.add(Stats{Errors: 1})
+		return
+	}
+
+	sampled := map[string]bool{}
+	for _, asset := range request.Assets {
+		sampled[e.resourceName(asset)] = true
+	}
+	counts := map[violationKey]int{}
+	for _, v := range served {
+		if sampled[v.GetResource()] {
+			counts[keyOf(v)]--
+		}
+	}
+	for _, v := range response.GetViolations() {
+		counts[keyOf(v)]++
+	}
+
+	stats := Stats{SampledAssets: int64(len(request.Assets))}
+	differing := map[string]bool{}
+	for key, count := range counts {
+		switch {
+		case count > 0:
+			stats.Introduced += int64(count)
+			glog.V(1).Infof("shadow policies introduce %d violations of %s on %s: %s", count, key.constraint, key.resource, key.message)
+		case count < 0:
+			stats.Resolved += int64(-count)
+			glog.V(1).Infof("shadow policies resolve %d violations of %s on %s: %s", -count, key.constraint, key.resource, key.message)
+		default:
+			continue
+		}
+		differing[key.resource] = true
+	}
+	stats.DifferingAssets = int64(len(differing))
+	if len(differing) > 0 {
+		glog.Infof("shadow review of %d assets: %d differ, %d violations introduced, %d resolved",
+			stats.SampledAssets, stats.DifferingAssets, stats.Introduced, stats.Resolved)
+	}
+	e.add(stats)
+}
+
+// resourceName returns the resource name the violations of the asset are reported on.
+func (e *Evaluator) resourceName(asset *validator.Asset) string {
+	if e.normalizeName == nil {
+		return asset.GetName()
+	}
+	return e.normalizeName(asset.GetName(), asset.GetAssetType())
+}
+
+// violationKey identifies a violation in the served and shadow responses.
+type violationKey struct {
+	constraint string
+	resource   string
+	message    string
+}
+
+func keyOf(v *validator.Violation) violationKey {
+	return violationKey{constraint: v.GetConstraint(), resource: v.GetResource(), message: v.GetMessage()}
+}
+
+func (e *Evaluator) add(s Stats) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.stats.SampledAssets += s.SampledAssets
+	e.stats.DifferingAssets += s.DifferingAssets
+	e.stats.Introduced += s.Introduced
+	e.stats.Resolved += s.Resolved
+	e.stats.Errors += s.Errors
+	e.stats.Dropped += s.Dropped
+}
+
+// Stats returns the results of the shadow reviews so far.
+func (e *Evaluator) Stats() Stats {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return e.stats
+}
+
+// Wait waits for the shadow reviews in flight to finish.
+func (e *Evaluator) Wait() {
+	e.wg.Wait()
+}
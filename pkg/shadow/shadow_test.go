@@ -0,0 +1,148 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shadow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeReviewer reports a violation of the constraint on every asset.
+type fakeReviewer struct {
+	constraint string
+	release    chan struct{}
+	// normalize, if set, returns the resource name of the violations of an asset.
+	normalize func(name, assetType string) string
+}
+
+func (r *fakeReviewer) Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
+	if r.release != nil {
+		<-r.release
+	}
+	if r.constraint == "" {
+		return nil, fmt.Errorf("review failed")
+	}
+	response := &validator.ReviewResponse{}
+	for _, asset := range request.Assets {
+		resource := asset.Name
+		if r.normalize != nil {
+			resource = r.normalize(asset.Name, asset.AssetType)
+		}
+		response.Violations = append(response.Violations, &validator.Violation{
+			Constraint: r.constraint,
+			Resource:   resource,
+			Message:    "denied",
+		})
+	}
+	return response, nil
+}
+
+func assets(names ...string) []*validator.Asset {
+	var assets []*validator.Asset
+	for _, name := range names {
+		assets = append(assets, &validator.Asset{Name: name})
+	}
+	return assets
+}
+
+func TestObserve(t *testing.T) {
+	e := New(&fakeReviewer{constraint: "GCPDeniedNamesConstraintV1.deny"}, 1)
+	request := &validator.ReviewRequest{Assets: assets("a", "b", "c")}
+	response := &validator.ReviewResponse{Violations: []*validator.Violation{
+		{Constraint: "GCPDeniedNamesConstraintV1.deny", Resource: "a", Message: "denied"},
+		{Constraint: "GCPAllowedNamesConstraintV1.allow", Resource: "b", Message: "not allowed"},
+	}}
+	e.Observe(request, response)
+	e.Wait()
+
+	want := Stats{SampledAssets: 3, DifferingAssets: 2, Introduced: 2, Resolved: 1}
+	if diff := cmp.Diff(want, e.Stats()); diff != "" {
+		t.Errorf("stats diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestObserveNormalizedNames(t *testing.T) {
+	normalize := func(name, assetType string) string {
+		return strings.ToLower(name)
+	}
+	e := New(&fakeReviewer{constraint: "GCPDeniedNamesConstraintV1.deny", normalize: normalize}, 1, WithNameNormalizer(normalize))
+	// The served violation is reported on the normalized name of the asset.
+	request := &validator.ReviewRequest{Assets: assets("//storage.googleapis.com/Bucket")}
+	response := &validator.ReviewResponse{Violations: []*validator.Violation{
+		{Constraint: "GCPDeniedNamesConstraintV1.deny", Resource: "//storage.googleapis.com/bucket", Message: "denied"},
+	}}
+	e.Observe(request, response)
+	e.Wait()
+
+	want := Stats{SampledAssets: 1}
+	if diff := cmp.Diff(want, e.Stats()); diff != "" {
+		t.Errorf("stats diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestObserveSamplesByName(t *testing.T) {
+	e := New(&fakeReviewer{constraint: "GCPDeniedNamesConstraintV1.deny"}, 0.5)
+	var names []string
+	for i := 0; i < 1000; i++ {
+		names = append(names, fmt.Sprintf("//storage.googleapis.com/bucket-%d", i))
+	}
+	for i := 0; i < 2; i++ {
+		e.Observe(&validator.ReviewRequest{Assets: assets(names...)}, &validator.ReviewResponse{})
+		e.Wait()
+	}
+
+	got := e.Stats()
+	if got.SampledAssets < 800 || got.SampledAssets > 1200 {
+		t.Errorf("sampled %d of 2000 assets at 50%%", got.SampledAssets)
+	}
+	if got.SampledAssets%2 != 0 {
+		t.Errorf("sampled %d assets, want the same assets sampled each time", got.SampledAssets)
+	}
+}
+
+func TestObserveDrops(t *testing.T) {
+	release := make(chan struct{})
+	e := New(&fakeReviewer{constraint: "GCPDeniedNamesConstraintV1.deny", release: release}, 1, WithMaxInFlight(1))
+	e.Observe(&validator.ReviewRequest{Assets: assets("a")}, &validator.ReviewResponse{})
+	// Over the in flight limit.
+	e.Observe(&validator.ReviewRequest{Assets: assets("b")}, &validator.ReviewResponse{})
+	// Parameter overrides may not apply to the shadow policies.
+	e.Observe(&validator.ReviewRequest{
+		Assets:             assets("c"),
+		ParameterOverrides: []*validator.ParameterOverride{{}},
+	}, &validator.ReviewResponse{})
+	close(release)
+	e.Wait()
+
+	want := Stats{SampledAssets: 1, DifferingAssets: 1, Introduced: 1, Dropped: 2}
+	if diff := cmp.Diff(want, e.Stats()); diff != "" {
+		t.Errorf("stats diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestObserveError(t *testing.T) {
+	e := New(&fakeReviewer{}, 1)
+	e.Observe(&validator.ReviewRequest{Assets: assets("a")}, &validator.ReviewResponse{})
+	e.Wait()
+
+	if got := e.Stats(); got != (Stats{Errors: 1}) {
+		t.Errorf("got stats %+v, want one error", got)
+	}
+}
@@ -16,17 +16,31 @@ package tftarget
 
 import (
 	"fmt"
+	"path"
 
 	"github.com/gobwas/glob"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 type matcher struct {
 	addresses         []string
 	excludedAddresses []string
+	// resourceTypes are globs for the resource types to match, empty matches all resource types.
+	resourceTypes         []string
+	excludedResourceTypes []string
+	// providers are globs for the provider to match, either by its full name such as
+	// registry.terraform.io/hashicorp/google-beta or its short name such as google-beta.  Empty
+	// matches all providers.
+	providers []string
+	// actions are the change actions to match, a review matches if any of its change.actions is in
+	// actions.  Replacements have both "create" and "delete" actions.  Empty matches all changes.
+	actions []string
 }
 
 var ErrInvalidReview = fmt.Errorf("unexpected type of review, expect map[string]interface{}")
 var ErrInvalidAddress = fmt.Errorf("unexpected type of address in review object")
+var ErrInvalidResourceType = fmt.Errorf("unexpected type of resource type in review object")
+var ErrInvalidActions = fmt.Errorf("unexpected type of change.actions in review object")
 
 // Match returns true if the Matcher's Constraint should run against the
 // passed review object.
@@ -58,5 +72,69 @@ func (m *matcher) Match(review interface{}) (bool, error) {
 			return false, nil
 		}
 	}
+
+	if len(m.resourceTypes) != 0 || len(m.excludedResourceTypes) != 0 {
+		resourceType, ok := reviewObj["type"].(string)
+		if !ok {
+			return false, ErrInvalidResourceType
+		}
+		if len(m.resourceTypes) != 0 && !matchesAny(m.resourceTypes, resourceType) {
+			return false, nil
+		}
+		if matchesAny(m.excludedResourceTypes, resourceType) {
+			return false, nil
+		}
+	}
+
+	if len(m.providers) != 0 {
+		// Reviews without a provider_name only match constraints that don't select providers.
+		providerName, _ := reviewObj["provider_name"].(string)
+		if providerName == "" {
+			return false, nil
+		}
+		if !matchesAny(m.providers, providerName, '/') && !matchesAny(m.providers, path.Base(providerName)) {
+			return false, nil
+		}
+	}
+
+	if len(m.actions) != 0 {
+		actions, found, err := unstructured.NestedFieldNoCopy(reviewObj, "change", "actions")
+		if err != nil {
+			return false, ErrInvalidActions
+		}
+		if !found {
+			return false, nil
+		}
+		actionList, ok := actions.([]interface{})
+		if !ok {
+			return false, ErrInvalidActions
+		}
+		if !anyAction(m.actions, actionList) {
+			return false, nil
+		}
+	}
 	return true, nil
 }
+
+// anyAction returns true if any of the review actions is one of the wanted actions.
+func anyAction(want []string, actions []interface{}) bool {
+	for _, action := range actions {
+		for _, w := range want {
+			if action == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesAny returns true if value matches any of the glob patterns.
+func matchesAny(patterns []string, value string, separators ...rune) bool {
+	for _, pattern := range patterns {
+		g := glob.MustCompile(pattern, separators...)
+		if g.Match(value) {
+			return true
+		}
+	}
+	return false
+}
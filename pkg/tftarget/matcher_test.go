@@ -7,12 +7,16 @@ import (
 
 func TestMatch(t *testing.T) {
 	tests := []struct {
-		name    string
-		include []string
-		exclude []string
-		review  interface{}
-		want    bool
-		wantErr error
+		name                  string
+		include               []string
+		exclude               []string
+		resourceTypes         []string
+		excludedResourceTypes []string
+		providers             []string
+		actions               []string
+		review                interface{}
+		want                  bool
+		wantErr               error
 	}{
 		{
 			name:    "include **",
@@ -65,6 +69,111 @@ func TestMatch(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name:          "resource type match",
+			include:       []string{"**"},
+			resourceTypes: []string{"google_compute_*"},
+			review: map[string]interface{}{
+				"address": "google_compute_instance.abc",
+				"type":    "google_compute_instance",
+			},
+			want: true,
+		},
+		{
+			name:          "resource type not match",
+			include:       []string{"**"},
+			resourceTypes: []string{"google_compute_*"},
+			review: map[string]interface{}{
+				"address": "google_storage_bucket.abc",
+				"type":    "google_storage_bucket",
+			},
+			want: false,
+		},
+		{
+			name:                  "excluded resource type",
+			include:               []string{"**"},
+			excludedResourceTypes: []string{"google_storage_*"},
+			review: map[string]interface{}{
+				"address": "google_storage_bucket.abc",
+				"type":    "google_storage_bucket",
+			},
+			want: false,
+		},
+		{
+			name:      "provider short name",
+			include:   []string{"**"},
+			providers: []string{"google-beta"},
+			review: map[string]interface{}{
+				"address":       "abc.def",
+				"provider_name": "registry.terraform.io/hashicorp/google-beta",
+			},
+			want: true,
+		},
+		{
+			name:      "provider glob does not cross path segments",
+			include:   []string{"**"},
+			providers: []string{"registry.terraform.io/*"},
+			review: map[string]interface{}{
+				"address":       "abc.def",
+				"provider_name": "registry.terraform.io/hashicorp/google-beta",
+			},
+			want: false,
+		},
+		{
+			name:      "provider missing",
+			include:   []string{"**"},
+			providers: []string{"google"},
+			review: map[string]interface{}{
+				"address": "abc.def",
+			},
+			want: false,
+		},
+		{
+			name:          "invalid resource type",
+			include:       []string{"**"},
+			resourceTypes: []string{"google_*"},
+			review: map[string]interface{}{
+				"address": "abc.def",
+				"type":    123,
+			},
+			wantErr: ErrInvalidResourceType,
+		},
+		{
+			name:    "action match",
+			include: []string{"**"},
+			actions: []string{"create", "update"},
+			review: map[string]interface{}{
+				"address": "abc.def",
+				"change": map[string]interface{}{
+					"actions": []interface{}{"update"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:    "action not match",
+			include: []string{"**"},
+			actions: []string{"delete"},
+			review: map[string]interface{}{
+				"address": "abc.def",
+				"change": map[string]interface{}{
+					"actions": []interface{}{"create"},
+				},
+			},
+			want: false,
+		},
+		{
+			name:    "invalid actions",
+			include: []string{"**"},
+			actions: []string{"delete"},
+			review: map[string]interface{}{
+				"address": "abc.def",
+				"change": map[string]interface{}{
+					"actions": "delete",
+				},
+			},
+			wantErr: ErrInvalidActions,
+		},
 		{
 			name: "invalid address",
 			review: map[string]interface{}{
@@ -81,8 +190,12 @@ func TestMatch(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			matcher := &matcher{
-				addresses:         test.include,
-				excludedAddresses: test.exclude,
+				addresses:             test.include,
+				excludedAddresses:     test.exclude,
+				resourceTypes:         test.resourceTypes,
+				excludedResourceTypes: test.excludedResourceTypes,
+				providers:             test.providers,
+				actions:               test.actions,
 			}
 			got, err := matcher.Match(test.review)
 			if got != test.want {
@@ -20,6 +20,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/gobwas/glob"
 	"github.com/open-policy-agent/frameworks/constraint/pkg/core/constraints"
 	"github.com/open-policy-agent/frameworks/constraint/pkg/handler"
 	"github.com/open-policy-agent/frameworks/constraint/pkg/types"
@@ -31,6 +32,25 @@ import (
 // Name is the target name for TFTarget
 const Name = "validation.resourcechange.terraform.cloud.google.com"
 
+// Keys added to the review object from the plan a resource change belongs to.
+const (
+	// ModulePathKey is the address of the module that declares the resource, eg
+	// "module.network.module.subnets", or an empty string for the root module.
+	ModulePathKey = "module_path"
+	// RootModuleVariablesKey holds the values of the root module's input variables by name.
+	RootModuleVariablesKey = "root_module_variables"
+	// ProviderVersionConstraintKey is the version constraint of the resource's provider, eg ">= 4.0",
+	// absent if the configuration does not constrain the provider version.
+	ProviderVersionConstraintKey = "provider_version_constraint"
+	// PlanMetadataKey holds the plan-level data: the terraform_version and format_version of the plan,
+	// the workspace set by the caller, or empty, and the variables of the root module that are not
+	// declared sensitive, by name.
+	PlanMetadataKey = "plan_metadata"
+)
+
+// changeActions are the values terraform uses in change.actions of a resource change.
+var changeActions = []apiextensions.JSON{"no-op", "create", "read", "update", "delete"}
+
 // TFTarget is the constraint framework target for config-validator
 type TFTarget struct {
 }
@@ -68,9 +88,30 @@ func (g *TFTarget) ToMatcher(constraint *unstructured.Unstructured) (constraints
 		exclude = []string{}
 	}
 
+	resourceTypes, _, err := unstructured.NestedStringSlice(match, "resourceTypes")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get string slice from spec.match.resourceTypes: %w", err)
+	}
+	excludedResourceTypes, _, err := unstructured.NestedStringSlice(match, "excludedResourceTypes")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get string slice from spec.match.excludedResourceTypes: %w", err)
+	}
+	providers, _, err := unstructured.NestedStringSlice(match, "providers")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get string slice from spec.match.providers: %w", err)
+	}
+	actions, _, err := unstructured.NestedStringSlice(match, "actions")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get string slice from spec.match.actions: %w", err)
+	}
+
 	return &matcher{
-		addresses:         include,
-		excludedAddresses: exclude,
+		addresses:             include,
+		excludedAddresses:     exclude,
+		resourceTypes:         resourceTypes,
+		excludedResourceTypes: excludedResourceTypes,
+		providers:             providers,
+		actions:               actions,
 	}, nil
 }
 
@@ -95,6 +136,39 @@ func (g *TFTarget) MatchSchema() apiextensions.JSONSchemaProps {
 					},
 				},
 			},
+			"resourceTypes": {
+				Type: "array",
+				Items: &apiextensions.JSONSchemaPropsOrArray{
+					Schema: &apiextensions.JSONSchemaProps{
+						Type: "string",
+					},
+				},
+			},
+			"excludedResourceTypes": {
+				Type: "array",
+				Items: &apiextensions.JSONSchemaPropsOrArray{
+					Schema: &apiextensions.JSONSchemaProps{
+						Type: "string",
+					},
+				},
+			},
+			"providers": {
+				Type: "array",
+				Items: &apiextensions.JSONSchemaPropsOrArray{
+					Schema: &apiextensions.JSONSchemaProps{
+						Type: "string",
+					},
+				},
+			},
+			"actions": {
+				Type: "array",
+				Items: &apiextensions.JSONSchemaPropsOrArray{
+					Schema: &apiextensions.JSONSchemaProps{
+						Type: "string",
+						Enum: changeActions,
+					},
+				},
+			},
 		},
 	}
 }
@@ -137,8 +211,8 @@ func (g *TFTarget) HandleViolation(result *types.Result) error {
 
 var partRegex = regexp.MustCompile(`[\w.\-_\[\]\d]+`)
 
-// checkPathGlob
-func checkPathGlob(expression string) error {
+// checkAddressGlob returns an error if expression is not a glob of Terraform resource addresses.
+func checkAddressGlob(expression string) error {
 	// check for path components / numbers
 	parts := strings.Split(expression, ".")
 	for i := 0; i < len(parts); i++ {
@@ -154,9 +228,19 @@ func checkPathGlob(expression string) error {
 	return nil
 }
 
-func checkPathGlobs(rs []string) error {
+func checkAddressGlobs(rs []string) error {
 	for idx, r := range rs {
-		if err := checkPathGlob(r); err != nil {
+		if err := checkAddressGlob(r); err != nil {
+			return errors.Wrapf(err, "idx: %d", idx)
+		}
+	}
+	return nil
+}
+
+// checkGlobs checks that each of the patterns is a valid glob.
+func checkGlobs(rs []string, separators ...rune) error {
+	for idx, r := range rs {
+		if _, err := glob.Compile(r, separators...); err != nil {
 			return errors.Wrapf(err, "idx: %d", idx)
 		}
 	}
@@ -170,7 +254,7 @@ func (g *TFTarget) ValidateConstraint(constraint *unstructured.Unstructured) err
 		return errors.Errorf("invalid spec.match.addresses: %s", err)
 	}
 	if found {
-		if err := checkPathGlobs(includes); err != nil {
+		if err := checkAddressGlobs(includes); err != nil {
 			return errors.Wrapf(err, "invalid glob in target")
 		}
 	}
@@ -179,9 +263,25 @@ func (g *TFTarget) ValidateConstraint(constraint *unstructured.Unstructured) err
 		return errors.Errorf("invalid spec.match.excludedAddresses: %s", err)
 	}
 	if found {
-		if err := checkPathGlobs(excludes); err != nil {
+		if err := checkAddressGlobs(excludes); err != nil {
 			return errors.Wrapf(err, "invalid glob in exclude")
 		}
 	}
+	for _, field := range []string{"resourceTypes", "excludedResourceTypes"} {
+		resourceTypes, _, err := unstructured.NestedStringSlice(constraint.Object, "spec", "match", field)
+		if err != nil {
+			return errors.Errorf("invalid spec.match.%s: %s", field, err)
+		}
+		if err := checkGlobs(resourceTypes); err != nil {
+			return errors.Wrapf(err, "invalid glob in spec.match.%s", field)
+		}
+	}
+	providers, _, err := unstructured.NestedStringSlice(constraint.Object, "spec", "match", "providers")
+	if err != nil {
+		return errors.Errorf("invalid spec.match.providers: %s", err)
+	}
+	if err := checkGlobs(providers, '/'); err != nil {
+		return errors.Wrapf(err, "invalid glob in spec.match.providers")
+	}
 	return nil
 }
@@ -15,6 +15,7 @@
 package tftarget
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -34,6 +35,8 @@ type reviewTestData struct {
 	wantConstraintError bool
 	providerName        string
 	removeProviderBlock bool
+	resourceType        string
+	actions             []string
 }
 
 func (td *reviewTestData) jsonAssetTestcase() *targettesting.ReviewTestcase {
@@ -49,6 +52,20 @@ func (td *reviewTestData) jsonAssetTestcase() *targettesting.ReviewTestcase {
 		providerName = td.providerName
 	}
 
+	resourceType := "test-asset-type"
+	if td.resourceType != "" {
+		resourceType = td.resourceType
+	}
+
+	change := "{}"
+	if td.actions != nil {
+		actions, err := json.Marshal(td.actions)
+		if err != nil {
+			panic(err)
+		}
+		change = fmt.Sprintf(`{"actions": %s}`, actions)
+	}
+
 	providerBlock := ""
 	if td.removeProviderBlock != true {
 		providerBlock = fmt.Sprintf(`
@@ -59,12 +76,12 @@ func (td *reviewTestData) jsonAssetTestcase() *targettesting.ReviewTestcase {
 	tc.Object = targettesting.FromJSON(fmt.Sprintf(`
 {
   "name": "test-name",
-  "type": "test-asset-type",
+  "type": "%s",
   "address": "%s",
-  "change": {}
+  "change": %s
 	%s
 }
-`, td.address, providerBlock))
+`, resourceType, td.address, change, providerBlock))
 	return tc
 }
 
@@ -229,6 +246,126 @@ var testData = []reviewTestData{
 		wantMatch:           true,
 		removeProviderBlock: true,
 	},
+	// resource type and provider tests
+	{
+		name: "resource type glob match",
+		match: map[string]interface{}{
+			"resourceTypes": []interface{}{"google_compute_*"},
+		},
+		address:      "google_compute_instance.test",
+		resourceType: "google_compute_instance",
+		wantMatch:    true,
+	},
+	{
+		name: "resource type glob not match",
+		match: map[string]interface{}{
+			"resourceTypes": []interface{}{"google_compute_*"},
+		},
+		address:      "google_storage_bucket.test",
+		resourceType: "google_storage_bucket",
+		wantMatch:    false,
+	},
+	{
+		name: "excluded resource type",
+		match: map[string]interface{}{
+			"resourceTypes":         []interface{}{"google_compute_*"},
+			"excludedResourceTypes": []interface{}{"google_compute_firewall"},
+		},
+		address:      "google_compute_firewall.test",
+		resourceType: "google_compute_firewall",
+		wantMatch:    false,
+	},
+	{
+		name: "provider short name match",
+		match: map[string]interface{}{
+			"providers": []interface{}{"google-beta"},
+		},
+		address:      "google_compute_instance.test",
+		providerName: "registry.terraform.io/hashicorp/google-beta",
+		wantMatch:    true,
+	},
+	{
+		name: "provider full name match",
+		match: map[string]interface{}{
+			"providers": []interface{}{"registry.terraform.io/hashicorp/*"},
+		},
+		address:   "google_compute_instance.test",
+		wantMatch: true,
+	},
+	{
+		name: "provider not match",
+		match: map[string]interface{}{
+			"providers": []interface{}{"google-beta"},
+		},
+		address:   "google_compute_instance.test",
+		wantMatch: false,
+	},
+	{
+		name: "provider block missing with providers",
+		match: map[string]interface{}{
+			"providers": []interface{}{"google"},
+		},
+		address:             "google_compute_instance.test",
+		removeProviderBlock: true,
+		wantMatch:           false,
+	},
+	{
+		name: "Bad resourceTypes glob",
+		match: map[string]interface{}{
+			"resourceTypes": []interface{}{"google_[compute"},
+		},
+		wantConstraintError: true,
+	},
+	{
+		name: "Bad providers type",
+		match: map[string]interface{}{
+			"providers": "google",
+		},
+		wantConstraintError: true,
+	},
+	// change action tests
+	{
+		name: "action match",
+		match: map[string]interface{}{
+			"actions": []interface{}{"delete"},
+		},
+		address:   "google_kms_key_ring.test",
+		actions:   []string{"delete"},
+		wantMatch: true,
+	},
+	{
+		name: "replace matches delete",
+		match: map[string]interface{}{
+			"actions": []interface{}{"delete"},
+		},
+		address:   "google_kms_key_ring.test",
+		actions:   []string{"delete", "create"},
+		wantMatch: true,
+	},
+	{
+		name: "action not match",
+		match: map[string]interface{}{
+			"actions": []interface{}{"create", "update"},
+		},
+		address:   "google_kms_key_ring.test",
+		actions:   []string{"no-op"},
+		wantMatch: false,
+	},
+	{
+		name: "actions missing",
+		match: map[string]interface{}{
+			"actions": []interface{}{"create"},
+		},
+		address:   "google_kms_key_ring.test",
+		wantMatch: false,
+	},
+	{
+		name: "Bad actions value",
+		match: map[string]interface{}{
+			"actions": []interface{}{"destroy"},
+		},
+		wantConstraintError: true,
+	},
 
 	{
 		name: "Bad target type",
@@ -325,6 +462,24 @@ func TestToMatcher(t *testing.T) {
 			),
 			wantErr: true,
 		},
+		{
+			name: "non string slice type in resourceTypes",
+			constraint: cts.MakeConstraint(t,
+				"kind",
+				"name",
+				cts.Set("abc", "spec", "match", "resourceTypes"),
+			),
+			wantErr: true,
+		},
+		{
+			name: "non string slice type in providers",
+			constraint: cts.MakeConstraint(t,
+				"kind",
+				"name",
+				cts.Set("abc", "spec", "match", "providers"),
+			),
+			wantErr: true,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {